@@ -0,0 +1,36 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// startTime is when the agent started, for gpu_collector_uptime_seconds.
+var startTime = time.Now()
+
+// agentCollectors report which build of the collector runs on a node and for how long,
+// so a fleet can be checked for stragglers after an upgrade and restarts show up.
+func agentCollectors() []prometheus.Collector {
+	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gpu_collector_build_info",
+		Help: "Always 1; the labels are the version, Go version and platform of the running collector.",
+	}, []string{"version", "goversion", "goos", "goarch"})
+	buildInfo.WithLabelValues(version, runtime.Version(), runtime.GOOS, runtime.GOARCH).Set(1)
+
+	return []prometheus.Collector{
+		buildInfo,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gpu_collector_start_time_seconds",
+			Help: "Unix time the collector started.",
+		}, func() float64 { return float64(startTime.UnixNano()) / 1e9 }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gpu_collector_uptime_seconds",
+			Help: "Seconds since the collector started.",
+		}, func() float64 { return time.Since(startTime).Seconds() }),
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+
+	"gpu-collector/aggregatorpb"
+)
+
+//go:generate protoc -I ../proto --go_out=aggregatorpb --go_opt=paths=source_relative,Maggregator.proto=gpu-collector/aggregatorpb --go-grpc_out=aggregatorpb --go-grpc_opt=paths=source_relative,Maggregator.proto=gpu-collector/aggregatorpb aggregator.proto
+
+var aggregatorErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "gpu_collector_aggregator_errors_total",
+	Help: "Streams to the aggregator that failed; the agent reconnects after AGGREGATOR_INTERVAL.",
+})
+
+// aggregatorClient streams the collector's metrics, inventory and alerts to the central
+// aggregator over gRPC, for nodes Prometheus cannot scrape. Alerts are written to the
+// stream as soon as they are queued; alerts of a report that could not be written are
+// sent again on the next stream.
+type aggregatorClient struct {
+	url      string
+	node     string
+	interval time.Duration
+	conn     *grpc.ClientConn
+	client   aggregatorpb.AggregatorClient
+	// gatherer and backend are set once the registry is built.
+	gatherer prometheus.Gatherer
+	backend  gpuBackend
+
+	mu sync.Mutex
+	// pending holds the alerts not yet written to a stream.
+	pending []webhookAlert
+	wake    chan struct{}
+}
+
+func newAggregatorClient(aggregatorURL, node string, interval time.Duration, tlsConfig *tls.Config) (*aggregatorClient, error) {
+	u, err := url.Parse(aggregatorURL)
+	if err != nil {
+		return nil, err
+	}
+	target := u.Host
+	if u.Port() == "" {
+		target = net.JoinHostPort(u.Hostname(), "443")
+	}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithUserAgent("gpu-collector/"+version),
+		// The stream stays open; pings detect a dead connection.
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: 30 * time.Second, Timeout: 15 * time.Second, PermitWithoutStream: true}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregatorClient{
+		url:      strings.TrimRight(aggregatorURL, "/"),
+		node:     node,
+		interval: interval,
+		conn:     conn,
+		client:   aggregatorpb.NewAggregatorClient(conn),
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// newAggregatorTLSConfig verifies the aggregator with the CA bundle at caFile (default:
+// the system roots) and presents the client certificate at certFile and keyFile, if set.
+func newAggregatorTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// queue adds alerts to the next report and sends it right away.
+func (c *aggregatorClient) queue(alerts []webhookAlert) {
+	c.mu.Lock()
+	c.pending = append(c.pending, alerts...)
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run keeps a stream open, reconnecting every interval after an error.
+func (c *aggregatorClient) run() {
+	for {
+		if err := c.stream(); err != nil {
+			aggregatorErrors.Inc()
+			log.Printf("Error streaming to the aggregator at %s: %v", c.url, err)
+		}
+		time.Sleep(c.interval)
+	}
+}
+
+// stream opens a Push stream and sends a report every interval, and whenever alerts
+// are queued, until the stream fails.
+func (c *aggregatorClient) stream() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.client.Push(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The aggregator only answers when it ends the stream.
+	done := make(chan error, 1)
+	go func() {
+		err := stream.RecvMsg(new(aggregatorpb.PushResponse))
+		if err == nil {
+			err = errors.New("the aggregator ended the stream")
+		}
+		done <- err
+	}()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		c.mu.Lock()
+		alerts := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+
+		if err := stream.Send(c.report(first, alerts)); err != nil {
+			c.mu.Lock()
+			c.pending = append(alerts, c.pending...)
+			c.mu.Unlock()
+			// Send only reports io.EOF; the status comes with the response.
+			return <-done
+		}
+		select {
+		case <-ticker.C:
+		case <-c.wake:
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// report builds the next NodeReport; the first report of a stream carries the
+// inventory.
+func (c *aggregatorClient) report(withInventory bool, alerts []webhookAlert) *aggregatorpb.NodeReport {
+	families, err := c.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for the aggregator: %v", err)
+	}
+	var inventory *nodeInventory
+	if withInventory {
+		if inv, err := c.backend.Inventory(); err != nil {
+			log.Printf("Error reading GPU inventory for the aggregator: %v", err)
+		} else {
+			inv.Hostname, _ = os.Hostname()
+			inventory = &inv
+		}
+	}
+	return newNodeReport(c.node, time.Now(), families, inventory, alerts)
+}
+
+// newNodeReport builds a NodeReport of proto/aggregator.proto. Only gauges, counters
+// and untyped metrics are reported, which is all the collector exports.
+func newNodeReport(node string, now time.Time, families []*dto.MetricFamily, inventory *nodeInventory, alerts []webhookAlert) *aggregatorpb.NodeReport {
+	report := &aggregatorpb.NodeReport{Node: node, TimestampMs: now.UnixMilli(), AgentVersion: version}
+	for _, family := range families {
+		var metricType aggregatorpb.MetricType
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			metricType = aggregatorpb.MetricType_METRIC_TYPE_GAUGE
+		case dto.MetricType_COUNTER:
+			metricType = aggregatorpb.MetricType_METRIC_TYPE_COUNTER
+		case dto.MetricType_UNTYPED:
+			metricType = aggregatorpb.MetricType_METRIC_TYPE_UNTYPED
+		default:
+			continue
+		}
+		f := &aggregatorpb.MetricFamily{Name: family.GetName(), Help: family.GetHelp(), Type: metricType}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			var value float64
+			switch {
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			default:
+				value = m.GetUntyped().GetValue()
+			}
+			f.Series = append(f.Series, &aggregatorpb.Series{Labels: labels, Value: value})
+		}
+		report.Metrics = append(report.Metrics, f)
+	}
+	if inventory != nil {
+		report.Inventory = newInventoryReport(*inventory)
+	}
+	for _, alert := range alerts {
+		report.Alerts = append(report.Alerts, &aggregatorpb.Alert{
+			Status:      alert.Status,
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    alert.StartsAt,
+			EndsAt:      alert.EndsAt,
+		})
+	}
+	return report
+}
+
+func newInventoryReport(inventory nodeInventory) *aggregatorpb.Inventory {
+	report := &aggregatorpb.Inventory{
+		Hostname:      inventory.Hostname,
+		DriverVersion: inventory.DriverVersion,
+		CudaVersion:   inventory.CUDAVersion,
+	}
+	for _, gpu := range inventory.GPUs {
+		report.Gpus = append(report.Gpus, &aggregatorpb.GPU{
+			Index:             int64(gpu.Index),
+			Uuid:              gpu.UUID,
+			Name:              gpu.Name,
+			Serial:            gpu.Serial,
+			VbiosVersion:      gpu.VBIOSVersion,
+			MemoryTotalBytes:  gpu.MemoryTotalBytes,
+			PciBusId:          gpu.PCIBusID,
+			PcieGeneration:    int64(gpu.PCIeGeneration),
+			PcieMaxGeneration: int64(gpu.PCIeMaxGeneration),
+			PcieWidth:         int64(gpu.PCIeWidth),
+			PcieMaxWidth:      int64(gpu.PCIeMaxWidth),
+		})
+	}
+	return report
+}
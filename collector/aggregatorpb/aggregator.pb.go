@@ -0,0 +1,742 @@
+// The gRPC service between the node agents (gpu-collector) and the central aggregator
+// (alertmanager-adapter), for deployments where Prometheus cannot scrape the nodes.
+// Agents connect with mTLS and keep one Push stream open, sending a report every
+// interval and as soon as they raise or resolve an alert.
+//
+// The Go stubs are generated into collector/aggregatorpb and
+// gchat_adapter_build/internal/aggregatorpb with "go generate" in each module (protoc,
+// protoc-gen-go and protoc-gen-go-grpc); regenerate both after changing this file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: aggregator.proto
+
+package aggregatorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MetricType int32
+
+const (
+	MetricType_METRIC_TYPE_UNTYPED MetricType = 0
+	MetricType_METRIC_TYPE_GAUGE   MetricType = 1
+	MetricType_METRIC_TYPE_COUNTER MetricType = 2
+)
+
+// Enum value maps for MetricType.
+var (
+	MetricType_name = map[int32]string{
+		0: "METRIC_TYPE_UNTYPED",
+		1: "METRIC_TYPE_GAUGE",
+		2: "METRIC_TYPE_COUNTER",
+	}
+	MetricType_value = map[string]int32{
+		"METRIC_TYPE_UNTYPED": 0,
+		"METRIC_TYPE_GAUGE":   1,
+		"METRIC_TYPE_COUNTER": 2,
+	}
+)
+
+func (x MetricType) Enum() *MetricType {
+	p := new(MetricType)
+	*p = x
+	return p
+}
+
+func (x MetricType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MetricType) Descriptor() protoreflect.EnumDescriptor {
+	return file_aggregator_proto_enumTypes[0].Descriptor()
+}
+
+func (MetricType) Type() protoreflect.EnumType {
+	return &file_aggregator_proto_enumTypes[0]
+}
+
+func (x MetricType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MetricType.Descriptor instead.
+func (MetricType) EnumDescriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{0}
+}
+
+type NodeReport struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// node is the agent's NODE_NAME (default: hostname); it becomes the instance label
+	// of its metrics and alerts.
+	Node         string `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	TimestampMs  int64  `protobuf:"varint,2,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	AgentVersion string `protobuf:"bytes,3,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	// metrics are the agent's metrics as a scrape would see them.
+	Metrics []*MetricFamily `protobuf:"bytes,4,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	// inventory is set on the first report of every stream.
+	Inventory *Inventory `protobuf:"bytes,5,opt,name=inventory,proto3" json:"inventory,omitempty"`
+	// alerts are the agent's alerts that changed state since the previous report.
+	Alerts        []*Alert `protobuf:"bytes,6,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeReport) Reset() {
+	*x = NodeReport{}
+	mi := &file_aggregator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeReport) ProtoMessage() {}
+
+func (x *NodeReport) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeReport.ProtoReflect.Descriptor instead.
+func (*NodeReport) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NodeReport) GetNode() string {
+	if x != nil {
+		return x.Node
+	}
+	return ""
+}
+
+func (x *NodeReport) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *NodeReport) GetAgentVersion() string {
+	if x != nil {
+		return x.AgentVersion
+	}
+	return ""
+}
+
+func (x *NodeReport) GetMetrics() []*MetricFamily {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *NodeReport) GetInventory() *Inventory {
+	if x != nil {
+		return x.Inventory
+	}
+	return nil
+}
+
+func (x *NodeReport) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+type MetricFamily struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Help          string                 `protobuf:"bytes,2,opt,name=help,proto3" json:"help,omitempty"`
+	Type          MetricType             `protobuf:"varint,3,opt,name=type,proto3,enum=gpunodemonitor.v1.MetricType" json:"type,omitempty"`
+	Series        []*Series              `protobuf:"bytes,4,rep,name=series,proto3" json:"series,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricFamily) Reset() {
+	*x = MetricFamily{}
+	mi := &file_aggregator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricFamily) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricFamily) ProtoMessage() {}
+
+func (x *MetricFamily) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricFamily.ProtoReflect.Descriptor instead.
+func (*MetricFamily) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MetricFamily) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MetricFamily) GetHelp() string {
+	if x != nil {
+		return x.Help
+	}
+	return ""
+}
+
+func (x *MetricFamily) GetType() MetricType {
+	if x != nil {
+		return x.Type
+	}
+	return MetricType_METRIC_TYPE_UNTYPED
+}
+
+func (x *MetricFamily) GetSeries() []*Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type Series struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Labels        map[string]string      `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Value         float64                `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Series) Reset() {
+	*x = Series{}
+	mi := &file_aggregator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Series) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Series) ProtoMessage() {}
+
+func (x *Series) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Series.ProtoReflect.Descriptor instead.
+func (*Series) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Series) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Series) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+// Inventory mirrors the agent's /api/inventory.
+type Inventory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hostname      string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	DriverVersion string                 `protobuf:"bytes,2,opt,name=driver_version,json=driverVersion,proto3" json:"driver_version,omitempty"`
+	CudaVersion   string                 `protobuf:"bytes,3,opt,name=cuda_version,json=cudaVersion,proto3" json:"cuda_version,omitempty"`
+	Gpus          []*GPU                 `protobuf:"bytes,4,rep,name=gpus,proto3" json:"gpus,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Inventory) Reset() {
+	*x = Inventory{}
+	mi := &file_aggregator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Inventory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Inventory) ProtoMessage() {}
+
+func (x *Inventory) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Inventory.ProtoReflect.Descriptor instead.
+func (*Inventory) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Inventory) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Inventory) GetDriverVersion() string {
+	if x != nil {
+		return x.DriverVersion
+	}
+	return ""
+}
+
+func (x *Inventory) GetCudaVersion() string {
+	if x != nil {
+		return x.CudaVersion
+	}
+	return ""
+}
+
+func (x *Inventory) GetGpus() []*GPU {
+	if x != nil {
+		return x.Gpus
+	}
+	return nil
+}
+
+type GPU struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Index             int64                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Uuid              string                 `protobuf:"bytes,2,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Name              string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Serial            string                 `protobuf:"bytes,4,opt,name=serial,proto3" json:"serial,omitempty"`
+	VbiosVersion      string                 `protobuf:"bytes,5,opt,name=vbios_version,json=vbiosVersion,proto3" json:"vbios_version,omitempty"`
+	MemoryTotalBytes  uint64                 `protobuf:"varint,6,opt,name=memory_total_bytes,json=memoryTotalBytes,proto3" json:"memory_total_bytes,omitempty"`
+	PciBusId          string                 `protobuf:"bytes,7,opt,name=pci_bus_id,json=pciBusId,proto3" json:"pci_bus_id,omitempty"`
+	PcieGeneration    int64                  `protobuf:"varint,8,opt,name=pcie_generation,json=pcieGeneration,proto3" json:"pcie_generation,omitempty"`
+	PcieMaxGeneration int64                  `protobuf:"varint,9,opt,name=pcie_max_generation,json=pcieMaxGeneration,proto3" json:"pcie_max_generation,omitempty"`
+	PcieWidth         int64                  `protobuf:"varint,10,opt,name=pcie_width,json=pcieWidth,proto3" json:"pcie_width,omitempty"`
+	PcieMaxWidth      int64                  `protobuf:"varint,11,opt,name=pcie_max_width,json=pcieMaxWidth,proto3" json:"pcie_max_width,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *GPU) Reset() {
+	*x = GPU{}
+	mi := &file_aggregator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GPU) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GPU) ProtoMessage() {}
+
+func (x *GPU) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GPU.ProtoReflect.Descriptor instead.
+func (*GPU) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GPU) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *GPU) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *GPU) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GPU) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *GPU) GetVbiosVersion() string {
+	if x != nil {
+		return x.VbiosVersion
+	}
+	return ""
+}
+
+func (x *GPU) GetMemoryTotalBytes() uint64 {
+	if x != nil {
+		return x.MemoryTotalBytes
+	}
+	return 0
+}
+
+func (x *GPU) GetPciBusId() string {
+	if x != nil {
+		return x.PciBusId
+	}
+	return ""
+}
+
+func (x *GPU) GetPcieGeneration() int64 {
+	if x != nil {
+		return x.PcieGeneration
+	}
+	return 0
+}
+
+func (x *GPU) GetPcieMaxGeneration() int64 {
+	if x != nil {
+		return x.PcieMaxGeneration
+	}
+	return 0
+}
+
+func (x *GPU) GetPcieWidth() int64 {
+	if x != nil {
+		return x.PcieWidth
+	}
+	return 0
+}
+
+func (x *GPU) GetPcieMaxWidth() int64 {
+	if x != nil {
+		return x.PcieMaxWidth
+	}
+	return 0
+}
+
+// Alert is an alert in Alertmanager's webhook format; times are RFC 3339.
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations   map[string]string      `protobuf:"bytes,3,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	StartsAt      string                 `protobuf:"bytes,4,opt,name=starts_at,json=startsAt,proto3" json:"starts_at,omitempty"`
+	EndsAt        string                 `protobuf:"bytes,5,opt,name=ends_at,json=endsAt,proto3" json:"ends_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_aggregator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Alert) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Alert) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Alert) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+func (x *Alert) GetStartsAt() string {
+	if x != nil {
+		return x.StartsAt
+	}
+	return ""
+}
+
+func (x *Alert) GetEndsAt() string {
+	if x != nil {
+		return x.EndsAt
+	}
+	return ""
+}
+
+type PushResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// reports is how many reports of the stream the aggregator accepted.
+	Reports       uint64 `protobuf:"varint,1,opt,name=reports,proto3" json:"reports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushResponse) Reset() {
+	*x = PushResponse{}
+	mi := &file_aggregator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushResponse) ProtoMessage() {}
+
+func (x *PushResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_aggregator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushResponse.ProtoReflect.Descriptor instead.
+func (*PushResponse) Descriptor() ([]byte, []int) {
+	return file_aggregator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PushResponse) GetReports() uint64 {
+	if x != nil {
+		return x.Reports
+	}
+	return 0
+}
+
+var File_aggregator_proto protoreflect.FileDescriptor
+
+const file_aggregator_proto_rawDesc = "" +
+	"\n" +
+	"\x10aggregator.proto\x12\x11gpunodemonitor.v1\"\x91\x02\n" +
+	"\n" +
+	"NodeReport\x12\x12\n" +
+	"\x04node\x18\x01 \x01(\tR\x04node\x12!\n" +
+	"\ftimestamp_ms\x18\x02 \x01(\x03R\vtimestampMs\x12#\n" +
+	"\ragent_version\x18\x03 \x01(\tR\fagentVersion\x129\n" +
+	"\ametrics\x18\x04 \x03(\v2\x1f.gpunodemonitor.v1.MetricFamilyR\ametrics\x12:\n" +
+	"\tinventory\x18\x05 \x01(\v2\x1c.gpunodemonitor.v1.InventoryR\tinventory\x120\n" +
+	"\x06alerts\x18\x06 \x03(\v2\x18.gpunodemonitor.v1.AlertR\x06alerts\"\x9c\x01\n" +
+	"\fMetricFamily\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04help\x18\x02 \x01(\tR\x04help\x121\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x1d.gpunodemonitor.v1.MetricTypeR\x04type\x121\n" +
+	"\x06series\x18\x04 \x03(\v2\x19.gpunodemonitor.v1.SeriesR\x06series\"\x98\x01\n" +
+	"\x06Series\x12=\n" +
+	"\x06labels\x18\x01 \x03(\v2%.gpunodemonitor.v1.Series.LabelsEntryR\x06labels\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9d\x01\n" +
+	"\tInventory\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12%\n" +
+	"\x0edriver_version\x18\x02 \x01(\tR\rdriverVersion\x12!\n" +
+	"\fcuda_version\x18\x03 \x01(\tR\vcudaVersion\x12*\n" +
+	"\x04gpus\x18\x04 \x03(\v2\x16.gpunodemonitor.v1.GPUR\x04gpus\"\xea\x02\n" +
+	"\x03GPU\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x12\n" +
+	"\x04uuid\x18\x02 \x01(\tR\x04uuid\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x16\n" +
+	"\x06serial\x18\x04 \x01(\tR\x06serial\x12#\n" +
+	"\rvbios_version\x18\x05 \x01(\tR\fvbiosVersion\x12,\n" +
+	"\x12memory_total_bytes\x18\x06 \x01(\x04R\x10memoryTotalBytes\x12\x1c\n" +
+	"\n" +
+	"pci_bus_id\x18\a \x01(\tR\bpciBusId\x12'\n" +
+	"\x0fpcie_generation\x18\b \x01(\x03R\x0epcieGeneration\x12.\n" +
+	"\x13pcie_max_generation\x18\t \x01(\x03R\x11pcieMaxGeneration\x12\x1d\n" +
+	"\n" +
+	"pcie_width\x18\n" +
+	" \x01(\x03R\tpcieWidth\x12$\n" +
+	"\x0epcie_max_width\x18\v \x01(\x03R\fpcieMaxWidth\"\xdb\x02\n" +
+	"\x05Alert\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12<\n" +
+	"\x06labels\x18\x02 \x03(\v2$.gpunodemonitor.v1.Alert.LabelsEntryR\x06labels\x12K\n" +
+	"\vannotations\x18\x03 \x03(\v2).gpunodemonitor.v1.Alert.AnnotationsEntryR\vannotations\x12\x1b\n" +
+	"\tstarts_at\x18\x04 \x01(\tR\bstartsAt\x12\x17\n" +
+	"\aends_at\x18\x05 \x01(\tR\x06endsAt\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"(\n" +
+	"\fPushResponse\x12\x18\n" +
+	"\areports\x18\x01 \x01(\x04R\areports*U\n" +
+	"\n" +
+	"MetricType\x12\x17\n" +
+	"\x13METRIC_TYPE_UNTYPED\x10\x00\x12\x15\n" +
+	"\x11METRIC_TYPE_GAUGE\x10\x01\x12\x17\n" +
+	"\x13METRIC_TYPE_COUNTER\x10\x022V\n" +
+	"\n" +
+	"Aggregator\x12H\n" +
+	"\x04Push\x12\x1d.gpunodemonitor.v1.NodeReport\x1a\x1f.gpunodemonitor.v1.PushResponse(\x01b\x06proto3"
+
+var (
+	file_aggregator_proto_rawDescOnce sync.Once
+	file_aggregator_proto_rawDescData []byte
+)
+
+func file_aggregator_proto_rawDescGZIP() []byte {
+	file_aggregator_proto_rawDescOnce.Do(func() {
+		file_aggregator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_aggregator_proto_rawDesc), len(file_aggregator_proto_rawDesc)))
+	})
+	return file_aggregator_proto_rawDescData
+}
+
+var file_aggregator_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_aggregator_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_aggregator_proto_goTypes = []any{
+	(MetricType)(0),      // 0: gpunodemonitor.v1.MetricType
+	(*NodeReport)(nil),   // 1: gpunodemonitor.v1.NodeReport
+	(*MetricFamily)(nil), // 2: gpunodemonitor.v1.MetricFamily
+	(*Series)(nil),       // 3: gpunodemonitor.v1.Series
+	(*Inventory)(nil),    // 4: gpunodemonitor.v1.Inventory
+	(*GPU)(nil),          // 5: gpunodemonitor.v1.GPU
+	(*Alert)(nil),        // 6: gpunodemonitor.v1.Alert
+	(*PushResponse)(nil), // 7: gpunodemonitor.v1.PushResponse
+	nil,                  // 8: gpunodemonitor.v1.Series.LabelsEntry
+	nil,                  // 9: gpunodemonitor.v1.Alert.LabelsEntry
+	nil,                  // 10: gpunodemonitor.v1.Alert.AnnotationsEntry
+}
+var file_aggregator_proto_depIdxs = []int32{
+	2,  // 0: gpunodemonitor.v1.NodeReport.metrics:type_name -> gpunodemonitor.v1.MetricFamily
+	4,  // 1: gpunodemonitor.v1.NodeReport.inventory:type_name -> gpunodemonitor.v1.Inventory
+	6,  // 2: gpunodemonitor.v1.NodeReport.alerts:type_name -> gpunodemonitor.v1.Alert
+	0,  // 3: gpunodemonitor.v1.MetricFamily.type:type_name -> gpunodemonitor.v1.MetricType
+	3,  // 4: gpunodemonitor.v1.MetricFamily.series:type_name -> gpunodemonitor.v1.Series
+	8,  // 5: gpunodemonitor.v1.Series.labels:type_name -> gpunodemonitor.v1.Series.LabelsEntry
+	5,  // 6: gpunodemonitor.v1.Inventory.gpus:type_name -> gpunodemonitor.v1.GPU
+	9,  // 7: gpunodemonitor.v1.Alert.labels:type_name -> gpunodemonitor.v1.Alert.LabelsEntry
+	10, // 8: gpunodemonitor.v1.Alert.annotations:type_name -> gpunodemonitor.v1.Alert.AnnotationsEntry
+	1,  // 9: gpunodemonitor.v1.Aggregator.Push:input_type -> gpunodemonitor.v1.NodeReport
+	7,  // 10: gpunodemonitor.v1.Aggregator.Push:output_type -> gpunodemonitor.v1.PushResponse
+	10, // [10:11] is the sub-list for method output_type
+	9,  // [9:10] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_aggregator_proto_init() }
+func file_aggregator_proto_init() {
+	if File_aggregator_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_aggregator_proto_rawDesc), len(file_aggregator_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_aggregator_proto_goTypes,
+		DependencyIndexes: file_aggregator_proto_depIdxs,
+		EnumInfos:         file_aggregator_proto_enumTypes,
+		MessageInfos:      file_aggregator_proto_msgTypes,
+	}.Build()
+	File_aggregator_proto = out.File
+	file_aggregator_proto_goTypes = nil
+	file_aggregator_proto_depIdxs = nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	chassisFanSpeedDesc = prometheus.NewDesc("chassis_fan_speed_rpm",
+		"Speed of a chassis fan read from the node's BMC.", []string{"sensor"}, nil)
+	chassisFanHealthyDesc = prometheus.NewDesc("chassis_fan_healthy",
+		"1 if the BMC reports the chassis fan as working, 0 if it failed or is outside its thresholds.", []string{"sensor"}, nil)
+	chassisTemperatureDesc = prometheus.NewDesc("chassis_temperature_celsius",
+		"Chassis temperature sensor read from the node's BMC; inlet is \"true\" for the air intake (inlet or ambient) sensors.", []string{"sensor", "inlet"}, nil)
+	chassisPSUHealthyDesc = prometheus.NewDesc("chassis_psu_healthy",
+		"1 if the BMC reports the power supply as working, 0 if it failed or lost its input.", []string{"sensor"}, nil)
+	chassisReadErrorDesc = prometheus.NewDesc("chassis_sensors_read_error",
+		"1 if the last attempt to read the chassis sensors from the BMC failed, 0 otherwise.", nil, nil)
+)
+
+// chassisSensor is one fan, temperature or power supply sensor. value is nil for
+// sensors without a numeric reading, like most power supplies.
+type chassisSensor struct {
+	name    string
+	value   *float64
+	healthy bool
+	inlet   bool
+}
+
+// chassisReading is everything read from the BMC in one pass.
+type chassisReading struct {
+	fans, temperatures, psus []chassisSensor
+}
+
+// inletPattern recognizes the sensors that measure the air entering the chassis.
+var inletPattern = regexp.MustCompile(`(?i)inlet|intake|ambient`)
+
+// chassisMonitor reads the chassis sensors in the background, since a BMC can take
+// seconds to answer, and reports the last reading on every scrape.
+type chassisMonitor struct {
+	read func(ctx context.Context) (chassisReading, error)
+
+	mu      sync.Mutex
+	reading chassisReading
+	err     error
+}
+
+func newChassisMonitor(read func(ctx context.Context) (chassisReading, error)) *chassisMonitor {
+	return &chassisMonitor{read: read}
+}
+
+// run reads the sensors every interval, each read bounded by the interval.
+func (m *chassisMonitor) run(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		reading, err := m.read(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Error reading chassis sensors: %v", err)
+		}
+
+		m.mu.Lock()
+		if err == nil {
+			m.reading = reading
+		}
+		m.err = err
+		m.mu.Unlock()
+		time.Sleep(interval)
+	}
+}
+
+func (m *chassisMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- chassisFanSpeedDesc
+	ch <- chassisFanHealthyDesc
+	ch <- chassisTemperatureDesc
+	ch <- chassisPSUHealthyDesc
+	ch <- chassisReadErrorDesc
+}
+
+func (m *chassisMonitor) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	reading, err := m.reading, m.err
+	m.mu.Unlock()
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	ch <- prometheus.MustNewConstMetric(chassisReadErrorDesc, prometheus.GaugeValue, failed)
+
+	for _, s := range reading.fans {
+		gauge(ch, chassisFanSpeedDesc, s.value, s.name)
+		gauge(ch, chassisFanHealthyDesc, healthValue(s.healthy), s.name)
+	}
+	for _, s := range reading.temperatures {
+		gauge(ch, chassisTemperatureDesc, s.value, s.name, strconv.FormatBool(s.inlet))
+	}
+	for _, s := range reading.psus {
+		gauge(ch, chassisPSUHealthyDesc, healthValue(s.healthy), s.name)
+	}
+}
+
+func healthValue(healthy bool) *float64 {
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	return &v
+}
+
+// readIPMISensors reads the sensors with ipmitool from the local BMC (/dev/ipmi0).
+func readIPMISensors(ipmitool string) func(ctx context.Context) (chassisReading, error) {
+	return func(ctx context.Context) (chassisReading, error) {
+		var reading chassisReading
+		for _, sensorType := range []struct {
+			name   string
+			target *[]chassisSensor
+		}{
+			{"Fan", &reading.fans},
+			{"Temperature", &reading.temperatures},
+			{"Power Supply", &reading.psus},
+		} {
+			out, err := exec.CommandContext(ctx, ipmitool, "sdr", "type", sensorType.name).Output()
+			if err != nil {
+				return chassisReading{}, fmt.Errorf("ipmitool sdr type %s: %w", sensorType.name, err)
+			}
+			*sensorType.target = parseIPMISensors(bytes.NewReader(out), sensorType.name == "Power Supply")
+		}
+		return reading, nil
+	}
+}
+
+// parseIPMISensors parses the output of "ipmitool sdr type", one sensor per line:
+//
+//	FAN3             | 32h | cr  | 29.3 | 0 RPM
+//	Inlet Temp       | 04h | ok  |  7.1 | 24 degrees C
+//	PS2 Status       | 60h | ok  | 10.2 | Presence detected, Power Supply AC lost
+//
+// Sensors that are not present ("ns") are skipped. A power supply is healthy when its
+// state only reports its presence.
+func parseIPMISensors(r io.Reader, psu bool) []chassisSensor {
+	var sensors []chassisSensor
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 5 {
+			continue
+		}
+		name, status, reading := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[2]), strings.TrimSpace(fields[4])
+		if status == "ns" || name == "" {
+			continue
+		}
+		s := chassisSensor{name: name, healthy: status == "ok", inlet: inletPattern.MatchString(name)}
+		if psu {
+			s.healthy = s.healthy && !strings.Contains(reading, "lost") && !strings.Contains(reading, "ailure") && !strings.Contains(reading, "rror")
+		} else if value, _, ok := strings.Cut(reading, " "); ok {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s.value = &f
+			}
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors
+}
+
+// redfishClient reads the sensors from a BMC's Redfish API, through the legacy
+// Thermal and Power resources of every chassis, which current BMCs still serve.
+type redfishClient struct {
+	baseURL            string
+	username, password string
+	client             *http.Client
+}
+
+func newRedfishClient(baseURL, username, password string, insecure bool) *redfishClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		// BMCs usually serve a self-signed certificate.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &redfishClient{baseURL: strings.TrimRight(baseURL, "/"), username: username, password: password, client: &http.Client{Transport: transport}}
+}
+
+type redfishStatus struct {
+	State  string `json:"State"`
+	Health string `json:"Health"`
+}
+
+// present reports whether the component is installed; absent fans and PSUs are skipped.
+func (s redfishStatus) present() bool {
+	return s.State != "Absent"
+}
+
+func (s redfishStatus) healthy() bool {
+	return s.Health == "" || s.Health == "OK"
+}
+
+func (c *redfishClient) read(ctx context.Context) (chassisReading, error) {
+	var collection struct {
+		Members []struct {
+			ID string `json:"@odata.id"`
+		} `json:"Members"`
+	}
+	if err := c.get(ctx, "/redfish/v1/Chassis", &collection); err != nil {
+		return chassisReading{}, err
+	}
+
+	var reading chassisReading
+	for _, member := range collection.Members {
+		var thermal struct {
+			Fans []struct {
+				Name    string        `json:"Name"`
+				Reading *float64      `json:"Reading"`
+				Units   string        `json:"ReadingUnits"`
+				Status  redfishStatus `json:"Status"`
+			} `json:"Fans"`
+			Temperatures []struct {
+				Name            string        `json:"Name"`
+				ReadingCelsius  *float64      `json:"ReadingCelsius"`
+				PhysicalContext string        `json:"PhysicalContext"`
+				Status          redfishStatus `json:"Status"`
+			} `json:"Temperatures"`
+		}
+		// Not every chassis (e.g. a GPU baseboard) has thermal or power resources.
+		if err := c.get(ctx, member.ID+"/Thermal", &thermal); err == nil {
+			for _, f := range thermal.Fans {
+				if !f.Status.present() {
+					continue
+				}
+				s := chassisSensor{name: f.Name, healthy: f.Status.healthy()}
+				// Fans reporting a percentage have no RPM reading.
+				if f.Units == "" || f.Units == "RPM" {
+					s.value = f.Reading
+				}
+				reading.fans = append(reading.fans, s)
+			}
+			for _, t := range thermal.Temperatures {
+				if !t.Status.present() || t.ReadingCelsius == nil {
+					continue
+				}
+				reading.temperatures = append(reading.temperatures, chassisSensor{
+					name:    t.Name,
+					value:   t.ReadingCelsius,
+					healthy: t.Status.healthy(),
+					inlet:   t.PhysicalContext == "Intake" || inletPattern.MatchString(t.Name),
+				})
+			}
+		}
+
+		var power struct {
+			PowerSupplies []struct {
+				Name   string        `json:"Name"`
+				Status redfishStatus `json:"Status"`
+			} `json:"PowerSupplies"`
+		}
+		if err := c.get(ctx, member.ID+"/Power", &power); err == nil {
+			for _, p := range power.PowerSupplies {
+				if p.Status.present() {
+					reading.psus = append(reading.psus, chassisSensor{name: p.Name, healthy: p.Status.healthy()})
+				}
+			}
+		}
+	}
+	return reading, nil
+}
+
+func (c *redfishClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", path, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(v)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// dcgmBackend reads GPU state by scraping an NVIDIA dcgm-exporter, which gives access to
+// DCGM's datacenter health fields (XID errors, NVLink errors, thermal violations and
+// page retirement) that plain NVML polling does not track.
+type dcgmBackend struct {
+	url    string
+	client *http.Client
+}
+
+func newDCGMBackend(url string) *dcgmBackend {
+	return &dcgmBackend{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// dcgmField applies the value of one dcgm-exporter metric to a sample.
+type dcgmField func(s *gpuSample, v float64)
+
+// dcgmFields maps dcgm-exporter metric names to sample fields. Fields that only exist
+// when enabled in the exporter's counter CSV are simply absent otherwise.
+var dcgmFields = map[string]dcgmField{
+	"DCGM_FI_DEV_GPU_UTIL":             func(s *gpuSample, v float64) { s.UtilizationPercent = float(v) },
+	"DCGM_FI_DEV_GPU_TEMP":             func(s *gpuSample, v float64) { s.TemperatureCelsius = float(v) },
+	"DCGM_FI_DEV_POWER_USAGE":          func(s *gpuSample, v float64) { s.PowerDrawWatts = float(v) },
+	"DCGM_FI_DEV_FAN_SPEED":            func(s *gpuSample, v float64) { s.FanSpeedPercent = float(v) },
+	"DCGM_FI_DEV_ENFORCED_POWER_LIMIT": func(s *gpuSample, v float64) { s.PowerLimitWatts = float(v) },
+	// Energy is reported in millijoules.
+	"DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION": func(s *gpuSample, v float64) { s.EnergyJoules = float(v / 1000) },
+	// Framebuffer sizes are reported in MiB; total is the sum of used, free and reserved.
+	"DCGM_FI_DEV_FB_USED": func(s *gpuSample, v float64) {
+		s.MemoryUsedBytes = float(v * 1024 * 1024)
+		s.MemoryTotalBytes = add(s.MemoryTotalBytes, v*1024*1024)
+	},
+	"DCGM_FI_DEV_FB_FREE":     func(s *gpuSample, v float64) { s.MemoryTotalBytes = add(s.MemoryTotalBytes, v*1024*1024) },
+	"DCGM_FI_DEV_FB_RESERVED": func(s *gpuSample, v float64) { s.MemoryTotalBytes = add(s.MemoryTotalBytes, v*1024*1024) },
+
+	"DCGM_FI_DEV_ECC_SBE_AGG_TOTAL": func(s *gpuSample, v float64) { s.ECCCorrectedErrors = float(v) },
+	"DCGM_FI_DEV_ECC_DBE_AGG_TOTAL": func(s *gpuSample, v float64) { s.ECCUncorrectedErrors = float(v) },
+	"DCGM_FI_DEV_ECC_SBE_VOL_TOTAL": func(s *gpuSample, v float64) { s.ECCVolatileCorrectedErrors = float(v) },
+	"DCGM_FI_DEV_ECC_DBE_VOL_TOTAL": func(s *gpuSample, v float64) { s.ECCVolatileUncorrectedErrors = float(v) },
+
+	"DCGM_FI_DEV_CORRECTABLE_REMAPPED_ROWS":   func(s *gpuSample, v float64) { s.RemappedRowsCorrectable = float(v) },
+	"DCGM_FI_DEV_UNCORRECTABLE_REMAPPED_ROWS": func(s *gpuSample, v float64) { s.RemappedRowsUncorrectable = float(v) },
+	"DCGM_FI_DEV_ROW_REMAP_PENDING":           func(s *gpuSample, v float64) { s.RowRemapPending = float(v) },
+	"DCGM_FI_DEV_ROW_REMAP_FAILURE":           func(s *gpuSample, v float64) { s.RowRemapFailure = float(v) },
+
+	"DCGM_FI_DEV_XID_ERRORS": func(s *gpuSample, v float64) { s.XIDLastError = float(v) },
+	"DCGM_FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_TOTAL": func(s *gpuSample, v float64) {
+		s.NVLinkCRCErrors = add(s.NVLinkCRCErrors, v)
+	},
+	"DCGM_FI_DEV_NVLINK_CRC_DATA_ERROR_COUNT_TOTAL": func(s *gpuSample, v float64) {
+		s.NVLinkCRCErrors = add(s.NVLinkCRCErrors, v)
+	},
+	// The profiling metrics need DCGM_FI_PROF_* entries in the exporter's counter CSV.
+	"DCGM_FI_PROF_NVLINK_TX_BYTES":                  func(s *gpuSample, v float64) { s.NVLinkTXBytesPerSecond = float(v) },
+	"DCGM_FI_PROF_NVLINK_RX_BYTES":                  func(s *gpuSample, v float64) { s.NVLinkRXBytesPerSecond = float(v) },
+	"DCGM_FI_PROF_PCIE_TX_BYTES":                    func(s *gpuSample, v float64) { s.PCIeTXBytesPerSecond = float(v) },
+	"DCGM_FI_PROF_PCIE_RX_BYTES":                    func(s *gpuSample, v float64) { s.PCIeRXBytesPerSecond = float(v) },
+	"DCGM_FI_DEV_PCIE_REPLAY_COUNTER":               func(s *gpuSample, v float64) { s.PCIeReplayErrors = float(v) },
+	"DCGM_FI_DEV_NVLINK_REPLAY_ERROR_COUNT_TOTAL":   func(s *gpuSample, v float64) { s.NVLinkReplayErrors = float(v) },
+	"DCGM_FI_DEV_NVLINK_RECOVERY_ERROR_COUNT_TOTAL": func(s *gpuSample, v float64) { s.NVLinkRecoveryErrors = float(v) },
+	// Thermal violation time is reported in microseconds.
+	"DCGM_FI_DEV_THERMAL_VIOLATION": func(s *gpuSample, v float64) { s.ThermalViolationSeconds = float(v / 1e6) },
+	"DCGM_FI_DEV_RETIRED_SBE":       func(s *gpuSample, v float64) { s.RetiredPagesSingleBit = float(v) },
+	"DCGM_FI_DEV_RETIRED_DBE":       func(s *gpuSample, v float64) { s.RetiredPagesDoubleBit = float(v) },
+	"DCGM_FI_DEV_RETIRED_PENDING":   func(s *gpuSample, v float64) { s.RetiredPagesPending = float(v) },
+	// The clock event reasons bitmask; older DCGM releases call it CLOCK_THROTTLE_REASONS.
+	"DCGM_FI_DEV_CLOCKS_EVENT_REASONS":   setThrottleReasons,
+	"DCGM_FI_DEV_CLOCK_THROTTLE_REASONS": setThrottleReasons,
+}
+
+// dcgmMIGField applies the value of one dcgm-exporter metric to a MIG device.
+type dcgmMIGField func(d *migDevice, v float64)
+
+// dcgmMIGFields are the fields dcgm-exporter reports per MIG device, labelled with the
+// GPU instance (GPU_I_ID) and its profile (GPU_I_PROFILE) besides the parent GPU.
+var dcgmMIGFields = map[string]dcgmMIGField{
+	"DCGM_FI_DEV_FB_USED": func(d *migDevice, v float64) {
+		d.MemoryUsedBytes = float(v * 1024 * 1024)
+		d.MemoryTotalBytes = add(d.MemoryTotalBytes, v*1024*1024)
+	},
+	"DCGM_FI_DEV_FB_FREE": func(d *migDevice, v float64) { d.MemoryTotalBytes = add(d.MemoryTotalBytes, v*1024*1024) },
+	// The graphics engine activity is a ratio of the sample period.
+	"DCGM_FI_PROF_GR_ENGINE_ACTIVE": func(d *migDevice, v float64) { d.UtilizationPercent = float(v * 100) },
+}
+
+func setThrottleReasons(s *gpuSample, v float64) {
+	reasons := uint64(v)
+	s.ThrottleReasons = &reasons
+	s.SupportedThrottleReasons = allThrottleReasons
+}
+
+func (b *dcgmBackend) Samples() ([]gpuSample, error) {
+	resp, err := b.client.Get(b.url)
+	if err != nil {
+		return nil, fmt.Errorf("scraping dcgm-exporter: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scraping dcgm-exporter: unexpected status %s", resp.Status)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dcgm-exporter metrics: %w", err)
+	}
+
+	byIndex := make(map[int]*gpuSample)
+	for name, family := range families {
+		apply, device := dcgmFields[name]
+		applyMIG, slice := dcgmMIGFields[name]
+		if !device && !slice {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := labelMap(m)
+			index, err := strconv.Atoi(labels["gpu"])
+			if err != nil {
+				continue
+			}
+			s, ok := byIndex[index]
+			if !ok {
+				s = &gpuSample{Index: index, Vendor: "nvidia", UUID: labels["UUID"], Name: labels["modelName"], DriverVersion: labels["DCGM_FI_DRIVER_VERSION"], PCIBusID: labels["pci_bus_id"]}
+				byIndex[index] = s
+			}
+			// MIG devices report under their parent's gpu label; their readings must not
+			// overwrite the parent's.
+			if instance := labels["GPU_I_ID"]; instance != "" {
+				if slice {
+					applyMIG(s.migSlice(labels["GPU_I_PROFILE"], instance), metricValue(m))
+				}
+				continue
+			}
+			if device {
+				apply(s, metricValue(m))
+			}
+		}
+	}
+
+	samples := make([]gpuSample, 0, len(byIndex))
+	for _, s := range byIndex {
+		sortMIGDevices(s.MIGDevices)
+		samples = append(samples, *s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Index < samples[j].Index })
+	return samples, nil
+}
+
+// Inventory is limited to what dcgm-exporter reports with its metrics: the GPU model,
+// UUID and framebuffer size, plus the driver version and PCI bus ID when the exporter adds
+// them as labels.
+func (b *dcgmBackend) Inventory() (nodeInventory, error) {
+	samples, err := b.Samples()
+	if err != nil {
+		return nodeInventory{}, err
+	}
+	var inventory nodeInventory
+	for _, s := range samples {
+		gpu := gpuInventory{Index: s.Index, UUID: s.UUID, Name: s.Name, PCIBusID: s.PCIBusID}
+		if s.MemoryTotalBytes != nil {
+			gpu.MemoryTotalBytes = uint64(*s.MemoryTotalBytes)
+		}
+		inventory.GPUs = append(inventory.GPUs, gpu)
+		if inventory.DriverVersion == "" {
+			inventory.DriverVersion = s.DriverVersion
+		}
+	}
+	return inventory, nil
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// metricValue returns the sample value regardless of the declared metric type.
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	default:
+		return m.GetUntyped().GetValue()
+	}
+}
+
+// add accumulates v into an optional reading.
+func add(total *float64, v float64) *float64 {
+	if total == nil {
+		return float(v)
+	}
+	return float(*total + v)
+}
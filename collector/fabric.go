@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	fabricPortUpDesc = prometheus.NewDesc("infiniband_port_up",
+		"1 if the InfiniBand or RoCE port is active with its physical link up, 0 otherwise.", []string{"device", "port", "link_layer"}, nil)
+	fabricPortRateDesc = prometheus.NewDesc("infiniband_port_rate_bytes_per_second",
+		"Signalling rate the port negotiated.", []string{"device", "port"}, nil)
+	fabricPortErrorsDesc = prometheus.NewDesc("infiniband_port_errors_total",
+		"Error counters of the port, by sysfs counter name (e.g. symbol_error, link_downed, port_xmit_discards).", []string{"device", "port", "counter"}, nil)
+	fabricPortDataDesc = prometheus.NewDesc("infiniband_port_data_bytes_total",
+		"Data moved through the port, including RDMA traffic, by direction.", []string{"device", "port", "direction"}, nil)
+	fabricPortPacketsDesc = prometheus.NewDesc("infiniband_port_packets_total",
+		"Packets moved through the port, by direction.", []string{"device", "port", "direction"}, nil)
+	fabricPortThroughputDesc = prometheus.NewDesc("infiniband_port_throughput_bytes_per_second",
+		"Data rate through the port since the previous scrape, by direction.", []string{"device", "port", "direction"}, nil)
+	fabricReadErrorDesc = prometheus.NewDesc("infiniband_read_error",
+		"1 if the last attempt to read the InfiniBand ports from sysfs failed, 0 otherwise.", nil, nil)
+)
+
+// fabricErrorCounters are the error counters exported from a port's counters directory
+// (the InfiniBand PortCounters) and, for RoCE on Mellanox/NVIDIA NICs, hw_counters.
+// Counters a port lacks are skipped.
+var fabricErrorCounters = []struct {
+	dir, name string
+}{
+	{"counters", "symbol_error"},
+	{"counters", "link_downed"},
+	{"counters", "link_error_recovery"},
+	{"counters", "port_rcv_errors"},
+	{"counters", "port_rcv_remote_physical_errors"},
+	{"counters", "port_xmit_discards"},
+	{"counters", "local_link_integrity_errors"},
+	{"counters", "excessive_buffer_overrun_errors"},
+	{"hw_counters", "out_of_sequence"},
+	{"hw_counters", "packet_seq_err"},
+	{"hw_counters", "local_ack_timeout_err"},
+}
+
+// fabricPort is one port of an RDMA device read from sysfs.
+type fabricPort struct {
+	device, port, linkLayer string
+	up                      bool
+	rateBytesPerSecond      *float64
+	errors                  map[string]float64
+	// The data counters count octets divided by four, as the InfiniBand spec defines.
+	txBytes, rxBytes, txPackets, rxPackets *float64
+}
+
+// fabricMonitor exports the InfiniBand and RoCE ports of the node from
+// /sys/class/infiniband on every scrape, since multi-node training failures often trace
+// back to the fabric: a flapping link, symbol errors from a bad cable or discards from
+// congestion. Alerting is left to Prometheus (see prometheus/rules/network_fabric.yml).
+type fabricMonitor struct {
+	sysfs string
+
+	mu sync.Mutex
+	// last holds the data counters of the previous scrape by device/port/direction, for
+	// the throughput.
+	last   map[string]float64
+	lastAt time.Time
+}
+
+func newFabricMonitor(sysfs string) *fabricMonitor {
+	return &fabricMonitor{sysfs: sysfs, last: make(map[string]float64)}
+}
+
+// read returns the ports of every RDMA device.
+func (m *fabricMonitor) read() ([]fabricPort, error) {
+	portDirs, err := filepath.Glob(filepath.Join(m.sysfs, "*", "ports", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(portDirs)
+	ports := make([]fabricPort, 0, len(portDirs))
+	for _, dir := range portDirs {
+		p := fabricPort{
+			device:    filepath.Base(filepath.Dir(filepath.Dir(dir))),
+			port:      filepath.Base(dir),
+			linkLayer: readSysfsString(filepath.Join(dir, "link_layer")),
+			errors:    make(map[string]float64),
+		}
+		state := readSysfsString(filepath.Join(dir, "state"))
+		if state == "" {
+			return nil, fmt.Errorf("reading %s: no port state", dir)
+		}
+		// state is e.g. "4: ACTIVE" and phys_state "5: LinkUp".
+		p.up = strings.HasSuffix(state, "ACTIVE") && strings.HasSuffix(readSysfsString(filepath.Join(dir, "phys_state")), "LinkUp")
+		// rate is e.g. "200 Gb/sec (4X HDR)".
+		if fields := strings.Fields(readSysfsString(filepath.Join(dir, "rate"))); len(fields) > 0 {
+			if gbps, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				p.rateBytesPerSecond = float(gbps * 1e9 / 8)
+			}
+		}
+		for _, c := range fabricErrorCounters {
+			if v, ok := readSysfsNumber(filepath.Join(dir, c.dir, c.name)); ok {
+				p.errors[c.name] = v
+			}
+		}
+		if words, ok := readSysfsNumber(filepath.Join(dir, "counters", "port_xmit_data")); ok {
+			p.txBytes = float(words * 4)
+		}
+		if words, ok := readSysfsNumber(filepath.Join(dir, "counters", "port_rcv_data")); ok {
+			p.rxBytes = float(words * 4)
+		}
+		if n, ok := readSysfsNumber(filepath.Join(dir, "counters", "port_xmit_packets")); ok {
+			p.txPackets = float(n)
+		}
+		if n, ok := readSysfsNumber(filepath.Join(dir, "counters", "port_rcv_packets")); ok {
+			p.rxPackets = float(n)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+func readSysfsString(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func readSysfsNumber(path string) (float64, bool) {
+	v, err := strconv.ParseFloat(readSysfsString(path), 64)
+	return v, err == nil
+}
+
+func (m *fabricMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fabricPortUpDesc
+	ch <- fabricPortRateDesc
+	ch <- fabricPortErrorsDesc
+	ch <- fabricPortDataDesc
+	ch <- fabricPortPacketsDesc
+	ch <- fabricPortThroughputDesc
+	ch <- fabricReadErrorDesc
+}
+
+func (m *fabricMonitor) Collect(ch chan<- prometheus.Metric) {
+	ports, err := m.read()
+	if err != nil {
+		log.Printf("Error reading InfiniBand ports: %v", err)
+		ch <- prometheus.MustNewConstMetric(fabricReadErrorDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(fabricReadErrorDesc, prometheus.GaugeValue, 0)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(m.lastAt).Seconds()
+	last := make(map[string]float64)
+	for _, p := range ports {
+		gauge(ch, fabricPortUpDesc, healthValue(p.up), p.device, p.port, p.linkLayer)
+		gauge(ch, fabricPortRateDesc, p.rateBytesPerSecond, p.device, p.port)
+		for _, c := range fabricErrorCounters {
+			if v, ok := p.errors[c.name]; ok {
+				counter(ch, fabricPortErrorsDesc, &v, p.device, p.port, c.name)
+			}
+		}
+		counter(ch, fabricPortPacketsDesc, p.txPackets, p.device, p.port, "tx")
+		counter(ch, fabricPortPacketsDesc, p.rxPackets, p.device, p.port, "rx")
+		for direction, bytes := range map[string]*float64{"tx": p.txBytes, "rx": p.rxBytes} {
+			if bytes == nil {
+				continue
+			}
+			counter(ch, fabricPortDataDesc, bytes, p.device, p.port, direction)
+			key := p.device + "/" + p.port + "/" + direction
+			last[key] = *bytes
+			// A counter that went backwards was reset; skip the rate until the next scrape.
+			if previous, ok := m.last[key]; ok && elapsed > 0 && *bytes >= previous {
+				rate := (*bytes - previous) / elapsed
+				gauge(ch, fabricPortThroughputDesc, &rate, p.device, p.port, direction)
+			}
+		}
+	}
+	m.last, m.lastAt = last, now
+}
+
+// hasFabric reports whether the node has RDMA devices in sysfs.
+func hasFabric(sysfs string) bool {
+	entries, err := os.ReadDir(sysfs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error listing %s: %v", sysfs, err)
+	}
+	return len(entries) > 0
+}
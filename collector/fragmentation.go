@@ -0,0 +1,197 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stddef.h>
+#include <string.h>
+
+// The CUDA driver API is loaded at runtime like NVML, so the collector neither links
+// against nor needs the CUDA headers. Only the few entry points the probe uses are
+// declared; CUresult 0 is CUDA_SUCCESS.
+typedef int (*cuInit_t)(unsigned int);
+typedef int (*cuDeviceGetCount_t)(int *);
+typedef int (*cuDeviceGet_t)(int *, int);
+typedef int (*cuDeviceGetUuid_t)(char *, int);
+typedef int (*cuDevicePrimaryCtxRetain_t)(void **, int);
+typedef int (*cuDevicePrimaryCtxRelease_t)(int);
+typedef int (*cuCtxSetCurrent_t)(void *);
+typedef int (*cuMemGetInfo_t)(size_t *, size_t *);
+typedef int (*cuMemAlloc_t)(unsigned long long *, size_t);
+typedef int (*cuMemFree_t)(unsigned long long);
+
+static struct {
+	cuInit_t init;
+	cuDeviceGetCount_t deviceGetCount;
+	cuDeviceGet_t deviceGet;
+	cuDeviceGetUuid_t deviceGetUuid;
+	cuDevicePrimaryCtxRetain_t primaryCtxRetain;
+	cuDevicePrimaryCtxRelease_t primaryCtxRelease;
+	cuCtxSetCurrent_t ctxSetCurrent;
+	cuMemGetInfo_t memGetInfo;
+	cuMemAlloc_t memAlloc;
+	cuMemFree_t memFree;
+} cu;
+
+// cuda_load opens libcuda and initializes the driver API. It returns -1 when the
+// library or one of its symbols is missing, else cuInit's result.
+static int cuda_load(void) {
+	void *lib = dlopen("libcuda.so.1", RTLD_NOW | RTLD_GLOBAL);
+	if (lib == NULL) {
+		return -1;
+	}
+	cu.init = (cuInit_t)dlsym(lib, "cuInit");
+	cu.deviceGetCount = (cuDeviceGetCount_t)dlsym(lib, "cuDeviceGetCount");
+	cu.deviceGet = (cuDeviceGet_t)dlsym(lib, "cuDeviceGet");
+	cu.deviceGetUuid = (cuDeviceGetUuid_t)dlsym(lib, "cuDeviceGetUuid");
+	cu.primaryCtxRetain = (cuDevicePrimaryCtxRetain_t)dlsym(lib, "cuDevicePrimaryCtxRetain");
+	cu.primaryCtxRelease = (cuDevicePrimaryCtxRelease_t)dlsym(lib, "cuDevicePrimaryCtxRelease_v2");
+	cu.ctxSetCurrent = (cuCtxSetCurrent_t)dlsym(lib, "cuCtxSetCurrent");
+	cu.memGetInfo = (cuMemGetInfo_t)dlsym(lib, "cuMemGetInfo_v2");
+	cu.memAlloc = (cuMemAlloc_t)dlsym(lib, "cuMemAlloc_v2");
+	cu.memFree = (cuMemFree_t)dlsym(lib, "cuMemFree_v2");
+	if (!cu.init || !cu.deviceGetCount || !cu.deviceGet || !cu.deviceGetUuid || !cu.primaryCtxRetain ||
+		!cu.primaryCtxRelease || !cu.ctxSetCurrent || !cu.memGetInfo || !cu.memAlloc || !cu.memFree) {
+		return -1;
+	}
+	return cu.init(0);
+}
+
+static int cuda_device_count(int *count) {
+	return cu.deviceGetCount(count);
+}
+
+// cuda_probe finds the largest block that can be allocated on the device by bisecting
+// between 0 and the free memory in steps of granularity bytes. Every allocation is freed
+// right away. The whole probe runs in one call so the context stays on one thread.
+static int cuda_probe(int ordinal, size_t granularity, char uuid[16], size_t *free_bytes, size_t *total_bytes, size_t *largest) {
+	int dev, ret;
+	void *ctx;
+	if ((ret = cu.deviceGet(&dev, ordinal)) != 0) return ret;
+	if ((ret = cu.deviceGetUuid(uuid, dev)) != 0) return ret;
+	if ((ret = cu.primaryCtxRetain(&ctx, dev)) != 0) return ret;
+	if ((ret = cu.ctxSetCurrent(ctx)) != 0) goto release;
+	if ((ret = cu.memGetInfo(free_bytes, total_bytes)) != 0) goto release;
+
+	size_t lo = 0, hi = *free_bytes / granularity;
+	while (lo < hi) {
+		size_t mid = lo + (hi - lo + 1) / 2;
+		unsigned long long ptr;
+		if (cu.memAlloc(&ptr, mid * granularity) == 0) {
+			cu.memFree(ptr);
+			lo = mid;
+		} else {
+			hi = mid - 1;
+		}
+	}
+	*largest = lo * granularity;
+
+release:
+	cu.ctxSetCurrent(NULL);
+	cu.primaryCtxRelease(dev);
+	return ret;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	largestFreeBlockDesc = prometheus.NewDesc("gpu_memory_largest_free_block_bytes",
+		"Largest block of GPU memory a process could allocate at the last fragmentation probe.", deviceLabels, nil)
+	probedFreeMemoryDesc = prometheus.NewDesc("gpu_memory_probed_free_bytes",
+		"Free GPU memory seen by the fragmentation probe, after its own context was created.", deviceLabels, nil)
+	fragmentationDesc = prometheus.NewDesc("gpu_memory_fragmentation_ratio",
+		"1 - largest allocatable block / free memory at the last fragmentation probe: 0 when the free memory is one block, close to 1 when it is scattered.", deviceLabels, nil)
+)
+
+// probeGranularity is the step of the largest block search. Training frameworks
+// allocate in blocks of this size and up, so finer steps would not change the verdict.
+const probeGranularity = 64 << 20
+
+// memoryProbe periodically measures how fragmented the free memory of every GPU is,
+// which NVML and DCGM cannot tell: it creates a CUDA context on each GPU and searches
+// for the largest block that can still be allocated. The context takes a few hundred
+// MiB of memory while the probe runs, and each trial allocation holds the memory for
+// an instant, so the probe is opt-in.
+type memoryProbe struct {
+	mu      sync.Mutex
+	results map[string]memoryProbeResult
+}
+
+// memoryProbeResult is the last probe of one GPU.
+type memoryProbeResult struct {
+	freeBytes, largestBlockBytes float64
+}
+
+// newMemoryProbe loads the CUDA driver library and returns an error when it is missing,
+// e.g. in a container started without the "compute" driver capability.
+func newMemoryProbe() (*memoryProbe, error) {
+	if ret := C.cuda_load(); ret != 0 {
+		if ret == -1 {
+			return nil, fmt.Errorf("libcuda.so.1 not found or incomplete")
+		}
+		return nil, fmt.Errorf("cuInit failed with CUDA error %d", int(ret))
+	}
+	return &memoryProbe{results: make(map[string]memoryProbeResult)}, nil
+}
+
+// run probes every GPU every interval.
+func (p *memoryProbe) run(interval time.Duration) {
+	for {
+		p.probe()
+		time.Sleep(interval)
+	}
+}
+
+func (p *memoryProbe) probe() {
+	var count C.int
+	if ret := C.cuda_device_count(&count); ret != 0 {
+		log.Printf("Error counting CUDA devices for the fragmentation probe: CUDA error %d", int(ret))
+		return
+	}
+	results := make(map[string]memoryProbeResult, int(count))
+	for i := 0; i < int(count); i++ {
+		var uuid [16]C.char
+		var free, total, largest C.size_t
+		if ret := C.cuda_probe(C.int(i), probeGranularity, &uuid[0], &free, &total, &largest); ret != 0 {
+			log.Printf("Error probing memory fragmentation of CUDA device %d: CUDA error %d", i, int(ret))
+			continue
+		}
+		results[cudaUUID(C.GoBytes(unsafe.Pointer(&uuid[0]), 16))] = memoryProbeResult{freeBytes: float64(free), largestBlockBytes: float64(largest)}
+	}
+
+	p.mu.Lock()
+	p.results = results
+	p.mu.Unlock()
+}
+
+// result returns the last probe of the GPU with the given NVML UUID.
+func (p *memoryProbe) result(uuid string) (memoryProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.results[uuid]
+	return r, ok
+}
+
+// cudaUUID formats the 16 bytes of a CUDA device UUID like NVML does,
+// "GPU-xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+func cudaUUID(b []byte) string {
+	return fmt.Sprintf("GPU-%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// fragmentation is 1 - largest block / free memory, 0 when nothing is free.
+func (r memoryProbeResult) fragmentation() float64 {
+	if r.freeBytes <= 0 {
+		return 0
+	}
+	return 1 - r.largestBlockBytes/r.freeBytes
+}
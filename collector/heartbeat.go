@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var heartbeatErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "gpu_collector_heartbeat_errors_total",
+	Help: "Heartbeats that could not be delivered to the adapter.",
+})
+
+// heartbeater tells the adapter's dead man's switch (POST /api/heartbeat) that the node
+// is alive. Each heartbeat carries the interval, so the adapter knows when one is
+// overdue; a node that stops sending them, because it hung, lost power or lost its
+// network, raises NodeUnreachable there.
+type heartbeater struct {
+	url      string
+	token    string
+	node     string
+	interval time.Duration
+	client   *http.Client
+}
+
+func newHeartbeater(url, token, node string, interval time.Duration) *heartbeater {
+	return &heartbeater{url: url, token: token, node: node, interval: interval, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// run sends a heartbeat right away and then every interval. A failed heartbeat is
+// logged and counted; the adapter only raises an alert after several are missed.
+func (h *heartbeater) run() {
+	for {
+		if err := h.send(); err != nil {
+			heartbeatErrors.Inc()
+			log.Printf("Error sending heartbeat to %s: %v", h.url, err)
+		}
+		time.Sleep(h.interval)
+	}
+}
+
+func (h *heartbeater) send() error {
+	body, err := json.Marshal(struct {
+		Node     string `json:"node"`
+		Interval string `json:"interval"`
+	}{h.node, h.interval.String()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.token)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// idleAllocations tracks how long each GPU allocated to a workload has been idle. A
+// reservation whose GPU does no work for a long time wastes a scarce GPU; admins use the
+// alert to reclaim it from the owning pod or job.
+type idleAllocations struct {
+	// duration is how long an allocated GPU must stay idle to be flagged.
+	duration time.Duration
+	// threshold is the utilization in percent below which a GPU counts as idle.
+	threshold float64
+
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newIdleAllocations(duration time.Duration, threshold float64) *idleAllocations {
+	return &idleAllocations{duration: duration, threshold: threshold, since: make(map[string]time.Time)}
+}
+
+// observe records the GPU's utilization and whether it is allocated, and returns how
+// long it has been idle while allocated, or 0 if it is busy or free now.
+func (i *idleAllocations) observe(uuid string, utilizationPercent float64, allocated bool, now time.Time) time.Duration {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !allocated || utilizationPercent >= i.threshold {
+		delete(i.since, uuid)
+		return 0
+	}
+	since, ok := i.since[uuid]
+	if !ok {
+		since = now
+		i.since[uuid] = now
+	}
+	return now.Sub(since)
+}
+
+// alerting reports whether a GPU has been idle while allocated for longer than the
+// alert duration.
+func (i *idleAllocations) alerting(idle time.Duration) bool {
+	return idle > 0 && idle >= i.duration
+}
+
+// allocated reports whether a GPU is allocated to a workload: to a pod or SLURM job when
+// attribution is enabled, or to the compute processes running on it. workload holds the
+// GPU's label values after gpuLabels and vendor.
+func allocated(s gpuSample, workload []string) bool {
+	for _, value := range workload {
+		if value != "" {
+			return true
+		}
+	}
+	return len(s.Processes) > 0
+}
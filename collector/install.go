@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Where -install puts the agent on bare-metal nodes that run it without Docker.
+const (
+	installBinaryPath = "/usr/local/bin/gpu-collector"
+	installUnitPath   = "/etc/systemd/system/gpu-collector.service"
+	installEnvPath    = "/etc/default/gpu-collector"
+	installUnitName   = "gpu-collector.service"
+)
+
+// systemdUnit runs the agent as root, which the host PID namespace lookups of GPU
+// processes, /dev/kmsg and ipmitool need. Configuration is read from the environment
+// file, with the same variables as the container.
+var systemdUnit = template.Must(template.New("unit").Parse(`[Unit]
+Description=GPU node monitor collector
+After=network-online.target nvidia-persistenced.service
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{.Binary}}
+EnvironmentFile=-{{.EnvFile}}
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// defaultEnvFile is written on the first install only, so later installs keep the
+// node's settings.
+const defaultEnvFile = `# Settings of the gpu-collector agent, read by ` + installUnitName + `.
+# Every variable of the gpu-collector service in docker-compose.yml can be set here.
+# Apply changes with: systemctl restart gpu-collector
+LISTEN_ADDRESS=:9500
+# GPU_BACKEND=dcgm
+# DCGM_EXPORTER_URL=http://localhost:9400/metrics
+# XID_WATCHER=kmsg
+# ADAPTER_URL=http://gchat-adapter.example.com:8080/webhook
+# REMOTE_WRITE_URL=https://prometheus.example.com/api/v1/write
+# SLURM_ATTRIBUTION=scontrol
+`
+
+// installAgent copies the running binary to installBinaryPath, writes the systemd unit
+// and a default environment file, and enables and starts the service.
+func installAgent() error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return err
+	}
+	if self != installBinaryPath {
+		if err := copyExecutable(self, installBinaryPath); err != nil {
+			return fmt.Errorf("installing %s: %w", installBinaryPath, err)
+		}
+		log.Printf("Installed %s", installBinaryPath)
+	}
+
+	var unit bytes.Buffer
+	if err := systemdUnit.Execute(&unit, map[string]string{"Binary": installBinaryPath, "EnvFile": installEnvPath}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(installUnitPath, unit.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing systemd unit: %w", err)
+	}
+	log.Printf("Wrote %s", installUnitPath)
+
+	if _, err := os.Stat(installEnvPath); os.IsNotExist(err) {
+		if err := os.WriteFile(installEnvPath, []byte(defaultEnvFile), 0o600); err != nil {
+			return fmt.Errorf("writing %s: %w", installEnvPath, err)
+		}
+		log.Printf("Wrote %s", installEnvPath)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	// restart rather than start, so reinstalling an upgraded binary takes effect.
+	if err := systemctl("enable", installUnitName); err != nil {
+		return err
+	}
+	if err := systemctl("restart", installUnitName); err != nil {
+		return err
+	}
+	log.Printf("Enabled and started %s (version %s)", installUnitName, version)
+	return nil
+}
+
+// uninstallAgent stops and disables the service and removes the unit and the binary.
+// The environment file is kept for a later install.
+func uninstallAgent() error {
+	if err := systemctl("disable", "--now", installUnitName); err != nil {
+		return err
+	}
+	for _, path := range []string{installUnitPath, installBinaryPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	log.Printf("Removed %s; %s was kept", installUnitName, installEnvPath)
+	return nil
+}
+
+// copyExecutable copies src to dst through a temporary file, so a running agent's
+// binary is replaced rather than overwritten in place.
+func copyExecutable(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".gpu-collector-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// intelToolTimeout bounds every xpu-smi and hl-smi call, which run on every scrape.
+const intelToolTimeout = 10 * time.Second
+
+// runIntelTool runs xpu-smi or hl-smi and returns its standard output.
+func runIntelTool(tool string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), intelToolTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, tool, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", tool, strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// toolNumber parses a number printed by xpu-smi or hl-smi, which print N/A for readings
+// the device does not support.
+func toolNumber(s string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// xpuBackend reads Intel Data Center GPUs (Max and Flex series) with xpu-smi, from the
+// XPU Manager that Intel ships for them.
+type xpuBackend struct {
+	xpuSMI string
+
+	mu sync.Mutex
+	// devices caches what xpu-smi discovery reports per device ID, which only changes
+	// when the collector restarts.
+	devices map[int]xpuDevice
+}
+
+func newXPUBackend(xpuSMI string) *xpuBackend {
+	return &xpuBackend{xpuSMI: xpuSMI}
+}
+
+// xpuDevice is what xpu-smi discovery reports for one GPU.
+type xpuDevice struct {
+	id                                int
+	uuid, name, busID                 string
+	serial, driverVersion, firmware   string
+	memoryTotalBytes                  uint64
+	pcieGeneration, pcieMaxGeneration int
+	pcieWidth, pcieMaxWidth           int
+}
+
+// xpuFields maps the columns of xpu-smi dump to sample fields. xpuMetrics are the IDs
+// of these columns, which is how xpu-smi dump selects them.
+var xpuFields = map[string]func(s *gpuSample, v *float64){
+	"GPU Utilization (%)":                   func(s *gpuSample, v *float64) { s.UtilizationPercent = v },
+	"GPU Power (W)":                         func(s *gpuSample, v *float64) { s.PowerDrawWatts = v },
+	"GPU Core Temperature (Celsius Degree)": func(s *gpuSample, v *float64) { s.TemperatureCelsius = v },
+	"GPU Energy Consumed (J)":               func(s *gpuSample, v *float64) { s.EnergyJoules = v },
+	"GPU Memory Used (MiB)": func(s *gpuSample, v *float64) {
+		if v != nil {
+			s.MemoryUsedBytes = float(*v * 1024 * 1024)
+		}
+	},
+}
+
+const xpuMetrics = "0,1,3,8,18"
+
+// discover lists the GPUs, reading the details of each once.
+func (b *xpuBackend) discover() ([]xpuDevice, error) {
+	out, err := runIntelTool(b.xpuSMI, "discovery", "-j")
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Devices []map[string]any `json:"device_list"`
+	}
+	if err := decodeToolJSON(out, &list); err != nil {
+		return nil, fmt.Errorf("parsing xpu-smi discovery: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.devices == nil {
+		b.devices = make(map[int]xpuDevice)
+	}
+	devices := make([]xpuDevice, 0, len(list.Devices))
+	for _, d := range list.Devices {
+		id, err := strconv.Atoi(jsonText(d["device_id"]))
+		if err != nil {
+			continue
+		}
+		device, ok := b.devices[id]
+		if !ok {
+			device = b.describe(id, d)
+			b.devices[id] = device
+		}
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].id < devices[j].id })
+	return devices, nil
+}
+
+// describe reads the details of one GPU from xpu-smi discovery -d, falling back to its
+// entry in the device list when that fails.
+func (b *xpuBackend) describe(id int, entry map[string]any) xpuDevice {
+	details := entry
+	if out, err := runIntelTool(b.xpuSMI, "discovery", "-d", strconv.Itoa(id), "-j"); err == nil {
+		var d map[string]any
+		if decodeToolJSON(out, &d) == nil {
+			details = d
+		}
+	}
+	device := xpuDevice{
+		id:            id,
+		uuid:          jsonText(details["uuid"]),
+		name:          jsonText(details["device_name"]),
+		busID:         jsonText(details["pci_bdf_address"]),
+		serial:        jsonText(details["serial_number"]),
+		driverVersion: jsonText(details["driver_version"]),
+		firmware:      jsonText(details["gfx_firmware_version"]),
+	}
+	device.memoryTotalBytes, _ = strconv.ParseUint(jsonText(details["memory_physical_size_byte"]), 10, 64)
+	device.pcieGeneration, _ = strconv.Atoi(jsonText(details["pcie_generation"]))
+	device.pcieMaxGeneration, _ = strconv.Atoi(jsonText(details["pcie_max_generation"]))
+	device.pcieWidth, _ = strconv.Atoi(jsonText(details["pcie_link_width"]))
+	device.pcieMaxWidth, _ = strconv.Atoi(jsonText(details["max_pcie_link_width"]))
+	return device
+}
+
+// decodeToolJSON decodes JSON keeping numbers as printed, since xpu-smi prints some
+// numbers as strings and others as JSON numbers.
+func decodeToolJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// jsonText returns a string or number of decoded tool JSON as text.
+func jsonText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func (b *xpuBackend) Samples() ([]gpuSample, error) {
+	devices, err := b.discover()
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(devices))
+	samples := make([]gpuSample, len(devices))
+	byID := make(map[int]*gpuSample, len(devices))
+	for i, d := range devices {
+		ids[i] = strconv.Itoa(d.id)
+		samples[i] = gpuSample{Index: d.id, Vendor: "intel", UUID: d.uuid, Name: d.name, PCIBusID: d.busID}
+		if d.memoryTotalBytes > 0 {
+			samples[i].MemoryTotalBytes = float(float64(d.memoryTotalBytes))
+		}
+		byID[d.id] = &samples[i]
+	}
+
+	out, err := runIntelTool(b.xpuSMI, "dump", "-d", strings.Join(ids, ","), "-m", xpuMetrics, "-n", "1")
+	if err != nil {
+		return nil, err
+	}
+	if err := parseXPUDump(bytes.NewReader(out), byID); err != nil {
+		return nil, fmt.Errorf("parsing xpu-smi dump: %w", err)
+	}
+	return samples, nil
+}
+
+// parseXPUDump applies the output of xpu-smi dump to the samples, one CSV row per device
+// after a header naming the columns:
+//
+//	Timestamp, DeviceId, GPU Utilization (%), GPU Power (W), GPU Core Temperature (Celsius Degree), ...
+//	06:14:46.000,    0, 12.50, 281.33, 52.00, ...
+func parseXPUDump(r io.Reader, byID map[int]*gpuSample) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	device := -1
+	for i, name := range header {
+		if strings.TrimSpace(name) == "DeviceId" {
+			device = i
+		}
+	}
+	if device < 0 {
+		return fmt.Errorf("no DeviceId column in %q", strings.Join(header, ","))
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(row) != len(header) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(row[device]))
+		if err != nil {
+			continue
+		}
+		s, ok := byID[id]
+		if !ok {
+			continue
+		}
+		for i, name := range header {
+			if apply, ok := xpuFields[strings.TrimSpace(name)]; ok {
+				apply(s, toolNumber(row[i]))
+			}
+		}
+	}
+}
+
+func (b *xpuBackend) Inventory() (nodeInventory, error) {
+	devices, err := b.discover()
+	if err != nil {
+		return nodeInventory{}, err
+	}
+	var inventory nodeInventory
+	for _, d := range devices {
+		inventory.GPUs = append(inventory.GPUs, gpuInventory{
+			Index:             d.id,
+			UUID:              d.uuid,
+			Name:              d.name,
+			Serial:            d.serial,
+			VBIOSVersion:      d.firmware,
+			MemoryTotalBytes:  d.memoryTotalBytes,
+			PCIBusID:          d.busID,
+			PCIeGeneration:    d.pcieGeneration,
+			PCIeMaxGeneration: d.pcieMaxGeneration,
+			PCIeWidth:         d.pcieWidth,
+			PCIeMaxWidth:      d.pcieMaxWidth,
+		})
+		if inventory.DriverVersion == "" {
+			inventory.DriverVersion = d.driverVersion
+		}
+	}
+	return inventory, nil
+}
+
+// gaudiBackend reads Intel Gaudi accelerators with hl-smi, whose query interface mirrors
+// nvidia-smi's.
+type gaudiBackend struct {
+	hlSMI string
+}
+
+func newGaudiBackend(hlSMI string) *gaudiBackend {
+	return &gaudiBackend{hlSMI: hlSMI}
+}
+
+// gaudiQuery are the hl-smi --query-aip fields read on every scrape, in the column
+// order query expects. Sizes are in MiB.
+const gaudiQuery = "index,uuid,name,bus_id,serial,driver_version,utilization.aip,memory.used,memory.total,temperature.aip,power.draw," +
+	"ecc.errors.corrected.aggregate.total,ecc.errors.uncorrected.aggregate.total," +
+	"pcie.link.gen.current,pcie.link.gen.max,pcie.link.width.current,pcie.link.width.max"
+
+// gaudiDevice is one row of the hl-smi query.
+type gaudiDevice struct {
+	sample    gpuSample
+	inventory gpuInventory
+	driver    string
+}
+
+func (b *gaudiBackend) query() ([]gaudiDevice, error) {
+	out, err := runIntelTool(b.hlSMI, "--query-aip="+gaudiQuery, "--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(bytes.NewReader(out))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = 17
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing hl-smi output: %w", err)
+	}
+
+	devices := make([]gaudiDevice, 0, len(rows))
+	for _, row := range rows {
+		index, err := strconv.Atoi(row[0])
+		if err != nil {
+			continue
+		}
+		s := gpuSample{
+			Index:                index,
+			Vendor:               "intel",
+			UUID:                 row[1],
+			Name:                 row[2],
+			PCIBusID:             row[3],
+			UtilizationPercent:   toolNumber(row[6]),
+			TemperatureCelsius:   toolNumber(row[9]),
+			PowerDrawWatts:       toolNumber(row[10]),
+			ECCCorrectedErrors:   toolNumber(row[11]),
+			ECCUncorrectedErrors: toolNumber(row[12]),
+		}
+		if v := toolNumber(row[7]); v != nil {
+			s.MemoryUsedBytes = float(*v * 1024 * 1024)
+		}
+		if v := toolNumber(row[8]); v != nil {
+			s.MemoryTotalBytes = float(*v * 1024 * 1024)
+		}
+		inventory := gpuInventory{Index: index, UUID: row[1], Name: row[2], PCIBusID: row[3], Serial: row[4]}
+		if s.MemoryTotalBytes != nil {
+			inventory.MemoryTotalBytes = uint64(*s.MemoryTotalBytes)
+		}
+		inventory.PCIeGeneration, _ = strconv.Atoi(row[13])
+		inventory.PCIeMaxGeneration, _ = strconv.Atoi(row[14])
+		inventory.PCIeWidth, _ = strconv.Atoi(row[15])
+		inventory.PCIeMaxWidth, _ = strconv.Atoi(row[16])
+		devices = append(devices, gaudiDevice{sample: s, inventory: inventory, driver: row[5]})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].sample.Index < devices[j].sample.Index })
+	return devices, nil
+}
+
+func (b *gaudiBackend) Samples() ([]gpuSample, error) {
+	devices, err := b.query()
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]gpuSample, len(devices))
+	for i, d := range devices {
+		samples[i] = d.sample
+	}
+	return samples, nil
+}
+
+func (b *gaudiBackend) Inventory() (nodeInventory, error) {
+	devices, err := b.query()
+	if err != nil {
+		return nodeInventory{}, err
+	}
+	var inventory nodeInventory
+	for _, d := range devices {
+		inventory.GPUs = append(inventory.GPUs, d.inventory)
+		if inventory.DriverVersion == "" {
+			inventory.DriverVersion = d.driver
+		}
+	}
+	return inventory, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// sampleInterconnect reads the NVLink and PCIe counters of one device, summed over its
+// active NVLinks. GPUs without NVLink (most PCIe cards) only get the PCIe readings.
+func sampleInterconnect(device nvml.Device, sample *gpuSample) {
+	if n, ret := device.GetPcieReplayCounter(); ret == nvml.SUCCESS {
+		sample.PCIeReplayErrors = float(float64(n))
+	}
+	// PCIe throughput is sampled by the driver over 20ms and reported in KB/s.
+	if kb, ret := device.GetPcieThroughput(nvml.PCIE_UTIL_TX_BYTES); ret == nvml.SUCCESS {
+		sample.PCIeTXBytesPerSecond = float(float64(kb) * 1024)
+	}
+	if kb, ret := device.GetPcieThroughput(nvml.PCIE_UTIL_RX_BYTES); ret == nvml.SUCCESS {
+		sample.PCIeRXBytesPerSecond = float(float64(kb) * 1024)
+	}
+
+	var fields []nvml.FieldValue
+	for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+		if state, ret := device.GetNvLinkState(link); ret != nvml.SUCCESS || state != nvml.FEATURE_ENABLED {
+			continue
+		}
+		if n, ret := device.GetNvLinkErrorCounter(link, nvml.NVLINK_ERROR_DL_CRC_FLIT); ret == nvml.SUCCESS {
+			sample.NVLinkCRCErrors = add(sample.NVLinkCRCErrors, float64(n))
+		}
+		if n, ret := device.GetNvLinkErrorCounter(link, nvml.NVLINK_ERROR_DL_CRC_DATA); ret == nvml.SUCCESS {
+			sample.NVLinkCRCErrors = add(sample.NVLinkCRCErrors, float64(n))
+		}
+		if n, ret := device.GetNvLinkErrorCounter(link, nvml.NVLINK_ERROR_DL_REPLAY); ret == nvml.SUCCESS {
+			sample.NVLinkReplayErrors = add(sample.NVLinkReplayErrors, float64(n))
+		}
+		if n, ret := device.GetNvLinkErrorCounter(link, nvml.NVLINK_ERROR_DL_RECOVERY); ret == nvml.SUCCESS {
+			sample.NVLinkRecoveryErrors = add(sample.NVLinkRecoveryErrors, float64(n))
+		}
+		fields = append(fields,
+			nvml.FieldValue{FieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX, ScopeId: uint32(link)},
+			nvml.FieldValue{FieldId: nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_RX, ScopeId: uint32(link)})
+	}
+	if len(fields) == 0 || device.GetFieldValues(fields) != nvml.SUCCESS {
+		return
+	}
+	// The throughput fields count the payload data moved over the link, in KiB.
+	for _, f := range fields {
+		if nvml.Return(f.NvmlReturn) != nvml.SUCCESS || nvml.ValueType(f.ValueType) != nvml.VALUE_TYPE_UNSIGNED_LONG_LONG {
+			continue
+		}
+		bytes := float64(binary.NativeEndian.Uint64(f.Value[:])) * 1024
+		if f.FieldId == nvml.FI_DEV_NVLINK_THROUGHPUT_DATA_TX {
+			sample.NVLinkTXBytes = add(sample.NVLinkTXBytes, bytes)
+		} else {
+			sample.NVLinkRXBytes = add(sample.NVLinkRXBytes, bytes)
+		}
+	}
+}
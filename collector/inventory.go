@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// nodeInventory is the hardware snapshot served on /api/inventory.
+type nodeInventory struct {
+	Hostname      string         `json:"hostname"`
+	DriverVersion string         `json:"driverVersion,omitempty"`
+	CUDAVersion   string         `json:"cudaVersion,omitempty"`
+	GPUs          []gpuInventory `json:"gpus"`
+	CollectedAt   time.Time      `json:"collectedAt"`
+}
+
+// gpuInventory describes one GPU. Fields the backend cannot read are omitted.
+type gpuInventory struct {
+	Index            int    `json:"index"`
+	UUID             string `json:"uuid"`
+	Name             string `json:"name"`
+	Serial           string `json:"serial,omitempty"`
+	VBIOSVersion     string `json:"vbiosVersion,omitempty"`
+	MemoryTotalBytes uint64 `json:"memoryTotalBytes,omitempty"`
+	PCIBusID         string `json:"pciBusId,omitempty"`
+	// The current PCIe link can be below the maximum while the GPU is idle.
+	PCIeGeneration    int `json:"pcieGeneration,omitempty"`
+	PCIeMaxGeneration int `json:"pcieMaxGeneration,omitempty"`
+	PCIeWidth         int `json:"pcieWidth,omitempty"`
+	PCIeMaxWidth      int `json:"pcieMaxWidth,omitempty"`
+}
+
+// inventoryHandler serves GET /api/inventory, read from the backend on every request.
+func inventoryHandler(backend gpuBackend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		inventory, err := backend.Inventory()
+		if err != nil {
+			log.Printf("Error reading GPU inventory: %v", err)
+			http.Error(w, "Error reading GPU inventory", http.StatusInternalServerError)
+			return
+		}
+		inventory.Hostname, _ = os.Hostname()
+		inventory.CollectedAt = time.Now().UTC()
+		if inventory.GPUs == nil {
+			inventory.GPUs = []gpuInventory{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(inventory)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryLeaks tracks GPU memory that stays allocated after its owner is gone. When a
+// job's processes exit but a CUDA context survives them (a zombie process, a crashed
+// MPS server or a driver bug), the memory stays in use with nothing running on the GPU,
+// and the next job on it fails with out of memory until the GPU is reset.
+type memoryLeaks struct {
+	// duration is how long the memory must stay allocated without an owner to be flagged;
+	// it gives exiting processes time to tear down their contexts.
+	duration time.Duration
+	// thresholdBytes is the memory a GPU without owner may use without counting as
+	// leaked, which covers what the driver reserves.
+	thresholdBytes float64
+
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newMemoryLeaks(duration time.Duration, thresholdBytes float64) *memoryLeaks {
+	return &memoryLeaks{duration: duration, thresholdBytes: thresholdBytes, since: make(map[string]time.Time)}
+}
+
+// observe records the GPU's memory use and whether it is allocated, and returns the
+// leaked memory in bytes and for how long it has been leaked, or 0 if the GPU has an
+// owner or is below the threshold.
+func (m *memoryLeaks) observe(uuid string, usedBytes float64, allocated bool, now time.Time) (float64, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if allocated || usedBytes < m.thresholdBytes {
+		delete(m.since, uuid)
+		return 0, 0
+	}
+	since, ok := m.since[uuid]
+	if !ok {
+		since = now
+		m.since[uuid] = now
+	}
+	return usedBytes, now.Sub(since)
+}
+
+// alerting reports whether a GPU's memory has been leaked for longer than the alert
+// duration.
+func (m *memoryLeaks) alerting(leaked time.Duration) bool {
+	return leaked > 0 && leaked >= m.duration
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// localAlertRule is a condition the collector alerts on by itself, without waiting
+// for Prometheus and Alertmanager.
+type localAlertRule struct {
+	name     string
+	severity string
+	active   func(s gpuSample) bool
+	summary  string
+}
+
+// memoryHealthRules fire when a GPU's memory needs a reset to repair itself or cannot be
+// repaired any more, the earliest warning of failing GPU memory.
+var memoryHealthRules = []localAlertRule{
+	{
+		name:     "GpuPageRetirementPending",
+		severity: "critical",
+		active:   func(s gpuSample) bool { return isSet(s.RetiredPagesPending) },
+		summary:  "framebuffer pages are pending retirement; drain the node and reset the GPU to retire them",
+	},
+	{
+		name:     "GpuRowRemapPending",
+		severity: "critical",
+		active:   func(s gpuSample) bool { return isSet(s.RowRemapPending) },
+		summary:  "a memory row remapping is pending; drain the node and reset the GPU to apply it",
+	},
+	{
+		name:     "GpuRowRemapFailure",
+		severity: "critical",
+		active:   func(s gpuSample) bool { return isSet(s.RowRemapFailure) },
+		summary:  "memory row remapping failed; the GPU cannot repair its memory any more and should be replaced",
+	},
+}
+
+// localAlerter evaluates local alert rules against the backend and posts state changes to
+// the alertmanager adapter in Alertmanager's webhook format, so they go through the same
+// routing and formatting as every other alert.
+type localAlerter struct {
+	backend gpuBackend
+	rules   []localAlertRule
+	adapter *adapterClient
+
+	// firing holds the active alerts by rule name and GPU UUID.
+	firing map[string]webhookAlert
+}
+
+// adapterClient posts alerts raised by the collector itself to the alertmanager adapter.
+type adapterClient struct {
+	url      string
+	secret   []byte
+	token    string
+	instance string
+	client   *http.Client
+	// slurm is set when SLURM_ATTRIBUTION is enabled, to label the alerts with the jobs
+	// of their GPU.
+	slurm *slurmAttribution
+	// aggregator is set when AGGREGATOR_URL is; the alerts then go through its stream
+	// instead of the webhook.
+	aggregator *aggregatorClient
+}
+
+// webhookPayload and webhookAlert are the parts of Alertmanager's webhook format the adapter reads.
+type webhookPayload struct {
+	Status   string         `json:"status"`
+	GroupKey string         `json:"groupKey"`
+	Alerts   []webhookAlert `json:"alerts"`
+}
+
+type webhookAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt"`
+}
+
+// newAdapterClient returns a client for the adapter webhook at url. instance is the
+// instance label of the alerts, secret signs them and token is sent as a bearer token
+// when they are set.
+func newAdapterClient(url, secret, token, instance string) *adapterClient {
+	return &adapterClient{
+		url:      url,
+		secret:   []byte(secret),
+		token:    token,
+		instance: instance,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func newLocalAlerter(backend gpuBackend, rules []localAlertRule, adapter *adapterClient) *localAlerter {
+	return &localAlerter{
+		backend: backend,
+		rules:   rules,
+		adapter: adapter,
+		firing:  make(map[string]webhookAlert),
+	}
+}
+
+// run evaluates the rules right away and then every interval.
+func (l *localAlerter) run(interval time.Duration) {
+	for {
+		if err := l.check(); err != nil {
+			log.Printf("Error evaluating local alerts: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// check posts newly firing and resolved alerts. If the post fails, the state is kept
+// unchanged so the same changes are sent again on the next check.
+func (l *localAlerter) check() error {
+	samples, err := l.backend.Samples()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	next := make(map[string]webhookAlert, len(l.firing))
+	var changed []webhookAlert
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		for _, rule := range l.rules {
+			key := rule.name + "/" + s.UUID
+			seen[key] = true
+			alert, wasFiring := l.firing[key]
+			switch active := rule.active(s); {
+			case active && wasFiring:
+				next[key] = alert
+			case active:
+				alert = l.newAlert(rule, s, now)
+				next[key] = alert
+				changed = append(changed, alert)
+			case wasFiring:
+				alert.Status = "resolved"
+				alert.EndsAt = now
+				changed = append(changed, alert)
+			}
+		}
+	}
+	// Keep alerts for GPUs missing from this sample (e.g. a transient read error) firing.
+	for key, alert := range l.firing {
+		if !seen[key] {
+			next[key] = alert
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := l.adapter.post(changed); err != nil {
+		return err
+	}
+	for _, alert := range changed {
+		log.Printf("Posted local alert %s (%s) for GPU %s", alert.Labels["alertname"], alert.Status, alert.Labels["gpu"])
+	}
+	l.firing = next
+	return nil
+}
+
+func (l *localAlerter) newAlert(rule localAlertRule, s gpuSample, now string) webhookAlert {
+	gpu := strconv.Itoa(s.Index)
+	alert := l.adapter.newAlert(rule.name, rule.severity, gpu, s.UUID, s.Name, now)
+	alert.Annotations["summary"] = fmt.Sprintf("GPU %s on %s: %s", gpu, l.adapter.instance, rule.summary)
+	return alert
+}
+
+// target is where the alerts go, for logs.
+func (c *adapterClient) target() string {
+	if c.aggregator != nil {
+		return c.aggregator.url
+	}
+	return c.url
+}
+
+// newAlert returns a firing alert about one GPU with the collector's common labels.
+func (c *adapterClient) newAlert(name, severity, gpu, uuid, model, startsAt string) webhookAlert {
+	alert := webhookAlert{
+		Status: "firing",
+		Labels: map[string]string{
+			"alertname": name,
+			"severity":  severity,
+			"instance":  c.instance,
+			"job":       "gpu_collector",
+			"gpu":       gpu,
+			"UUID":      uuid,
+			"modelName": model,
+		},
+		Annotations: map[string]string{},
+		StartsAt:    startsAt,
+		EndsAt:      "0001-01-01T00:00:00Z",
+	}
+	if c.slurm != nil {
+		for i, value := range c.slurm.labels(gpu) {
+			if value != "" {
+				alert.Labels[slurmLabels[i]] = value
+			}
+		}
+	}
+	return alert
+}
+
+// post sends the alerts in one webhook request, signed if a secret is configured.
+func (c *adapterClient) post(alerts []webhookAlert) error {
+	if c.aggregator != nil {
+		c.aggregator.queue(alerts)
+		return nil
+	}
+	payload := webhookPayload{Status: "resolved", GroupKey: "gpu-collector/" + c.instance, Alerts: alerts}
+	for _, alert := range alerts {
+		if alert.Status == "firing" {
+			payload.Status = "firing"
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(c.secret) > 0 {
+		mac := hmac.New(sha256.New, c.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to the adapter: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting to the adapter: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// isSet reports whether an optional 0/1 reading is available and 1.
+func isSet(v *float64) bool {
+	return v != nil && *v != 0
+}
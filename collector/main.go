@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	// --install sets the agent up as a systemd service on nodes without Docker, see install.go.
+	install := flag.Bool("install", false, "install the collector as the gpu-collector systemd service and start it")
+	uninstall := flag.Bool("uninstall", false, "stop and remove the gpu-collector systemd service")
+	printVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	switch {
+	case *printVersion:
+		fmt.Println("gpu-collector", version)
+		return
+	case *install:
+		if err := installAgent(); err != nil {
+			log.Fatalf("Error installing the agent: %v", err)
+		}
+		return
+	case *uninstall:
+		if err := uninstallAgent(); err != nil {
+			log.Fatalf("Error uninstalling the agent: %v", err)
+		}
+		return
+	}
+	log.Printf("gpu-collector %s", version)
+
+	// Optional: the address the /metrics and /api/* endpoints listen on.
+	listenAddress := os.Getenv("LISTEN_ADDRESS")
+	if listenAddress == "" {
+		listenAddress = ":9500"
+	}
+
+	// GPU_BACKEND selects where GPU state comes from: "nvml" (default) reads the driver
+	// directly, "dcgm" scrapes the dcgm-exporter at DCGM_EXPORTER_URL for DCGM health fields,
+	// "rocm" reads AMD GPUs from the amdgpu files in DRM_SYSFS_PATH (default
+	// /sys/class/drm), "xpu" Intel Data Center GPUs with XPU_SMI_PATH (default xpu-smi) and
+	// "gaudi" Intel Gaudi accelerators with HL_SMI_PATH (default hl-smi). The metrics are the
+	// same for every backend, labelled with the vendor.
+	var backend gpuBackend
+	switch mode := os.Getenv("GPU_BACKEND"); mode {
+	case "", "nvml":
+		// NVML is loaded dynamically from the driver; inside a container this needs the NVIDIA runtime.
+		if ret := nvml.Init(); ret != nvml.SUCCESS {
+			log.Fatalf("Error initializing NVML: %s", nvml.ErrorString(ret))
+		}
+		defer nvml.Shutdown()
+
+		if version, ret := nvml.SystemGetDriverVersion(); ret == nvml.SUCCESS {
+			log.Printf("NVML initialized, driver version %s", version)
+		}
+		backend = nvmlBackend{}
+	case "dcgm":
+		url := os.Getenv("DCGM_EXPORTER_URL")
+		if url == "" {
+			url = "http://localhost:9400/metrics"
+		}
+		backend = newDCGMBackend(url)
+		log.Printf("Reading GPU state from dcgm-exporter at %s", url)
+	case "rocm":
+		drm := os.Getenv("DRM_SYSFS_PATH")
+		if drm == "" {
+			drm = "/sys/class/drm"
+		}
+		backend = newROCmBackend(drm)
+		log.Printf("Reading AMD GPU state from %s", drm)
+	case "xpu":
+		xpuSMI := os.Getenv("XPU_SMI_PATH")
+		if xpuSMI == "" {
+			xpuSMI = "xpu-smi"
+		}
+		if _, err := exec.LookPath(xpuSMI); err != nil {
+			log.Fatalf("Error finding xpu-smi for GPU_BACKEND=xpu: %v", err)
+		}
+		backend = newXPUBackend(xpuSMI)
+		log.Printf("Reading Intel GPU state with %s", xpuSMI)
+	case "gaudi":
+		hlSMI := os.Getenv("HL_SMI_PATH")
+		if hlSMI == "" {
+			hlSMI = "hl-smi"
+		}
+		if _, err := exec.LookPath(hlSMI); err != nil {
+			log.Fatalf("Error finding hl-smi for GPU_BACKEND=gaudi: %v", err)
+		}
+		backend = newGaudiBackend(hlSMI)
+		log.Printf("Reading Intel Gaudi state with %s", hlSMI)
+	default:
+		log.Fatalf("Error: unsupported GPU_BACKEND %q (expected \"nvml\", \"dcgm\", \"rocm\", \"xpu\" or \"gaudi\")", mode)
+	}
+
+	// Optional: flag GPUs whose temperature rises faster than THERMAL_TREND_THRESHOLD
+	// (°C per minute, default 2) over the last THERMAL_TREND_WINDOW (default 5m).
+	window := 5 * time.Minute
+	if v := os.Getenv("THERMAL_TREND_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Error: invalid THERMAL_TREND_WINDOW %q", v)
+		}
+		window = d
+	}
+	threshold := 2.0
+	if v := os.Getenv("THERMAL_TREND_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			log.Fatalf("Error: invalid THERMAL_TREND_THRESHOLD %q", v)
+		}
+		threshold = f
+	}
+	log.Printf("Thermal trend alert above %g°C/min over %s", threshold, window)
+
+	// Optional: flag GPUs that draw their enforced power limit for longer than
+	// POWER_CAP_DURATION (default 10m).
+	powerCapDuration := 10 * time.Minute
+	if v := os.Getenv("POWER_CAP_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Error: invalid POWER_CAP_DURATION %q", v)
+		}
+		powerCapDuration = d
+	}
+	log.Printf("Power cap alert after %s at the power limit", powerCapDuration)
+
+	// Optional: flag GPUs that stay below IDLE_GPU_UTILIZATION_PERCENT (default 5) for
+	// longer than IDLE_GPU_DURATION (default 30m) while allocated to a pod or SLURM job
+	// (see POD_ATTRIBUTION and SLURM_ATTRIBUTION) or held by compute processes.
+	idleDuration := 30 * time.Minute
+	if v := os.Getenv("IDLE_GPU_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Error: invalid IDLE_GPU_DURATION %q", v)
+		}
+		idleDuration = d
+	}
+	idleThreshold := 5.0
+	if v := os.Getenv("IDLE_GPU_UTILIZATION_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			log.Fatalf("Error: invalid IDLE_GPU_UTILIZATION_PERCENT %q", v)
+		}
+		idleThreshold = f
+	}
+	log.Printf("Idle GPU alert after %s below %g%% utilization while allocated", idleDuration, idleThreshold)
+
+	// Optional: flag GPUs that keep more than GPU_MEMORY_LEAK_THRESHOLD_MIB (default 1024)
+	// allocated for longer than GPU_MEMORY_LEAK_DURATION (default 5m) after their last
+	// compute process, pod or job is gone. Only NVML lists the processes on a GPU, so other
+	// backends do not track leaks.
+	var leaks *memoryLeaks
+	if _, ok := backend.(nvmlBackend); ok {
+		leakDuration := 5 * time.Minute
+		if v := os.Getenv("GPU_MEMORY_LEAK_DURATION"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid GPU_MEMORY_LEAK_DURATION %q", v)
+			}
+			leakDuration = d
+		}
+		leakThreshold := 1024.0
+		if v := os.Getenv("GPU_MEMORY_LEAK_THRESHOLD_MIB"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				log.Fatalf("Error: invalid GPU_MEMORY_LEAK_THRESHOLD_MIB %q", v)
+			}
+			leakThreshold = f
+		}
+		leaks = newMemoryLeaks(leakDuration, leakThreshold*1024*1024)
+		log.Printf("GPU memory leak alert after %s above %g MiB without an owner", leakDuration, leakThreshold)
+	}
+
+	// Optional: SLURM_ATTRIBUTION=scontrol (on SLURM compute nodes) labels the per-GPU
+	// metrics and the collector's own alerts with the slurm_job_id and user of the job the
+	// GPU is allocated to. The jobs are read with SCONTROL_PATH (default scontrol) every
+	// SLURM_ATTRIBUTION_INTERVAL (default 30s); SLURM_NODE_NAME is the node's name in
+	// SLURM (default the short hostname).
+	var slurm *slurmAttribution
+	switch mode := os.Getenv("SLURM_ATTRIBUTION"); mode {
+	case "", "off":
+	case "scontrol":
+		scontrol := os.Getenv("SCONTROL_PATH")
+		if scontrol == "" {
+			scontrol = "scontrol"
+		}
+		node := os.Getenv("SLURM_NODE_NAME")
+		if node == "" {
+			hostname, _ := os.Hostname()
+			node, _, _ = strings.Cut(hostname, ".")
+		}
+		interval := 30 * time.Second
+		if v := os.Getenv("SLURM_ATTRIBUTION_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid SLURM_ATTRIBUTION_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		slurm = newSlurmAttribution(scontrol, node)
+		go slurm.run(interval)
+		log.Printf("Attributing GPUs to the SLURM jobs of node %s every %s", node, interval)
+	default:
+		log.Fatalf("Error: unsupported SLURM_ATTRIBUTION %q (expected \"scontrol\" or \"off\")", mode)
+	}
+
+	// Optional: stream the metrics, inventory and alerts over gRPC (see
+	// proto/aggregator.proto) to the aggregator at AGGREGATOR_URL, e.g.
+	// https://adapter:9443, for nodes Prometheus cannot scrape. A report goes out every
+	// AGGREGATOR_INTERVAL (default 30s), named NODE_NAME (default: hostname).
+	// AGGREGATOR_CA_FILE verifies the aggregator (default: the system roots), and
+	// AGGREGATOR_CERT_FILE and AGGREGATOR_KEY_FILE are the node's client certificate for
+	// mTLS. Alerts raised by the collector itself then go through the stream instead of
+	// ADAPTER_URL.
+	var aggregator *aggregatorClient
+	if aggregatorURL := os.Getenv("AGGREGATOR_URL"); aggregatorURL != "" {
+		if !strings.HasPrefix(aggregatorURL, "https://") {
+			log.Fatalf("Error: AGGREGATOR_URL %q must be an https:// URL", aggregatorURL)
+		}
+		interval := 30 * time.Second
+		if v := os.Getenv("AGGREGATOR_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid AGGREGATOR_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		tlsConfig, err := newAggregatorTLSConfig(os.Getenv("AGGREGATOR_CA_FILE"), os.Getenv("AGGREGATOR_CERT_FILE"), os.Getenv("AGGREGATOR_KEY_FILE"))
+		if err != nil {
+			log.Fatalf("Error loading the aggregator TLS files: %v", err)
+		}
+		node := os.Getenv("NODE_NAME")
+		if node == "" {
+			node, _ = os.Hostname()
+		}
+		aggregator, err = newAggregatorClient(aggregatorURL, node, interval, tlsConfig)
+		if err != nil {
+			log.Fatalf("Error: invalid AGGREGATOR_URL %q: %v", aggregatorURL, err)
+		}
+	}
+
+	// Optional: post alerts raised by the collector itself (memory health, XID errors)
+	// straight to the alertmanager adapter at ADAPTER_URL, or through the aggregator.
+	var adapter *adapterClient
+	if adapterURL := os.Getenv("ADAPTER_URL"); adapterURL != "" || aggregator != nil {
+		interval := time.Minute
+		if v := os.Getenv("LOCAL_ALERT_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid LOCAL_ALERT_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		instance := os.Getenv("NODE_NAME")
+		if instance == "" {
+			instance, _ = os.Hostname()
+		}
+		adapter = newAdapterClient(adapterURL, os.Getenv("ADAPTER_SIGNATURE_SECRET"), os.Getenv("ADAPTER_BEARER_TOKEN"), instance)
+		adapter.slurm = slurm
+		adapter.aggregator = aggregator
+		go newLocalAlerter(backend, memoryHealthRules, adapter).run(interval)
+		log.Printf("Posting memory health alerts for %s to %s every %s", instance, adapter.target(), interval)
+	}
+
+	// XID_WATCHER picks where XID errors are watched as they happen: "nvml" (the default
+	// with the NVML backend) uses NVML's event API, "kmsg" follows the kernel log at
+	// KMSG_PATH (default /dev/kmsg) and "off" disables the watcher. An XID alert is
+	// resolved once XID_RESOLVE_AFTER (default 1h) passes without the same XID on the GPU.
+	xidHold := defaultXIDHold
+	if v := os.Getenv("XID_RESOLVE_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Error: invalid XID_RESOLVE_AFTER %q", v)
+		}
+		xidHold = d
+	}
+	xids := newXIDWatcher(adapter, xidHold)
+	xidSource := os.Getenv("XID_WATCHER")
+	if xidSource == "" {
+		xidSource = "off"
+		if _, ok := backend.(nvmlBackend); ok {
+			xidSource = "nvml"
+		}
+	}
+	switch xidSource {
+	case "nvml":
+		if _, ok := backend.(nvmlBackend); !ok {
+			log.Fatalf("Error: XID_WATCHER=nvml requires GPU_BACKEND=nvml")
+		}
+		go func() {
+			if err := xids.watchNVMLXIDs(); err != nil {
+				log.Printf("Error watching XID events, gpu_xid_errors_total stays empty: %v", err)
+			}
+		}()
+		log.Printf("Watching XID errors through NVML events")
+	case "kmsg":
+		path := os.Getenv("KMSG_PATH")
+		if path == "" {
+			path = "/dev/kmsg"
+		}
+		go func() {
+			if err := xids.watchKernelLogXIDs(path, backend); err != nil {
+				log.Printf("Error watching the kernel log for XID errors, gpu_xid_errors_total stays empty: %v", err)
+			}
+		}()
+		log.Printf("Watching XID errors in %s", path)
+	case "off":
+	default:
+		log.Fatalf("Error: unsupported XID_WATCHER %q (expected \"nvml\", \"kmsg\" or \"off\")", xidSource)
+	}
+	if adapter != nil && xidSource != "off" {
+		go xids.runResolver(time.Minute)
+	}
+
+	// Optional: FRAGMENTATION_PROBE_INTERVAL (e.g. 10m) enables the memory fragmentation
+	// probe, which finds the largest allocatable block on every GPU through the CUDA
+	// driver (libcuda, the "compute" driver capability). It briefly creates a CUDA context
+	// and trial allocations on each GPU, so keep the interval long.
+	var probe *memoryProbe
+	if v := os.Getenv("FRAGMENTATION_PROBE_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Error: invalid FRAGMENTATION_PROBE_INTERVAL %q", v)
+		}
+		if probe, err = newMemoryProbe(); err != nil {
+			log.Printf("Error loading the CUDA driver, memory fragmentation is not probed: %v", err)
+		} else {
+			go probe.run(interval)
+			log.Printf("Probing GPU memory fragmentation every %s", interval)
+		}
+	}
+
+	// Optional: POD_ATTRIBUTION=kubelet (in a Kubernetes DaemonSet) labels the per-GPU
+	// metrics with the pod, namespace and container the GPU is allocated to, read from the
+	// kubelet pod-resources API at KUBELET_POD_RESOURCES_SOCKET every
+	// POD_ATTRIBUTION_INTERVAL (default 30s).
+	var pods *podAttribution
+	switch mode := os.Getenv("POD_ATTRIBUTION"); mode {
+	case "", "off":
+	case "kubelet":
+		socket := os.Getenv("KUBELET_POD_RESOURCES_SOCKET")
+		if socket == "" {
+			socket = defaultPodResourcesSocket
+		}
+		interval := 30 * time.Second
+		if v := os.Getenv("POD_ATTRIBUTION_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid POD_ATTRIBUTION_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		pods = newPodAttribution(socket)
+		go pods.run(interval)
+		log.Printf("Attributing GPUs to pods through %s every %s", socket, interval)
+	default:
+		log.Fatalf("Error: unsupported POD_ATTRIBUTION %q (expected \"kubelet\" or \"off\")", mode)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newGPUCollector(backend, newThermalTrends(window, threshold), newPowerCaps(powerCapDuration), newIdleAllocations(idleDuration, idleThreshold), leaks, probe, pods, slurm), xids)
+	registry.MustRegister(agentCollectors()...)
+
+	// Optional: CHASSIS_SENSORS exports the node's fans, temperatures and power supplies
+	// read from its BMC every CHASSIS_INTERVAL (default 30s): "ipmi" runs ipmitool
+	// against the local BMC (needs /dev/ipmi0), "redfish" queries the BMC's Redfish API at
+	// REDFISH_URL with REDFISH_USERNAME and REDFISH_PASSWORD (REDFISH_INSECURE=true skips
+	// certificate verification). Default "off".
+	var readChassis func(ctx context.Context) (chassisReading, error)
+	switch mode := os.Getenv("CHASSIS_SENSORS"); mode {
+	case "", "off":
+	case "ipmi":
+		ipmitool := os.Getenv("IPMITOOL_PATH")
+		if ipmitool == "" {
+			ipmitool = "ipmitool"
+		}
+		readChassis = readIPMISensors(ipmitool)
+	case "redfish":
+		redfishURL := os.Getenv("REDFISH_URL")
+		if redfishURL == "" {
+			log.Fatalf("Error: CHASSIS_SENSORS=redfish requires REDFISH_URL")
+		}
+		readChassis = newRedfishClient(redfishURL, os.Getenv("REDFISH_USERNAME"), os.Getenv("REDFISH_PASSWORD"), os.Getenv("REDFISH_INSECURE") == "true").read
+	default:
+		log.Fatalf("Error: unsupported CHASSIS_SENSORS %q (expected \"ipmi\", \"redfish\" or \"off\")", mode)
+	}
+	if readChassis != nil {
+		interval := 30 * time.Second
+		if v := os.Getenv("CHASSIS_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid CHASSIS_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		chassis := newChassisMonitor(readChassis)
+		go chassis.run(interval)
+		registry.MustRegister(chassis)
+		log.Printf("Reading chassis sensors through %s every %s", os.Getenv("CHASSIS_SENSORS"), interval)
+	}
+
+	// Optional: STORAGE_MONITOR=on exports the usage of the STORAGE_FILESYSTEMS mount
+	// points (comma separated, default /; mount them from the host in a container), the
+	// NVMe SMART logs read with NVME_CLI_PATH (default nvme; needs the /dev/nvme* devices)
+	// and the software RAID arrays of MDSTAT_PATH (default /proc/mdstat) every
+	// STORAGE_INTERVAL (default 1m). With ADAPTER_URL, filesystems above
+	// STORAGE_FULL_PERCENT (default 90) of their space or inodes, NVMe drives with critical
+	// warnings, media errors or above NVME_WEAR_PERCENT (default 90) of their endurance,
+	// and degraded arrays raise alerts.
+	switch mode := os.Getenv("STORAGE_MONITOR"); mode {
+	case "", "off":
+	case "on":
+		filesystems := []string{"/"}
+		if v := os.Getenv("STORAGE_FILESYSTEMS"); v != "" {
+			filesystems = strings.Split(v, ",")
+		}
+		mdstat := os.Getenv("MDSTAT_PATH")
+		if mdstat == "" {
+			mdstat = "/proc/mdstat"
+		}
+		nvmeCLI := os.Getenv("NVME_CLI_PATH")
+		if nvmeCLI == "" {
+			nvmeCLI = "nvme"
+		}
+		if _, err := exec.LookPath(nvmeCLI); err != nil {
+			log.Printf("Error finding nvme-cli, NVMe SMART logs are not read: %v", err)
+			nvmeCLI = ""
+		}
+		interval := time.Minute
+		if v := os.Getenv("STORAGE_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid STORAGE_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		fullPercent := 90.0
+		if v := os.Getenv("STORAGE_FULL_PERCENT"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 || f > 100 {
+				log.Fatalf("Error: invalid STORAGE_FULL_PERCENT %q", v)
+			}
+			fullPercent = f
+		}
+		wearPercent := 90.0
+		if v := os.Getenv("NVME_WEAR_PERCENT"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				log.Fatalf("Error: invalid NVME_WEAR_PERCENT %q", v)
+			}
+			wearPercent = f
+		}
+		storage := newStorageMonitor(filesystems, mdstat, nvmeCLI, fullPercent, wearPercent, adapter)
+		go storage.run(interval)
+		registry.MustRegister(storage)
+		log.Printf("Monitoring filesystems %s, NVMe drives and RAID arrays every %s", strings.Join(filesystems, ", "), interval)
+	default:
+		log.Fatalf("Error: unsupported STORAGE_MONITOR %q (expected \"on\" or \"off\")", mode)
+	}
+
+	// Optional: push the metrics every REMOTE_WRITE_INTERVAL (default 30s) to the
+	// Prometheus remote-write endpoint REMOTE_WRITE_URL, for nodes Prometheus cannot
+	// scrape (e.g. behind NAT). The series get job REMOTE_WRITE_JOB (default
+	// gpu_collector) and instance NODE_NAME (default: hostname). REMOTE_WRITE_BEARER_TOKEN
+	// or REMOTE_WRITE_USERNAME and REMOTE_WRITE_PASSWORD authenticate the pushes.
+	if remoteWriteURL := os.Getenv("REMOTE_WRITE_URL"); remoteWriteURL != "" {
+		interval := 30 * time.Second
+		if v := os.Getenv("REMOTE_WRITE_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid REMOTE_WRITE_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		job := os.Getenv("REMOTE_WRITE_JOB")
+		if job == "" {
+			job = "gpu_collector"
+		}
+		instance := os.Getenv("NODE_NAME")
+		if instance == "" {
+			instance, _ = os.Hostname()
+		}
+		var authorize func(req *http.Request)
+		if token := os.Getenv("REMOTE_WRITE_BEARER_TOKEN"); token != "" {
+			authorize = func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+		} else if username := os.Getenv("REMOTE_WRITE_USERNAME"); username != "" {
+			password := os.Getenv("REMOTE_WRITE_PASSWORD")
+			authorize = func(req *http.Request) { req.SetBasicAuth(username, password) }
+		}
+		registry.MustRegister(remoteWriteErrors)
+		go newRemoteWriter(remoteWriteURL, registry, job, instance, authorize).run(interval)
+		log.Printf("Pushing metrics as instance %s to %s every %s", instance, remoteWriteURL, interval)
+	}
+
+	// Optional: evaluate the threshold rules of RULES_FILE (see rules.go for the format)
+	// against the collector's own metrics every RULES_INTERVAL (default 30s), for labs
+	// without Prometheus. Alerts go to the Alertmanager API at ALERTMANAGER_URL, or else
+	// to the adapter at ADAPTER_URL.
+	if rulesFile := os.Getenv("RULES_FILE"); rulesFile != "" {
+		rules, err := loadThresholdRules(rulesFile)
+		if err != nil {
+			log.Fatalf("Error loading RULES_FILE: %v", err)
+		}
+		interval := 30 * time.Second
+		if v := os.Getenv("RULES_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid RULES_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		var sink alertSink
+		target := os.Getenv("ALERTMANAGER_URL")
+		switch {
+		case target != "":
+			sink = newAlertmanagerClient(target)
+		case adapter != nil:
+			sink, target = adapter, adapter.target()
+		default:
+			log.Fatalf("Error: RULES_FILE requires ALERTMANAGER_URL or ADAPTER_URL")
+		}
+		instance := os.Getenv("NODE_NAME")
+		if instance == "" {
+			instance, _ = os.Hostname()
+		}
+		go newRulesEngine(registry, rules, sink, instance).run(interval)
+		log.Printf("Evaluating %d alerting rules every %s, sending alerts to %s", len(rules), interval, target)
+	}
+
+	// Optional: SELFTEST=cuda serves POST /api/selftest/{gpu}, which runs a short
+	// bandwidth and GEMM benchmark on an idle GPU and compares it with the GPU's baseline
+	// (its first run, or one posted with ?baseline=true). Results are kept in
+	// SELFTEST_RESULTS_PATH (default /var/lib/gpu-collector/selftest.json); a result more
+	// than SELFTEST_MAX_DROP_PERCENT (default 10) below the baseline raises
+	// GpuSelfTestDegraded at ADAPTER_URL. Callers must send SELFTEST_TOKEN as a bearer
+	// token; it is required because a self-test occupies the GPU.
+	switch mode := os.Getenv("SELFTEST"); mode {
+	case "", "off":
+	case "cuda":
+		path := os.Getenv("SELFTEST_RESULTS_PATH")
+		if path == "" {
+			path = "/var/lib/gpu-collector/selftest.json"
+		}
+		maxDrop := 10.0
+		if v := os.Getenv("SELFTEST_MAX_DROP_PERCENT"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 || f >= 100 {
+				log.Fatalf("Error: invalid SELFTEST_MAX_DROP_PERCENT %q", v)
+			}
+			maxDrop = f
+		}
+		token := os.Getenv("SELFTEST_TOKEN")
+		if token == "" {
+			log.Fatalf("Error: SELFTEST requires SELFTEST_TOKEN")
+		}
+		tester, err := newSelfTester(backend, path, maxDrop, token, adapter)
+		if err != nil {
+			log.Fatalf("Error enabling SELFTEST: %v", err)
+		}
+		registry.MustRegister(tester)
+		http.HandleFunc("/api/selftest", tester.handle)
+		http.HandleFunc("/api/selftest/", tester.handle)
+		log.Printf("Serving GPU self-tests, alerting on drops of more than %g%% below the baseline", maxDrop)
+	default:
+		log.Fatalf("Error: unsupported SELFTEST %q (expected \"cuda\" or \"off\")", mode)
+	}
+
+	// Optional: send a heartbeat every HEARTBEAT_INTERVAL (default 30s) to the adapter's
+	// dead man's switch at HEARTBEAT_URL, which alerts when the node goes silent.
+	// HEARTBEAT_TOKEN, the adapter's heartbeat.token, is required.
+	if heartbeatURL := os.Getenv("HEARTBEAT_URL"); heartbeatURL != "" {
+		token := os.Getenv("HEARTBEAT_TOKEN")
+		if token == "" {
+			log.Fatalf("Error: HEARTBEAT_URL requires HEARTBEAT_TOKEN")
+		}
+		interval := 30 * time.Second
+		if v := os.Getenv("HEARTBEAT_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid HEARTBEAT_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		node := os.Getenv("NODE_NAME")
+		if node == "" {
+			node, _ = os.Hostname()
+		}
+		registry.MustRegister(heartbeatErrors)
+		go newHeartbeater(heartbeatURL, token, node, interval).run()
+		log.Printf("Sending heartbeats for %s to %s every %s", node, heartbeatURL, interval)
+	}
+
+	// Optional: TOPOLOGY=nvml serves /api/topology with the GPU topology (NVLinks, NUMA
+	// nodes, PCIe switches), read every TOPOLOGY_INTERVAL (default 5m). The first
+	// topology read is the baseline, kept in TOPOLOGY_BASELINE_PATH (default
+	// /var/lib/gpu-collector/topology.json); a topology that differs from it raises
+	// GpuTopologyChanged at ADAPTER_URL until POST /api/topology?baseline=true accepts
+	// it. Callers must send TOPOLOGY_TOKEN as a bearer token.
+	switch mode := os.Getenv("TOPOLOGY"); mode {
+	case "", "off":
+	case "nvml":
+		if _, ok := backend.(nvmlBackend); !ok {
+			log.Fatalf("Error: TOPOLOGY=nvml requires GPU_BACKEND=nvml")
+		}
+		token := os.Getenv("TOPOLOGY_TOKEN")
+		if token == "" {
+			log.Fatalf("Error: TOPOLOGY requires TOPOLOGY_TOKEN")
+		}
+		path := os.Getenv("TOPOLOGY_BASELINE_PATH")
+		if path == "" {
+			path = "/var/lib/gpu-collector/topology.json"
+		}
+		interval := 5 * time.Minute
+		if v := os.Getenv("TOPOLOGY_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				log.Fatalf("Error: invalid TOPOLOGY_INTERVAL %q", v)
+			}
+			interval = d
+		}
+		topology, err := newTopologyWatcher(path, token, adapter)
+		if err != nil {
+			log.Fatalf("Error loading TOPOLOGY_BASELINE_PATH: %v", err)
+		}
+		go topology.run(interval)
+		registry.MustRegister(topology)
+		http.HandleFunc("/api/topology", topology.handle)
+		log.Printf("Checking the GPU topology against the baseline in %s every %s", path, interval)
+	default:
+		log.Fatalf("Error: unsupported TOPOLOGY %q (expected \"nvml\" or \"off\")", mode)
+	}
+
+	// Optional: on nodes with InfiniBand or RoCE NICs, export their port state, error
+	// counters and traffic from INFINIBAND_SYSFS_PATH (default /sys/class/infiniband) on
+	// every scrape. INFINIBAND=off disables it.
+	fabricSysfs := os.Getenv("INFINIBAND_SYSFS_PATH")
+	if fabricSysfs == "" {
+		fabricSysfs = "/sys/class/infiniband"
+	}
+	if os.Getenv("INFINIBAND") != "off" && hasFabric(fabricSysfs) {
+		registry.MustRegister(newFabricMonitor(fabricSysfs))
+		log.Printf("Exporting the InfiniBand/RoCE ports of %s", fabricSysfs)
+	}
+
+	if aggregator != nil {
+		aggregator.gatherer, aggregator.backend = registry, backend
+		registry.MustRegister(aggregatorErrors)
+		go aggregator.run()
+		log.Printf("Streaming metrics, inventory and alerts as node %s to the aggregator at %s every %s", aggregator.node, aggregator.url, aggregator.interval)
+	}
+
+	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	http.Handle("/api/inventory", inventoryHandler(backend))
+
+	log.Printf("GPU collector listening on %s", listenAddress)
+	if err := http.ListenAndServe(listenAddress, nil); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gpuLabels identify the GPU on every per-device metric; see deviceLabels for the
+// workload labels that may follow them.
+var gpuLabels = []string{"gpu", "uuid", "name"}
+
+// deviceLabels are the labels of the per-device metrics: gpuLabels and vendor, then
+// workloadLabels when POD_ATTRIBUTION=kubelet, then slurmLabels when
+// SLURM_ATTRIBUTION=scontrol. The label set has to be known when the descriptors are
+// created, so it is read from the environment at package initialization rather than in
+// main. Its capacity is its length, so descriptors appending their own labels to it
+// never share an array.
+var deviceLabels = func() []string {
+	labels := append(gpuLabels[:len(gpuLabels):len(gpuLabels)], "vendor")
+	if os.Getenv("POD_ATTRIBUTION") == "kubelet" {
+		labels = append(labels, workloadLabels...)
+	}
+	if os.Getenv("SLURM_ATTRIBUTION") == "scontrol" {
+		labels = append(labels, slurmLabels...)
+	}
+	return labels[:len(labels):len(labels)]
+}()
+
+var (
+	utilizationDesc = prometheus.NewDesc("gpu_utilization_percent",
+		"Percent of time over the last sample period during which a kernel was executing on the GPU.", deviceLabels, nil)
+	memoryUsedDesc = prometheus.NewDesc("gpu_memory_used_bytes",
+		"GPU framebuffer memory currently allocated.", deviceLabels, nil)
+	memoryTotalDesc = prometheus.NewDesc("gpu_memory_total_bytes",
+		"Total GPU framebuffer memory.", deviceLabels, nil)
+	temperatureDesc = prometheus.NewDesc("gpu_temperature_celsius",
+		"GPU core temperature.", deviceLabels, nil)
+	powerDrawDesc = prometheus.NewDesc("gpu_power_draw_watts",
+		"Current GPU power draw.", deviceLabels, nil)
+	powerLimitDesc = prometheus.NewDesc("gpu_power_limit_watts",
+		"Power limit currently enforced by the driver.", deviceLabels, nil)
+	energyDesc = prometheus.NewDesc("gpu_energy_consumed_joules_total",
+		"Energy used by the GPU since the driver was last loaded.", deviceLabels, nil)
+	powerCappedDesc = prometheus.NewDesc("gpu_power_capped_seconds",
+		"How long the GPU has been drawing its enforced power limit (0 if it is below it).", deviceLabels, nil)
+	powerCapAlertDesc = prometheus.NewDesc("gpu_power_cap_alert",
+		"1 if the GPU has been at its power limit for longer than the configured duration, 0 otherwise.", deviceLabels, nil)
+	idleAllocatedDesc = prometheus.NewDesc("gpu_idle_allocated_seconds",
+		"How long the GPU has been idle while allocated to a pod, job or process (0 if it is busy or free).", deviceLabels, nil)
+	idleAllocatedAlertDesc = prometheus.NewDesc("gpu_idle_allocated_alert",
+		"1 if the GPU has been idle while allocated for longer than the configured duration, 0 otherwise.", deviceLabels, nil)
+	memoryLeakedDesc = prometheus.NewDesc("gpu_memory_leaked_bytes",
+		"GPU memory still allocated with no compute process, pod or job left on the GPU (0 if it has an owner).", deviceLabels, nil)
+	memoryLeakAlertDesc = prometheus.NewDesc("gpu_memory_leak_alert",
+		"1 if GPU memory has stayed allocated without an owner for longer than the configured duration, 0 otherwise.", deviceLabels, nil)
+	fanSpeedDesc = prometheus.NewDesc("gpu_fan_speed_percent",
+		"Intended fan speed as a percent of the maximum.", deviceLabels, nil)
+	eccErrorsDesc = prometheus.NewDesc("gpu_ecc_errors_total",
+		"Lifetime (aggregate) ECC memory errors by error type.", append(deviceLabels, "error_type"), nil)
+	eccVolatileErrorsDesc = prometheus.NewDesc("gpu_ecc_volatile_errors_total",
+		"ECC memory errors since the driver was last loaded, by error type.", append(deviceLabels, "error_type"), nil)
+	remappedRowsDesc = prometheus.NewDesc("gpu_remapped_rows",
+		"Memory rows remapped, by the kind of error that caused the remapping.", append(deviceLabels, "cause"), nil)
+	rowRemapPendingDesc = prometheus.NewDesc("gpu_row_remap_pending",
+		"1 if a row remapping is pending and takes effect after the next GPU reset, 0 otherwise.", deviceLabels, nil)
+	rowRemapFailureDesc = prometheus.NewDesc("gpu_row_remap_failure",
+		"1 if a row remapping has failed (the GPU's memory cannot be repaired any further), 0 otherwise.", deviceLabels, nil)
+
+	xidLastErrorDesc = prometheus.NewDesc("gpu_xid_last_error_code",
+		"Code of the most recent XID error reported for the GPU (0 if none).", deviceLabels, nil)
+	nvlinkErrorsDesc = prometheus.NewDesc("gpu_nvlink_errors_total",
+		"NVLink errors summed over all links, by error type.", append(deviceLabels, "error_type"), nil)
+	nvlinkDataDesc = prometheus.NewDesc("gpu_nvlink_data_bytes_total",
+		"Payload data moved over the GPU's NVLinks since the driver was last loaded, by direction (NVML backend).", append(deviceLabels, "direction"), nil)
+	nvlinkThroughputDesc = prometheus.NewDesc("gpu_nvlink_throughput_bytes_per_second",
+		"Current NVLink throughput summed over the GPU's links, by direction (DCGM backend).", append(deviceLabels, "direction"), nil)
+	pcieReplayErrorsDesc = prometheus.NewDesc("gpu_pcie_replay_errors_total",
+		"PCIe replays, i.e. packets the link had to resend after an error.", deviceLabels, nil)
+	pcieThroughputDesc = prometheus.NewDesc("gpu_pcie_throughput_bytes_per_second",
+		"Current PCIe throughput, by direction.", append(deviceLabels, "direction"), nil)
+	throttleReasonDesc = prometheus.NewDesc("gpu_clock_throttle_reason",
+		"1 if the reason is currently holding the GPU clocks below their maximum, 0 otherwise; only reasons the GPU supports are reported.", append(deviceLabels, "reason"), nil)
+	thermalViolationDesc = prometheus.NewDesc("gpu_thermal_violation_seconds_total",
+		"Time the GPU spent throttled because of thermal limits.", deviceLabels, nil)
+	retiredPagesDesc = prometheus.NewDesc("gpu_retired_pages",
+		"Framebuffer pages retired, by retirement cause.", append(deviceLabels, "cause"), nil)
+	retiredPagesPendingDesc = prometheus.NewDesc("gpu_retired_pages_pending",
+		"1 if page retirement is pending and takes effect after the next GPU reset, 0 otherwise.", deviceLabels, nil)
+
+	temperatureTrendDesc = prometheus.NewDesc("gpu_temperature_trend_celsius_per_minute",
+		"Rate of change of the GPU temperature over the trend window (least-squares fit).", deviceLabels, nil)
+	thermalTrendAlertDesc = prometheus.NewDesc("gpu_thermal_trend_alert",
+		"1 if the GPU temperature is rising faster than the configured threshold, 0 otherwise.", deviceLabels, nil)
+
+	processMemoryDesc = prometheus.NewDesc("gpu_process_memory_used_bytes",
+		"GPU memory used by a compute process.", append(gpuLabels, "pid", "user", "container", "command"), nil)
+
+	scrapeErrorDesc = prometheus.NewDesc("gpu_collector_scrape_error",
+		"1 if the last attempt to read GPU state failed, 0 otherwise.", nil, nil)
+)
+
+// gpuCollector is a prometheus.Collector that reads the backend on every scrape.
+type gpuCollector struct {
+	backend   gpuBackend
+	trends    *thermalTrends
+	powerCaps *powerCaps
+	idle      *idleAllocations
+	// leaks is nil unless the backend lists the GPUs' processes (NVML).
+	leaks *memoryLeaks
+	// memoryProbe is nil unless the fragmentation probe is enabled.
+	memoryProbe *memoryProbe
+	// pods is nil unless POD_ATTRIBUTION labels the metrics with the GPUs' pods.
+	pods *podAttribution
+	// slurm is nil unless SLURM_ATTRIBUTION labels the metrics with the GPUs' jobs.
+	slurm *slurmAttribution
+}
+
+func newGPUCollector(backend gpuBackend, trends *thermalTrends, powerCaps *powerCaps, idle *idleAllocations, leaks *memoryLeaks, memoryProbe *memoryProbe, pods *podAttribution, slurm *slurmAttribution) *gpuCollector {
+	return &gpuCollector{backend: backend, trends: trends, powerCaps: powerCaps, idle: idle, leaks: leaks, memoryProbe: memoryProbe, pods: pods, slurm: slurm}
+}
+
+func (c *gpuCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- utilizationDesc
+	ch <- memoryUsedDesc
+	ch <- memoryTotalDesc
+	ch <- temperatureDesc
+	ch <- powerDrawDesc
+	ch <- powerLimitDesc
+	ch <- energyDesc
+	ch <- powerCappedDesc
+	ch <- powerCapAlertDesc
+	ch <- idleAllocatedDesc
+	ch <- idleAllocatedAlertDesc
+	ch <- memoryLeakedDesc
+	ch <- memoryLeakAlertDesc
+	ch <- fanSpeedDesc
+	ch <- eccErrorsDesc
+	ch <- eccVolatileErrorsDesc
+	ch <- remappedRowsDesc
+	ch <- rowRemapPendingDesc
+	ch <- rowRemapFailureDesc
+	ch <- xidLastErrorDesc
+	ch <- nvlinkErrorsDesc
+	ch <- nvlinkDataDesc
+	ch <- nvlinkThroughputDesc
+	ch <- pcieReplayErrorsDesc
+	ch <- pcieThroughputDesc
+	ch <- throttleReasonDesc
+	ch <- thermalViolationDesc
+	ch <- retiredPagesDesc
+	ch <- retiredPagesPendingDesc
+	ch <- temperatureTrendDesc
+	ch <- thermalTrendAlertDesc
+	ch <- processMemoryDesc
+	ch <- migModeDesc
+	ch <- migMemoryUsedDesc
+	ch <- migMemoryTotalDesc
+	ch <- migUtilizationDesc
+	ch <- largestFreeBlockDesc
+	ch <- probedFreeMemoryDesc
+	ch <- fragmentationDesc
+	ch <- scrapeErrorDesc
+}
+
+func (c *gpuCollector) Collect(ch chan<- prometheus.Metric) {
+	samples, err := c.backend.Samples()
+	if err != nil {
+		log.Printf("Error reading GPU state: %v", err)
+		ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 0)
+
+	now := time.Now()
+	for _, s := range samples {
+		gpu := []string{strconv.Itoa(s.Index), s.UUID, s.Name}
+		labels := append(gpu[:3:3], s.Vendor)
+		if c.pods != nil {
+			labels = append(labels, c.pods.labels(s.UUID)...)
+		}
+		if c.slurm != nil {
+			labels = append(labels, c.slurm.labels(gpu[0])...)
+		}
+		gauge(ch, utilizationDesc, s.UtilizationPercent, labels...)
+		gauge(ch, memoryUsedDesc, s.MemoryUsedBytes, labels...)
+		gauge(ch, memoryTotalDesc, s.MemoryTotalBytes, labels...)
+		gauge(ch, temperatureDesc, s.TemperatureCelsius, labels...)
+		gauge(ch, powerDrawDesc, s.PowerDrawWatts, labels...)
+		gauge(ch, powerLimitDesc, s.PowerLimitWatts, labels...)
+		counter(ch, energyDesc, s.EnergyJoules, labels...)
+		if s.PowerDrawWatts != nil && s.PowerLimitWatts != nil {
+			capped := c.powerCaps.observe(s.UUID, *s.PowerDrawWatts, *s.PowerLimitWatts, now)
+			seconds, alerting := capped.Seconds(), 0.0
+			if c.powerCaps.alerting(capped) {
+				alerting = 1
+			}
+			gauge(ch, powerCappedDesc, &seconds, labels...)
+			gauge(ch, powerCapAlertDesc, &alerting, labels...)
+		}
+		if s.UtilizationPercent != nil {
+			idle := c.idle.observe(s.UUID, *s.UtilizationPercent, allocated(s, labels[len(gpu)+1:]), now)
+			seconds, alerting := idle.Seconds(), 0.0
+			if c.idle.alerting(idle) {
+				alerting = 1
+			}
+			gauge(ch, idleAllocatedDesc, &seconds, labels...)
+			gauge(ch, idleAllocatedAlertDesc, &alerting, labels...)
+		}
+		// MIG devices' processes are not listed on the GPU, so its memory has no visible owner.
+		if c.leaks != nil && s.MemoryUsedBytes != nil && (s.MIGMode == nil || *s.MIGMode == 0) {
+			leakedBytes, leaked := c.leaks.observe(s.UUID, *s.MemoryUsedBytes, allocated(s, labels[len(gpu)+1:]), now)
+			alerting := 0.0
+			if c.leaks.alerting(leaked) {
+				alerting = 1
+			}
+			gauge(ch, memoryLeakedDesc, &leakedBytes, labels...)
+			gauge(ch, memoryLeakAlertDesc, &alerting, labels...)
+		}
+		gauge(ch, fanSpeedDesc, s.FanSpeedPercent, labels...)
+		counter(ch, eccErrorsDesc, s.ECCCorrectedErrors, append(labels, "corrected")...)
+		counter(ch, eccErrorsDesc, s.ECCUncorrectedErrors, append(labels, "uncorrected")...)
+		counter(ch, eccVolatileErrorsDesc, s.ECCVolatileCorrectedErrors, append(labels, "corrected")...)
+		counter(ch, eccVolatileErrorsDesc, s.ECCVolatileUncorrectedErrors, append(labels, "uncorrected")...)
+		gauge(ch, xidLastErrorDesc, s.XIDLastError, labels...)
+		counter(ch, nvlinkErrorsDesc, s.NVLinkCRCErrors, append(labels, "crc")...)
+		counter(ch, nvlinkErrorsDesc, s.NVLinkReplayErrors, append(labels, "replay")...)
+		counter(ch, nvlinkErrorsDesc, s.NVLinkRecoveryErrors, append(labels, "recovery")...)
+		counter(ch, nvlinkDataDesc, s.NVLinkTXBytes, append(labels, "tx")...)
+		counter(ch, nvlinkDataDesc, s.NVLinkRXBytes, append(labels, "rx")...)
+		gauge(ch, nvlinkThroughputDesc, s.NVLinkTXBytesPerSecond, append(labels, "tx")...)
+		gauge(ch, nvlinkThroughputDesc, s.NVLinkRXBytesPerSecond, append(labels, "rx")...)
+		counter(ch, pcieReplayErrorsDesc, s.PCIeReplayErrors, labels...)
+		gauge(ch, pcieThroughputDesc, s.PCIeTXBytesPerSecond, append(labels, "tx")...)
+		gauge(ch, pcieThroughputDesc, s.PCIeRXBytesPerSecond, append(labels, "rx")...)
+		if s.ThrottleReasons != nil {
+			for _, r := range throttleReasons {
+				if s.SupportedThrottleReasons&r.bit == 0 {
+					continue
+				}
+				active := 0.0
+				if *s.ThrottleReasons&r.bit != 0 {
+					active = 1
+				}
+				gauge(ch, throttleReasonDesc, &active, append(labels, r.name)...)
+			}
+		}
+		counter(ch, thermalViolationDesc, s.ThermalViolationSeconds, labels...)
+		gauge(ch, retiredPagesDesc, s.RetiredPagesSingleBit, append(labels, "single_bit_ecc")...)
+		gauge(ch, retiredPagesDesc, s.RetiredPagesDoubleBit, append(labels, "double_bit_ecc")...)
+		gauge(ch, retiredPagesPendingDesc, s.RetiredPagesPending, labels...)
+		gauge(ch, remappedRowsDesc, s.RemappedRowsCorrectable, append(labels, "correctable")...)
+		gauge(ch, remappedRowsDesc, s.RemappedRowsUncorrectable, append(labels, "uncorrectable")...)
+		gauge(ch, rowRemapPendingDesc, s.RowRemapPending, labels...)
+		gauge(ch, rowRemapFailureDesc, s.RowRemapFailure, labels...)
+		if s.TemperatureCelsius != nil {
+			if perMinute, ok := c.trends.observe(s.UUID, *s.TemperatureCelsius, now); ok {
+				rising := 0.0
+				if c.trends.rising(perMinute) {
+					rising = 1
+				}
+				gauge(ch, temperatureTrendDesc, &perMinute, labels...)
+				gauge(ch, thermalTrendAlertDesc, &rising, labels...)
+			}
+		}
+		if c.memoryProbe != nil {
+			if r, ok := c.memoryProbe.result(s.UUID); ok {
+				fragmentation := r.fragmentation()
+				gauge(ch, largestFreeBlockDesc, &r.largestBlockBytes, labels...)
+				gauge(ch, probedFreeMemoryDesc, &r.freeBytes, labels...)
+				gauge(ch, fragmentationDesc, &fragmentation, labels...)
+			}
+		}
+		collectMIG(ch, s, gpu, labels)
+		for _, p := range s.Processes {
+			gauge(ch, processMemoryDesc, p.MemoryUsedBytes, append(gpu, strconv.FormatUint(uint64(p.PID), 10), p.User, p.ContainerID, p.Command)...)
+		}
+	}
+}
+
+// gauge emits a gauge sample if the reading is available.
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, labels ...string) {
+	if value != nil {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, *value, labels...)
+	}
+}
+
+// counter emits a counter sample if the reading is available.
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, labels ...string) {
+	if value != nil {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, *value, labels...)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// migLabels identify a MIG device: its parent GPU, the profile of its GPU instance
+// (e.g. 1g.10gb) and the IDs of its GPU and compute instance.
+var migLabels = append(gpuLabels[:len(gpuLabels):len(gpuLabels)], "mig_profile", "gpu_instance", "compute_instance", "mig_uuid")
+
+var (
+	migModeDesc = prometheus.NewDesc("gpu_mig_mode",
+		"1 if MIG mode is enabled on the GPU, 0 otherwise. With MIG, the per-GPU utilization is not reported; see gpu_mig_*.", deviceLabels, nil)
+	migMemoryUsedDesc = prometheus.NewDesc("gpu_mig_memory_used_bytes",
+		"Framebuffer memory allocated on a MIG device.", migLabels, nil)
+	migMemoryTotalDesc = prometheus.NewDesc("gpu_mig_memory_total_bytes",
+		"Framebuffer memory of a MIG device.", migLabels, nil)
+	migUtilizationDesc = prometheus.NewDesc("gpu_mig_utilization_percent",
+		"Percent of time the graphics engine of a MIG device was active (DCGM backend, needs DCGM_FI_PROF_GR_ENGINE_ACTIVE).", migLabels, nil)
+)
+
+// migDevice is a snapshot of one MIG device, the slice of a GPU that a workload sees as
+// a GPU of its own. Readings are nil when the backend does not provide them.
+type migDevice struct {
+	UUID    string
+	Profile string
+	// GPUInstance and ComputeInstance are the instance IDs; the DCGM backend does not
+	// know the compute instance.
+	GPUInstance     string
+	ComputeInstance string
+
+	MemoryUsedBytes    *float64
+	MemoryTotalBytes   *float64
+	UtilizationPercent *float64
+}
+
+// sampleMIG reads the MIG mode of a device and, with MIG enabled, the memory of each of
+// its MIG devices. NVML has no utilization for MIG devices; that takes DCGM's profiling
+// metrics.
+func sampleMIG(device nvml.Device, sample *gpuSample) {
+	current, _, ret := device.GetMigMode()
+	if ret != nvml.SUCCESS {
+		// NOT_SUPPORTED on GPUs without MIG.
+		return
+	}
+	sample.MIGMode = float(boolValue(current == nvml.DEVICE_MIG_ENABLE))
+	if current != nvml.DEVICE_MIG_ENABLE {
+		return
+	}
+	count, ret := device.GetMaxMigDeviceCount()
+	if ret != nvml.SUCCESS {
+		return
+	}
+	for i := 0; i < count; i++ {
+		// Slots without a MIG device return NOT_FOUND.
+		mig, ret := device.GetMigDeviceHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			continue
+		}
+		d := migDevice{GPUInstance: instanceID(mig.GetGpuInstanceId()), ComputeInstance: instanceID(mig.GetComputeInstanceId())}
+		if uuid, ret := mig.GetUUID(); ret == nvml.SUCCESS {
+			d.UUID = uuid
+		}
+		name, _ := mig.GetName()
+		attributes, ret := mig.GetAttributes()
+		if ret != nvml.SUCCESS {
+			attributes = nvml.DeviceAttributes{}
+		}
+		d.Profile = migProfile(name, attributes)
+		if mem, ret := mig.GetMemoryInfo(); ret == nvml.SUCCESS {
+			d.MemoryUsedBytes = float(float64(mem.Used))
+			d.MemoryTotalBytes = float(float64(mem.Total))
+		}
+		sample.MIGDevices = append(sample.MIGDevices, d)
+	}
+}
+
+func instanceID(id int, ret nvml.Return) string {
+	if ret != nvml.SUCCESS {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// migProfile returns the profile of a MIG device as nvidia-smi names it, taken from
+// the device name ("NVIDIA A100-SXM4-40GB MIG 1g.5gb"), or else built from its slice
+// count and memory size. A compute instance smaller than its GPU instance is prefixed,
+// e.g. 1c.2g.10gb.
+func migProfile(name string, attributes nvml.DeviceAttributes) string {
+	if _, profile, ok := strings.Cut(name, " MIG "); ok {
+		return profile
+	}
+	if attributes.GpuInstanceSliceCount == 0 {
+		return ""
+	}
+	profile := fmt.Sprintf("%dg.%dgb", attributes.GpuInstanceSliceCount, (attributes.MemorySizeMB+1023)/1024)
+	if c := attributes.ComputeInstanceSliceCount; c != 0 && c != attributes.GpuInstanceSliceCount {
+		profile = fmt.Sprintf("%dc.%s", c, profile)
+	}
+	return profile
+}
+
+// migSlice returns the MIG device of the sample with the given GPU instance, adding it
+// if it is new. The pointer is only valid until the next call.
+func (s *gpuSample) migSlice(profile, gpuInstance string) *migDevice {
+	s.MIGMode = float(1)
+	for i := range s.MIGDevices {
+		if s.MIGDevices[i].GPUInstance == gpuInstance {
+			return &s.MIGDevices[i]
+		}
+	}
+	s.MIGDevices = append(s.MIGDevices, migDevice{Profile: profile, GPUInstance: gpuInstance})
+	return &s.MIGDevices[len(s.MIGDevices)-1]
+}
+
+// sortMIGDevices orders the MIG devices of a sample by GPU instance ID.
+func sortMIGDevices(devices []migDevice) {
+	sort.Slice(devices, func(i, j int) bool {
+		a, _ := strconv.Atoi(devices[i].GPUInstance)
+		b, _ := strconv.Atoi(devices[j].GPUInstance)
+		return a < b
+	})
+}
+
+// collectMIG emits the MIG mode of a GPU and the readings of its MIG devices.
+func collectMIG(ch chan<- prometheus.Metric, s gpuSample, gpu, labels []string) {
+	gauge(ch, migModeDesc, s.MIGMode, labels...)
+	for _, d := range s.MIGDevices {
+		mig := append(gpu[:len(gpu):len(gpu)], d.Profile, d.GPUInstance, d.ComputeInstance, d.UUID)
+		gauge(ch, migMemoryUsedDesc, d.MemoryUsedBytes, mig...)
+		gauge(ch, migMemoryTotalDesc, d.MemoryTotalBytes, mig...)
+		gauge(ch, migUtilizationDesc, d.UtilizationPercent, mig...)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+// nvmlBackend reads GPU state through the NVIDIA Management Library.
+// nvml.Init must have been called before Samples is used.
+type nvmlBackend struct{}
+
+func (nvmlBackend) Samples() ([]gpuSample, error) {
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("getting device count: %s", nvml.ErrorString(ret))
+	}
+
+	samples := make([]gpuSample, 0, count)
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("Error getting handle for GPU %d: %s", i, nvml.ErrorString(ret))
+			continue
+		}
+		samples = append(samples, sampleDevice(i, device))
+	}
+	return samples, nil
+}
+
+func (nvmlBackend) Inventory() (nodeInventory, error) {
+	var inventory nodeInventory
+	if version, ret := nvml.SystemGetDriverVersion(); ret == nvml.SUCCESS {
+		inventory.DriverVersion = version
+	}
+	// The CUDA version is encoded as 1000*major + 10*minor, e.g. 12040 for 12.4.
+	if version, ret := nvml.SystemGetCudaDriverVersion(); ret == nvml.SUCCESS {
+		inventory.CUDAVersion = fmt.Sprintf("%d.%d", version/1000, version%1000/10)
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return inventory, fmt.Errorf("getting device count: %s", nvml.ErrorString(ret))
+	}
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("Error getting handle for GPU %d: %s", i, nvml.ErrorString(ret))
+			continue
+		}
+		inventory.GPUs = append(inventory.GPUs, describeDevice(i, device))
+	}
+	return inventory, nil
+}
+
+// describeDevice reads the static properties of one device; unsupported ones stay empty.
+func describeDevice(index int, device nvml.Device) gpuInventory {
+	gpu := gpuInventory{Index: index}
+	if uuid, ret := device.GetUUID(); ret == nvml.SUCCESS {
+		gpu.UUID = uuid
+	}
+	if name, ret := device.GetName(); ret == nvml.SUCCESS {
+		gpu.Name = name
+	}
+	if serial, ret := device.GetSerial(); ret == nvml.SUCCESS {
+		gpu.Serial = serial
+	}
+	if vbios, ret := device.GetVbiosVersion(); ret == nvml.SUCCESS {
+		gpu.VBIOSVersion = vbios
+	}
+	if mem, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+		gpu.MemoryTotalBytes = mem.Total
+	}
+	if pci, ret := device.GetPciInfo(); ret == nvml.SUCCESS {
+		gpu.PCIBusID = cString(pci.BusId[:])
+	}
+	if gen, ret := device.GetCurrPcieLinkGeneration(); ret == nvml.SUCCESS {
+		gpu.PCIeGeneration = gen
+	}
+	if gen, ret := device.GetMaxPcieLinkGeneration(); ret == nvml.SUCCESS {
+		gpu.PCIeMaxGeneration = gen
+	}
+	if width, ret := device.GetCurrPcieLinkWidth(); ret == nvml.SUCCESS {
+		gpu.PCIeWidth = width
+	}
+	if width, ret := device.GetMaxPcieLinkWidth(); ret == nvml.SUCCESS {
+		gpu.PCIeMaxWidth = width
+	}
+	return gpu
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// cString converts a NUL terminated C char array to a string.
+func cString(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
+
+// sampleDevice collects every supported reading for one device. Readings that fail
+// (typically NOT_SUPPORTED, e.g. fan speed on passively cooled datacenter GPUs) are left nil.
+func sampleDevice(index int, device nvml.Device) gpuSample {
+	sample := gpuSample{Index: index, Vendor: "nvidia"}
+
+	if uuid, ret := device.GetUUID(); ret == nvml.SUCCESS {
+		sample.UUID = uuid
+	}
+	if name, ret := device.GetName(); ret == nvml.SUCCESS {
+		sample.Name = name
+	}
+	if util, ret := device.GetUtilizationRates(); ret == nvml.SUCCESS {
+		sample.UtilizationPercent = float(float64(util.Gpu))
+	}
+	if mem, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+		sample.MemoryUsedBytes = float(float64(mem.Used))
+		sample.MemoryTotalBytes = float(float64(mem.Total))
+	}
+	if temp, ret := device.GetTemperature(nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+		sample.TemperatureCelsius = float(float64(temp))
+	}
+	if milliwatts, ret := device.GetPowerUsage(); ret == nvml.SUCCESS {
+		sample.PowerDrawWatts = float(float64(milliwatts) / 1000)
+	}
+	if milliwatts, ret := device.GetEnforcedPowerLimit(); ret == nvml.SUCCESS {
+		sample.PowerLimitWatts = float(float64(milliwatts) / 1000)
+	}
+	if millijoules, ret := device.GetTotalEnergyConsumption(); ret == nvml.SUCCESS {
+		sample.EnergyJoules = float(float64(millijoules) / 1000)
+	}
+	if fan, ret := device.GetFanSpeed(); ret == nvml.SUCCESS {
+		sample.FanSpeedPercent = float(float64(fan))
+	}
+	if n, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_CORRECTED, nvml.AGGREGATE_ECC); ret == nvml.SUCCESS {
+		sample.ECCCorrectedErrors = float(float64(n))
+	}
+	if n, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.AGGREGATE_ECC); ret == nvml.SUCCESS {
+		sample.ECCUncorrectedErrors = float(float64(n))
+	}
+	if n, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_CORRECTED, nvml.VOLATILE_ECC); ret == nvml.SUCCESS {
+		sample.ECCVolatileCorrectedErrors = float(float64(n))
+	}
+	if n, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC); ret == nvml.SUCCESS {
+		sample.ECCVolatileUncorrectedErrors = float(float64(n))
+	}
+	if pages, ret := device.GetRetiredPages(nvml.PAGE_RETIREMENT_CAUSE_MULTIPLE_SINGLE_BIT_ECC_ERRORS); ret == nvml.SUCCESS {
+		sample.RetiredPagesSingleBit = float(float64(len(pages)))
+	}
+	if pages, ret := device.GetRetiredPages(nvml.PAGE_RETIREMENT_CAUSE_DOUBLE_BIT_ECC_ERROR); ret == nvml.SUCCESS {
+		sample.RetiredPagesDoubleBit = float(float64(len(pages)))
+	}
+	if pending, ret := device.GetRetiredPagesPendingStatus(); ret == nvml.SUCCESS {
+		sample.RetiredPagesPending = float(boolValue(pending == nvml.FEATURE_ENABLED))
+	}
+	if correctable, uncorrectable, pending, failed, ret := device.GetRemappedRows(); ret == nvml.SUCCESS {
+		sample.RemappedRowsCorrectable = float(float64(correctable))
+		sample.RemappedRowsUncorrectable = float(float64(uncorrectable))
+		sample.RowRemapPending = float(boolValue(pending))
+		sample.RowRemapFailure = float(boolValue(failed))
+	}
+	sampleInterconnect(device, &sample)
+	sampleThrottleReasons(device, &sample)
+	sampleMIG(device, &sample)
+	if procs, ret := device.GetComputeRunningProcesses(); ret == nvml.SUCCESS {
+		for _, info := range procs {
+			p := gpuProcess{PID: info.Pid}
+			// UsedGpuMemory is unavailable (max uint64) without sufficient privileges, e.g. on Windows WDDM.
+			if info.UsedGpuMemory != ^uint64(0) {
+				p.MemoryUsedBytes = float(float64(info.UsedGpuMemory))
+			}
+			describeProcess(&p)
+			sample.Processes = append(sample.Processes, p)
+		}
+	}
+	return sample
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// workloadLabels name the Kubernetes workload a GPU is allocated to. They follow
+// gpuLabels on the per-device metrics when POD_ATTRIBUTION=kubelet.
+var workloadLabels = []string{"pod", "namespace", "container"}
+
+// defaultPodResourcesSocket is where the kubelet serves its pod-resources API.
+const defaultPodResourcesSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+
+// workload is a container a GPU is allocated to.
+type workload struct {
+	pod, namespace, container string
+}
+
+// podAttribution maps GPUs to the pods using them through the kubelet pod-resources
+// gRPC API (v1 PodResourcesLister.List), which lists the devices the device plugin
+// allocated to every container. The collector runs as a DaemonSet with the socket's
+// directory mounted. The API is small enough that its messages are decoded by hand
+// rather than pulling in gRPC and the kubelet API module.
+type podAttribution struct {
+	socket string
+	client *http.Client
+
+	mu sync.Mutex
+	// workloads holds the containers by GPU UUID.
+	workloads map[string][]workload
+}
+
+func newPodAttribution(socket string) *podAttribution {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return &podAttribution{
+		socket: socket,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				Protocols: protocols,
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		workloads: make(map[string][]workload),
+	}
+}
+
+// run refreshes the allocations every interval. On errors the last known allocations
+// are kept, so a kubelet restart does not strip the labels.
+func (a *podAttribution) run(interval time.Duration) {
+	for {
+		workloads, err := a.list(context.Background())
+		if err != nil {
+			log.Printf("Error listing pod resources from %s: %v", a.socket, err)
+		} else {
+			a.mu.Lock()
+			a.workloads = workloads
+			a.mu.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// labels returns the workload label values of a GPU, empty if no pod uses it. GPUs
+// shared by several containers (time-slicing) list them all, comma separated.
+func (a *podAttribution) labels(uuid string) []string {
+	a.mu.Lock()
+	workloads := a.workloads[uuid]
+	a.mu.Unlock()
+
+	var pods, namespaces, containers []string
+	for _, w := range workloads {
+		pods = appendUnique(pods, w.pod)
+		namespaces = appendUnique(namespaces, w.namespace)
+		containers = appendUnique(containers, w.container)
+	}
+	return []string{strings.Join(pods, ","), strings.Join(namespaces, ","), strings.Join(containers, ",")}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// list calls PodResourcesLister.List and returns the containers by GPU UUID.
+func (a *podAttribution) list(ctx context.Context) (map[string][]workload, error) {
+	// An empty ListPodResourcesRequest in a gRPC frame: uncompressed, length 0.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/v1.PodResourcesLister/List", bytes.NewReader(make([]byte, 5)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	// Errors come as trailers, or as headers of a response without a body.
+	status, message := resp.Trailer.Get("grpc-status"), resp.Trailer.Get("grpc-message")
+	if status == "" {
+		status, message = resp.Header.Get("grpc-status"), resp.Header.Get("grpc-message")
+	}
+	if status != "0" {
+		return nil, fmt.Errorf("gRPC status %s: %s", status, message)
+	}
+	if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		return nil, fmt.Errorf("malformed gRPC response of %d bytes", len(body))
+	}
+	return decodePodResources(body[5:])
+}
+
+// decodePodResources decodes a ListPodResourcesResponse:
+//
+//	ListPodResourcesResponse { repeated PodResources pod_resources = 1; }
+//	PodResources             { string name = 1; string namespace = 2; repeated ContainerResources containers = 3; }
+//	ContainerResources       { string name = 1; repeated ContainerDevices devices = 2; ... }
+//	ContainerDevices         { string resource_name = 1; repeated string device_ids = 2; ... }
+//
+// Only devices of nvidia.com/ resources are kept. Time-sliced GPUs are advertised as
+// "<uuid>::<replica>", which is mapped back to the GPU.
+func decodePodResources(b []byte) (map[string][]workload, error) {
+	workloads := make(map[string][]workload)
+	err := decodeMessage(b, func(num protowire.Number, pod []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var name, namespace string
+		var containers [][]byte
+		if err := decodeMessage(pod, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1:
+				name = string(v)
+			case 2:
+				namespace = string(v)
+			case 3:
+				containers = append(containers, v)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, c := range containers {
+			var container string
+			var devices []string
+			if err := decodeMessage(c, func(num protowire.Number, v []byte) error {
+				switch num {
+				case 1:
+					container = string(v)
+				case 2:
+					var resource string
+					var ids []string
+					if err := decodeMessage(v, func(num protowire.Number, v []byte) error {
+						switch num {
+						case 1:
+							resource = string(v)
+						case 2:
+							ids = append(ids, string(v))
+						}
+						return nil
+					}); err != nil {
+						return err
+					}
+					if strings.HasPrefix(resource, "nvidia.com/") {
+						devices = append(devices, ids...)
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, id := range devices {
+				uuid, _, _ := strings.Cut(id, "::")
+				workloads[uuid] = append(workloads[uuid], workload{pod: name, namespace: namespace, container: container})
+			}
+		}
+		return nil
+	})
+	for _, w := range workloads {
+		sort.Slice(w, func(i, j int) bool {
+			if w[i].namespace != w[j].namespace {
+				return w[i].namespace < w[j].namespace
+			}
+			if w[i].pod != w[j].pod {
+				return w[i].pod < w[j].pod
+			}
+			return w[i].container < w[j].container
+		})
+	}
+	return workloads, err
+}
+
+// decodeMessage calls field for every length-delimited field of a protobuf message and
+// skips the others.
+func decodeMessage(b []byte, field func(num protowire.Number, value []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := field(num, value); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
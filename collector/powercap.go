@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// powerCapMargin is how close to its enforced power limit a GPU's draw must be for the
+// GPU to count as capped; the draw hovers just below the limit while the driver throttles.
+const powerCapMargin = 0.97
+
+// powerCaps tracks how long each GPU has been drawing its enforced power limit. A GPU
+// pinned at its cap for a long time is being throttled, which often means a thermal
+// problem or a power supply that lowered the limit.
+type powerCaps struct {
+	// duration is how long a GPU must stay capped to be flagged.
+	duration time.Duration
+
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newPowerCaps(duration time.Duration) *powerCaps {
+	return &powerCaps{duration: duration, since: make(map[string]time.Time)}
+}
+
+// observe records the GPU's draw and limit and returns how long it has been capped, or 0
+// if it is not capped now.
+func (p *powerCaps) observe(uuid string, drawWatts, limitWatts float64, now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if limitWatts <= 0 || drawWatts < limitWatts*powerCapMargin {
+		delete(p.since, uuid)
+		return 0
+	}
+	since, ok := p.since[uuid]
+	if !ok {
+		since = now
+		p.since[uuid] = now
+	}
+	return now.Sub(since)
+}
+
+// alerting reports whether a GPU has been capped for longer than the alert duration.
+func (p *powerCaps) alerting(capped time.Duration) bool {
+	return capped > 0 && capped >= p.duration
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+)
+
+// gpuProcess is a compute process holding memory on a GPU.
+type gpuProcess struct {
+	PID             uint32
+	MemoryUsedBytes *float64
+	// User is the owner's user name, or the numeric UID if it cannot be resolved.
+	User string
+	// ContainerID is the short ID of the container the process runs in, "" on the host.
+	ContainerID string
+	Command     string
+}
+
+// containerIDPattern matches the 64 hex digit container ID in cgroup paths written by
+// Docker, containerd and CRI-O, e.g. ".../docker-<id>.scope" or ".../cri-containerd-<id>".
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// describeProcess fills in the owner, container and command of a process from /proc.
+// The collector needs the host PID namespace (pid: host) to see the processes NVML
+// reports; fields that cannot be read are left empty.
+func describeProcess(p *gpuProcess) {
+	dir := fmt.Sprintf("/proc/%d", p.PID)
+
+	if comm, err := os.ReadFile(dir + "/comm"); err == nil {
+		p.Command = strings.TrimSpace(string(comm))
+	}
+	if uid := processUID(dir + "/status"); uid != "" {
+		p.User = uid
+		if u, err := user.LookupId(uid); err == nil {
+			p.User = u.Username
+		}
+	}
+	if cgroup, err := os.ReadFile(dir + "/cgroup"); err == nil {
+		if id := containerIDPattern.FindString(string(cgroup)); id != "" {
+			p.ContainerID = id[:12]
+		}
+	}
+}
+
+// processUID returns the real UID from a /proc/<pid>/status file.
+func processUID(statusPath string) string {
+	f, err := os.Open(statusPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == "Uid:" {
+			return fields[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var remoteWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "gpu_collector_remote_write_errors_total",
+	Help: "Remote-write requests that failed; their samples are not retried, the next push sends the current ones.",
+})
+
+// remoteWriter pushes the collector's metrics to a Prometheus remote-write endpoint
+// (Prometheus with --web.enable-remote-write-receiver, Mimir, Thanos Receive,
+// VictoriaMetrics, ...), for nodes Prometheus cannot scrape. Every push gathers the
+// registry like a scrape would and labels the series with the job and instance the
+// scrape would have added.
+type remoteWriter struct {
+	url      string
+	gatherer prometheus.Gatherer
+	// targetLabels are added to every series.
+	targetLabels map[string]string
+	// authorize sets the credentials of a request, if any are configured.
+	authorize func(req *http.Request)
+	client    *http.Client
+}
+
+func newRemoteWriter(url string, gatherer prometheus.Gatherer, job, instance string, authorize func(req *http.Request)) *remoteWriter {
+	return &remoteWriter{
+		url:          url,
+		gatherer:     gatherer,
+		targetLabels: map[string]string{"job": job, "instance": instance},
+		authorize:    authorize,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// run pushes every interval. Failed pushes are logged and counted; since the samples
+// are gauges and counters, the next push makes up for a lost one.
+func (w *remoteWriter) run(interval time.Duration) {
+	for {
+		if err := w.push(context.Background()); err != nil {
+			remoteWriteErrors.Inc()
+			log.Printf("Error pushing metrics to %s: %v", w.url, err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (w *remoteWriter) push(ctx context.Context) error {
+	families, err := w.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(families, w.targetLabels, time.Now()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "gpu-collector")
+	if w.authorize != nil {
+		w.authorize(req)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes the families as a remote-write 1.0 WriteRequest protobuf:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+//
+// Only gauges, counters and untyped metrics are encoded, which is all the collector
+// exports.
+func encodeWriteRequest(families []*dto.MetricFamily, targetLabels map[string]string, now time.Time) []byte {
+	var buf []byte
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch {
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			case m.GetUntyped() != nil:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			timestamp := now.UnixMilli()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+
+			labels := map[string]string{"__name__": family.GetName()}
+			for name, value := range targetLabels {
+				labels[name] = value
+			}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, encodeTimeSeries(labels, value, timestamp))
+		}
+	}
+	return buf
+}
+
+// encodeTimeSeries encodes one series with a single sample. Remote-write receivers
+// require the labels sorted by name.
+func encodeTimeSeries(labels map[string]string, value float64, timestamp int64) []byte {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var series []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+		series = protowire.AppendTag(series, 1, protowire.BytesType)
+		series = protowire.AppendBytes(series, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp))
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	return protowire.AppendBytes(series, sample)
+}
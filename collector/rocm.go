@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// amdVendorID is the PCI vendor ID of AMD GPUs.
+const amdVendorID = "0x1002"
+
+// rocmBackend reads AMD GPUs (Instinct MI series) from the amdgpu driver's sysfs files
+// under drm (normally /sys/class/drm), the same files rocm-smi reads, so neither ROCm nor
+// an exporter has to be installed on the node. Readings the GPU or kernel does not
+// expose are left nil, like NOT_SUPPORTED readings with NVML.
+type rocmBackend struct {
+	drm string
+}
+
+func newROCmBackend(drm string) *rocmBackend {
+	return &rocmBackend{drm: drm}
+}
+
+// cardPattern matches the DRM card directories, not their connectors (card0-DP-1).
+var cardPattern = regexp.MustCompile(`^card(\d+)$`)
+
+// amdCard is an AMD GPU's DRM card: the device directory holds the amdgpu files, hwmon
+// its hardware monitor (empty if the driver registered none).
+type amdCard struct {
+	index  int
+	device string
+	hwmon  string
+}
+
+// cards lists the AMD GPUs ordered by card number, which is the order the driver probed
+// them in; their position is the GPU index.
+func (b *rocmBackend) cards() ([]amdCard, error) {
+	entries, err := os.ReadDir(b.drm)
+	if err != nil {
+		return nil, err
+	}
+	type card struct {
+		number int
+		device string
+	}
+	var found []card
+	for _, e := range entries {
+		m := cardPattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		device := filepath.Join(b.drm, e.Name(), "device")
+		if readSysfsString(filepath.Join(device, "vendor")) != amdVendorID {
+			continue
+		}
+		number, _ := strconv.Atoi(m[1])
+		found = append(found, card{number: number, device: device})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].number < found[j].number })
+
+	cards := make([]amdCard, 0, len(found))
+	for i, c := range found {
+		hwmons, _ := filepath.Glob(filepath.Join(c.device, "hwmon", "hwmon*"))
+		card := amdCard{index: i, device: c.device}
+		if len(hwmons) > 0 {
+			card.hwmon = hwmons[0]
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func (b *rocmBackend) Samples() ([]gpuSample, error) {
+	cards, err := b.cards()
+	if err != nil {
+		return nil, fmt.Errorf("listing amdgpu devices: %w", err)
+	}
+	samples := make([]gpuSample, 0, len(cards))
+	for _, c := range cards {
+		samples = append(samples, c.sample())
+	}
+	return samples, nil
+}
+
+// sample collects every reading amdgpu exposes for the card. hwmon reports millidegrees,
+// microwatts and microjoules.
+func (c amdCard) sample() gpuSample {
+	s := gpuSample{Index: c.index, Vendor: "amd", UUID: c.uuid(), Name: c.name(), PCIBusID: c.busID()}
+
+	if v, ok := readSysfsNumber(filepath.Join(c.device, "gpu_busy_percent")); ok {
+		s.UtilizationPercent = float(v)
+	}
+	if v, ok := readSysfsNumber(filepath.Join(c.device, "mem_info_vram_used")); ok {
+		s.MemoryUsedBytes = float(v)
+	}
+	if v, ok := readSysfsNumber(filepath.Join(c.device, "mem_info_vram_total")); ok {
+		s.MemoryTotalBytes = float(v)
+	}
+	if c.hwmon != "" {
+		if v, ok := c.temperature(); ok {
+			s.TemperatureCelsius = float(v / 1000)
+		}
+		// Newer kernels report the instantaneous power of MI300 as power1_input only.
+		if v, ok := readSysfsNumber(filepath.Join(c.hwmon, "power1_average")); ok {
+			s.PowerDrawWatts = float(v / 1e6)
+		} else if v, ok := readSysfsNumber(filepath.Join(c.hwmon, "power1_input")); ok {
+			s.PowerDrawWatts = float(v / 1e6)
+		}
+		if v, ok := readSysfsNumber(filepath.Join(c.hwmon, "power1_cap")); ok {
+			s.PowerLimitWatts = float(v / 1e6)
+		}
+		if v, ok := readSysfsNumber(filepath.Join(c.hwmon, "energy1_input")); ok {
+			s.EnergyJoules = float(v / 1e6)
+		}
+		// Instinct boards are passively cooled and have no fan; pwm1 ranges up to 255.
+		if v, ok := readSysfsNumber(filepath.Join(c.hwmon, "pwm1")); ok {
+			s.FanSpeedPercent = float(v / 255 * 100)
+		}
+	}
+	// The RAS counters count the errors since the driver was loaded.
+	if ce, ue, ok := rasErrorCounts(filepath.Join(c.device, "ras", "umc_err_count")); ok {
+		s.ECCVolatileCorrectedErrors = float(ce)
+		s.ECCVolatileUncorrectedErrors = float(ue)
+	}
+	if retired, pending, ok := badPages(filepath.Join(c.device, "ras", "gpu_vram_bad_pages")); ok {
+		s.RetiredPagesDoubleBit = float(retired)
+		s.RetiredPagesPending = float(boolValue(pending > 0))
+	}
+	if v, ok := readSysfsNumber(filepath.Join(c.device, "pcie_replay_count")); ok {
+		s.PCIeReplayErrors = float(v)
+	}
+	return s
+}
+
+// temperature reads the junction (hotspot) temperature, which is what the MI series
+// throttles on and the closest match to NVML's core temperature, falling back to the
+// edge sensor on GPUs without one.
+func (c amdCard) temperature() (float64, bool) {
+	inputs := make(map[string]string)
+	paths, _ := filepath.Glob(filepath.Join(c.hwmon, "temp*_label"))
+	for _, path := range paths {
+		inputs[readSysfsString(path)] = strings.TrimSuffix(path, "_label") + "_input"
+	}
+	for _, label := range []string{"junction", "edge"} {
+		if path, ok := inputs[label]; ok {
+			if v, ok := readSysfsNumber(path); ok {
+				return v, true
+			}
+		}
+	}
+	return readSysfsNumber(filepath.Join(c.hwmon, "temp1_input"))
+}
+
+// uuid is the GPU's unique ID (the one rocm-smi shows), or its PCI bus ID on GPUs that
+// do not report one, so the GPU can still be told apart across scrapes.
+func (c amdCard) uuid() string {
+	if id := readSysfsString(filepath.Join(c.device, "unique_id")); id != "" {
+		return id
+	}
+	return c.busID()
+}
+
+// name is the product name from the board's FRU, or the PCI device ID without one.
+func (c amdCard) name() string {
+	if name := readSysfsString(filepath.Join(c.device, "product_name")); name != "" {
+		return name
+	}
+	return "AMD GPU " + readSysfsString(filepath.Join(c.device, "device"))
+}
+
+// busID is the PCI address the device directory links to, e.g. 0000:c1:00.0.
+func (c amdCard) busID() string {
+	path, err := filepath.EvalSymlinks(c.device)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// rasErrorCounts parses a RAS error count file ("ue: 0" and "ce: 3" lines).
+func rasErrorCounts(path string) (corrected, uncorrected float64, ok bool) {
+	content := readSysfsString(path)
+	if content == "" {
+		return 0, 0, false
+	}
+	var seen int
+	for _, line := range strings.Split(content, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "ce":
+			corrected = n
+			seen++
+		case "ue":
+			uncorrected = n
+			seen++
+		}
+	}
+	return corrected, uncorrected, seen == 2
+}
+
+// badPages counts the VRAM pages the driver retired after uncorrectable errors, and the
+// ones whose retirement is still pending. Each line of gpu_vram_bad_pages is
+// "pfn : size : flag", the flag being R (reserved), P (pending) or F (failed to reserve).
+func badPages(path string) (retired, pending float64, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) != 3 {
+			continue
+		}
+		switch strings.TrimSpace(fields[2]) {
+		case "R":
+			retired++
+		case "P":
+			pending++
+		}
+	}
+	return retired, pending, scanner.Err() == nil
+}
+
+// Inventory reads the board identity from sysfs. The driver version is only known for
+// amdgpu builds that set one (the DKMS module from ROCm), not for the in-tree driver.
+func (b *rocmBackend) Inventory() (nodeInventory, error) {
+	cards, err := b.cards()
+	if err != nil {
+		return nodeInventory{}, fmt.Errorf("listing amdgpu devices: %w", err)
+	}
+	inventory := nodeInventory{DriverVersion: readSysfsString("/sys/module/amdgpu/version")}
+	for _, c := range cards {
+		gpu := gpuInventory{
+			Index:        c.index,
+			UUID:         c.uuid(),
+			Name:         c.name(),
+			Serial:       readSysfsString(filepath.Join(c.device, "serial_number")),
+			VBIOSVersion: readSysfsString(filepath.Join(c.device, "vbios_version")),
+			PCIBusID:     c.busID(),
+		}
+		if v, ok := readSysfsNumber(filepath.Join(c.device, "mem_info_vram_total")); ok {
+			gpu.MemoryTotalBytes = uint64(v)
+		}
+		gpu.PCIeGeneration = pcieGeneration(readSysfsString(filepath.Join(c.device, "current_link_speed")))
+		gpu.PCIeMaxGeneration = pcieGeneration(readSysfsString(filepath.Join(c.device, "max_link_speed")))
+		gpu.PCIeWidth, _ = strconv.Atoi(readSysfsString(filepath.Join(c.device, "current_link_width")))
+		gpu.PCIeMaxWidth, _ = strconv.Atoi(readSysfsString(filepath.Join(c.device, "max_link_width")))
+		inventory.GPUs = append(inventory.GPUs, gpu)
+	}
+	return inventory, nil
+}
+
+// pcieGenerations maps the transfer rates of PCI sysfs link speeds ("16.0 GT/s PCIe")
+// to PCIe generations.
+var pcieGenerations = map[string]int{"2.5": 1, "5.0": 2, "8.0": 3, "16.0": 4, "32.0": 5, "64.0": 6}
+
+func pcieGeneration(speed string) int {
+	rate, _, _ := strings.Cut(speed, " ")
+	return pcieGenerations[rate]
+}
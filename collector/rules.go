@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/yaml.v3"
+)
+
+// thresholdRule is one rule of RULES_FILE: the alert fires for every series of Metric
+// (optionally only those with the given Labels) whose value has compared true against
+// Threshold for at least For, e.g.
+//
+//	rules:
+//	  - alert: GpuTemperatureHigh
+//	    metric: gpu_temperature_celsius
+//	    operator: ">"
+//	    threshold: 85
+//	    for: 5m
+//	    severity: critical
+//	    summary: GPU is running hot
+type thresholdRule struct {
+	Alert     string            `yaml:"alert"`
+	Metric    string            `yaml:"metric"`
+	Labels    map[string]string `yaml:"labels"`
+	Operator  string            `yaml:"operator"`
+	Threshold float64           `yaml:"threshold"`
+	For       time.Duration     `yaml:"for"`
+	Severity  string            `yaml:"severity"`
+	Summary   string            `yaml:"summary"`
+}
+
+// ruleOperators are the comparisons a rule may use.
+var ruleOperators = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// loadThresholdRules reads and checks RULES_FILE. Unknown keys are rejected so that a
+// typo does not silently disable a rule.
+func loadThresholdRules(path string) ([]thresholdRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []thresholdRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for i, rule := range file.Rules {
+		if rule.Alert == "" || rule.Metric == "" {
+			return nil, fmt.Errorf("rule %d: alert and metric are required", i+1)
+		}
+		if _, ok := ruleOperators[rule.Operator]; !ok {
+			return nil, fmt.Errorf("rule %s: unsupported operator %q (expected >, >=, <, <=, == or !=)", rule.Alert, rule.Operator)
+		}
+		if rule.For < 0 {
+			return nil, fmt.Errorf("rule %s: for must not be negative", rule.Alert)
+		}
+		if rule.Severity == "" {
+			file.Rules[i].Severity = "warning"
+		}
+	}
+	return file.Rules, nil
+}
+
+// alertSink receives the alerts of the rules engine.
+type alertSink interface {
+	// send is given the alerts that changed state since the last evaluation and all
+	// alerts that are firing now.
+	send(changed, firing []webhookAlert) error
+}
+
+// send posts the changes; the adapter remembers firing alerts itself.
+func (c *adapterClient) send(changed, firing []webhookAlert) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	return c.post(changed)
+}
+
+// alertmanagerClient posts alerts to the Alertmanager API. Alertmanager resolves alerts
+// that are not re-sent within its resolve_timeout, so every evaluation re-sends all
+// firing alerts.
+type alertmanagerClient struct {
+	url    string
+	client *http.Client
+}
+
+func newAlertmanagerClient(url string) *alertmanagerClient {
+	return &alertmanagerClient{url: strings.TrimRight(url, "/"), client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// postableAlert is an alert of POST /api/v2/alerts.
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+}
+
+func (c *alertmanagerClient) send(changed, firing []webhookAlert) error {
+	var alerts []postableAlert
+	for _, alert := range firing {
+		alerts = append(alerts, postableAlert{Labels: alert.Labels, Annotations: alert.Annotations, StartsAt: alert.StartsAt})
+	}
+	for _, alert := range changed {
+		if alert.Status == "resolved" {
+			alerts = append(alerts, postableAlert{Labels: alert.Labels, Annotations: alert.Annotations, StartsAt: alert.StartsAt, EndsAt: alert.EndsAt})
+		}
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.url+"/api/v2/alerts", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting to Alertmanager: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting to Alertmanager: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// rulesEngine evaluates threshold rules against the collector's own metrics, so small
+// labs get GPU alerts without running Prometheus.
+type rulesEngine struct {
+	gatherer prometheus.Gatherer
+	rules    []thresholdRule
+	sink     alertSink
+	instance string
+
+	// pending holds since when each series has matched its rule, by alert key.
+	pending map[string]time.Time
+	// firing holds the active alerts by alert key.
+	firing map[string]webhookAlert
+}
+
+func newRulesEngine(gatherer prometheus.Gatherer, rules []thresholdRule, sink alertSink, instance string) *rulesEngine {
+	return &rulesEngine{
+		gatherer: gatherer,
+		rules:    rules,
+		sink:     sink,
+		instance: instance,
+		pending:  make(map[string]time.Time),
+		firing:   make(map[string]webhookAlert),
+	}
+}
+
+// run evaluates the rules right away and then every interval.
+func (e *rulesEngine) run(interval time.Duration) {
+	for {
+		if err := e.evaluate(time.Now()); err != nil {
+			log.Printf("Error evaluating alerting rules: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// evaluate sends newly firing and resolved alerts. If sending fails, the firing state is
+// kept unchanged so the same changes are sent again on the next evaluation.
+func (e *rulesEngine) evaluate(now time.Time) error {
+	families, err := e.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	timestamp := now.UTC().Format(time.RFC3339)
+	pending := make(map[string]time.Time, len(e.pending))
+	next := make(map[string]webhookAlert, len(e.firing))
+	var changed []webhookAlert
+	for _, rule := range e.rules {
+		compare := ruleOperators[rule.Operator]
+		for _, m := range byName[rule.Metric].GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			value, ok := seriesValue(m)
+			if !ok || !matchesLabels(labels, rule.Labels) || !compare(value, rule.Threshold) {
+				continue
+			}
+
+			key := rule.Alert + seriesKey(labels)
+			since, ok := e.pending[key]
+			if !ok {
+				since = now
+			}
+			pending[key] = since
+			if now.Sub(since) < rule.For {
+				continue
+			}
+			if alert, ok := e.firing[key]; ok {
+				next[key] = alert
+				continue
+			}
+			alert := e.newAlert(rule, labels, value, timestamp)
+			next[key] = alert
+			changed = append(changed, alert)
+		}
+	}
+	for key, alert := range e.firing {
+		if _, ok := next[key]; !ok {
+			alert.Status = "resolved"
+			alert.EndsAt = timestamp
+			changed = append(changed, alert)
+		}
+	}
+
+	firing := make([]webhookAlert, 0, len(next))
+	for _, alert := range next {
+		firing = append(firing, alert)
+	}
+	e.pending = pending
+	if err := e.sink.send(changed, firing); err != nil {
+		return err
+	}
+	for _, alert := range changed {
+		log.Printf("Sent rule alert %s (%s): %s", alert.Labels["alertname"], alert.Status, alert.Annotations["summary"])
+	}
+	e.firing = next
+	return nil
+}
+
+// newAlert labels the alert with the series' labels and the collector's common labels.
+// The GPU labels are also set under the names the adapter shows on its cards.
+func (e *rulesEngine) newAlert(rule thresholdRule, labels map[string]string, value float64, startsAt string) webhookAlert {
+	alert := webhookAlert{
+		Status:      "firing",
+		Labels:      map[string]string{},
+		Annotations: map[string]string{},
+		StartsAt:    startsAt,
+		EndsAt:      "0001-01-01T00:00:00Z",
+	}
+	for name, v := range labels {
+		if v != "" {
+			alert.Labels[name] = v
+		}
+	}
+	if uuid := labels["uuid"]; uuid != "" {
+		alert.Labels["UUID"] = uuid
+	}
+	if model := labels["name"]; model != "" {
+		alert.Labels["modelName"] = model
+	}
+	alert.Labels["alertname"] = rule.Alert
+	alert.Labels["severity"] = rule.Severity
+	alert.Labels["instance"] = e.instance
+	alert.Labels["job"] = "gpu_collector"
+
+	subject := e.instance
+	if gpu := labels["gpu"]; gpu != "" {
+		subject = fmt.Sprintf("GPU %s on %s", gpu, e.instance)
+	}
+	condition := fmt.Sprintf("%s %s %s (current value: %s)", rule.Metric, rule.Operator,
+		strconv.FormatFloat(rule.Threshold, 'g', -1, 64), strconv.FormatFloat(value, 'g', 4, 64))
+	if rule.Summary != "" {
+		alert.Annotations["summary"] = fmt.Sprintf("%s: %s", subject, rule.Summary)
+		alert.Annotations["description"] = condition
+	} else {
+		alert.Annotations["summary"] = fmt.Sprintf("%s: %s", subject, condition)
+	}
+	return alert
+}
+
+// seriesValue returns the value of a gauge, counter or untyped series.
+func seriesValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue(), true
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue(), true
+	case m.GetUntyped() != nil:
+		return m.GetUntyped().GetValue(), true
+	}
+	return 0, false
+}
+
+func matchesLabels(labels, want map[string]string) bool {
+	for name, value := range want {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// seriesKey formats labels like Prometheus does, e.g. {gpu="0",name="A100"}.
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
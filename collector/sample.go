@@ -0,0 +1,87 @@
+package main
+
+// gpuSample is a vendor-neutral snapshot of a single GPU taken during one scrape.
+// Optional readings are nil when the device or driver does not support them.
+type gpuSample struct {
+	Index int
+	UUID  string
+	Name  string
+	// Vendor is "nvidia", "amd" or "intel", for nodes of a mixed cluster to export the same metrics.
+	Vendor string
+	// DriverVersion is only set by the DCGM backend, from the exporter's labels, and
+	// PCIBusID by every backend but NVML.
+	DriverVersion string
+	PCIBusID      string
+
+	UtilizationPercent *float64
+	MemoryUsedBytes    *float64
+	MemoryTotalBytes   *float64
+	TemperatureCelsius *float64
+	PowerDrawWatts     *float64
+	FanSpeedPercent    *float64
+
+	// PowerLimitWatts is the power limit the driver currently enforces, which may be below
+	// the default limit. EnergyJoules is the energy used since the driver was last loaded.
+	PowerLimitWatts *float64
+	EnergyJoules    *float64
+
+	// Aggregate ECC counts cover the GPU's lifetime, volatile ones the time since the
+	// last driver load.
+	ECCCorrectedErrors           *float64
+	ECCUncorrectedErrors         *float64
+	ECCVolatileCorrectedErrors   *float64
+	ECCVolatileUncorrectedErrors *float64
+
+	// Memory repair state. Page retirement is used up to Volta, row remapping from Ampere on.
+	RetiredPagesSingleBit     *float64
+	RetiredPagesDoubleBit     *float64
+	RetiredPagesPending       *float64
+	RemappedRowsCorrectable   *float64
+	RemappedRowsUncorrectable *float64
+	RowRemapPending           *float64
+	RowRemapFailure           *float64
+
+	// ThrottleReasons is the bitmask of the reasons the clocks are held below their
+	// maximum (see throttleReasons); only the bits in SupportedThrottleReasons are
+	// meaningful. The DCGM backend does not know the supported reasons and reports all.
+	ThrottleReasons          *uint64
+	SupportedThrottleReasons uint64
+
+	// Health readings, currently only provided by the DCGM backend.
+	XIDLastError            *float64
+	ThermalViolationSeconds *float64
+
+	// Interconnect readings. NVLink counters are summed over the GPU's active links.
+	// NVLinkTXBytes and NVLinkRXBytes (data moved since the driver loaded) come from
+	// NVML, the NVLink throughput rates from DCGM's profiling metrics.
+	NVLinkCRCErrors        *float64
+	NVLinkReplayErrors     *float64
+	NVLinkRecoveryErrors   *float64
+	NVLinkTXBytes          *float64
+	NVLinkRXBytes          *float64
+	NVLinkTXBytesPerSecond *float64
+	NVLinkRXBytesPerSecond *float64
+	PCIeReplayErrors       *float64
+	PCIeTXBytesPerSecond   *float64
+	PCIeRXBytesPerSecond   *float64
+
+	// MIGMode is 1 with MIG enabled; MIGDevices are then the GPU's MIG devices, whose
+	// readings replace the per-GPU utilization the driver stops reporting.
+	MIGMode    *float64
+	MIGDevices []migDevice
+
+	// Processes lists the compute processes on the GPU, currently only provided by the
+	// NVML backend.
+	Processes []gpuProcess
+}
+
+// gpuBackend reads the current state of every GPU on the node.
+type gpuBackend interface {
+	Samples() ([]gpuSample, error)
+	// Inventory describes the node's GPUs and driver for /api/inventory.
+	Inventory() (nodeInventory, error)
+}
+
+func float(v float64) *float64 {
+	return &v
+}
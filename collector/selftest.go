@@ -0,0 +1,561 @@
+package main
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stddef.h>
+#include <stdint.h>
+#include <time.h>
+
+// The self-test loads the CUDA driver API like the fragmentation probe, plus cuBLAS
+// for the GEMM test when it is installed. CUresult and cublasStatus_t 0 mean success.
+typedef int (*stInit_t)(unsigned int);
+typedef int (*stDeviceGetCount_t)(int *);
+typedef int (*stDeviceGet_t)(int *, int);
+typedef int (*stDeviceGetUuid_t)(char *, int);
+typedef int (*stPrimaryCtxRetain_t)(void **, int);
+typedef int (*stPrimaryCtxRelease_t)(int);
+typedef int (*stCtxSetCurrent_t)(void *);
+typedef int (*stCtxSynchronize_t)(void);
+typedef int (*stMemAlloc_t)(unsigned long long *, size_t);
+typedef int (*stMemFree_t)(unsigned long long);
+typedef int (*stMemAllocHost_t)(void **, size_t);
+typedef int (*stMemFreeHost_t)(void *);
+typedef int (*stMemcpyHtoD_t)(unsigned long long, const void *, size_t);
+typedef int (*stMemcpyDtoH_t)(void *, unsigned long long, size_t);
+typedef int (*stMemcpyDtoD_t)(unsigned long long, unsigned long long, size_t);
+typedef int (*stMemsetD32_t)(unsigned long long, unsigned int, size_t);
+typedef int (*cublasCreate_t)(void **);
+typedef int (*cublasDestroy_t)(void *);
+typedef int (*cublasSgemm_t)(void *, int, int, int, int, int, const float *, const float *, int,
+	const float *, int, const float *, float *, int);
+
+static struct {
+	stInit_t init;
+	stDeviceGetCount_t deviceGetCount;
+	stDeviceGet_t deviceGet;
+	stDeviceGetUuid_t deviceGetUuid;
+	stPrimaryCtxRetain_t primaryCtxRetain;
+	stPrimaryCtxRelease_t primaryCtxRelease;
+	stCtxSetCurrent_t ctxSetCurrent;
+	stCtxSynchronize_t ctxSynchronize;
+	stMemAlloc_t memAlloc;
+	stMemFree_t memFree;
+	stMemAllocHost_t memAllocHost;
+	stMemFreeHost_t memFreeHost;
+	stMemcpyHtoD_t memcpyHtoD;
+	stMemcpyDtoH_t memcpyDtoH;
+	stMemcpyDtoD_t memcpyDtoD;
+	stMemsetD32_t memsetD32;
+	cublasCreate_t blasCreate;
+	cublasDestroy_t blasDestroy;
+	cublasSgemm_t sgemm;
+} st;
+
+// selftest_load opens libcuda and, if present, libcublas. It returns -1 when libcuda or
+// one of its symbols is missing, else cuInit's result; has_blas tells if GEMM can run.
+static int selftest_load(int *has_blas) {
+	void *lib = dlopen("libcuda.so.1", RTLD_NOW | RTLD_GLOBAL);
+	if (lib == NULL) {
+		return -1;
+	}
+	st.init = (stInit_t)dlsym(lib, "cuInit");
+	st.deviceGetCount = (stDeviceGetCount_t)dlsym(lib, "cuDeviceGetCount");
+	st.deviceGet = (stDeviceGet_t)dlsym(lib, "cuDeviceGet");
+	st.deviceGetUuid = (stDeviceGetUuid_t)dlsym(lib, "cuDeviceGetUuid");
+	st.primaryCtxRetain = (stPrimaryCtxRetain_t)dlsym(lib, "cuDevicePrimaryCtxRetain");
+	st.primaryCtxRelease = (stPrimaryCtxRelease_t)dlsym(lib, "cuDevicePrimaryCtxRelease_v2");
+	st.ctxSetCurrent = (stCtxSetCurrent_t)dlsym(lib, "cuCtxSetCurrent");
+	st.ctxSynchronize = (stCtxSynchronize_t)dlsym(lib, "cuCtxSynchronize");
+	st.memAlloc = (stMemAlloc_t)dlsym(lib, "cuMemAlloc_v2");
+	st.memFree = (stMemFree_t)dlsym(lib, "cuMemFree_v2");
+	st.memAllocHost = (stMemAllocHost_t)dlsym(lib, "cuMemAllocHost_v2");
+	st.memFreeHost = (stMemFreeHost_t)dlsym(lib, "cuMemFreeHost");
+	st.memcpyHtoD = (stMemcpyHtoD_t)dlsym(lib, "cuMemcpyHtoD_v2");
+	st.memcpyDtoH = (stMemcpyDtoH_t)dlsym(lib, "cuMemcpyDtoH_v2");
+	st.memcpyDtoD = (stMemcpyDtoD_t)dlsym(lib, "cuMemcpyDtoD_v2");
+	st.memsetD32 = (stMemsetD32_t)dlsym(lib, "cuMemsetD32_v2");
+	if (!st.init || !st.deviceGetCount || !st.deviceGet || !st.deviceGetUuid || !st.primaryCtxRetain ||
+		!st.primaryCtxRelease || !st.ctxSetCurrent || !st.ctxSynchronize || !st.memAlloc || !st.memFree ||
+		!st.memAllocHost || !st.memFreeHost || !st.memcpyHtoD || !st.memcpyDtoH || !st.memcpyDtoD || !st.memsetD32) {
+		return -1;
+	}
+
+	*has_blas = 0;
+	const char *blas_names[] = {"libcublas.so.12", "libcublas.so.11", "libcublas.so"};
+	for (int i = 0; i < 3; i++) {
+		void *blas = dlopen(blas_names[i], RTLD_NOW | RTLD_GLOBAL);
+		if (blas == NULL) {
+			continue;
+		}
+		st.blasCreate = (cublasCreate_t)dlsym(blas, "cublasCreate_v2");
+		st.blasDestroy = (cublasDestroy_t)dlsym(blas, "cublasDestroy_v2");
+		st.sgemm = (cublasSgemm_t)dlsym(blas, "cublasSgemm_v2");
+		*has_blas = st.blasCreate && st.blasDestroy && st.sgemm;
+		break;
+	}
+	return st.init(0);
+}
+
+static int selftest_device_count(int *count) {
+	return st.deviceGetCount(count);
+}
+
+static int selftest_device_uuid(int ordinal, char uuid[16]) {
+	int dev, ret;
+	if ((ret = st.deviceGet(&dev, ordinal)) != 0) return ret;
+	return st.deviceGetUuid(uuid, dev);
+}
+
+static double seconds_since(struct timespec *start) {
+	struct timespec now;
+	clock_gettime(CLOCK_MONOTONIC, &now);
+	return (now.tv_sec - start->tv_sec) + (now.tv_nsec - start->tv_nsec) / 1e9;
+}
+
+// selftest_run measures the copy bandwidths between pinned host memory and the device
+// and within the device over iterations copies of bytes, then the throughput of
+// iterations n x n SGEMMs if use_blas is set. Results are in bytes and FLOP per second;
+// device to device counts every byte read and written. Everything runs in one call so
+// the context stays on one thread.
+static int selftest_run(int ordinal, size_t bytes, int iterations, int n, int use_blas,
+	double *h2d, double *d2h, double *d2d, double *flops) {
+	int dev, ret;
+	void *ctx, *host = NULL, *blas = NULL;
+	unsigned long long src = 0, dst = 0, a = 0, b = 0, c = 0;
+	struct timespec start;
+
+	if ((ret = st.deviceGet(&dev, ordinal)) != 0) return ret;
+	if ((ret = st.primaryCtxRetain(&ctx, dev)) != 0) return ret;
+	if ((ret = st.ctxSetCurrent(ctx)) != 0) goto release;
+	if ((ret = st.memAllocHost(&host, bytes)) != 0) goto release;
+	if ((ret = st.memAlloc(&src, bytes)) != 0) goto release;
+	if ((ret = st.memAlloc(&dst, bytes)) != 0) goto release;
+
+	// One untimed copy each way warms up the DMA engines.
+	if ((ret = st.memcpyHtoD(src, host, bytes)) != 0) goto release;
+	clock_gettime(CLOCK_MONOTONIC, &start);
+	for (int i = 0; i < iterations; i++) {
+		if ((ret = st.memcpyHtoD(src, host, bytes)) != 0) goto release;
+	}
+	if ((ret = st.ctxSynchronize()) != 0) goto release;
+	*h2d = (double)bytes * iterations / seconds_since(&start);
+
+	if ((ret = st.memcpyDtoH(host, src, bytes)) != 0) goto release;
+	clock_gettime(CLOCK_MONOTONIC, &start);
+	for (int i = 0; i < iterations; i++) {
+		if ((ret = st.memcpyDtoH(host, src, bytes)) != 0) goto release;
+	}
+	if ((ret = st.ctxSynchronize()) != 0) goto release;
+	*d2h = (double)bytes * iterations / seconds_since(&start);
+
+	if ((ret = st.memcpyDtoD(dst, src, bytes)) != 0) goto release;
+	if ((ret = st.ctxSynchronize()) != 0) goto release;
+	clock_gettime(CLOCK_MONOTONIC, &start);
+	for (int i = 0; i < iterations; i++) {
+		if ((ret = st.memcpyDtoD(dst, src, bytes)) != 0) goto release;
+	}
+	if ((ret = st.ctxSynchronize()) != 0) goto release;
+	*d2d = 2.0 * bytes * iterations / seconds_since(&start);
+
+	*flops = 0;
+	if (use_blas) {
+		size_t matrix = (size_t)n * n * sizeof(float);
+		const float alpha = 1.0f, beta = 0.0f;
+		if ((ret = st.memAlloc(&a, matrix)) != 0) goto release;
+		if ((ret = st.memAlloc(&b, matrix)) != 0) goto release;
+		if ((ret = st.memAlloc(&c, matrix)) != 0) goto release;
+		// 0x3f800000 is 1.0f, so the products stay finite.
+		if ((ret = st.memsetD32(a, 0x3f800000, (size_t)n * n)) != 0) goto release;
+		if ((ret = st.memsetD32(b, 0x3f800000, (size_t)n * n)) != 0) goto release;
+		if ((ret = st.blasCreate(&blas)) != 0) goto release;
+		#define SGEMM() st.sgemm(blas, 0, 0, n, n, n, &alpha, (const float *)(uintptr_t)a, n, \
+			(const float *)(uintptr_t)b, n, &beta, (float *)(uintptr_t)c, n)
+		if ((ret = SGEMM()) != 0) goto release;
+		if ((ret = st.ctxSynchronize()) != 0) goto release;
+		clock_gettime(CLOCK_MONOTONIC, &start);
+		for (int i = 0; i < iterations; i++) {
+			if ((ret = SGEMM()) != 0) goto release;
+		}
+		if ((ret = st.ctxSynchronize()) != 0) goto release;
+		*flops = 2.0 * n * n * n * iterations / seconds_since(&start);
+		#undef SGEMM
+	}
+
+release:
+	if (blas) st.blasDestroy(blas);
+	if (c) st.memFree(c);
+	if (b) st.memFree(b);
+	if (a) st.memFree(a);
+	if (dst) st.memFree(dst);
+	if (src) st.memFree(src);
+	if (host) st.memFreeHost(host);
+	st.ctxSetCurrent(NULL);
+	st.primaryCtxRelease(dev);
+	return ret;
+}
+*/
+import "C"
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sizes of the self-test: 256 MiB copies and 4096 x 4096 SGEMMs, 10 of each, which
+// takes a few seconds on a data center GPU.
+const (
+	selfTestCopyBytes  = 256 << 20
+	selfTestIterations = 10
+	selfTestMatrixSize = 4096
+)
+
+// selfTestIdlePercent is the utilization above which a GPU is considered busy.
+const selfTestIdlePercent = 5
+
+var (
+	selfTestResultDesc = prometheus.NewDesc("gpu_selftest_result",
+		"Result of the last self-test: bytes per second for the copy tests, FLOP per second for gemm.", append(gpuLabels, "test"), nil)
+	selfTestBaselineRatioDesc = prometheus.NewDesc("gpu_selftest_baseline_ratio",
+		"Result of the last self-test relative to the GPU's baseline.", append(gpuLabels, "test"), nil)
+	selfTestDegradedDesc = prometheus.NewDesc("gpu_selftest_degraded",
+		"1 if the last self-test found a result more than the allowed drop below the baseline.", gpuLabels, nil)
+	selfTestTimestampDesc = prometheus.NewDesc("gpu_selftest_last_run_timestamp_seconds",
+		"Unix time of the last self-test.", gpuLabels, nil)
+)
+
+// selfTestResult is one run on one GPU. Results are bytes per second, except GEMM in
+// FLOP per second (0 when cuBLAS is not installed).
+type selfTestResult struct {
+	At             time.Time          `json:"at"`
+	HostToDevice   float64            `json:"hostToDeviceBytesPerSecond"`
+	DeviceToHost   float64            `json:"deviceToHostBytesPerSecond"`
+	DeviceToDevice float64            `json:"deviceToDeviceBytesPerSecond"`
+	GEMM           float64            `json:"gemmFlopsPerSecond,omitempty"`
+	DriverVersion  string             `json:"driverVersion,omitempty"`
+	Degraded       bool               `json:"degraded"`
+	DropPercent    map[string]float64 `json:"dropPercent,omitempty"`
+}
+
+// tests returns the results by test name.
+func (r selfTestResult) tests() map[string]float64 {
+	tests := map[string]float64{"host_to_device": r.HostToDevice, "device_to_host": r.DeviceToHost, "device_to_device": r.DeviceToDevice}
+	if r.GEMM > 0 {
+		tests["gemm"] = r.GEMM
+	}
+	return tests
+}
+
+// selfTestRecord is what the results file keeps per GPU UUID.
+type selfTestRecord struct {
+	Index    int             `json:"index"`
+	Name     string          `json:"name"`
+	Baseline *selfTestResult `json:"baseline"`
+	Last     *selfTestResult `json:"last"`
+}
+
+// selfTester runs the GPU self-test of POST /api/selftest/{gpu}: copy bandwidths and an
+// SGEMM on an idle GPU, compared with the GPU's baseline, which is its first run (or
+// the run requested with ?baseline=true, e.g. after replacing the GPU). Results are
+// kept in a JSON file so the baseline survives restarts. A run more than maxDrop
+// percent below the baseline in any test raises GpuSelfTestDegraded through the adapter,
+// and the next passing run resolves it.
+type selfTester struct {
+	backend gpuBackend
+	path    string
+	maxDrop float64
+	token   string
+	hasBLAS bool
+	// adapter is nil unless ADAPTER_URL is set.
+	adapter *adapterClient
+
+	// running serializes the runs; a second request gets 409 Conflict.
+	running sync.Mutex
+	mu      sync.Mutex
+	records map[string]*selfTestRecord
+}
+
+// newSelfTester loads the CUDA driver library and the stored results. It fails when
+// libcuda is missing, e.g. in a container without the "compute" driver capability.
+func newSelfTester(backend gpuBackend, path string, maxDrop float64, token string, adapter *adapterClient) (*selfTester, error) {
+	var hasBLAS C.int
+	if ret := C.selftest_load(&hasBLAS); ret != 0 {
+		if ret == -1 {
+			return nil, fmt.Errorf("libcuda.so.1 not found or incomplete")
+		}
+		return nil, fmt.Errorf("cuInit failed with CUDA error %d", int(ret))
+	}
+	t := &selfTester{backend: backend, path: path, maxDrop: maxDrop, token: token, hasBLAS: hasBLAS != 0, adapter: adapter, records: make(map[string]*selfTestRecord)}
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(content, &t.records); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	return t, nil
+}
+
+var (
+	errGPUBusy     = errors.New("GPU is not idle")
+	errGPUNotFound = errors.New("no such GPU on this node")
+)
+
+// run tests the GPU with the given index and stores the result.
+func (t *selfTester) run(index int, baseline bool) (selfTestRecord, error) {
+	samples, err := t.backend.Samples()
+	if err != nil {
+		return selfTestRecord{}, err
+	}
+	var sample *gpuSample
+	for i := range samples {
+		if samples[i].Index == index {
+			sample = &samples[i]
+		}
+	}
+	if sample == nil {
+		return selfTestRecord{}, errGPUNotFound
+	}
+	if (sample.UtilizationPercent != nil && *sample.UtilizationPercent > selfTestIdlePercent) || len(sample.Processes) > 0 {
+		return selfTestRecord{}, errGPUBusy
+	}
+
+	ordinal, err := cudaOrdinal(sample.UUID)
+	if err != nil {
+		return selfTestRecord{}, err
+	}
+	var h2d, d2h, d2d, flops C.double
+	useBLAS := C.int(0)
+	if t.hasBLAS {
+		useBLAS = 1
+	}
+	if ret := C.selftest_run(C.int(ordinal), selfTestCopyBytes, selfTestIterations, selfTestMatrixSize, useBLAS, &h2d, &d2h, &d2d, &flops); ret != 0 {
+		return selfTestRecord{}, fmt.Errorf("self-test failed with CUDA error %d", int(ret))
+	}
+	result := &selfTestResult{
+		At:             time.Now().UTC(),
+		HostToDevice:   float64(h2d),
+		DeviceToHost:   float64(d2h),
+		DeviceToDevice: float64(d2d),
+		GEMM:           float64(flops),
+		DriverVersion:  sample.DriverVersion,
+	}
+
+	t.mu.Lock()
+	record := t.records[sample.UUID]
+	if record == nil {
+		record = &selfTestRecord{}
+		t.records[sample.UUID] = record
+	}
+	previous := record.Last
+	record.Index, record.Name = sample.Index, sample.Name
+	if record.Baseline == nil || baseline {
+		record.Baseline = result
+	} else {
+		t.compare(result, record.Baseline)
+	}
+	record.Last = result
+	snapshot := *record
+	err = t.save()
+	t.mu.Unlock()
+	if err != nil {
+		log.Printf("Error storing self-test results in %s: %v", t.path, err)
+	}
+
+	log.Printf("Self-test of GPU %d (%s): host to device %.1f GB/s, device to host %.1f GB/s, device to device %.1f GB/s, GEMM %.1f TFLOPS, degraded %t",
+		index, sample.UUID, result.HostToDevice/1e9, result.DeviceToHost/1e9, result.DeviceToDevice/1e9, result.GEMM/1e12, result.Degraded)
+	if t.adapter != nil && (result.Degraded || (previous != nil && previous.Degraded)) {
+		t.alert(snapshot, previous, sample)
+	}
+	return snapshot, nil
+}
+
+// compare sets the drops of the result's tests below the baseline and whether any
+// exceeds the allowed drop.
+func (t *selfTester) compare(result, baseline *selfTestResult) {
+	tests, base := result.tests(), baseline.tests()
+	for test, value := range tests {
+		if base[test] <= 0 {
+			continue
+		}
+		drop := (base[test] - value) / base[test] * 100
+		if drop <= 0 {
+			continue
+		}
+		if result.DropPercent == nil {
+			result.DropPercent = make(map[string]float64)
+		}
+		result.DropPercent[test] = drop
+		if drop > t.maxDrop {
+			result.Degraded = true
+		}
+	}
+}
+
+// alert posts GpuSelfTestDegraded, firing for a degraded run or resolved by a passing
+// run after a degraded previous one.
+func (t *selfTester) alert(record selfTestRecord, previous *selfTestResult, sample *gpuSample) {
+	gpu := strconv.Itoa(sample.Index)
+	at := record.Last.At.Format(time.RFC3339)
+	if !record.Last.Degraded {
+		alert := t.adapter.newAlert("GpuSelfTestDegraded", "warning", gpu, sample.UUID, sample.Name, previous.At.Format(time.RFC3339))
+		alert.Status = "resolved"
+		alert.EndsAt = at
+		alert.Annotations["summary"] = fmt.Sprintf("GPU %s on %s: self-test results are back within %.0f%% of the baseline",
+			gpu, t.adapter.instance, t.maxDrop)
+		t.post(alert)
+		return
+	}
+
+	var drops []string
+	for _, test := range []string{"host_to_device", "device_to_host", "device_to_device", "gemm"} {
+		if drop := record.Last.DropPercent[test]; drop > t.maxDrop {
+			drops = append(drops, fmt.Sprintf("%s -%.0f%%", strings.ReplaceAll(test, "_", " "), drop))
+		}
+	}
+	alert := t.adapter.newAlert("GpuSelfTestDegraded", "warning", gpu, sample.UUID, sample.Name, at)
+	alert.Annotations["summary"] = fmt.Sprintf("GPU %s on %s: self-test results dropped below the baseline of %s (%s)",
+		gpu, t.adapter.instance, record.Baseline.At.Format("2006-01-02"), strings.Join(drops, ", "))
+	t.post(alert)
+}
+
+func (t *selfTester) post(alert webhookAlert) {
+	if err := t.adapter.post([]webhookAlert{alert}); err != nil {
+		log.Printf("Error posting self-test alert for GPU %s: %v", alert.Labels["gpu"], err)
+	}
+}
+
+// save writes the results file through a temporary file. t.mu must be held.
+func (t *selfTester) save() error {
+	content, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// cudaOrdinal returns the CUDA device ordinal of the GPU with the given NVML UUID; the
+// CUDA and NVML orders differ unless CUDA_DEVICE_ORDER=PCI_BUS_ID.
+func cudaOrdinal(uuid string) (int, error) {
+	var count C.int
+	if ret := C.selftest_device_count(&count); ret != 0 {
+		return 0, fmt.Errorf("counting CUDA devices: CUDA error %d", int(ret))
+	}
+	for i := 0; i < int(count); i++ {
+		var id [16]C.char
+		if ret := C.selftest_device_uuid(C.int(i), &id[0]); ret != 0 {
+			return 0, fmt.Errorf("reading the UUID of CUDA device %d: CUDA error %d", i, int(ret))
+		}
+		if cudaUUID(C.GoBytes(unsafe.Pointer(&id[0]), 16)) == uuid {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("GPU %s is not visible to CUDA", uuid)
+}
+
+// handle serves POST /api/selftest/{gpu}[?baseline=true] with the stored record of the
+// GPU, and GET /api/selftest with the records of every GPU.
+func (t *selfTester) handle(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+t.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	gpu := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/selftest"), "/")
+	switch {
+	case r.Method == http.MethodGet && gpu == "":
+		t.mu.Lock()
+		content, err := json.Marshal(t.records)
+		t.mu.Unlock()
+		if err != nil {
+			http.Error(w, "Error encoding self-test results", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(content)
+		return
+	case r.Method != http.MethodPost || gpu == "":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	index, err := strconv.Atoi(gpu)
+	if err != nil || index < 0 {
+		http.Error(w, "Invalid GPU index", http.StatusBadRequest)
+		return
+	}
+	if !t.running.TryLock() {
+		http.Error(w, "A self-test is already running", http.StatusConflict)
+		return
+	}
+	defer t.running.Unlock()
+
+	record, err := t.run(index, r.URL.Query().Get("baseline") == "true")
+	switch {
+	case errors.Is(err, errGPUNotFound):
+		http.Error(w, fmt.Sprintf("No GPU %d on this node", index), http.StatusNotFound)
+		return
+	case errors.Is(err, errGPUBusy):
+		http.Error(w, fmt.Sprintf("GPU %d is not idle", index), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error running the self-test of GPU %d: %v", index, err)
+		http.Error(w, "Self-test failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+func (t *selfTester) Describe(ch chan<- *prometheus.Desc) {
+	ch <- selfTestResultDesc
+	ch <- selfTestBaselineRatioDesc
+	ch <- selfTestDegradedDesc
+	ch <- selfTestTimestampDesc
+}
+
+func (t *selfTester) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for uuid, record := range t.records {
+		if record.Last == nil {
+			continue
+		}
+		labels := []string{strconv.Itoa(record.Index), uuid, record.Name}
+		base := record.Baseline.tests()
+		for test, value := range record.Last.tests() {
+			ch <- prometheus.MustNewConstMetric(selfTestResultDesc, prometheus.GaugeValue, value, append(labels, test)...)
+			if base[test] > 0 {
+				ch <- prometheus.MustNewConstMetric(selfTestBaselineRatioDesc, prometheus.GaugeValue, value/base[test], append(labels, test)...)
+			}
+		}
+		degraded := 0.0
+		if record.Last.Degraded {
+			degraded = 1
+		}
+		ch <- prometheus.MustNewConstMetric(selfTestDegradedDesc, prometheus.GaugeValue, degraded, labels...)
+		ch <- prometheus.MustNewConstMetric(selfTestTimestampDesc, prometheus.GaugeValue, float64(record.Last.At.Unix()), labels...)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slurmLabels name the SLURM job a GPU is allocated to and the job's owner. They follow
+// gpuLabels (and workloadLabels) on the per-device metrics when SLURM_ATTRIBUTION=scontrol.
+var slurmLabels = []string{"slurm_job_id", "user"}
+
+// slurmJob is a job a GPU is allocated to.
+type slurmJob struct {
+	id, user string
+}
+
+// slurmAttribution maps GPU indices to the SLURM jobs using them, so HPC admins can
+// contact the job owner of a failing GPU directly. The jobs running on the node are
+// listed by the local slurmd (scontrol listpids) and their GPUs read from the detailed
+// job records (scontrol -d show job), whose per-node GRES lists the device indices.
+type slurmAttribution struct {
+	scontrol string
+	node     string
+
+	mu sync.Mutex
+	// jobs holds the jobs by GPU index.
+	jobs map[string][]slurmJob
+}
+
+func newSlurmAttribution(scontrol, node string) *slurmAttribution {
+	return &slurmAttribution{scontrol: scontrol, node: node, jobs: make(map[string][]slurmJob)}
+}
+
+// run refreshes the allocations every interval. On errors the last known allocations
+// are kept, so a slurmctld failover does not strip the labels.
+func (a *slurmAttribution) run(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		jobs, err := a.list(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Error listing SLURM jobs on %s: %v", a.node, err)
+		} else {
+			a.mu.Lock()
+			a.jobs = jobs
+			a.mu.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// labels returns the slurmLabels values of a GPU, empty if no job uses it. GPUs shared
+// by several jobs (sharding) list them all, comma separated.
+func (a *slurmAttribution) labels(gpu string) []string {
+	a.mu.Lock()
+	jobs := a.jobs[gpu]
+	a.mu.Unlock()
+
+	var ids, users []string
+	for _, j := range jobs {
+		ids = appendUnique(ids, j.id)
+		users = appendUnique(users, j.user)
+	}
+	return []string{strings.Join(ids, ","), strings.Join(users, ",")}
+}
+
+// list returns the jobs running on the node by GPU index.
+func (a *slurmAttribution) list(ctx context.Context) (map[string][]slurmJob, error) {
+	out, err := exec.CommandContext(ctx, a.scontrol, "listpids").Output()
+	if err != nil {
+		return nil, fmt.Errorf("scontrol listpids: %w", err)
+	}
+	jobs := make(map[string][]slurmJob)
+	for _, id := range parseListPIDs(out) {
+		out, err := exec.CommandContext(ctx, a.scontrol, "--details", "--oneliner", "show", "job", id).Output()
+		if err != nil {
+			// The job may have ended since it was listed.
+			log.Printf("Error reading SLURM job %s: %v", id, err)
+			continue
+		}
+		job, gpus := parseJobRecord(out, a.node)
+		for _, gpu := range gpus {
+			jobs[gpu] = append(jobs[gpu], job)
+		}
+	}
+	for _, j := range jobs {
+		sort.Slice(j, func(i, k int) bool { return j[i].id < j[k].id })
+	}
+	return jobs, nil
+}
+
+// parseListPIDs returns the job IDs of "scontrol listpids", one process per line after
+// the header:
+//
+//	PID      JOBID    STEPID   LOCALID GLOBALID
+//	1234     4242     0        0       0
+func parseListPIDs(out []byte) []string {
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		ids = appendUnique(ids, fields[1])
+	}
+	return ids
+}
+
+// parseJobRecord returns the job and the GPU indices it is allocated on node from the
+// one-line detailed output of "scontrol -d -o show job", e.g.
+//
+//	JobId=4242 JobName=train UserId=alice(1001) ... Nodes=gpu[01-02] CPU_IDs=0-15 Mem=64000 GRES=gpu:a100:2(IDX:0-1) ...
+//
+// Every "Nodes=" starts the allocation of a group of nodes; older releases print the
+// indices as GRES_IDX=gpu(IDX:0-1) instead.
+func parseJobRecord(out []byte, node string) (slurmJob, []string) {
+	var job slurmJob
+	var gpus []string
+	onNode := false
+	for _, field := range strings.Fields(string(out)) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "JobId":
+			job.id = value
+		case "UserId":
+			job.user, _, _ = strings.Cut(value, "(")
+		case "Nodes":
+			onNode = false
+			for _, name := range expandHostlist(value) {
+				if name == node {
+					onNode = true
+				}
+			}
+		case "GRES", "GRES_IDX":
+			if !onNode {
+				continue
+			}
+			for _, gres := range splitOutsideBrackets(value, ',', '(', ')') {
+				name, rest, _ := strings.Cut(gres, "(IDX:")
+				if !strings.HasPrefix(name, "gpu") || rest == "" {
+					continue
+				}
+				for _, gpu := range expandRanges(strings.TrimSuffix(rest, ")")) {
+					gpus = appendUnique(gpus, gpu)
+				}
+			}
+		}
+	}
+	return job, gpus
+}
+
+// expandHostlist expands a SLURM host list such as "gpu[01-03,07],login1".
+func expandHostlist(list string) []string {
+	var hosts []string
+	for _, part := range splitOutsideBrackets(list, ',', '[', ']') {
+		prefix, rest, ok := strings.Cut(part, "[")
+		if !ok {
+			hosts = append(hosts, part)
+			continue
+		}
+		ranges, suffix, _ := strings.Cut(rest, "]")
+		for _, n := range expandRanges(ranges) {
+			hosts = append(hosts, prefix+n+suffix)
+		}
+	}
+	return hosts
+}
+
+// expandRanges expands "0-2,5" to 0, 1, 2 and 5, keeping the zero padding of the bounds.
+func expandRanges(ranges string) []string {
+	var values []string
+	for _, r := range strings.Split(ranges, ",") {
+		lo, hi, ok := strings.Cut(r, "-")
+		if !ok {
+			if r != "" {
+				values = append(values, r)
+			}
+			continue
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for i := from; i <= to; i++ {
+			values = append(values, fmt.Sprintf("%0*d", len(lo), i))
+		}
+	}
+	return values
+}
+
+// splitOutsideBrackets splits s at sep, except between open and close.
+func splitOutsideBrackets(s string, sep, open, close byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	filesystemSizeDesc = prometheus.NewDesc("storage_filesystem_size_bytes",
+		"Size of a monitored filesystem.", []string{"mountpoint"}, nil)
+	filesystemAvailDesc = prometheus.NewDesc("storage_filesystem_avail_bytes",
+		"Space of a monitored filesystem available to unprivileged users.", []string{"mountpoint"}, nil)
+	filesystemFilesDesc = prometheus.NewDesc("storage_filesystem_files",
+		"Inodes of a monitored filesystem.", []string{"mountpoint"}, nil)
+	filesystemFilesFreeDesc = prometheus.NewDesc("storage_filesystem_files_free",
+		"Free inodes of a monitored filesystem.", []string{"mountpoint"}, nil)
+	nvmeCriticalWarningDesc = prometheus.NewDesc("storage_nvme_critical_warning",
+		"Critical warning bits of an NVMe controller's SMART log (0 if none).", []string{"device"}, nil)
+	nvmeTemperatureDesc = prometheus.NewDesc("storage_nvme_temperature_celsius",
+		"Composite temperature of an NVMe controller.", []string{"device"}, nil)
+	nvmePercentageUsedDesc = prometheus.NewDesc("storage_nvme_percentage_used",
+		"Vendor estimate of the share of the NVMe drive's endurance used, in percent; may exceed 100.", []string{"device"}, nil)
+	nvmeAvailableSpareDesc = prometheus.NewDesc("storage_nvme_available_spare_percent",
+		"Spare capacity left on the NVMe drive, in percent.", []string{"device"}, nil)
+	nvmeMediaErrorsDesc = prometheus.NewDesc("storage_nvme_media_errors_total",
+		"Unrecovered data integrity errors over the NVMe drive's lifetime.", []string{"device"}, nil)
+	mdraidDisksDesc = prometheus.NewDesc("storage_mdraid_disks",
+		"Member disks of a Linux software RAID array.", []string{"array"}, nil)
+	mdraidDisksActiveDesc = prometheus.NewDesc("storage_mdraid_disks_active",
+		"Member disks of a Linux software RAID array that are in sync.", []string{"array"}, nil)
+	mdraidActiveDesc = prometheus.NewDesc("storage_mdraid_active",
+		"1 if the Linux software RAID array is active, 0 if it is inactive.", []string{"array"}, nil)
+	mdraidSyncProgressDesc = prometheus.NewDesc("storage_mdraid_sync_progress_ratio",
+		"Progress of a running resync, recovery, reshape or check of a software RAID array.", []string{"array", "action"}, nil)
+	storageReadErrorDesc = prometheus.NewDesc("storage_read_error",
+		"1 if the last attempt to read the filesystems, NVMe SMART logs or RAID arrays failed, 0 otherwise.", nil, nil)
+)
+
+// filesystemUsage is the usage of one monitored mount point.
+type filesystemUsage struct {
+	mountpoint                              string
+	sizeBytes, availBytes, files, filesFree float64
+}
+
+// nvmeHealth is the part of an NVMe controller's SMART log that predicts failures.
+type nvmeHealth struct {
+	device                                              string
+	criticalWarning, temperatureCelsius, percentageUsed float64
+	availableSpare, mediaErrors                         float64
+}
+
+// mdArray is one Linux software RAID array of /proc/mdstat. syncAction is empty unless
+// a resync, recovery, reshape or check runs.
+type mdArray struct {
+	name               string
+	active             bool
+	disks, activeDisks int
+	syncAction         string
+	syncProgress       float64
+}
+
+// storageReading is everything read in one pass. A source that failed keeps its
+// previous readings.
+type storageReading struct {
+	filesystems []filesystemUsage
+	nvme        []nvmeHealth
+	arrays      []mdArray
+}
+
+// storageMonitor watches the node's filesystems, NVMe drives and software RAID arrays,
+// since training jobs die from a full scratch disk or a failing drive as often as from a
+// failing GPU. Readings are exported as metrics, and when ADAPTER_URL is set built-in
+// threshold alerts are posted through the adapter: NodeFilesystemAlmostFull,
+// NvmeCriticalWarning, NvmeMediaErrors, NvmeWearOut and MdraidDegraded.
+type storageMonitor struct {
+	filesystems []string
+	// mdstat is the path of /proc/mdstat; a missing file means no arrays.
+	mdstat string
+	// nvmeCLI is the nvme-cli binary, empty to skip the SMART logs.
+	nvmeCLI string
+	// fullPercent is the space or inode usage at which a filesystem is almost full, and
+	// wearPercent the percentage used at which an NVMe drive is worn out.
+	fullPercent, wearPercent float64
+	// adapter is nil unless ADAPTER_URL is set.
+	adapter *adapterClient
+
+	mu      sync.Mutex
+	reading storageReading
+	err     error
+	// firing holds the active alerts by alert name and subject.
+	firing map[string]webhookAlert
+}
+
+func newStorageMonitor(filesystems []string, mdstat, nvmeCLI string, fullPercent, wearPercent float64, adapter *adapterClient) *storageMonitor {
+	return &storageMonitor{
+		filesystems: filesystems,
+		mdstat:      mdstat,
+		nvmeCLI:     nvmeCLI,
+		fullPercent: fullPercent,
+		wearPercent: wearPercent,
+		adapter:     adapter,
+		firing:      make(map[string]webhookAlert),
+	}
+}
+
+// run reads the storage state right away and then every interval, each read bounded by
+// the interval.
+func (m *storageMonitor) run(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		m.check(ctx)
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// check reads every source and posts the alerts that changed state.
+func (m *storageMonitor) check(ctx context.Context) {
+	m.mu.Lock()
+	reading := m.reading
+	m.mu.Unlock()
+
+	// failed lists the alerts whose source could not be read; they keep their state.
+	failed := make(map[string]bool)
+	var errs []error
+	if filesystems, err := readFilesystems(m.filesystems); err != nil {
+		errs = append(errs, err)
+		failed["NodeFilesystemAlmostFull"] = true
+	} else {
+		reading.filesystems = filesystems
+	}
+	if m.nvmeCLI != "" {
+		if nvme, err := readNVMeHealth(ctx, m.nvmeCLI); err != nil {
+			errs = append(errs, err)
+			failed["NvmeCriticalWarning"], failed["NvmeMediaErrors"], failed["NvmeWearOut"] = true, true, true
+		} else {
+			reading.nvme = nvme
+		}
+	}
+	if arrays, err := readMdstat(m.mdstat); err != nil {
+		errs = append(errs, err)
+		failed["MdraidDegraded"] = true
+	} else {
+		reading.arrays = arrays
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		log.Printf("Error reading storage state: %v", err)
+	}
+
+	m.mu.Lock()
+	m.reading, m.err = reading, err
+	m.mu.Unlock()
+
+	if m.adapter != nil {
+		m.alert(reading, failed)
+	}
+}
+
+// storageAlert is a built-in alert condition that currently holds for subject.
+type storageAlert struct {
+	name, severity string
+	// label and subject are the label naming what the alert is about and its value.
+	label, subject string
+	summary        string
+}
+
+// conditions returns the built-in alerts that hold for the reading.
+func (m *storageMonitor) conditions(reading storageReading) []storageAlert {
+	var alerts []storageAlert
+	for _, fs := range reading.filesystems {
+		var full []string
+		if fs.sizeBytes > 0 && 100*(1-fs.availBytes/fs.sizeBytes) >= m.fullPercent {
+			full = append(full, fmt.Sprintf("%.0f%% of its space", 100*(1-fs.availBytes/fs.sizeBytes)))
+		}
+		if fs.files > 0 && 100*(1-fs.filesFree/fs.files) >= m.fullPercent {
+			full = append(full, fmt.Sprintf("%.0f%% of its inodes", 100*(1-fs.filesFree/fs.files)))
+		}
+		if len(full) > 0 {
+			alerts = append(alerts, storageAlert{"NodeFilesystemAlmostFull", "warning", "mountpoint", fs.mountpoint,
+				fmt.Sprintf("%s uses %s; jobs writing checkpoints or datasets to it will fail, clean it up", fs.mountpoint, strings.Join(full, " and "))})
+		}
+	}
+	for _, d := range reading.nvme {
+		if d.criticalWarning != 0 {
+			alerts = append(alerts, storageAlert{"NvmeCriticalWarning", "critical", "device", d.device,
+				fmt.Sprintf("NVMe drive %s reports critical warning %#x (spare, temperature, reliability, read-only or backup power); back up its data and replace it", d.device, int(d.criticalWarning))})
+		}
+		if d.mediaErrors > 0 {
+			alerts = append(alerts, storageAlert{"NvmeMediaErrors", "critical", "device", d.device,
+				fmt.Sprintf("NVMe drive %s has recorded %.0f unrecovered media errors; data on it may be corrupted, replace it", d.device, d.mediaErrors)})
+		}
+		if d.percentageUsed >= m.wearPercent {
+			alerts = append(alerts, storageAlert{"NvmeWearOut", "warning", "device", d.device,
+				fmt.Sprintf("NVMe drive %s has used %.0f%% of its rated endurance; plan its replacement", d.device, d.percentageUsed)})
+		}
+	}
+	for _, a := range reading.arrays {
+		if !a.active || a.activeDisks < a.disks {
+			state := fmt.Sprintf("%d of %d disks in sync", a.activeDisks, a.disks)
+			if !a.active {
+				state = "inactive"
+			}
+			alerts = append(alerts, storageAlert{"MdraidDegraded", "critical", "array", a.name,
+				fmt.Sprintf("software RAID array %s is degraded (%s); replace the failed disk before another one fails", a.name, state)})
+		}
+	}
+	return alerts
+}
+
+// alert posts newly firing and resolved built-in alerts. If the post fails, the state
+// is kept unchanged so the same changes are sent again on the next check.
+func (m *storageMonitor) alert(reading storageReading, failed map[string]bool) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	next := make(map[string]webhookAlert)
+	var changed []webhookAlert
+	for _, c := range m.conditions(reading) {
+		key := c.name + "/" + c.subject
+		alert, ok := m.firing[key]
+		if !ok {
+			alert = m.newAlert(c, now)
+			changed = append(changed, alert)
+		}
+		next[key] = alert
+	}
+	for key, alert := range m.firing {
+		if _, ok := next[key]; ok {
+			continue
+		}
+		if failed[alert.Labels["alertname"]] {
+			next[key] = alert
+			continue
+		}
+		alert.Status = "resolved"
+		alert.EndsAt = now
+		changed = append(changed, alert)
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	if err := m.adapter.post(changed); err != nil {
+		log.Printf("Error posting storage alerts: %v", err)
+		return
+	}
+	for _, alert := range changed {
+		log.Printf("Posted storage alert %s (%s): %s", alert.Labels["alertname"], alert.Status, alert.Annotations["summary"])
+	}
+	m.firing = next
+}
+
+// newAlert returns a firing alert about the node's storage, which is not about any GPU.
+func (m *storageMonitor) newAlert(c storageAlert, now string) webhookAlert {
+	alert := m.adapter.newAlert(c.name, c.severity, "", "", "", now)
+	for name, value := range alert.Labels {
+		if value == "" {
+			delete(alert.Labels, name)
+		}
+	}
+	alert.Labels[c.label] = c.subject
+	alert.Annotations["summary"] = fmt.Sprintf("%s: %s", m.adapter.instance, c.summary)
+	return alert
+}
+
+// readFilesystems reads the usage of the mount points. In a container they have to be
+// mounted from the host.
+func readFilesystems(mountpoints []string) ([]filesystemUsage, error) {
+	usage := make([]filesystemUsage, 0, len(mountpoints))
+	for _, mountpoint := range mountpoints {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(mountpoint, &st); err != nil {
+			return nil, fmt.Errorf("statfs %s: %w", mountpoint, err)
+		}
+		blockSize := float64(st.Bsize)
+		usage = append(usage, filesystemUsage{
+			mountpoint: mountpoint,
+			sizeBytes:  float64(st.Blocks) * blockSize,
+			availBytes: float64(st.Bavail) * blockSize,
+			files:      float64(st.Files),
+			filesFree:  float64(st.Ffree),
+		})
+	}
+	return usage, nil
+}
+
+// readNVMeHealth reads the SMART log of every NVMe controller in /sys/class/nvme with
+// "nvme smart-log -o json", which needs access to the /dev/nvme* devices.
+func readNVMeHealth(ctx context.Context, nvmeCLI string) ([]nvmeHealth, error) {
+	controllers, _ := filepath.Glob("/sys/class/nvme/nvme*")
+	sort.Strings(controllers)
+	health := make([]nvmeHealth, 0, len(controllers))
+	for _, controller := range controllers {
+		device := filepath.Base(controller)
+		out, err := exec.CommandContext(ctx, nvmeCLI, "smart-log", "/dev/"+device, "-o", "json").Output()
+		if err != nil {
+			return nil, fmt.Errorf("nvme smart-log /dev/%s: %w", device, err)
+		}
+		h, err := parseSmartLog(out)
+		if err != nil {
+			return nil, fmt.Errorf("nvme smart-log /dev/%s: %w", device, err)
+		}
+		h.device = device
+		health = append(health, h)
+	}
+	return health, nil
+}
+
+// parseSmartLog decodes nvme-cli's JSON SMART log. Its field names and number formats
+// changed between releases: the endurance is percent_used or percentage_used, and
+// numbers may be strings (e.g. "310 K"). The temperature is in Kelvin, except in the
+// releases that print it in Celsius.
+func parseSmartLog(out []byte) (nvmeHealth, error) {
+	var smart map[string]any
+	if err := json.Unmarshal(out, &smart); err != nil {
+		return nvmeHealth{}, err
+	}
+	number := func(names ...string) float64 {
+		for _, name := range names {
+			switch v := smart[name].(type) {
+			case float64:
+				return v
+			case string:
+				if f, err := strconv.ParseFloat(strings.TrimRight(strings.Fields(v + " ")[0], "%"), 64); err == nil {
+					return f
+				}
+			}
+		}
+		return 0
+	}
+	h := nvmeHealth{
+		criticalWarning: number("critical_warning"),
+		percentageUsed:  number("percent_used", "percentage_used"),
+		availableSpare:  number("avail_spare", "available_spare"),
+		mediaErrors:     number("media_errors"),
+	}
+	h.temperatureCelsius = number("temperature")
+	if h.temperatureCelsius > 200 {
+		h.temperatureCelsius -= 273.15
+	}
+	return h, nil
+}
+
+var (
+	mdArrayPattern    = regexp.MustCompile(`^(md\S+) : (\S+)`)
+	mdMemberPattern   = regexp.MustCompile(`\S+\[\d+\](\([A-Z]\))?`)
+	mdDisksPattern    = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+	mdProgressPattern = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([\d.]+)%`)
+)
+
+// readMdstat reads the software RAID arrays from /proc/mdstat.
+func readMdstat(path string) ([]mdArray, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The md driver is not loaded, so there are no arrays.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMdstat(f)
+}
+
+// parseMdstat parses /proc/mdstat: an array's first line names its state and members,
+// with (F) after failed and (S) after spare ones, and the next lines hold the [n/m]
+// count of disks in sync (absent for RAID 0) and the progress of a running sync.
+func parseMdstat(r io.Reader) ([]mdArray, error) {
+	var arrays []mdArray
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mdArrayPattern.FindStringSubmatch(line); m != nil {
+			a := mdArray{name: m[1], active: m[2] == "active"}
+			for _, member := range mdMemberPattern.FindAllStringSubmatch(line, -1) {
+				if member[1] == "(S)" {
+					continue
+				}
+				a.disks++
+				if member[1] == "" {
+					a.activeDisks++
+				}
+			}
+			arrays = append(arrays, a)
+			continue
+		}
+		if len(arrays) == 0 || !strings.HasPrefix(line, " ") {
+			continue
+		}
+		a := &arrays[len(arrays)-1]
+		if m := mdDisksPattern.FindStringSubmatch(line); m != nil {
+			a.disks, _ = strconv.Atoi(m[1])
+			a.activeDisks, _ = strconv.Atoi(m[2])
+		}
+		if m := mdProgressPattern.FindStringSubmatch(line); m != nil {
+			percent, _ := strconv.ParseFloat(m[2], 64)
+			a.syncAction, a.syncProgress = m[1], percent/100
+		}
+	}
+	return arrays, scanner.Err()
+}
+
+func (m *storageMonitor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- filesystemSizeDesc
+	ch <- filesystemAvailDesc
+	ch <- filesystemFilesDesc
+	ch <- filesystemFilesFreeDesc
+	ch <- nvmeCriticalWarningDesc
+	ch <- nvmeTemperatureDesc
+	ch <- nvmePercentageUsedDesc
+	ch <- nvmeAvailableSpareDesc
+	ch <- nvmeMediaErrorsDesc
+	ch <- mdraidDisksDesc
+	ch <- mdraidDisksActiveDesc
+	ch <- mdraidActiveDesc
+	ch <- mdraidSyncProgressDesc
+	ch <- storageReadErrorDesc
+}
+
+func (m *storageMonitor) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	reading, err := m.reading, m.err
+	m.mu.Unlock()
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	ch <- prometheus.MustNewConstMetric(storageReadErrorDesc, prometheus.GaugeValue, failed)
+
+	for _, fs := range reading.filesystems {
+		gauge(ch, filesystemSizeDesc, &fs.sizeBytes, fs.mountpoint)
+		gauge(ch, filesystemAvailDesc, &fs.availBytes, fs.mountpoint)
+		gauge(ch, filesystemFilesDesc, &fs.files, fs.mountpoint)
+		gauge(ch, filesystemFilesFreeDesc, &fs.filesFree, fs.mountpoint)
+	}
+	for _, d := range reading.nvme {
+		gauge(ch, nvmeCriticalWarningDesc, &d.criticalWarning, d.device)
+		gauge(ch, nvmeTemperatureDesc, &d.temperatureCelsius, d.device)
+		gauge(ch, nvmePercentageUsedDesc, &d.percentageUsed, d.device)
+		gauge(ch, nvmeAvailableSpareDesc, &d.availableSpare, d.device)
+		counter(ch, nvmeMediaErrorsDesc, &d.mediaErrors, d.device)
+	}
+	for _, a := range reading.arrays {
+		disks, activeDisks := float64(a.disks), float64(a.activeDisks)
+		gauge(ch, mdraidDisksDesc, &disks, a.name)
+		gauge(ch, mdraidDisksActiveDesc, &activeDisks, a.name)
+		gauge(ch, mdraidActiveDesc, healthValue(a.active), a.name)
+		if a.syncAction != "" {
+			gauge(ch, mdraidSyncProgressDesc, &a.syncProgress, a.name, a.syncAction)
+		}
+	}
+}
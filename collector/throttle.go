@@ -0,0 +1,45 @@
+package main
+
+import "github.com/NVIDIA/go-nvml/pkg/nvml"
+
+// throttleReasons names the NVML clock event ("throttle") reason bits, i.e. why the GPU
+// currently runs its clocks below the maximum. They are the reason label values of
+// gpu_clock_throttle_reason.
+var throttleReasons = []struct {
+	bit  uint64
+	name string
+}{
+	{nvml.ClocksEventReasonGpuIdle, "gpu_idle"},
+	{nvml.ClocksEventReasonApplicationsClocksSetting, "applications_clocks_setting"},
+	{nvml.ClocksEventReasonSwPowerCap, "sw_power_cap"},
+	{nvml.ClocksThrottleReasonHwSlowdown, "hw_slowdown"},
+	{nvml.ClocksEventReasonSyncBoost, "sync_boost"},
+	{nvml.ClocksEventReasonSwThermalSlowdown, "sw_thermal_slowdown"},
+	{nvml.ClocksThrottleReasonHwThermalSlowdown, "hw_thermal_slowdown"},
+	{nvml.ClocksThrottleReasonHwPowerBrakeSlowdown, "hw_power_brake_slowdown"},
+	{nvml.ClocksEventReasonDisplayClockSetting, "display_clock_setting"},
+}
+
+// allThrottleReasons is the mask of every reason in throttleReasons.
+var allThrottleReasons = func() uint64 {
+	var mask uint64
+	for _, r := range throttleReasons {
+		mask |= r.bit
+	}
+	return mask
+}()
+
+// sampleThrottleReasons reads the active clock throttle reasons of one device and the
+// reasons it supports.
+func sampleThrottleReasons(device nvml.Device, sample *gpuSample) {
+	active, ret := device.GetCurrentClocksEventReasons()
+	if ret != nvml.SUCCESS {
+		return
+	}
+	supported, ret := device.GetSupportedClocksEventReasons()
+	if ret != nvml.SUCCESS {
+		supported = allThrottleReasons
+	}
+	sample.ThrottleReasons = &active
+	sample.SupportedThrottleReasons = supported
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pciDevicesDir is where sysfs links every PCI device to its place in the PCIe tree.
+const pciDevicesDir = "/sys/bus/pci/devices"
+
+var (
+	topologyInfoDesc = prometheus.NewDesc("gpu_topology_info",
+		"Where the GPU sits in the node: its PCI bus ID, NUMA node (-1 if unknown) and upstream PCIe switch (empty if attached to a root port).", append(gpuLabels, "pci_bus_id", "numa_node", "pcie_switch"), nil)
+	nvlinkLinksDesc = prometheus.NewDesc("gpu_nvlink_links_active",
+		"Active NVLinks of the GPU, by the type of device at their far end (gpu, switch, cpu).", append(gpuLabels, "remote_type"), nil)
+	topologyConnectionDesc = prometheus.NewDesc("gpu_topology_connection",
+		"1 for the connection between two GPUs as nvidia-smi topo -m shows it: NV<n> for n NVLinks, else the closest common PCIe ancestor (PIX, PXB, PHB, NODE, SYS).", append(gpuLabels, "peer_gpu", "connection"), nil)
+	topologyChangedDesc = prometheus.NewDesc("gpu_topology_changed",
+		"1 if the GPU topology differs from the node's baseline, 0 otherwise.", nil, nil)
+)
+
+// nodeTopology is the GPU topology served on /api/topology.
+type nodeTopology struct {
+	GPUs []gpuTopology `json:"gpus"`
+	// Connections maps every pair of GPUs, by UUID, to their connection as in
+	// gpu_topology_connection.
+	Connections map[string]map[string]string `json:"connections"`
+	CollectedAt time.Time                    `json:"collectedAt"`
+}
+
+type gpuTopology struct {
+	Index    int    `json:"index"`
+	UUID     string `json:"uuid"`
+	Name     string `json:"name"`
+	PCIBusID string `json:"pciBusId"`
+	NUMANode int    `json:"numaNode"`
+	// PCIeSwitch is the upstream port of the PCIe switch the GPU hangs off, empty when it
+	// is attached to a root port.
+	PCIeSwitch string `json:"pcieSwitch,omitempty"`
+	// NVLinks counts the active NVLinks by the type of their remote device.
+	NVLinks map[string]int `json:"nvlinks,omitempty"`
+}
+
+// readTopology reads the topology of every GPU through NVML and sysfs.
+func readTopology() (nodeTopology, error) {
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nodeTopology{}, fmt.Errorf("getting device count: %s", nvml.ErrorString(ret))
+	}
+	topology := nodeTopology{Connections: make(map[string]map[string]string), CollectedAt: time.Now().UTC()}
+	var devices []nvml.Device
+	// nvlinkPeers counts the NVLinks of each GPU by the bus ID of their remote device.
+	var nvlinkPeers []map[string]int
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("Error getting handle for GPU %d: %s", i, nvml.ErrorString(ret))
+			continue
+		}
+		gpu := gpuTopology{Index: i, NUMANode: -1}
+		gpu.UUID, _ = device.GetUUID()
+		gpu.Name, _ = device.GetName()
+		if pci, ret := device.GetPciInfo(); ret == nvml.SUCCESS {
+			gpu.PCIBusID = sysfsBusID(cString(pci.BusId[:]))
+			gpu.NUMANode, gpu.PCIeSwitch = pcieLocation(gpu.PCIBusID)
+		}
+		peers := make(map[string]int)
+		for link := 0; link < nvml.NVLINK_MAX_LINKS; link++ {
+			if state, ret := device.GetNvLinkState(link); ret != nvml.SUCCESS || state != nvml.FEATURE_ENABLED {
+				continue
+			}
+			if gpu.NVLinks == nil {
+				gpu.NVLinks = make(map[string]int)
+			}
+			kind, _ := device.GetNvLinkRemoteDeviceType(link)
+			gpu.NVLinks[nvlinkRemoteType(kind)]++
+			if remote, ret := device.GetNvLinkRemotePciInfo(link); ret == nvml.SUCCESS {
+				peers[sysfsBusID(cString(remote.BusId[:]))]++
+			}
+		}
+		topology.GPUs = append(topology.GPUs, gpu)
+		devices = append(devices, device)
+		nvlinkPeers = append(nvlinkPeers, peers)
+	}
+
+	for i, a := range topology.GPUs {
+		topology.Connections[a.UUID] = make(map[string]string)
+		for j, b := range topology.GPUs {
+			if i == j {
+				continue
+			}
+			topology.Connections[a.UUID][b.UUID] = connection(a, b, nvlinkPeers[i][b.PCIBusID], devices[i], devices[j])
+		}
+	}
+	return topology, nil
+}
+
+// connection names the connection between two GPUs like nvidia-smi topo -m. GPUs on
+// NVSwitches reach each other over all the switch links of the one with fewer.
+func connection(a, b gpuTopology, direct int, deviceA, deviceB nvml.Device) string {
+	if direct > 0 {
+		return "NV" + strconv.Itoa(direct)
+	}
+	if n := min(a.NVLinks["switch"], b.NVLinks["switch"]); n > 0 {
+		return "NV" + strconv.Itoa(n)
+	}
+	level, ret := deviceA.GetTopologyCommonAncestor(deviceB)
+	if ret != nvml.SUCCESS {
+		return "unknown"
+	}
+	switch level {
+	case nvml.TOPOLOGY_INTERNAL, nvml.TOPOLOGY_SINGLE:
+		return "PIX"
+	case nvml.TOPOLOGY_MULTIPLE:
+		return "PXB"
+	case nvml.TOPOLOGY_HOSTBRIDGE:
+		return "PHB"
+	case nvml.TOPOLOGY_NODE:
+		return "NODE"
+	default:
+		return "SYS"
+	}
+}
+
+func nvlinkRemoteType(kind nvml.IntNvLinkDeviceType) string {
+	switch kind {
+	case nvml.NVLINK_DEVICE_TYPE_GPU:
+		return "gpu"
+	case nvml.NVLINK_DEVICE_TYPE_SWITCH:
+		return "switch"
+	case nvml.NVLINK_DEVICE_TYPE_IBMNPU:
+		return "cpu"
+	default:
+		return "unknown"
+	}
+}
+
+// sysfsBusID converts NVML's bus ID (00000000:07:00.0) to the sysfs form (0000:07:00.0).
+func sysfsBusID(busID string) string {
+	domain, rest, ok := strings.Cut(strings.ToLower(busID), ":")
+	if !ok {
+		return busID
+	}
+	if len(domain) > 4 {
+		domain = domain[len(domain)-4:]
+	}
+	return domain + ":" + rest
+}
+
+// pcieLocation reads the NUMA node of a PCI device and its upstream PCIe switch from
+// sysfs, where the device's path runs through every bridge from the root port down, e.g.
+// /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0/0000:02:08.0/0000:07:00.0 for a
+// GPU behind the switch with upstream port 0000:01:00.0.
+func pcieLocation(busID string) (numaNode int, pcieSwitch string) {
+	numaNode = -1
+	dir := filepath.Join(pciDevicesDir, busID)
+	if content, err := os.ReadFile(filepath.Join(dir, "numa_node")); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(content))); err == nil {
+			numaNode = n
+		}
+	}
+	path, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return numaNode, ""
+	}
+	var bridges []string
+	for _, part := range strings.Split(filepath.Dir(path), string(filepath.Separator)) {
+		if strings.Count(part, ":") == 2 {
+			bridges = append(bridges, part)
+		}
+	}
+	// The root port, the switch's upstream port and the downstream port leading to the GPU.
+	if len(bridges) < 3 {
+		return numaNode, ""
+	}
+	return numaNode, bridges[len(bridges)-2]
+}
+
+// diff lists how the topology differs from the baseline, GPUs matched by UUID.
+func (t nodeTopology) diff(baseline nodeTopology) []string {
+	current := make(map[string]gpuTopology, len(t.GPUs))
+	for _, gpu := range t.GPUs {
+		current[gpu.UUID] = gpu
+	}
+	var changes []string
+	for _, was := range baseline.GPUs {
+		gpu, ok := current[was.UUID]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("GPU %d (%s) is missing", was.Index, was.UUID))
+			continue
+		}
+		if gpu.PCIBusID != was.PCIBusID {
+			changes = append(changes, fmt.Sprintf("GPU %d moved from PCI %s to %s", gpu.Index, was.PCIBusID, gpu.PCIBusID))
+		}
+		if gpu.NUMANode != was.NUMANode {
+			changes = append(changes, fmt.Sprintf("GPU %d moved from NUMA node %d to %d", gpu.Index, was.NUMANode, gpu.NUMANode))
+		}
+		if gpu.PCIeSwitch != was.PCIeSwitch {
+			changes = append(changes, fmt.Sprintf("GPU %d moved from PCIe switch %q to %q", gpu.Index, was.PCIeSwitch, gpu.PCIeSwitch))
+		}
+		for _, kind := range []string{"gpu", "switch", "cpu", "unknown"} {
+			if gpu.NVLinks[kind] != was.NVLinks[kind] {
+				changes = append(changes, fmt.Sprintf("GPU %d has %d NVLink(s) to %s, was %d", gpu.Index, gpu.NVLinks[kind], nvlinkTargets[kind], was.NVLinks[kind]))
+			}
+		}
+	}
+	for _, gpu := range t.GPUs {
+		if !baseline.has(gpu.UUID) {
+			changes = append(changes, fmt.Sprintf("GPU %d (%s) is new", gpu.Index, gpu.UUID))
+		}
+	}
+	for _, a := range baseline.GPUs {
+		for _, b := range baseline.GPUs {
+			was, now := baseline.Connections[a.UUID][b.UUID], t.Connections[a.UUID][b.UUID]
+			if a.Index < b.Index && now != "" && now != was {
+				changes = append(changes, fmt.Sprintf("GPU %d and GPU %d are connected by %s, was %s", current[a.UUID].Index, current[b.UUID].Index, now, was))
+			}
+		}
+	}
+	return changes
+}
+
+var nvlinkTargets = map[string]string{"gpu": "GPUs", "switch": "NVSwitches", "cpu": "CPUs", "unknown": "unknown devices"}
+
+func (t nodeTopology) has(uuid string) bool {
+	for _, gpu := range t.GPUs {
+		if gpu.UUID == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// topologyWatcher reads the GPU topology every interval and compares it with the node's
+// baseline, the first topology it read (or the one accepted with POST
+// /api/topology?baseline=true after a planned hardware change). The baseline is kept in
+// a JSON file, so a GPU that drops off an NVSwitch or lands on another PCIe switch after
+// a reboot raises GpuTopologyChanged through the adapter, which hints at a badly seated
+// GPU, board or cable. It resolves once the topology matches the baseline again.
+type topologyWatcher struct {
+	path  string
+	token string
+	// adapter is nil unless ADAPTER_URL is set.
+	adapter *adapterClient
+
+	mu       sync.Mutex
+	current  nodeTopology
+	baseline *nodeTopology
+	changes  []string
+	// firing is the GpuTopologyChanged alert while it fires.
+	firing *webhookAlert
+}
+
+func newTopologyWatcher(path, token string, adapter *adapterClient) (*topologyWatcher, error) {
+	w := &topologyWatcher{path: path, token: token, adapter: adapter}
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var baseline nodeTopology
+		if err := json.Unmarshal(content, &baseline); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		w.baseline = &baseline
+	}
+	return w, nil
+}
+
+// run checks the topology right away and then every interval.
+func (w *topologyWatcher) run(interval time.Duration) {
+	for {
+		if err := w.check(false); err != nil {
+			log.Printf("Error reading the GPU topology: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// check reads the topology and compares it with the baseline, replacing the baseline if
+// there is none yet or accept is set.
+func (w *topologyWatcher) check(accept bool) error {
+	topology, err := readTopology()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.current = topology
+	if w.baseline == nil || accept {
+		w.baseline = &topology
+		if err := w.save(); err != nil {
+			log.Printf("Error storing the topology baseline in %s: %v", w.path, err)
+		}
+	}
+	w.changes = topology.diff(*w.baseline)
+	changes, firing := w.changes, w.firing
+	w.mu.Unlock()
+
+	if len(changes) > 0 && firing == nil {
+		log.Printf("GPU topology differs from the baseline: %s", strings.Join(changes, "; "))
+	}
+	if w.adapter == nil {
+		return nil
+	}
+	var alert webhookAlert
+	switch {
+	case len(changes) > 0 && firing == nil:
+		alert = w.newAlert(changes)
+	case len(changes) == 0 && firing != nil:
+		alert = *firing
+		alert.Status = "resolved"
+		alert.EndsAt = time.Now().UTC().Format(time.RFC3339)
+	default:
+		return nil
+	}
+	if err := w.adapter.post([]webhookAlert{alert}); err != nil {
+		// The state is kept, so the alert is posted again on the next check.
+		log.Printf("Error posting topology alert: %v", err)
+		return nil
+	}
+	w.mu.Lock()
+	if alert.Status == "firing" {
+		w.firing = &alert
+	} else {
+		w.firing = nil
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// newAlert returns GpuTopologyChanged for the node, which is not about any one GPU.
+func (w *topologyWatcher) newAlert(changes []string) webhookAlert {
+	alert := w.adapter.newAlert("GpuTopologyChanged", "warning", "", "", "", time.Now().UTC().Format(time.RFC3339))
+	for name, value := range alert.Labels {
+		if value == "" {
+			delete(alert.Labels, name)
+		}
+	}
+	alert.Annotations["summary"] = fmt.Sprintf("GPU topology of %s changed since %s: %s",
+		w.adapter.instance, w.baseline.CollectedAt.Format("2006-01-02"), strings.Join(changes, "; "))
+	alert.Annotations["description"] = "A GPU that dropped off an NVSwitch or moved to another PCIe switch or NUMA node usually means a badly seated GPU, board or cable. After a planned change, accept the new topology with POST /api/topology?baseline=true."
+	return alert
+}
+
+// save writes the baseline through a temporary file. w.mu must be held.
+func (w *topologyWatcher) save() error {
+	content, err := json.MarshalIndent(w.baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// topologyStatus is the body of GET /api/topology.
+type topologyStatus struct {
+	nodeTopology
+	BaselineAt time.Time `json:"baselineAt"`
+	Changes    []string  `json:"changes"`
+}
+
+// handle serves GET /api/topology with the current topology and how it differs from the
+// baseline, and POST /api/topology?baseline=true, which rereads the topology and makes
+// it the baseline.
+func (w *topologyWatcher) handle(rw http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+w.token)) != 1 {
+		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Query().Get("baseline") == "true":
+		if err := w.check(true); err != nil {
+			log.Printf("Error reading the GPU topology: %v", err)
+			http.Error(rw, "Error reading the GPU topology", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Accepted the current GPU topology as the baseline")
+	case r.Method != http.MethodGet:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.mu.Lock()
+	status := topologyStatus{nodeTopology: w.current, Changes: w.changes}
+	if w.baseline != nil {
+		status.BaselineAt = w.baseline.CollectedAt
+	}
+	w.mu.Unlock()
+	if status.GPUs == nil {
+		status.GPUs = []gpuTopology{}
+	}
+	if status.Changes == nil {
+		status.Changes = []string{}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(status)
+}
+
+func (w *topologyWatcher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- topologyInfoDesc
+	ch <- nvlinkLinksDesc
+	ch <- topologyConnectionDesc
+	ch <- topologyChangedDesc
+}
+
+func (w *topologyWatcher) Collect(ch chan<- prometheus.Metric) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.baseline == nil {
+		// Not read yet.
+		return
+	}
+	indexes := make(map[string]string, len(w.current.GPUs))
+	for _, gpu := range w.current.GPUs {
+		indexes[gpu.UUID] = strconv.Itoa(gpu.Index)
+	}
+	for _, gpu := range w.current.GPUs {
+		labels := []string{strconv.Itoa(gpu.Index), gpu.UUID, gpu.Name}
+		ch <- prometheus.MustNewConstMetric(topologyInfoDesc, prometheus.GaugeValue, 1, append(labels, gpu.PCIBusID, strconv.Itoa(gpu.NUMANode), gpu.PCIeSwitch)...)
+		for kind, links := range gpu.NVLinks {
+			ch <- prometheus.MustNewConstMetric(nvlinkLinksDesc, prometheus.GaugeValue, float64(links), append(labels, kind)...)
+		}
+		for peer, connection := range w.current.Connections[gpu.UUID] {
+			ch <- prometheus.MustNewConstMetric(topologyConnectionDesc, prometheus.GaugeValue, 1, append(labels, indexes[peer], connection)...)
+		}
+	}
+	changed := 0.0
+	if len(w.changes) > 0 {
+		changed = 1
+	}
+	ch <- prometheus.MustNewConstMetric(topologyChangedDesc, prometheus.GaugeValue, changed)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// thermalTrends keeps a rolling window of temperature readings per GPU and estimates how
+// fast each GPU is heating up. A steady climb usually points at a failing fan or blocked
+// airflow and shows up well before the GPU reaches its thermal throttle limit.
+type thermalTrends struct {
+	window time.Duration
+	// threshold is the rise in °C per minute at which a GPU is flagged.
+	threshold float64
+
+	mu      sync.Mutex
+	samples map[string][]temperatureReading
+}
+
+type temperatureReading struct {
+	at      time.Time
+	celsius float64
+}
+
+func newThermalTrends(window time.Duration, threshold float64) *thermalTrends {
+	return &thermalTrends{
+		window:    window,
+		threshold: threshold,
+		samples:   make(map[string][]temperatureReading),
+	}
+}
+
+// observe records a reading for the GPU and returns its current trend in °C per minute.
+// ok is false until the readings span at least half the window, so a handful of samples
+// right after startup cannot raise an alert.
+func (t *thermalTrends) observe(uuid string, celsius float64, now time.Time) (perMinute float64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.window)
+	for id, readings := range t.samples {
+		// Forget GPUs that have not been seen for a whole window.
+		if id != uuid && !readings[len(readings)-1].at.After(cutoff) {
+			delete(t.samples, id)
+		}
+	}
+
+	readings := append(t.samples[uuid], temperatureReading{at: now, celsius: celsius})
+	first := 0
+	for first < len(readings) && !readings[first].at.After(cutoff) {
+		first++
+	}
+	readings = readings[first:]
+	t.samples[uuid] = readings
+
+	if len(readings) < 3 || now.Sub(readings[0].at) < t.window/2 {
+		return 0, false
+	}
+	return slope(readings) * 60, true
+}
+
+// rising reports whether a trend is above the alert threshold.
+func (t *thermalTrends) rising(perMinute float64) bool {
+	return perMinute >= t.threshold
+}
+
+// slope is the least-squares fit of temperature over time, in °C per second.
+func slope(readings []temperatureReading) float64 {
+	origin := readings[0].at
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, r := range readings {
+		x := r.at.Sub(origin).Seconds()
+		n++
+		sumX += x
+		sumY += r.celsius
+		sumXY += x * r.celsius
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
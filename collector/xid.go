@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// xidRepeatInterval is how long the same XID on the same GPU is only counted, not alerted
+// on again, since a failing GPU or application often logs the same XID many times.
+const xidRepeatInterval = 10 * time.Minute
+
+// defaultXIDHold is how long an XID alert stays firing after the last XID of its code on
+// the GPU, unless XID_RESOLVE_AFTER says otherwise.
+const defaultXIDHold = time.Hour
+
+// xidClass is what an XID code means and how urgent it is.
+type xidClass struct {
+	description string
+	// severity is "critical" for XIDs that point at the GPU, its memory or its links and
+	// "warning" for those usually caused by the application.
+	severity string
+	action   string
+}
+
+// xidClasses decodes the XIDs seen on datacenter GPUs, after NVIDIA's XID catalog.
+var xidClasses = map[int]xidClass{
+	13:  {"graphics engine exception", "warning", "usually an application bug; check the job's logs"},
+	31:  {"GPU memory page fault", "warning", "usually an illegal memory access by the application"},
+	32:  {"invalid or corrupted push buffer stream", "warning", "check the driver and the application"},
+	38:  {"driver firmware error", "critical", "reset the GPU; update the driver if it recurs"},
+	43:  {"GPU stopped processing", "warning", "usually an application fault; the GPU keeps working"},
+	45:  {"preemptive cleanup after a previous error", "warning", "look for an earlier XID on the GPU"},
+	48:  {"double bit ECC error (DBE)", "critical", "drain the node and reset the GPU"},
+	61:  {"internal micro-controller breakpoint", "critical", "reset the GPU"},
+	62:  {"internal micro-controller halt", "critical", "reset the GPU"},
+	63:  {"ECC page retirement or row remapping recorded", "warning", "reset the GPU when convenient to apply it"},
+	64:  {"ECC page retirement or row remapping recording failure", "critical", "drain the node and reset the GPU"},
+	68:  {"video decoder exception", "warning", "check the application"},
+	69:  {"graphics engine class error", "warning", "check the application"},
+	74:  {"NVLink error", "critical", "check the NVLink connections and reset the GPU"},
+	79:  {"GPU has fallen off the bus", "critical", "drain the node; the GPU needs a reset or a reboot"},
+	92:  {"high single-bit ECC error rate", "warning", "watch the GPU's ECC counters"},
+	94:  {"contained ECC error", "warning", "the affected application was stopped; reset the GPU when it is idle"},
+	95:  {"uncontained ECC error", "critical", "drain the node and reset the GPU"},
+	109: {"context switch timeout", "warning", "check the application"},
+	119: {"GSP RPC timeout", "critical", "reset the GPU"},
+	120: {"GSP error", "critical", "reset the GPU"},
+	121: {"C2C link error", "critical", "reset the GPU"},
+	140: {"unrecovered ECC error", "critical", "drain the node and reset the GPU"},
+	154: {"GPU recovery action changed", "critical", "follow the recovery action reported by nvidia-smi"},
+}
+
+// classifyXID returns the class of an XID, with a generic one for codes not in the table.
+func classifyXID(xid int) xidClass {
+	if class, ok := xidClasses[xid]; ok {
+		return class
+	}
+	return xidClass{"unknown XID", "warning", "look the code up in NVIDIA's XID catalog"}
+}
+
+// xidEvent is one XID error reported for a GPU. Fields that could not be determined
+// are empty, and index is -1 when the GPU is unknown.
+type xidEvent struct {
+	index    int
+	uuid     string
+	name     string
+	pciBusID string
+	xid      int
+	detail   string
+	at       time.Time
+}
+
+// gpuName names the GPU of the event in messages.
+func (e xidEvent) gpuName() string {
+	switch {
+	case e.index >= 0:
+		return "GPU " + strconv.Itoa(e.index)
+	case e.pciBusID != "":
+		return "GPU at PCI " + e.pciBusID
+	default:
+		return "unknown GPU"
+	}
+}
+
+var xidErrorsDesc = prometheus.NewDesc("gpu_xid_errors_total",
+	"XID errors reported by the driver since the collector started, by XID code.", append(gpuLabels, "xid"), nil)
+
+// xidWatcher counts XID errors as they happen and alerts on each through the adapter,
+// without waiting for the next scrape. An XID is an event rather than a state, so its
+// alert is resolved once hold has passed without the same XID on the GPU.
+type xidWatcher struct {
+	// adapter is nil when XIDs are only counted.
+	adapter *adapterClient
+	hold    time.Duration
+
+	mu     sync.Mutex
+	counts map[xidCountKey]float64
+	// alerted holds when each GPU and XID was last alerted on.
+	alerted map[string]time.Time
+	// firing holds the alerts posted and not yet resolved, by the same key as alerted.
+	firing map[string]*xidAlert
+}
+
+// xidAlert is a posted XID alert and when its XID was last seen.
+type xidAlert struct {
+	alert    webhookAlert
+	lastSeen time.Time
+}
+
+type xidCountKey struct {
+	gpu, uuid, name string
+	xid             int
+}
+
+func newXIDWatcher(adapter *adapterClient, hold time.Duration) *xidWatcher {
+	return &xidWatcher{
+		adapter: adapter,
+		hold:    hold,
+		counts:  make(map[xidCountKey]float64),
+		alerted: make(map[string]time.Time),
+		firing:  make(map[string]*xidAlert),
+	}
+}
+
+func (w *xidWatcher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- xidErrorsDesc
+}
+
+func (w *xidWatcher) Collect(ch chan<- prometheus.Metric) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, n := range w.counts {
+		ch <- prometheus.MustNewConstMetric(xidErrorsDesc, prometheus.CounterValue, n, key.gpu, key.uuid, key.name, strconv.Itoa(key.xid))
+	}
+}
+
+// record counts an XID and posts an alert for it unless the same XID was alerted on for
+// the GPU within xidRepeatInterval. Either way the alert's hold starts over.
+func (w *xidWatcher) record(e xidEvent) {
+	gpu := ""
+	if e.index >= 0 {
+		gpu = strconv.Itoa(e.index)
+	}
+	class := classifyXID(e.xid)
+	log.Printf("XID %d on %s (%s): %s", e.xid, e.gpuName(), class.description, e.detail)
+
+	w.mu.Lock()
+	w.counts[xidCountKey{gpu, e.uuid, e.name, e.xid}]++
+	key := fmt.Sprintf("%s/%s/%d", e.uuid, e.pciBusID, e.xid)
+	last, seen := w.alerted[key]
+	repeat := seen && e.at.Sub(last) < xidRepeatInterval
+	if f, ok := w.firing[key]; ok {
+		f.lastSeen = e.at
+	}
+	w.mu.Unlock()
+	if w.adapter == nil || repeat {
+		return
+	}
+
+	alert := w.adapter.newAlert("GpuXidError", class.severity, gpu, e.uuid, e.name, e.at.UTC().Format(time.RFC3339))
+	alert.Labels["xid"] = strconv.Itoa(e.xid)
+	alert.Annotations["summary"] = fmt.Sprintf("%s on %s: XID %d, %s; %s", e.gpuName(), w.adapter.instance, e.xid, class.description, class.action)
+	if e.detail != "" {
+		alert.Annotations["description"] = e.detail
+	}
+	if err := w.adapter.post([]webhookAlert{alert}); err != nil {
+		log.Printf("Error posting XID %d alert for %s: %v", e.xid, e.gpuName(), err)
+		return
+	}
+	w.mu.Lock()
+	w.alerted[key] = e.at
+	w.firing[key] = &xidAlert{alert: alert, lastSeen: e.at}
+	w.mu.Unlock()
+}
+
+// runResolver resolves held XID alerts every interval.
+func (w *xidWatcher) runResolver(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := w.resolve(time.Now()); err != nil {
+			log.Printf("Error posting resolved XID alerts: %v", err)
+		}
+	}
+}
+
+// resolve posts the alerts whose XID was last seen hold or longer before now as
+// resolved. If the post fails, they stay firing and are resolved on the next call.
+func (w *xidWatcher) resolve(now time.Time) error {
+	w.mu.Lock()
+	var resolved []webhookAlert
+	held := make(map[string]*xidAlert)
+	for key, f := range w.firing {
+		if now.Sub(f.lastSeen) < w.hold {
+			continue
+		}
+		alert := f.alert
+		alert.Status = "resolved"
+		alert.EndsAt = now.UTC().Format(time.RFC3339)
+		resolved = append(resolved, alert)
+		held[key] = f
+	}
+	w.mu.Unlock()
+	if len(resolved) == 0 {
+		return nil
+	}
+
+	if err := w.adapter.post(resolved); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	for key, f := range held {
+		// An alert posted again while this post was under way stays firing. The next XID
+		// after the resolved one alerts again, without waiting for xidRepeatInterval.
+		if w.firing[key] == f {
+			delete(w.firing, key)
+			delete(w.alerted, key)
+		}
+	}
+	w.mu.Unlock()
+	for _, alert := range resolved {
+		log.Printf("Resolved XID %s alert for %s after %s without another", alert.Labels["xid"], alert.Labels["UUID"], w.hold)
+	}
+	return nil
+}
+
+// watchNVMLXIDs receives XID events through NVML's event API until NVML fails for good.
+// nvml.Init must have been called.
+func (w *xidWatcher) watchNVMLXIDs() error {
+	set, ret := nvml.EventSetCreate()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("creating NVML event set: %s", nvml.ErrorString(ret))
+	}
+	defer set.Free()
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("getting device count: %s", nvml.ErrorString(ret))
+	}
+	registered := 0
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			log.Printf("Error getting handle for GPU %d: %s", i, nvml.ErrorString(ret))
+			continue
+		}
+		if ret := device.RegisterEvents(nvml.EventTypeXidCriticalError, set); ret != nvml.SUCCESS {
+			log.Printf("Not watching XID events of GPU %d: %s", i, nvml.ErrorString(ret))
+			continue
+		}
+		registered++
+	}
+	if registered == 0 {
+		return errors.New("no GPU supports XID events")
+	}
+
+	for {
+		data, ret := set.Wait(5000)
+		if ret == nvml.ERROR_TIMEOUT {
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			log.Printf("Error waiting for NVML events: %s", nvml.ErrorString(ret))
+			time.Sleep(time.Second)
+			continue
+		}
+		if data.EventType != nvml.EventTypeXidCriticalError {
+			continue
+		}
+		e := xidEvent{index: -1, xid: int(data.EventData), at: time.Now()}
+		if index, ret := data.Device.GetIndex(); ret == nvml.SUCCESS {
+			e.index = index
+		}
+		if uuid, ret := data.Device.GetUUID(); ret == nvml.SUCCESS {
+			e.uuid = uuid
+		}
+		if name, ret := data.Device.GetName(); ret == nvml.SUCCESS {
+			e.name = name
+		}
+		w.record(e)
+	}
+}
+
+// kmsgXIDPattern matches the driver's XID log line, e.g.
+// "NVRM: Xid (PCI:0000:3b:00): 79, pid=1234, GPU has fallen off the bus."
+var kmsgXIDPattern = regexp.MustCompile(`NVRM: Xid \(PCI:([0-9a-fA-F:.]+)\): (\d+),\s*(.*)`)
+
+// watchKernelLogXIDs follows the kernel log at path (normally /dev/kmsg, which needs
+// CAP_SYSLOG) from its current end and records every XID line. GPUs are identified by
+// their PCI bus ID through the backend's inventory.
+func (w *xidWatcher) watchKernelLogXIDs(path string, backend gpuBackend) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seeking to the end of %s: %w", path, err)
+	}
+
+	gpus := make(map[string]gpuInventory)
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		switch {
+		case errors.Is(err, syscall.EPIPE):
+			// /dev/kmsg reports records overwritten before they were read; carry on.
+			continue
+		case err == io.EOF:
+			// A regular file (e.g. a kern.log) has no more lines yet.
+			time.Sleep(time.Second)
+			continue
+		case err != nil:
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		m := kmsgXIDPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		xid, _ := strconv.Atoi(m[2])
+		e := xidEvent{index: -1, xid: xid, detail: strings.TrimSpace(m[3]), at: time.Now()}
+
+		bus := normalizePCIBusID(m[1])
+		gpu, ok := gpus[bus]
+		if !ok {
+			// A GPU that fell off the bus may not be listed any more; keep what is known.
+			if inventory, err := backend.Inventory(); err == nil {
+				for _, g := range inventory.GPUs {
+					gpus[normalizePCIBusID(g.PCIBusID)] = g
+				}
+			}
+			gpu, ok = gpus[bus]
+		}
+		e.pciBusID = m[1]
+		if ok {
+			e.index, e.uuid, e.name = gpu.Index, gpu.UUID, gpu.Name
+		}
+		w.record(e)
+	}
+}
+
+// normalizePCIBusID reduces the bus ID spellings of NVML ("00000000:3B:00.0") and the
+// kernel log ("0000:3b:00") to one form ("0000:3b:00").
+func normalizePCIBusID(id string) string {
+	id = strings.ToLower(id)
+	if i := strings.LastIndex(id, "."); i >= 0 {
+		id = id[:i]
+	}
+	parts := strings.Split(id, ":")
+	if len(parts) < 3 {
+		return id
+	}
+	domain, err := strconv.ParseUint(parts[len(parts)-3], 16, 32)
+	if err != nil {
+		return id
+	}
+	return fmt.Sprintf("%04x:%s:%s", domain, parts[len(parts)-2], parts[len(parts)-1])
+}
@@ -0,0 +1,194 @@
+// Command gpu-notify posts an ad-hoc alert to the alertmanager adapter, so cron jobs and
+// shell scripts on GPU nodes go through the adapter's routing, templates, deduplication
+// and outputs instead of posting to Google Chat themselves:
+//
+//	gpu-notify -severity critical -summary "NVMe scratch is 97% full" -instance gpu-node-03
+//	gpu-notify -alertname ScratchFull -summary "Scratch cleaned up" -resolve
+//
+// The alert is sent in Alertmanager's webhook format with job="gpu-notify". Sending the
+// same alertname, instance and labels again with -resolve resolves it. The adapter URL,
+// signature secret and bearer token default to ADAPTER_URL, ADAPTER_SIGNATURE_SECRET and
+// ADAPTER_BEARER_TOKEN, as for the collector.
+//
+// Build it with: go build -o gpu-notify ./cmd/notify
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// webhookPayload and webhookAlert are the parts of Alertmanager's webhook format the
+// adapter reads.
+type webhookPayload struct {
+	Version      string            `json:"version"`
+	Status       string            `json:"status"`
+	Receiver     string            `json:"receiver"`
+	GroupKey     string            `json:"groupKey"`
+	GroupLabels  map[string]string `json:"groupLabels"`
+	CommonLabels map[string]string `json:"commonLabels"`
+	Alerts       []webhookAlert    `json:"alerts"`
+}
+
+type webhookAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt"`
+}
+
+// keyValues collects repeated "-label name=value" flags.
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	var pairs []string
+	for name, value := range kv {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	kv[name] = value
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	hostname, _ := os.Hostname()
+	labels, annotations := keyValues{}, keyValues{}
+
+	flags := flag.NewFlagSet("gpu-notify", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: gpu-notify [flags] -summary \"...\"\n")
+		flags.PrintDefaults()
+	}
+	adapterURL := flags.String("adapter", envOr("ADAPTER_URL", "http://localhost:8080/webhook"), "webhook URL of the adapter")
+	alertname := flags.String("alertname", "Notification", "alertname label")
+	severity := flags.String("severity", "info", "severity label, e.g. critical, warning or info")
+	summary := flags.String("summary", "", "summary annotation (required)")
+	description := flags.String("description", "", "description annotation")
+	instance := flags.String("instance", hostname, "instance label")
+	resolve := flags.Bool("resolve", false, "resolve the alert instead of firing it")
+	secret := flags.String("signature-secret", os.Getenv("ADAPTER_SIGNATURE_SECRET"), "sign the request with this HMAC-SHA256 secret (signature in the adapter config)")
+	token := flags.String("token", os.Getenv("ADAPTER_BEARER_TOKEN"), "bearer token for the adapter (auth in the adapter config)")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of the request")
+	flags.Var(labels, "label", "additional label as name=value, may be repeated")
+	flags.Var(annotations, "annotation", "additional annotation as name=value, may be repeated")
+	flags.Parse(args)
+
+	if *summary == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	alert := webhookAlert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": *alertname, "instance": *instance, "job": "gpu-notify"},
+		Annotations: map[string]string{"summary": *summary},
+		StartsAt:    time.Now().UTC().Format(time.RFC3339),
+		EndsAt:      "0001-01-01T00:00:00Z",
+	}
+	for name, value := range labels {
+		alert.Labels[name] = value
+	}
+	if *severity != "" {
+		alert.Labels["severity"] = *severity
+	}
+	for name, value := range annotations {
+		alert.Annotations[name] = value
+	}
+	if *description != "" {
+		alert.Annotations["description"] = *description
+	}
+	if *resolve {
+		alert.Status = "resolved"
+		alert.EndsAt = alert.StartsAt
+	}
+
+	payload := webhookPayload{
+		Version:      "4",
+		Status:       alert.Status,
+		Receiver:     "gpu-notify",
+		GroupKey:     "gpu-notify/" + *instance + "/" + *alertname,
+		GroupLabels:  map[string]string{"alertname": *alertname, "instance": *instance},
+		CommonLabels: alert.Labels,
+		Alerts:       []webhookAlert{alert},
+	}
+	client := &http.Client{Timeout: *timeout}
+	if err := post(client, *adapterURL, *secret, *token, payload); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending the alert: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// post sends the payload, signed if a secret is set, and returns the adapter's error
+// message if it refuses it.
+func post(client *http.Client, url, secret, token string, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	// The adapter answers 4xx with {"status", "error", "message"}, 5xx with plain text.
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var webhookErr struct {
+		Message string `json:"message"`
+	}
+	message := strings.TrimSpace(string(respBody))
+	if json.Unmarshal(respBody, &webhookErr) == nil && webhookErr.Message != "" {
+		message = webhookErr.Message
+	}
+	if message == "" {
+		return errors.New(resp.Status)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, message)
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
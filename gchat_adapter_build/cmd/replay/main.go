@@ -0,0 +1,22 @@
+// Command replay renders captured Alertmanager (or, with -grafana, Grafana) webhook
+// payloads through the adapter's routing and templates and prints the resulting
+// messages as JSON, so template and routing changes can be checked offline:
+//
+//	replay -config config.yml payload.json
+//	replay -config config.yml -grafana - < grafana-payload.json
+//
+// With -live the messages are also posted to their webhooks. Redaction is applied;
+// enrichment, deduplication, grouping and maintenance windows are not.
+//
+// Build it with: go build -o replay ./cmd/replay
+package main
+
+import (
+	"os"
+
+	"alertmanager-adapter/internal/adapter"
+)
+
+func main() {
+	os.Exit(adapter.Replay(os.Args[1:]))
+}
@@ -0,0 +1,276 @@
+package adapter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// actionLinkTTL bounds how long the buttons on a card keep working.
+const actionLinkTTL = threadTrackerTTL
+
+// alertActions adds "Acknowledge" and "Silence 1h" buttons to Google Chat cards.
+// Incoming webhook messages can only carry link buttons, so each button opens a signed
+// URL on the adapter. Opening it only shows a confirmation page, since link unfurlers,
+// URL scanners and browser prefetching open links too; confirming posts the signed
+// fields back, and the handler creates a matching silence in Alertmanager and replies
+// in the incident's thread.
+type alertActions struct {
+	baseURL         string
+	alertmanagerURL string
+	secret          []byte
+	ackDuration     time.Duration
+	client          *http.Client
+}
+
+// silenceAction is one of the card buttons.
+type silenceAction struct {
+	name     string
+	label    string
+	duration time.Duration
+}
+
+// newAlertActions returns nil when card actions are disabled.
+func newAlertActions(cfg ActionsConfig) *alertActions {
+	if cfg.BaseURL == "" {
+		return nil
+	}
+	return &alertActions{
+		baseURL:         strings.TrimRight(cfg.BaseURL, "/"),
+		alertmanagerURL: strings.TrimRight(cfg.AlertmanagerURL, "/"),
+		secret:          []byte(cfg.Secret),
+		ackDuration:     cfg.AckDuration,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// actions returns the buttons offered on a firing alert's card.
+func (a *alertActions) actions() []silenceAction {
+	return []silenceAction{
+		{name: "ack", label: "Acknowledge", duration: a.ackDuration},
+		{name: "silence", label: "Silence 1h", duration: time.Hour},
+	}
+}
+
+func (a *alertActions) action(name string) (silenceAction, bool) {
+	for _, action := range a.actions() {
+		if action.name == name {
+			return action, true
+		}
+	}
+	return silenceAction{}, false
+}
+
+// buttons returns the card widget with the action buttons for the alert.
+func (a *alertActions) buttons(alert Alert) (CardWidget, error) {
+	labels, err := json.Marshal(alert.Labels)
+	if err != nil {
+		return CardWidget{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(labels)
+	fingerprint := alertFingerprint(alert)
+	expires := strconv.FormatInt(time.Now().Add(actionLinkTTL).Unix(), 10)
+
+	var buttons []CardButton
+	for _, action := range a.actions() {
+		q := url.Values{}
+		q.Set("action", action.name)
+		q.Set("labels", encoded)
+		q.Set("fingerprint", fingerprint)
+		q.Set("expires", expires)
+		q.Set("sig", a.sign(action.name, encoded, fingerprint, expires))
+		buttons = append(buttons, CardButton{
+			Text:    action.label,
+			OnClick: CardOnClick{OpenLink: &CardOpenLink{URL: a.baseURL + "/actions?" + q.Encode()}},
+		})
+	}
+	return CardWidget{ButtonList: &ButtonList{Buttons: buttons}}, nil
+}
+
+func (a *alertActions) sign(action, labels, fingerprint, expires string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", action, labels, fingerprint, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// alertmanagerSilence is the body of POST /api/v2/silences.
+type alertmanagerSilence struct {
+	Matchers  []silenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// createSilence silences exactly the alert's label set and returns the silence ID.
+func (a *alertActions) createSilence(labels map[string]string, duration time.Duration, comment string) (string, error) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	silence := alertmanagerSilence{
+		StartsAt:  time.Now().UTC(),
+		EndsAt:    time.Now().Add(duration).UTC(),
+		CreatedBy: "alertmanager-adapter",
+		Comment:   comment,
+	}
+	for _, name := range names {
+		silence.Matchers = append(silence.Matchers, silenceMatcher{Name: name, Value: labels[name], IsEqual: true})
+	}
+
+	return postSilence(a.client, a.alertmanagerURL, silence)
+}
+
+// reply posts a notice about the alert to its Google Chat webhooks, in the incident's
+// thread when threading is enabled.
+func (a *adapter) reply(ctx context.Context, alert Alert, text string) error {
+	n, retry := a.backend("gchat")
+	chat, ok := n.(*googleChatNotifier)
+	if !ok {
+		return fmt.Errorf("output %q is not enabled", "gchat")
+	}
+	messages, err := chat.renderReply(alert, text)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		attempts := 0
+		if err := retry.do(ctx, chat.Name()+" post", func() error {
+			attempts++
+			return a.send(ctx, chat, m, attempts)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// actionFields are the signed fields of an action link, passed on by its confirmation
+// form.
+var actionFields = []string{"action", "labels", "fingerprint", "expires", "sig"}
+
+// handleAction serves the links behind the card buttons: GET shows the confirmation
+// page, POST carries out the action.
+func (a *adapter) handleAction(w http.ResponseWriter, r *http.Request) {
+	actions := a.actions
+	logger := loggerFrom(r.Context())
+	var fields url.Values
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		fields = r.URL.Query()
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid action form", http.StatusBadRequest)
+			return
+		}
+		fields = r.PostForm
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	encoded, fingerprint, expires := fields.Get("labels"), fields.Get("fingerprint"), fields.Get("expires")
+
+	action, ok := actions.action(fields.Get("action"))
+	if !ok || !hmac.Equal([]byte(fields.Get("sig")), []byte(actions.sign(action.name, encoded, fingerprint, expires))) {
+		http.Error(w, "Invalid action link", http.StatusForbidden)
+		return
+	}
+	if unix, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().Unix() > unix {
+		http.Error(w, "This action link has expired", http.StatusGone)
+		return
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	var labels map[string]string
+	if err == nil {
+		err = json.Unmarshal(raw, &labels)
+	}
+	if err != nil || len(labels) == 0 {
+		http.Error(w, "Invalid action link", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		actions.confirm(w, action, labels, fields)
+		return
+	}
+
+	alertname := labels["alertname"]
+	comment := fmt.Sprintf("%s %s from Google Chat", action.label, alertname)
+	end := time.Now().Add(action.duration)
+	silenceID, silenceErr := actions.createSilence(labels, action.duration, comment)
+	// The mute keeps the adapter itself, and every replica sharing its state, from
+	// notifying the alert, even if Alertmanager did not take the silence.
+	muteErr := a.mutes.mute(fingerprint, action.name, end)
+	if silenceErr != nil && muteErr != nil {
+		logger.Error("Error creating silence", "action", action.name, "alertname", alertname, "err", silenceErr, "mute_err", muteErr)
+		http.Error(w, "Error creating silence in Alertmanager", http.StatusBadGateway)
+		return
+	}
+	silence := "silence " + silenceID
+	if silenceErr != nil {
+		logger.Error("Error creating silence, muting the alert in the adapter only", "action", action.name, "alertname", alertname, "err", silenceErr)
+		silence = "in the adapter only, Alertmanager did not accept the silence"
+	} else {
+		logger.Info("Created silence", "silence_id", silenceID, "action", action.name, "alertname", alertname)
+	}
+	if muteErr != nil {
+		logger.Warn("Error storing alert mute", "action", action.name, "alertname", alertname, "err", muteErr)
+	}
+	if a.escalator != nil && a.escalator.acknowledge(fingerprint) {
+		logger.Info("Stopped escalating acknowledged alert", "alertname", alertname)
+	}
+
+	until := end.UTC().Format("2006-01-02 15:04:05 MST")
+	notice := fmt.Sprintf("🔕 %s: %s on %s is silenced until %s (%s).",
+		action.label, alertname, labels["instance"], until, silence)
+	if action.name == "ack" {
+		notice = fmt.Sprintf("👀 Acknowledged: %s on %s; notifications are silenced until %s (%s).",
+			alertname, labels["instance"], until, silence)
+	}
+	if err := a.reply(r.Context(), Alert{Labels: labels, Status: "firing", Fingerprint: fingerprint}, notice); err != nil {
+		logger.Error("Error posting action notice", "action", action.name, "alertname", alertname, "err", err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html><title>%s</title><p>%s</p>", html.EscapeString(action.label), html.EscapeString(notice))
+}
+
+// confirm shows the page asking to confirm the action, whose form posts the link's
+// signed fields back to the handler.
+func (a *alertActions) confirm(w http.ResponseWriter, action silenceAction, labels map[string]string, fields url.Values) {
+	question := fmt.Sprintf("%s %s on %s for %s?", action.label, labels["alertname"], labels["instance"], action.duration)
+	if action.name == "ack" {
+		question = fmt.Sprintf("Acknowledge %s on %s? Notifications are silenced for %s.", labels["alertname"], labels["instance"], action.duration)
+	}
+	var form strings.Builder
+	for _, name := range actionFields {
+		fmt.Fprintf(&form, `<input type="hidden" name="%s" value="%s">`, name, html.EscapeString(fields.Get(name)))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Keep the page, and the form, out of caches and referrers.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	fmt.Fprintf(w, `<!DOCTYPE html><title>%s</title><p>%s</p><form method="post" action="%s">%s<button type="submit">%s</button></form>`,
+		html.EscapeString(action.label), html.EscapeString(question), html.EscapeString(a.baseURL+"/actions"), form.String(), html.EscapeString(action.label))
+}
@@ -0,0 +1,117 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdayNames maps the lower-cased English day names to their weekdays.
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// activeTime is when a route applies: on its days, during its hours, in loc.
+type activeTime struct {
+	days [7]bool
+	// hours are ranges of minutes after midnight; end < start runs past midnight.
+	hours []minuteRange
+	loc   *time.Location
+}
+
+type minuteRange struct{ start, end int }
+
+// parseActiveTime returns nil for an empty configuration, which is always active. loc
+// is used when cfg has no timezone of its own.
+func parseActiveTime(cfg ActiveTimeConfig, loc *time.Location) (*activeTime, error) {
+	if len(cfg.Weekdays) == 0 && len(cfg.Hours) == 0 {
+		return nil, nil
+	}
+	a := &activeTime{loc: loc}
+	if cfg.Timezone != "" {
+		var err error
+		if a.loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	if a.loc == nil {
+		a.loc = time.UTC
+	}
+
+	if len(cfg.Weekdays) == 0 {
+		a.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, spec := range cfg.Weekdays {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+		first, ok := weekdayNames[strings.TrimSpace(from)]
+		last, lastOK := first, true
+		if isRange {
+			last, lastOK = weekdayNames[strings.TrimSpace(to)]
+		}
+		if !ok || !lastOK {
+			return nil, fmt.Errorf("weekday %q is not a day name or a range of them such as monday:friday", spec)
+		}
+		// A range may wrap around the end of the week, e.g. friday:monday.
+		for d := first; ; d = (d + 1) % 7 {
+			a.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	for _, spec := range cfg.Hours {
+		from, to, ok := strings.Cut(spec, "-")
+		start, startErr := parseClock(from)
+		end, endErr := parseClock(to)
+		if !ok || startErr != nil || endErr != nil || start == end {
+			return nil, fmt.Errorf("hours %q is not a range such as 09:00-18:00", spec)
+		}
+		a.hours = append(a.hours, minuteRange{start: start, end: end})
+	}
+	return a, nil
+}
+
+// parseClock parses "HH:MM" (24:00 allowed as the end of the day) as minutes after
+// midnight.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+	return h*60 + m, nil
+}
+
+// contains reports whether the route applies at t. A nil activeTime always does. An
+// hours range past midnight belongs to the day it starts on.
+func (a *activeTime) contains(t time.Time) bool {
+	if a == nil {
+		return true
+	}
+	t = t.In(a.loc)
+	day, minute := t.Weekday(), t.Hour()*60+t.Minute()
+	if len(a.hours) == 0 {
+		return a.days[day]
+	}
+	for _, r := range a.hours {
+		switch {
+		case r.start < r.end:
+			if a.days[day] && minute >= r.start && minute < r.end {
+				return true
+			}
+		case minute >= r.start:
+			if a.days[day] {
+				return true
+			}
+		case minute < r.end:
+			if a.days[(day+6)%7] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,582 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// adapter turns Alertmanager webhook calls into messages for the configured output backends.
+type adapter struct {
+	// mu guards the settings that can be swapped by a config reload.
+	mu        sync.RWMutex
+	notifiers []output
+	// selector is nil unless output routes are configured.
+	selector *outputSelector
+	retry    retryPolicy
+	// enrichers annotate alerts before rendering, e.g. with DCGM health data.
+	enrichers []alertEnricher
+	// redactor is nil unless redaction rules are configured.
+	redactor *redactor
+	// filter is nil unless inbound filter rules are configured.
+	filter *alertFilter
+	// inhibitor is nil unless inhibition rules are configured.
+	inhibitor *alertInhibitor
+	// threads is kept across reloads so incident threads survive them.
+	threads *threadTracker
+	// issues is kept across reloads so open Jira issues survive them.
+	issues *jiraIssues
+	// actions is nil unless card action buttons are configured.
+	actions *alertActions
+
+	// health tracks delivery outcomes for /readyz.
+	health *healthTracker
+
+	// pool is nil unless payloads are dispatched on a bounded worker pool.
+	pool *deliveryPool
+	// queue is nil unless the durable outbound queue is enabled.
+	queue *outboundQueue
+	// deadLetters is nil unless the dead letter store is enabled.
+	deadLetters *deadLetterStore
+	// grouper is nil unless a grouping window is configured.
+	grouper *alertGrouper
+	// limiter is nil unless outbound rate limiting is configured.
+	limiter *rateLimiter
+	// dedup is nil unless duplicate suppression is configured.
+	dedup *deduplicator
+	// history is nil unless the alert history database is configured.
+	history *alertHistory
+	// audit is nil unless the audit log of outbound attempts is configured.
+	audit *auditLog
+	// slo is nil unless delivery SLO windows are configured.
+	slo *deliverySLO
+	// maintenance is nil unless maintenance windows are configured.
+	maintenance *maintenanceScheduler
+	// storm is nil unless the alert storm breaker is enabled.
+	storm *alertStorm
+	// digest is nil unless low-severity alerts are collected into digests.
+	digest *alertDigest
+	// escalator is nil unless escalation policies are configured.
+	escalator *escalator
+	// timeline is nil unless the incident timeline is enabled.
+	timeline *incidentTimeline
+	// mutes is nil unless card actions are enabled.
+	mutes *alertMutes
+	// strictPayloads rejects webhook payloads with fields unknown to their schema.
+	strictPayloads bool
+}
+
+func (a *adapter) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	payload, err := decodeAlertmanagerPayload(r.Body, a.strictPayloads)
+	if err != nil {
+		loggerFrom(r.Context()).Warn("Error decoding payload", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+	if payload.TruncatedAlerts > 0 {
+		loggerFrom(r.Context()).Warn("Alertmanager truncated the payload; raise max_alerts in its webhook_config to forward every alert", "group_key", payload.GroupKey, "truncated_alerts", payload.TruncatedAlerts)
+	}
+	a.accept(w, r, payload)
+}
+
+// accept runs a decoded payload through the pipeline and writes the response. It is
+// shared by every webhook input format.
+func (a *adapter) accept(w http.ResponseWriter, r *http.Request, payload AlertmanagerPayload) {
+	result, err := a.process(r.Context(), payload)
+	if errors.Is(err, errDeliveryBacklog) {
+		loggerFrom(r.Context()).Warn("Delivery queue is full, rejecting webhook", "group_key", payload.GroupKey)
+		http.Error(w, "Delivery queue is full", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error forwarding alert", "err", err)
+		http.Error(w, "Error forwarding alert", http.StatusInternalServerError)
+		return
+	}
+	if result == acceptedForDelivery {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	fmt.Fprint(w, result)
+}
+
+// process runs a payload through filtering, deduplication, grouping and dispatch. It
+// returns what happened to it, in the words of the webhook response.
+func (a *adapter) process(ctx context.Context, payload AlertmanagerPayload) (_ string, err error) {
+	logger := loggerFrom(ctx)
+	alertsReceived.Add(float64(len(payload.Alerts)))
+
+	for _, alert := range payload.Alerts {
+		logger.Debug("Alert received", alertAttr(alert), "labels", alert.Labels)
+	}
+
+	a.mu.RLock()
+	filter, inhibitor := a.filter, a.inhibitor
+	a.mu.RUnlock()
+	if filter != nil {
+		kept, dropped := filter.filter(payload.Alerts)
+		for _, alert := range dropped {
+			logger.Debug("Alert dropped by a filter rule", alertAttr(alert))
+		}
+		if a.history != nil && len(dropped) > 0 {
+			a.history.record(dropped, outcomeFiltered, nil)
+		}
+		if len(kept) == 0 {
+			return "Alert dropped by filter rules", nil
+		}
+		payload.Alerts = kept
+		payload.Status = combinedStatus(kept)
+	}
+
+	if inhibitor != nil {
+		// Sources are recorded before any suppression below, so an acknowledged or
+		// duplicate root cause still inhibits.
+		forward, inhibited, by := inhibitor.filter(payload.Alerts, time.Now())
+		for i, alert := range inhibited {
+			logger.Debug("Alert inhibited by a firing alert", alertAttr(alert), "source", by[i])
+		}
+		if a.history != nil && len(inhibited) > 0 {
+			a.history.record(inhibited, outcomeInhibited, nil)
+		}
+		if len(forward) == 0 {
+			return "Alert inhibited by a firing alert", nil
+		}
+		payload.Alerts = forward
+		payload.Status = combinedStatus(forward)
+	}
+
+	if a.mutes != nil {
+		forward, muted := a.mutes.filter(payload.Alerts)
+		for _, alert := range muted {
+			logger.Debug("Dropping acknowledged or silenced alert", alertAttr(alert))
+		}
+		if a.history != nil && len(muted) > 0 {
+			a.history.record(muted, outcomeMuted, nil)
+		}
+		if len(forward) == 0 {
+			return "Alert acknowledged or silenced", nil
+		}
+		payload.Alerts = forward
+		payload.Status = combinedStatus(forward)
+	}
+
+	if a.dedup != nil {
+		fresh := a.dedup.filter(payload.Alerts)
+		duplicatesSuppressed.Add(float64(len(payload.Alerts) - len(fresh)))
+		if a.history != nil && len(fresh) < len(payload.Alerts) {
+			a.history.record(duplicates(payload.Alerts, fresh), outcomeDuplicate, nil)
+		}
+		if len(fresh) == 0 {
+			return "Duplicate alert suppressed", nil
+		}
+		payload.Alerts = fresh
+		payload.Status = combinedStatus(fresh)
+		// A failed delivery makes Alertmanager retry, which must not count as a duplicate.
+		defer func() {
+			if err != nil {
+				a.dedup.release(fresh)
+			}
+		}()
+	}
+
+	if a.maintenance != nil {
+		forward, held := a.maintenance.hold(payload.Alerts)
+		if len(held) > 0 {
+			for _, alert := range held {
+				logger.Info("Holding back alert during a maintenance window", alertAttr(alert))
+			}
+			if a.history != nil {
+				a.history.record(held, outcomeMaintenance, nil)
+			}
+		}
+		if len(forward) == 0 {
+			return "Alert held during maintenance window", nil
+		}
+		payload.Alerts = forward
+	}
+
+	if a.storm != nil {
+		forward, held, started := a.storm.hold(payload.Alerts, time.Now())
+		if len(held) > 0 && a.history != nil {
+			a.history.record(held, outcomeStorm, nil)
+		}
+		if started != nil {
+			go a.postSummaries("storm", started, a.storm.startSummary)
+		}
+		if len(forward) == 0 {
+			return "Alert held during an alert storm", nil
+		}
+		payload.Alerts = forward
+	}
+
+	if a.timeline != nil {
+		// The resolution summary follows the resolved notification, posted below.
+		if update, resolved := a.timeline.observe(payload, time.Now()); resolved {
+			defer func() { go a.postTimeline(update) }()
+		}
+	}
+
+	if a.digest != nil {
+		forward, held := a.digest.hold(payload.Alerts)
+		if len(held) > 0 && a.history != nil {
+			a.history.record(held, outcomeDigest, nil)
+		}
+		if len(forward) == 0 {
+			return "Alert collected for the next digest", nil
+		}
+		payload.Alerts = forward
+	}
+
+	if a.grouper != nil {
+		// The combined message is sent when the group's window ends.
+		a.grouper.add(payload)
+		return "Alert accepted for grouping", nil
+	}
+
+	if a.pool != nil {
+		// Delivery errors are logged by the worker; Alertmanager is not asked to retry.
+		if err := a.pool.submit(ctx, payload); err != nil {
+			return "", err
+		}
+		return acceptedForDelivery, nil
+	}
+	if err := a.dispatch(ctx, payload); err != nil {
+		return "", err
+	}
+	if a.queue != nil {
+		return "Alert queued successfully", nil
+	}
+	return "Alert forwarded successfully", nil
+}
+
+// dispatch renders the payload for every backend and then either stores the messages in
+// the outbound queue or delivers them right away. Without a queue, an error is only
+// returned after all retries are exhausted, which lets Alertmanager's own retry loop take over.
+func (a *adapter) dispatch(ctx context.Context, payload AlertmanagerPayload) (err error) {
+	if a.history != nil {
+		// Deferred so the history sees the enriched annotations and the final outcome.
+		defer func() {
+			outcome := outcomeDelivered
+			if err != nil {
+				outcome = outcomeFailed
+			} else if a.queue != nil {
+				outcome = outcomeQueued
+			}
+			a.history.record(payload.Alerts, outcome, err)
+		}()
+	}
+
+	a.mu.RLock()
+	enrichers := a.enrichers
+	a.mu.RUnlock()
+	for _, e := range enrichers {
+		payload = e.enrich(payload)
+	}
+	payload = a.redact(payload)
+	if a.escalator != nil {
+		a.escalator.observe(payload.Alerts)
+	}
+
+	messages, err := a.buildMessages(ctx, payload)
+	if err != nil {
+		return fmt.Errorf("rendering message: %w", err)
+	}
+
+	if a.queue != nil {
+		// Acknowledge as soon as the messages are safely on disk; the queue drains them later.
+		if err := a.queue.enqueue(messages...); err != nil {
+			return fmt.Errorf("queueing alert: %w", err)
+		}
+		return nil
+	}
+
+	failed := 0
+	for _, m := range messages {
+		if err := a.deliver(ctx, m); err != nil {
+			slog.Error("Error forwarding message", "backend", m.Backend, "alerts", m.Alerts, "err", err)
+			a.deadLetter(m, payload.Alerts, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d message(s) could not be delivered", failed, len(messages))
+	}
+	return nil
+}
+
+// apply builds the backends for cfg and swaps them in. Requests already being processed
+// finish with the previous backends; on error the running configuration is kept.
+func (a *adapter) apply(cfg *Config) error {
+	locales, err := newLocalization(cfg.Templates)
+	if err != nil {
+		return err
+	}
+	notifiers, err := newOutputs(cfg, outputDeps{threads: a.threads, issues: a.issues, actions: a.actions, locales: locales})
+	if err != nil {
+		return err
+	}
+	enrichers, err := newEnrichers(cfg)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.notifiers = notifiers
+	a.selector = newOutputSelector(cfg.OutputRoutes)
+	a.retry = newRetryPolicy(cfg.Retry)
+	a.enrichers = enrichers
+	a.redactor = newRedactor(cfg.Redaction)
+	a.filter = newAlertFilter(cfg.Filters)
+	a.inhibitor = newAlertInhibitor(cfg.InhibitRules, a.inhibitor)
+	setSeverities(cfg.Severities)
+	return nil
+}
+
+// dispatchDigest delivers a digest built by the alert digest.
+func (a *adapter) dispatchDigest(payload AlertmanagerPayload) {
+	if err := a.dispatch(context.Background(), payload); err != nil {
+		slog.Error("Error forwarding alert digest", "err", err)
+	}
+}
+
+// redact applies the configured redaction rules, after enrichment so annotations added
+// by the adapter itself are covered too.
+func (a *adapter) redact(payload AlertmanagerPayload) AlertmanagerPayload {
+	a.mu.RLock()
+	r := a.redactor
+	a.mu.RUnlock()
+	if r == nil {
+		return payload
+	}
+	return r.redact(payload)
+}
+
+// current returns the backends and retry policy of the active configuration.
+func (a *adapter) current() ([]output, retryPolicy) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.notifiers, a.retry
+}
+
+// currentSelector returns the output routes of the active configuration.
+func (a *adapter) currentSelector() *outputSelector {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.selector
+}
+
+// buildMessages renders the payload for every enabled backend, each in its own span.
+// The messages carry ctx's trace so their delivery is part of it.
+func (a *adapter) buildMessages(ctx context.Context, payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	notifiers, _ := a.current()
+	selector := a.currentSelector()
+
+	carrier := traceCarrier(ctx)
+	var messages []notifier.Notification
+	for _, n := range notifiers {
+		selected := payload
+		if selector != nil {
+			selected.Alerts = selector.filter(payload.Alerts, n.Name())
+			if len(selected.Alerts) == 0 {
+				continue
+			}
+			selected.Status = combinedStatus(selected.Alerts)
+		}
+		selected.Alerts = sortBySeverity(selected.Alerts)
+		_, span := tracer.Start(ctx, "render "+n.Name(), trace.WithAttributes(attribute.Int("alerts", len(selected.Alerts))))
+		rendered, err := n.render(selected)
+		endSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name(), err)
+		}
+		for i := range rendered {
+			rendered[i].Trace = carrier
+		}
+		messages = append(messages, rendered...)
+	}
+	return messages, nil
+}
+
+// deliver sends a single message through its backend, retrying according to the adapter's retry policy.
+func (a *adapter) deliver(ctx context.Context, m notifier.Notification) error {
+	backend := m.Backend
+	if backend == "" {
+		backend = "gchat"
+	}
+
+	n, retry := a.backend(backend)
+	if n == nil {
+		forwardFailures.WithLabelValues(backend).Inc()
+		return fmt.Errorf("output %q is not enabled: %w", backend, errNotRetryable)
+	}
+
+	if a.limiter != nil && !a.limiter.allow(destination{backend, m.Destination}, m.Alerts) {
+		slog.Warn("Rate limit reached, suppressing message", "backend", backend, "alerts", m.Alerts)
+		alertsRateLimited.WithLabelValues(backend).Add(float64(m.Alerts))
+		if a.audit != nil {
+			a.audit.record(m, 0, 0, 0, auditRateLimited, nil)
+		}
+		return nil
+	}
+
+	ctx, span := tracer.Start(messageContext(ctx, m), "deliver "+backend, trace.WithAttributes(attribute.Int("alerts", m.Alerts)))
+	attempts, start := 0, time.Now()
+	err := retry.do(ctx, n.Name()+" post", func() error {
+		attempts++
+		return a.send(ctx, n, m, attempts)
+	})
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	endSpan(span, err)
+	if a.health != nil {
+		a.health.record(backend, err)
+	}
+	if a.slo != nil {
+		since := m.RenderedAt
+		if since.IsZero() {
+			since = start
+		}
+		a.slo.record(backend, m.Destination, time.Since(since), err)
+	}
+	if err != nil {
+		forwardFailures.WithLabelValues(backend).Inc()
+		return err
+	}
+	messagesForwarded.WithLabelValues(backend).Inc()
+	if !m.RenderedAt.IsZero() {
+		forwardLatency.WithLabelValues(backend).Observe(time.Since(m.RenderedAt).Seconds())
+	}
+	return nil
+}
+
+// send makes one attempt at posting m through n and records it in the audit log.
+func (a *adapter) send(ctx context.Context, n output, m notifier.Notification, attempt int) error {
+	if a.audit == nil {
+		return n.Send(ctx, m)
+	}
+	ctx, status := withResponseStatus(ctx)
+	start := time.Now()
+	err := n.Send(ctx, m)
+	outcome := auditDelivered
+	if err != nil {
+		outcome = auditFailed
+	}
+	a.audit.record(m, attempt, *status, time.Since(start), outcome, err)
+	return err
+}
+
+// sendText delivers a plain notice to one destination, bypassing the rate limiter.
+// Backends that cannot post plain notices are skipped.
+func (a *adapter) sendText(d destination, text string) error {
+	n, retry := a.backend(d.backend)
+	if n == nil {
+		return fmt.Errorf("output %q is not enabled", d.backend)
+	}
+	m, err := n.renderText(d.webhookURL, text)
+	if errors.Is(err, errTextUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	attempts := 0
+	return retry.do(context.Background(), n.Name()+" post", func() error {
+		attempts++
+		return a.send(context.Background(), n, m, attempts)
+	})
+}
+
+// summarizeMaintenance posts the alerts held back during a maintenance window to the
+// webhooks they would have been sent to.
+func (a *adapter) summarizeMaintenance(window string, alerts []Alert) {
+	a.postSummaries("maintenance", alerts, func(routed []Alert) string { return maintenanceSummary(window, routed) })
+}
+
+// summarizeStorm posts the alerts held back during an alert storm to the webhooks they
+// would have been sent to.
+func (a *adapter) summarizeStorm(held []Alert, notifications map[string]int, since time.Time) {
+	a.postSummaries("storm", held, func(routed []Alert) string { return a.storm.endSummary(routed, notifications, since) })
+}
+
+// postSummaries posts a plain notice about alerts to every webhook they would have been
+// sent to; summary writes the notice for the alerts of one webhook.
+func (a *adapter) postSummaries(what string, alerts []Alert, summary func(routed []Alert) string) {
+	notifiers, _ := a.current()
+	selector := a.currentSelector()
+	for _, n := range notifiers {
+		byURL := make(map[string][]Alert)
+		for _, alert := range selector.filter(alerts, n.Name()) {
+			for _, url := range n.routes(alert) {
+				byURL[url] = append(byURL[url], alert)
+			}
+		}
+		for url, routed := range byURL {
+			if err := a.sendText(destination{n.Name(), url}, summary(routed)); err != nil {
+				slog.Error("Error sending "+what+" summary", "backend", n.Name(), "err", err)
+			}
+		}
+	}
+}
+
+// backend returns the enabled notifier with the given name (nil if there is none)
+// together with the current retry policy.
+func (a *adapter) backend(name string) (output, retryPolicy) {
+	notifiers, retry := a.current()
+	for _, n := range notifiers {
+		if n.Name() == name {
+			return n, retry
+		}
+	}
+	return nil, retry
+}
+
+// shutdown stops accepting webhooks, waits for in-flight ones (and their posts) and the
+// payloads queued for the delivery workers, flushes pending groups and then delivers what is left in the outbound queue, all within ctx.
+// stopDrain and drained stop the queue's background drain so the queue is flushed from
+// here alone.
+func (a *adapter) shutdown(ctx context.Context, server *http.Server, stopDrain func(), drained <-chan struct{}) {
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Error waiting for in-flight webhooks", "err", err)
+	}
+	if a.pool != nil {
+		if err := a.pool.close(ctx); err != nil {
+			slog.Warn("Drain timeout reached while payloads waited for a delivery worker", "queued", a.pool.pending())
+		}
+	}
+	if a.grouper != nil {
+		a.grouper.flushAll()
+	}
+	if a.digest != nil {
+		a.digest.flush(a.dispatchDigest)
+	}
+	if a.queue == nil {
+		return
+	}
+
+	stopDrain()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		slog.Warn("Drain timeout reached while a queued message was being delivered", "queued", a.queue.pending())
+		return
+	}
+	if err := a.queue.flush(ctx, a.deliver); err != nil {
+		slog.Warn("Stopped flushing the outbound queue", "queued", a.queue.pending(), "err", err)
+		return
+	}
+	slog.Info("Outbound queue flushed")
+}
@@ -0,0 +1,413 @@
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"alertmanager-adapter/internal/aggregatorpb"
+)
+
+//go:generate protoc -I ../../../proto --go_out=../aggregatorpb --go_opt=paths=source_relative,Maggregator.proto=alertmanager-adapter/internal/aggregatorpb --go-grpc_out=../aggregatorpb --go-grpc_opt=paths=source_relative,Maggregator.proto=alertmanager-adapter/internal/aggregatorpb aggregator.proto
+
+// maxReportSize bounds a single NodeReport.
+const maxReportSize = 16 << 20
+
+// nodeAggregator is the central end of the gRPC service of proto/aggregator.proto: node
+// agents (gpu-collector with AGGREGATOR_URL) keep a Push stream open over mTLS and
+// report their metrics, inventory and alerts, for deployments where Prometheus cannot
+// scrape the nodes. The latest metrics of every node are served for one Prometheus
+// scrape at /api/aggregator/metrics and feed the dashboard; alerts go through the same
+// pipeline as webhooks.
+type nodeAggregator struct {
+	aggregatorpb.UnimplementedAggregatorServer
+
+	server        *grpc.Server
+	listenAddress string
+	staleAfter    time.Duration
+	process       func(context.Context, AlertmanagerPayload) (string, error)
+	// observe is given the metrics of every report; set when the dashboard is enabled.
+	observe func(node string, families map[string]*dto.MetricFamily)
+
+	mu    sync.Mutex
+	nodes map[string]*aggregatedNode
+}
+
+// aggregatedNode is a node in GET /api/aggregator/nodes.
+type aggregatedNode struct {
+	Node         string             `json:"node"`
+	AgentVersion string             `json:"agentVersion,omitempty"`
+	Address      string             `json:"address"`
+	LastReport   time.Time          `json:"lastReport"`
+	Stale        bool               `json:"stale"`
+	Inventory    *reportedInventory `json:"inventory,omitempty"`
+	families     map[string]*dto.MetricFamily
+}
+
+// nodeReport is a NodeReport converted for the pipeline and the dashboard.
+type nodeReport struct {
+	node, agentVersion string
+	timestamp          time.Time
+	families           map[string]*dto.MetricFamily
+	inventory          *reportedInventory
+	alerts             []Alert
+}
+
+// reportedInventory is a node's Inventory, in the JSON of the collector's /api/inventory.
+type reportedInventory struct {
+	Hostname      string        `json:"hostname"`
+	DriverVersion string        `json:"driverVersion,omitempty"`
+	CUDAVersion   string        `json:"cudaVersion,omitempty"`
+	GPUs          []reportedGPU `json:"gpus"`
+}
+
+type reportedGPU struct {
+	Index             int64  `json:"index"`
+	UUID              string `json:"uuid"`
+	Name              string `json:"name"`
+	Serial            string `json:"serial,omitempty"`
+	VBIOSVersion      string `json:"vbiosVersion,omitempty"`
+	MemoryTotalBytes  uint64 `json:"memoryTotalBytes,omitempty"`
+	PCIBusID          string `json:"pciBusId,omitempty"`
+	PCIeGeneration    int64  `json:"pcieGeneration,omitempty"`
+	PCIeMaxGeneration int64  `json:"pcieMaxGeneration,omitempty"`
+	PCIeWidth         int64  `json:"pcieWidth,omitempty"`
+	PCIeMaxWidth      int64  `json:"pcieMaxWidth,omitempty"`
+}
+
+// newNodeAggregator returns nil when the aggregator is disabled.
+func newNodeAggregator(cfg AggregatorConfig, process func(context.Context, AlertmanagerPayload) (string, error)) (*nodeAggregator, error) {
+	if cfg.ListenAddress == "" {
+		return nil, nil
+	}
+	tlsConfig, err := newServerTLSConfig(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("aggregator: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("aggregator: %w", err)
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	g := &nodeAggregator{
+		listenAddress: cfg.ListenAddress,
+		staleAfter:    cfg.StaleAfter,
+		process:       process,
+		nodes:         make(map[string]*aggregatedNode),
+	}
+	g.server = grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.MaxRecvMsgSize(maxReportSize),
+		// The agents ping every 30s to detect dead connections.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: 20 * time.Second, PermitWithoutStream: true}),
+	)
+	aggregatorpb.RegisterAggregatorServer(g.server, g)
+	return g, nil
+}
+
+// serve accepts agent streams until close is called.
+func (g *nodeAggregator) serve() error {
+	lis, err := net.Listen("tcp", g.listenAddress)
+	if err != nil {
+		return err
+	}
+	return g.server.Serve(lis)
+}
+
+func (g *nodeAggregator) close() {
+	g.server.Stop()
+}
+
+// Push serves Aggregator.Push: it applies every report of the stream as it arrives and
+// answers with the number of reports once the agent ends the stream. A stream without
+// a report for staleAfter is dropped.
+func (g *nodeAggregator) Push(stream aggregatorpb.Aggregator_PushServer) error {
+	aggregatorStreams.Inc()
+	defer aggregatorStreams.Dec()
+	var address string
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		address = p.Addr.String()
+	}
+
+	// Recv cannot time out, so it runs on its own; returning ends the call and with
+	// it a blocked Recv.
+	type received struct {
+		report *aggregatorpb.NodeReport
+		err    error
+	}
+	next := make(chan received)
+	go func() {
+		for {
+			report, err := stream.Recv()
+			select {
+			case next <- received{report, err}:
+			case <-stream.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	idle := time.NewTimer(g.staleAfter)
+	defer idle.Stop()
+	var reports uint64
+	for {
+		var r received
+		select {
+		case r = <-next:
+		case <-idle.C:
+			slog.Warn("Aggregator stream ended", "remote", address, "reports", reports, "err", "no report for "+g.staleAfter.String())
+			return status.Errorf(codes.DeadlineExceeded, "no report for %s", g.staleAfter)
+		}
+		if errors.Is(r.err, io.EOF) {
+			return stream.SendAndClose(&aggregatorpb.PushResponse{Reports: reports})
+		}
+		if r.err != nil {
+			slog.Warn("Aggregator stream ended", "remote", address, "reports", reports, "err", r.err)
+			return r.err
+		}
+		report, err := reportFromProto(r.report)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid NodeReport: "+err.Error())
+		}
+		g.receive(stream.Context(), report, address)
+		reports++
+		idle.Reset(g.staleAfter)
+	}
+}
+
+// receive records a report and processes its alerts. The alerts are processed before
+// the next report of the stream is read, so their order is kept.
+func (g *nodeAggregator) receive(ctx context.Context, report nodeReport, address string) {
+	aggregatorReports.Inc()
+	g.mu.Lock()
+	n, ok := g.nodes[report.node]
+	if !ok {
+		n = &aggregatedNode{Node: report.node}
+		g.nodes[report.node] = n
+		slog.Info("Node agent connected to the aggregator", "node", report.node, "remote", address)
+	}
+	n.AgentVersion, n.Address, n.LastReport = report.agentVersion, address, time.Now().UTC()
+	n.families = report.families
+	if report.inventory != nil {
+		n.Inventory = report.inventory
+	}
+	g.mu.Unlock()
+
+	if g.observe != nil {
+		g.observe(report.node, report.families)
+	}
+	if len(report.alerts) == 0 {
+		return
+	}
+	payload := AlertmanagerPayload{
+		Status:   combinedStatus(report.alerts),
+		Receiver: "aggregator",
+		GroupKey: "gpu-collector/" + report.node,
+		Alerts:   report.alerts,
+	}
+	if _, err := g.process(ctx, payload); err != nil {
+		slog.Error("Error forwarding alerts from the aggregator", "node", report.node, "alerts", len(report.alerts), "err", err)
+	}
+}
+
+// handleNodes serves GET /api/aggregator/nodes, every node that reported since the
+// start, ordered by name.
+func (g *nodeAggregator) handleNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	now := time.Now()
+	g.mu.Lock()
+	nodes := make([]aggregatedNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		c := *n
+		c.Stale = now.Sub(n.LastReport) > g.staleAfter
+		nodes = append(nodes, c)
+	}
+	g.mu.Unlock()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// handleMetrics serves GET /api/aggregator/metrics, the latest metrics of every node
+// that is not stale in the Prometheus text format, with the node as their instance
+// label. Scrape it with honor_labels: true so the instance labels are kept.
+func (g *nodeAggregator) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	now := time.Now()
+	merged := make(map[string]*dto.MetricFamily)
+	g.mu.Lock()
+	for _, n := range g.nodes {
+		if now.Sub(n.LastReport) > g.staleAfter {
+			continue
+		}
+		for name, family := range n.families {
+			m, ok := merged[name]
+			if !ok {
+				m = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type}
+				merged[name] = m
+			}
+			for _, metric := range family.Metric {
+				m.Metric = append(m.Metric, withInstance(metric, n.Node))
+			}
+		}
+	}
+	g.mu.Unlock()
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.Header().Set("Content-Type", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	for _, name := range names {
+		if _, err := expfmt.MetricFamilyToText(w, merged[name]); err != nil {
+			slog.Warn("Error writing aggregated metrics", "err", err)
+			return
+		}
+	}
+}
+
+// withInstance returns a copy of the metric with its instance label set to node.
+func withInstance(m *dto.Metric, node string) *dto.Metric {
+	c := proto.Clone(m).(*dto.Metric)
+	labels := make([]*dto.LabelPair, 0, len(c.Label)+1)
+	for _, l := range c.Label {
+		if l.GetName() != "instance" {
+			labels = append(labels, l)
+		}
+	}
+	labels = append(labels, &dto.LabelPair{Name: proto.String("instance"), Value: proto.String(node)})
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	c.Label = labels
+	return c
+}
+
+// reportFromProto converts a NodeReport of proto/aggregator.proto.
+func reportFromProto(r *aggregatorpb.NodeReport) (nodeReport, error) {
+	if r.GetNode() == "" {
+		return nodeReport{}, errors.New("node is required")
+	}
+	report := nodeReport{
+		node:         r.GetNode(),
+		agentVersion: r.GetAgentVersion(),
+		timestamp:    time.UnixMilli(r.GetTimestampMs()),
+		families:     make(map[string]*dto.MetricFamily),
+	}
+	for _, f := range r.GetMetrics() {
+		if f.GetName() != "" {
+			report.families[f.GetName()] = metricFamilyFromProto(f)
+		}
+	}
+	if inv := r.GetInventory(); inv != nil {
+		report.inventory = inventoryFromProto(inv)
+	}
+	for _, a := range r.GetAlerts() {
+		if a.GetStatus() != "firing" && a.GetStatus() != "resolved" {
+			return nodeReport{}, fmt.Errorf("alert status %q is not firing or resolved", a.GetStatus())
+		}
+		alert := Alert{
+			Status:      a.GetStatus(),
+			Labels:      a.GetLabels(),
+			Annotations: a.GetAnnotations(),
+			StartsAt:    a.GetStartsAt(),
+			EndsAt:      a.GetEndsAt(),
+		}
+		if alert.Labels == nil {
+			alert.Labels = make(map[string]string)
+		}
+		if alert.Annotations == nil {
+			alert.Annotations = make(map[string]string)
+		}
+		alert.Fingerprint = labelsFingerprint(alert.Labels)
+		report.alerts = append(report.alerts, alert)
+	}
+	return report, nil
+}
+
+// metricFamilyFromProto converts a MetricFamily into the client_model family the
+// dashboard and /api/aggregator/metrics use, with the labels of every series sorted.
+func metricFamilyFromProto(f *aggregatorpb.MetricFamily) *dto.MetricFamily {
+	family := &dto.MetricFamily{Name: proto.String(f.GetName()), Help: proto.String(f.GetHelp()), Type: dto.MetricType_UNTYPED.Enum()}
+	switch f.GetType() {
+	case aggregatorpb.MetricType_METRIC_TYPE_GAUGE:
+		family.Type = dto.MetricType_GAUGE.Enum()
+	case aggregatorpb.MetricType_METRIC_TYPE_COUNTER:
+		family.Type = dto.MetricType_COUNTER.Enum()
+	}
+	for _, s := range f.GetSeries() {
+		names := make([]string, 0, len(s.GetLabels()))
+		for name := range s.GetLabels() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		m := &dto.Metric{}
+		for _, name := range names {
+			m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(s.GetLabels()[name])})
+		}
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			m.Gauge = &dto.Gauge{Value: proto.Float64(s.GetValue())}
+		case dto.MetricType_COUNTER:
+			m.Counter = &dto.Counter{Value: proto.Float64(s.GetValue())}
+		default:
+			m.Untyped = &dto.Untyped{Value: proto.Float64(s.GetValue())}
+		}
+		family.Metric = append(family.Metric, m)
+	}
+	return family
+}
+
+// inventoryFromProto converts an Inventory and its GPUs.
+func inventoryFromProto(inv *aggregatorpb.Inventory) *reportedInventory {
+	inventory := &reportedInventory{
+		Hostname:      inv.GetHostname(),
+		DriverVersion: inv.GetDriverVersion(),
+		CUDAVersion:   inv.GetCudaVersion(),
+		GPUs:          []reportedGPU{},
+	}
+	for _, gpu := range inv.GetGpus() {
+		inventory.GPUs = append(inventory.GPUs, reportedGPU{
+			Index:             gpu.GetIndex(),
+			UUID:              gpu.GetUuid(),
+			Name:              gpu.GetName(),
+			Serial:            gpu.GetSerial(),
+			VBIOSVersion:      gpu.GetVbiosVersion(),
+			MemoryTotalBytes:  gpu.GetMemoryTotalBytes(),
+			PCIBusID:          gpu.GetPciBusId(),
+			PCIeGeneration:    gpu.GetPcieGeneration(),
+			PCIeMaxGeneration: gpu.GetPcieMaxGeneration(),
+			PCIeWidth:         gpu.GetPcieWidth(),
+			PCIeMaxWidth:      gpu.GetPcieMaxWidth(),
+		})
+	}
+	return inventory
+}
@@ -0,0 +1,172 @@
+package adapter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"alertmanager-adapter/internal/aggregatorpb"
+)
+
+// startTestAggregator serves an aggregator without TLS on an in-memory listener and
+// returns a client for it and the payloads it processed.
+func startTestAggregator(t *testing.T, staleAfter time.Duration) (*nodeAggregator, aggregatorpb.AggregatorClient, func() []AlertmanagerPayload) {
+	var mu sync.Mutex
+	var payloads []AlertmanagerPayload
+	g := &nodeAggregator{
+		staleAfter: staleAfter,
+		process: func(_ context.Context, payload AlertmanagerPayload) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			payloads = append(payloads, payload)
+			return "", nil
+		},
+		nodes: make(map[string]*aggregatedNode),
+	}
+	g.server = grpc.NewServer(grpc.MaxRecvMsgSize(maxReportSize))
+	aggregatorpb.RegisterAggregatorServer(g.server, g)
+	lis := bufconn.Listen(1 << 20)
+	go g.server.Serve(lis)
+	t.Cleanup(g.close)
+
+	conn, err := grpc.NewClient("passthrough:///aggregator",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return g, aggregatorpb.NewAggregatorClient(conn), func() []AlertmanagerPayload {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]AlertmanagerPayload(nil), payloads...)
+	}
+}
+
+func testNodeReport(node string) *aggregatorpb.NodeReport {
+	return &aggregatorpb.NodeReport{
+		Node:         node,
+		TimestampMs:  time.Now().UnixMilli(),
+		AgentVersion: "v1.2.3",
+		Metrics: []*aggregatorpb.MetricFamily{{
+			Name: "gpu_temperature_celsius",
+			Help: "GPU core temperature.",
+			Type: aggregatorpb.MetricType_METRIC_TYPE_GAUGE,
+			Series: []*aggregatorpb.Series{
+				{Labels: map[string]string{"gpu": "0", "uuid": "GPU-0"}, Value: 71},
+				{Labels: map[string]string{"gpu": "1", "uuid": "GPU-1"}, Value: 88},
+			},
+		}},
+	}
+}
+
+func TestAggregatorPush(t *testing.T) {
+	g, client, payloads := startTestAggregator(t, time.Minute)
+
+	stream, err := client.Push(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := testNodeReport("gpu-node-01")
+	first.Inventory = &aggregatorpb.Inventory{Hostname: "gpu-node-01", DriverVersion: "550.54.15", Gpus: []*aggregatorpb.GPU{{Index: 0, Uuid: "GPU-0", Name: "NVIDIA H100 80GB HBM3"}}}
+	second := testNodeReport("gpu-node-01")
+	second.Alerts = []*aggregatorpb.Alert{{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "GpuHighTemperature", "gpu": "1"},
+		Annotations: map[string]string{"summary": "GPU 1 is running hot"},
+		StartsAt:    "2026-10-15T08:00:00Z",
+	}}
+	for _, report := range []*aggregatorpb.NodeReport{first, second} {
+		if err := stream.Send(report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetReports() != 2 {
+		t.Errorf("reports %d, want 2", resp.GetReports())
+	}
+
+	got := payloads()
+	if len(got) != 1 || len(got[0].Alerts) != 1 {
+		t.Fatalf("processed %+v, want one payload with one alert", got)
+	}
+	if alert := got[0].Alerts[0]; alert.Status != "firing" || alert.Labels["alertname"] != "GpuHighTemperature" || alert.Fingerprint == "" || got[0].GroupKey != "gpu-collector/gpu-node-01" {
+		t.Errorf("processed alert %+v of %q", alert, got[0].GroupKey)
+	}
+
+	g.mu.Lock()
+	n := g.nodes["gpu-node-01"]
+	g.mu.Unlock()
+	if n == nil || n.AgentVersion != "v1.2.3" || n.Inventory == nil || len(n.Inventory.GPUs) != 1 || n.Inventory.GPUs[0].UUID != "GPU-0" {
+		t.Fatalf("node %+v, want the reported version and inventory", n)
+	}
+
+	rec := httptest.NewRecorder()
+	g.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/aggregator/metrics", nil))
+	for _, want := range []string{
+		"# TYPE gpu_temperature_celsius gauge",
+		`gpu_temperature_celsius{gpu="0",instance="gpu-node-01",uuid="GPU-0"} 71`,
+		`gpu_temperature_celsius{gpu="1",instance="gpu-node-01",uuid="GPU-1"} 88`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics lack %s:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestAggregatorRejectsInvalidReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		report *aggregatorpb.NodeReport
+	}{
+		{"without node", testNodeReport("")},
+		{"invalid alert status", &aggregatorpb.NodeReport{Node: "gpu-node-01", Alerts: []*aggregatorpb.Alert{{Status: "pending"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client, payloads := startTestAggregator(t, time.Minute)
+			stream, err := client.Push(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Send(tt.report); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("CloseAndRecv() = %v, want InvalidArgument", err)
+			}
+			if got := payloads(); len(got) != 0 {
+				t.Errorf("processed %+v", got)
+			}
+		})
+	}
+}
+
+func TestAggregatorDropsSilentStreams(t *testing.T) {
+	_, client, _ := startTestAggregator(t, 50*time.Millisecond)
+	stream, err := client.Push(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(testNodeReport("gpu-node-01")); err != nil {
+		t.Fatal(err)
+	}
+	var resp aggregatorpb.PushResponse
+	if err := stream.RecvMsg(&resp); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("RecvMsg() = %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,241 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+)
+
+// Outcomes of the attempts recorded in the audit log.
+const (
+	auditDelivered   = "delivered"
+	auditFailed      = "failed"
+	auditRateLimited = "rate_limited"
+)
+
+const auditSchema = `
+CREATE TABLE IF NOT EXISTS notification_attempts (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	attempted_at INTEGER NOT NULL,
+	backend      TEXT NOT NULL,
+	destination  TEXT NOT NULL,
+	payload_hash TEXT NOT NULL,
+	alerts       INTEGER NOT NULL,
+	attempt      INTEGER NOT NULL,
+	status_code  INTEGER NOT NULL,
+	latency_ms   INTEGER NOT NULL,
+	outcome      TEXT NOT NULL,
+	error        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS notification_attempts_attempted_at ON notification_attempts (attempted_at);
+`
+
+// auditLog records every outbound notification attempt in a SQLite file, for "why
+// didn't we get paged" investigations after an incident. Rows are only ever inserted,
+// and deleted once they are older than the retention.
+type auditLog struct {
+	db        *sql.DB
+	retention time.Duration
+	token     string
+}
+
+// auditRecord is one attempt as returned by the query API. Destination never holds a
+// webhook's secret path or an API key: URLs are reduced to their host and other
+// destinations except email recipients to a short hash.
+type auditRecord struct {
+	ID          int64     `json:"id"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	Backend     string    `json:"backend"`
+	Destination string    `json:"destination"`
+	PayloadHash string    `json:"payloadHash"`
+	Alerts      int       `json:"alerts"`
+	// Attempt is 1 for the first try; later attempts are retries.
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMS  int64  `json:"latencyMs"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+func openAuditLog(cfg AuditConfig) (*auditLog, error) {
+	db, err := sql.Open("sqlite", cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serializing on one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(auditSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating audit log schema: %w", err)
+	}
+	return &auditLog{db: db, retention: cfg.Retention, token: cfg.Token}, nil
+}
+
+func (l *auditLog) close() error {
+	return l.db.Close()
+}
+
+// record stores one attempt. Failures are only logged: the audit log must never stand
+// in the way of forwarding alerts.
+func (l *auditLog) record(m notifier.Notification, attempt, statusCode int, latency time.Duration, outcome string, attemptErr error) {
+	backend := backendName(m)
+	errText := ""
+	if attemptErr != nil {
+		errText = hideDestination(attemptErr.Error(), m.Destination)
+	}
+	hash := sha256.Sum256(m.Body)
+	_, err := l.db.Exec(`INSERT INTO notification_attempts (attempted_at, backend, destination, payload_hash, alerts,
+		attempt, status_code, latency_ms, outcome, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UnixMilli(), backend, auditDestination(backend, m.Destination), hex.EncodeToString(hash[:]),
+		m.Alerts, attempt, statusCode, latency.Milliseconds(), outcome, errText)
+	if err != nil {
+		slog.Error("Error recording notification attempt in the audit log", "err", err)
+	}
+}
+
+// auditDestination returns what the audit log shows of a destination.
+func auditDestination(backend, destination string) string {
+	if host := destinationHost(destination); host != "" {
+		return host
+	}
+	if backend == "email" {
+		return destination
+	}
+	hash := sha256.Sum256([]byte(destination))
+	return "sha256:" + hex.EncodeToString(hash[:6])
+}
+
+// run deletes the attempts older than the retention every hour; without a retention
+// the log is kept forever.
+func (l *auditLog) run() {
+	if l.retention <= 0 {
+		return
+	}
+	for {
+		result, err := l.db.Exec(`DELETE FROM notification_attempts WHERE attempted_at < ?`, time.Now().Add(-l.retention).UnixMilli())
+		if err != nil {
+			slog.Error("Error pruning the audit log", "err", err)
+		} else if n, _ := result.RowsAffected(); n > 0 {
+			slog.Debug("Pruned the audit log", "deleted", n)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// auditQuery filters the audit log. Empty fields match everything.
+type auditQuery struct {
+	Backend string
+	Outcome string
+	Since   time.Time
+	Limit   int
+}
+
+// query returns matching attempts, newest first.
+func (l *auditLog) query(q auditQuery) ([]auditRecord, error) {
+	stmt := `SELECT id, attempted_at, backend, destination, payload_hash, alerts, attempt, status_code, latency_ms, outcome, error
+		FROM notification_attempts WHERE attempted_at >= ?`
+	args := []any{q.Since.UnixMilli()}
+	if q.Backend != "" {
+		stmt += " AND backend = ?"
+		args = append(args, q.Backend)
+	}
+	if q.Outcome != "" {
+		stmt += " AND outcome = ?"
+		args = append(args, q.Outcome)
+	}
+	stmt += " ORDER BY id DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := l.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []auditRecord{}
+	for rows.Next() {
+		var r auditRecord
+		var attemptedAt int64
+		if err := rows.Scan(&r.ID, &attemptedAt, &r.Backend, &r.Destination, &r.PayloadHash, &r.Alerts,
+			&r.Attempt, &r.StatusCode, &r.LatencyMS, &r.Outcome, &r.Error); err != nil {
+			return nil, err
+		}
+		r.AttemptedAt = time.UnixMilli(attemptedAt).UTC()
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// handleAudit serves GET /api/audit. Supported parameters: backend, outcome (delivered,
+// failed or rate_limited), since (a duration such as "24h", default 24h) and limit
+// (default 100, at most 1000).
+func (l *auditLog) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if l.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+l.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	q := auditQuery{
+		Backend: strings.ToLower(params.Get("backend")),
+		Outcome: params.Get("outcome"),
+		Since:   time.Now().Add(-24 * time.Hour),
+		Limit:   100,
+	}
+	if since := params.Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid since duration", http.StatusBadRequest)
+			return
+		}
+		q.Since = time.Now().Add(-d)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	records, err := l.query(q)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error querying the audit log", "err", err)
+		http.Error(w, "Error querying the audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+type responseStatusKey struct{}
+
+// withResponseStatus returns a context in which postJSONWithHeader stores the status
+// code of the response, so the audit log can record it for successful posts too.
+func withResponseStatus(ctx context.Context) (context.Context, *int) {
+	status := new(int)
+	return context.WithValue(ctx, responseStatusKey{}, status), status
+}
+
+// setResponseStatus stores code in the status of a context from withResponseStatus.
+func setResponseStatus(ctx context.Context, code int) {
+	if status, ok := ctx.Value(responseStatusKey{}).(*int); ok {
+		*status = code
+	}
+}
@@ -0,0 +1,56 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// webhookAuth requires HTTP basic auth or a bearer token on incoming webhooks, as sent by
+// the http_config of Alertmanager's webhook_config (basic_auth, or authorization with the
+// default Bearer type). When both are configured, either is accepted.
+type webhookAuth struct {
+	username, password string
+	token              string
+}
+
+// newWebhookAuth returns nil when no credentials are configured.
+func newWebhookAuth(cfg AuthConfig) *webhookAuth {
+	if cfg.Username == "" && cfg.BearerToken == "" {
+		return nil
+	}
+	return &webhookAuth{username: cfg.Username, password: cfg.Password, token: cfg.BearerToken}
+}
+
+// wrap returns a handler that rejects requests without valid credentials with 401.
+func (a *webhookAuth) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorized(r) {
+			loggerFrom(r.Context()).Warn("Rejecting webhook without valid credentials", "remote_addr", r.RemoteAddr)
+			if a.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="alertmanager-adapter"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeWebhookError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *webhookAuth) authorized(r *http.Request) bool {
+	if a.username != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			// Both comparisons always run so the response time does not tell which one failed.
+			userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.username))
+			passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.password))
+			return userOK&passOK == 1
+		}
+	}
+	if a.token != "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		return ok && strings.EqualFold(scheme, "Bearer") && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+	}
+	return false
+}
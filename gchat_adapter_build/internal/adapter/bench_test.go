@@ -0,0 +1,221 @@
+package adapter
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchSink stands in for the backends: it reads every post, decompressing gzip bodies,
+// and counts the connections clients opened.
+type benchSink struct {
+	connections atomic.Int64
+}
+
+func (s *benchSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, "{}")
+}
+
+// startBenchSink serves a benchSink until the benchmark ends.
+func startBenchSink(b *testing.B) (*benchSink, string) {
+	sink := &benchSink{}
+	server := httptest.NewUnstartedServer(sink)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			sink.connections.Add(1)
+		}
+	}
+	server.Start()
+	b.Cleanup(server.Close)
+	return sink, server.URL + "/v1/spaces/bench/messages"
+}
+
+// newBenchAdapter returns an adapter that renders Google Chat cards for webhookURL with
+// the default config.
+func newBenchAdapter(b *testing.B, webhookURL string) *adapter {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cfg := defaultConfig()
+	cfg.Outputs = []string{"gchat"}
+	cfg.GoogleChat.WebhookConfig = WebhookConfig{WebhookURL: webhookURL}
+	cfg.GoogleChat.ThreadBy = ""
+	cfg.GoogleChat.OnCall = OnCallConfig{}
+	a, err := newOfflineAdapter(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return a
+}
+
+// BenchmarkRender renders a payload of ten alerts as Google Chat messages.
+func BenchmarkRender(b *testing.B) {
+	a := newBenchAdapter(b, "http://127.0.0.1/v1/spaces/bench/messages")
+	payload := benchPayload(0, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := a.buildMessages(context.Background(), payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncode encodes a rendered card with the pooled encoders, and with
+// json.Marshal for comparison.
+func BenchmarkEncode(b *testing.B) {
+	a := newBenchAdapter(b, "http://127.0.0.1/v1/spaces/bench/messages")
+	messages, err := a.buildMessages(context.Background(), benchPayload(0, 10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var card GoogleChatCard
+	if err := json.Unmarshal(messages[0].Body, &card); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := newNotification("gchat", "bench", 10, card); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := json.Marshal(card); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkSend posts a rendered message to a local sink from parallel goroutines, like
+// the delivery workers, uncompressed and gzip-compressed: "encoded" posts the body of a
+// notification, "streamed" encodes the card while it is sent, like the API clients.
+// conns/op shows whether the connection pool keeps up.
+func BenchmarkSend(b *testing.B) {
+	a := newBenchAdapter(b, "http://127.0.0.1/v1/spaces/bench/messages")
+	messages, err := a.buildMessages(context.Background(), benchPayload(0, 10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	body := messages[0].Body
+	var card GoogleChatCard
+	if err := json.Unmarshal(body, &card); err != nil {
+		b.Fatal(err)
+	}
+
+	posts := map[string]func(webhookURL string) error{
+		"encoded": func(webhookURL string) error {
+			return postJSON(context.Background(), webhookURL, body)
+		},
+		"streamed": func(webhookURL string) error {
+			req, err := newOutboundJSONRequest(context.Background(), http.MethodPost, webhookURL, card)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := outboundClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer drainAndClose(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				return &webhookStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+			}
+			return nil
+		},
+	}
+
+	defer setupHTTPClient(defaultConfig().HTTPClient)
+	for _, mode := range []string{"encoded", "streamed"} {
+		post := posts[mode]
+		for _, compression := range []string{"", "gzip"} {
+			name := compression
+			if name == "" {
+				name = "plain"
+			}
+			b.Run(mode+"/"+name, func(b *testing.B) {
+				sink, webhookURL := startBenchSink(b)
+				cfg := defaultConfig().HTTPClient
+				cfg.Compression = compression
+				cfg.MaxIdleConnsPerHost = 64
+				if err := setupHTTPClient(cfg); err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(body)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						if err := post(webhookURL); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+				b.ReportMetric(float64(sink.connections.Load())/float64(b.N), "conns/op")
+			})
+		}
+	}
+}
+
+// benchPayload returns a firing payload of n alerts spread over GPU nodes, with the
+// labels and annotations of the collector's alerts.
+func benchPayload(first, n int) AlertmanagerPayload {
+	payload := AlertmanagerPayload{
+		Version:     "4",
+		GroupKey:    "bench/" + strconv.Itoa(first),
+		Status:      "firing",
+		Receiver:    "bench",
+		GroupLabels: map[string]string{"alertname": "GpuHighTemperature"},
+		ExternalURL: "http://alertmanager.example.com",
+	}
+	startsAt := time.Now().UTC().Format(time.RFC3339)
+	for i := first; i < first+n; i++ {
+		node := fmt.Sprintf("gpu-node-%03d", i/8)
+		payload.Alerts = append(payload.Alerts, Alert{
+			Status: "firing",
+			Labels: map[string]string{
+				"alertname": "GpuHighTemperature",
+				"severity":  []string{"critical", "warning", "info"}[i%3],
+				"instance":  node + ":9500",
+				"gpu":       strconv.Itoa(i % 8),
+				"uuid":      fmt.Sprintf("GPU-%08x-bench", i),
+			},
+			Annotations: map[string]string{
+				"summary":     "GPU " + strconv.Itoa(i%8) + " on " + node + " is running hot",
+				"description": "The GPU core temperature has been above 85°C for 5 minutes; check the node's cooling and the workload's power draw.",
+			},
+			StartsAt:     startsAt,
+			GeneratorURL: "http://prometheus.example.com/graph?g0.expr=gpu_temperature_celsius",
+			Fingerprint:  fmt.Sprintf("%016x", i),
+		})
+	}
+	return payload
+}
@@ -0,0 +1,196 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CardV2 is a single entry of the cardsV2 array in a Google Chat message.
+type CardV2 struct {
+	CardID string `json:"cardId"`
+	Card   Card   `json:"card"`
+}
+
+// Card is the body of a Google Chat card.
+type Card struct {
+	Header   *CardHeader   `json:"header,omitempty"`
+	Sections []CardSection `json:"sections,omitempty"`
+}
+
+// CardHeader is the title block shown at the top of a card.
+type CardHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+// CardSection groups widgets under an optional header.
+type CardSection struct {
+	Header  string       `json:"header,omitempty"`
+	Widgets []CardWidget `json:"widgets"`
+}
+
+// CardWidget holds exactly one of the supported widget types.
+type CardWidget struct {
+	DecoratedText *DecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *TextParagraph `json:"textParagraph,omitempty"`
+	ButtonList    *ButtonList    `json:"buttonList,omitempty"`
+}
+
+// DecoratedText is a key/value style widget.
+type DecoratedText struct {
+	TopLabel string `json:"topLabel,omitempty"`
+	Text     string `json:"text"`
+	WrapText bool   `json:"wrapText,omitempty"`
+}
+
+// TextParagraph is a block of (lightly HTML formatted) text.
+type TextParagraph struct {
+	Text string `json:"text"`
+}
+
+// ButtonList is a row of buttons.
+type ButtonList struct {
+	Buttons []CardButton `json:"buttons"`
+}
+
+// CardButton is a button that opens a link when clicked.
+type CardButton struct {
+	Text    string      `json:"text"`
+	OnClick CardOnClick `json:"onClick"`
+}
+
+// CardOnClick is the action of a button.
+type CardOnClick struct {
+	OpenLink *CardOpenLink `json:"openLink,omitempty"`
+}
+
+// CardOpenLink opens URL in a new browser tab.
+type CardOpenLink struct {
+	URL string `json:"url"`
+}
+
+// buildCards renders one card per alert in the payload, localized by l. Alerts get
+// runbook and dashboard buttons when links is non-nil, and firing alerts action buttons
+// when actions is non-nil.
+func buildCards(payload AlertmanagerPayload, l *localizer, links *linkButtons, actions *alertActions) ([]CardV2, error) {
+	cards := make([]CardV2, 0, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		card := buildAlertCard(i, alert, payload.Status, l)
+		if links != nil {
+			if buttons, ok := links.widget(alert); ok {
+				card.Card.Sections = append(card.Card.Sections, CardSection{Widgets: []CardWidget{buttons}})
+			}
+		}
+		if status, _, _ := alertAppearance(alert, payload.Status); actions != nil && status == "firing" {
+			buttons, err := actions.buttons(alert)
+			if err != nil {
+				return nil, err
+			}
+			card.Card.Sections = append(card.Card.Sections, CardSection{Widgets: []CardWidget{buttons}})
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+func buildAlertCard(index int, alert Alert, payloadStatus string, l *localizer) CardV2 {
+	severity := alert.Labels["severity"]
+	status, color, icon := alertAppearance(alert, payloadStatus)
+
+	subtitle := strings.ToUpper(l.translate(status))
+	if severity != "" {
+		subtitle = fmt.Sprintf("%s · %s", strings.ToUpper(l.translate(severity)), subtitle)
+	}
+
+	widgets := []CardWidget{
+		{TextParagraph: &TextParagraph{
+			Text: fmt.Sprintf(`<font color="%s"><b>%s %s</b></font>`, color, icon, subtitle),
+		}},
+	}
+	widgets = appendDecoratedText(widgets, l.translate("Instance"), alert.Labels["instance"])
+	widgets = appendDecoratedText(widgets, l.translate("GPU"), gpuDescription(alert.Labels))
+	widgets = appendDecoratedText(widgets, l.translate("Workload"), workloadDescription(alert.Labels))
+	widgets = appendDecoratedText(widgets, l.translate("SLURM job"), slurmJobDescription(alert.Labels))
+	widgets = appendDecoratedText(widgets, l.translate("Summary"), alert.Annotations["summary"])
+	widgets = appendDecoratedText(widgets, l.translate("Node"), alert.Annotations[nodeInfoAnnotation])
+	widgets = appendDecoratedText(widgets, l.translate("GPU health"), alert.Annotations[gpuHealthAnnotation])
+	widgets = appendDecoratedText(widgets, l.translate("Top processes"), alert.Annotations[topProcessesAnnotation])
+	widgets = appendDecoratedText(widgets, l.translate("Throttling"), alert.Annotations[throttleReasonsAnnotation])
+	widgets = appendDecoratedText(widgets, l.translate("Chassis"), alert.Annotations[chassisAnnotation])
+
+	sections := []CardSection{{Widgets: widgets}}
+
+	var times []CardWidget
+	times = appendDecoratedText(times, l.translate("Started"), l.formatTime(alert.StartsAt))
+	times = appendDecoratedText(times, l.translate("Ended"), l.formatTime(alert.EndsAt))
+	if len(times) > 0 {
+		sections = append(sections, CardSection{Header: l.translate("Timeline"), Widgets: times})
+	}
+
+	return CardV2{
+		CardID: fmt.Sprintf("alert-%d", index),
+		Card: Card{
+			Header: &CardHeader{
+				Title:    alert.Labels["alertname"],
+				Subtitle: subtitle,
+			},
+			Sections: sections,
+		},
+	}
+}
+
+// appendDecoratedText adds a key/value widget, skipping empty values.
+func appendDecoratedText(widgets []CardWidget, label, text string) []CardWidget {
+	if text == "" {
+		return widgets
+	}
+	return append(widgets, CardWidget{DecoratedText: &DecoratedText{
+		TopLabel: label,
+		Text:     text,
+		WrapText: true,
+	}})
+}
+
+// gpuDescription combines the DCGM exporter GPU labels into a single line.
+func gpuDescription(labels map[string]string) string {
+	var parts []string
+	if gpu := labels["gpu"]; gpu != "" {
+		parts = append(parts, "GPU "+gpu)
+	}
+	if model := labels["modelName"]; model != "" {
+		parts = append(parts, model)
+	}
+	if uuid := labels["UUID"]; uuid != "" {
+		parts = append(parts, "("+uuid+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// workloadDescription names the Kubernetes workload of the pod, namespace and container
+// labels, e.g. "ml/train-7f9c (container trainer)"; it is "" for alerts without a pod.
+func workloadDescription(labels map[string]string) string {
+	pod := labels["pod"]
+	if pod == "" {
+		return ""
+	}
+	if namespace := labels["namespace"]; namespace != "" {
+		pod = namespace + "/" + pod
+	}
+	if container := labels["container"]; container != "" {
+		pod += " (container " + container + ")"
+	}
+	return pod
+}
+
+// slurmJobDescription names the SLURM job of the slurm_job_id and user labels, e.g.
+// "4242 (user alice)"; it is "" for alerts without a job.
+func slurmJobDescription(labels map[string]string) string {
+	job := labels["slurm_job_id"]
+	if job == "" {
+		return ""
+	}
+	if user := labels["user"]; user != "" {
+		job += " (user " + user + ")"
+	}
+	return job
+}
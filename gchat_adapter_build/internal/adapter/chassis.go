@@ -0,0 +1,133 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// chassisAnnotation is the annotation summarizing the alerting node's chassis fans,
+// power supplies and inlet temperature; the rich message formats display it as
+// "Chassis".
+const chassisAnnotation = "chassis_sensors"
+
+// chassisEnricher looks up the alerting node's gpu-collector and annotates GPU thermal
+// alerts with the chassis sensors read from the node's BMC, so an overheating GPU shows
+// e.g. "fan FAN3 failed" right next to it.
+type chassisEnricher struct {
+	urlTemplate string
+	alertnames  *regexp.Regexp
+	client      *http.Client
+}
+
+// chassisState is the chassis sensors of one node.
+type chassisState struct {
+	failedFans, failedPSUs []string
+	// inletCelsius is the warmest inlet reading; hasInlet is false without inlet sensors.
+	inletCelsius float64
+	hasInlet     bool
+	sensors      int
+}
+
+// newChassisEnricher returns nil when no collector URL is configured. The alert name
+// pattern has already been checked by Config.validate.
+func newChassisEnricher(cfg ChassisConfig) *chassisEnricher {
+	if cfg.CollectorURL == "" {
+		return nil
+	}
+	return &chassisEnricher{
+		urlTemplate: cfg.CollectorURL,
+		alertnames:  regexp.MustCompile(cfg.AlertPattern),
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (e *chassisEnricher) enrich(payload AlertmanagerPayload) AlertmanagerPayload {
+	cache := make(map[string]*chassisState)
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alerts[i] = alert
+		if alert.Status == "resolved" || !e.alertnames.MatchString(alert.Labels["alertname"]) {
+			continue
+		}
+
+		url := nodeURL(e.urlTemplate, alert.Labels["instance"])
+		if url == "" {
+			continue
+		}
+		state, ok := cache[url]
+		if !ok {
+			var err error
+			state, err = e.fetch(url)
+			if err != nil {
+				slog.Warn("Error fetching chassis sensors", "url", url, "err", err)
+			}
+			cache[url] = state
+		}
+
+		if summary := state.summary(); summary != "" {
+			alerts[i] = withAnnotations(alert, map[string]string{chassisAnnotation: summary})
+		}
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (e *chassisEnricher) fetch(url string) (*chassisState, error) {
+	families, err := scrapeMetrics(e.client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &chassisState{}
+	for _, m := range families["chassis_fan_healthy"].GetMetric() {
+		state.sensors++
+		if metricValue(m) == 0 {
+			state.failedFans = append(state.failedFans, metricLabels(m)["sensor"])
+		}
+	}
+	for _, m := range families["chassis_psu_healthy"].GetMetric() {
+		state.sensors++
+		if metricValue(m) == 0 {
+			state.failedPSUs = append(state.failedPSUs, metricLabels(m)["sensor"])
+		}
+	}
+	for _, m := range families["chassis_temperature_celsius"].GetMetric() {
+		if metricLabels(m)["inlet"] != "true" {
+			continue
+		}
+		state.sensors++
+		if v := metricValue(m); !state.hasInlet || v > state.inletCelsius {
+			state.inletCelsius, state.hasInlet = v, true
+		}
+	}
+	sort.Strings(state.failedFans)
+	sort.Strings(state.failedPSUs)
+	return state, nil
+}
+
+// summary describes the state, e.g. "fan FAN3 failed · inlet 31°C" or
+// "fans and PSUs OK · inlet 24°C". It is "" for nodes whose collector does not read
+// the chassis sensors.
+func (s *chassisState) summary() string {
+	if s == nil || s.sensors == 0 {
+		return ""
+	}
+	var parts []string
+	for _, fan := range s.failedFans {
+		parts = append(parts, "fan "+fan+" failed")
+	}
+	for _, psu := range s.failedPSUs {
+		parts = append(parts, "PSU "+psu+" failed")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "fans and PSUs OK")
+	}
+	if s.hasInlet {
+		parts = append(parts, fmt.Sprintf("inlet %.0f°C", s.inletCelsius))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,1963 @@
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every adapter setting. It is read from the YAML file given with -config;
+// without one, the legacy environment variables are used instead (see configFromEnv).
+// Durations use Go syntax, e.g. "500ms" or "30s".
+type Config struct {
+	// ListenAddress is the address of the webhook listener. Changing it requires a restart.
+	ListenAddress string `yaml:"listenAddress"`
+	// Log configures the structured logs; changing the format requires a restart.
+	Log LogConfig `yaml:"log"`
+	// Outputs lists the enabled backends: gchat, slack, teams, discord, email, pagerduty,
+	// opsgenie, victorops, telegram, http, jira.
+	Outputs []string `yaml:"outputs"`
+	// OutputRoutes pick the backends an alert is sent to by its labels. Alerts matching
+	// no route go to every enabled backend.
+	OutputRoutes []OutputRouteConfig `yaml:"outputRoutes"`
+
+	// Templates configures the helper functions of the message and email templates.
+	Templates TemplatesConfig `yaml:"templates"`
+	// Severities set the icon, color and order of each severity label value, from the
+	// most to the least severe; empty keeps critical, warning and info.
+	Severities []SeverityConfig `yaml:"severities"`
+
+	GoogleChat GoogleChatConfig `yaml:"googleChat"`
+	Slack      WebhookConfig    `yaml:"slack"`
+	Teams      WebhookConfig    `yaml:"teams"`
+	Discord    WebhookConfig    `yaml:"discord"`
+	Email      EmailConfig      `yaml:"email"`
+	PagerDuty  PagerDutyConfig  `yaml:"pagerduty"`
+	Opsgenie   OpsgenieConfig   `yaml:"opsgenie"`
+	VictorOps  VictorOpsConfig  `yaml:"victorops"`
+	Telegram   TelegramConfig   `yaml:"telegram"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	Jira       JiraConfig       `yaml:"jira"`
+
+	Retry RetryConfig `yaml:"retry"`
+	// HTTPClient tunes the client posting to the backends. Changing it requires a restart.
+	HTTPClient HTTPClientConfig `yaml:"httpClient"`
+	// QueuePath enables the durable outbound queue. Changing it requires a restart.
+	QueuePath string `yaml:"queuePath"`
+	// Workers dispatches webhooks on a bounded pool after answering them. Changing it
+	// requires a restart.
+	Workers WorkersConfig `yaml:"workers"`
+	// GroupWindow batches alerts by group key. Changing it requires a restart.
+	GroupWindow time.Duration   `yaml:"groupWindow"`
+	Signature   SignatureConfig `yaml:"signature"`
+	// Auth requires credentials on incoming webhooks. Changing it requires a restart.
+	Auth AuthConfig `yaml:"auth"`
+	// Requests limits and validates incoming webhooks. Changing it requires a restart.
+	Requests RequestsConfig `yaml:"requests"`
+
+	DCGM      DCGMConfig      `yaml:"dcgm"`
+	Processes ProcessesConfig `yaml:"processes"`
+	Throttle  ThrottleConfig  `yaml:"throttle"`
+	Chassis   ChassisConfig   `yaml:"chassis"`
+	// NodeMetadata annotates alerts with the alerting node's location, owner and model.
+	NodeMetadata NodeMetadataConfig `yaml:"nodeMetadata"`
+	// DedupTTL suppresses alerts re-sent with an unchanged status within this duration.
+	// Changing it requires a restart.
+	DedupTTL time.Duration `yaml:"dedupTTL"`
+	// IdempotencyTTL is how long a processed webhook is remembered, so a retry of it is
+	// answered without posting again; 0, the default, disables it. Changing it requires a
+	// restart.
+	IdempotencyTTL time.Duration `yaml:"idempotencyTTL"`
+	// DrainTimeout bounds how long shutdown waits for in-flight webhooks, pending groups
+	// and the outbound queue. Changing it requires a restart.
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+	// HistoryPath is the SQLite file recording every received alert; empty disables the
+	// history and its query API. Changing it requires a restart.
+	HistoryPath string `yaml:"historyPath"`
+	// DeadLetter keeps messages that could not be delivered after all retries. Changing
+	// it requires a restart.
+	DeadLetter DeadLetterConfig `yaml:"deadLetter"`
+	// Audit records every outbound notification attempt. Changing it requires a restart.
+	Audit AuditConfig `yaml:"audit"`
+	// SLO tracks delivery success and latency over rolling windows and reports them.
+	// Changing it requires a restart.
+	SLO SLOConfig `yaml:"slo"`
+	// Actions adds acknowledge/silence buttons to Google Chat cards. Changing it
+	// requires a restart.
+	Actions ActionsConfig `yaml:"actions"`
+	// Filters drop or downgrade matching alerts as soon as they are received.
+	Filters []FilterRuleConfig `yaml:"filters"`
+	// InhibitRules suppress alerts while a related alert fires, e.g. a node's GPU alerts
+	// while the node is down.
+	InhibitRules []InhibitRuleConfig `yaml:"inhibitRules"`
+	// Redaction strips or masks labels and annotations before alerts are rendered.
+	Redaction []RedactionConfig `yaml:"redaction"`
+	// Readiness tunes /readyz. Changing it requires a restart.
+	Readiness ReadinessConfig `yaml:"readiness"`
+	// SharedState keeps dedup and thread state in Redis so several replicas can run
+	// behind one Service. Changing it requires a restart.
+	SharedState SharedStateConfig `yaml:"sharedState"`
+	// Dashboard enables the web dashboard at /dashboard. Changing it requires a restart.
+	Dashboard DashboardConfig `yaml:"dashboard"`
+	// SilenceAPI enables /api/silence. Changing it requires a restart.
+	SilenceAPI SilenceAPIConfig `yaml:"silenceAPI"`
+	// Preview enables /api/preview. Changing it requires a restart.
+	Preview PreviewConfig `yaml:"preview"`
+	// ConfigAPI enables /api/routes and /api/templates. Changing it requires a restart.
+	ConfigAPI ConfigAPIConfig `yaml:"configAPI"`
+	// Maintenance lists recurring windows during which matching alerts are held back.
+	// Changing it requires a restart.
+	Maintenance []MaintenanceWindowConfig `yaml:"maintenance"`
+	// Tracing exports OpenTelemetry spans. Changing it requires a restart.
+	Tracing TracingConfig `yaml:"tracing"`
+	// Digest collects low-severity alerts into one message per interval. Changing it
+	// requires a restart.
+	Digest DigestConfig `yaml:"digest"`
+	// Storm holds back alerts during alert storms. Changing it requires a restart.
+	Storm StormConfig `yaml:"storm"`
+	// RateLimit applies per destination webhook. Changing it requires a restart.
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+	// Timeline posts still-firing updates and resolution summaries per incident.
+	// Changing it requires a restart.
+	Timeline TimelineConfig `yaml:"timeline"`
+	// Escalation re-notifies about alerts nobody acknowledged. Changing it requires a
+	// restart.
+	Escalation []EscalationPolicyConfig `yaml:"escalation"`
+	// SNMP receives traps from PDUs, switches and BMCs. Changing it requires a restart.
+	SNMP SNMPConfig `yaml:"snmp"`
+	// Kubernetes receives Events and node conditions on /kubernetes. Changing it
+	// requires a restart.
+	Kubernetes KubernetesConfig `yaml:"kubernetes"`
+	// Zabbix receives Zabbix webhook media type notifications on /zabbix. Changing it
+	// requires a restart.
+	Zabbix ZabbixConfig `yaml:"zabbix"`
+	// Nagios receives Nagios and Icinga notification commands on /nagios. Changing it
+	// requires a restart.
+	Nagios NagiosConfig `yaml:"nagios"`
+	// VersionDrift alerts on nodes whose driver or CUDA version differs from the fleet.
+	// Changing it requires a restart.
+	VersionDrift VersionDriftConfig `yaml:"versionDrift"`
+	// Heartbeat alerts on nodes that stop sending heartbeats. Changing it requires a
+	// restart.
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+	// Aggregator receives the metrics, inventory and alerts node agents stream over
+	// gRPC. Changing it requires a restart.
+	Aggregator AggregatorConfig `yaml:"aggregator"`
+}
+
+// LogConfig selects the log level and output format.
+type LogConfig struct {
+	// Level is debug, info (default), warn or error.
+	Level string `yaml:"level"`
+	// Format is "json" (default) or "text".
+	Format string `yaml:"format"`
+}
+
+// WebhookConfig is the routing section shared by the webhook based backends.
+type WebhookConfig struct {
+	// WebhookURL receives alerts whose severity has no entry in SeverityWebhooks.
+	WebhookURL string `yaml:"webhookURL"`
+	// SeverityWebhooks maps a severity label value (e.g. "critical") to its own webhook.
+	SeverityWebhooks map[string]string `yaml:"severityWebhooks"`
+	// Routes send alerts by label, e.g. per team or namespace, and take precedence over
+	// the severity and default webhooks.
+	Routes []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig sends alerts matching all Matchers to WebhookURL.
+type RouteConfig struct {
+	// Matchers use the label matcher syntax, e.g. `team="ml"` or `namespace=~"research-.*"`.
+	Matchers   []string `yaml:"matchers"`
+	WebhookURL string   `yaml:"webhookURL"`
+	// Continue keeps matching later routes, fanning the alert out to every matching one.
+	Continue bool `yaml:"continue"`
+	// Timezone and Locale override those of templates for the route's webhook.
+	Timezone string `yaml:"timezone"`
+	Locale   string `yaml:"locale"`
+	// ActiveTime limits the route to certain times of the week, e.g. business hours.
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// ActiveTimeConfig is when a route applies; outside it the route is skipped as if its
+// matchers did not match, so the alert falls through to later routes. Empty means
+// always.
+type ActiveTimeConfig struct {
+	// Weekdays are days or inclusive ranges of days, e.g. ["monday:friday", "sunday"];
+	// empty means every day.
+	Weekdays []string `yaml:"weekdays"`
+	// Hours are "HH:MM-HH:MM" ranges, the end excluded, e.g. ["09:00-18:00"]. A range
+	// ending before it starts runs past midnight. Empty means the whole day.
+	Hours []string `yaml:"hours"`
+	// Timezone is an IANA zone name; the default is the route's timezone, else that of
+	// templates.
+	Timezone string `yaml:"timezone"`
+}
+
+// TemplatesConfig configures how messages show times and fixed words, and the template
+// helper functions (see templatefuncs.go and locale.go).
+type TemplatesConfig struct {
+	// Timezone is the IANA zone name messages show startsAt and endsAt in and
+	// toLocalTime converts to; the default is UTC.
+	Timezone string `yaml:"timezone"`
+	// TimeFormat is the Go time layout of startsAt and endsAt in messages; the default
+	// is "2006-01-02 15:04:05 MST".
+	TimeFormat string `yaml:"timeFormat"`
+	// Locale names the translations of status words, severities and field names, e.g.
+	// "ko"; the default is English.
+	Locale string `yaml:"locale"`
+	// TranslationsPath is a YAML file mapping each locale to its translations of the
+	// English words, e.g. {ko: {firing: 발생, Instance: 인스턴스}}.
+	TranslationsPath string `yaml:"translationsPath"`
+}
+
+// SeverityConfig is how alerts with one of Values as their severity label are shown.
+type SeverityConfig struct {
+	// Values are the severity label values, matched case-insensitively, e.g. [page, s1].
+	Values []string `yaml:"values"`
+	// Icon is shown for firing alerts and returned by severityEmoji; the default is 🚨
+	// (and severityEmoji's colored circles).
+	Icon string `yaml:"icon"`
+	// Color is the "#RRGGBB" accent color of rich formats; the default is gray.
+	Color string `yaml:"color"`
+	// TeamsStyle is the Adaptive Card container style in Teams: default, emphasis,
+	// good, attention, warning or accent; the default is emphasis.
+	TeamsStyle string `yaml:"teamsStyle"`
+}
+
+// OutputRouteConfig sends alerts matching all Matchers to the listed backends only.
+type OutputRouteConfig struct {
+	Matchers []string `yaml:"matchers"`
+	Outputs  []string `yaml:"outputs"`
+	// Continue keeps matching later routes, adding their backends as well.
+	Continue bool `yaml:"continue"`
+}
+
+// GoogleChatConfig extends the webhook routing with the Google Chat message options.
+type GoogleChatConfig struct {
+	WebhookConfig `yaml:",inline"`
+	// TemplatePath is a Go text/template file for the message text.
+	TemplatePath string `yaml:"templatePath"`
+	// Format is "cards" (default) or "text".
+	Format string `yaml:"format"`
+	// ThreadBy is empty (no threading), "incident" or "node".
+	ThreadBy string `yaml:"threadBy"`
+	// OnCall mentions the current on-call user in messages about critical alerts.
+	OnCall OnCallConfig `yaml:"onCall"`
+	// LinkDomains lists the domains whose runbook_url and dashboard_url annotations are
+	// shown as card buttons; subdomains are included. Empty shows no link buttons.
+	LinkDomains []string `yaml:"linkDomains"`
+}
+
+// OnCallConfig names the schedule the on-call user is read from: a rotation, an iCal
+// calendar, PagerDuty or Opsgenie (at most one of them).
+type OnCallConfig struct {
+	Rotation OnCallRotationConfig `yaml:"rotation"`
+	// CalendarURL is an iCal feed whose current event's title names the on-call user,
+	// e.g. a Google Calendar's "secret address in iCal format".
+	CalendarURL string                `yaml:"calendarURL"`
+	PagerDuty   OnCallPagerDutyConfig `yaml:"pagerduty"`
+	Opsgenie    OnCallOpsgenieConfig  `yaml:"opsgenie"`
+	// Users maps the names or emails the schedule returns to Google Chat user IDs
+	// ("users/123456789"). Rotation entries may be user IDs themselves.
+	Users map[string]string `yaml:"users"`
+	// Severities lists the severity label values whose firing alerts mention the user.
+	Severities []string      `yaml:"severities"`
+	CacheTTL   time.Duration `yaml:"cacheTTL"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// OnCallRotationConfig hands the on-call shift to the next of Users every Shift,
+// starting with the first at Start.
+type OnCallRotationConfig struct {
+	Start time.Time     `yaml:"start"`
+	Shift time.Duration `yaml:"shift"`
+	Users []string      `yaml:"users"`
+}
+
+// OnCallPagerDutyConfig reads the on-call user of a PagerDuty schedule.
+type OnCallPagerDutyConfig struct {
+	// APIToken is a REST API key with read access.
+	APIToken   string `yaml:"apiToken"`
+	ScheduleID string `yaml:"scheduleID"`
+	APIURL     string `yaml:"apiURL"`
+}
+
+// OnCallOpsgenieConfig reads the on-call user of an Opsgenie schedule.
+type OnCallOpsgenieConfig struct {
+	APIKey string `yaml:"apiKey"`
+	// Schedule is the schedule's name.
+	Schedule string `yaml:"schedule"`
+	// APIURL is https://api.eu.opsgenie.com for accounts in the EU region.
+	APIURL string `yaml:"apiURL"`
+}
+
+// EmailConfig configures the SMTP backend. Recipients are routed like webhooks: label
+// routes first, then SeverityRecipients, then To.
+type EmailConfig struct {
+	SMTP SMTPConfig `yaml:"smtp"`
+	From string     `yaml:"from"`
+	// To receives alerts no route or severity entry matches.
+	To                 []string            `yaml:"to"`
+	SeverityRecipients map[string][]string `yaml:"severityRecipients"`
+	Routes             []EmailRouteConfig  `yaml:"routes"`
+	// TemplatePath is a Go html/template file for the message body.
+	TemplatePath string `yaml:"templatePath"`
+}
+
+// SMTPConfig is the mail server the email backend submits to.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// TLS is "starttls" (default), "tls" for implicit TLS (usually port 465) or "none".
+	TLS string `yaml:"tls"`
+}
+
+// EmailRouteConfig sends alerts matching all Matchers to the To recipients.
+type EmailRouteConfig struct {
+	Matchers []string `yaml:"matchers"`
+	To       []string `yaml:"to"`
+	Continue bool     `yaml:"continue"`
+	// Timezone and Locale override those of templates for the recipients.
+	Timezone   string           `yaml:"timezone"`
+	Locale     string           `yaml:"locale"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the recipient routing as webhook routing, with each recipient
+// list joined into one comma separated destination.
+func (c EmailConfig) webhookConfig() WebhookConfig {
+	wc := WebhookConfig{
+		WebhookURL:       strings.Join(c.To, ","),
+		SeverityWebhooks: make(map[string]string, len(c.SeverityRecipients)),
+	}
+	for severity, to := range c.SeverityRecipients {
+		wc.SeverityWebhooks[severity] = strings.Join(to, ",")
+	}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: strings.Join(rc.To, ","), Continue: rc.Continue, Timezone: rc.Timezone, Locale: rc.Locale, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// PagerDutyConfig configures the PagerDuty Events API v2 backend. Routing keys are
+// routed like webhooks: label routes first, then RoutingKey.
+type PagerDutyConfig struct {
+	// RoutingKey is the integration key of the default PagerDuty service.
+	RoutingKey string                 `yaml:"routingKey"`
+	Routes     []PagerDutyRouteConfig `yaml:"routes"`
+	// Severities lists the severity label values that page; other alerts are not sent.
+	Severities []string `yaml:"severities"`
+	// URL overrides the Events API endpoint, e.g. for PagerDuty's EU service region.
+	URL string `yaml:"url"`
+}
+
+// PagerDutyRouteConfig sends alerts matching all Matchers to the service with RoutingKey.
+type PagerDutyRouteConfig struct {
+	Matchers   []string         `yaml:"matchers"`
+	RoutingKey string           `yaml:"routingKey"`
+	Continue   bool             `yaml:"continue"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the PagerDuty routing as webhook routing with routing keys as destinations.
+func (c PagerDutyConfig) webhookConfig() WebhookConfig {
+	wc := WebhookConfig{WebhookURL: c.RoutingKey}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: rc.RoutingKey, Continue: rc.Continue, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// OpsgenieConfig configures the Opsgenie Alert API backend. API keys are routed like
+// webhooks: label routes first, then APIKey.
+type OpsgenieConfig struct {
+	// APIKey is the key of the default Opsgenie API integration.
+	APIKey string                `yaml:"apiKey"`
+	Routes []OpsgenieRouteConfig `yaml:"routes"`
+	// Priorities maps severity label values onto Opsgenie priorities (P1-P5), on top of
+	// the defaults critical P1, error P2, warning P3 and info P5.
+	Priorities map[string]string `yaml:"priorities"`
+	// APIURL is https://api.eu.opsgenie.com for accounts in the EU region.
+	APIURL string `yaml:"apiURL"`
+}
+
+// OpsgenieRouteConfig sends alerts matching all Matchers to the integration with APIKey.
+type OpsgenieRouteConfig struct {
+	Matchers   []string         `yaml:"matchers"`
+	APIKey     string           `yaml:"apiKey"`
+	Continue   bool             `yaml:"continue"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the Opsgenie routing as webhook routing with API keys as destinations.
+func (c OpsgenieConfig) webhookConfig() WebhookConfig {
+	wc := WebhookConfig{WebhookURL: c.APIKey}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: rc.APIKey, Continue: rc.Continue, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// VictorOpsConfig configures the Splunk On-Call (VictorOps) REST endpoint backend.
+// Routing keys are routed like webhooks: label routes first, then RoutingKey.
+type VictorOpsConfig struct {
+	// APIKey is the key of the REST endpoint integration, the part of its URL after
+	// /alert/.
+	APIKey string `yaml:"apiKey"`
+	// RoutingKey picks the escalation policy of alerts matching no route.
+	RoutingKey string                 `yaml:"routingKey"`
+	Routes     []VictorOpsRouteConfig `yaml:"routes"`
+	// MessageTypes maps severity label values onto CRITICAL, WARNING or INFO, on top of
+	// the defaults critical CRITICAL, error CRITICAL, warning WARNING and info INFO.
+	MessageTypes map[string]string `yaml:"messageTypes"`
+	// URL overrides the REST endpoint up to the API key.
+	URL string `yaml:"url"`
+}
+
+// VictorOpsRouteConfig sends alerts matching all Matchers with RoutingKey.
+type VictorOpsRouteConfig struct {
+	Matchers   []string         `yaml:"matchers"`
+	RoutingKey string           `yaml:"routingKey"`
+	Continue   bool             `yaml:"continue"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the Splunk On-Call routing as webhook routing with routing keys as destinations.
+func (c VictorOpsConfig) webhookConfig() WebhookConfig {
+	wc := WebhookConfig{WebhookURL: c.RoutingKey}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: rc.RoutingKey, Continue: rc.Continue, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// TelegramConfig configures the Telegram bot backend. Chats are routed like webhooks;
+// a route without its own bot token uses BotToken.
+type TelegramConfig struct {
+	// BotToken is the token BotFather issued for the bot, e.g. "123456:ABC-DEF...".
+	BotToken string `yaml:"botToken"`
+	// ChatID is the default chat: a user or group ID, or "@channelname".
+	ChatID string                `yaml:"chatID"`
+	Routes []TelegramRouteConfig `yaml:"routes"`
+	// APIURL is a self-hosted Bot API server; the default is https://api.telegram.org.
+	APIURL string `yaml:"apiURL"`
+}
+
+// TelegramRouteConfig sends alerts matching all Matchers to ChatID.
+type TelegramRouteConfig struct {
+	Matchers []string `yaml:"matchers"`
+	BotToken string   `yaml:"botToken"`
+	ChatID   string   `yaml:"chatID"`
+	Continue bool     `yaml:"continue"`
+	// Timezone and Locale override those of templates for the chat.
+	Timezone   string           `yaml:"timezone"`
+	Locale     string           `yaml:"locale"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the Telegram routing as webhook routing with the bots'
+// sendMessage URLs for the chats as destinations.
+func (c TelegramConfig) webhookConfig() WebhookConfig {
+	var wc WebhookConfig
+	if c.BotToken != "" && c.ChatID != "" {
+		wc.WebhookURL = telegramDestination(c.APIURL, c.BotToken, c.ChatID)
+	}
+	for _, rc := range c.Routes {
+		token := rc.BotToken
+		if token == "" {
+			token = c.BotToken
+		}
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: telegramDestination(c.APIURL, token, rc.ChatID), Continue: rc.Continue, Timezone: rc.Timezone, Locale: rc.Locale, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// HTTPConfig configures the generic HTTP backend. Endpoints are routed like webhooks:
+// label routes first, then the default endpoint.
+type HTTPConfig struct {
+	// The default endpoint receives alerts no route matches.
+	HTTPEndpointConfig `yaml:",inline"`
+	Routes             []HTTPRouteConfig `yaml:"routes"`
+}
+
+// HTTPEndpointConfig describes the request sent for alerts.
+type HTTPEndpointConfig struct {
+	// Name tells endpoints with the same URL apart; the default is the URL.
+	Name string `yaml:"name"`
+	// Method is the request method; the default is POST.
+	Method string `yaml:"method"`
+	URL    string `yaml:"url"`
+	// Headers are added to every request, e.g. an Authorization header. Content-Type
+	// defaults to application/json.
+	Headers map[string]string `yaml:"headers"`
+	// Body is a Go text/template executed with the payload of the endpoint's alerts, with
+	// the message template helpers and toJSON; the default sends the payload as JSON.
+	Body string `yaml:"body"`
+	// PerAlert sends one request per alert, e.g. for one ticket per alert.
+	PerAlert bool `yaml:"perAlert"`
+	// SkipResolved does not send resolved alerts, e.g. to an API that only opens tickets.
+	SkipResolved bool `yaml:"skipResolved"`
+}
+
+// destination names the endpoint in the routing, the logs and the outbound queue.
+func (c HTTPEndpointConfig) destination() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.URL
+}
+
+// HTTPRouteConfig sends alerts matching all Matchers to its endpoint.
+type HTTPRouteConfig struct {
+	Matchers           []string `yaml:"matchers"`
+	HTTPEndpointConfig `yaml:",inline"`
+	Continue           bool `yaml:"continue"`
+	// Timezone and Locale override those of templates for the endpoint's body.
+	Timezone   string           `yaml:"timezone"`
+	Locale     string           `yaml:"locale"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the endpoint routing as webhook routing with the endpoints'
+// destinations.
+func (c HTTPConfig) webhookConfig() WebhookConfig {
+	var wc WebhookConfig
+	if c.URL != "" {
+		wc.WebhookURL = c.destination()
+	}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: rc.destination(), Continue: rc.Continue, Timezone: rc.Timezone, Locale: rc.Locale, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// JiraConfig configures the Jira backend, which opens an issue per selected alert and
+// comments on and transitions it once the alert resolves. Projects are routed like
+// webhooks: label routes first, then Project.
+type JiraConfig struct {
+	// URL is the Jira site, e.g. "https://example.atlassian.net".
+	URL string `yaml:"url"`
+	// Username and APIToken authenticate with basic auth, as Jira Cloud expects (the
+	// account's email and an API token); BearerToken is a personal access token of Jira
+	// Data Center instead.
+	Username    string `yaml:"username"`
+	APIToken    string `yaml:"apiToken"`
+	BearerToken string `yaml:"bearerToken"`
+	// Project is the key of the project of alerts no route matches, e.g. "OPS".
+	Project string            `yaml:"project"`
+	Routes  []JiraRouteConfig `yaml:"routes"`
+	// IssueType is the name of the issue type opened, e.g. "Bug" (default) or "Incident".
+	IssueType string   `yaml:"issueType"`
+	Labels    []string `yaml:"labels"`
+	// Severities lists the severity label values that open issues.
+	Severities []string `yaml:"severities"`
+	// Matchers select the alerts that open issues among those; the default selects the
+	// hardware alerts: XID errors, uncorrectable memory errors and unreachable nodes.
+	Matchers []string `yaml:"matchers"`
+	// ResolveTransition is the transition applied once the alert resolves, e.g. "Done";
+	// without it the issue only gets a comment.
+	ResolveTransition string `yaml:"resolveTransition"`
+}
+
+// JiraRouteConfig opens the issues of alerts matching all Matchers in Project.
+type JiraRouteConfig struct {
+	Matchers   []string         `yaml:"matchers"`
+	Project    string           `yaml:"project"`
+	Continue   bool             `yaml:"continue"`
+	ActiveTime ActiveTimeConfig `yaml:"activeTime"`
+}
+
+// webhookConfig expresses the Jira routing as webhook routing with project keys as
+// destinations.
+func (c JiraConfig) webhookConfig() WebhookConfig {
+	wc := WebhookConfig{WebhookURL: c.Project}
+	for _, rc := range c.Routes {
+		wc.Routes = append(wc.Routes, RouteConfig{Matchers: rc.Matchers, WebhookURL: rc.Project, Continue: rc.Continue, ActiveTime: rc.ActiveTime})
+	}
+	return wc
+}
+
+// RetryConfig tunes the retries of failed outbound posts.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
+}
+
+// HTTPClientConfig sets the timeouts, proxy and connection pool of outbound posts.
+type HTTPClientConfig struct {
+	// ConnectTimeout bounds the TCP connect and the TLS handshake.
+	ConnectTimeout time.Duration `yaml:"connectTimeout"`
+	// RequestTimeout bounds a whole post, from connecting to reading the response.
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+	// ProxyURL overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+	ProxyURL            string `yaml:"proxyURL"`
+	MaxIdleConns        int    `yaml:"maxIdleConns"`
+	MaxIdleConnsPerHost int    `yaml:"maxIdleConnsPerHost"`
+	// MaxConnsPerHost limits the open connections per backend; 0 means no limit.
+	MaxConnsPerHost int           `yaml:"maxConnsPerHost"`
+	IdleConnTimeout time.Duration `yaml:"idleConnTimeout"`
+	// KeepAlive is the interval of TCP keep-alive probes on pooled connections, which
+	// keeps firewalls and NAT gateways from dropping them while idle.
+	KeepAlive time.Duration `yaml:"keepAlive"`
+	// Compression is empty (none) or "gzip", which compresses request bodies of at
+	// least CompressMinBytes. Only enable it when every backend accepts
+	// Content-Encoding: gzip.
+	Compression      string `yaml:"compression"`
+	CompressMinBytes int    `yaml:"compressMinBytes"`
+}
+
+// SignatureConfig enables HMAC verification of incoming webhooks.
+type SignatureConfig struct {
+	Secret string `yaml:"secret"`
+	// Mode is "enforce" (default) or "warn".
+	Mode string `yaml:"mode"`
+}
+
+// AuthConfig requires HTTP basic auth and/or a bearer token on incoming webhooks.
+type AuthConfig struct {
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	BearerToken string `yaml:"bearerToken"`
+}
+
+// RequestsConfig hardens the webhook endpoints against malformed and hostile payloads.
+type RequestsConfig struct {
+	// MaxBodyBytes is the largest accepted webhook body; larger ones get 413.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes"`
+	// StrictJSON rejects payloads with fields the Alertmanager or Grafana webhook format
+	// does not define, e.g. from a misconfigured sender, instead of ignoring them.
+	StrictJSON bool `yaml:"strictJSON"`
+}
+
+// DCGMConfig enables enriching outgoing alerts with DCGM health data.
+type DCGMConfig struct {
+	// ExporterURL is the dcgm-exporter metrics URL of the alerting node. "{host}" is
+	// replaced by the alert's instance label without its port, "{instance}" by the full label.
+	ExporterURL string        `yaml:"exporterURL"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// ProcessesConfig enables annotating alerts with the top GPU processes reported by the
+// node's gpu-collector.
+type ProcessesConfig struct {
+	// CollectorURL is the gpu-collector metrics URL of the alerting node, with the same
+	// "{host}" and "{instance}" placeholders as DCGMConfig.ExporterURL.
+	CollectorURL string `yaml:"collectorURL"`
+	// AlertPattern is a regular expression selecting the alerts (by alertname) to annotate.
+	AlertPattern string        `yaml:"alertPattern"`
+	Top          int           `yaml:"top"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// ThrottleConfig adds the alerting GPU's clock throttle reasons to matching alerts.
+type ThrottleConfig struct {
+	// CollectorURL is the gpu-collector metrics URL of the alerting node, with the same
+	// placeholders as ProcessesConfig.CollectorURL.
+	CollectorURL string `yaml:"collectorURL"`
+	// AlertPattern is a regular expression selecting the alerts (by alertname) to annotate.
+	AlertPattern string        `yaml:"alertPattern"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// ChassisConfig adds the alerting node's chassis fan, power supply and inlet temperature
+// state, read by its gpu-collector from the BMC, to matching alerts.
+type ChassisConfig struct {
+	// CollectorURL is the gpu-collector metrics URL of the alerting node, with the same
+	// placeholders as ProcessesConfig.CollectorURL.
+	CollectorURL string `yaml:"collectorURL"`
+	// AlertPattern is a regular expression selecting the alerts (by alertname) to annotate.
+	AlertPattern string        `yaml:"alertPattern"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// NodeMetadataConfig selects the inventory the node metadata enricher reads. Exactly
+// one of Nodes, File, URL and Kubernetes may be set.
+type NodeMetadataConfig struct {
+	// Nodes maps node names to their location, owner and model.
+	Nodes map[string]map[string]string `yaml:"nodes"`
+	// File is a YAML file in the format of Nodes; it is reloaded with the config.
+	File string `yaml:"file"`
+	// URL returns a JSON object for one node; "{host}" is replaced by the node name.
+	URL string `yaml:"url"`
+	// Kubernetes reads the labels of the Kubernetes node with the node's name.
+	Kubernetes bool `yaml:"kubernetes"`
+	// Fields maps location, owner and model to the JSON field or node label holding them.
+	Fields   map[string]string `yaml:"fields"`
+	CacheTTL time.Duration     `yaml:"cacheTTL"`
+	Timeout  time.Duration     `yaml:"timeout"`
+}
+
+// ActionsConfig enables the card buttons that create Alertmanager silences.
+type ActionsConfig struct {
+	// BaseURL is the adapter's externally reachable URL, e.g. "https://adapter.example.com".
+	BaseURL string `yaml:"baseURL"`
+	// AlertmanagerURL is where silences are created, e.g. "http://alertmanager:9093".
+	AlertmanagerURL string `yaml:"alertmanagerURL"`
+	// Secret signs the button links so they cannot be forged.
+	Secret string `yaml:"secret"`
+	// AckDuration is how long "Acknowledge" silences the alert.
+	AckDuration time.Duration `yaml:"ackDuration"`
+}
+
+// MaintenanceWindowConfig is one recurring maintenance window.
+type MaintenanceWindowConfig struct {
+	Name string `yaml:"name"`
+	// Matchers select the alerts held back, e.g. `instance=~"gpu-node-0[1-4].*"`.
+	// All must match; an empty list matches every alert.
+	Matchers []string `yaml:"matchers"`
+	// Schedule is a five field cron expression (or a descriptor such as "@weekly")
+	// giving the start of each window.
+	Schedule string        `yaml:"schedule"`
+	Duration time.Duration `yaml:"duration"`
+	// Timezone is an IANA zone name for Schedule; the default is UTC.
+	Timezone string `yaml:"timezone"`
+}
+
+// EscalationPolicyConfig runs its steps for firing alerts that match all Matchers until
+// they resolve or are acknowledged with a card button. The first matching policy wins.
+type EscalationPolicyConfig struct {
+	Name     string                 `yaml:"name"`
+	Matchers []string               `yaml:"matchers"`
+	Steps    []EscalationStepConfig `yaml:"steps"`
+}
+
+// EscalationStepConfig is one step of an escalation chain.
+type EscalationStepConfig struct {
+	// After is how long after the adapter first forwarded the alert the step runs.
+	After time.Duration `yaml:"after"`
+	// Mention is put in front of the notice re-posted in the alert's Google Chat
+	// thread, e.g. "<users/123456789>" or "<users/all>".
+	Mention string `yaml:"mention"`
+	// Outputs are backends that receive the alert at this step, e.g. ["pagerduty"].
+	Outputs []string `yaml:"outputs"`
+}
+
+// FilterRuleConfig is an inbound filter rule; the first rule matching an alert applies.
+type FilterRuleConfig struct {
+	// Matchers use the label matcher syntax and must all match.
+	Matchers []string `yaml:"matchers"`
+	// Action is "drop" (the alert is discarded), "keep" (forwarded unchanged, ending the
+	// rule evaluation) or "downgrade" (its severity label becomes Severity).
+	Action   string `yaml:"action"`
+	Severity string `yaml:"severity"`
+}
+
+// InhibitRuleConfig is an inhibition rule: while an alert matching SourceMatchers fires,
+// alerts matching TargetMatchers with the same values of the Equal labels are not posted.
+type InhibitRuleConfig struct {
+	SourceMatchers []string `yaml:"sourceMatchers"`
+	TargetMatchers []string `yaml:"targetMatchers"`
+	// Equal lists the labels source and target must share; "node" compares the nodes
+	// the alerts are about (the node label, or else the instance without its port).
+	Equal []string `yaml:"equal"`
+}
+
+// RedactionConfig is one redaction rule, applied to labels and annotations alike.
+type RedactionConfig struct {
+	// Keys are anchored regular expressions for label/annotation names; plain names
+	// match exactly.
+	Keys []string `yaml:"keys"`
+	// Action is "mask" (default, the value becomes "[REDACTED]") or "strip" (the key is removed).
+	Action string `yaml:"action"`
+	// Values, if set, masks only the parts of the value matching this regular expression.
+	Values string `yaml:"values"`
+}
+
+// ReadinessConfig sets the thresholds of the /readyz checks.
+type ReadinessConfig struct {
+	// BackendWindow is how long a backend may fail without any successful delivery.
+	BackendWindow time.Duration `yaml:"backendWindow"`
+	// MaxQueued is the outbound queue size above which the adapter is not ready; 0 disables the check.
+	MaxQueued int `yaml:"maxQueued"`
+}
+
+// SharedStateConfig selects where state shared between replicas is kept.
+type SharedStateConfig struct {
+	// RedisURL is e.g. "redis://:password@redis:6379/0" (rediss:// for TLS); empty keeps
+	// the state in memory.
+	RedisURL string `yaml:"redisURL"`
+	// KeyPrefix namespaces the adapter's keys, e.g. to share one Redis between deployments.
+	KeyPrefix string `yaml:"keyPrefix"`
+}
+
+// SilenceAPIConfig enables the /api/silence proxy to Alertmanager.
+type SilenceAPIConfig struct {
+	// AlertmanagerURL is where silences are created, e.g. "http://alertmanager:9093".
+	AlertmanagerURL string `yaml:"alertmanagerURL"`
+	// Token must be sent as "Authorization: Bearer <token>"; it is required, since the
+	// API creates and expires silences.
+	Token string `yaml:"token"`
+	// MaxDuration caps the duration callers may request.
+	MaxDuration time.Duration `yaml:"maxDuration"`
+}
+
+// DeadLetterConfig configures the dead letter store and its API (/api/deadletters).
+type DeadLetterConfig struct {
+	// Path is the BoltDB file of the store; empty disables it.
+	Path string `yaml:"path"`
+	// Token must be sent as "Authorization: Bearer <token>"; it is required, since the API
+	// lists message bodies and retries and discards them.
+	Token string `yaml:"token"`
+}
+
+// PreviewConfig enables /api/preview, which renders a payload for every backend without
+// sending it.
+type PreviewConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Token, if set, must be sent as "Authorization: Bearer <token>".
+	Token string `yaml:"token"`
+}
+
+// ConfigAPIConfig enables /api/routes and /api/templates, which edit the backends'
+// routes and message templates of the config file at runtime.
+type ConfigAPIConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Token must be sent as "Authorization: Bearer <token>"; it is required, since the API
+	// rewrites the config file.
+	Token string `yaml:"token"`
+	// TemplateDir is where templates created through the API are written; the default is
+	// the directory of the config file.
+	TemplateDir string `yaml:"templateDir"`
+}
+
+// SLOConfig configures the delivery SLO metrics and their report.
+type SLOConfig struct {
+	// Windows are the rolling windows of the success ratio and p95 latency gauges, e.g.
+	// [1h, 24h, 168h]; empty disables them.
+	Windows []time.Duration `yaml:"windows"`
+	Report  SLOReportConfig `yaml:"report"`
+}
+
+// SLOReportConfig posts a "notification pipeline health" card over the longest window
+// to a Google Chat space.
+type SLOReportConfig struct {
+	// WebhookURL is the Google Chat webhook of the space; empty disables the report.
+	WebhookURL string `yaml:"webhookURL"`
+	// Schedule is a five field cron expression (or a descriptor such as "@weekly");
+	// the default is Mondays at 09:00.
+	Schedule string `yaml:"schedule"`
+	// Timezone is an IANA zone name for Schedule; the default is UTC.
+	Timezone string `yaml:"timezone"`
+}
+
+// TimelineConfig configures the incident timeline: for every group key, a "still
+// firing" update every UpdateInterval and a summary once all its alerts resolved.
+type TimelineConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// UpdateInterval is the cadence of the still-firing updates; 0 posts only the
+	// resolution summaries.
+	UpdateInterval time.Duration `yaml:"updateInterval"`
+}
+
+// VersionDriftConfig enables the fleet check of NVIDIA driver and CUDA versions.
+type VersionDriftConfig struct {
+	// Inventories are gpu-collector inventory URLs, e.g.
+	// "http://gpu-node-01:9500/api/inventory"; empty disables the check.
+	Inventories []string `yaml:"inventories"`
+	// Interval is how often the inventories are read.
+	Interval time.Duration `yaml:"interval"`
+	// ExpectedDriverVersion and ExpectedCUDAVersion pin the baseline; without them the
+	// baseline is the version most nodes run.
+	ExpectedDriverVersion string `yaml:"expectedDriverVersion"`
+	ExpectedCUDAVersion   string `yaml:"expectedCUDAVersion"`
+	// Severity is the severity label of the drift alerts.
+	Severity string `yaml:"severity"`
+}
+
+// HeartbeatConfig enables the dead man's switch: POST /api/heartbeat for node agents
+// and a NodeUnreachable alert for nodes that stop calling it.
+type HeartbeatConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the heartbeat interval of nodes that do not send their own.
+	Interval time.Duration `yaml:"interval"`
+	// MissedHeartbeats is how many heartbeats in a row a node misses before it is
+	// reported unreachable.
+	MissedHeartbeats int `yaml:"missedHeartbeats"`
+	// Severity is the severity label of NodeUnreachable.
+	Severity string `yaml:"severity"`
+	// Nodes are watched from the start, even before their first heartbeat; other nodes
+	// are watched once they sent one.
+	Nodes []string `yaml:"nodes"`
+	// Token must be sent as "Authorization: Bearer <token>"; it is required.
+	Token string `yaml:"token"`
+}
+
+// AggregatorConfig serves the gRPC service of proto/aggregator.proto to gpu-collectors
+// with AGGREGATOR_URL.
+type AggregatorConfig struct {
+	// ListenAddress is the TLS address of the gRPC service, e.g. ":9443"; empty
+	// disables the aggregator.
+	ListenAddress string `yaml:"listenAddress"`
+	TLSCertFile   string `yaml:"tlsCertFile"`
+	TLSKeyFile    string `yaml:"tlsKeyFile"`
+	// ClientCAFile is a PEM CA bundle the agents' client certificates must be signed
+	// by (mTLS); without it, any agent that trusts the server may report.
+	ClientCAFile string `yaml:"clientCAFile"`
+	// StaleAfter is how long a node's metrics are served after its last report, and
+	// how long a stream may stay silent; it must exceed the agents' AGGREGATOR_INTERVAL.
+	StaleAfter time.Duration `yaml:"staleAfter"`
+}
+
+// SNMPConfig enables the SNMP trap listener, which turns the configured traps into
+// alerts and sends them through the same pipeline as webhooks.
+type SNMPConfig struct {
+	// ListenAddress is the UDP address traps are received on, e.g. ":162"; empty
+	// disables the listener.
+	ListenAddress string `yaml:"listenAddress"`
+	// Communities are accepted from SNMPv2c senders; without any, v2c is refused.
+	Communities []string `yaml:"communities"`
+	// Users are accepted from SNMPv3 senders.
+	Users []SNMPUserConfig `yaml:"users"`
+	// Traps translate notifications into alerts by their snmpTrapOID. Traps with other
+	// OIDs are dropped.
+	Traps []SNMPTrapConfig `yaml:"traps"`
+}
+
+// SNMPUserConfig is an SNMPv3 user. Without AuthProtocol the user sends noAuthNoPriv,
+// without PrivProtocol authNoPriv messages.
+type SNMPUserConfig struct {
+	Username string `yaml:"username"`
+	// AuthProtocol is MD5 or SHA (HMAC-96).
+	AuthProtocol string `yaml:"authProtocol"`
+	AuthPassword string `yaml:"authPassword"`
+	// PrivProtocol is DES or AES (128-bit).
+	PrivProtocol string `yaml:"privProtocol"`
+	PrivPassword string `yaml:"privPassword"`
+}
+
+// SNMPTrapConfig translates one trap OID into an alert.
+type SNMPTrapConfig struct {
+	// OID is the snmpTrapOID.0 of the trap, e.g. "1.3.6.1.4.1.318.0.5".
+	OID       string `yaml:"oid"`
+	Alertname string `yaml:"alertname"`
+	Severity  string `yaml:"severity"`
+	// Resolves makes the trap resolve its alert instead of firing it, e.g. for "power
+	// restored". The alert is the one with the same alertname, instance and
+	// varbindLabels values.
+	Resolves bool `yaml:"resolves"`
+	// Labels are added to the alert.
+	Labels map[string]string `yaml:"labels"`
+	// VarbindLabels set labels from the values of the trap's variable bindings: the
+	// first varbind whose OID is or starts with the given OID, e.g. an outlet index.
+	VarbindLabels map[string]string `yaml:"varbindLabels"`
+	// Summary is the alert's summary annotation; "{name}" is replaced by the value of
+	// label name.
+	Summary string `yaml:"summary"`
+}
+
+// KubernetesConfig enables /kubernetes, which turns the configured Kubernetes Events
+// and node conditions (e.g. from node-problem-detector) into alerts and sends them
+// through the same pipeline as webhooks.
+type KubernetesConfig struct {
+	// Conditions translate node conditions by type, e.g. "KernelDeadlock".
+	Conditions []KubernetesProblemConfig `yaml:"conditions"`
+	// Events translate events by reason, e.g. "GpuXidError". Events with other reasons
+	// are dropped.
+	Events []KubernetesProblemConfig `yaml:"events"`
+}
+
+// KubernetesProblemConfig translates one node condition type or event reason into an
+// alert.
+type KubernetesProblemConfig struct {
+	// Name is the condition type or the event reason.
+	Name string `yaml:"name"`
+	// Alertname defaults to Name.
+	Alertname string `yaml:"alertname"`
+	Severity  string `yaml:"severity"`
+	// Resolves makes an event resolve its alert instead of firing it. The alert is the
+	// one with the same alertname and involved object. Conditions resolve when False.
+	Resolves bool `yaml:"resolves"`
+	// Labels are added to the alert.
+	Labels map[string]string `yaml:"labels"`
+	// Summary is the alert's summary annotation; "{name}" is replaced by the value of
+	// label name.
+	Summary string `yaml:"summary"`
+}
+
+// ZabbixConfig enables /zabbix, which turns the problem and recovery notifications of
+// a Zabbix webhook media type into alerts.
+type ZabbixConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Severities map Zabbix severities (e.g. "High") onto severity label values; they
+	// replace the defaults for the same severities.
+	Severities map[string]string `yaml:"severities"`
+	// Labels are added to every alert, e.g. to route them.
+	Labels map[string]string `yaml:"labels"`
+	// Timezone is the Zabbix server's time zone, which event times are given in;
+	// empty is the adapter's.
+	Timezone string `yaml:"timezone"`
+}
+
+// NagiosConfig enables /nagios, which turns the PROBLEM and RECOVERY notifications of
+// a Nagios or Icinga notification command into alerts.
+type NagiosConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Severities map host and service states (e.g. "CRITICAL") onto severity label
+	// values; they replace the defaults for the same states.
+	Severities map[string]string `yaml:"severities"`
+	// Labels are added to every alert, e.g. to route them.
+	Labels map[string]string `yaml:"labels"`
+}
+
+// AuditConfig configures the audit log of outbound notification attempts and its
+// query API (GET /api/audit).
+type AuditConfig struct {
+	// Path is the SQLite file of the audit log; empty disables it.
+	Path string `yaml:"path"`
+	// Retention is how long attempts are kept; 0 keeps them forever.
+	Retention time.Duration `yaml:"retention"`
+	// Token, if set, must be sent as "Authorization: Bearer <token>".
+	Token string `yaml:"token"`
+}
+
+// DashboardConfig configures the web dashboard. Alerts are listed from the alert
+// history and silences from the silence API's (or else the actions') Alertmanager.
+type DashboardConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Collectors are gpu-collector metrics URLs, e.g. "http://gpu-node-01:9500/metrics",
+	// whose GPUs get utilization and temperature sparklines.
+	Collectors []string `yaml:"collectors"`
+	// Interval is how often the collectors are scraped.
+	Interval time.Duration `yaml:"interval"`
+	// Points is how many readings each sparkline shows.
+	Points int `yaml:"points"`
+}
+
+// TracingConfig enables OpenTelemetry tracing.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP endpoint, e.g. "http://jaeger:4318"; empty disables tracing.
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"serviceName"`
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// WorkersConfig sizes the delivery pool: Concurrency workers dispatch payloads queued by
+// webhooks, and webhooks finding QueueDepth payloads queued are rejected with 503.
+type WorkersConfig struct {
+	// Concurrency is the number of workers; 0 dispatches within the webhook request.
+	Concurrency int `yaml:"concurrency"`
+	QueueDepth  int `yaml:"queueDepth"`
+}
+
+// DigestConfig collects alerts of the listed severities into one message per interval.
+type DigestConfig struct {
+	// Interval between digests; 0 disables them.
+	Interval   time.Duration `yaml:"interval"`
+	Severities []string      `yaml:"severities"`
+}
+
+// StormConfig configures the alert storm breaker, which trips when more than Threshold
+// alerts arrive within Window and resets once fewer than Threshold did.
+type StormConfig struct {
+	// Threshold is the number of alerts per window that is a storm; 0 disables it.
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+	// DashboardURL is linked from the storm summaries, e.g. the adapter's /dashboard.
+	DashboardURL string `yaml:"dashboardURL"`
+}
+
+// RateLimitConfig is a token bucket applied to every destination webhook.
+type RateLimitConfig struct {
+	// PerMinute is the sustained message rate; 0 disables rate limiting.
+	PerMinute int `yaml:"perMinute"`
+	// Burst is the number of messages that may be sent back to back.
+	Burst int `yaml:"burst"`
+}
+
+// defaultConfig returns the settings used for anything the file or environment leaves out.
+func defaultConfig() *Config {
+	return &Config{
+		ListenAddress: ":8080",
+		Log:           LogConfig{Level: "info", Format: "json"},
+		Outputs:       []string{"gchat"},
+		GoogleChat: GoogleChatConfig{
+			Format: "cards",
+			OnCall: OnCallConfig{
+				Severities: []string{"critical"},
+				CacheTTL:   5 * time.Minute,
+				Timeout:    5 * time.Second,
+				PagerDuty:  OnCallPagerDutyConfig{APIURL: "https://api.pagerduty.com"},
+				Opsgenie:   OnCallOpsgenieConfig{APIURL: "https://api.opsgenie.com"},
+			},
+		},
+		Email:     EmailConfig{SMTP: SMTPConfig{Port: 587, TLS: "starttls"}},
+		PagerDuty: PagerDutyConfig{Severities: []string{"critical"}},
+		Jira: JiraConfig{
+			IssueType:  "Bug",
+			Severities: []string{"critical"},
+			Matchers:   []string{`alertname=~"GpuXidError|GpuRowRemapFailure|GpuPageRetirementPending|NodeUnreachable|HostDown-NodeExporter"`},
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    5,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     30 * time.Second,
+		},
+		HTTPClient: HTTPClientConfig{
+			ConnectTimeout:      5 * time.Second,
+			RequestTimeout:      30 * time.Second,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			KeepAlive:           30 * time.Second,
+			CompressMinBytes:    1024,
+		},
+		DrainTimeout: 25 * time.Second,
+		DCGM:         DCGMConfig{Timeout: 2 * time.Second},
+		Processes: ProcessesConfig{
+			AlertPattern: "(?i)mem|util",
+			Top:          3,
+			Timeout:      2 * time.Second,
+		},
+		Throttle:     ThrottleConfig{AlertPattern: "(?i)util|temp|therm|clock|power|slow", Timeout: 2 * time.Second},
+		Chassis:      ChassisConfig{AlertPattern: "(?i)temp|therm|hot|fan|slow", Timeout: 2 * time.Second},
+		NodeMetadata: NodeMetadataConfig{CacheTTL: 10 * time.Minute, Timeout: 2 * time.Second},
+		Actions:      ActionsConfig{AckDuration: 4 * time.Hour},
+		Audit:        AuditConfig{Retention: 90 * 24 * time.Hour},
+		Requests:     RequestsConfig{MaxBodyBytes: defaultMaxBodyBytes},
+		Timeline:     TimelineConfig{UpdateInterval: 2 * time.Hour},
+		SilenceAPI:   SilenceAPIConfig{MaxDuration: 24 * time.Hour},
+		Readiness:    ReadinessConfig{BackendWindow: 10 * time.Minute, MaxQueued: 1000},
+		SharedState:  SharedStateConfig{KeyPrefix: "gchat-adapter:"},
+		Digest:       DigestConfig{Severities: []string{"info", "warning"}},
+		Tracing:      TracingConfig{ServiceName: "alertmanager-adapter", SampleRatio: 1},
+		Dashboard:    DashboardConfig{Interval: 30 * time.Second, Points: 60},
+		VersionDrift: VersionDriftConfig{Interval: 10 * time.Minute, Severity: "warning"},
+		Storm:        StormConfig{Window: time.Minute},
+		Workers:      WorkersConfig{QueueDepth: 100},
+		Heartbeat:    HeartbeatConfig{Interval: 30 * time.Second, MissedHeartbeats: 3, Severity: "critical"},
+		Aggregator:   AggregatorConfig{StaleAfter: 2 * time.Minute},
+	}
+}
+
+// loadConfigFile reads and validates a YAML config file. Unknown keys are rejected so
+// that typos surface immediately instead of silently falling back to defaults.
+func loadConfigFile(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return parseConfig(content, path)
+}
+
+// parseConfig parses and validates the content of the config file at path.
+func parseConfig(content []byte, path string) (*Config, error) {
+	content, err := resolveConfigSecrets(content)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	cfg := defaultConfig()
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// configFromEnv builds the config from the environment variables the adapter used
+// before config files existed, so existing docker-compose deployments keep working.
+func configFromEnv() (*Config, error) {
+	cfg := defaultConfig()
+
+	if v := os.Getenv("OUTPUTS"); v != "" {
+		cfg.Outputs = strings.Split(v, ",")
+	}
+	for envName, target := range map[string]*WebhookConfig{
+		"GOOGLE_CHAT_WEBHOOK_URL": &cfg.GoogleChat.WebhookConfig,
+		"SLACK_WEBHOOK_URL":       &cfg.Slack,
+		"TEAMS_WEBHOOK_URL":       &cfg.Teams,
+		"DISCORD_WEBHOOK_URL":     &cfg.Discord,
+	} {
+		wc, err := webhookConfigFromEnv(envName)
+		if err != nil {
+			return nil, err
+		}
+		*target = wc
+	}
+	// Secrets may also be read from the file named by <NAME>_FILE, e.g. a Docker or
+	// Kubernetes secret, or reference a secret manager (see secretReference).
+	for name, target := range map[string]*string{
+		"PAGERDUTY_ROUTING_KEY":       &cfg.PagerDuty.RoutingKey,
+		"OPSGENIE_API_KEY":            &cfg.Opsgenie.APIKey,
+		"VICTOROPS_API_KEY":           &cfg.VictorOps.APIKey,
+		"JIRA_API_TOKEN":              &cfg.Jira.APIToken,
+		"TELEGRAM_BOT_TOKEN":          &cfg.Telegram.BotToken,
+		"REDIS_URL":                   &cfg.SharedState.RedisURL,
+		"WEBHOOK_HMAC_SECRET":         &cfg.Signature.Secret,
+		"WEBHOOK_BASIC_AUTH_PASSWORD": &cfg.Auth.Password,
+		"WEBHOOK_BEARER_TOKEN":        &cfg.Auth.BearerToken,
+	} {
+		v, err := secretEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		*target = v
+	}
+	cfg.Jira.URL = os.Getenv("JIRA_URL")
+	cfg.Jira.Username = os.Getenv("JIRA_USERNAME")
+	cfg.Jira.Project = os.Getenv("JIRA_PROJECT")
+	cfg.Jira.ResolveTransition = os.Getenv("JIRA_RESOLVE_TRANSITION")
+	cfg.Telegram.ChatID = os.Getenv("TELEGRAM_CHAT_ID")
+	cfg.VictorOps.RoutingKey = os.Getenv("VICTOROPS_ROUTING_KEY")
+	cfg.GoogleChat.TemplatePath = os.Getenv("MESSAGE_TEMPLATE_PATH")
+	if v := os.Getenv("MESSAGE_FORMAT"); v != "" {
+		cfg.GoogleChat.Format = v
+	}
+	cfg.GoogleChat.ThreadBy = os.Getenv("GOOGLE_CHAT_THREAD_BY")
+
+	if v := os.Getenv("RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RETRY_MAX_ATTEMPTS %q: must be a positive integer", v)
+		}
+		cfg.Retry.MaxAttempts = n
+	}
+	for name, target := range map[string]*int{
+		"WORKER_CONCURRENCY": &cfg.Workers.Concurrency,
+		"WORKER_QUEUE_DEPTH": &cfg.Workers.QueueDepth,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: must be a positive integer", name, v)
+			}
+			*target = n
+		}
+	}
+	if v := os.Getenv("WEBHOOK_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_BODY_BYTES %q: must be a positive integer", v)
+		}
+		cfg.Requests.MaxBodyBytes = n
+	}
+	cfg.Requests.StrictJSON = os.Getenv("WEBHOOK_STRICT_JSON") == "true"
+	for name, target := range map[string]*time.Duration{
+		"RETRY_INITIAL_BACKOFF": &cfg.Retry.InitialBackoff,
+		"RETRY_MAX_BACKOFF":     &cfg.Retry.MaxBackoff,
+		"HTTP_CONNECT_TIMEOUT":  &cfg.HTTPClient.ConnectTimeout,
+		"HTTP_REQUEST_TIMEOUT":  &cfg.HTTPClient.RequestTimeout,
+		"HTTP_KEEP_ALIVE":       &cfg.HTTPClient.KeepAlive,
+		"GROUP_WINDOW":          &cfg.GroupWindow,
+		"IDEMPOTENCY_TTL":       &cfg.IdempotencyTTL,
+		"DIGEST_INTERVAL":       &cfg.Digest.Interval,
+	} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*target = d
+		}
+	}
+
+	cfg.QueuePath = os.Getenv("QUEUE_PATH")
+	cfg.Audit.Path = os.Getenv("AUDIT_LOG_PATH")
+	cfg.Tracing.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.Tracing.ServiceName = v
+	}
+	cfg.Signature.Mode = os.Getenv("WEBHOOK_SIGNATURE_MODE")
+	cfg.Auth.Username = os.Getenv("WEBHOOK_BASIC_AUTH_USERNAME")
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// webhookConfigFromEnv reads the default webhook from the variable named envName and
+// per-severity webhooks from every envName_<SEVERITY> variable, e.g.
+// GOOGLE_CHAT_WEBHOOK_URL_CRITICAL routes alerts with severity="critical". Like other
+// secrets, each may be read from the file named by the variable with a _FILE suffix.
+func webhookConfigFromEnv(envName string) (WebhookConfig, error) {
+	prefix := envName + "_"
+	webhookURL, err := secretEnv(envName)
+	if err != nil {
+		return WebhookConfig{}, err
+	}
+	wc := WebhookConfig{
+		WebhookURL:       webhookURL,
+		SeverityWebhooks: make(map[string]string),
+	}
+	for _, entry := range os.Environ() {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || value == "" || !strings.HasPrefix(key, prefix) || key == prefix+"FILE" {
+			continue
+		}
+		key = strings.TrimSuffix(key, "_FILE")
+		if severity := strings.ToLower(strings.TrimPrefix(key, prefix)); severity != "" {
+			if wc.SeverityWebhooks[severity], err = secretEnv(key); err != nil {
+				return WebhookConfig{}, err
+			}
+		}
+	}
+	return wc, nil
+}
+
+// restartRequired lists the settings that differ between two configs but are only
+// applied at startup.
+func restartRequired(current, next *Config) []string {
+	var changed []string
+	if next.ListenAddress != current.ListenAddress {
+		changed = append(changed, "listenAddress")
+	}
+	if next.Log.Format != current.Log.Format {
+		changed = append(changed, "log.format")
+	}
+	if next.HTTPClient != current.HTTPClient {
+		changed = append(changed, "httpClient")
+	}
+	if next.QueuePath != current.QueuePath {
+		changed = append(changed, "queuePath")
+	}
+	if next.Workers != current.Workers {
+		changed = append(changed, "workers")
+	}
+	if next.GroupWindow != current.GroupWindow {
+		changed = append(changed, "groupWindow")
+	}
+	if next.Signature != current.Signature {
+		changed = append(changed, "signature")
+	}
+	if next.Auth != current.Auth {
+		changed = append(changed, "auth")
+	}
+	if next.Requests != current.Requests {
+		changed = append(changed, "requests")
+	}
+	if next.DrainTimeout != current.DrainTimeout {
+		changed = append(changed, "drainTimeout")
+	}
+	if next.Audit != current.Audit {
+		changed = append(changed, "audit")
+	}
+	if next.Timeline != current.Timeline {
+		changed = append(changed, "timeline")
+	}
+	if next.HistoryPath != current.HistoryPath {
+		changed = append(changed, "historyPath")
+	}
+	if next.DeadLetter != current.DeadLetter {
+		changed = append(changed, "deadLetter")
+	}
+	if next.Actions != current.Actions {
+		changed = append(changed, "actions")
+	}
+	if !reflect.DeepEqual(next.SLO, current.SLO) {
+		changed = append(changed, "slo")
+	}
+	if next.Readiness != current.Readiness {
+		changed = append(changed, "readiness")
+	}
+	if !reflect.DeepEqual(next.Dashboard, current.Dashboard) {
+		changed = append(changed, "dashboard")
+	}
+	if next.SilenceAPI != current.SilenceAPI {
+		changed = append(changed, "silenceAPI")
+	}
+	if next.Preview != current.Preview {
+		changed = append(changed, "preview")
+	}
+	if next.ConfigAPI != current.ConfigAPI {
+		changed = append(changed, "configAPI")
+	}
+	if next.SharedState != current.SharedState {
+		changed = append(changed, "sharedState")
+	}
+	if next.DedupTTL != current.DedupTTL {
+		changed = append(changed, "dedupTTL")
+	}
+	if next.IdempotencyTTL != current.IdempotencyTTL {
+		changed = append(changed, "idempotencyTTL")
+	}
+	if !reflect.DeepEqual(next.Maintenance, current.Maintenance) {
+		changed = append(changed, "maintenance")
+	}
+	if next.Tracing != current.Tracing {
+		changed = append(changed, "tracing")
+	}
+	if !reflect.DeepEqual(next.Digest, current.Digest) {
+		changed = append(changed, "digest")
+	}
+	if next.Storm != current.Storm {
+		changed = append(changed, "storm")
+	}
+	if next.RateLimit != current.RateLimit {
+		changed = append(changed, "rateLimit")
+	}
+	if !reflect.DeepEqual(next.Escalation, current.Escalation) {
+		changed = append(changed, "escalation")
+	}
+	if !reflect.DeepEqual(next.SNMP, current.SNMP) {
+		changed = append(changed, "snmp")
+	}
+	if !reflect.DeepEqual(next.Kubernetes, current.Kubernetes) {
+		changed = append(changed, "kubernetes")
+	}
+	if !reflect.DeepEqual(next.Zabbix, current.Zabbix) {
+		changed = append(changed, "zabbix")
+	}
+	if !reflect.DeepEqual(next.Nagios, current.Nagios) {
+		changed = append(changed, "nagios")
+	}
+	if !reflect.DeepEqual(next.VersionDrift, current.VersionDrift) {
+		changed = append(changed, "versionDrift")
+	}
+	if !reflect.DeepEqual(next.Heartbeat, current.Heartbeat) {
+		changed = append(changed, "heartbeat")
+	}
+	if next.Aggregator != current.Aggregator {
+		changed = append(changed, "aggregator")
+	}
+	return changed
+}
+
+// validate checks the endpoints of the http backend. Endpoints sharing a destination
+// must be the same, since the destination picks the endpoint.
+func (c HTTPConfig) validate() error {
+	endpoints := make(map[string]HTTPEndpointConfig)
+	check := func(prefix string, ec HTTPEndpointConfig) error {
+		if u, err := url.Parse(ec.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("%s: invalid url %q", prefix, ec.URL)
+		}
+		switch strings.ToUpper(ec.Method) {
+		case "", http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet, http.MethodDelete:
+		default:
+			return fmt.Errorf("%s: unsupported method %q", prefix, ec.Method)
+		}
+		if other, ok := endpoints[ec.destination()]; ok && !reflect.DeepEqual(other, ec) {
+			return fmt.Errorf("%s: another endpoint is named %q; set a distinct name", prefix, ec.destination())
+		}
+		endpoints[ec.destination()] = ec
+		return nil
+	}
+	if c.URL != "" {
+		if err := check("http", c.HTTPEndpointConfig); err != nil {
+			return err
+		}
+	}
+	for i, rc := range c.Routes {
+		prefix := fmt.Sprintf("http.routes[%d]", i)
+		if err := check(prefix, rc.HTTPEndpointConfig); err != nil {
+			return err
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		if _, err := time.LoadLocation(rc.Timezone); err != nil {
+			return fmt.Errorf("%s: invalid timezone: %w", prefix, err)
+		}
+	}
+	return nil
+}
+
+// validate checks settings that can be verified without building the backends.
+func (c *Config) validate() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf("invalid log.level %q", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		return fmt.Errorf("log.format must be \"json\" or \"text\"")
+	}
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry.maxAttempts must be a positive integer")
+	}
+	if c.Retry.InitialBackoff <= 0 || c.Retry.MaxBackoff <= 0 {
+		return fmt.Errorf("retry backoffs must be positive durations")
+	}
+	if c.HTTPClient.ConnectTimeout <= 0 || c.HTTPClient.RequestTimeout <= 0 {
+		return fmt.Errorf("httpClient timeouts must be positive durations")
+	}
+	if c.HTTPClient.MaxIdleConns < 0 || c.HTTPClient.MaxIdleConnsPerHost < 0 || c.HTTPClient.MaxConnsPerHost < 0 {
+		return fmt.Errorf("httpClient connection limits must not be negative")
+	}
+	if c.HTTPClient.KeepAlive < 0 {
+		return fmt.Errorf("httpClient.keepAlive must not be negative")
+	}
+	if c.HTTPClient.Compression != "" && c.HTTPClient.Compression != "gzip" {
+		return fmt.Errorf("unsupported httpClient.compression %q (expected \"gzip\")", c.HTTPClient.Compression)
+	}
+	if c.HTTPClient.CompressMinBytes < 0 {
+		return fmt.Errorf("httpClient.compressMinBytes must not be negative")
+	}
+	if c.HTTPClient.ProxyURL != "" {
+		if u, err := url.Parse(c.HTTPClient.ProxyURL); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid httpClient.proxyURL %q", c.HTTPClient.ProxyURL)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, sc := range c.Severities {
+		if len(sc.Values) == 0 {
+			return fmt.Errorf("severities[%d]: values is required", i)
+		}
+		for _, v := range sc.Values {
+			if seen[strings.ToLower(v)] {
+				return fmt.Errorf("severities[%d]: %q is listed more than once", i, v)
+			}
+			seen[strings.ToLower(v)] = true
+		}
+		if sc.Color != "" && !hexColorPattern.MatchString(sc.Color) {
+			return fmt.Errorf("severities[%d]: color %q is not #RRGGBB", i, sc.Color)
+		}
+		if sc.TeamsStyle != "" && !slices.Contains(teamsStyles, sc.TeamsStyle) {
+			return fmt.Errorf("severities[%d]: unknown teamsStyle %q", i, sc.TeamsStyle)
+		}
+	}
+	if c.Actions.BaseURL != "" {
+		if c.Actions.AlertmanagerURL == "" || c.Actions.Secret == "" {
+			return fmt.Errorf("actions requires alertmanagerURL and secret")
+		}
+		if c.Actions.AckDuration <= 0 {
+			return fmt.Errorf("actions.ackDuration must be a positive duration")
+		}
+	}
+	if c.SilenceAPI.AlertmanagerURL != "" {
+		if c.SilenceAPI.Token == "" {
+			return fmt.Errorf("silenceAPI requires token")
+		}
+		if c.SilenceAPI.MaxDuration <= 0 {
+			return fmt.Errorf("silenceAPI.maxDuration must be a positive duration")
+		}
+	}
+	if c.DeadLetter.Path != "" && c.DeadLetter.Token == "" {
+		return fmt.Errorf("deadLetter requires token")
+	}
+	if c.ConfigAPI.Enabled && c.ConfigAPI.Token == "" {
+		return fmt.Errorf("configAPI.token is required when configAPI is enabled")
+	}
+	if c.Dashboard.Enabled && (c.Dashboard.Interval <= 0 || c.Dashboard.Points < 2) {
+		return fmt.Errorf("dashboard.interval must be a positive duration and dashboard.points at least 2")
+	}
+	if len(c.VersionDrift.Inventories) > 0 && c.VersionDrift.Interval <= 0 {
+		return fmt.Errorf("versionDrift.interval must be a positive duration")
+	}
+	if c.Workers.Concurrency < 0 || c.Workers.Concurrency > 0 && c.Workers.QueueDepth < 1 {
+		return fmt.Errorf("workers.concurrency must not be negative and workers.queueDepth at least 1")
+	}
+	if c.Workers.Concurrency > 0 && c.QueuePath != "" {
+		return fmt.Errorf("workers cannot be combined with queuePath: the durable queue already delivers in the background")
+	}
+	if c.Storm.Threshold > 0 && c.Storm.Window <= 0 {
+		return fmt.Errorf("storm.window must be a positive duration")
+	}
+	if c.Heartbeat.Enabled && (c.Heartbeat.Interval <= 0 || c.Heartbeat.MissedHeartbeats < 1) {
+		return fmt.Errorf("heartbeat.interval must be a positive duration and heartbeat.missedHeartbeats at least 1")
+	}
+	if c.Heartbeat.Enabled && c.Heartbeat.Token == "" {
+		return fmt.Errorf("heartbeat requires token")
+	}
+	if c.Aggregator.ListenAddress != "" {
+		if c.Aggregator.TLSCertFile == "" || c.Aggregator.TLSKeyFile == "" {
+			return fmt.Errorf("aggregator requires tlsCertFile and tlsKeyFile")
+		}
+		if c.Aggregator.StaleAfter <= 0 {
+			return fmt.Errorf("aggregator.staleAfter must be a positive duration")
+		}
+	}
+	if c.Processes.CollectorURL != "" {
+		if _, err := regexp.Compile(c.Processes.AlertPattern); err != nil {
+			return fmt.Errorf("invalid processes.alertPattern: %w", err)
+		}
+		if c.Processes.Top < 1 {
+			return fmt.Errorf("processes.top must be a positive integer")
+		}
+	}
+	if c.Throttle.CollectorURL != "" {
+		if _, err := regexp.Compile(c.Throttle.AlertPattern); err != nil {
+			return fmt.Errorf("invalid throttle.alertPattern: %w", err)
+		}
+	}
+	if _, err := time.LoadLocation(c.Templates.Timezone); err != nil {
+		return fmt.Errorf("invalid templates.timezone: %w", err)
+	}
+	if c.Chassis.CollectorURL != "" {
+		if _, err := regexp.Compile(c.Chassis.AlertPattern); err != nil {
+			return fmt.Errorf("invalid chassis.alertPattern: %w", err)
+		}
+	}
+	sources := 0
+	for _, set := range []bool{c.NodeMetadata.Nodes != nil, c.NodeMetadata.File != "", c.NodeMetadata.URL != "", c.NodeMetadata.Kubernetes} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("nodeMetadata: only one of nodes, file, url and kubernetes may be set")
+	}
+	for field := range c.NodeMetadata.Fields {
+		if !slices.Contains(nodeMetadataFields, field) {
+			return fmt.Errorf("nodeMetadata.fields: unknown field %q (expected location, owner or model)", field)
+		}
+	}
+	if sources > 0 && c.NodeMetadata.CacheTTL <= 0 {
+		return fmt.Errorf("nodeMetadata.cacheTTL must be a positive duration")
+	}
+	if c.DrainTimeout <= 0 {
+		return fmt.Errorf("drainTimeout must be a positive duration")
+	}
+	if c.Readiness.BackendWindow <= 0 {
+		return fmt.Errorf("readiness.backendWindow must be a positive duration")
+	}
+	if c.Readiness.MaxQueued < 0 {
+		return fmt.Errorf("readiness.maxQueued must not be negative")
+	}
+	if c.DedupTTL < 0 {
+		return fmt.Errorf("dedupTTL must not be negative")
+	}
+	if c.IdempotencyTTL < 0 {
+		return fmt.Errorf("idempotencyTTL must not be negative")
+	}
+	if c.GroupWindow < 0 {
+		return fmt.Errorf("groupWindow must not be negative")
+	}
+	if c.GoogleChat.Format != "cards" && c.GoogleChat.Format != "text" {
+		return fmt.Errorf("unsupported Google Chat format %q (expected \"cards\" or \"text\")", c.GoogleChat.Format)
+	}
+	if t := c.GoogleChat.ThreadBy; t != "" && t != "incident" && t != "node" {
+		return fmt.Errorf("unsupported Google Chat threadBy %q (expected \"incident\" or \"node\")", t)
+	}
+	for _, domain := range c.GoogleChat.LinkDomains {
+		if domain == "" || strings.ContainsAny(domain, ":/ ") {
+			return fmt.Errorf("googleChat.linkDomains: %q is not a domain name", domain)
+		}
+	}
+	if err := c.GoogleChat.OnCall.validate(); err != nil {
+		return fmt.Errorf("googleChat.onCall: %w", err)
+	}
+	for name, wc := range map[string]WebhookConfig{
+		"googleChat": c.GoogleChat.WebhookConfig,
+		"slack":      c.Slack,
+		"teams":      c.Teams,
+		"discord":    c.Discord,
+	} {
+		for i, rc := range wc.Routes {
+			if rc.WebhookURL == "" {
+				return fmt.Errorf("%s.routes[%d]: webhookURL is required", name, i)
+			}
+			if _, err := parseMatchers(rc.Matchers); err != nil {
+				return fmt.Errorf("%s.routes[%d]: %w", name, i, err)
+			}
+			if _, err := time.LoadLocation(rc.Timezone); err != nil {
+				return fmt.Errorf("%s.routes[%d]: invalid timezone: %w", name, i, err)
+			}
+		}
+	}
+	for name, wc := range map[string]WebhookConfig{
+		"googleChat": c.GoogleChat.WebhookConfig,
+		"slack":      c.Slack,
+		"teams":      c.Teams,
+		"discord":    c.Discord,
+		"email":      c.Email.webhookConfig(),
+		"pagerduty":  c.PagerDuty.webhookConfig(),
+		"opsgenie":   c.Opsgenie.webhookConfig(),
+		"victorops":  c.VictorOps.webhookConfig(),
+		"telegram":   c.Telegram.webhookConfig(),
+		"http":       c.HTTP.webhookConfig(),
+		"jira":       c.Jira.webhookConfig(),
+	} {
+		for i, rc := range wc.Routes {
+			if _, err := parseActiveTime(rc.ActiveTime, time.UTC); err != nil {
+				return fmt.Errorf("%s.routes[%d].activeTime: %w", name, i, err)
+			}
+		}
+	}
+	for i, rc := range c.OutputRoutes {
+		if len(rc.Outputs) == 0 {
+			return fmt.Errorf("outputRoutes[%d]: outputs is required", i)
+		}
+		for _, name := range rc.Outputs {
+			if !slices.ContainsFunc(c.Outputs, func(enabled string) bool { return strings.EqualFold(strings.TrimSpace(enabled), name) }) {
+				return fmt.Errorf("outputRoutes[%d]: output %q is not enabled in outputs", i, name)
+			}
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("outputRoutes[%d]: %w", i, err)
+		}
+	}
+	for i, rc := range c.Email.Routes {
+		if len(rc.To) == 0 {
+			return fmt.Errorf("email.routes[%d]: to is required", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("email.routes[%d]: %w", i, err)
+		}
+		if _, err := time.LoadLocation(rc.Timezone); err != nil {
+			return fmt.Errorf("email.routes[%d]: invalid timezone: %w", i, err)
+		}
+	}
+	for i, rc := range c.PagerDuty.Routes {
+		if rc.RoutingKey == "" {
+			return fmt.Errorf("pagerduty.routes[%d]: routingKey is required", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("pagerduty.routes[%d]: %w", i, err)
+		}
+	}
+	for i, rc := range c.Opsgenie.Routes {
+		if rc.APIKey == "" {
+			return fmt.Errorf("opsgenie.routes[%d]: apiKey is required", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("opsgenie.routes[%d]: %w", i, err)
+		}
+	}
+	for i, rc := range c.VictorOps.Routes {
+		if rc.RoutingKey == "" {
+			return fmt.Errorf("victorops.routes[%d]: routingKey is required", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("victorops.routes[%d]: %w", i, err)
+		}
+	}
+	if (c.Telegram.BotToken == "") != (c.Telegram.ChatID == "") {
+		return fmt.Errorf("telegram: botToken and chatID must be set together")
+	}
+	for i, rc := range c.Telegram.Routes {
+		if rc.ChatID == "" {
+			return fmt.Errorf("telegram.routes[%d]: chatID is required", i)
+		}
+		if rc.BotToken == "" && c.Telegram.BotToken == "" {
+			return fmt.Errorf("telegram.routes[%d]: botToken is required without a default botToken", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("telegram.routes[%d]: %w", i, err)
+		}
+		if _, err := time.LoadLocation(rc.Timezone); err != nil {
+			return fmt.Errorf("telegram.routes[%d]: invalid timezone: %w", i, err)
+		}
+	}
+	if _, err := parseMatchers(c.Jira.Matchers); err != nil {
+		return fmt.Errorf("jira.matchers: %w", err)
+	}
+	if c.Jira.URL != "" && c.Jira.BearerToken == "" && (c.Jira.Username == "" || c.Jira.APIToken == "") {
+		return fmt.Errorf("jira requires username and apiToken, or bearerToken")
+	}
+	for i, rc := range c.Jira.Routes {
+		if rc.Project == "" {
+			return fmt.Errorf("jira.routes[%d]: project is required", i)
+		}
+		if _, err := parseMatchers(rc.Matchers); err != nil {
+			return fmt.Errorf("jira.routes[%d]: %w", i, err)
+		}
+	}
+	if err := c.HTTP.validate(); err != nil {
+		return err
+	}
+	for severity, priority := range c.Opsgenie.Priorities {
+		if !slices.Contains([]string{"P1", "P2", "P3", "P4", "P5"}, strings.ToUpper(priority)) {
+			return fmt.Errorf("opsgenie.priorities.%s: %q is not one of P1-P5", severity, priority)
+		}
+	}
+	for severity, messageType := range c.VictorOps.MessageTypes {
+		if !slices.Contains([]string{"CRITICAL", "WARNING", "INFO"}, strings.ToUpper(messageType)) {
+			return fmt.Errorf("victorops.messageTypes.%s: %q is not one of CRITICAL, WARNING and INFO", severity, messageType)
+		}
+	}
+	if t := c.Email.SMTP.TLS; t != "starttls" && t != "tls" && t != "none" {
+		return fmt.Errorf("unsupported email.smtp.tls %q (expected \"starttls\", \"tls\" or \"none\")", t)
+	}
+	if _, err := parseFilterRules(c.Filters); err != nil {
+		return err
+	}
+	if _, err := parseInhibitRules(c.InhibitRules); err != nil {
+		return err
+	}
+	if _, err := parseRedactionRules(c.Redaction); err != nil {
+		return err
+	}
+	if _, err := parseMaintenanceWindows(c.Maintenance); err != nil {
+		return err
+	}
+	if _, err := parseEscalationPolicies(c.Escalation); err != nil {
+		return err
+	}
+	for _, window := range c.SLO.Windows {
+		if window < sloBucketWidth {
+			return fmt.Errorf("slo.windows: %s is shorter than %s", window, sloBucketWidth)
+		}
+	}
+	if c.SLO.Report.WebhookURL != "" {
+		if len(c.SLO.Windows) == 0 {
+			return fmt.Errorf("slo.report requires slo.windows")
+		}
+		if !slices.ContainsFunc(c.Outputs, func(enabled string) bool { return strings.EqualFold(strings.TrimSpace(enabled), "gchat") }) {
+			return fmt.Errorf("slo.report: output \"gchat\" is not enabled in outputs")
+		}
+		if _, err := parseSLOSchedule(c.SLO.Report); err != nil {
+			return err
+		}
+	}
+	for i, pc := range c.Escalation {
+		for j, sc := range pc.Steps {
+			needed := sc.Outputs
+			if sc.Mention != "" {
+				needed = append([]string{"gchat"}, needed...)
+			}
+			for _, name := range needed {
+				if !slices.ContainsFunc(c.Outputs, func(enabled string) bool { return strings.EqualFold(strings.TrimSpace(enabled), name) }) {
+					return fmt.Errorf("escalation[%d].steps[%d]: output %q is not enabled in outputs", i, j, name)
+				}
+			}
+		}
+	}
+	if c.Timeline.UpdateInterval < 0 {
+		return fmt.Errorf("timeline.updateInterval must not be negative")
+	}
+	if c.Audit.Retention < 0 {
+		return fmt.Errorf("audit.retention must not be negative")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sampleRatio must be between 0 and 1")
+	}
+	if c.Digest.Interval < 0 {
+		return fmt.Errorf("digest.interval must not be negative")
+	}
+	if c.Digest.Interval > 0 && len(c.Digest.Severities) == 0 {
+		return fmt.Errorf("digest.severities must list at least one severity")
+	}
+	if c.RateLimit.PerMinute < 0 || c.RateLimit.Burst < 0 {
+		return fmt.Errorf("rateLimit values must not be negative")
+	}
+	if m := c.Signature.Mode; m != "" && m != "enforce" && m != "warn" {
+		return fmt.Errorf("invalid signature mode %q (expected \"enforce\" or \"warn\")", m)
+	}
+	if (c.Auth.Username == "") != (c.Auth.Password == "") {
+		return fmt.Errorf("auth.username and auth.password must be set together")
+	}
+	if c.Requests.MaxBodyBytes <= 0 {
+		return fmt.Errorf("requests.maxBodyBytes must be positive")
+	}
+	if err := c.SNMP.validate(); err != nil {
+		return err
+	}
+	if err := c.Kubernetes.validate(); err != nil {
+		return err
+	}
+	if c.Zabbix.Timezone != "" {
+		if _, err := time.LoadLocation(c.Zabbix.Timezone); err != nil {
+			return fmt.Errorf("zabbix.timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c OnCallConfig) validate() error {
+	sources := 0
+	for _, set := range []bool{len(c.Rotation.Users) > 0, c.CalendarURL != "", c.PagerDuty.ScheduleID != "", c.Opsgenie.Schedule != ""} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		return fmt.Errorf("only one of rotation, calendarURL, pagerduty and opsgenie may be set")
+	case sources == 0:
+		return nil
+	case len(c.Rotation.Users) > 0 && (c.Rotation.Start.IsZero() || c.Rotation.Shift <= 0):
+		return fmt.Errorf("rotation requires start and a positive shift")
+	case c.PagerDuty.ScheduleID != "" && c.PagerDuty.APIToken == "":
+		return fmt.Errorf("pagerduty requires apiToken")
+	case c.Opsgenie.Schedule != "" && c.Opsgenie.APIKey == "":
+		return fmt.Errorf("opsgenie requires apiKey")
+	case len(c.Severities) == 0:
+		return fmt.Errorf("severities must list at least one severity")
+	case c.CacheTTL <= 0 || c.Timeout <= 0:
+		return fmt.Errorf("cacheTTL and timeout must be positive durations")
+	}
+	return nil
+}
+
+func (c SNMPConfig) validate() error {
+	if c.ListenAddress == "" {
+		return nil
+	}
+	if len(c.Communities) == 0 && len(c.Users) == 0 {
+		return fmt.Errorf("snmp requires communities or users")
+	}
+	for i, u := range c.Users {
+		switch {
+		case u.Username == "":
+			return fmt.Errorf("snmp.users[%d]: username is required", i)
+		case u.AuthProtocol != "" && !slices.Contains([]string{"MD5", "SHA"}, strings.ToUpper(u.AuthProtocol)):
+			return fmt.Errorf("snmp.users[%d]: unsupported authProtocol %q (expected MD5 or SHA)", i, u.AuthProtocol)
+		case u.AuthProtocol != "" && len(u.AuthPassword) < 8:
+			return fmt.Errorf("snmp.users[%d]: authPassword must have at least 8 characters", i)
+		case u.PrivProtocol != "" && u.AuthProtocol == "":
+			return fmt.Errorf("snmp.users[%d]: privProtocol requires authProtocol", i)
+		case u.PrivProtocol != "" && !slices.Contains([]string{"DES", "AES"}, strings.ToUpper(u.PrivProtocol)):
+			return fmt.Errorf("snmp.users[%d]: unsupported privProtocol %q (expected DES or AES)", i, u.PrivProtocol)
+		case u.PrivProtocol != "" && len(u.PrivPassword) < 8:
+			return fmt.Errorf("snmp.users[%d]: privPassword must have at least 8 characters", i)
+		}
+	}
+	if len(c.Traps) == 0 {
+		return fmt.Errorf("snmp.traps must translate at least one trap OID")
+	}
+	for i, t := range c.Traps {
+		switch {
+		case !snmpOIDPattern.MatchString(strings.TrimPrefix(t.OID, ".")):
+			return fmt.Errorf("snmp.traps[%d]: invalid oid %q", i, t.OID)
+		case t.Alertname == "":
+			return fmt.Errorf("snmp.traps[%d]: alertname is required", i)
+		}
+		for name, oid := range t.VarbindLabels {
+			if !snmpOIDPattern.MatchString(strings.TrimPrefix(oid, ".")) {
+				return fmt.Errorf("snmp.traps[%d]: invalid OID %q of varbind label %s", i, oid, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (c KubernetesConfig) validate() error {
+	check := func(prefix string, problems []KubernetesProblemConfig) error {
+		names := make(map[string]bool, len(problems))
+		for i, p := range problems {
+			switch {
+			case p.Name == "":
+				return fmt.Errorf("%s[%d]: name is required", prefix, i)
+			case names[p.Name]:
+				return fmt.Errorf("%s[%d]: duplicate name %q", prefix, i, p.Name)
+			case p.Resolves && prefix == "kubernetes.conditions":
+				return fmt.Errorf("%s[%d]: resolves only applies to events; conditions resolve when False", prefix, i)
+			}
+			names[p.Name] = true
+		}
+		return nil
+	}
+	if err := check("kubernetes.conditions", c.Conditions); err != nil {
+		return err
+	}
+	return check("kubernetes.events", c.Events)
+}
+
+// hexColorPattern matches a "#RRGGBB" color.
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// teamsStyles are the Adaptive Card container styles.
+var teamsStyles = []string{"default", "emphasis", "good", "attention", "warning", "accent"}
+
+// snmpOIDPattern matches a dotted OID.
+var snmpOIDPattern = regexp.MustCompile(`^[0-2](\.[0-9]+)+$`)
@@ -0,0 +1,634 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxConfigAPIBody bounds the routes and templates sent to the config API.
+const maxConfigAPIBody = 1 << 20
+
+// routeSections maps the backend names of outputs to the config sections holding their
+// routes.
+var routeSections = map[string]string{
+	"gchat": "googleChat", "slack": "slack", "teams": "teams", "discord": "discord", "email": "email",
+	"pagerduty": "pagerduty", "opsgenie": "opsgenie", "victorops": "victorops", "telegram": "telegram", "http": "http",
+	"jira": "jira",
+}
+
+// editableTemplate is a message template the config API can replace: its config
+// section, the file name it is created with and how it is loaded.
+type editableTemplate struct {
+	section  string
+	file     string
+	builtin  string
+	validate func(path string) error
+}
+
+var editableTemplates = map[string]editableTemplate{
+	"gchat": {section: "googleChat", file: "message.tmpl", builtin: defaultMessageTemplate, validate: func(path string) error {
+		_, err := loadMessageTemplate(path)
+		return err
+	}},
+	"email": {section: "email", file: "email.html.tmpl", builtin: defaultEmailTemplate, validate: func(path string) error {
+		_, err := loadEmailTemplate(path)
+		return err
+	}},
+}
+
+// configAPI serves /api/routes and /api/templates, so an admin UI or script can add a
+// team's Chat space or tweak a template on a running adapter. Changes are validated like
+// a reload, written back to the config file (and template files) and applied at once.
+// The YAML document is edited in place rather than re-encoded from Config, so comments,
+// secret references and settings left at their defaults survive; routes are also
+// returned as written, with secret references unresolved.
+type configAPI struct {
+	path        string
+	token       string
+	templateDir string
+	// apply makes an edited config take effect, like a reload.
+	apply func(*Config) error
+
+	// mu serializes edits of the config file.
+	mu sync.Mutex
+}
+
+// configEditError is a rejected request, answered with status.
+type configEditError struct {
+	status        int
+	code, message string
+}
+
+func (e *configEditError) Error() string {
+	return e.message
+}
+
+// newConfigAPI returns nil when the config API is disabled. path is the config file.
+func newConfigAPI(cfg ConfigAPIConfig, path string, apply func(*Config) error) *configAPI {
+	if !cfg.Enabled {
+		return nil
+	}
+	dir := cfg.TemplateDir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	return &configAPI{path: path, token: cfg.Token, templateDir: dir, apply: apply}
+}
+
+func (c *configAPI) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+c.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleRoutes serves GET /api/routes (every backend's routes), GET, POST (append, or
+// insert before ?index=) and PUT (replace all) /api/routes/{backend}, and GET, PUT and
+// DELETE /api/routes/{backend}/{index}. Routes are JSON objects with the fields of the
+// backend's routes in the config file, e.g. {"matchers": ["team=\"ml\""], "webhookURL": ...}.
+func (c *configAPI) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(w, r) {
+		return
+	}
+	backend, position, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/routes"), "/"), "/")
+	section, ok := routeSections[backend]
+	if backend != "" && !ok {
+		writeWebhookError(w, http.StatusNotFound, "unknown_backend", fmt.Sprintf("unknown backend %q", backend))
+		return
+	}
+	index := -1
+	if position != "" {
+		var err error
+		if index, err = strconv.Atoi(position); err != nil || index < 0 {
+			writeWebhookError(w, http.StatusNotFound, "not_found", "route index must be a non-negative integer")
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && backend == "":
+		c.read(w, func(root *yaml.Node) (any, error) {
+			routes := make(map[string]any)
+			for backend, section := range routeSections {
+				if list := mappingValue(mappingValue(root, section), "routes"); list != nil && len(list.Content) > 0 {
+					var err error
+					if routes[backend], err = decodeRoutes(list); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return routes, nil
+		})
+	case r.Method == http.MethodGet:
+		c.read(w, func(root *yaml.Node) (any, error) {
+			list := mappingValue(mappingValue(root, section), "routes")
+			if index < 0 {
+				return decodeRoutes(list)
+			}
+			if list == nil || index >= len(list.Content) {
+				return nil, routeNotFound(backend, index)
+			}
+			var v any
+			err := list.Content[index].Decode(&v)
+			return v, err
+		})
+	case r.Method == http.MethodPost && index < 0:
+		route, ok := decodeRouteBody(w, r, yaml.MappingNode)
+		if !ok {
+			return
+		}
+		c.write(w, r, http.StatusCreated, func(root *yaml.Node) (any, error) {
+			list := routesNode(root, section)
+			at := len(list.Content)
+			if v := r.URL.Query().Get("index"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 || n > len(list.Content) {
+					return nil, &configEditError{status: http.StatusBadRequest, code: "invalid_index", message: fmt.Sprintf("index must be between 0 and %d", len(list.Content))}
+				}
+				at = n
+			}
+			list.Content = append(list.Content[:at], append([]*yaml.Node{route}, list.Content[at:]...)...)
+			return decodeRoutes(list)
+		})
+	case r.Method == http.MethodPut && index < 0:
+		routes, ok := decodeRouteBody(w, r, yaml.SequenceNode)
+		if !ok {
+			return
+		}
+		c.write(w, r, http.StatusOK, func(root *yaml.Node) (any, error) {
+			list := routesNode(root, section)
+			list.Content = routes.Content
+			return decodeRoutes(list)
+		})
+	case r.Method == http.MethodPut:
+		route, ok := decodeRouteBody(w, r, yaml.MappingNode)
+		if !ok {
+			return
+		}
+		c.write(w, r, http.StatusOK, func(root *yaml.Node) (any, error) {
+			list := routesNode(root, section)
+			if index >= len(list.Content) {
+				return nil, routeNotFound(backend, index)
+			}
+			list.Content[index] = route
+			return decodeRoutes(list)
+		})
+	case r.Method == http.MethodDelete && index >= 0:
+		c.write(w, r, http.StatusOK, func(root *yaml.Node) (any, error) {
+			list := routesNode(root, section)
+			if index >= len(list.Content) {
+				return nil, routeNotFound(backend, index)
+			}
+			list.Content = append(list.Content[:index], list.Content[index+1:]...)
+			return decodeRoutes(list)
+		})
+	default:
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	}
+}
+
+// decodeRoutes returns a routes list as JSON-encodable values; a missing list is empty.
+func decodeRoutes(list *yaml.Node) (any, error) {
+	routes := []any{}
+	if list != nil {
+		if err := list.Decode(&routes); err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+func routeNotFound(backend string, index int) error {
+	return &configEditError{status: http.StatusNotFound, code: "not_found", message: fmt.Sprintf("%s has no route %d", backend, index)}
+}
+
+// templateSummary is how /api/templates lists the templates.
+type templateSummary struct {
+	Name string `json:"name"`
+	// Path is the template file, empty while the built-in template is used.
+	Path string `json:"path"`
+}
+
+// handleTemplates serves GET /api/templates (the editable templates and their files),
+// GET /api/templates/{name} (the template text, the built-in one if none is set), PUT
+// /api/templates/{name} (replace the text; a template without a file is created in
+// templateDir) and DELETE /api/templates/{name} (back to the built-in template; the file
+// is kept). The names are gchat and email.
+func (c *configAPI) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(w, r) {
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/templates"), "/")
+	tmpl, ok := editableTemplates[name]
+	if name != "" && !ok {
+		writeWebhookError(w, http.StatusNotFound, "unknown_template", fmt.Sprintf("unknown template %q", name))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		c.read(w, func(root *yaml.Node) (any, error) {
+			summaries := []templateSummary{}
+			for _, name := range []string{"gchat", "email"} {
+				summaries = append(summaries, templateSummary{Name: name, Path: templatePath(root, editableTemplates[name])})
+			}
+			return summaries, nil
+		})
+	case r.Method == http.MethodGet:
+		c.mu.Lock()
+		root, err := c.document()
+		c.mu.Unlock()
+		if err != nil {
+			loggerFrom(r.Context()).Error("Error reading config file", "path", c.path, "err", err)
+			writeWebhookError(w, http.StatusInternalServerError, "read_failed", "Error reading the config file")
+			return
+		}
+		text := []byte(tmpl.builtin)
+		if path := templatePath(root, tmpl); path != "" {
+			if text, err = os.ReadFile(path); err != nil {
+				loggerFrom(r.Context()).Error("Error reading template", "path", path, "err", err)
+				writeWebhookError(w, http.StatusInternalServerError, "read_failed", "Error reading the template file")
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(text)
+	case r.Method == http.MethodPut:
+		text, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigAPIBody))
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		c.writeTemplate(w, r, name, tmpl, text)
+	case r.Method == http.MethodDelete && name != "":
+		c.write(w, r, http.StatusOK, func(root *yaml.Node) (any, error) {
+			if section := mappingValue(root, tmpl.section); section != nil {
+				deleteMappingValue(section, "templatePath")
+			}
+			return templateSummary{Name: name}, nil
+		})
+	default:
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	}
+}
+
+// writeTemplate replaces the text of tmpl, after loading it like the adapter does. The
+// file is replaced atomically, so a reload never reads half of it.
+func (c *configAPI) writeTemplate(w http.ResponseWriter, r *http.Request, name string, tmpl editableTemplate, text []byte) {
+	logger := loggerFrom(r.Context())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	root, err := c.document()
+	if err != nil {
+		logger.Error("Error reading config file", "path", c.path, "err", err)
+		writeWebhookError(w, http.StatusInternalServerError, "read_failed", "Error reading the config file")
+		return
+	}
+	path := templatePath(root, tmpl)
+	configured := path != ""
+	if !configured {
+		path = filepath.Join(c.templateDir, tmpl.file)
+	}
+
+	staged, err := stageFile(path, text)
+	if err != nil {
+		logger.Error("Error writing template", "path", path, "err", err)
+		writeWebhookError(w, http.StatusInternalServerError, "write_failed", "Error writing the template file")
+		return
+	}
+	defer os.Remove(staged)
+	if err := tmpl.validate(staged); err != nil {
+		writeWebhookError(w, http.StatusBadRequest, "invalid_template", err.Error())
+		return
+	}
+	previous, readErr := os.ReadFile(path)
+	if err := replaceFile(path, text); err != nil {
+		logger.Error("Error writing template", "path", path, "err", err)
+		writeWebhookError(w, http.StatusInternalServerError, "write_failed", "Error writing the template file")
+		return
+	}
+	// Put the previous template back if the config cannot take the new one, so the file
+	// and the running adapter agree.
+	restore := func() {
+		if readErr == nil {
+			replaceFile(path, previous)
+		} else {
+			os.Remove(path)
+		}
+	}
+
+	if configured {
+		next, err := loadConfigFile(c.path)
+		if err == nil {
+			err = c.apply(next)
+		}
+		if err != nil {
+			restore()
+			logger.Error("Error applying template", "path", path, "err", err)
+			writeWebhookError(w, http.StatusInternalServerError, "apply_failed", err.Error())
+			return
+		}
+	} else if err := c.editLocked(root, func(root *yaml.Node) error {
+		setMappingValue(sectionNode(root, tmpl.section), "templatePath", &yaml.Node{Kind: yaml.ScalarNode, Value: path})
+		return nil
+	}); err != nil {
+		restore()
+		writeEditError(w, r, err)
+		return
+	}
+	logger.Info("Template changed through the config API", "path", path, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templateSummary{Name: name, Path: path})
+}
+
+// templatePath is the template file configured for tmpl, empty for the built-in one.
+func templatePath(root *yaml.Node, tmpl editableTemplate) string {
+	if v := mappingValue(mappingValue(root, tmpl.section), "templatePath"); v != nil {
+		return v.Value
+	}
+	return ""
+}
+
+// read answers with the JSON of what get extracts from the config document.
+func (c *configAPI) read(w http.ResponseWriter, get func(root *yaml.Node) (any, error)) {
+	c.mu.Lock()
+	root, err := c.document()
+	c.mu.Unlock()
+	var v any
+	if err == nil {
+		v, err = get(root)
+	}
+	var editErr *configEditError
+	switch {
+	case errors.As(err, &editErr):
+		writeWebhookError(w, editErr.status, editErr.code, editErr.message)
+	case err != nil:
+		writeWebhookError(w, http.StatusInternalServerError, "read_failed", "Error reading the config file: "+err.Error())
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// write applies change to the config document, and once the result is validated,
+// written and applied answers with status and the JSON of what change returned.
+func (c *configAPI) write(w http.ResponseWriter, r *http.Request, status int, change func(root *yaml.Node) (any, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result any
+	root, err := c.document()
+	if err == nil {
+		err = c.editLocked(root, func(root *yaml.Node) error {
+			var err error
+			result, err = change(root)
+			return err
+		})
+	}
+	if err != nil {
+		writeEditError(w, r, err)
+		return
+	}
+	loggerFrom(r.Context()).Info("Config changed through the config API", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeEditError(w http.ResponseWriter, r *http.Request, err error) {
+	var editErr *configEditError
+	if errors.As(err, &editErr) {
+		writeWebhookError(w, editErr.status, editErr.code, editErr.message)
+		return
+	}
+	loggerFrom(r.Context()).Error("Error changing config through the config API", "err", err)
+	writeWebhookError(w, http.StatusInternalServerError, "write_failed", err.Error())
+}
+
+// editLocked changes root, validates the result like a reload, and writes and applies
+// it. The file is only replaced once the result is valid, and restored if it cannot be
+// applied. c.mu must be held.
+//
+// Secret references are only resolved where the file on disk has them: a reference the
+// change added or rewrote would let API callers read local files, the environment or the
+// secret managers, so the change is rejected.
+func (c *configAPI) editLocked(root *yaml.Node, change func(root *yaml.Node) error) error {
+	fromFile := secretScalars(root, make(map[*yaml.Node]string))
+	if err := change(root); err != nil {
+		return err
+	}
+	for n, v := range secretScalars(root, make(map[*yaml.Node]string)) {
+		if ref, ok := fromFile[n]; !ok || ref != v {
+			return &configEditError{status: http.StatusBadRequest, code: "invalid_config", message: "secret references can only be set in the config file"}
+		}
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	encoder.Close()
+	next, err := parseConfig(out.Bytes(), c.path)
+	if err != nil {
+		return &configEditError{status: http.StatusBadRequest, code: "invalid_config", message: err.Error()}
+	}
+
+	previous, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if err := replaceFile(c.path, out.Bytes()); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	if err := c.apply(next); err != nil {
+		replaceFile(c.path, previous)
+		return fmt.Errorf("applying config: %w", err)
+	}
+	return nil
+}
+
+// document reads the config file as a YAML mapping node.
+func (c *configAPI) document() (*yaml.Node, error) {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config is not a YAML mapping")
+	}
+	// Comments before the first key belong to the document; keep them on the mapping.
+	root := doc.Content[0]
+	root.HeadComment = strings.TrimSpace(doc.HeadComment + "\n" + root.HeadComment)
+	return root, nil
+}
+
+// decodeRouteBody reads a JSON route (kind yaml.MappingNode) or list of routes (kind
+// yaml.SequenceNode) from the request as YAML nodes in block style.
+func decodeRouteBody(w http.ResponseWriter, r *http.Request, kind yaml.Kind) (*yaml.Node, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigAPIBody))
+	if err != nil {
+		writeDecodeError(w, err)
+		return nil, false
+	}
+	// JSON is YAML, so the route keeps its field order.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(body, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != kind {
+		want := "a JSON object"
+		if kind == yaml.SequenceNode {
+			want = "a JSON array of objects"
+		}
+		writeWebhookError(w, http.StatusBadRequest, "invalid_payload", "request body must be "+want)
+		return nil, false
+	}
+	node := doc.Content[0]
+	if hasSecretReference(node) {
+		writeWebhookError(w, http.StatusBadRequest, "invalid_payload", "secret references cannot be set through the config API; put them in the config file")
+		return nil, false
+	}
+	blockStyle(node)
+	return node, true
+}
+
+// hasSecretReference reports whether a scalar under n contains a secret reference.
+func hasSecretReference(n *yaml.Node) bool {
+	return len(secretScalars(n, make(map[*yaml.Node]string))) > 0
+}
+
+// secretScalars adds the scalars under n that contain a secret reference to scalars,
+// with their values, and returns it.
+func secretScalars(n *yaml.Node, scalars map[*yaml.Node]string) map[*yaml.Node]string {
+	if n.Kind == yaml.ScalarNode && secretReference.MatchString(n.Value) {
+		scalars[n] = n.Value
+	}
+	for _, child := range n.Content {
+		secretScalars(child, scalars)
+	}
+	return scalars
+}
+
+// blockStyle drops the flow and quoting style JSON is parsed with, so edited routes look
+// like the rest of the file; strings that would read as other types stay quoted.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		var plain any
+		if yaml.Unmarshal([]byte(n.Value), &plain) != nil || plain != n.Value {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+// mappingValue returns the value of key in mapping m, or nil if m is not a mapping or
+// has no such key.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+func deleteMappingValue(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// sectionNode returns the mapping of a top-level config section, adding it if missing.
+func sectionNode(root *yaml.Node, section string) *yaml.Node {
+	node := mappingValue(root, section)
+	if node == nil || node.Kind != yaml.MappingNode {
+		node = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(root, section, node)
+	}
+	return node
+}
+
+// routesNode returns the routes list of a config section, adding it if missing.
+func routesNode(root *yaml.Node, section string) *yaml.Node {
+	m := sectionNode(root, section)
+	node := mappingValue(m, "routes")
+	if node == nil || node.Kind != yaml.SequenceNode {
+		node = &yaml.Node{Kind: yaml.SequenceNode}
+		setMappingValue(m, "routes", node)
+	}
+	return node
+}
+
+// stageFile writes content to a new file next to path, to be renamed over it.
+func stageFile(path string, content []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// replaceFile atomically replaces the file at path with content, keeping its mode; a
+// new file is readable by all, like the files it sits next to.
+func replaceFile(path string, content []byte) error {
+	staged, err := stageFile(path, content)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	os.Chmod(staged, mode)
+	if err := os.Rename(staged, path); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	return nil
+}
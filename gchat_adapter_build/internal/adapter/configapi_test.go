@@ -0,0 +1,238 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configAPITestConfig = `# Adapter under test.
+outputs: [gchat]
+googleChat:
+  # The default space.
+  webhookURL: https://chat.googleapis.com/v1/spaces/default/messages?key=k
+`
+
+// newTestConfigAPI serves a config API for a config file with configAPITestConfig. The
+// returned pointer holds the config last applied, nil until one is.
+func newTestConfigAPI(t *testing.T) (*configAPI, string, **Config) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(configAPITestConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	applied := new(*Config)
+	api := newConfigAPI(ConfigAPIConfig{Enabled: true, Token: "t0ken"}, path, func(cfg *Config) error {
+		*applied = cfg
+		return nil
+	})
+	return api, path, applied
+}
+
+func configAPIRequest(api *configAPI, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer t0ken")
+	rec := httptest.NewRecorder()
+	if strings.HasPrefix(target, "/api/templates") {
+		api.handleTemplates(rec, req)
+	} else {
+		api.handleRoutes(rec, req)
+	}
+	return rec
+}
+
+func TestConfigAPIAuthorization(t *testing.T) {
+	api, _, _ := newTestConfigAPI(t)
+	for _, header := range []string{"", "Bearer wrong", "t0ken"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/routes", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		api.handleRoutes(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, rec.Code)
+		}
+	}
+	if newConfigAPI(ConfigAPIConfig{}, "config.yml", nil) != nil {
+		t.Error("newConfigAPI() is enabled by default")
+	}
+}
+
+func TestConfigAPIRoutes(t *testing.T) {
+	api, path, applied := newTestConfigAPI(t)
+
+	rec := configAPIRequest(api, http.MethodPost, "/api/routes/gchat", `{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages?key=k"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", rec.Code, rec.Body)
+	}
+	if *applied == nil || len((*applied).GoogleChat.Routes) != 1 {
+		t.Fatalf("POST: applied config %+v, want one Google Chat route", *applied)
+	}
+	content, _ := os.ReadFile(path)
+	for _, want := range []string{"# Adapter under test.", "# The default space.", `- matchers:`, "spaces/ml/messages"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("config file lacks %q:\n%s", want, content)
+		}
+	}
+
+	rec = configAPIRequest(api, http.MethodPost, "/api/routes/gchat?index=0", `{"matchers":["team=\"storage\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/storage/messages?key=k"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST ?index=0: status %d: %s", rec.Code, rec.Body)
+	}
+	var routes []map[string]any
+	rec = configAPIRequest(api, http.MethodGet, "/api/routes/gchat", "")
+	if err := json.NewDecoder(rec.Body).Decode(&routes); err != nil || len(routes) != 2 || !strings.Contains(routes[0]["webhookURL"].(string), "storage") {
+		t.Fatalf("GET: %v %v, want the storage route first of two", routes, err)
+	}
+
+	rec = configAPIRequest(api, http.MethodDelete, "/api/routes/gchat/0", "")
+	if rec.Code != http.StatusOK || len((*applied).GoogleChat.Routes) != 1 {
+		t.Fatalf("DELETE: status %d, %d routes applied", rec.Code, len((*applied).GoogleChat.Routes))
+	}
+	if rec := configAPIRequest(api, http.MethodDelete, "/api/routes/gchat/5", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a missing route: status %d, want 404", rec.Code)
+	}
+}
+
+func TestConfigAPIRejectsInvalidRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"unknown backend", http.MethodPost, "/api/routes/fax", `{}`, http.StatusNotFound, "unknown_backend"},
+		{"bad index", http.MethodGet, "/api/routes/gchat/first", "", http.StatusNotFound, "not_found"},
+		{"not an object", http.MethodPost, "/api/routes/gchat", `["team=\"ml\""]`, http.StatusBadRequest, "invalid_payload"},
+		{"not JSON", http.MethodPost, "/api/routes/gchat", `{"matchers":`, http.StatusBadRequest, "invalid_payload"},
+		{"not a list", http.MethodPut, "/api/routes/gchat", `{"matchers":[]}`, http.StatusBadRequest, "invalid_payload"},
+		{"unknown field", http.MethodPost, "/api/routes/gchat", `{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages","colour":"red"}`, http.StatusBadRequest, "invalid_config"},
+		{"invalid matcher", http.MethodPost, "/api/routes/gchat", `{"matchers":["team=~\"(\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages"}`, http.StatusBadRequest, "invalid_config"},
+		{"index out of range", http.MethodPost, "/api/routes/gchat?index=3", `{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages"}`, http.StatusBadRequest, "invalid_index"},
+		{"secret reference", http.MethodPost, "/api/routes/gchat", `{"matchers":["team=\"ml\""],"webhookURL":"${file:/etc/gchat-adapter/other-webhook}"}`, http.StatusBadRequest, "invalid_payload"},
+		{"secret reference in a list", http.MethodPut, "/api/routes/gchat", `[{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages?key=${env:CHAT_KEY}"}]`, http.StatusBadRequest, "invalid_payload"},
+		{"too large", http.MethodPost, "/api/routes/gchat", `{"matchers":["` + strings.Repeat("x", maxConfigAPIBody) + `"]}`, http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{"method", http.MethodPatch, "/api/routes/gchat", `{}`, http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, path, applied := newTestConfigAPI(t)
+			rec := configAPIRequest(api, tt.method, tt.target, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var got webhookError
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.Error != tt.wantError {
+				t.Errorf("error %q (%v), want %q", got.Error, err, tt.wantError)
+			}
+			if content, _ := os.ReadFile(path); string(content) != configAPITestConfig {
+				t.Errorf("config file changed:\n%s", content)
+			}
+			if *applied != nil {
+				t.Error("a rejected change was applied")
+			}
+		})
+	}
+}
+
+func TestConfigAPIRestoresFileWhenApplyFails(t *testing.T) {
+	api, path, _ := newTestConfigAPI(t)
+	api.apply = func(*Config) error { return errors.New("backend down") }
+
+	rec := configAPIRequest(api, http.MethodPost, "/api/routes/gchat", `{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages"}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", rec.Code)
+	}
+	if content, _ := os.ReadFile(path); string(content) != configAPITestConfig {
+		t.Errorf("config file was not restored:\n%s", content)
+	}
+}
+
+func TestConfigAPISecretReferences(t *testing.T) {
+	t.Setenv("CONFIG_API_TEST_WEBHOOK", "https://chat.googleapis.com/v1/spaces/secret/messages?key=k")
+	api, path, applied := newTestConfigAPI(t)
+	const withReference = "outputs: [gchat]\ngoogleChat:\n  webhookURL: ${env:CONFIG_API_TEST_WEBHOOK}\n"
+	if err := os.WriteFile(path, []byte(withReference), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// References in the file are still resolved, and written back as references.
+	rec := configAPIRequest(api, http.MethodPost, "/api/routes/gchat", `{"matchers":["team=\"ml\""],"webhookURL":"https://chat.googleapis.com/v1/spaces/ml/messages?key=k"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", rec.Code, rec.Body)
+	}
+	if got := (*applied).GoogleChat.WebhookURL; !strings.Contains(got, "spaces/secret") {
+		t.Errorf("applied webhookURL %q, want the resolved secret", got)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "${env:CONFIG_API_TEST_WEBHOOK}") || strings.Contains(string(content), "spaces/secret") {
+		t.Errorf("config file:\n%s\nwant the reference, not the secret", content)
+	}
+
+	// Edits that add or rewrite a reference are rejected whatever handler makes them.
+	for name, change := range map[string]func(root *yaml.Node) error{
+		"added": func(root *yaml.Node) error {
+			setMappingValue(sectionNode(root, "googleChat"), "templatePath", &yaml.Node{Kind: yaml.ScalarNode, Value: "${file:/etc/passwd}"})
+			return nil
+		},
+		"rewritten": func(root *yaml.Node) error {
+			mappingValue(mappingValue(root, "googleChat"), "webhookURL").Value = "${env:HOME}"
+			return nil
+		},
+	} {
+		*applied = nil
+		api.mu.Lock()
+		root, err := api.document()
+		if err == nil {
+			err = api.editLocked(root, change)
+		}
+		api.mu.Unlock()
+		var editErr *configEditError
+		if !errors.As(err, &editErr) || editErr.status != http.StatusBadRequest {
+			t.Errorf("%s reference: editLocked() = %v, want a 400", name, err)
+		}
+		if *applied != nil {
+			t.Errorf("%s reference: the change was applied", name)
+		}
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(content) {
+		t.Errorf("config file changed:\n%s", after)
+	}
+}
+
+func TestConfigAPITemplates(t *testing.T) {
+	api, path, applied := newTestConfigAPI(t)
+
+	rec := configAPIRequest(api, http.MethodPut, "/api/templates/gchat", "{{ .Status ")
+	if rec.Code != http.StatusBadRequest || *applied != nil {
+		t.Fatalf("PUT of an invalid template: status %d, want 400 and nothing applied", rec.Code)
+	}
+
+	rec = configAPIRequest(api, http.MethodPut, "/api/templates/gchat", "{{ .Status }}: {{ len .Alerts }} alerts")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", rec.Code, rec.Body)
+	}
+	want := filepath.Join(filepath.Dir(path), "message.tmpl")
+	if *applied == nil || (*applied).GoogleChat.TemplatePath != want {
+		t.Fatalf("applied config does not use %s", want)
+	}
+	rec = configAPIRequest(api, http.MethodGet, "/api/templates/gchat", "")
+	if rec.Body.String() != "{{ .Status }}: {{ len .Alerts }} alerts" {
+		t.Errorf("GET = %q", rec.Body)
+	}
+
+	if rec := configAPIRequest(api, http.MethodGet, "/api/templates/sms", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown template: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// dashboard serves a live overview at /dashboard: firing alerts and recent deliveries
+// from the alert history, the silences in Alertmanager, and recent utilization and
+// temperature of every GPU read from the gpu-collectors, scraped or reported to the
+// aggregator.
+type dashboard struct {
+	// history is nil unless the alert history is enabled; the alert lists are empty then.
+	history *alertHistory
+	// alertmanagerURL is empty when no Alertmanager is configured for silences.
+	alertmanagerURL string
+	collectors      []string
+	interval        time.Duration
+	points          int
+	client          *http.Client
+
+	mu   sync.Mutex
+	gpus map[gpuSeriesKey]*gpuSeries
+}
+
+type gpuSeriesKey struct {
+	node, gpu string
+}
+
+// gpuSeries holds the latest readings of one GPU, oldest first.
+type gpuSeries struct {
+	Node        string      `json:"node"`
+	GPU         string      `json:"gpu"`
+	Name        string      `json:"name"`
+	Utilization []float64   `json:"utilization"`
+	Temperature []float64   `json:"temperature"`
+	Times       []time.Time `json:"times"`
+}
+
+// dashboardState is what /api/dashboard returns.
+type dashboardState struct {
+	GeneratedAt time.Time         `json:"generatedAt"`
+	History     bool              `json:"history"`
+	Firing      []historyRecord   `json:"firing"`
+	Recent      []historyRecord   `json:"recent"`
+	Silences    []silenceSummary  `json:"silences"`
+	GPUs        []gpuSeries       `json:"gpus"`
+	Errors      map[string]string `json:"errors,omitempty"`
+}
+
+// newDashboard returns nil when the dashboard is disabled. history may be nil.
+func newDashboard(cfg DashboardConfig, history *alertHistory, alertmanagerURL string) *dashboard {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &dashboard{
+		history:         history,
+		alertmanagerURL: strings.TrimRight(alertmanagerURL, "/"),
+		collectors:      cfg.Collectors,
+		interval:        cfg.Interval,
+		points:          cfg.Points,
+		client:          &http.Client{Timeout: cfg.Interval},
+		gpus:            make(map[gpuSeriesKey]*gpuSeries),
+	}
+}
+
+// run scrapes the collectors right away and then every interval, for as long as the
+// process runs.
+func (d *dashboard) run() {
+	for {
+		for _, collector := range d.collectors {
+			if err := d.scrape(collector); err != nil {
+				slog.Warn("Error scraping gpu-collector for the dashboard", "url", collector, "err", err)
+			}
+		}
+		time.Sleep(d.interval)
+	}
+}
+
+// scrape adds the current utilization and temperature of the collector's GPUs to their
+// series. The node is the collector's host, since the collector does not label it.
+func (d *dashboard) scrape(collectorURL string) error {
+	families, err := scrapeMetrics(d.client, collectorURL)
+	if err != nil {
+		return err
+	}
+	node := collectorURL
+	if u, err := url.Parse(collectorURL); err == nil {
+		node = u.Host
+		if host, _, err := net.SplitHostPort(u.Host); err == nil {
+			node = host
+		}
+	}
+	d.record(node, families)
+	return nil
+}
+
+// record adds the utilization and temperature of a node's GPUs, read from a scrape or
+// reported to the aggregator, to their series.
+func (d *dashboard) record(node string, families map[string]*dto.MetricFamily) {
+	now := time.Now().UTC()
+	readings := make(map[gpuSeriesKey]*[2]float64)
+	names := make(map[gpuSeriesKey]string)
+	for i, metric := range []string{"gpu_utilization_percent", "gpu_temperature_celsius"} {
+		family, ok := families[metric]
+		if !ok {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := metricLabels(m)
+			key := gpuSeriesKey{node, labels["gpu"]}
+			if readings[key] == nil {
+				readings[key] = &[2]float64{-1, -1}
+			}
+			readings[key][i] = metricValue(m)
+			names[key] = labels["name"]
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, r := range readings {
+		s, ok := d.gpus[key]
+		if !ok {
+			s = &gpuSeries{Node: key.node, GPU: key.gpu}
+			d.gpus[key] = s
+		}
+		s.Name = names[key]
+		// -1 marks a reading the GPU does not report; the page leaves a gap for it.
+		s.Utilization = lastN(append(s.Utilization, r[0]), d.points)
+		s.Temperature = lastN(append(s.Temperature, r[1]), d.points)
+		s.Times = lastN(append(s.Times, now), d.points)
+	}
+}
+
+// lastN returns the last n elements of s.
+func lastN[T any](s []T, n int) []T {
+	if len(s) <= n {
+		return s
+	}
+	return append(s[:0:0], s[len(s)-n:]...)
+}
+
+// handlePage serves the dashboard itself.
+func (d *dashboard) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// handleState serves GET /api/dashboard, the data the page polls. Sources that fail
+// are reported in errors and leave their section empty.
+func (d *dashboard) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	logger := loggerFrom(r.Context())
+	state := dashboardState{
+		GeneratedAt: time.Now().UTC(),
+		History:     d.history != nil,
+		Firing:      []historyRecord{},
+		Recent:      []historyRecord{},
+		Silences:    []silenceSummary{},
+		GPUs:        d.series(),
+		Errors:      make(map[string]string),
+	}
+
+	if d.history != nil {
+		firing, err := d.history.firing(time.Now().Add(-7 * 24 * time.Hour))
+		if err != nil {
+			logger.Error("Error querying firing alerts", "err", err)
+			state.Errors["firing"] = "Error querying the alert history"
+		} else {
+			state.Firing = firing
+		}
+		recent, err := d.history.query(historyQuery{Since: time.Now().Add(-24 * time.Hour), Limit: 50})
+		if err != nil {
+			logger.Error("Error querying alert history", "err", err)
+			state.Errors["recent"] = "Error querying the alert history"
+		} else {
+			state.Recent = recent
+		}
+	}
+
+	if d.alertmanagerURL != "" {
+		silences, err := fetchSilences(d.client, d.alertmanagerURL)
+		if err != nil {
+			logger.Warn("Error listing silences for the dashboard", "err", err)
+			state.Errors["silences"] = "Error listing silences in Alertmanager"
+		}
+		for _, silence := range silences {
+			state.Silences = append(state.Silences, summarizeSilence(silence.ID, silence.Status.State, silence.alertmanagerSilence))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// series returns a copy of every GPU's series, ordered by node and GPU.
+func (d *dashboard) series() []gpuSeries {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	series := make([]gpuSeries, 0, len(d.gpus))
+	for _, s := range d.gpus {
+		c := *s
+		c.Utilization = append([]float64(nil), s.Utilization...)
+		c.Temperature = append([]float64(nil), s.Temperature...)
+		c.Times = append([]time.Time(nil), s.Times...)
+		series = append(series, c)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Node != series[j].Node {
+			return series[i].Node < series[j].Node
+		}
+		return series[i].GPU < series[j].GPU
+	})
+	return series
+}
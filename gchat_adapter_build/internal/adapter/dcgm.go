@@ -0,0 +1,195 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gpuHealthAnnotation is the annotation the DCGM enricher adds with a one-line health
+// summary; the rich message formats display it next to the alert summary.
+const gpuHealthAnnotation = "gpu_health"
+
+// dcgmEnricher looks up the alerting node's dcgm-exporter and annotates alerts with
+// DCGM health data (XID errors, NVLink errors, thermal violations, retired pages).
+type dcgmEnricher struct {
+	urlTemplate string
+	client      *http.Client
+}
+
+// dcgmHealth is the health state of one GPU as reported by dcgm-exporter.
+type dcgmHealth struct {
+	index               string
+	uuid                string
+	xid                 float64
+	nvlinkErrors        float64
+	thermalViolationSec float64
+	retiredPages        float64
+	retirementPending   bool
+}
+
+// newDCGMEnricher returns nil when no exporter URL is configured.
+func newDCGMEnricher(cfg DCGMConfig) *dcgmEnricher {
+	if cfg.ExporterURL == "" {
+		return nil
+	}
+	return &dcgmEnricher{urlTemplate: cfg.ExporterURL, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// enrich adds the DCGM annotations to every alert it can resolve to a dcgm-exporter.
+// Lookup failures are logged and leave the alert unchanged: enrichment must never
+// keep an alert from being delivered.
+func (e *dcgmEnricher) enrich(payload AlertmanagerPayload) AlertmanagerPayload {
+	cache := make(map[string][]dcgmHealth)
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alerts[i] = alert
+
+		url := nodeURL(e.urlTemplate, alert.Labels["instance"])
+		if url == "" {
+			continue
+		}
+		gpus, ok := cache[url]
+		if !ok {
+			var err error
+			gpus, err = e.fetch(url)
+			if err != nil {
+				slog.Warn("Error fetching DCGM health", "url", url, "err", err)
+			}
+			cache[url] = gpus
+		}
+
+		if annotations := dcgmAnnotations(alert.Labels, gpus); len(annotations) > 0 {
+			alerts[i] = withAnnotations(alert, annotations)
+		}
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (e *dcgmEnricher) fetch(url string) ([]dcgmHealth, error) {
+	families, err := scrapeMetrics(e.client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	byIndex := make(map[string]*dcgmHealth)
+	each := func(name string, apply func(h *dcgmHealth, v float64)) {
+		for _, m := range families[name].GetMetric() {
+			var index, uuid string
+			for _, l := range m.GetLabel() {
+				switch l.GetName() {
+				case "gpu":
+					index = l.GetValue()
+				case "UUID":
+					uuid = l.GetValue()
+				}
+			}
+			h, ok := byIndex[index]
+			if !ok {
+				h = &dcgmHealth{index: index, uuid: uuid}
+				byIndex[index] = h
+			}
+			apply(h, metricValue(m))
+		}
+	}
+	each("DCGM_FI_DEV_XID_ERRORS", func(h *dcgmHealth, v float64) { h.xid = v })
+	for _, name := range []string{
+		"DCGM_FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_TOTAL",
+		"DCGM_FI_DEV_NVLINK_CRC_DATA_ERROR_COUNT_TOTAL",
+		"DCGM_FI_DEV_NVLINK_REPLAY_ERROR_COUNT_TOTAL",
+		"DCGM_FI_DEV_NVLINK_RECOVERY_ERROR_COUNT_TOTAL",
+	} {
+		each(name, func(h *dcgmHealth, v float64) { h.nvlinkErrors += v })
+	}
+	each("DCGM_FI_DEV_THERMAL_VIOLATION", func(h *dcgmHealth, v float64) { h.thermalViolationSec = v / 1e6 })
+	each("DCGM_FI_DEV_RETIRED_SBE", func(h *dcgmHealth, v float64) { h.retiredPages += v })
+	each("DCGM_FI_DEV_RETIRED_DBE", func(h *dcgmHealth, v float64) { h.retiredPages += v })
+	each("DCGM_FI_DEV_RETIRED_PENDING", func(h *dcgmHealth, v float64) { h.retirementPending = v > 0 })
+
+	gpus := make([]dcgmHealth, 0, len(byIndex))
+	for _, h := range byIndex {
+		gpus = append(gpus, *h)
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].index < gpus[j].index })
+	return gpus, nil
+}
+
+// dcgmAnnotations picks the GPU the alert is about (by its gpu or UUID label) or, for
+// node-level alerts, every GPU with a health problem, and renders the annotations.
+func dcgmAnnotations(labels map[string]string, gpus []dcgmHealth) map[string]string {
+	var matched []dcgmHealth
+	for _, h := range gpus {
+		if (labels["gpu"] != "" && labels["gpu"] == h.index) || (labels["UUID"] != "" && labels["UUID"] == h.uuid) {
+			matched = []dcgmHealth{h}
+			break
+		}
+	}
+	perGPU := matched != nil
+	if !perGPU {
+		matched = gpus
+	}
+
+	annotations := make(map[string]string)
+	var summaries []string
+	for _, h := range matched {
+		summary := h.summary()
+		if summary == "" {
+			continue
+		}
+		if perGPU {
+			summaries = append(summaries, summary)
+		} else {
+			summaries = append(summaries, fmt.Sprintf("GPU %s: %s", h.index, summary))
+		}
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+	annotations[gpuHealthAnnotation] = strings.Join(summaries, "; ")
+
+	if perGPU {
+		h := matched[0]
+		if h.xid > 0 {
+			annotations["dcgm_xid_error"] = strconv.FormatFloat(h.xid, 'f', -1, 64)
+		}
+		if h.nvlinkErrors > 0 {
+			annotations["dcgm_nvlink_errors"] = strconv.FormatFloat(h.nvlinkErrors, 'f', -1, 64)
+		}
+		if h.thermalViolationSec > 0 {
+			annotations["dcgm_thermal_violation_seconds"] = strconv.FormatFloat(h.thermalViolationSec, 'f', 1, 64)
+		}
+		if h.retiredPages > 0 {
+			annotations["dcgm_retired_pages"] = strconv.FormatFloat(h.retiredPages, 'f', -1, 64)
+		}
+		if h.retirementPending {
+			annotations["dcgm_retired_pages_pending"] = "true"
+		}
+	}
+	return annotations
+}
+
+// summary renders the GPU's problems, e.g. "XID 79 · NVLink errors 12", or "" if healthy.
+func (h dcgmHealth) summary() string {
+	var parts []string
+	if h.xid > 0 {
+		parts = append(parts, fmt.Sprintf("XID %.0f", h.xid))
+	}
+	if h.nvlinkErrors > 0 {
+		parts = append(parts, fmt.Sprintf("NVLink errors %.0f", h.nvlinkErrors))
+	}
+	if h.thermalViolationSec > 0 {
+		parts = append(parts, fmt.Sprintf("thermal throttling %.1fs", h.thermalViolationSec))
+	}
+	if h.retiredPages > 0 || h.retirementPending {
+		part := fmt.Sprintf("retired pages %.0f", h.retiredPages)
+		if h.retirementPending {
+			part += " (retirement pending)"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,326 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	bolt "go.etcd.io/bbolt"
+)
+
+// deadLetterBucket holds dead letters keyed by a big-endian sequence number, like the
+// outbound queue.
+var deadLetterBucket = []byte("deadletters")
+
+// errDeadLetterNotFound is returned for an unknown dead letter ID.
+var errDeadLetterNotFound = errors.New("dead letter not found")
+
+// deadLetterStore keeps messages that could not be delivered after all retries, in a
+// BoltDB file, until they are retried successfully or discarded through the API.
+type deadLetterStore struct {
+	db    *bolt.DB
+	token string
+}
+
+// deadLetter is a stored message. Alerts are the alerts of the payload the message was
+// rendered from when they are known; they are not for messages dropped by the outbound
+// queue, which only stores rendered messages.
+type deadLetter struct {
+	ID           uint64                `json:"id"`
+	Notification notifier.Notification `json:"notification"`
+	Alerts       []Alert               `json:"alerts,omitempty"`
+	Error        string                `json:"error"`
+	FailedAt     time.Time             `json:"failedAt"`
+	// Retries counts failed retries through the API.
+	Retries int `json:"retries,omitempty"`
+}
+
+func openDeadLetterStore(cfg DeadLetterConfig) (*deadLetterStore, error) {
+	db, err := bolt.Open(cfg.Path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(deadLetterBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &deadLetterStore{db: db, token: cfg.Token}, nil
+}
+
+func (s *deadLetterStore) close() error {
+	return s.db.Close()
+}
+
+// add stores a message that failed with err.
+func (s *deadLetterStore) add(m notifier.Notification, alerts []Alert, cause error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deadLetterBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(deadLetter{ID: seq, Notification: m, Alerts: alerts, Error: cause.Error(), FailedAt: time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+		return b.Put(sequenceKey(seq), value)
+	})
+	if err != nil {
+		return fmt.Errorf("storing dead letter: %w", err)
+	}
+	return nil
+}
+
+// list returns up to limit dead letters, newest first, optionally of one backend only.
+func (s *deadLetterStore) list(backend string, limit int) ([]deadLetter, error) {
+	var letters []deadLetter
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(deadLetterBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(letters) < limit; k, v = c.Prev() {
+			var d deadLetter
+			if err := json.Unmarshal(v, &d); err != nil {
+				return fmt.Errorf("decoding dead letter %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			if backend != "" && backendName(d.Notification) != backend {
+				continue
+			}
+			letters = append(letters, d)
+		}
+		return nil
+	})
+	return letters, err
+}
+
+func (s *deadLetterStore) get(id uint64) (deadLetter, error) {
+	var d deadLetter
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(deadLetterBucket).Get(sequenceKey(id))
+		if v == nil {
+			return errDeadLetterNotFound
+		}
+		return json.Unmarshal(v, &d)
+	})
+	return d, err
+}
+
+// update stores d again, e.g. after a failed retry.
+func (s *deadLetterStore) update(d deadLetter) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		value, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(deadLetterBucket).Put(sequenceKey(d.ID), value)
+	})
+}
+
+func (s *deadLetterStore) remove(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deadLetterBucket)
+		if b.Get(sequenceKey(id)) == nil {
+			return errDeadLetterNotFound
+		}
+		return b.Delete(sequenceKey(id))
+	})
+}
+
+// count returns the number of stored dead letters.
+func (s *deadLetterStore) count() int {
+	n := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(deadLetterBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// backendName is the backend of a notification, "gchat" for those stored before
+// multiple backends existed.
+func backendName(m notifier.Notification) string {
+	if m.Backend == "" {
+		return "gchat"
+	}
+	return m.Backend
+}
+
+// deadLetter keeps a message that failed for good, so it is not lost. The failure has
+// already been logged.
+func (a *adapter) deadLetter(m notifier.Notification, alerts []Alert, cause error) {
+	if a.deadLetters == nil {
+		return
+	}
+	if err := a.deadLetters.add(m, alerts, cause); err != nil {
+		slog.Error("Error keeping undeliverable message", "backend", backendName(m), "err", err)
+		return
+	}
+	deadLettered.WithLabelValues(backendName(m)).Inc()
+}
+
+// deadLetterRejected keeps a message the outbound queue dropped.
+func (a *adapter) deadLetterRejected(m notifier.Notification, cause error) {
+	a.deadLetter(m, nil, cause)
+}
+
+// listedDeadLetter is a dead letter as returned by the API. Only the host of the
+// destination is shown, also in the error, since webhook URLs usually embed their
+// credentials.
+type listedDeadLetter struct {
+	ID          uint64          `json:"id"`
+	Backend     string          `json:"backend"`
+	Destination string          `json:"destination,omitempty"`
+	Message     json.RawMessage `json:"message"`
+	AlertCount  int             `json:"alertCount"`
+	Alerts      []Alert         `json:"alerts,omitempty"`
+	Error       string          `json:"error"`
+	FailedAt    time.Time       `json:"failedAt"`
+	Retries     int             `json:"retries,omitempty"`
+}
+
+func (d deadLetter) listed() listedDeadLetter {
+	host := destinationHost(d.Notification.Destination)
+	return listedDeadLetter{
+		ID:          d.ID,
+		Backend:     backendName(d.Notification),
+		Destination: host,
+		Message:     d.Notification.Body,
+		AlertCount:  d.Notification.Alerts,
+		Alerts:      d.Alerts,
+		Error:       hideDestination(d.Error, d.Notification.Destination),
+		FailedAt:    d.FailedAt,
+		Retries:     d.Retries,
+	}
+}
+
+// handleDeadLetters serves the dead letter API:
+//
+//	GET    /api/deadletters              list (parameters: backend, limit, default 100)
+//	POST   /api/deadletters/{id}/retry   deliver again; removed once delivered
+//	DELETE /api/deadletters/{id}         discard
+func (a *adapter) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	s := a.deadLetters
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/deadletters"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		a.listDeadLetters(w, r)
+		return
+	}
+
+	idText, action, _ := strings.Cut(path, "/")
+	id, err := strconv.ParseUint(idText, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dead letter ID", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && action == "retry":
+		a.retryDeadLetter(w, r, id)
+	case r.Method == http.MethodDelete && action == "":
+		if err := s.remove(id); err != nil {
+			deadLetterError(w, err)
+			return
+		}
+		loggerFrom(r.Context()).Info("Dead letter discarded", "id", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *adapter) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	letters, err := a.deadLetters.list(r.URL.Query().Get("backend"), limit)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error listing dead letters", "err", err)
+		http.Error(w, "Error listing dead letters", http.StatusInternalServerError)
+		return
+	}
+	listed := make([]listedDeadLetter, 0, len(letters))
+	for _, d := range letters {
+		listed = append(listed, d.listed())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"total": a.deadLetters.count(), "deadLetters": listed})
+}
+
+// retryDeadLetter delivers a dead letter again, bypassing the outbound queue so the
+// caller learns the outcome.
+func (a *adapter) retryDeadLetter(w http.ResponseWriter, r *http.Request, id uint64) {
+	logger := loggerFrom(r.Context()).With("id", id)
+	d, err := a.deadLetters.get(id)
+	if err != nil {
+		deadLetterError(w, err)
+		return
+	}
+
+	if err := a.deliver(r.Context(), d.Notification); err != nil {
+		logger.Warn("Retrying dead letter failed", "backend", backendName(d.Notification), "err", err)
+		d.Retries++
+		d.Error = err.Error()
+		if err := a.deadLetters.update(d); err != nil {
+			logger.Error("Error updating dead letter", "err", err)
+		}
+		http.Error(w, "Delivery failed: "+hideDestination(err.Error(), d.Notification.Destination), http.StatusBadGateway)
+		return
+	}
+	if err := a.deadLetters.remove(id); err != nil {
+		logger.Error("Error removing delivered dead letter", "err", err)
+	}
+	logger.Info("Dead letter delivered", "backend", backendName(d.Notification))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "status": "delivered"})
+}
+
+// destinationHost returns the host of a webhook URL, or "" for other destinations.
+func destinationHost(destination string) string {
+	if u, err := url.Parse(destination); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// hideDestination replaces a webhook URL quoted in an error message with its host.
+func hideDestination(message, destination string) string {
+	host := destinationHost(destination)
+	if host == "" {
+		return message
+	}
+	return strings.ReplaceAll(message, destination, host)
+}
+
+func deadLetterError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errDeadLetterNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	slog.Error("Error reading dead letter", "err", err)
+	http.Error(w, "Error reading dead letter", http.StatusInternalServerError)
+}
@@ -0,0 +1,68 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alertmanager-adapter/notifier"
+)
+
+func TestDeadLetterConfigRequiresToken(t *testing.T) {
+	const base = "outputs: [gchat]\ngoogleChat:\n  webhookURL: https://chat.googleapis.com/v1/spaces/default/messages\n"
+	if _, err := parseConfig([]byte(base+"deadLetter:\n  path: /data/deadletters.db\n"), "test.yml"); err == nil || !strings.Contains(err.Error(), "deadLetter requires token") {
+		t.Errorf("parseConfig() without a token: %v, want an error", err)
+	}
+	if _, err := parseConfig([]byte(base+"deadLetter:\n  path: /data/deadletters.db\n  token: t0ken\n"), "test.yml"); err != nil {
+		t.Errorf("parseConfig() with a token: %v", err)
+	}
+}
+
+func TestDeadLetterAPIAuthorization(t *testing.T) {
+	store, err := openDeadLetterStore(DeadLetterConfig{Path: filepath.Join(t.TempDir(), "deadletters.db"), Token: "t0ken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.close()
+	m := notifier.Notification{Backend: "gchat", Destination: "https://chat.googleapis.com/v1/spaces/x/messages?key=secret", Body: json.RawMessage(`{"text":"GPU 0 is hot"}`), Alerts: 1}
+	if err := store.add(m, nil, errors.New("400 Bad Request")); err != nil {
+		t.Fatal(err)
+	}
+	a := &adapter{deadLetters: store}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		wantStatus    int
+	}{
+		{"list without token", http.MethodGet, "/api/deadletters", "", http.StatusUnauthorized},
+		{"list with a wrong token", http.MethodGet, "/api/deadletters", "Bearer wrong", http.StatusUnauthorized},
+		{"empty bearer", http.MethodGet, "/api/deadletters", "Bearer ", http.StatusUnauthorized},
+		{"retry without token", http.MethodPost, "/api/deadletters/1/retry", "", http.StatusUnauthorized},
+		{"discard without token", http.MethodDelete, "/api/deadletters/1", "", http.StatusUnauthorized},
+		{"list", http.MethodGet, "/api/deadletters", "Bearer t0ken", http.StatusOK},
+		{"discard", http.MethodDelete, "/api/deadletters/1", "Bearer t0ken", http.StatusNoContent},
+		{"discard again", http.MethodDelete, "/api/deadletters/1", "Bearer t0ken", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			a.handleDeadLetters(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusUnauthorized && strings.Contains(rec.Body.String(), "GPU 0") {
+				t.Errorf("unauthorized response shows a message: %s", rec.Body)
+			}
+		})
+	}
+}
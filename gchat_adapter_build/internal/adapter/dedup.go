@@ -0,0 +1,79 @@
+package adapter
+
+import (
+	"log/slog"
+	"time"
+)
+
+// deduplicator drops alerts that were already forwarded with the same status within the
+// TTL. Alertmanager re-sends every firing alert of a group on each repeat_interval (and
+// on every change to the group), which would otherwise post identical messages again.
+// With a shared store, an alert received by several replicas is forwarded by only one.
+type deduplicator struct {
+	ttl   time.Duration
+	store stateStore
+}
+
+// newDeduplicator returns nil when deduplication is disabled.
+func newDeduplicator(ttl time.Duration, store stateStore) *deduplicator {
+	if ttl <= 0 {
+		return nil
+	}
+	return &deduplicator{ttl: ttl, store: store}
+}
+
+// filter returns the alerts not seen within the TTL, and records them. An alert
+// changing status (firing to resolved or back) is never a duplicate: recording one
+// status forgets the other. If the store cannot be reached, alerts are let through: a
+// duplicate post beats a lost alert.
+func (d *deduplicator) filter(alerts []Alert) []Alert {
+	fresh := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		claimed, err := d.store.setNX("dedup/"+dedupKey(alert), "1", d.ttl)
+		if err != nil {
+			slog.Warn("Error checking for duplicate alert, forwarding it", alertAttr(alert), "err", err)
+			claimed = true
+		}
+		if !claimed {
+			continue
+		}
+		fresh = append(fresh, alert)
+		other := "firing"
+		if alert.Status == "firing" {
+			other = "resolved"
+		}
+		if err := d.store.del("dedup/" + alertFingerprint(alert) + "/" + other); err != nil {
+			slog.Warn("Error forgetting the previous status of alert", alertAttr(alert), "err", err)
+		}
+	}
+	return fresh
+}
+
+// release forgets alerts that filter recorded but that could not be delivered, so that
+// Alertmanager's retry of the webhook is not suppressed as a duplicate.
+func (d *deduplicator) release(alerts []Alert) {
+	for _, alert := range alerts {
+		if err := d.store.del("dedup/" + dedupKey(alert)); err != nil {
+			slog.Warn("Error releasing undelivered alert for retry", alertAttr(alert), "err", err)
+		}
+	}
+}
+
+func dedupKey(alert Alert) string {
+	return alertFingerprint(alert) + "/" + alert.Status
+}
+
+// duplicates returns the alerts that filter dropped, given its result.
+func duplicates(alerts, fresh []Alert) []Alert {
+	kept := make(map[string]bool, len(fresh))
+	for _, alert := range fresh {
+		kept[dedupKey(alert)] = true
+	}
+	var dropped []Alert
+	for _, alert := range alerts {
+		if !kept[dedupKey(alert)] {
+			dropped = append(dropped, alert)
+		}
+	}
+	return dropped
+}
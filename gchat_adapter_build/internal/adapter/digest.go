@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// digestSummaries caps how many distinct alert summaries a digest entry quotes.
+const digestSummaries = 3
+
+// alertDigest holds back alerts of low severities and posts them as one summarized
+// message per interval, with one entry per alert name and node, so noisy info and
+// warning alerts do not bury critical ones. Alerts of other severities pass through.
+type alertDigest struct {
+	severities map[string]bool
+	interval   time.Duration
+
+	mu      sync.Mutex
+	since   time.Time
+	entries map[digestKey]*digestEntry
+}
+
+// digestKey groups the digested alerts.
+type digestKey struct {
+	alertname, node string
+}
+
+// digestEntry collects the notifications for one alert name on one node.
+type digestEntry struct {
+	// alerts holds the latest state of each alert by fingerprint.
+	alerts        map[string]Alert
+	notifications int
+	summaries     []string
+}
+
+// newAlertDigest returns nil when digests are disabled.
+func newAlertDigest(cfg DigestConfig) *alertDigest {
+	if cfg.Interval <= 0 {
+		return nil
+	}
+	severities := make(map[string]bool, len(cfg.Severities))
+	for _, s := range cfg.Severities {
+		severities[strings.ToLower(s)] = true
+	}
+	return &alertDigest{severities: severities, interval: cfg.Interval, entries: make(map[digestKey]*digestEntry)}
+}
+
+// hold removes the alerts with a digested severity from the list and adds them to the
+// next digest.
+func (d *alertDigest) hold(alerts []Alert) (forward, held []Alert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, alert := range alerts {
+		if !d.severities[strings.ToLower(alert.Labels["severity"])] {
+			forward = append(forward, alert)
+			continue
+		}
+		held = append(held, alert)
+
+		key := digestKey{alert.Labels["alertname"], alertNode(alert.Labels)}
+		entry, ok := d.entries[key]
+		if !ok {
+			if len(d.entries) == 0 {
+				d.since = time.Now()
+			}
+			entry = &digestEntry{alerts: make(map[string]Alert)}
+			d.entries[key] = entry
+		}
+		entry.alerts[alertFingerprint(alert)] = alert
+		entry.notifications++
+		if summary := alert.Annotations["summary"]; summary != "" && len(entry.summaries) < digestSummaries && !slices.Contains(entry.summaries, summary) {
+			entry.summaries = append(entry.summaries, summary)
+		}
+	}
+	return forward, held
+}
+
+// run flushes the digest every interval, for as long as the process runs.
+func (d *alertDigest) run(flush func(AlertmanagerPayload)) {
+	for range time.Tick(d.interval) {
+		d.flush(flush)
+	}
+}
+
+// flush hands the digest collected so far to send, if it has any entries.
+func (d *alertDigest) flush(send func(AlertmanagerPayload)) {
+	d.mu.Lock()
+	entries, since := d.entries, d.since
+	d.entries = make(map[digestKey]*digestEntry)
+	d.mu.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+	payload := digestPayload(entries, since)
+	slog.Info("Posting alert digest", "entries", len(payload.Alerts))
+	send(payload)
+}
+
+// digestPayload turns the entries into one payload with an alert per entry, ordered by
+// alert name and node. Each carries the labels its alerts have in common (so routes
+// still match) and a summary of what happened since the digest started.
+func digestPayload(entries map[digestKey]*digestEntry, since time.Time) AlertmanagerPayload {
+	keys := make([]digestKey, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].alertname != keys[j].alertname {
+			return keys[i].alertname < keys[j].alertname
+		}
+		return keys[i].node < keys[j].node
+	})
+
+	alerts := make([]Alert, 0, len(keys))
+	for _, key := range keys {
+		entry := entries[key]
+		var labels map[string]string
+		firing, startsAt := 0, ""
+		for _, alert := range entry.alerts {
+			labels = commonLabels(labels, alert.Labels)
+			if alert.Status != "resolved" {
+				firing++
+			}
+			if startsAt == "" || alert.StartsAt < startsAt {
+				startsAt = alert.StartsAt
+			}
+		}
+		labels["alertname"] = key.alertname
+		if key.node != "" {
+			labels["instance"] = key.node
+		}
+
+		status := "resolved"
+		if firing > 0 {
+			status = "firing"
+		}
+		summary := fmt.Sprintf("Digest since %s: %d notification(s) for %d alert(s), %d still firing",
+			since.UTC().Format("15:04 MST"), entry.notifications, len(entry.alerts), firing)
+		if len(entry.summaries) > 0 {
+			summary += ": " + strings.Join(entry.summaries, "; ")
+		}
+		alerts = append(alerts, Alert{
+			Labels:      labels,
+			Annotations: map[string]string{"summary": summary},
+			Status:      status,
+			StartsAt:    startsAt,
+			EndsAt:      "0001-01-01T00:00:00Z",
+		})
+	}
+	return AlertmanagerPayload{Alerts: alerts, Status: combinedStatus(alerts), GroupKey: "digest"}
+}
+
+// commonLabels returns the labels of next that have the same value in common; a nil
+// common starts from next.
+func commonLabels(common, next map[string]string) map[string]string {
+	if common == nil {
+		common = make(map[string]string, len(next))
+		for k, v := range next {
+			common[k] = v
+		}
+		return common
+	}
+	for k, v := range common {
+		if next[k] != v {
+			delete(common, k)
+		}
+	}
+	return common
+}
@@ -0,0 +1,148 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"alertmanager-adapter/notifier"
+)
+
+// discordMaxEmbeds is the number of embeds Discord accepts in a single message.
+const discordMaxEmbeds = 10
+
+// discordNotifier renders alerts as Discord webhook messages with one embed per alert.
+type discordNotifier struct {
+	router *webhookRouter
+}
+
+// discordMessage is the body of a Discord webhook execution request.
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+func init() {
+	outputRegistry.Register("discord", func(cfg *Config, deps outputDeps) (output, error) {
+		return newDiscordNotifier(cfg.Discord, deps.locales)
+	})
+}
+
+func newDiscordNotifier(wc WebhookConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(wc, locales)
+	if err != nil {
+		return nil, fmt.Errorf("discord.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no Discord webhook URL is configured")
+	}
+	return &discordNotifier{router: router}, nil
+}
+
+func (n *discordNotifier) Name() string { return "discord" }
+
+// render posts one message per destination and per discordMaxEmbeds alerts.
+func (n *discordNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No Discord webhook configured for alert, dropping it", alertAttr(alert))
+	}
+
+	var messages []notifier.Notification
+	for _, group := range routed {
+		alerts := group.payload.Alerts
+		for start := 0; start < len(alerts); start += discordMaxEmbeds {
+			end := min(start+discordMaxEmbeds, len(alerts))
+			chunk := group.payload
+			chunk.Alerts = alerts[start:end]
+			m, err := newNotification(n.Name(), group.webhookURL, len(chunk.Alerts), buildDiscordMessage(chunk, group.localizer))
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (n *discordNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *discordNotifier) renderText(webhookURL, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), webhookURL, 0, discordMessage{Content: text})
+}
+
+func (n *discordNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, m.Destination, m.Body); err != nil {
+		return fmt.Errorf("posting to Discord: %w", err)
+	}
+	return nil
+}
+
+func buildDiscordMessage(payload AlertmanagerPayload, l *localizer) discordMessage {
+	var msg discordMessage
+	for _, alert := range payload.Alerts {
+		severity := alert.Labels["severity"]
+		status, color, icon := alertAppearance(alert, payload.Status)
+
+		var fields []discordField
+		fields = appendDiscordField(fields, l.translate("Status"), strings.ToUpper(l.translate(status)), true)
+		fields = appendDiscordField(fields, l.translate("Severity"), l.translate(severity), true)
+		fields = appendDiscordField(fields, l.translate("Instance"), alert.Labels["instance"], true)
+		fields = appendDiscordField(fields, l.translate("GPU"), gpuDescription(alert.Labels), false)
+		fields = appendDiscordField(fields, l.translate("Workload"), workloadDescription(alert.Labels), false)
+		fields = appendDiscordField(fields, l.translate("SLURM job"), slurmJobDescription(alert.Labels), false)
+		fields = appendDiscordField(fields, l.translate("Node"), alert.Annotations[nodeInfoAnnotation], false)
+		fields = appendDiscordField(fields, l.translate("GPU health"), alert.Annotations[gpuHealthAnnotation], false)
+		fields = appendDiscordField(fields, l.translate("Top processes"), alert.Annotations[topProcessesAnnotation], false)
+		fields = appendDiscordField(fields, l.translate("Throttling"), alert.Annotations[throttleReasonsAnnotation], false)
+		fields = appendDiscordField(fields, l.translate("Chassis"), alert.Annotations[chassisAnnotation], false)
+		fields = appendDiscordField(fields, l.translate("Started"), l.formatTime(alert.StartsAt), true)
+		fields = appendDiscordField(fields, l.translate("Ended"), l.formatTime(alert.EndsAt), true)
+
+		embed := discordEmbed{
+			Title:       icon + " " + alert.Labels["alertname"],
+			Description: alert.Annotations["summary"],
+			Color:       discordColor(color),
+			Fields:      fields,
+		}
+		// Discord shows the timestamp in the embed footer; skip Alertmanager's zero time.
+		if l.formatTime(alert.StartsAt) != "" {
+			embed.Timestamp = alert.StartsAt
+		}
+		msg.Embeds = append(msg.Embeds, embed)
+	}
+	return msg
+}
+
+// appendDiscordField adds an embed field, skipping empty values.
+func appendDiscordField(fields []discordField, name, value string, inline bool) []discordField {
+	if value == "" {
+		return fields
+	}
+	return append(fields, discordField{Name: name, Value: value, Inline: inline})
+}
+
+// discordColor converts a "#RRGGBB" color to the integer Discord expects.
+func discordColor(hex string) int {
+	v, err := strconv.ParseInt(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,389 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// driftComponent is a version compared across the fleet.
+type driftComponent struct {
+	name      string
+	alertname string
+	// title names the component in alert summaries.
+	title string
+}
+
+var driftComponents = []driftComponent{
+	{name: "driver", alertname: "GpuDriverVersionDrift", title: "NVIDIA driver"},
+	{name: "cuda", alertname: "CudaVersionDrift", title: "CUDA"},
+}
+
+// fleetDrift reads the inventory of every gpu-collector and alerts on nodes whose NVIDIA
+// driver or CUDA version differs from the fleet baseline: the configured expected
+// version, or else the version most nodes run. Mixed driver versions are a common reason
+// for jobs that work on one node and crash on another. The alerts fire and resolve
+// through the same pipeline as webhooks; GET /api/drift shows the fleet view.
+type fleetDrift struct {
+	inventories []string
+	interval    time.Duration
+	// expected holds the pinned version by component name.
+	expected map[string]string
+	severity string
+	client   *http.Client
+	process  func(context.Context, AlertmanagerPayload) (string, error)
+
+	mu sync.Mutex
+	// nodes holds the last inventory read of each node, by inventory URL.
+	nodes    map[string]driftNode
+	baseline map[string]string
+	// firing holds the active alerts by component name and node.
+	firing    map[string]Alert
+	checkedAt time.Time
+}
+
+// driftNode is a node in the fleet view.
+type driftNode struct {
+	Node      string `json:"node"`
+	URL       string `json:"url"`
+	Driver    string `json:"driverVersion,omitempty"`
+	CUDA      string `json:"cudaVersion,omitempty"`
+	Drifted   bool   `json:"drifted"`
+	Error     string `json:"error,omitempty"`
+	checkedOK bool
+}
+
+// driftState is what /api/drift returns.
+type driftState struct {
+	CheckedAt time.Time         `json:"checkedAt"`
+	Baseline  map[string]string `json:"baseline"`
+	Expected  map[string]string `json:"expected,omitempty"`
+	Nodes     []driftNode       `json:"nodes"`
+}
+
+// newFleetDrift returns nil when the check is disabled.
+func newFleetDrift(cfg VersionDriftConfig, process func(context.Context, AlertmanagerPayload) (string, error)) *fleetDrift {
+	if len(cfg.Inventories) == 0 {
+		return nil
+	}
+	d := &fleetDrift{
+		inventories: cfg.Inventories,
+		interval:    cfg.Interval,
+		expected:    make(map[string]string),
+		severity:    cfg.Severity,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		process:     process,
+		nodes:       make(map[string]driftNode),
+		baseline:    make(map[string]string),
+		firing:      make(map[string]Alert),
+	}
+	if cfg.ExpectedDriverVersion != "" {
+		d.expected["driver"] = cfg.ExpectedDriverVersion
+	}
+	if cfg.ExpectedCUDAVersion != "" {
+		d.expected["cuda"] = cfg.ExpectedCUDAVersion
+	}
+	return d
+}
+
+// run checks the fleet right away and then every interval, for as long as the process
+// runs.
+func (d *fleetDrift) run() {
+	for {
+		if err := d.check(context.Background()); err != nil {
+			slog.Error("Error posting version drift alerts", "err", err)
+		}
+		time.Sleep(d.interval)
+	}
+}
+
+// check reads every inventory and posts the alerts that started or stopped firing. A
+// node whose inventory cannot be read keeps its alerts. If the post fails, the alert
+// state is kept unchanged so the same changes are posted again on the next check.
+func (d *fleetDrift) check(ctx context.Context) error {
+	nodes := make(map[string]driftNode, len(d.inventories))
+	for _, u := range d.inventories {
+		node, err := d.fetch(u)
+		if err != nil {
+			slog.Warn("Error reading gpu-collector inventory for the version drift check", "url", u, "err", err)
+			node = d.previous(u)
+			node.Error = err.Error()
+			node.checkedOK = false
+		}
+		nodes[u] = node
+	}
+
+	baseline := make(map[string]string, len(driftComponents))
+	for _, c := range driftComponents {
+		if v, ok := d.expected[c.name]; ok {
+			baseline[c.name] = v
+			continue
+		}
+		var versions []string
+		for _, node := range nodes {
+			if v := node.version(c.name); node.checkedOK && v != "" {
+				versions = append(versions, v)
+			}
+		}
+		baseline[c.name] = mostCommonVersion(versions)
+	}
+
+	d.mu.Lock()
+	firing := d.firing
+	d.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	next := make(map[string]Alert, len(firing))
+	var changed []Alert
+	for u, node := range nodes {
+		for _, c := range driftComponents {
+			key := c.name + "/" + node.Node
+			alert, wasFiring := firing[key]
+			if !node.checkedOK {
+				if wasFiring {
+					next[key] = alert
+					node.Drifted = true
+				}
+				continue
+			}
+			version, want := node.version(c.name), baseline[c.name]
+			drifted := version != "" && want != "" && version != want
+			node.Drifted = node.Drifted || drifted
+			switch {
+			case drifted && wasFiring:
+				next[key] = alert
+			case drifted:
+				alert = d.newAlert(c, node.Node, version, want, nodes, now)
+				next[key] = alert
+				changed = append(changed, alert)
+			case wasFiring:
+				alert.Status = "resolved"
+				alert.EndsAt = now
+				changed = append(changed, alert)
+			}
+		}
+		nodes[u] = node
+	}
+
+	if len(changed) > 0 {
+		if err := d.post(ctx, changed); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	d.nodes, d.baseline, d.firing, d.checkedAt = nodes, baseline, next, time.Now().UTC()
+	d.mu.Unlock()
+	d.updateMetrics()
+	return nil
+}
+
+// previous returns the last successful read of the node at u, if any.
+func (d *fleetDrift) previous(u string) driftNode {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node, ok := d.nodes[u]
+	if !ok {
+		node = driftNode{Node: inventoryHost(u), URL: u}
+	}
+	return node
+}
+
+// fetch reads the inventory of one node. The node is named by the collector's
+// hostname, or by the host of u when the collector does not report one.
+func (d *fleetDrift) fetch(u string) (driftNode, error) {
+	resp, err := d.client.Get(u)
+	if err != nil {
+		return driftNode{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return driftNode{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var inventory struct {
+		Hostname      string `json:"hostname"`
+		DriverVersion string `json:"driverVersion"`
+		CUDAVersion   string `json:"cudaVersion"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inventory); err != nil {
+		return driftNode{}, fmt.Errorf("decoding inventory: %w", err)
+	}
+	node := driftNode{Node: inventory.Hostname, URL: u, Driver: inventory.DriverVersion, CUDA: inventory.CUDAVersion, checkedOK: true}
+	if node.Node == "" {
+		node.Node = inventoryHost(u)
+	}
+	return node, nil
+}
+
+func (n driftNode) version(component string) string {
+	if component == "cuda" {
+		return n.CUDA
+	}
+	return n.Driver
+}
+
+func (d *fleetDrift) newAlert(c driftComponent, node, version, want string, nodes map[string]driftNode, startsAt string) Alert {
+	labels := map[string]string{"alertname": c.alertname, "instance": node, "job": "version_drift", "severity": d.severity}
+	baseline := fmt.Sprintf("the version most nodes run (%s)", want)
+	if _, ok := d.expected[c.name]; ok {
+		baseline = "the expected version " + want
+	}
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		if v := n.version(c.name); n.checkedOK && v != "" {
+			counts[v]++
+		}
+	}
+	return Alert{
+		Status: "firing",
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("%s %s on %s differs from %s", c.title, version, node, baseline),
+			"description": "Fleet " + c.title + " versions: " + versionCounts(counts),
+		},
+		StartsAt:    startsAt,
+		EndsAt:      "0001-01-01T00:00:00Z",
+		Fingerprint: labelsFingerprint(labels),
+	}
+}
+
+func (d *fleetDrift) post(ctx context.Context, alerts []Alert) error {
+	payload := AlertmanagerPayload{
+		Status:      "resolved",
+		Receiver:    "version-drift",
+		GroupKey:    "version-drift",
+		GroupLabels: map[string]string{"job": "version_drift"},
+		Alerts:      alerts,
+	}
+	for _, alert := range alerts {
+		if alert.Status == "firing" {
+			payload.Status = "firing"
+		}
+	}
+	_, err := d.process(ctx, payload)
+	return err
+}
+
+func (d *fleetDrift) updateMetrics() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fleetVersionNodes.Reset()
+	for _, c := range driftComponents {
+		drifted := 0
+		for _, node := range d.nodes {
+			if v := node.version(c.name); node.checkedOK && v != "" {
+				fleetVersionNodes.WithLabelValues(c.name, v).Inc()
+			}
+			if _, ok := d.firing[c.name+"/"+node.Node]; ok {
+				drifted++
+			}
+		}
+		fleetDriftNodes.WithLabelValues(c.name).Set(float64(drifted))
+	}
+}
+
+// handleState serves GET /api/drift.
+func (d *fleetDrift) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.mu.Lock()
+	state := driftState{CheckedAt: d.checkedAt, Baseline: d.baseline, Expected: d.expected, Nodes: make([]driftNode, 0, len(d.nodes))}
+	for _, node := range d.nodes {
+		state.Nodes = append(state.Nodes, node)
+	}
+	d.mu.Unlock()
+	sort.Slice(state.Nodes, func(i, j int) bool { return state.Nodes[i].Node < state.Nodes[j].Node })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// inventoryHost returns the host of an inventory URL without its port.
+func inventoryHost(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	if host, _, err := net.SplitHostPort(parsed.Host); err == nil {
+		return host
+	}
+	return parsed.Host
+}
+
+// mostCommonVersion returns the version most often in versions; ties go to the newer
+// version, so a fleet halfway through an upgrade alerts on the nodes not yet upgraded.
+func mostCommonVersion(versions []string) string {
+	counts := make(map[string]int)
+	for _, v := range versions {
+		counts[v]++
+	}
+	best := ""
+	for v, n := range counts {
+		if best == "" || n > counts[best] || n == counts[best] && compareVersions(v, best) > 0 {
+			best = v
+		}
+	}
+	return best
+}
+
+// compareVersions compares dotted versions such as "550.54.15" part by part, numerically
+// where both parts are numbers.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, errX := strconv.Atoi(x)
+		yn, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (errX != nil || errY != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// versionCounts lists versions with their node counts, the most common first, e.g.
+// "550.54.15 (14 nodes), 535.104.05 (2 nodes)".
+func versionCounts(counts map[string]int) string {
+	versions := make([]string, 0, len(counts))
+	for v := range counts {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if counts[versions[i]] != counts[versions[j]] {
+			return counts[versions[i]] > counts[versions[j]]
+		}
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		unit := "nodes"
+		if counts[v] == 1 {
+			unit = "node"
+		}
+		parts[i] = fmt.Sprintf("%s (%d %s)", v, counts[v], unit)
+	}
+	return strings.Join(parts, ", ")
+}
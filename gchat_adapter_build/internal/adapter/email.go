@@ -0,0 +1,360 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+)
+
+// defaultEmailTemplate renders one table per alert. Custom templates (email.templatePath)
+// receive the same emailTemplateData.
+const defaultEmailTemplate = `<!DOCTYPE html>
+<html><body style="font-family: Arial, sans-serif; font-size: 14px; color: #202124;">
+{{range .Alerts}}
+<table style="border-collapse: collapse; width: 100%; max-width: 720px; margin-bottom: 16px; border: 1px solid #dadce0;">
+  <tr><td colspan="2" style="background: {{.Color}}; color: #ffffff; padding: 8px 12px; font-weight: bold;">{{.Icon}} {{index .Labels "alertname"}} · {{.DisplayStatus}}</td></tr>
+  {{range .Fields}}<tr><td style="padding: 4px 12px; color: #5f6368; white-space: nowrap; vertical-align: top;">{{.Name}}</td><td style="padding: 4px 12px; white-space: pre-wrap;">{{.Value}}</td></tr>
+  {{end}}
+</table>
+{{end}}
+</body></html>
+`
+
+// emailNotifier sends alerts by SMTP. Its destinations are comma separated recipient
+// lists, routed like the webhooks of the other backends.
+type emailNotifier struct {
+	router *webhookRouter
+	smtp   SMTPConfig
+	from   string
+	// templates hold the email template for each of the router's localizers.
+	templates map[*localizer]*template.Template
+}
+
+// emailMessage is the rendered body of an outbound email; the recipients are the
+// message's destination.
+type emailMessage struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html,omitempty"`
+	Text    string `json:"text"`
+}
+
+// emailTemplateData is the data passed to the HTML template.
+type emailTemplateData struct {
+	AlertmanagerPayload
+	Alerts []emailAlert
+}
+
+// emailAlert is an alert together with the details shown for it.
+type emailAlert struct {
+	Alert
+	DisplayStatus string
+	Color         string
+	Icon          string
+	Fields        []emailField
+}
+
+type emailField struct {
+	Name  string
+	Value string
+}
+
+func init() {
+	outputRegistry.Register("email", func(cfg *Config, deps outputDeps) (output, error) {
+		return newEmailNotifier(cfg.Email, deps.locales)
+	})
+}
+
+func newEmailNotifier(cfg EmailConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(cfg.webhookConfig(), locales)
+	if err != nil {
+		return nil, fmt.Errorf("email.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no email recipients are configured")
+	}
+	if cfg.SMTP.Host == "" || cfg.From == "" {
+		return nil, fmt.Errorf("email requires smtp.host and from")
+	}
+
+	tmpl, err := loadEmailTemplate(cfg.TemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	n := &emailNotifier{router: router, smtp: cfg.SMTP, from: cfg.From, templates: make(map[*localizer]*template.Template)}
+	for _, l := range router.allLocalizers() {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return nil, err
+		}
+		n.templates[l] = clone.Funcs(templateFuncs(l))
+	}
+	return n, nil
+}
+
+// loadEmailTemplate parses the HTML template at path, or the built-in default when path is
+// empty. Like message templates, it is bound to each route's localizer afterwards.
+func loadEmailTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New("email").Funcs(templateFuncs(englishUTC)).Parse(defaultEmailTemplate)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading email template: %w", err)
+	}
+	tmpl, err := template.New(path).Option("missingkey=zero").Funcs(templateFuncs(englishUTC)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing email template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (n *emailNotifier) Name() string { return "email" }
+
+// render sends one email per recipient list.
+func (n *emailNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No email recipients configured for alert, dropping it", alertAttr(alert))
+	}
+
+	var messages []notifier.Notification
+	for _, group := range routed {
+		msg, err := n.buildEmail(group.payload, group.localizer)
+		if err != nil {
+			return nil, err
+		}
+		m, err := newNotification(n.Name(), group.webhookURL, len(group.payload.Alerts), msg)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (n *emailNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *emailNotifier) renderText(recipients, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), recipients, 0, emailMessage{Subject: "Alertmanager adapter notice", Text: text})
+}
+
+func (n *emailNotifier) buildEmail(payload AlertmanagerPayload, l *localizer) (emailMessage, error) {
+	data := emailTemplateData{AlertmanagerPayload: payload}
+	var text strings.Builder
+	for _, alert := range payload.Alerts {
+		status, color, icon := alertAppearance(alert, payload.Status)
+		ea := emailAlert{Alert: alert, DisplayStatus: strings.ToUpper(l.translate(status)), Color: color, Icon: icon}
+		for _, f := range []emailField{
+			{"Severity", l.translate(alert.Labels["severity"])},
+			{"Instance", alert.Labels["instance"]},
+			{"GPU", gpuDescription(alert.Labels)},
+			{"Workload", workloadDescription(alert.Labels)},
+			{"SLURM job", slurmJobDescription(alert.Labels)},
+			{"Summary", alert.Annotations["summary"]},
+			{"Description", alert.Annotations["description"]},
+			{"Node", alert.Annotations[nodeInfoAnnotation]},
+			{"GPU health", alert.Annotations[gpuHealthAnnotation]},
+			{"Top processes", alert.Annotations[topProcessesAnnotation]},
+			{"Throttling", alert.Annotations[throttleReasonsAnnotation]},
+			{"Chassis", alert.Annotations[chassisAnnotation]},
+			{"Started", l.formatTime(alert.StartsAt)},
+			{"Ended", l.formatTime(alert.EndsAt)},
+		} {
+			if f.Value != "" {
+				f.Name = l.translate(f.Name)
+				ea.Fields = append(ea.Fields, f)
+			}
+		}
+		data.Alerts = append(data.Alerts, ea)
+
+		fmt.Fprintf(&text, "%s %s · %s\n", icon, alert.Labels["alertname"], ea.DisplayStatus)
+		for _, f := range ea.Fields {
+			fmt.Fprintf(&text, "  %s: %s\n", f.Name, f.Value)
+		}
+		text.WriteString("\n")
+	}
+
+	var html bytes.Buffer
+	if err := n.templates[l].Execute(&html, data); err != nil {
+		return emailMessage{}, fmt.Errorf("executing email template: %w", err)
+	}
+	return emailMessage{Subject: emailSubject(payload), HTML: html.String(), Text: text.String()}, nil
+}
+
+// emailSubject follows Alertmanager's own subject line, e.g. "[FIRING:2] GpuHot (gpu-node-07:9400)".
+func emailSubject(payload AlertmanagerPayload) string {
+	counts := make(map[string]int)
+	names := make(map[string]bool)
+	instances := make(map[string]bool)
+	for _, alert := range payload.Alerts {
+		status, _, _ := alertAppearance(alert, payload.Status)
+		counts[strings.ToUpper(status)]++
+		names[alert.Labels["alertname"]] = true
+		instances[alert.Labels["instance"]] = true
+	}
+
+	var parts []string
+	for _, status := range []string{"FIRING", "RESOLVED"} {
+		if counts[status] > 0 {
+			parts = append(parts, status+":"+strconv.Itoa(counts[status]))
+		}
+	}
+	subject := "[" + strings.Join(parts, ", ") + "]"
+	if len(names) == 1 {
+		subject += " " + payload.Alerts[0].Labels["alertname"]
+	} else {
+		subject += fmt.Sprintf(" %d alerts", len(payload.Alerts))
+	}
+	if len(instances) == 1 && payload.Alerts[0].Labels["instance"] != "" {
+		subject += " (" + payload.Alerts[0].Labels["instance"] + ")"
+	}
+	return subject
+}
+
+func (n *emailNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	var msg emailMessage
+	if err := json.Unmarshal(m.Body, &msg); err != nil {
+		return fmt.Errorf("decoding email: %w: %w", err, errNotRetryable)
+	}
+	recipients := splitRecipients(m.Destination)
+	body, err := n.encode(recipients, msg)
+	if err != nil {
+		return fmt.Errorf("encoding email: %w: %w", err, errNotRetryable)
+	}
+	if err := n.deliver(recipients, body); err != nil {
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			// Permanent failures such as unknown recipients will not succeed on retry.
+			return fmt.Errorf("sending email: %w: %w", err, errNotRetryable)
+		}
+		return fmt.Errorf("sending email: %w", err)
+	}
+	return nil
+}
+
+// encode builds the MIME message with a plain text and, if rendered, an HTML part.
+func (n *emailNotifier) encode(recipients []string, msg emailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver runs one SMTP transaction, using implicit TLS, STARTTLS or plain text as configured.
+func (n *emailNotifier) deliver(recipients []string, body []byte) error {
+	addr := net.JoinHostPort(n.smtp.Host, strconv.Itoa(n.smtp.Port))
+	tlsConfig := &tls.Config{ServerName: n.smtp.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if n.smtp.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	c, err := smtp.NewClient(conn, n.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if n.smtp.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if n.smtp.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.smtp.Username, n.smtp.Password, n.smtp.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// splitRecipients parses a destination back into its recipients.
+func splitRecipients(destination string) []string {
+	var recipients []string
+	for _, r := range strings.Split(destination, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
@@ -0,0 +1,223 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// escalationPolicy is a chain of steps run for firing alerts that match all matchers
+// and are not acknowledged.
+type escalationPolicy struct {
+	name     string
+	matchers []labelMatcher
+	steps    []escalationStep
+}
+
+type escalationStep struct {
+	after   time.Duration
+	mention string
+	outputs []string
+}
+
+// escalator tracks the firing alerts covered by an escalation policy from the moment the
+// adapter first forwards them until they resolve or are acknowledged through a card
+// button. The state is kept in memory, so a restart starts every chain over.
+type escalator struct {
+	policies []escalationPolicy
+
+	mu      sync.Mutex
+	pending map[string]*escalation
+}
+
+// escalation is the progress of one alert through its policy.
+type escalation struct {
+	alert  Alert
+	policy *escalationPolicy
+	since  time.Time
+	// next is the index of the next step to run.
+	next int
+}
+
+// parseEscalationPolicies validates and compiles the configured policies.
+func parseEscalationPolicies(configs []EscalationPolicyConfig) ([]escalationPolicy, error) {
+	policies := make([]escalationPolicy, 0, len(configs))
+	for i, pc := range configs {
+		name := pc.Name
+		if name == "" {
+			name = fmt.Sprintf("escalation[%d]", i)
+		}
+		matchers, err := parseMatchers(pc.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("escalation policy %s: %w", name, err)
+		}
+		if len(pc.Steps) == 0 {
+			return nil, fmt.Errorf("escalation policy %s: steps is required", name)
+		}
+		policy := escalationPolicy{name: name, matchers: matchers}
+		for j, sc := range pc.Steps {
+			if sc.After <= 0 || (j > 0 && sc.After <= pc.Steps[j-1].After) {
+				return nil, fmt.Errorf("escalation policy %s: steps[%d].after must be positive and later than the previous step", name, j)
+			}
+			if sc.Mention == "" && len(sc.Outputs) == 0 {
+				return nil, fmt.Errorf("escalation policy %s: steps[%d] needs a mention or outputs", name, j)
+			}
+			policy.steps = append(policy.steps, escalationStep{after: sc.After, mention: sc.Mention, outputs: sc.Outputs})
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// newEscalator returns nil when no escalation policies are configured.
+func newEscalator(configs []EscalationPolicyConfig) (*escalator, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	policies, err := parseEscalationPolicies(configs)
+	if err != nil {
+		return nil, err
+	}
+	return &escalator{policies: policies, pending: make(map[string]*escalation)}, nil
+}
+
+// observe starts the chain of newly firing alerts that match a policy (the first
+// matching one) and ends it for resolved alerts. An alert that is already pending keeps
+// its progress but its latest state is used for the notices.
+func (e *escalator) observe(alerts []Alert) {
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, alert := range alerts {
+		fp := alertFingerprint(alert)
+		if alert.Status == "resolved" {
+			delete(e.pending, fp)
+			continue
+		}
+		if pending, ok := e.pending[fp]; ok {
+			pending.alert = alert
+			continue
+		}
+		for i := range e.policies {
+			if matchAll(e.policies[i].matchers, alert.Labels) {
+				e.pending[fp] = &escalation{alert: alert, policy: &e.policies[i], since: now}
+				break
+			}
+		}
+	}
+}
+
+// acknowledge ends the chain of the alert with the given fingerprint. It reports
+// whether one was pending.
+func (e *escalator) acknowledge(fingerprint string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.pending[fingerprint]
+	delete(e.pending, fingerprint)
+	return ok
+}
+
+// dueEscalation is a step that has to run now.
+type dueEscalation struct {
+	alert   Alert
+	policy  string
+	step    escalationStep
+	elapsed time.Duration
+}
+
+// due returns the steps whose time has come and advances their chains. Chains that
+// have run their last step are dropped.
+func (e *escalator) due(now time.Time) []dueEscalation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due []dueEscalation
+	for fp, pending := range e.pending {
+		elapsed := now.Sub(pending.since)
+		for pending.next < len(pending.policy.steps) && elapsed >= pending.policy.steps[pending.next].after {
+			due = append(due, dueEscalation{alert: pending.alert, policy: pending.policy.name, step: pending.policy.steps[pending.next], elapsed: elapsed})
+			pending.next++
+		}
+		if pending.next == len(pending.policy.steps) {
+			delete(e.pending, fp)
+		}
+	}
+	return due
+}
+
+// run checks for due steps every interval.
+func (e *escalator) run(interval time.Duration, escalate func(dueEscalation)) {
+	for {
+		time.Sleep(interval)
+		for _, d := range e.due(time.Now()) {
+			escalate(d)
+		}
+	}
+}
+
+// escalate runs one step: it posts a notice mentioning the step's recipient in the
+// alert's Google Chat thread and sends the alert to the step's additional outputs.
+func (a *adapter) escalate(d dueEscalation) {
+	alertname := d.alert.Labels["alertname"]
+	logger := slog.With("policy", d.policy, "alertname", alertname, "instance", d.alert.Labels["instance"])
+	// The alert may have been acknowledged on another replica.
+	fp := alertFingerprint(d.alert)
+	if a.mutes != nil {
+		if mute, ok := a.mutes.muted(fp); ok {
+			logger.Info("Stopped escalating acknowledged alert", "action", mute.action, "until", mute.until.UTC().Format(time.RFC3339))
+			a.escalator.acknowledge(fp)
+			return
+		}
+	}
+	logger.Info("Escalating unacknowledged alert", "elapsed", d.elapsed.Round(time.Second).String(), "outputs", strings.Join(d.step.outputs, ","))
+	alertsEscalated.WithLabelValues(d.policy).Inc()
+
+	ctx := context.Background()
+	if d.step.mention != "" {
+		if err := a.reply(ctx, d.alert, escalationNotice(d)); err != nil {
+			logger.Error("Error posting escalation notice", "err", err)
+		}
+	}
+
+	payload := AlertmanagerPayload{Status: "firing", GroupKey: "escalation/" + d.policy, Alerts: []Alert{d.alert}}
+	for _, name := range d.step.outputs {
+		n, _ := a.backend(name)
+		if n == nil {
+			logger.Error("Escalation output is not enabled", "backend", name)
+			continue
+		}
+		messages, err := n.render(payload)
+		if err != nil {
+			logger.Error("Error rendering escalation", "backend", name, "err", err)
+			continue
+		}
+		for _, m := range messages {
+			if err := a.deliver(ctx, m); err != nil {
+				logger.Error("Error sending escalation", "backend", name, "err", err)
+				a.deadLetter(m, payload.Alerts, err)
+			}
+		}
+	}
+}
+
+// escalationNotice is the text re-posted for an unacknowledged alert.
+func escalationNotice(d dueEscalation) string {
+	notice := fmt.Sprintf("🔺 %s %s on %s (%s) has been firing unacknowledged for %s.",
+		d.step.mention, d.alert.Labels["alertname"], d.alert.Labels["instance"], d.alert.Labels["severity"], roundElapsed(d.elapsed))
+	if summary := d.alert.Annotations["summary"]; summary != "" {
+		notice += "\n" + summary
+	}
+	return notice
+}
+
+// roundElapsed formats d to the minute, or to the second below a minute.
+func roundElapsed(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+}
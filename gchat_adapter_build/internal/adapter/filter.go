@@ -0,0 +1,96 @@
+package adapter
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Actions of the inbound filter rules.
+const (
+	filterDrop      = "drop"
+	filterKeep      = "keep"
+	filterDowngrade = "downgrade"
+)
+
+// alertFilter drops or downgrades known-noisy alerts as they arrive, before any other
+// processing, without touching Alertmanager's routing. The first rule whose matchers
+// all match an alert decides; alerts matching no rule pass unchanged. A keep rule ahead
+// of a broader drop rule makes an exception to it.
+type alertFilter struct {
+	rules []filterRule
+}
+
+type filterRule struct {
+	matchers []labelMatcher
+	action   string
+	severity string
+}
+
+// newAlertFilter returns nil when no filter rules are configured. The rules have
+// already been checked by Config.validate.
+func newAlertFilter(configs []FilterRuleConfig) *alertFilter {
+	rules, _ := parseFilterRules(configs)
+	if len(rules) == 0 {
+		return nil
+	}
+	return &alertFilter{rules: rules}
+}
+
+func parseFilterRules(configs []FilterRuleConfig) ([]filterRule, error) {
+	var rules []filterRule
+	for i, fc := range configs {
+		if len(fc.Matchers) == 0 {
+			return nil, fmt.Errorf("filters[%d]: matchers is required", i)
+		}
+		matchers, err := parseMatchers(fc.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("filters[%d]: %w", i, err)
+		}
+		switch fc.Action {
+		case filterDrop, filterKeep:
+			if fc.Severity != "" {
+				return nil, fmt.Errorf("filters[%d]: severity can only be used with action downgrade", i)
+			}
+		case filterDowngrade:
+			if fc.Severity == "" {
+				return nil, fmt.Errorf("filters[%d]: severity is required with action downgrade", i)
+			}
+		default:
+			return nil, fmt.Errorf("filters[%d]: unsupported action %q (expected \"drop\", \"keep\" or \"downgrade\")", i, fc.Action)
+		}
+		rules = append(rules, filterRule{matchers: matchers, action: fc.Action, severity: fc.Severity})
+	}
+	return rules, nil
+}
+
+// filter returns the alerts to process, downgraded ones with their new severity, and
+// the dropped ones. The incoming label maps are not modified.
+func (f *alertFilter) filter(alerts []Alert) (kept, dropped []Alert) {
+	for _, alert := range alerts {
+		rule, ok := f.match(alert)
+		switch {
+		case ok && rule.action == filterDrop:
+			dropped = append(dropped, alert)
+			alertsFiltered.WithLabelValues(filterDrop).Inc()
+		case ok && rule.action == filterDowngrade:
+			labels := make(map[string]string, len(alert.Labels)+1)
+			maps.Copy(labels, alert.Labels)
+			labels["severity"] = rule.severity
+			alert.Labels = labels
+			alertsFiltered.WithLabelValues(filterDowngrade).Inc()
+			kept = append(kept, alert)
+		default:
+			kept = append(kept, alert)
+		}
+	}
+	return kept, dropped
+}
+
+func (f *alertFilter) match(alert Alert) (filterRule, bool) {
+	for _, rule := range f.rules {
+		if matchAll(rule.matchers, alert.Labels) {
+			return rule, true
+		}
+	}
+	return filterRule{}, false
+}
@@ -0,0 +1,35 @@
+package adapter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// alertFingerprint identifies an alert by its label set. Alertmanager sends its own
+// fingerprint with every alert; for payloads without one (older versions, hand-written
+// test payloads) an equivalent hash of the sorted labels is computed instead.
+func alertFingerprint(alert Alert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+	return labelsFingerprint(alert.Labels)
+}
+
+// labelsFingerprint hashes a label set independently of map iteration order.
+func labelsFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[name]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,202 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"alertmanager-adapter/notifier"
+)
+
+// googleChatNotifier renders alerts as Google Chat messages.
+type googleChatNotifier struct {
+	router *webhookRouter
+	// messageTemplates hold the message template for each of the router's localizers.
+	messageTemplates map[*localizer]*template.Template
+	customTemplate   bool
+	messageFormat    string
+
+	// threads is nil unless GOOGLE_CHAT_THREAD_BY=incident enables incident threading.
+	threads *threadTracker
+	// threadByNode posts every message about a node in that node's thread.
+	threadByNode bool
+	// links is nil unless linkDomains allows runbook and dashboard buttons.
+	links *linkButtons
+	// actions is nil unless card action buttons are configured.
+	actions *alertActions
+	// onCall is nil unless an on-call schedule is configured.
+	onCall *onCallSchedule
+}
+
+func init() {
+	outputRegistry.Register("gchat", func(cfg *Config, deps outputDeps) (output, error) {
+		return newGoogleChatNotifier(cfg.GoogleChat, deps.locales, deps.threads, deps.actions)
+	})
+}
+
+// newGoogleChatNotifier builds the Google Chat backend. threads is shared across config
+// reloads so incident threads survive them; it is only used when cfg.ThreadBy is
+// "incident".
+// actions may be nil.
+func newGoogleChatNotifier(cfg GoogleChatConfig, locales *localization, threads *threadTracker, actions *alertActions) (output, error) {
+	router, err := newWebhookRouter(cfg.WebhookConfig, locales)
+	if err != nil {
+		return nil, fmt.Errorf("googleChat.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no Google Chat webhook URL is configured")
+	}
+
+	messageTemplate, err := loadMessageTemplate(cfg.TemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	messageTemplates, err := localizeTemplates(messageTemplate, router.allLocalizers())
+	if err != nil {
+		return nil, err
+	}
+
+	n := &googleChatNotifier{
+		router:           router,
+		messageTemplates: messageTemplates,
+		customTemplate:   cfg.TemplatePath != "",
+		messageFormat:    cfg.Format,
+		links:            newLinkButtons(cfg.LinkDomains),
+		actions:          actions,
+		onCall:           newOnCallSchedule(cfg.OnCall),
+	}
+	// ThreadBy "incident" posts repeat and resolved notifications as replies
+	// in the thread of the original firing message.
+	// ThreadBy "node" instead keeps one rolling thread per node for all its alerts.
+	switch cfg.ThreadBy {
+	case "incident":
+		n.threads = threads
+	case "node":
+		n.threadByNode = true
+	}
+	return n, nil
+}
+
+func (n *googleChatNotifier) Name() string { return "gchat" }
+
+func (n *googleChatNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No Google Chat webhook configured for alert, dropping it", alertAttr(alert))
+	}
+
+	if n.threadByNode {
+		var byNode []routedPayload
+		for _, group := range routed {
+			byNode = append(byNode, splitByNode(group)...)
+		}
+		routed = byNode
+	}
+
+	messages := make([]notifier.Notification, 0, len(routed))
+	for _, group := range routed {
+		pages, err := n.paginate(group.payload, group.localizer)
+		if err != nil {
+			return nil, err
+		}
+		if n.onCall != nil {
+			// Only the first page mentions the on-call user, so they are notified once.
+			if mention := n.onCall.mention(group.payload.Alerts); mention != "" {
+				pages[0].message.Text = strings.TrimSuffix("On call: "+mention+"\n"+pages[0].message.Text, "\n")
+			}
+		}
+
+		threadKey := ""
+		if n.threads != nil {
+			threadKey = n.threads.threadKey(group.payload.Alerts)
+		} else if node := alertNode(group.payload.Alerts[0].Labels); n.threadByNode && node != "" {
+			threadKey = nodeThreadKey(node)
+		} else if len(pages) > 1 {
+			threadKey = pageThreadKey(group.payload)
+		}
+		webhookURL := group.webhookURL
+		if threadKey != "" {
+			if webhookURL, err = withThreadKey(webhookURL, threadKey); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, page := range pages {
+			m, err := newNotification(n.Name(), webhookURL, page.alerts, page.message)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// buildMessage renders the payload as a single Google Chat message, localized by l.
+func (n *googleChatNotifier) buildMessage(payload AlertmanagerPayload, l *localizer) (GoogleChatCard, error) {
+	var chatMessage GoogleChatCard
+	if n.messageFormat == "cards" {
+		cards, err := buildCards(payload, l, n.links, n.actions)
+		if err != nil {
+			return chatMessage, err
+		}
+		chatMessage.CardsV2 = cards
+	}
+
+	// The text is always sent in text mode; in card mode only when a custom template was supplied.
+	if n.messageFormat == "text" || n.customTemplate {
+		text, err := renderMessage(n.messageTemplates[l], payload)
+		if err != nil {
+			return chatMessage, err
+		}
+		chatMessage.Text = text
+	}
+	return chatMessage, nil
+}
+
+func (n *googleChatNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *googleChatNotifier) renderText(webhookURL, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), webhookURL, 0, GoogleChatCard{Text: text})
+}
+
+// renderReply builds a text notice about the alert for each of its webhooks, threaded
+// under the alert's incident if one is tracked, or under its node's thread.
+func (n *googleChatNotifier) renderReply(alert Alert, text string) ([]notifier.Notification, error) {
+	urls := n.router.routes(alert)
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no Google Chat webhook configured for alert %s", alert.Labels["alertname"])
+	}
+	key, threaded := "", false
+	if n.threads != nil {
+		key, threaded = n.threads.thread(alertFingerprint(alert))
+	} else if node := alertNode(alert.Labels); n.threadByNode && node != "" {
+		key, threaded = nodeThreadKey(node), true
+	}
+
+	messages := make([]notifier.Notification, 0, len(urls))
+	for _, webhookURL := range urls {
+		if threaded {
+			var err error
+			if webhookURL, err = withThreadKey(webhookURL, key); err != nil {
+				return nil, err
+			}
+		}
+		m, err := n.renderText(webhookURL, text)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// send posts a single message to a Google Chat incoming webhook.
+func (n *googleChatNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, m.Destination, m.Body); err != nil {
+		return fmt.Errorf("posting to Google Chat: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package adapter
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// grafanaPayload is the webhook body of Grafana unified alerting. It extends the
+// Alertmanager format with per-alert links and the evaluated values.
+type grafanaPayload struct {
+	Status            string            `json:"status"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []grafanaAlert    `json:"alerts"`
+
+	// The remaining fields Grafana sends are not used, but declared so strict decoding
+	// accepts them.
+	Version string `json:"version"`
+	OrgID   int64  `json:"orgId"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+}
+
+type grafanaAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     string            `json:"startsAt"`
+	EndsAt       string            `json:"endsAt"`
+	Fingerprint  string            `json:"fingerprint"`
+	GeneratorURL string            `json:"generatorURL"`
+	SilenceURL   string            `json:"silenceURL"`
+	DashboardURL string            `json:"dashboardURL"`
+	PanelURL     string            `json:"panelURL"`
+	ValueString  string            `json:"valueString"`
+	Values       map[string]any    `json:"values"`
+	ImageURL     string            `json:"imageURL"`
+}
+
+// handleGrafana accepts Grafana-managed alerts on /grafana and forwards them like
+// Alertmanager alerts.
+func (a *adapter) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	var payload grafanaPayload
+	if err := decodeJSON(json.NewDecoder(r.Body), &payload, a.strictPayloads); err != nil {
+		loggerFrom(r.Context()).Warn("Error decoding Grafana payload", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+	normalized := payload.normalize()
+	if err := normalized.validate(); err != nil {
+		loggerFrom(r.Context()).Warn("Invalid Grafana payload", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+	a.accept(w, r, normalized)
+}
+
+// normalize converts the payload to the internal model. Grafana's links and values are
+// kept as annotations so templates can use them; an existing annotation of the same name
+// takes precedence.
+func (p grafanaPayload) normalize() AlertmanagerPayload {
+	payload := AlertmanagerPayload{
+		GroupKey:          p.GroupKey,
+		TruncatedAlerts:   p.TruncatedAlerts,
+		Status:            p.Status,
+		Receiver:          p.Receiver,
+		GroupLabels:       p.GroupLabels,
+		CommonLabels:      p.CommonLabels,
+		CommonAnnotations: p.CommonAnnotations,
+		ExternalURL:       p.ExternalURL,
+	}
+	for _, ga := range p.Alerts {
+		alert := Alert{
+			Labels:       ga.Labels,
+			Annotations:  make(map[string]string, len(ga.Annotations)+5),
+			Status:       ga.Status,
+			StartsAt:     ga.StartsAt,
+			EndsAt:       ga.EndsAt,
+			GeneratorURL: ga.GeneratorURL,
+			Fingerprint:  ga.Fingerprint,
+		}
+		for name, value := range map[string]string{
+			"value":         ga.ValueString,
+			"generator_url": ga.GeneratorURL,
+			"silence_url":   ga.SilenceURL,
+			"dashboard_url": ga.DashboardURL,
+			"panel_url":     ga.PanelURL,
+		} {
+			if value != "" {
+				alert.Annotations[name] = value
+			}
+		}
+		for name, value := range ga.Annotations {
+			alert.Annotations[name] = value
+		}
+		if alert.Labels == nil {
+			alert.Labels = map[string]string{}
+		}
+		payload.Alerts = append(payload.Alerts, alert)
+	}
+	return payload
+}
@@ -0,0 +1,98 @@
+package adapter
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// alertGrouper buffers payloads per Alertmanager group key for a fixed window and then
+// hands a single combined payload to flush. This collapses the bursts of webhook calls
+// Alertmanager sends during a node flap into one message per group and window.
+type alertGrouper struct {
+	window time.Duration
+	flush  func(AlertmanagerPayload)
+
+	mu      sync.Mutex
+	pending map[string]*pendingGroup
+}
+
+// pendingGroup is the combined payload for one group key, waiting for its window to end.
+type pendingGroup struct {
+	payload AlertmanagerPayload
+	// index maps an alert fingerprint to its position in payload.Alerts.
+	index map[string]int
+}
+
+func newAlertGrouper(window time.Duration, flush func(AlertmanagerPayload)) *alertGrouper {
+	return &alertGrouper{
+		window:  window,
+		flush:   flush,
+		pending: make(map[string]*pendingGroup),
+	}
+}
+
+// add merges the payload into its group. The first payload for a group starts the window;
+// later ones within the window replace alerts with the same fingerprint, so each alert
+// appears once with its latest state.
+func (g *alertGrouper) add(payload AlertmanagerPayload) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := payload.GroupKey
+	group, ok := g.pending[key]
+	if !ok {
+		group = &pendingGroup{payload: payload, index: make(map[string]int)}
+		group.payload.Alerts = nil
+		g.pending[key] = group
+		time.AfterFunc(g.window, func() { g.flushGroup(key) })
+	}
+
+	for _, alert := range payload.Alerts {
+		fp := alertFingerprint(alert)
+		if i, seen := group.index[fp]; seen {
+			group.payload.Alerts[i] = alert
+			continue
+		}
+		group.index[fp] = len(group.payload.Alerts)
+		group.payload.Alerts = append(group.payload.Alerts, alert)
+	}
+	group.payload.Status = combinedStatus(group.payload.Alerts)
+}
+
+func (g *alertGrouper) flushGroup(key string) {
+	g.mu.Lock()
+	group, ok := g.pending[key]
+	delete(g.pending, key)
+	g.mu.Unlock()
+
+	if !ok || len(group.payload.Alerts) == 0 {
+		return
+	}
+	slog.Info("Flushing grouped alerts", "group_key", key, "alerts", len(group.payload.Alerts))
+	g.flush(group.payload)
+}
+
+// flushAll flushes every pending group right away, e.g. on shutdown.
+func (g *alertGrouper) flushAll() {
+	g.mu.Lock()
+	keys := make([]string, 0, len(g.pending))
+	for key := range g.pending {
+		keys = append(keys, key)
+	}
+	g.mu.Unlock()
+
+	for _, key := range keys {
+		g.flushGroup(key)
+	}
+}
+
+// combinedStatus is "firing" while any alert in the group still fires, "resolved" otherwise.
+func combinedStatus(alerts []Alert) string {
+	for _, alert := range alerts {
+		if alert.Status != "resolved" {
+			return "firing"
+		}
+	}
+	return "resolved"
+}
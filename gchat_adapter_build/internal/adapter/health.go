@@ -0,0 +1,124 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// healthTracker remembers the outcome of recent deliveries per backend for /readyz.
+type healthTracker struct {
+	// window is how long a backend may keep failing without a single success before the
+	// adapter reports itself as not ready.
+	window time.Duration
+	// maxQueued is the outbound queue size above which the adapter is not ready; 0 disables the check.
+	maxQueued int
+
+	mu       sync.Mutex
+	backends map[string]*backendHealth
+}
+
+type backendHealth struct {
+	// failingSince is the first failure after the last success; zero while deliveries succeed.
+	failingSince time.Time
+	lastError    string
+}
+
+// readinessCheck is one entry of the /readyz response.
+type readinessCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func newHealthTracker(cfg ReadinessConfig) *healthTracker {
+	return &healthTracker{window: cfg.BackendWindow, maxQueued: cfg.MaxQueued, backends: make(map[string]*backendHealth)}
+}
+
+// record notes the outcome of a delivery to backend.
+func (h *healthTracker) record(backend string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.backends[backend]
+	if !ok {
+		b = &backendHealth{}
+		h.backends[backend] = b
+	}
+	if err == nil {
+		b.failingSince = time.Time{}
+		return
+	}
+	if b.failingSince.IsZero() {
+		b.failingSince = time.Now()
+	}
+	b.lastError = err.Error()
+}
+
+// backendCheck fails once every delivery to the backend has failed for longer than the
+// window. An idle backend counts as reachable.
+func (h *healthTracker) backendCheck(backend string) readinessCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.backends[backend]
+	if !ok || b.failingSince.IsZero() {
+		return readinessCheck{OK: true}
+	}
+	failing := time.Since(b.failingSince)
+	if failing <= h.window {
+		return readinessCheck{OK: true, Detail: "last delivery failed: " + b.lastError}
+	}
+	detail := fmt.Sprintf("deliveries failing for %s: %s", failing.Round(time.Second), b.lastError)
+	return readinessCheck{OK: false, Detail: detail}
+}
+
+// handleHealthz reports that the process is up and serving requests.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "ok")
+}
+
+// handleReadyz reports whether the adapter can currently forward alerts: a configuration
+// with at least one backend is loaded, every backend delivered successfully recently (or
+// has not failed), and the outbound queue is below its capacity.
+func (a *adapter) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]readinessCheck)
+	notifiers, _ := a.current()
+	checks["config"] = readinessCheck{OK: len(notifiers) > 0}
+
+	names := make([]string, 0, len(notifiers))
+	for _, n := range notifiers {
+		names = append(names, n.Name())
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks["backend:"+name] = a.health.backendCheck(name)
+	}
+
+	if a.queue != nil {
+		queued := a.queue.pending()
+		check := readinessCheck{OK: true, Detail: fmt.Sprintf("%d message(s) queued", queued)}
+		if a.health.maxQueued > 0 && queued > a.health.maxQueued {
+			check.OK = false
+			check.Detail = fmt.Sprintf("%d message(s) queued, capacity is %d", queued, a.health.maxQueued)
+		}
+		checks["queue"] = check
+	}
+
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status string                    `json:"status"`
+		Checks map[string]readinessCheck `json:"checks"`
+	}{status, checks})
+}
@@ -0,0 +1,329 @@
+package adapter
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// heartbeatTTL is how long the last heartbeat of a node is kept in the state store.
+const heartbeatTTL = 7 * 24 * time.Hour
+
+// maxHeartbeatBody bounds the body of POST /api/heartbeat, which only names a node.
+const maxHeartbeatBody = 4 << 10
+
+// nodeHeartbeats is a dead man's switch for GPU nodes: every gpu-collector with
+// HEARTBEAT_URL posts a heartbeat every interval, and a node that misses
+// MissedHeartbeats in a row raises NodeUnreachable through the same pipeline as
+// webhooks. The alert resolves with the node's next heartbeat. This catches what the
+// node's own alerts cannot: a hung kernel, a power loss or a lost network.
+//
+// The last heartbeat of each node is kept in the state store, so with a shared Redis
+// the replicas agree on it wherever the load balancer sent the heartbeat; the
+// deduplicator then keeps their alerts from being posted twice.
+type nodeHeartbeats struct {
+	store    stateStore
+	interval time.Duration
+	missed   int
+	severity string
+	token    string
+	process  func(context.Context, AlertmanagerPayload) (string, error)
+
+	mu sync.Mutex
+	// nodes holds every node this replica heard from or was configured with.
+	nodes map[string]*heartbeatNode
+}
+
+type heartbeatNode struct {
+	lastSeen time.Time
+	interval time.Duration
+	// firing is the NodeUnreachable alert while it fires.
+	firing *Alert
+}
+
+// heartbeatRequest is the body of POST /api/heartbeat.
+type heartbeatRequest struct {
+	Node string `json:"node"`
+	// Interval is how often the node sends heartbeats, e.g. "30s"; heartbeat.interval
+	// is assumed without it.
+	Interval string `json:"interval"`
+}
+
+// heartbeatStatus describes a node in GET /api/heartbeat.
+type heartbeatStatus struct {
+	Node        string    `json:"node"`
+	LastSeen    time.Time `json:"lastSeen,omitempty"`
+	Interval    string    `json:"interval"`
+	Unreachable bool      `json:"unreachable"`
+}
+
+// newNodeHeartbeats returns nil when the dead man's switch is disabled. Nodes are
+// expected to send their first heartbeat within MissedHeartbeats intervals of the
+// start.
+func newNodeHeartbeats(cfg HeartbeatConfig, store stateStore, process func(context.Context, AlertmanagerPayload) (string, error)) *nodeHeartbeats {
+	if !cfg.Enabled {
+		return nil
+	}
+	h := &nodeHeartbeats{
+		store:    store,
+		interval: cfg.Interval,
+		missed:   cfg.MissedHeartbeats,
+		severity: cfg.Severity,
+		token:    cfg.Token,
+		process:  process,
+		nodes:    make(map[string]*heartbeatNode),
+	}
+	now := time.Now()
+	for _, node := range cfg.Nodes {
+		h.nodes[node] = &heartbeatNode{lastSeen: now, interval: cfg.Interval}
+	}
+	return h
+}
+
+// run checks for overdue nodes every interval, for as long as the process runs.
+func (h *nodeHeartbeats) run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		h.check(context.Background(), time.Now())
+	}
+}
+
+// check raises NodeUnreachable for nodes whose last heartbeat is overdue and resolves it
+// for nodes heard from again. The last heartbeat is read from the state store, so
+// heartbeats received by other replicas count. If a post fails, the node's state is
+// kept so it is posted again on the next check.
+func (h *nodeHeartbeats) check(ctx context.Context, now time.Time) {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.nodes))
+	for name := range h.nodes {
+		names = append(names, name)
+	}
+	h.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		lastSeen, interval, ok := h.load(name)
+
+		h.mu.Lock()
+		node, known := h.nodes[name]
+		if !known {
+			// Forgotten through DELETE /api/heartbeat meanwhile.
+			h.mu.Unlock()
+			continue
+		}
+		if ok && lastSeen.After(node.lastSeen) {
+			node.lastSeen, node.interval = lastSeen, interval
+		}
+		silence := now.Sub(node.lastSeen)
+		overdue := silence > time.Duration(h.missed)*node.interval
+		var alert Alert
+		switch {
+		case overdue && node.firing == nil:
+			alert = h.newAlert(name, node, now)
+		case !overdue && node.firing != nil:
+			alert = *node.firing
+			alert.Status = "resolved"
+			alert.EndsAt = now.UTC().Format(time.RFC3339)
+		}
+		h.mu.Unlock()
+		if alert.Status == "" {
+			continue
+		}
+
+		if err := h.post(ctx, name, alert); err != nil {
+			slog.Error("Error posting heartbeat alert", alertAttr(alert), "err", err)
+			continue
+		}
+		h.mu.Lock()
+		if node, ok := h.nodes[name]; ok {
+			if alert.Status == "firing" {
+				node.firing = &alert
+			} else {
+				node.firing = nil
+			}
+		}
+		h.mu.Unlock()
+	}
+	h.updateMetrics()
+}
+
+func (h *nodeHeartbeats) newAlert(name string, node *heartbeatNode, now time.Time) Alert {
+	labels := map[string]string{"alertname": "NodeUnreachable", "instance": name, "job": "heartbeat", "severity": h.severity}
+	silence := now.Sub(node.lastSeen).Round(time.Second)
+	return Alert{
+		Status: "firing",
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("No heartbeat from %s for %s", name, silence),
+			"description": fmt.Sprintf("%s sends a heartbeat every %s and missed at least %d in a row. The node may be hung, powered off or cut off from the network; the alert resolves with its next heartbeat.",
+				name, node.interval, h.missed),
+		},
+		StartsAt:    now.UTC().Format(time.RFC3339),
+		EndsAt:      "0001-01-01T00:00:00Z",
+		Fingerprint: labelsFingerprint(labels),
+	}
+}
+
+func (h *nodeHeartbeats) post(ctx context.Context, name string, alert Alert) error {
+	payload := AlertmanagerPayload{
+		Status:       alert.Status,
+		Receiver:     "heartbeat",
+		GroupKey:     "heartbeat/" + name,
+		GroupLabels:  map[string]string{"alertname": "NodeUnreachable", "instance": name},
+		CommonLabels: alert.Labels,
+		Alerts:       []Alert{alert},
+	}
+	_, err := h.process(ctx, payload)
+	return err
+}
+
+// load reads the last heartbeat of a node from the state store.
+func (h *nodeHeartbeats) load(name string) (time.Time, time.Duration, bool) {
+	value, ok, err := h.store.get("heartbeat/" + name)
+	if err != nil {
+		slog.Warn("Error reading heartbeat from the state store", "node", name, "err", err)
+		return time.Time{}, 0, false
+	}
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	seen, interval, _ := strings.Cut(value, " ")
+	unix, err := strconv.ParseInt(seen, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		d = h.interval
+	}
+	return time.Unix(0, unix), d, true
+}
+
+// handle serves POST /api/heartbeat (a node's heartbeat), GET /api/heartbeat (the
+// status of every node) and DELETE /api/heartbeat?node= (forget a decommissioned node).
+func (h *nodeHeartbeats) handle(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+h.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.receive(w, r)
+	case http.MethodGet:
+		h.list(w)
+	case http.MethodDelete:
+		h.forget(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *nodeHeartbeats) receive(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHeartbeatBody)
+	var req heartbeatRequest
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err = json.NewDecoder(r.Body).Decode(&req)
+	} else if err = r.ParseForm(); err == nil {
+		req = heartbeatRequest{Node: r.FormValue("node"), Interval: r.FormValue("interval")}
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Node == "" {
+		http.Error(w, "node is required", http.StatusBadRequest)
+		return
+	}
+	interval := h.interval
+	if req.Interval != "" {
+		d, err := time.ParseDuration(req.Interval)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid interval", http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	now := time.Now()
+	if err := h.store.set("heartbeat/"+req.Node, fmt.Sprintf("%d %s", now.UnixNano(), interval), heartbeatTTL); err != nil {
+		loggerFrom(r.Context()).Warn("Error storing heartbeat in the state store", "node", req.Node, "err", err)
+	}
+	h.mu.Lock()
+	node, ok := h.nodes[req.Node]
+	if !ok {
+		node = &heartbeatNode{}
+		h.nodes[req.Node] = node
+		loggerFrom(r.Context()).Info("First heartbeat from node", "node", req.Node, "interval", interval.String())
+	}
+	node.lastSeen, node.interval = now, interval
+	h.mu.Unlock()
+	heartbeatsReceived.Inc()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *nodeHeartbeats) list(w http.ResponseWriter) {
+	h.mu.Lock()
+	nodes := make([]heartbeatStatus, 0, len(h.nodes))
+	for name, node := range h.nodes {
+		nodes = append(nodes, heartbeatStatus{Node: name, LastSeen: node.lastSeen.UTC(), Interval: node.interval.String(), Unreachable: node.firing != nil})
+	}
+	h.mu.Unlock()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// forget stops watching a node, resolving its alert if it fires.
+func (h *nodeHeartbeats) forget(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("node")
+	h.mu.Lock()
+	node, ok := h.nodes[name]
+	delete(h.nodes, name)
+	h.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown node", http.StatusNotFound)
+		return
+	}
+	if err := h.store.del("heartbeat/" + name); err != nil {
+		loggerFrom(r.Context()).Warn("Error deleting heartbeat from the state store", "node", name, "err", err)
+	}
+	if node.firing != nil {
+		alert := *node.firing
+		alert.Status = "resolved"
+		alert.EndsAt = time.Now().UTC().Format(time.RFC3339)
+		if err := h.post(r.Context(), name, alert); err != nil {
+			loggerFrom(r.Context()).Error("Error posting heartbeat alert", alertAttr(alert), "err", err)
+		}
+	}
+	h.updateMetrics()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *nodeHeartbeats) updateMetrics() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	unreachable := 0
+	for _, node := range h.nodes {
+		if node.firing != nil {
+			unreachable++
+		}
+	}
+	heartbeatNodes.WithLabelValues("reachable").Set(float64(len(h.nodes) - unreachable))
+	heartbeatNodes.WithLabelValues("unreachable").Set(float64(unreachable))
+}
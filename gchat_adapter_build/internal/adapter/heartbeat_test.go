@@ -0,0 +1,62 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatConfigRequiresToken(t *testing.T) {
+	const base = "outputs: [gchat]\ngoogleChat:\n  webhookURL: https://chat.googleapis.com/v1/spaces/default/messages\n"
+	if _, err := parseConfig([]byte(base+"heartbeat:\n  enabled: true\n"), "test.yml"); err == nil || !strings.Contains(err.Error(), "heartbeat requires token") {
+		t.Errorf("parseConfig() without a token: %v, want an error", err)
+	}
+	if _, err := parseConfig([]byte(base+"heartbeat:\n  enabled: true\n  token: t0ken\n"), "test.yml"); err != nil {
+		t.Errorf("parseConfig() with a token: %v", err)
+	}
+}
+
+func TestHeartbeatAPI(t *testing.T) {
+	h := newNodeHeartbeats(HeartbeatConfig{Enabled: true, Interval: 30 * time.Second, MissedHeartbeats: 3, Severity: "critical", Token: "t0ken"}, newMemoryStore(),
+		func(context.Context, AlertmanagerPayload) (string, error) { return "", nil })
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		authorization string
+		wantStatus    int
+	}{
+		{"without token", "application/json", `{"node":"gpu-node-01"}`, "", http.StatusUnauthorized},
+		{"wrong token", "application/json", `{"node":"gpu-node-01"}`, "Bearer wrong", http.StatusUnauthorized},
+		{"empty bearer", "application/json", `{"node":"gpu-node-01"}`, "Bearer ", http.StatusUnauthorized},
+		{"json", "application/json", `{"node":"gpu-node-01","interval":"1m"}`, "Bearer t0ken", http.StatusNoContent},
+		{"form", "application/x-www-form-urlencoded", "node=gpu-node-02", "Bearer t0ken", http.StatusNoContent},
+		{"invalid json", "application/json", `{"node":`, "Bearer t0ken", http.StatusBadRequest},
+		{"json too large", "application/json", `{"node":"` + strings.Repeat("x", maxHeartbeatBody) + `"}`, "Bearer t0ken", http.StatusRequestEntityTooLarge},
+		{"form too large", "application/x-www-form-urlencoded", "node=" + strings.Repeat("x", maxHeartbeatBody), "Bearer t0ken", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/heartbeat", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.handle(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.nodes) != 2 || h.nodes["gpu-node-01"] == nil || h.nodes["gpu-node-02"] == nil {
+		t.Errorf("nodes %v, want gpu-node-01 and gpu-node-02", h.nodes)
+	}
+}
@@ -0,0 +1,255 @@
+package adapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Delivery outcomes recorded in the alert history.
+const (
+	outcomeDelivered   = "delivered"
+	outcomeQueued      = "queued"
+	outcomeFailed      = "failed"
+	outcomeDuplicate   = "duplicate"
+	outcomeMaintenance = "maintenance"
+	outcomeDigest      = "digest"
+	outcomeStorm       = "storm"
+	outcomeFiltered    = "filtered"
+	outcomeMuted       = "muted"
+	outcomeInhibited   = "inhibited"
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS alerts (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	received_at INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,
+	alertname   TEXT NOT NULL,
+	instance    TEXT NOT NULL,
+	severity    TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	starts_at   TEXT NOT NULL,
+	ends_at     TEXT NOT NULL,
+	labels      TEXT NOT NULL,
+	annotations TEXT NOT NULL,
+	outcome     TEXT NOT NULL,
+	error       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS alerts_received_at ON alerts (received_at);
+CREATE INDEX IF NOT EXISTS alerts_instance ON alerts (instance, received_at);
+`
+
+// alertHistory records every received alert and what happened to it in a SQLite file,
+// so operators can audit what fired and whether it reached the chat.
+type alertHistory struct {
+	db *sql.DB
+}
+
+// historyRecord is one row of the history as returned by the query API.
+type historyRecord struct {
+	ID          int64             `json:"id"`
+	ReceivedAt  time.Time         `json:"receivedAt"`
+	Fingerprint string            `json:"fingerprint"`
+	Status      string            `json:"status"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Outcome     string            `json:"outcome"`
+	Error       string            `json:"error,omitempty"`
+}
+
+func openAlertHistory(path string) (*alertHistory, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serializing on one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating history schema: %w", err)
+	}
+	return &alertHistory{db: db}, nil
+}
+
+func (h *alertHistory) close() error {
+	return h.db.Close()
+}
+
+// record stores the alerts with their delivery outcome. Failures are only logged: the
+// history must never stand in the way of forwarding alerts.
+func (h *alertHistory) record(alerts []Alert, outcome string, deliveryErr error) {
+	errText := ""
+	if deliveryErr != nil {
+		errText = deliveryErr.Error()
+	}
+	receivedAt := time.Now().UnixMilli()
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		slog.Error("Error recording alert history", "err", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, alert := range alerts {
+		labels, _ := json.Marshal(alert.Labels)
+		annotations, _ := json.Marshal(alert.Annotations)
+		_, err := tx.Exec(`INSERT INTO alerts (received_at, fingerprint, alertname, instance, severity, status,
+			starts_at, ends_at, labels, annotations, outcome, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			receivedAt, alertFingerprint(alert), alert.Labels["alertname"], alert.Labels["instance"], alert.Labels["severity"],
+			alert.Status, alert.StartsAt, alert.EndsAt, string(labels), string(annotations), outcome, errText)
+		if err != nil {
+			slog.Error("Error recording alert history", "err", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error recording alert history", "err", err)
+	}
+}
+
+// historyQuery filters the history. Empty fields match everything.
+type historyQuery struct {
+	// Instance matches the instance label with or without its port.
+	Instance  string
+	Alertname string
+	Severity  string
+	Status    string
+	Outcome   string
+	Since     time.Time
+	Limit     int
+}
+
+// query returns matching records, newest first.
+func (h *alertHistory) query(q historyQuery) ([]historyRecord, error) {
+	stmt := `SELECT id, received_at, fingerprint, status, starts_at, ends_at, labels, annotations, outcome, error
+		FROM alerts WHERE received_at >= ?`
+	args := []any{q.Since.UnixMilli()}
+	if q.Instance != "" {
+		stmt += ` AND (instance = ? OR instance LIKE ? ESCAPE '\')`
+		args = append(args, q.Instance, escapeLike(q.Instance)+":%")
+	}
+	for column, value := range map[string]string{
+		"alertname": q.Alertname,
+		"severity":  q.Severity,
+		"status":    q.Status,
+		"outcome":   q.Outcome,
+	} {
+		if value != "" {
+			stmt += " AND " + column + " = ?"
+			args = append(args, value)
+		}
+	}
+	stmt += " ORDER BY id DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := h.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanHistoryRecords(rows)
+}
+
+// firing returns the alerts whose latest record since the given time is firing, most
+// recently received first.
+func (h *alertHistory) firing(since time.Time) ([]historyRecord, error) {
+	rows, err := h.db.Query(`SELECT id, received_at, fingerprint, status, starts_at, ends_at, labels, annotations, outcome, error
+		FROM alerts WHERE id IN (SELECT MAX(id) FROM alerts WHERE received_at >= ? GROUP BY fingerprint)
+		AND status = 'firing' ORDER BY id DESC`, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return scanHistoryRecords(rows)
+}
+
+// scanHistoryRecords reads and closes the rows of a history query.
+func scanHistoryRecords(rows *sql.Rows) ([]historyRecord, error) {
+	defer rows.Close()
+
+	records := []historyRecord{}
+	for rows.Next() {
+		var r historyRecord
+		var receivedAt int64
+		var labels, annotations string
+		if err := rows.Scan(&r.ID, &receivedAt, &r.Fingerprint, &r.Status, &r.StartsAt, &r.EndsAt,
+			&labels, &annotations, &r.Outcome, &r.Error); err != nil {
+			return nil, err
+		}
+		r.ReceivedAt = time.UnixMilli(receivedAt).UTC()
+		if err := json.Unmarshal([]byte(labels), &r.Labels); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(annotations), &r.Annotations); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	var out []rune
+	for _, c := range s {
+		if c == '%' || c == '_' || c == '\\' {
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// handleAlerts serves GET /api/alerts. Supported parameters: instance, alertname,
+// severity, status, outcome, since (a duration such as "24h", default 24h) and limit
+// (default 100, at most 1000).
+func (h *alertHistory) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	q := historyQuery{
+		Instance:  params.Get("instance"),
+		Alertname: params.Get("alertname"),
+		Severity:  params.Get("severity"),
+		Status:    params.Get("status"),
+		Outcome:   params.Get("outcome"),
+		Since:     time.Now().Add(-24 * time.Hour),
+		Limit:     100,
+	}
+	if since := params.Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid since duration", http.StatusBadRequest)
+			return
+		}
+		q.Since = time.Now().Add(-d)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+
+	records, err := h.query(q)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error querying alert history", "err", err)
+		http.Error(w, "Error querying alert history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
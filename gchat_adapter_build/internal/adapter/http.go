@@ -0,0 +1,206 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"alertmanager-adapter/notifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultHTTPBody sends the routed payload in Alertmanager's webhook format.
+const defaultHTTPBody = `{{toJSON .}}`
+
+// httpNotifier sends alerts to home-grown ticketing systems and internal APIs as
+// requests built from each endpoint's method, URL, headers and body template, so a new
+// system takes configuration instead of a backend. The rendered request, headers
+// included, is the notification, so a queued request is retried as it was rendered.
+type httpNotifier struct {
+	router *webhookRouter
+	// endpoints are keyed by destination: the endpoint's name, or else its URL.
+	endpoints map[string]*httpEndpoint
+}
+
+type httpEndpoint struct {
+	method       string
+	url          string
+	headers      map[string]string
+	body         *template.Template
+	perAlert     bool
+	skipResolved bool
+}
+
+// httpRequest is the body of an http notification.
+type httpRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body"`
+}
+
+func init() {
+	outputRegistry.Register("http", func(cfg *Config, deps outputDeps) (output, error) {
+		return newHTTPNotifier(cfg.HTTP, deps.locales)
+	})
+}
+
+func newHTTPNotifier(cfg HTTPConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(cfg.webhookConfig(), locales)
+	if err != nil {
+		return nil, fmt.Errorf("http.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no HTTP endpoint is configured")
+	}
+	n := &httpNotifier{router: router, endpoints: make(map[string]*httpEndpoint)}
+	if cfg.URL != "" {
+		if err := n.addEndpoint(cfg.HTTPEndpointConfig); err != nil {
+			return nil, fmt.Errorf("http: %w", err)
+		}
+	}
+	for i, rc := range cfg.Routes {
+		if err := n.addEndpoint(rc.HTTPEndpointConfig); err != nil {
+			return nil, fmt.Errorf("http.routes[%d]: %w", i, err)
+		}
+	}
+	return n, nil
+}
+
+// addEndpoint parses the body template of an endpoint, with the helper functions bound
+// to the localizer of its destination.
+func (n *httpNotifier) addEndpoint(ec HTTPEndpointConfig) error {
+	destination := ec.destination()
+	if _, ok := n.endpoints[destination]; ok {
+		// Config.validate made sure endpoints sharing a destination are the same.
+		return nil
+	}
+	body := ec.Body
+	if body == "" {
+		body = defaultHTTPBody
+	}
+	tmpl, err := template.New(destination).Option("missingkey=zero").Funcs(templateFuncs(n.router.localizer(destination))).Parse(body)
+	if err != nil {
+		return fmt.Errorf("parsing body template: %w", err)
+	}
+	method := strings.ToUpper(ec.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	headers := make(map[string]string, len(ec.Headers)+1)
+	for name, value := range ec.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	if _, ok := headers["Content-Type"]; !ok {
+		headers["Content-Type"] = "application/json"
+	}
+	n.endpoints[destination] = &httpEndpoint{method: method, url: ec.URL, headers: headers, body: tmpl, perAlert: ec.PerAlert, skipResolved: ec.SkipResolved}
+	return nil
+}
+
+func (n *httpNotifier) Name() string { return "http" }
+
+// render builds one request per endpoint, or per alert for endpoints with perAlert. The
+// body template gets the payload with the endpoint's alerts as its data, like message
+// templates.
+func (n *httpNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No HTTP endpoint configured for alert, dropping it", alertAttr(alert))
+	}
+
+	var requests []notifier.Notification
+	for _, group := range routed {
+		endpoint := n.endpoints[group.webhookURL]
+		var alerts []Alert
+		for _, alert := range group.payload.Alerts {
+			if !endpoint.skipResolved || alert.Status != "resolved" {
+				alerts = append(alerts, alert)
+			}
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+
+		var chunks []AlertmanagerPayload
+		if endpoint.perAlert {
+			for _, alert := range alerts {
+				chunk := group.payload
+				chunk.Status = alert.Status
+				chunk.Alerts = []Alert{alert}
+				chunks = append(chunks, chunk)
+			}
+		} else {
+			chunk := group.payload
+			chunk.Alerts = alerts
+			chunks = append(chunks, chunk)
+		}
+		for _, chunk := range chunks {
+			var body bytes.Buffer
+			if err := endpoint.body.Execute(&body, chunk); err != nil {
+				return nil, fmt.Errorf("executing body template of %s: %w", group.webhookURL, err)
+			}
+			req := httpRequest{Method: endpoint.method, URL: endpoint.url, Header: endpoint.headers, Body: body.String()}
+			m, err := newNotification(n.Name(), group.webhookURL, len(chunk.Alerts), req)
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, m)
+		}
+	}
+	return requests, nil
+}
+
+func (n *httpNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+// renderText is not supported: the endpoints expect the bodies of their templates.
+func (n *httpNotifier) renderText(destination, text string) (notifier.Notification, error) {
+	return notifier.Notification{}, errTextUnsupported
+}
+
+func (n *httpNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	var req httpRequest
+	if err := json.Unmarshal(m.Body, &req); err != nil {
+		return fmt.Errorf("decoding HTTP request: %w", err)
+	}
+	if err := sendHTTPRequest(ctx, req); err != nil {
+		return fmt.Errorf("sending HTTP request to %s: %w", m.Destination, err)
+	}
+	return nil
+}
+
+// sendHTTPRequest is postJSONWithHeader for requests of any method and content type.
+func sendHTTPRequest(ctx context.Context, r httpRequest) (err error) {
+	ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(webhookHost(r.URL)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := newOutboundRequest(ctx, r.Method, r.URL, []byte(r.Body))
+	if err != nil {
+		return err
+	}
+	for name, value := range r.Header {
+		req.Header.Set(name, value)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	setResponseStatus(ctx, resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
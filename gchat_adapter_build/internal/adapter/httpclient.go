@@ -0,0 +1,145 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// outboundClient posts the notifications of every webhook and API backend. It is
+// replaced by setupHTTPClient at startup; the default client only serves code paths
+// that run before the config is loaded.
+var outboundClient = http.DefaultClient
+
+// outboundGzipMinBytes is the body size from which posts are gzip-compressed; 0 leaves
+// every body uncompressed. It is set by setupHTTPClient along with outboundClient.
+var outboundGzipMinBytes int
+
+// setupHTTPClient builds outboundClient from the config. A hung backend then fails the
+// post after requestTimeout and is retried like any other failure, instead of blocking
+// the delivery forever.
+func setupHTTPClient(cfg HTTPClientConfig) error {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	outboundClient = client
+	outboundGzipMinBytes = 0
+	if cfg.Compression == "gzip" {
+		outboundGzipMinBytes = max(cfg.CompressMinBytes, 1)
+	}
+	return nil
+}
+
+// newHTTPClient returns a client with the configured timeouts, proxy and connection
+// pool. Without a proxyURL the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables
+// are honored.
+func newHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid httpClient.proxyURL: %w", err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: cfg.KeepAlive}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.RequestTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.RequestTimeout}, nil
+}
+
+// newOutboundRequest builds a post of an encoded body. The JSON of notifications is
+// encoded up front, since the notification carries it through the queue and retries.
+// With compression enabled, bodies of at least outboundGzipMinBytes are gzip-compressed
+// through a pipe while the transport sends them instead of into another buffer first;
+// GetBody restarts the compression for redirects and retried HTTP/2 requests.
+func newOutboundRequest(ctx context.Context, method, target string, body []byte) (*http.Request, error) {
+	if outboundGzipMinBytes == 0 || len(body) < outboundGzipMinBytes {
+		return http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	}
+	write := func(w io.Writer) error {
+		_, err := w.Write(body)
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, gzipStream(write))
+	if err != nil {
+		return nil, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) { return gzipStream(write), nil }
+	req.Header.Set("Content-Encoding", "gzip")
+	return req, nil
+}
+
+// newOutboundJSONRequest builds a request whose body is v encoded as JSON while the
+// transport sends it, for API calls that are not queued as notifications. The size is
+// not known up front, so with compression enabled every such body is compressed.
+func newOutboundJSONRequest(ctx context.Context, method, target string, v any) (*http.Request, error) {
+	write := func(w io.Writer) error { return json.NewEncoder(w).Encode(v) }
+	stream := plainStream
+	if outboundGzipMinBytes > 0 {
+		stream = gzipStream
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, stream(write))
+	if err != nil {
+		return nil, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) { return stream(write), nil }
+	if outboundGzipMinBytes > 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
+// gzipWriters are reused across posts, since every gzip.Writer allocates its own
+// compression tables.
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// gzipStream compresses what write produces into the returned reader as the transport
+// reads it.
+func gzipStream(write func(io.Writer) error) io.ReadCloser {
+	return plainStream(func(w io.Writer) error {
+		zw := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(zw)
+		zw.Reset(w)
+		if err := write(zw); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// plainStream returns a reader of what write produces, running write as the transport
+// reads it.
+func plainStream(write func(io.Writer) error) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() { w.CloseWithError(write(w)) }()
+	return r
+}
+
+// drainAndClose reads what is left of a response body before closing it, so the
+// transport can put the connection back into the pool instead of closing it. Long
+// bodies are not worth reading to the end and close the connection.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
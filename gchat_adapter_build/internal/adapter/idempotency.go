@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// idempotencyKeyHeader lets senders other than Alertmanager name their retries.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyPendingTTL bounds how long a webhook being processed holds its key, so a
+// replica dying mid-request does not block the retries.
+const idempotencyPendingTTL = 5 * time.Minute
+
+const (
+	idempotencyPending = "pending"
+	idempotencyDone    = "done"
+)
+
+// webhookIdempotency answers webhook retries from the result of the first delivery.
+// Alertmanager retries a webhook that timed out even if the adapter did post it, which
+// would post the messages twice; such a retry carries the same body, so a request whose
+// group key and body hash (or Idempotency-Key header) succeeded within the TTL is
+// answered with 200 without being processed again. A retry arriving while the first
+// request is still processed gets 503, so Alertmanager tries again later. Keys live in
+// the state store, so replicas recognize each other's retries.
+type webhookIdempotency struct {
+	store stateStore
+	ttl   time.Duration
+}
+
+// newWebhookIdempotency returns nil when idempotency keys are disabled.
+func newWebhookIdempotency(ttl time.Duration, store stateStore) *webhookIdempotency {
+	if ttl <= 0 {
+		return nil
+	}
+	return &webhookIdempotency{store: store, ttl: ttl}
+}
+
+// wrap returns a handler that short-circuits retries of requests next handled with a
+// 2xx status. The body is buffered so next can still read it.
+func (i *webhookIdempotency) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			loggerFrom(r.Context()).Warn("Error reading request body", "err", err)
+			writeDecodeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := idempotencyKey(r, body)
+		logger := loggerFrom(r.Context()).With("idempotency_key", key)
+		claimed, err := i.store.setNX(key, idempotencyPending, idempotencyPendingTTL)
+		if err != nil {
+			// As for deduplication, a duplicate post beats a lost alert.
+			logger.Warn("Error checking for a webhook retry, processing it", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !claimed {
+			state, _, _ := i.store.get(key)
+			if state == idempotencyDone {
+				webhookRetries.WithLabelValues("duplicate").Inc()
+				logger.Info("Webhook retry of a processed request, not processing it again")
+				fmt.Fprint(w, "Duplicate webhook, already processed")
+				return
+			}
+			webhookRetries.WithLabelValues("in_progress").Inc()
+			logger.Info("Webhook retry of a request still being processed")
+			w.Header().Set("Retry-After", "10")
+			writeWebhookError(w, http.StatusServiceUnavailable, "in_progress", "The same webhook is still being processed")
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= 200 && recorder.status <= 299 {
+			err = i.store.set(key, idempotencyDone, i.ttl)
+		} else {
+			// Failed requests must be retried for real.
+			err = i.store.del(key)
+		}
+		if err != nil {
+			logger.Warn("Error recording webhook result for retries", "err", err)
+		}
+	})
+}
+
+// idempotencyKey returns the state key of a request: its Idempotency-Key header, or
+// else the payload's group key and the hash of its body.
+func idempotencyKey(r *http.Request, body []byte) string {
+	if key := r.Header.Get(idempotencyKeyHeader); key != "" {
+		return "idempotency/header/" + key
+	}
+	var group struct {
+		GroupKey string `json:"groupKey"`
+	}
+	// A body that is not JSON is rejected by next; it is keyed by its hash alone.
+	json.Unmarshal(body, &group)
+	groupHash := sha256.Sum256([]byte(group.GroupKey))
+	bodyHash := sha256.Sum256(body)
+	return "idempotency/" + hex.EncodeToString(groupHash[:8]) + "/" + hex.EncodeToString(bodyHash[:])
+}
@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idempotencyTestHandler counts the requests that reach it and answers them with
+// status.
+type idempotencyTestHandler struct {
+	calls  int
+	status int
+}
+
+func (h *idempotencyTestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	w.WriteHeader(h.status)
+}
+
+func postIdempotent(handler http.Handler, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookIdempotency(t *testing.T) {
+	if newWebhookIdempotency(0, newMemoryStore()) != nil {
+		t.Error("newWebhookIdempotency(0) is enabled")
+	}
+	if newWebhookIdempotency(defaultConfig().IdempotencyTTL, newMemoryStore()) != nil {
+		t.Error("idempotency keys are enabled by default")
+	}
+
+	first := `{"groupKey":"{}:{alertname=\"GpuHighTemperature\"}","status":"firing"}`
+	second := `{"groupKey":"{}:{alertname=\"GpuHighTemperature\"}","status":"resolved"}`
+
+	t.Run("retry of a processed webhook", func(t *testing.T) {
+		next := &idempotencyTestHandler{status: http.StatusOK}
+		handler := newWebhookIdempotency(time.Minute, newMemoryStore()).wrap(next)
+
+		if rec := postIdempotent(handler, first, ""); rec.Code != http.StatusOK {
+			t.Fatalf("first request: status %d", rec.Code)
+		}
+		rec := postIdempotent(handler, first, "")
+		if rec.Code != http.StatusOK || next.calls != 1 {
+			t.Errorf("retry: status %d after %d calls, want 200 after 1", rec.Code, next.calls)
+		}
+		postIdempotent(handler, second, "")
+		if next.calls != 2 {
+			t.Errorf("a different body was not processed")
+		}
+	})
+
+	t.Run("retry of a failed webhook", func(t *testing.T) {
+		next := &idempotencyTestHandler{status: http.StatusInternalServerError}
+		handler := newWebhookIdempotency(time.Minute, newMemoryStore()).wrap(next)
+
+		postIdempotent(handler, first, "")
+		next.status = http.StatusOK
+		if rec := postIdempotent(handler, first, ""); rec.Code != http.StatusOK || next.calls != 2 {
+			t.Errorf("retry: status %d after %d calls, want 200 after 2", rec.Code, next.calls)
+		}
+	})
+
+	t.Run("retry while processing", func(t *testing.T) {
+		store := newMemoryStore()
+		next := &idempotencyTestHandler{status: http.StatusOK}
+		handler := newWebhookIdempotency(time.Minute, store).wrap(next)
+
+		req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		store.setNX(idempotencyKey(req, []byte(first)), idempotencyPending, time.Minute)
+		rec := postIdempotent(handler, first, "")
+		if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" || next.calls != 0 {
+			t.Errorf("status %d, Retry-After %q after %d calls; want 503 with Retry-After and no call", rec.Code, rec.Header().Get("Retry-After"), next.calls)
+		}
+	})
+
+	t.Run("Idempotency-Key header", func(t *testing.T) {
+		next := &idempotencyTestHandler{status: http.StatusAccepted}
+		handler := newWebhookIdempotency(time.Minute, newMemoryStore()).wrap(next)
+
+		postIdempotent(handler, first, "delivery-1")
+		postIdempotent(handler, second, "delivery-1")
+		if next.calls != 1 {
+			t.Errorf("%d calls for one Idempotency-Key, want 1", next.calls)
+		}
+		postIdempotent(handler, first, "delivery-2")
+		if next.calls != 2 {
+			t.Errorf("another Idempotency-Key was not processed")
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		store := newMemoryStore()
+		next := &idempotencyTestHandler{status: http.StatusOK}
+		handler := newWebhookIdempotency(time.Minute, store).wrap(next)
+
+		postIdempotent(handler, first, "")
+		store.mu.Lock()
+		store.expire(time.Now().Add(2 * time.Minute))
+		store.mu.Unlock()
+		postIdempotent(handler, first, "")
+		if next.calls != 2 {
+			t.Errorf("%d calls, want the webhook processed again after the TTL", next.calls)
+		}
+	})
+
+	t.Run("not a post", func(t *testing.T) {
+		next := &idempotencyTestHandler{status: http.StatusOK}
+		handler := newWebhookIdempotency(time.Minute, newMemoryStore()).wrap(next)
+		for range 2 {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/webhook", nil))
+		}
+		if next.calls != 2 {
+			t.Errorf("%d calls, want every GET passed on", next.calls)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package adapter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// inhibitSourceTTL is how long a firing source alert inhibits after it was last
+// received. Alertmanager re-sends firing alerts every repeat_interval, so a source
+// whose resolution never arrived stops inhibiting eventually.
+const inhibitSourceTTL = 24 * time.Hour
+
+// alertInhibitor suppresses alerts while a related alert fires, like Alertmanager's
+// inhibition rules but for alerts of every input: while an alert matching a rule's
+// source matchers fires, alerts matching its target matchers with the same values of
+// the equal labels are not posted, so responders see the root cause (a node down)
+// rather than the cascade it causes (its GPUs unreachable or idle). An alert never
+// inhibits itself.
+//
+// The firing sources are those received since the rules were configured; they are kept
+// across config reloads.
+type alertInhibitor struct {
+	rules []inhibitRule
+
+	mu sync.Mutex
+	// sources holds the firing alerts matching any rule's source matchers, by fingerprint.
+	sources map[string]inhibitSource
+}
+
+type inhibitRule struct {
+	source []labelMatcher
+	target []labelMatcher
+	equal  []string
+}
+
+type inhibitSource struct {
+	labels   map[string]string
+	lastSeen time.Time
+}
+
+// newAlertInhibitor returns nil when no inhibition rules are configured. The rules
+// have already been checked by Config.validate; the firing sources of previous, the
+// inhibitor of the configuration being replaced, are carried over.
+func newAlertInhibitor(configs []InhibitRuleConfig, previous *alertInhibitor) *alertInhibitor {
+	rules, _ := parseInhibitRules(configs)
+	if len(rules) == 0 {
+		return nil
+	}
+	i := &alertInhibitor{rules: rules, sources: make(map[string]inhibitSource)}
+	if previous != nil {
+		previous.mu.Lock()
+		for fp, source := range previous.sources {
+			if i.isSource(source.labels) {
+				i.sources[fp] = source
+			}
+		}
+		previous.mu.Unlock()
+	}
+	return i
+}
+
+func parseInhibitRules(configs []InhibitRuleConfig) ([]inhibitRule, error) {
+	var rules []inhibitRule
+	for i, ic := range configs {
+		if len(ic.SourceMatchers) == 0 || len(ic.TargetMatchers) == 0 {
+			return nil, fmt.Errorf("inhibitRules[%d]: sourceMatchers and targetMatchers are required", i)
+		}
+		source, err := parseMatchers(ic.SourceMatchers)
+		if err != nil {
+			return nil, fmt.Errorf("inhibitRules[%d].sourceMatchers: %w", i, err)
+		}
+		target, err := parseMatchers(ic.TargetMatchers)
+		if err != nil {
+			return nil, fmt.Errorf("inhibitRules[%d].targetMatchers: %w", i, err)
+		}
+		rules = append(rules, inhibitRule{source: source, target: target, equal: ic.Equal})
+	}
+	return rules, nil
+}
+
+func (i *alertInhibitor) isSource(labels map[string]string) bool {
+	for _, rule := range i.rules {
+		if matchAll(rule.source, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter records the firing and resolved sources among the alerts, then returns the
+// alerts to forward and those inhibited by a firing source, by the source's alert name.
+func (i *alertInhibitor) filter(alerts []Alert, now time.Time) (forward, inhibited []Alert, by []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, alert := range alerts {
+		if !i.isSource(alert.Labels) {
+			continue
+		}
+		fp := alertFingerprint(alert)
+		if alert.Status == "resolved" {
+			delete(i.sources, fp)
+		} else {
+			i.sources[fp] = inhibitSource{labels: alert.Labels, lastSeen: now}
+		}
+	}
+	for fp, source := range i.sources {
+		if now.Sub(source.lastSeen) > inhibitSourceTTL {
+			delete(i.sources, fp)
+		}
+	}
+
+	for _, alert := range alerts {
+		if source, ok := i.inhibiting(alert); ok {
+			inhibited = append(inhibited, alert)
+			by = append(by, source)
+			alertsInhibited.Inc()
+		} else {
+			forward = append(forward, alert)
+		}
+	}
+	return forward, inhibited, by
+}
+
+// inhibiting returns the alert name of a firing source that inhibits the alert. The
+// caller must hold i.mu.
+func (i *alertInhibitor) inhibiting(alert Alert) (string, bool) {
+	fp := alertFingerprint(alert)
+	for _, rule := range i.rules {
+		if !matchAll(rule.target, alert.Labels) {
+			continue
+		}
+		for sourceFP, source := range i.sources {
+			if sourceFP != fp && matchAll(rule.source, source.labels) && equalLabels(rule.equal, source.labels, alert.Labels) {
+				return source.labels["alertname"], true
+			}
+		}
+	}
+	return "", false
+}
+
+// equalLabels reports whether both label sets have the same values for the names.
+// "node" compares the nodes the alerts are about (see alertNode), so a node exporter
+// alert on port 9100 matches the GPU alerts of the same node on port 9400.
+func equalLabels(names []string, a, b map[string]string) bool {
+	for _, name := range names {
+		if name == "node" {
+			if alertNode(a) != alertNode(b) {
+				return false
+			}
+		} else if a[name] != b[name] {
+			return false
+		}
+	}
+	return true
+}
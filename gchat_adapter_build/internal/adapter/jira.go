@@ -0,0 +1,409 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// jiraIssueTTL bounds how long the issue of a firing alert is remembered, so alerts
+// that never resolve do not accumulate forever.
+const jiraIssueTTL = 30 * 24 * time.Hour
+
+// jiraPendingIssue marks an issue that is being created.
+const jiraPendingIssue = "pending"
+
+// jiraIssues remembers the Jira issue opened for each firing alert, keyed by project and
+// alert fingerprint, so repeated notifications do not open more issues and the resolved
+// notification finds the issue to close. With a shared store, replicas see each other's
+// issues.
+type jiraIssues struct {
+	store stateStore
+}
+
+func newJiraIssues(store stateStore) *jiraIssues {
+	return &jiraIssues{store: store}
+}
+
+func jiraIssueStateKey(project, fingerprint string) string {
+	return "jira/" + project + "/" + fingerprint
+}
+
+// jiraNotifier opens a Jira issue for every firing alert with a listed severity that
+// matches the matchers (by default the critical hardware alerts: XID errors, memory
+// errors and unreachable nodes), and comments on and transitions it once the alert
+// resolves. Its destinations are project keys.
+type jiraNotifier struct {
+	router     *webhookRouter
+	severities []string
+	matchers   []labelMatcher
+	issueType  string
+	labels     []string
+	transition string
+	client     *jiraClient
+	issues     *jiraIssues
+}
+
+// jiraEvent is the body of a Jira notification: the issue to open for a firing alert,
+// or the comment for a resolved one.
+type jiraEvent struct {
+	Project     string            `json:"project"`
+	Fingerprint string            `json:"fingerprint"`
+	Resolve     bool              `json:"resolve,omitempty"`
+	Fields      *jiraIssueFields  `json:"fields,omitempty"`
+	Comment     string            `json:"comment,omitempty"`
+	Alert       map[string]string `json:"alert"`
+}
+
+type jiraIssueFields struct {
+	Project     jiraKey  `json:"project"`
+	IssueType   jiraName `json:"issuetype"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels,omitempty"`
+}
+
+type jiraKey struct {
+	Key string `json:"key"`
+}
+
+type jiraName struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	outputRegistry.Register("jira", func(cfg *Config, deps outputDeps) (output, error) {
+		return newJiraNotifier(cfg.Jira, deps.issues)
+	})
+}
+
+func newJiraNotifier(cfg JiraConfig, issues *jiraIssues) (output, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no Jira URL is configured")
+	}
+	// Issues are written in Jira's markup rather than localized, so the router needs no
+	// localization and cannot fail.
+	router, _ := newWebhookRouter(cfg.webhookConfig(), nil)
+	if router.empty() {
+		return nil, fmt.Errorf("no Jira project is configured")
+	}
+	// The matchers have already been checked by Config.validate.
+	matchers, _ := parseMatchers(cfg.Matchers)
+	severities := make([]string, len(cfg.Severities))
+	for i, s := range cfg.Severities {
+		severities[i] = strings.ToLower(s)
+	}
+	return &jiraNotifier{
+		router:     router,
+		severities: severities,
+		matchers:   matchers,
+		issueType:  cfg.IssueType,
+		labels:     cfg.Labels,
+		transition: cfg.ResolveTransition,
+		client:     &jiraClient{url: strings.TrimSuffix(cfg.URL, "/"), username: cfg.Username, apiToken: cfg.APIToken, bearerToken: cfg.BearerToken},
+		issues:     issues,
+	}, nil
+}
+
+func (n *jiraNotifier) Name() string { return "jira" }
+
+// render builds an event for every selected alert and project.
+func (n *jiraNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	var events []notifier.Notification
+	for _, alert := range payload.Alerts {
+		if !n.selects(alert) {
+			continue
+		}
+		projects := n.router.routes(alert)
+		if len(projects) == 0 {
+			slog.Warn("No Jira project configured for alert, dropping it", alertAttr(alert))
+			continue
+		}
+		for _, project := range projects {
+			m, err := newNotification(n.Name(), project, 1, n.buildEvent(project, alert, payload))
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, m)
+		}
+	}
+	return events, nil
+}
+
+// selects reports whether the alert opens issues.
+func (n *jiraNotifier) selects(alert Alert) bool {
+	return slices.Contains(n.severities, strings.ToLower(alert.Labels["severity"])) && matchAll(n.matchers, alert.Labels)
+}
+
+func (n *jiraNotifier) routes(alert Alert) []string {
+	if !n.selects(alert) {
+		return nil
+	}
+	return n.router.routes(alert)
+}
+
+// renderText is not supported: notices are no reason to open an issue.
+func (n *jiraNotifier) renderText(project, text string) (notifier.Notification, error) {
+	return notifier.Notification{}, errTextUnsupported
+}
+
+func (n *jiraNotifier) buildEvent(project string, alert Alert, payload AlertmanagerPayload) jiraEvent {
+	event := jiraEvent{
+		Project:     project,
+		Fingerprint: alertFingerprint(alert),
+		Alert:       map[string]string{"alertname": alert.Labels["alertname"], "instance": alert.Labels["instance"]},
+	}
+	if status, _, _ := alertAppearance(alert, payload.Status); status == "resolved" {
+		event.Resolve = true
+		event.Comment = "The alert resolved"
+		if end := englishUTC.formatTime(alert.EndsAt); end != "" {
+			event.Comment += " at " + end
+		}
+		event.Comment += "."
+		return event
+	}
+
+	summary := alert.Labels["alertname"]
+	if instance := alert.Labels["instance"]; instance != "" {
+		summary += " on " + instance
+	}
+	if s := alert.Annotations["summary"]; s != "" {
+		summary += ": " + s
+	}
+	event.Fields = &jiraIssueFields{
+		Project:   jiraKey{Key: project},
+		IssueType: jiraName{Name: n.issueType},
+		// Jira rejects summaries longer than 255 characters.
+		Summary:     truncateRunes(strings.ReplaceAll(summary, "\n", " "), 255),
+		Description: jiraDescription(alert, payload),
+		Labels:      n.labels,
+	}
+	return event
+}
+
+// jiraDescription lists the alert's context in Jira's wiki markup: the description,
+// what the enrichers found out about the node and GPU, links, labels and annotations.
+func jiraDescription(alert Alert, payload AlertmanagerPayload) string {
+	var b strings.Builder
+	if d := alert.Annotations["description"]; d != "" {
+		b.WriteString(d + "\n\n")
+	}
+	for _, section := range []struct{ title, text string }{
+		{"Started", englishUTC.formatTime(alert.StartsAt)},
+		{"GPU", gpuDescription(alert.Labels)},
+		{"Workload", workloadDescription(alert.Labels)},
+		{"SLURM job", slurmJobDescription(alert.Labels)},
+		{"Node", alert.Annotations[nodeInfoAnnotation]},
+		{"GPU health", alert.Annotations[gpuHealthAnnotation]},
+		{"Top processes", alert.Annotations[topProcessesAnnotation]},
+		{"Throttling", alert.Annotations[throttleReasonsAnnotation]},
+		{"Chassis", alert.Annotations[chassisAnnotation]},
+	} {
+		if section.text != "" {
+			fmt.Fprintf(&b, "*%s:* %s\n", section.title, section.text)
+		}
+	}
+	for _, link := range []struct{ value, text string }{
+		{alert.Annotations["runbook_url"], "Runbook"},
+		{alert.Annotations["dashboard_url"], "Dashboard"},
+		{alert.GeneratorURL, "Source"},
+		{payload.ExternalURL, "Alertmanager"},
+	} {
+		if link.value != "" {
+			fmt.Fprintf(&b, "[%s|%s]\n", link.text, link.value)
+		}
+	}
+
+	b.WriteString("\n||Label||Value||\n")
+	for _, name := range sortedKeys(alert.Labels) {
+		fmt.Fprintf(&b, "|%s|%s|\n", name, jiraCell(alert.Labels[name]))
+	}
+	b.WriteString("\n||Annotation||Value||\n")
+	for _, name := range sortedKeys(alert.Annotations) {
+		fmt.Fprintf(&b, "|%s|%s|\n", name, jiraCell(alert.Annotations[name]))
+	}
+	return b.String()
+}
+
+// jiraCell keeps a value from breaking the table it is shown in.
+func jiraCell(s string) string {
+	if s == "" {
+		return " "
+	}
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Send opens the issue of a firing alert unless one is open already, or comments on and
+// transitions the issue of a resolved alert. An issue is claimed in the state store
+// before it is created, so replicas sending the same alert open one issue between them.
+func (n *jiraNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	var event jiraEvent
+	if err := json.Unmarshal(m.Body, &event); err != nil {
+		return fmt.Errorf("decoding Jira event: %w", err)
+	}
+	logger := loggerFrom(ctx).With("alertname", event.Alert["alertname"], "instance", event.Alert["instance"], "project", event.Project)
+	stateKey := jiraIssueStateKey(event.Project, event.Fingerprint)
+
+	if event.Resolve {
+		key, ok, err := n.issues.store.get(stateKey)
+		if err != nil {
+			return fmt.Errorf("reading Jira issue: %w", err)
+		}
+		if !ok {
+			// The alert never opened an issue, or it was resolved already.
+			return nil
+		}
+		if key == jiraPendingIssue {
+			return fmt.Errorf("the Jira issue is still being created")
+		}
+		if err := n.client.do(ctx, http.MethodPost, "/rest/api/2/issue/"+key+"/comment", map[string]string{"body": event.Comment}, nil); err != nil {
+			return fmt.Errorf("commenting on Jira issue %s: %w", key, err)
+		}
+		if n.transition != "" {
+			if err := n.client.transition(ctx, key, n.transition); err != nil {
+				return fmt.Errorf("transitioning Jira issue %s: %w", key, err)
+			}
+		}
+		if err := n.issues.store.del(stateKey); err != nil {
+			logger.Warn("Error forgetting resolved Jira issue", "issue", key, "err", err)
+		}
+		logger.Info("Resolved Jira issue", "issue", key)
+		return nil
+	}
+
+	claimed, err := n.issues.store.setNX(stateKey, jiraPendingIssue, jiraIssueTTL)
+	if err != nil {
+		return fmt.Errorf("claiming Jira issue: %w", err)
+	}
+	if !claimed {
+		// The alert has an issue already; repeated notifications do not open more.
+		return nil
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := n.client.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]any{"fields": event.Fields}, &created); err != nil {
+		if delErr := n.issues.store.del(stateKey); delErr != nil {
+			logger.Warn("Error releasing Jira issue claim", "err", delErr)
+		}
+		return fmt.Errorf("creating Jira issue: %w", err)
+	}
+	if err := n.issues.store.set(stateKey, created.Key, jiraIssueTTL); err != nil {
+		logger.Warn("Error recording Jira issue", "issue", created.Key, "err", err)
+	}
+	logger.Info("Opened Jira issue", "issue", created.Key, "url", jiraIssueURL(n.client.url, created.Key))
+	return nil
+}
+
+// jiraClient calls the Jira REST API v2, which Jira Cloud and Data Center both serve.
+type jiraClient struct {
+	url         string
+	username    string
+	apiToken    string
+	bearerToken string
+}
+
+// transition moves the issue through the transition with the given name, e.g. "Done".
+func (c *jiraClient) transition(ctx context.Context, key, name string) error {
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/rest/api/2/issue/"+key+"/transitions", nil, &available); err != nil {
+		return err
+	}
+	for _, t := range available.Transitions {
+		if strings.EqualFold(t.Name, name) {
+			return c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+key+"/transitions", map[string]any{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+	}
+	// An issue moved on by hand may have no such transition left; the comment is enough.
+	slog.Warn("Jira issue has no such transition", "issue", key, "transition", name)
+	return nil
+}
+
+// do sends a request with a JSON body, if any, encoded while it is sent, and decodes the
+// JSON answer into out, if given. Non-2xx answers are webhookStatusErrors, so they are
+// retried like posts.
+func (c *jiraClient) do(ctx context.Context, method, path string, body, out any) (err error) {
+	u := c.url + path
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(webhookHost(u)))
+	defer func() { endSpan(span, err) }()
+
+	var req *http.Request
+	if body != nil {
+		req, err = newOutboundJSONRequest(ctx, method, u, body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, u, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else {
+		req.SetBasicAuth(c.username, c.apiToken)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	setResponseStatus(ctx, resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Jira explains rejected fields in errorMessages and errors; log them, since a
+		// wrong project or issue type is only found out here.
+		var jiraErr struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&jiraErr) == nil && (len(jiraErr.ErrorMessages) > 0 || len(jiraErr.Errors) > 0) {
+			loggerFrom(ctx).Warn("Jira rejected the request", "path", path, "messages", strings.Join(jiraErr.ErrorMessages, "; "), "errors", fmt.Sprint(jiraErr.Errors))
+		}
+		return &webhookStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decoding answer: %w", err)
+		}
+	}
+	return nil
+}
+
+// jiraIssueURL returns the browse URL of an issue.
+func jiraIssueURL(site, key string) string {
+	return strings.TrimSuffix(site, "/") + "/browse/" + url.PathEscape(key)
+}
@@ -0,0 +1,252 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kubernetesInput accepts Kubernetes objects on /kubernetes and runs the configured
+// problems they report through the alert pipeline, so GPU problems reported the
+// Kubernetes way (node-problem-detector, the GPU Operator) reach the same spaces as
+// Alertmanager alerts:
+//
+//   - an Event (core/v1, as posted by kubernetes-event-exporter) with a configured
+//     reason fires an alert, or resolves it with resolves set;
+//   - a Node with a configured condition fires an alert while the condition is True
+//     and resolves it once the condition is False.
+//
+// The instance label is the node the object is about. Kubernetes objects carry many
+// fields the adapter does not use, so they are never decoded strictly.
+type kubernetesInput struct {
+	conditions map[string]KubernetesProblemConfig
+	events     map[string]KubernetesProblemConfig
+	accept     func(http.ResponseWriter, *http.Request, AlertmanagerPayload)
+	// since is when the input started: a False condition is only forwarded if it fired
+	// here or changed since, rather than on every update of a healthy node.
+	since time.Time
+
+	mu sync.Mutex
+	// firing holds the fingerprints of the condition alerts forwarded as firing.
+	firing map[string]bool
+}
+
+// kubernetesObject is the union of the Event and Node fields the adapter reads.
+type kubernetesObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+
+	// Event
+	InvolvedObject struct {
+		Kind      string `json:"kind"`
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"involvedObject"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Source  struct {
+		Host string `json:"host"`
+	} `json:"source"`
+	FirstTimestamp string `json:"firstTimestamp"`
+	LastTimestamp  string `json:"lastTimestamp"`
+	Count          int    `json:"count"`
+
+	// Node
+	Status struct {
+		Conditions []kubernetesCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+type kubernetesCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+// newKubernetesInput returns nil when no condition or event is configured. The config
+// has already been checked by Config.validate.
+func newKubernetesInput(cfg KubernetesConfig, accept func(http.ResponseWriter, *http.Request, AlertmanagerPayload)) *kubernetesInput {
+	if len(cfg.Conditions) == 0 && len(cfg.Events) == 0 {
+		return nil
+	}
+	k := &kubernetesInput{
+		conditions: make(map[string]KubernetesProblemConfig, len(cfg.Conditions)),
+		events:     make(map[string]KubernetesProblemConfig, len(cfg.Events)),
+		accept:     accept,
+		since:      time.Now(),
+		firing:     make(map[string]bool),
+	}
+	for _, c := range cfg.Conditions {
+		k.conditions[c.Name] = c
+	}
+	for _, e := range cfg.Events {
+		k.events[e.Name] = e
+	}
+	return k
+}
+
+func (k *kubernetesInput) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	var obj kubernetesObject
+	if err := decodeJSON(json.NewDecoder(r.Body), &obj, false); err != nil {
+		kubernetesObjects.WithLabelValues("malformed").Inc()
+		loggerFrom(r.Context()).Warn("Error decoding Kubernetes object", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+
+	var payload AlertmanagerPayload
+	switch {
+	case obj.Kind == "Node" || len(obj.Status.Conditions) > 0:
+		payload = k.nodePayload(obj)
+	case obj.Kind == "Event" || obj.Reason != "":
+		payload = k.eventPayload(obj)
+	default:
+		kubernetesObjects.WithLabelValues("malformed").Inc()
+		writeDecodeError(w, fmt.Errorf("expected an Event or a Node, got kind %q", obj.Kind))
+		return
+	}
+	if len(payload.Alerts) == 0 {
+		kubernetesObjects.WithLabelValues("ignored").Inc()
+		loggerFrom(r.Context()).Debug("Kubernetes object reports no configured problem", "kind", obj.Kind, "name", obj.Metadata.Name)
+		fmt.Fprint(w, "No configured problem reported")
+		return
+	}
+	kubernetesObjects.WithLabelValues("accepted").Inc()
+	k.accept(w, r, payload)
+}
+
+// eventPayload translates an event with a configured reason.
+func (k *kubernetesInput) eventPayload(obj kubernetesObject) AlertmanagerPayload {
+	problem, ok := k.events[obj.Reason]
+	if !ok {
+		return AlertmanagerPayload{}
+	}
+	node := obj.Source.Host
+	labels := map[string]string{"job": "kubernetes"}
+	if obj.InvolvedObject.Kind == "Node" {
+		node = obj.InvolvedObject.Name
+	} else if obj.InvolvedObject.Kind != "" {
+		labels[strings.ToLower(obj.InvolvedObject.Kind)] = obj.InvolvedObject.Name
+		if obj.InvolvedObject.Namespace != "" {
+			labels["namespace"] = obj.InvolvedObject.Namespace
+		}
+	}
+	labels["instance"] = node
+
+	startsAt := obj.FirstTimestamp
+	if startsAt == "" {
+		startsAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	alert := kubernetesAlert(problem, labels, obj.Message, startsAt)
+	if obj.Count > 1 {
+		alert.Annotations["count"] = fmt.Sprint(obj.Count)
+	}
+	if problem.Resolves {
+		alert.Status = "resolved"
+		alert.EndsAt = obj.LastTimestamp
+		if alert.EndsAt == "" {
+			alert.EndsAt = startsAt
+		}
+	}
+	return AlertmanagerPayload{
+		GroupKey:     "kubernetes/" + node + "/" + alert.Labels["alertname"],
+		Status:       alert.Status,
+		Receiver:     "kubernetes",
+		GroupLabels:  map[string]string{"alertname": alert.Labels["alertname"], "instance": node},
+		CommonLabels: alert.Labels,
+		Alerts:       []Alert{alert},
+	}
+}
+
+// nodePayload translates the configured conditions of a node: True fires, False
+// resolves. Unknown conditions are left alone.
+func (k *kubernetesInput) nodePayload(obj kubernetesObject) AlertmanagerPayload {
+	node := obj.Metadata.Name
+	var alerts []Alert
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, c := range obj.Status.Conditions {
+		problem, ok := k.conditions[c.Type]
+		if !ok {
+			continue
+		}
+		labels := map[string]string{"instance": node, "job": "kubernetes"}
+		alert := kubernetesAlert(problem, labels, c.Message, c.LastTransitionTime)
+		if c.Reason != "" {
+			alert.Annotations["reason"] = c.Reason
+		}
+		switch c.Status {
+		case "True":
+			k.firing[alert.Fingerprint] = true
+		case "False":
+			changed, err := time.Parse(time.RFC3339, c.LastTransitionTime)
+			if !k.firing[alert.Fingerprint] && (err != nil || changed.Before(k.since)) {
+				continue
+			}
+			delete(k.firing, alert.Fingerprint)
+			alert.Status = "resolved"
+			alert.EndsAt = c.LastTransitionTime
+		default:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	if len(alerts) == 0 {
+		return AlertmanagerPayload{}
+	}
+	return AlertmanagerPayload{
+		GroupKey:     "kubernetes/" + node,
+		Status:       combinedStatus(alerts),
+		Receiver:     "kubernetes",
+		GroupLabels:  map[string]string{"instance": node},
+		CommonLabels: map[string]string{"instance": node, "job": "kubernetes"},
+		Alerts:       alerts,
+	}
+}
+
+// kubernetesAlert builds a firing alert of the problem. Like for SNMP traps, the
+// fingerprint covers the labels before the configured labels and severity are added.
+func kubernetesAlert(problem KubernetesProblemConfig, labels map[string]string, message, startsAt string) Alert {
+	alertname := problem.Alertname
+	if alertname == "" {
+		alertname = problem.Name
+	}
+	labels["alertname"] = alertname
+	fingerprint := labelsFingerprint(labels)
+	for name, value := range problem.Labels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = value
+		}
+	}
+	if problem.Severity != "" {
+		labels["severity"] = problem.Severity
+	}
+
+	alert := Alert{
+		Labels:      labels,
+		Annotations: map[string]string{},
+		Status:      "firing",
+		StartsAt:    startsAt,
+		Fingerprint: fingerprint,
+	}
+	if message != "" {
+		alert.Annotations["description"] = message
+	}
+	if problem.Summary != "" {
+		alert.Annotations["summary"] = expandLabels(problem.Summary, labels)
+	}
+	return alert
+}
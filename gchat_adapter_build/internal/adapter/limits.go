@@ -0,0 +1,105 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxBodyBytes bounds webhook bodies unless configured otherwise. Alertmanager
+// payloads are a few KiB per alert, so this leaves room for thousands of alerts.
+const defaultMaxBodyBytes = 10 << 20
+
+// requestLimits hardens the webhook endpoints against malformed and hostile payloads:
+// bodies larger than maxBytes are cut off with 413 instead of being buffered, and
+// bodies declared as anything other than JSON are refused with 415. A request without a
+// Content-Type is accepted, as sent by hand-written curl calls and older scripts.
+type requestLimits struct {
+	maxBytes int64
+}
+
+func newRequestLimits(cfg RequestsConfig) *requestLimits {
+	return &requestLimits{maxBytes: cfg.MaxBodyBytes}
+}
+
+// wrap returns a handler that enforces the limits before calling next. It wraps the
+// signature verification, which buffers the body.
+func (l *requestLimits) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "" && !isJSONMediaType(ct) {
+			loggerFrom(r.Context()).Warn("Rejecting webhook with unsupported content type", "remote_addr", r.RemoteAddr, "content_type", ct)
+			payloadRejected.WithLabelValues("content_type").Inc()
+			writeWebhookError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", fmt.Sprintf("Content-Type must be application/json, got %q", ct))
+			return
+		}
+		if r.ContentLength > l.maxBytes {
+			loggerFrom(r.Context()).Warn("Rejecting oversized webhook", "remote_addr", r.RemoteAddr, "content_length", r.ContentLength)
+			payloadRejected.WithLabelValues("too_large").Inc()
+			writeBodyTooLarge(w, l.maxBytes)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, l.maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isJSONMediaType reports whether a Content-Type is application/json or a +json type.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// webhookError is the body of every 4xx response of the webhook endpoints, so senders
+// and their logs get a machine-readable reason.
+type webhookError struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	// Message is a human-readable description, e.g. which field failed validation.
+	Message string `json:"message"`
+}
+
+func writeWebhookError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(webhookError{Status: status, Error: code, Message: message})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, maxBytes int64) {
+	writeWebhookError(w, http.StatusRequestEntityTooLarge, "payload_too_large", fmt.Sprintf("request body exceeds %d bytes", maxBytes))
+}
+
+// writeDecodeError answers a payload that could not be read, decoded or validated.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		payloadRejected.WithLabelValues("too_large").Inc()
+		writeBodyTooLarge(w, maxBytesErr.Limit)
+		return
+	}
+	payloadDecodeErrors.Inc()
+	writeWebhookError(w, http.StatusBadRequest, "invalid_payload", "Invalid payload: "+err.Error())
+}
+
+// decodeJSON decodes exactly one JSON value from the decoder's input into v. With strict
+// set, fields v does not define are errors instead of being ignored.
+func decodeJSON(dec *json.Decoder, v any, strict bool) error {
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON payload")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLimits(t *testing.T) {
+	small := `{"status":"firing"}`
+	large := `{"status":"` + strings.Repeat("x", 100) + `"}`
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		// chunked hides the length, so only reading the body finds out it is too large.
+		chunked    bool
+		wantStatus int
+		wantError  string
+	}{
+		{"json", http.MethodPost, "application/json", small, false, http.StatusOK, ""},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", small, false, http.StatusOK, ""},
+		{"json suffix", http.MethodPost, "application/vnd.alertmanager+json", small, false, http.StatusOK, ""},
+		{"no content type", http.MethodPost, "", small, false, http.StatusOK, ""},
+		{"other content type", http.MethodPost, "text/plain", small, false, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"too large", http.MethodPost, "application/json", large, false, http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{"too large without length", http.MethodPost, "application/json", large, true, http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{"not a post", http.MethodGet, "text/plain", large, false, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRequestLimits(RequestsConfig{MaxBodyBytes: 64})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					writeDecodeError(w, err)
+				}
+			})
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(tt.method, "/webhook", body)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			l.wrap(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var got webhookError
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decoding error body: %v", err)
+			}
+			if got.Status != tt.wantStatus || got.Error != tt.wantError {
+				t.Errorf("error body = %+v, want status %d and error %q", got, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Status string `json:"status"`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		strict  bool
+		wantErr bool
+	}{
+		{"valid", `{"status":"firing"}`, true, false},
+		{"unknown field", `{"status":"firing","extra":1}`, false, false},
+		{"unknown field when strict", `{"status":"firing","extra":1}`, true, true},
+		{"trailing data", `{"status":"firing"}{}`, false, true},
+		{"malformed", `{"status":`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payload
+			err := decodeJSON(json.NewDecoder(strings.NewReader(tt.body)), &p, tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeJSON() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package adapter
+
+import (
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// linkAnnotations are the annotations shown as buttons on Google Chat cards, in order.
+var linkAnnotations = []struct {
+	annotation, label string
+}{
+	{"runbook_url", "Open Runbook"},
+	{"dashboard_url", "Open Dashboard"},
+}
+
+// linkButtons renders the runbook_url and dashboard_url annotations of an alert as card
+// buttons. Annotations come from rule files anyone with repository access can edit, so
+// only links to the allow-listed domains become buttons.
+type linkButtons struct {
+	domains []string
+}
+
+// newLinkButtons returns nil when no domain is allowed.
+func newLinkButtons(domains []string) *linkButtons {
+	if len(domains) == 0 {
+		return nil
+	}
+	l := &linkButtons{}
+	for _, domain := range domains {
+		l.domains = append(l.domains, strings.ToLower(strings.Trim(domain, ".")))
+	}
+	return l
+}
+
+// widget returns the button row of the alert's links, or false if it has none that
+// may be shown.
+func (l *linkButtons) widget(alert Alert) (CardWidget, bool) {
+	var buttons []CardButton
+	for _, link := range linkAnnotations {
+		raw := alert.Annotations[link.annotation]
+		if raw == "" {
+			continue
+		}
+		if !l.allowed(raw) {
+			slog.Debug("Not showing link outside the allowed domains", "annotation", link.annotation, "url", raw, alertAttr(alert))
+			continue
+		}
+		buttons = append(buttons, CardButton{
+			Text:    link.label,
+			OnClick: CardOnClick{OpenLink: &CardOpenLink{URL: raw}},
+		})
+	}
+	if len(buttons) == 0 {
+		return CardWidget{}, false
+	}
+	return CardWidget{ButtonList: &ButtonList{Buttons: buttons}}, true
+}
+
+// allowed reports whether raw is an http(s) URL on one of the domains or their
+// subdomains.
+func (l *linkButtons) allowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range l.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTimeFormat is how messages show startsAt and endsAt unless templates.timeFormat
+// is set.
+const defaultTimeFormat = "2006-01-02 15:04:05 MST"
+
+// localization holds the translations file and the message defaults of templates, and
+// hands out the localizer of each route. Routes without their own timezone or locale
+// use the defaults.
+type localization struct {
+	timezone     string
+	locale       string
+	timeFormat   string
+	translations map[string]map[string]string
+
+	// localizers are shared by routes with the same zone and locale, so backends can
+	// prepare one template per localizer.
+	localizers map[string]*localizer
+}
+
+// localizer renders the fixed parts of messages for one route: startsAt and endsAt in
+// its zone, and status words, severities and field names in its locale.
+type localizer struct {
+	loc        *time.Location
+	timeFormat string
+	// words maps English words to the locale's; nil leaves messages in English.
+	words map[string]string
+}
+
+// englishUTC is the localizer of backends and routes without localization.
+var englishUTC = &localizer{loc: time.UTC, timeFormat: defaultTimeFormat}
+
+// newLocalization reads the translations file of cfg, if any. cfg.Timezone has already
+// been checked by Config.validate.
+func newLocalization(cfg TemplatesConfig) (*localization, error) {
+	l := &localization{
+		timezone:   cfg.Timezone,
+		locale:     cfg.Locale,
+		timeFormat: cfg.TimeFormat,
+		localizers: make(map[string]*localizer),
+	}
+	if l.timeFormat == "" {
+		l.timeFormat = defaultTimeFormat
+	}
+	if cfg.TranslationsPath != "" {
+		content, err := os.ReadFile(cfg.TranslationsPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading translations: %w", err)
+		}
+		if err := yaml.Unmarshal(content, &l.translations); err != nil {
+			return nil, fmt.Errorf("parsing translations %s: %w", cfg.TranslationsPath, err)
+		}
+	}
+	if _, err := l.localizer("", ""); err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+	return l, nil
+}
+
+// localizer returns the localizer for a route's timezone and locale. A nil localization
+// returns englishUTC.
+func (l *localization) localizer(timezone, locale string) (*localizer, error) {
+	if l == nil {
+		return englishUTC, nil
+	}
+	if timezone == "" {
+		timezone = l.timezone
+	}
+	if locale == "" {
+		locale = l.locale
+	}
+	key := timezone + "|" + locale
+	if lz, ok := l.localizers[key]; ok {
+		return lz, nil
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+	words, ok := l.translations[locale]
+	// English needs no translations; every other locale must be in the file.
+	if !ok && locale != "" && !strings.EqualFold(locale, "en") {
+		return nil, fmt.Errorf("locale %q is not defined in templates.translationsPath", locale)
+	}
+	lz := &localizer{loc: loc, timeFormat: l.timeFormat, words: words}
+	l.localizers[key] = lz
+	return lz, nil
+}
+
+// translate returns the locale's word for s, matched exactly or else case-insensitively
+// (so "FIRING" finds "firing"), or s itself when there is none.
+func (l *localizer) translate(s string) string {
+	if s == "" || l.words == nil {
+		return s
+	}
+	if word, ok := l.words[s]; ok {
+		return word
+	}
+	for english, word := range l.words {
+		if strings.EqualFold(english, s) {
+			return word
+		}
+	}
+	return s
+}
+
+// formatTime turns an Alertmanager RFC3339 timestamp into a readable time in the
+// localizer's zone. Alertmanager uses the zero time for alerts that have not ended yet;
+// those return "".
+func (l *localizer) formatTime(value string) string {
+	if value == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	if t.IsZero() || t.Year() <= 1 {
+		return ""
+	}
+	return t.In(l.loc).Format(l.timeFormat)
+}
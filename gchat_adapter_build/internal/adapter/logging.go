@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// logLevel is shared by the handler so the level can change on config reload.
+var logLevel = new(slog.LevelVar)
+
+// requestIDHeader carries the request ID; an ID sent by the client is kept, so that a
+// proxy's IDs can be followed through the adapter's logs.
+const requestIDHeader = "X-Request-ID"
+
+type loggerKey struct{}
+
+// configureLogging installs the default structured logger. The format only takes
+// effect at startup; the level is updated on every reload.
+func configureLogging(cfg LogConfig) error {
+	if err := setLogLevel(cfg.Level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch cfg.Format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func setLogLevel(level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	logLevel.Set(l)
+	return nil
+}
+
+// fatal logs an error and exits, like log.Fatalf.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// withRequestID assigns every request an ID, returns it in the response header and
+// attaches a logger carrying it to the request context.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIDHeader, id)
+		logger := slog.Default().With("request_id", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+	})
+}
+
+// loggerFrom returns the request's logger, or the default logger outside a request.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// alertAttr groups the fields identifying an alert in log records.
+func alertAttr(alert Alert) slog.Attr {
+	return slog.Group("alert",
+		"alertname", alert.Labels["alertname"],
+		"fingerprint", alertFingerprint(alert),
+		"instance", alert.Labels["instance"],
+		"severity", alert.Labels["severity"],
+		"status", alert.Status,
+	)
+}
@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	// Maintenance window time zones must resolve in the minimal container image.
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maintenanceWindow is a recurring period during which matching alerts are held back.
+type maintenanceWindow struct {
+	name     string
+	matchers []labelMatcher
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// maintenanceScheduler holds back alerts that fire during a maintenance window. They are
+// still recorded, and once the window ends one summary per destination lists them.
+type maintenanceScheduler struct {
+	windows []maintenanceWindow
+
+	mu   sync.Mutex
+	held map[heldKey]map[string]Alert
+}
+
+// heldKey identifies one occurrence of a window.
+type heldKey struct {
+	window string
+	end    time.Time
+}
+
+// parseMaintenanceWindows validates and compiles the configured windows.
+func parseMaintenanceWindows(configs []MaintenanceWindowConfig) ([]maintenanceWindow, error) {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	windows := make([]maintenanceWindow, 0, len(configs))
+	for i, wc := range configs {
+		name := wc.Name
+		if name == "" {
+			name = fmt.Sprintf("maintenance[%d]", i)
+		}
+		matchers, err := parseMatchers(wc.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %s: %w", name, err)
+		}
+		timezone := wc.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: %w", name, err)
+		}
+		schedule, err := parser.Parse("CRON_TZ=" + timezone + " " + wc.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %s: invalid schedule %q: %w", name, wc.Schedule, err)
+		}
+		if wc.Duration <= 0 {
+			return nil, fmt.Errorf("maintenance window %s: duration must be positive", name)
+		}
+		windows = append(windows, maintenanceWindow{name: name, matchers: matchers, schedule: schedule, duration: wc.Duration})
+	}
+	return windows, nil
+}
+
+// newMaintenanceScheduler returns nil when no windows are configured.
+func newMaintenanceScheduler(configs []MaintenanceWindowConfig) (*maintenanceScheduler, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	windows, err := parseMaintenanceWindows(configs)
+	if err != nil {
+		return nil, err
+	}
+	return &maintenanceScheduler{windows: windows, held: make(map[heldKey]map[string]Alert)}, nil
+}
+
+// activeEnd returns the end of the window occurrence covering t, if any. A window whose
+// schedule fires at start is active on (start-1s, start+duration); if occurrences
+// overlap, the latest end wins.
+func (w maintenanceWindow) activeEnd(t time.Time) (time.Time, bool) {
+	var end time.Time
+	for start := w.schedule.Next(t.Add(-w.duration)); !start.After(t); start = w.schedule.Next(start) {
+		end = start.Add(w.duration)
+	}
+	return end, !end.IsZero()
+}
+
+// hold removes the alerts that fall into an active window from the list and keeps
+// their latest state for the window's summary. The held alerts are returned as well.
+func (s *maintenanceScheduler) hold(alerts []Alert) (forward, held []Alert) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, alert := range alerts {
+		key, ok := s.window(alert, now)
+		if !ok {
+			forward = append(forward, alert)
+			continue
+		}
+		if s.held[key] == nil {
+			s.held[key] = make(map[string]Alert)
+		}
+		s.held[key][alertFingerprint(alert)] = alert
+		held = append(held, alert)
+	}
+	return forward, held
+}
+
+func (s *maintenanceScheduler) window(alert Alert, now time.Time) (heldKey, bool) {
+	for _, w := range s.windows {
+		if !matchAll(w.matchers, alert.Labels) {
+			continue
+		}
+		if end, ok := w.activeEnd(now); ok {
+			return heldKey{window: w.name, end: end}, true
+		}
+	}
+	return heldKey{}, false
+}
+
+// run reports the alerts of every window that has ended, checking every interval.
+// summarize receives the window name and its held alerts.
+func (s *maintenanceScheduler) run(interval time.Duration, summarize func(window string, alerts []Alert)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for key, alerts := range s.takeEnded(time.Now()) {
+			summarize(key.window, alerts)
+		}
+	}
+}
+
+func (s *maintenanceScheduler) takeEnded(now time.Time) map[heldKey][]Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ended := make(map[heldKey][]Alert)
+	for key, byFingerprint := range s.held {
+		if key.end.After(now) {
+			continue
+		}
+		alerts := make([]Alert, 0, len(byFingerprint))
+		for _, alert := range byFingerprint {
+			alerts = append(alerts, alert)
+		}
+		sort.Slice(alerts, func(i, j int) bool { return alerts[i].StartsAt < alerts[j].StartsAt })
+		ended[key] = alerts
+		delete(s.held, key)
+	}
+	return ended
+}
+
+// maintenanceSummary renders the notice posted after a window ends.
+func maintenanceSummary(window string, alerts []Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔧 Maintenance window %s ended; %d alert(s) were held back:", window, len(alerts))
+	for _, alert := range alerts {
+		fmt.Fprintf(&b, "\n• %s on %s (%s)", alert.Labels["alertname"], alert.Labels["instance"], alert.Status)
+	}
+	return b.String()
+}
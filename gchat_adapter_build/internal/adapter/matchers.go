@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// labelMatcher is a Prometheus style label matcher: name=value, name!=value,
+// name=~regex or name!~regex. Regular expressions are anchored like in Prometheus.
+type labelMatcher struct {
+	name   string
+	value  string
+	re     *regexp.Regexp
+	negate bool
+}
+
+// parseMatcher parses a single matcher such as `instance=~"gpu-node-0[1-4].*"`.
+// Quotes around the value are optional.
+func parseMatcher(s string) (labelMatcher, error) {
+	for _, op := range []string{"!~", "=~", "!=", "="} {
+		i := strings.Index(s, op)
+		if i <= 0 {
+			continue
+		}
+		m := labelMatcher{
+			name:   strings.TrimSpace(s[:i]),
+			value:  strings.Trim(strings.TrimSpace(s[i+len(op):]), `"`),
+			negate: op[0] == '!',
+		}
+		if strings.HasSuffix(op, "~") {
+			re, err := regexp.Compile("^(?:" + m.value + ")$")
+			if err != nil {
+				return labelMatcher{}, fmt.Errorf("matcher %q: %w", s, err)
+			}
+			m.re = re
+		}
+		return m, nil
+	}
+	return labelMatcher{}, fmt.Errorf("matcher %q: expected name=value, name!=value, name=~regex or name!~regex", s)
+}
+
+// parseMatchers parses a list of matchers that must all match.
+func parseMatchers(specs []string) ([]labelMatcher, error) {
+	matchers := make([]labelMatcher, 0, len(specs))
+	for _, spec := range specs {
+		m, err := parseMatcher(spec)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func (m labelMatcher) matches(labels map[string]string) bool {
+	value := labels[m.name]
+	var ok bool
+	if m.re != nil {
+		ok = m.re.MatchString(value)
+	} else {
+		ok = value == m.value
+	}
+	return ok != m.negate
+}
+
+// matchAll reports whether every matcher matches the labels.
+func matchAll(matchers []labelMatcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
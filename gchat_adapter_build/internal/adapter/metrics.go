@@ -0,0 +1,136 @@
+package adapter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Self-metrics of the adapter, exposed on /metrics so the alerting pipeline itself can be alerted on.
+var (
+	alertsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_alerts_received_total",
+		Help: "Alerts received in webhook payloads.",
+	})
+	payloadDecodeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_payload_decode_errors_total",
+		Help: "Webhook requests rejected because their payload could not be decoded or failed schema validation.",
+	})
+	payloadRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_payloads_rejected_total",
+		Help: "Webhook requests rejected for their body size or content type, by reason (too_large, content_type).",
+	}, []string{"reason"})
+	snmpTraps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_snmp_traps_total",
+		Help: "SNMP traps received, by result (accepted, unknown_oid, unauthenticated, malformed).",
+	}, []string{"result"})
+	kubernetesObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_kubernetes_objects_total",
+		Help: "Kubernetes Events and Nodes received on /kubernetes, by result (accepted, ignored, malformed).",
+	}, []string{"result"})
+	legacyNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_legacy_notifications_total",
+		Help: "Zabbix and Nagios notifications received on /zabbix and /nagios, by source and result (accepted, ignored, malformed).",
+	}, []string{"source", "result"})
+	fleetVersionNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_fleet_version_nodes",
+		Help: "Nodes of the version drift check running a version, by component (driver, cuda) and version.",
+	}, []string{"component", "version"})
+	fleetDriftNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_fleet_drift_nodes",
+		Help: "Nodes whose version differs from the fleet baseline, by component (driver, cuda).",
+	}, []string{"component"})
+	heartbeatsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_heartbeats_received_total",
+		Help: "Node heartbeats received on /api/heartbeat.",
+	})
+	heartbeatNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_heartbeat_nodes",
+		Help: "Nodes watched by the dead man's switch, by state (reachable, unreachable).",
+	}, []string{"state"})
+	stormActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_storm_active",
+		Help: "1 while the alert storm breaker holds back alerts, 0 otherwise.",
+	})
+	deliveryQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_delivery_queue_length",
+		Help: "Payloads waiting for a delivery worker.",
+	})
+	deliveryQueueCapacity = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_delivery_queue_capacity",
+		Help: "Payloads the delivery queue holds before webhooks are rejected with 503.",
+	})
+	deliveryWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_delivery_workers_busy",
+		Help: "Delivery workers dispatching a payload.",
+	})
+	deliveryRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_delivery_rejected_total",
+		Help: "Webhook payloads rejected with 503 because the delivery queue was full.",
+	})
+	deliveryQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "alertmanager_adapter_delivery_queue_wait_seconds",
+		Help:    "Time payloads waited in the delivery queue for a worker.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+	messagesForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_messages_forwarded_total",
+		Help: "Messages successfully delivered, by backend.",
+	}, []string{"backend"})
+	forwardFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_forward_failures_total",
+		Help: "Messages that could not be delivered after all retries, by backend.",
+	}, []string{"backend"})
+	deadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_dead_letters_total",
+		Help: "Messages kept in the dead letter store after they failed for good, by backend.",
+	}, []string{"backend"})
+	duplicatesSuppressed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_duplicates_suppressed_total",
+		Help: "Alerts dropped because they were already forwarded with the same status within the dedup TTL.",
+	})
+	alertsFiltered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_alerts_filtered_total",
+		Help: "Alerts dropped or downgraded by the inbound filter rules, by action.",
+	}, []string{"action"})
+	webhookRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_webhook_retries_total",
+		Help: "Webhook retries answered without processing them again, by result (duplicate, in_progress).",
+	}, []string{"result"})
+	alertsInhibited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_alerts_inhibited_total",
+		Help: "Alerts not posted because a firing alert inhibited them.",
+	})
+	alertsEscalated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_escalations_total",
+		Help: "Escalation steps run for unacknowledged alerts, by escalation policy.",
+	}, []string{"policy"})
+	alertsRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_alerts_rate_limited_total",
+		Help: "Alerts in messages suppressed by the outbound rate limit (reported later in a summary), by backend.",
+	}, []string{"backend"})
+	forwardLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "alertmanager_adapter_forward_latency_seconds",
+		Help:    "Time from rendering a message to its successful delivery, including queueing and retries, by backend.",
+		Buckets: forwardLatencyBuckets,
+	}, []string{"backend"})
+	deliverySuccessRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_delivery_success_ratio",
+		Help: "Share of the messages delivered after retries over a rolling window, by backend and destination.",
+	}, []string{"backend", "destination", "window"})
+	deliveryLatencyP95 = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_delivery_latency_p95_seconds",
+		Help: "95th percentile of the time from rendering a message to its delivery over a rolling window, by backend and destination.",
+	}, []string{"backend", "destination", "window"})
+	aggregatorReports = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alertmanager_adapter_aggregator_reports_total",
+		Help: "Node reports received by the aggregator from node agents.",
+	})
+	aggregatorStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alertmanager_adapter_aggregator_streams",
+		Help: "Node agent streams currently open to the aggregator.",
+	})
+)
+
+// forwardLatencyBuckets are the latency buckets of alertmanager_adapter_forward_latency_seconds
+// and of the delivery SLO windows.
+var forwardLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertMutes records the alerts acknowledged or silenced through the card buttons in the
+// state store, by fingerprint, until the silence ends or the alert resolves. Alertmanager
+// stops notifying silenced alerts itself, but notifications already on their way,
+// pending escalations and the other replicas would not know; with a shared store every
+// replica drops them, and the state survives restarts. When Alertmanager cannot be
+// reached, the mute alone silences the alert in the adapter.
+type alertMutes struct {
+	store stateStore
+}
+
+// alertMute is the state stored for a muted alert.
+type alertMute struct {
+	// action is the button that muted the alert, "ack" or "silence".
+	action string
+	until  time.Time
+}
+
+func newAlertMutes(store stateStore) *alertMutes {
+	return &alertMutes{store: store}
+}
+
+func muteKey(fingerprint string) string {
+	return "mute/" + fingerprint
+}
+
+// mute stores the mute of an alert until the given time.
+func (m *alertMutes) mute(fingerprint, action string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return m.store.set(muteKey(fingerprint), fmt.Sprintf("%s %d", action, until.Unix()), ttl)
+}
+
+// muted returns the mute of an alert, if any. If the store cannot be reached the alert
+// counts as not muted: a notification too many beats a lost one.
+func (m *alertMutes) muted(fingerprint string) (alertMute, bool) {
+	value, ok, err := m.store.get(muteKey(fingerprint))
+	if err != nil {
+		slog.Warn("Error reading alert mute, treating the alert as not muted", "fingerprint", fingerprint, "err", err)
+		return alertMute{}, false
+	}
+	if !ok {
+		return alertMute{}, false
+	}
+	action, until, _ := strings.Cut(value, " ")
+	unix, _ := strconv.ParseInt(until, 10, 64)
+	return alertMute{action: action, until: time.Unix(unix, 0)}, true
+}
+
+// filter returns the alerts to forward and the muted ones. Resolved alerts are always
+// forwarded and end their alert's mute, so a new occurrence notifies again.
+func (m *alertMutes) filter(alerts []Alert) (forward, muted []Alert) {
+	for _, alert := range alerts {
+		fp := alertFingerprint(alert)
+		if alert.Status == "resolved" {
+			if err := m.store.del(muteKey(fp)); err != nil {
+				slog.Warn("Error removing alert mute", alertAttr(alert), "err", err)
+			}
+			forward = append(forward, alert)
+			continue
+		}
+		if _, ok := m.muted(fp); ok {
+			muted = append(muted, alert)
+			continue
+		}
+		forward = append(forward, alert)
+	}
+	return forward, muted
+}
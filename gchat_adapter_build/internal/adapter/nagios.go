@@ -0,0 +1,160 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNagiosSeverities maps Nagios and Icinga host and service states onto severity
+// label values.
+var defaultNagiosSeverities = map[string]string{
+	"down":        "critical",
+	"unreachable": "critical",
+	"critical":    "critical",
+	"warning":     "warning",
+	"unknown":     "warning",
+}
+
+// nagiosInput accepts notifications posted by a Nagios or Icinga notification command
+// on /nagios and runs them through the alert pipeline. PROBLEM notifications fire an
+// alert about the host or service, and RECOVERY (or an OK or UP state) resolves it;
+// acknowledgements, flapping, downtime and custom notifications are ignored.
+type nagiosInput struct {
+	severities map[string]string
+	labels     map[string]string
+	accept     func(http.ResponseWriter, *http.Request, AlertmanagerPayload)
+}
+
+// nagiosNotification is the JSON the notification command posts, with the values of
+// the macros named in config.example.yml. Host notifications have no service.
+type nagiosNotification struct {
+	NotificationType string `json:"notification_type"`
+	HostName         string `json:"host_name"`
+	HostAddress      string `json:"host_address"`
+	HostState        string `json:"host_state"`
+	Service          string `json:"service_description"`
+	ServiceState     string `json:"service_state"`
+	Output           string `json:"output"`
+	LongOutput       string `json:"long_output"`
+	// Timestamp is the Unix time of the notification ($TIMET$, $icinga.timet$).
+	Timestamp string `json:"timestamp"`
+	// URL links to the host or service in the Nagios or Icinga web interface.
+	URL string `json:"url"`
+}
+
+// newNagiosInput returns nil when the input is disabled.
+func newNagiosInput(cfg NagiosConfig, accept func(http.ResponseWriter, *http.Request, AlertmanagerPayload)) *nagiosInput {
+	if !cfg.Enabled {
+		return nil
+	}
+	severities := make(map[string]string, len(defaultNagiosSeverities)+len(cfg.Severities))
+	for state, severity := range defaultNagiosSeverities {
+		severities[state] = severity
+	}
+	for state, severity := range cfg.Severities {
+		severities[strings.ToLower(state)] = severity
+	}
+	return &nagiosInput{severities: severities, labels: cfg.Labels, accept: accept}
+}
+
+func (n *nagiosInput) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	var notification nagiosNotification
+	if err := decodeJSON(json.NewDecoder(r.Body), &notification, false); err != nil {
+		legacyNotifications.WithLabelValues("nagios", "malformed").Inc()
+		loggerFrom(r.Context()).Warn("Error decoding Nagios notification", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+	if notification.HostName == "" {
+		legacyNotifications.WithLabelValues("nagios", "malformed").Inc()
+		writeDecodeError(w, fmt.Errorf("host_name is required"))
+		return
+	}
+	// Icinga 2 spells the types in mixed case, e.g. "Problem".
+	switch strings.ToUpper(notification.NotificationType) {
+	case "PROBLEM", "RECOVERY":
+	default:
+		legacyNotifications.WithLabelValues("nagios", "ignored").Inc()
+		loggerFrom(r.Context()).Debug("Ignoring Nagios notification", "type", notification.NotificationType, "host", notification.HostName)
+		fmt.Fprintf(w, "%s notifications are not forwarded", notification.NotificationType)
+		return
+	}
+	legacyNotifications.WithLabelValues("nagios", "accepted").Inc()
+	n.accept(w, r, n.payload(notification))
+}
+
+// payload translates the notification. The alert is keyed by host and service, so the
+// recovery resolves the alert its problem fired.
+func (n *nagiosInput) payload(notification nagiosNotification) AlertmanagerPayload {
+	host := notification.HostName
+	alertname, state := "HostDown", notification.HostState
+	if notification.Service != "" {
+		alertname, state = notification.Service, notification.ServiceState
+	}
+	labels := map[string]string{"alertname": alertname, "instance": host, "job": "nagios"}
+	if notification.Service != "" {
+		labels["service"] = notification.Service
+	}
+	fingerprint := labelsFingerprint(labels)
+	if severity, ok := n.severities[strings.ToLower(state)]; ok {
+		labels["severity"] = severity
+	}
+	for label, value := range n.labels {
+		if _, ok := labels[label]; !ok {
+			labels[label] = value
+		}
+	}
+
+	startsAt := time.Now()
+	if seconds, err := strconv.ParseInt(notification.Timestamp, 10, 64); err == nil {
+		startsAt = time.Unix(seconds, 0)
+	}
+	summary := fmt.Sprintf("%s is %s", host, state)
+	if notification.Service != "" {
+		summary = fmt.Sprintf("%s on %s is %s", notification.Service, host, state)
+	}
+	alert := Alert{
+		Labels:       labels,
+		Annotations:  map[string]string{"summary": summary},
+		Status:       "firing",
+		StartsAt:     startsAt.UTC().Format(time.RFC3339),
+		GeneratorURL: notification.URL,
+		Fingerprint:  fingerprint,
+	}
+	for annotation, value := range map[string]string{
+		"description":  strings.TrimSpace(notification.Output + "\n" + notification.LongOutput),
+		"nagios_state": state,
+		"host_address": notification.HostAddress,
+	} {
+		if value != "" {
+			alert.Annotations[annotation] = value
+		}
+	}
+	switch strings.ToUpper(state) {
+	case "OK", "UP":
+		alert.Status = "resolved"
+	}
+	if strings.EqualFold(notification.NotificationType, "RECOVERY") {
+		alert.Status = "resolved"
+	}
+	if alert.Status == "resolved" {
+		alert.EndsAt = alert.StartsAt
+	}
+	return AlertmanagerPayload{
+		GroupKey:     "nagios/" + host + "/" + alertname,
+		Status:       alert.Status,
+		Receiver:     "nagios",
+		GroupLabels:  map[string]string{"alertname": alertname, "instance": host},
+		CommonLabels: labels,
+		Alerts:       []Alert{alert},
+	}
+}
@@ -0,0 +1,303 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nodeInfoAnnotation is the annotation the node metadata enricher adds with a one-line
+// summary such as "Lab 2, rack B3 · owner ml-platform · DGX A100"; the rich message
+// formats display it as "Node".
+const nodeInfoAnnotation = "node_info"
+
+// nodeMetadataFields are the facts looked up for a node, in display order. Each is also
+// added as its own node_<field> annotation for custom templates.
+var nodeMetadataFields = []string{"location", "owner", "model"}
+
+// Where the service account credentials are mounted in a Kubernetes pod.
+const (
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// nodeMetadataEnricher annotates alerts with the rack location, owner team and hardware
+// model of the alerting node, looked up in a static table, an HTTP inventory API or the
+// Kubernetes node labels. Lookups are cached, including nodes the source does not know.
+type nodeMetadataEnricher struct {
+	lookup func(node string) (map[string]string, error)
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedNodeMetadata
+}
+
+type cachedNodeMetadata struct {
+	metadata  map[string]string
+	fetchedAt time.Time
+}
+
+// newNodeMetadataEnricher returns nil when no source is configured. The source has
+// already been checked by Config.validate, except for files, which are read here.
+func newNodeMetadataEnricher(cfg NodeMetadataConfig) (*nodeMetadataEnricher, error) {
+	e := &nodeMetadataEnricher{ttl: cfg.CacheTTL, cache: make(map[string]cachedNodeMetadata)}
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch {
+	case cfg.Nodes != nil || cfg.File != "":
+		nodes := cfg.Nodes
+		if cfg.File != "" {
+			var err error
+			if nodes, err = loadNodeMetadataFile(cfg.File); err != nil {
+				return nil, err
+			}
+		}
+		e.lookup = func(node string) (map[string]string, error) {
+			return nodes[node], nil
+		}
+	case cfg.URL != "":
+		fields := metadataFieldNames(cfg.Fields, map[string]string{})
+		e.lookup = func(node string) (map[string]string, error) {
+			return fetchNodeMetadata(client, strings.ReplaceAll(cfg.URL, "{host}", url.PathEscape(node)), fields)
+		}
+	case cfg.Kubernetes:
+		k8s, err := newKubernetesNodeClient(cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		fields := metadataFieldNames(cfg.Fields, map[string]string{
+			"location": "topology.kubernetes.io/zone",
+			"owner":    "team",
+			"model":    "nvidia.com/gpu.product",
+		})
+		e.lookup = func(node string) (map[string]string, error) {
+			return k8s.nodeMetadata(node, fields)
+		}
+	default:
+		return nil, nil
+	}
+	return e, nil
+}
+
+// enrich adds the node annotations to every alert whose node the source knows. Lookup
+// failures are logged and leave the alert unchanged, and are not cached.
+func (e *nodeMetadataEnricher) enrich(payload AlertmanagerPayload) AlertmanagerPayload {
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alerts[i] = alert
+
+		node := alertNode(alert.Labels)
+		if node == "" {
+			continue
+		}
+		metadata, err := e.metadata(node)
+		if err != nil {
+			slog.Warn("Error looking up node metadata", "node", node, "err", err)
+			continue
+		}
+		if annotations := nodeMetadataAnnotations(metadata); len(annotations) > 0 {
+			alerts[i] = withAnnotations(alert, annotations)
+		}
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (e *nodeMetadataEnricher) metadata(node string) (map[string]string, error) {
+	e.mu.Lock()
+	cached, ok := e.cache[node]
+	e.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < e.ttl {
+		return cached.metadata, nil
+	}
+
+	metadata, err := e.lookup(node)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.cache[node] = cachedNodeMetadata{metadata: metadata, fetchedAt: time.Now()}
+	e.mu.Unlock()
+	return metadata, nil
+}
+
+// alertNode returns the node an alert is about: its node label (set by Kubernetes
+// scrape configs) or else its instance label without the port.
+func alertNode(labels map[string]string) string {
+	if node := labels["node"]; node != "" {
+		return node
+	}
+	instance := labels["instance"]
+	if host, _, err := net.SplitHostPort(instance); err == nil {
+		return host
+	}
+	return instance
+}
+
+func nodeMetadataAnnotations(metadata map[string]string) map[string]string {
+	annotations := make(map[string]string)
+	var parts []string
+	for _, field := range nodeMetadataFields {
+		value := metadata[field]
+		if value == "" {
+			continue
+		}
+		annotations["node_"+field] = value
+		if field == "owner" {
+			value = "owner " + value
+		}
+		parts = append(parts, value)
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	annotations[nodeInfoAnnotation] = strings.Join(parts, " · ")
+	return annotations
+}
+
+// metadataFieldNames maps every metadata field to the key it has in the source:
+// the configured one, else the source's default, else the field name itself.
+func metadataFieldNames(configured, defaults map[string]string) map[string]string {
+	names := make(map[string]string, len(nodeMetadataFields))
+	for _, field := range nodeMetadataFields {
+		switch {
+		case configured[field] != "":
+			names[field] = configured[field]
+		case defaults[field] != "":
+			names[field] = defaults[field]
+		default:
+			names[field] = field
+		}
+	}
+	return names
+}
+
+// pickMetadata copies the metadata fields out of a source's key/value map.
+func pickMetadata(values map[string]string, fields map[string]string) map[string]string {
+	metadata := make(map[string]string, len(fields))
+	for field, key := range fields {
+		if v := values[key]; v != "" {
+			metadata[field] = v
+		}
+	}
+	return metadata
+}
+
+// loadNodeMetadataFile reads a YAML map of node name to metadata fields.
+func loadNodeMetadataFile(path string) (map[string]map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading node metadata: %w", err)
+	}
+	var nodes map[string]map[string]string
+	if err := yaml.Unmarshal(content, &nodes); err != nil {
+		return nil, fmt.Errorf("parsing node metadata %s: %w", path, err)
+	}
+	return nodes, nil
+}
+
+// fetchNodeMetadata reads a JSON object describing one node. A 404 means the
+// inventory does not know the node.
+func fetchNodeMetadata(client *http.Client, url string, fields map[string]string) (map[string]string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	var values map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&values); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", url, err)
+	}
+	strs := make(map[string]string, len(values))
+	for k, v := range values {
+		switch v := v.(type) {
+		case string:
+			strs[k] = v
+		case float64, bool:
+			strs[k] = fmt.Sprint(v)
+		}
+	}
+	return pickMetadata(strs, fields), nil
+}
+
+// kubernetesNodeClient reads node labels from the API server with the pod's service
+// account, which needs permission to get nodes.
+type kubernetesNodeClient struct {
+	apiServer string
+	token     string
+	client    *http.Client
+}
+
+func newKubernetesNodeClient(timeout time.Duration) (*kubernetesNodeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("nodeMetadata.kubernetes requires running in a Kubernetes pod")
+	}
+	token, err := os.ReadFile(serviceAccountToken)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s", serviceAccountCA)
+	}
+	return &kubernetesNodeClient{
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+func (k *kubernetesNodeClient) nodeMetadata(node string, fields map[string]string) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, k.apiServer+"/api/v1/nodes/"+url.PathEscape(node), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+k.token)
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting node %s: API server returned status %d", node, resp.StatusCode)
+	}
+
+	var body struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding node %s: %w", node, err)
+	}
+	return pickMetadata(body.Metadata.Labels, fields), nil
+}
@@ -0,0 +1,291 @@
+package adapter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onCallSchedule looks up who is on call, in a static rotation, an iCal calendar
+// (e.g. a Google Calendar's secret address) or the PagerDuty or Opsgenie API, so that
+// Google Chat messages about critical alerts can mention that person. The answer is
+// cached; when a refresh fails the previous one keeps being used.
+type onCallSchedule struct {
+	lookup     func(now time.Time) (string, error)
+	users      map[string]string
+	severities []string
+	ttl        time.Duration
+
+	mu        sync.Mutex
+	current   string
+	fetchedAt time.Time
+}
+
+// newOnCallSchedule returns nil when no schedule source is configured. The source has
+// already been checked by Config.validate.
+func newOnCallSchedule(cfg OnCallConfig) *onCallSchedule {
+	s := &onCallSchedule{users: cfg.Users, severities: cfg.Severities, ttl: cfg.CacheTTL}
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch {
+	case len(cfg.Rotation.Users) > 0:
+		rotation := cfg.Rotation
+		s.lookup = func(now time.Time) (string, error) {
+			return rotation.onCall(now), nil
+		}
+	case cfg.CalendarURL != "":
+		s.lookup = func(now time.Time) (string, error) {
+			return fetchCalendarOnCall(client, cfg.CalendarURL, now)
+		}
+	case cfg.PagerDuty.ScheduleID != "":
+		s.lookup = func(time.Time) (string, error) {
+			return fetchPagerDutyOnCall(client, cfg.PagerDuty)
+		}
+	case cfg.Opsgenie.Schedule != "":
+		s.lookup = func(time.Time) (string, error) {
+			return fetchOpsgenieOnCall(client, cfg.Opsgenie)
+		}
+	default:
+		return nil
+	}
+	return s
+}
+
+// mention returns the Google Chat mention of the current on-call user for a message
+// with these alerts, or "" if none of them is firing with one of the severities.
+func (s *onCallSchedule) mention(alerts []Alert) string {
+	if !slices.ContainsFunc(alerts, func(a Alert) bool {
+		return a.Status != "resolved" && slices.Contains(s.severities, a.Labels["severity"])
+	}) {
+		return ""
+	}
+	person := s.onCall()
+	if person == "" {
+		return ""
+	}
+	if user := s.users[person]; user != "" {
+		return "<" + user + ">"
+	}
+	if strings.HasPrefix(person, "users/") {
+		return "<" + person + ">"
+	}
+	// Without a Google Chat user ID the name is shown, but nobody is notified.
+	return "@" + person
+}
+
+func (s *onCallSchedule) onCall() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetchedAt.IsZero() && time.Since(s.fetchedAt) < s.ttl {
+		return s.current
+	}
+	person, err := s.lookup(time.Now())
+	if err != nil {
+		slog.Warn("Error looking up the on-call user, using the previous one", "previous", s.current, "err", err)
+		return s.current
+	}
+	s.current, s.fetchedAt = person, time.Now()
+	return person
+}
+
+// onCall returns the user whose shift covers now. Shifts follow each other from Start,
+// in the order of Users, and wrap around.
+func (r OnCallRotationConfig) onCall(now time.Time) string {
+	elapsed := now.Sub(r.Start)
+	shifts := int64(elapsed / r.Shift)
+	if elapsed%r.Shift < 0 {
+		shifts--
+	}
+	n := int64(len(r.Users))
+	return r.Users[((shifts%n)+n)%n]
+}
+
+// fetchCalendarOnCall reads an iCalendar feed and returns the summary (title) of the
+// event covering now. Recurring events are not expanded, so each shift has to be an
+// event of its own.
+func fetchCalendarOnCall(client *http.Client, calendarURL string, now time.Time) (string, error) {
+	resp, err := client.Get(calendarURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("calendar returned status %d", resp.StatusCode)
+	}
+	events, err := parseICalEvents(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return "", fmt.Errorf("parsing calendar: %w", err)
+	}
+
+	// Of overlapping events, the one that started last wins, so a cover shift can be
+	// added on top of the regular one.
+	var current *icalEvent
+	for i, e := range events {
+		if !now.Before(e.start) && now.Before(e.end) && (current == nil || e.start.After(current.start)) {
+			current = &events[i]
+		}
+	}
+	if current == nil {
+		return "", nil
+	}
+	return current.summary, nil
+}
+
+type icalEvent struct {
+	summary    string
+	start, end time.Time
+}
+
+// parseICalEvents reads the start, end and summary of every VEVENT. Events without a
+// usable start are skipped; an event without an end lasts one day.
+func parseICalEvents(r io.Reader) ([]icalEvent, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Long lines are folded: continuation lines start with a space or a tab.
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var events []icalEvent
+	var event *icalEvent
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &icalEvent{}
+		case name == "END" && value == "VEVENT" && event != nil:
+			if !event.start.IsZero() {
+				if event.end.IsZero() {
+					event.end = event.start.Add(24 * time.Hour)
+				}
+				events = append(events, *event)
+			}
+			event = nil
+		case event == nil:
+		case name == "SUMMARY":
+			event.summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case name == "DTSTART":
+			event.start, _ = parseICalTime(value, params)
+		case name == "DTEND":
+			event.end, _ = parseICalTime(value, params)
+		}
+	}
+	return events, nil
+}
+
+// parseICalTime parses a DATE or DATE-TIME value; params may name its TZID.
+func parseICalTime(value, params string) (time.Time, error) {
+	location := time.UTC
+	for _, param := range strings.Split(params, ";") {
+		if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+			if loc, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				location = loc
+			}
+		}
+	}
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	case len(value) == len("20060102"):
+		return time.ParseInLocation("20060102", value, location)
+	default:
+		return time.ParseInLocation("20060102T150405", value, location)
+	}
+}
+
+// fetchPagerDutyOnCall returns the email (or else the name) of the user on call in the
+// schedule at the lowest escalation level.
+func fetchPagerDutyOnCall(client *http.Client, cfg OnCallPagerDutyConfig) (string, error) {
+	q := url.Values{}
+	q.Set("schedule_ids[]", cfg.ScheduleID)
+	q.Set("earliest", "true")
+	q.Set("include[]", "users")
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(cfg.APIURL, "/")+"/oncalls?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Token token="+cfg.APIToken)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+
+	var body struct {
+		OnCalls []struct {
+			EscalationLevel int `json:"escalation_level"`
+			User            struct {
+				Email   string `json:"email"`
+				Summary string `json:"summary"`
+			} `json:"user"`
+		} `json:"oncalls"`
+	}
+	if err := getJSON(client, req, &body); err != nil {
+		return "", fmt.Errorf("PagerDuty on-calls: %w", err)
+	}
+	person, level := "", 0
+	for _, oncall := range body.OnCalls {
+		name := oncall.User.Email
+		if name == "" {
+			name = oncall.User.Summary
+		}
+		if name != "" && (person == "" || oncall.EscalationLevel < level) {
+			person, level = name, oncall.EscalationLevel
+		}
+	}
+	return person, nil
+}
+
+// fetchOpsgenieOnCall returns the first on-call recipient of the schedule, usually the
+// user's email.
+func fetchOpsgenieOnCall(client *http.Client, cfg OnCallOpsgenieConfig) (string, error) {
+	endpoint := fmt.Sprintf("%s/v2/schedules/%s/on-calls?scheduleIdentifierType=name&flat=true",
+		strings.TrimRight(cfg.APIURL, "/"), url.PathEscape(cfg.Schedule))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "GenieKey "+cfg.APIKey)
+
+	var body struct {
+		Data struct {
+			OnCallRecipients []string `json:"onCallRecipients"`
+		} `json:"data"`
+	}
+	if err := getJSON(client, req, &body); err != nil {
+		return "", fmt.Errorf("Opsgenie on-calls: %w", err)
+	}
+	if len(body.Data.OnCallRecipients) == 0 {
+		return "", nil
+	}
+	return body.Data.OnCallRecipients[0], nil
+}
+
+// getJSON sends the request and decodes a JSON response into v.
+func getJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,184 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"alertmanager-adapter/notifier"
+)
+
+// opsgenieAPIURL is the Opsgenie API of the US service region.
+const opsgenieAPIURL = "https://api.opsgenie.com"
+
+// defaultOpsgeniePriorities maps severity label values onto Opsgenie priorities;
+// other severities get P3.
+var defaultOpsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+// opsgenieNotifier creates one Opsgenie alert per Alertmanager alert through the Alert
+// API and closes it when the alert resolves. Its destinations are API keys of Opsgenie
+// API integrations.
+type opsgenieNotifier struct {
+	router     *webhookRouter
+	priorities map[string]string
+	url        string
+}
+
+// opsgenieMessage is the rendered notification: the alert to create, or for resolved
+// alerts the alias of the one to close. Send turns it into the matching API request.
+type opsgenieMessage struct {
+	Alias string         `json:"alias"`
+	Close bool           `json:"close,omitempty"`
+	Alert *opsgenieAlert `json:"alert,omitempty"`
+}
+
+// opsgenieAlert is the body of a create alert request.
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+}
+
+func init() {
+	outputRegistry.Register("opsgenie", func(cfg *Config, _ outputDeps) (output, error) {
+		return newOpsgenieNotifier(cfg.Opsgenie)
+	})
+}
+
+func newOpsgenieNotifier(cfg OpsgenieConfig) (output, error) {
+	// Events carry no human-readable layout, so the router needs no localization and
+	// cannot fail.
+	router, _ := newWebhookRouter(cfg.webhookConfig(), nil)
+	if router.empty() {
+		return nil, fmt.Errorf("no Opsgenie API key is configured")
+	}
+	priorities := make(map[string]string, len(defaultOpsgeniePriorities)+len(cfg.Priorities))
+	for severity, priority := range defaultOpsgeniePriorities {
+		priorities[severity] = priority
+	}
+	for severity, priority := range cfg.Priorities {
+		priorities[strings.ToLower(severity)] = strings.ToUpper(priority)
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = opsgenieAPIURL
+	}
+	return &opsgenieNotifier{router: router, priorities: priorities, url: strings.TrimRight(apiURL, "/")}, nil
+}
+
+func (n *opsgenieNotifier) Name() string { return "opsgenie" }
+
+// render builds a create or close request for every alert.
+func (n *opsgenieNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	var messages []notifier.Notification
+	for _, alert := range payload.Alerts {
+		keys := n.router.routes(alert)
+		if len(keys) == 0 {
+			slog.Warn("No Opsgenie API key configured for alert, dropping it", alertAttr(alert))
+			continue
+		}
+		message := n.buildMessage(alert, payload.Status)
+		for _, key := range keys {
+			m, err := newNotification(n.Name(), key, 1, message)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (n *opsgenieNotifier) routes(alert Alert) []string {
+	return n.router.routes(alert)
+}
+
+// renderText is not supported: every Opsgenie request creates or closes an alert.
+func (n *opsgenieNotifier) renderText(apiKey, text string) (notifier.Notification, error) {
+	return notifier.Notification{}, errTextUnsupported
+}
+
+func (n *opsgenieNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	var message opsgenieMessage
+	if err := json.Unmarshal(m.Body, &message); err != nil {
+		return fmt.Errorf("decoding Opsgenie message: %v: %w", err, errNotRetryable)
+	}
+
+	header := http.Header{"Authorization": {"GenieKey " + m.Destination}}
+	endpoint, body := n.url+"/v2/alerts", []byte(nil)
+	if message.Close {
+		// Closing by alias needs no alert ID, so it works for alerts created before a restart.
+		endpoint = fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", n.url, url.PathEscape(message.Alias))
+		body, _ = json.Marshal(map[string]string{"source": "alertmanager-adapter", "note": "Resolved in Alertmanager"})
+	} else {
+		body, _ = json.Marshal(message.Alert)
+	}
+	if err := postJSONWithHeader(ctx, endpoint, body, header); err != nil {
+		return fmt.Errorf("posting to Opsgenie: %w", err)
+	}
+	return nil
+}
+
+// buildMessage keys the Opsgenie alert by the alert fingerprint. Opsgenie deduplicates
+// open alerts with the same alias, so repeated notifications only raise its count, and
+// the resolved notification closes it.
+func (n *opsgenieNotifier) buildMessage(alert Alert, payloadStatus string) opsgenieMessage {
+	alias := alertFingerprint(alert)
+	if status, _, _ := alertAppearance(alert, payloadStatus); status == "resolved" {
+		return opsgenieMessage{Alias: alias, Close: true}
+	}
+
+	message := alert.Labels["alertname"]
+	if s := alert.Annotations["summary"]; s != "" {
+		message += ": " + s
+	}
+	description := alert.Annotations["description"]
+	if description == "" {
+		description = alert.Annotations["summary"]
+	}
+	details := make(map[string]string, len(alert.Labels)+len(alert.Annotations))
+	for name, value := range alert.Labels {
+		details[name] = value
+	}
+	for name, value := range alert.Annotations {
+		details[name] = value
+	}
+	var tags []string
+	for _, label := range []string{"severity", "alertname", "team"} {
+		if value := alert.Labels[label]; value != "" {
+			tags = append(tags, value)
+		}
+	}
+	sort.Strings(tags)
+
+	priority := n.priorities[strings.ToLower(alert.Labels["severity"])]
+	if priority == "" {
+		priority = "P3"
+	}
+	return opsgenieMessage{Alias: alias, Alert: &opsgenieAlert{
+		// Opsgenie cuts messages after 130 and descriptions after 15000 characters.
+		Message:     truncateRunes(message, 130),
+		Alias:       alias,
+		Description: truncateRunes(description, 15000),
+		Tags:        tags,
+		Details:     details,
+		Entity:      alert.Labels["instance"],
+		Source:      "alertmanager-adapter",
+		Priority:    priority,
+	}}
+}
@@ -0,0 +1,208 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// output is an enabled backend. Rendering and sending are separate steps so that
+// rendered notifications can be stored in the outbound queue and retried independently.
+type output interface {
+	notifier.Notifier
+	// render routes the payload's alerts and builds one notification per destination.
+	render(payload AlertmanagerPayload) ([]notifier.Notification, error)
+	// routes returns the destinations the alert is sent to.
+	routes(alert Alert) []string
+	// renderText builds a plain notice (e.g. a rate limit summary) for one destination.
+	// Backends without a notion of plain notices return errTextUnsupported.
+	renderText(destination, text string) (notifier.Notification, error)
+}
+
+// errTextUnsupported is returned by renderText of backends that cannot post plain notices.
+var errTextUnsupported = errors.New("plain notices are not supported")
+
+// outputFactory builds a backend from the configuration. deps holds the state that
+// outlives config reloads.
+type outputFactory func(cfg *Config, deps outputDeps) (output, error)
+
+// outputDeps is the adapter state some backends need.
+type outputDeps struct {
+	threads *threadTracker
+	issues  *jiraIssues
+	actions *alertActions
+	// locales localizes the messages of the human-readable backends.
+	locales *localization
+}
+
+// outputRegistry holds every backend; each backend's file registers it in init.
+var outputRegistry = notifier.NewRegistry[outputFactory]()
+
+// newOutputs builds the backends listed in cfg.Outputs.
+func newOutputs(cfg *Config, deps outputDeps) ([]output, error) {
+	var outputs []output
+	seen := make(map[string]bool)
+	for _, name := range cfg.Outputs {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		factory, ok := outputRegistry.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown output %q (available: %s)", name, strings.Join(outputRegistry.Names(), ", "))
+		}
+		o, err := factory(cfg, deps)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, o)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no output backend is enabled")
+	}
+	return outputs, nil
+}
+
+// outputSelector picks the backends of an alert from the output routes: the first
+// matching route wins unless it sets continue. Alerts matching no route go to every
+// enabled backend.
+type outputSelector struct {
+	routes []outputRoute
+}
+
+type outputRoute struct {
+	matchers         []labelMatcher
+	outputs          []string
+	continueMatching bool
+}
+
+// newOutputSelector returns nil when no output routes are configured.
+func newOutputSelector(routes []OutputRouteConfig) *outputSelector {
+	if len(routes) == 0 {
+		return nil
+	}
+	s := &outputSelector{}
+	for _, rc := range routes {
+		// The matchers have already been checked by Config.validate.
+		matchers, _ := parseMatchers(rc.Matchers)
+		route := outputRoute{matchers: matchers, continueMatching: rc.Continue}
+		for _, name := range rc.Outputs {
+			route.outputs = append(route.outputs, strings.ToLower(strings.TrimSpace(name)))
+		}
+		s.routes = append(s.routes, route)
+	}
+	return s
+}
+
+// selects reports whether the alert is sent to the backend name.
+func (s *outputSelector) selects(alert Alert, name string) bool {
+	if s == nil {
+		return true
+	}
+	matched := false
+	for _, route := range s.routes {
+		if !matchAll(route.matchers, alert.Labels) {
+			continue
+		}
+		if slices.Contains(route.outputs, name) {
+			return true
+		}
+		matched = true
+		if !route.continueMatching {
+			break
+		}
+	}
+	return !matched
+}
+
+// filter returns the alerts sent to the backend name.
+func (s *outputSelector) filter(alerts []Alert, name string) []Alert {
+	if s == nil {
+		return alerts
+	}
+	var selected []Alert
+	for _, alert := range alerts {
+		if s.selects(alert, name) {
+			selected = append(selected, alert)
+		}
+	}
+	return selected
+}
+
+// newNotification encodes body as the notification for backend, reporting on the given
+// number of alerts.
+func newNotification(backend, destination string, alerts int, body any) (notifier.Notification, error) {
+	e := jsonEncoders.Get().(*jsonEncoder)
+	defer jsonEncoders.Put(e)
+	e.buf.Reset()
+	if err := e.enc.Encode(body); err != nil {
+		return notifier.Notification{}, fmt.Errorf("encoding %s message: %w", backend, err)
+	}
+	// Like json.Marshal, without the newline Encode ends with; the buffer is reused.
+	raw := bytes.Clone(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")))
+	return notifier.Notification{Backend: backend, Destination: destination, Body: raw, Alerts: alerts, RenderedAt: time.Now()}, nil
+}
+
+// jsonEncoder is an encoder with the buffer it writes to. Rendering reuses them, so
+// the buffer does not have to grow again for every message.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var jsonEncoders = sync.Pool{New: func() any {
+	e := new(jsonEncoder)
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// postJSON sends an already encoded JSON body to a webhook and maps non-2xx answers to
+// webhookStatusError. Each post is a client span, and the trace context is sent along.
+func postJSON(ctx context.Context, webhookURL string, body []byte) error {
+	return postJSONWithHeader(ctx, webhookURL, body, nil)
+}
+
+// postJSONWithHeader is postJSON with additional request headers, e.g. credentials of
+// APIs that do not take them in the URL.
+func postJSONWithHeader(ctx context.Context, webhookURL string, body []byte, header http.Header) (err error) {
+	ctx, span := tracer.Start(ctx, "POST", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(webhookHost(webhookURL)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := newOutboundRequest(ctx, http.MethodPost, webhookURL, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	setResponseStatus(ctx, resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// pagerDutyNotifier sends one Events API v2 event per alert. Its destinations are
+// integration routing keys; only alerts whose severity is listed in severities are sent.
+type pagerDutyNotifier struct {
+	router     *webhookRouter
+	severities []string
+	url        string
+}
+
+// pagerDutyEvent is the body of an Events API v2 request.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Client      string            `json:"client,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func init() {
+	outputRegistry.Register("pagerduty", func(cfg *Config, _ outputDeps) (output, error) {
+		return newPagerDutyNotifier(cfg.PagerDuty)
+	})
+}
+
+func newPagerDutyNotifier(cfg PagerDutyConfig) (output, error) {
+	// Events carry no human-readable layout, so the router needs no localization and
+	// cannot fail.
+	router, _ := newWebhookRouter(cfg.webhookConfig(), nil)
+	if router.empty() {
+		return nil, fmt.Errorf("no PagerDuty routing key is configured")
+	}
+	severities := make([]string, len(cfg.Severities))
+	for i, s := range cfg.Severities {
+		severities[i] = strings.ToLower(s)
+	}
+	url := cfg.URL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	return &pagerDutyNotifier{router: router, severities: severities, url: url}, nil
+}
+
+func (n *pagerDutyNotifier) Name() string { return "pagerduty" }
+
+// render builds a trigger or resolve event for every alert with a paging severity.
+func (n *pagerDutyNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	var messages []notifier.Notification
+	for _, alert := range payload.Alerts {
+		if !n.pages(alert) {
+			continue
+		}
+		keys := n.router.routes(alert)
+		if len(keys) == 0 {
+			slog.Warn("No PagerDuty routing key configured for alert, dropping it", alertAttr(alert))
+			continue
+		}
+		for _, key := range keys {
+			m, err := newNotification(n.Name(), key, 1, buildPagerDutyEvent(key, alert, payload.Status))
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// pages reports whether the alert's severity is forwarded to PagerDuty.
+func (n *pagerDutyNotifier) pages(alert Alert) bool {
+	return slices.Contains(n.severities, strings.ToLower(alert.Labels["severity"]))
+}
+
+func (n *pagerDutyNotifier) routes(alert Alert) []string {
+	if !n.pages(alert) {
+		return nil
+	}
+	return n.router.routes(alert)
+}
+
+// renderText is not supported: every PagerDuty event opens or resolves an incident.
+func (n *pagerDutyNotifier) renderText(routingKey, text string) (notifier.Notification, error) {
+	return notifier.Notification{}, errTextUnsupported
+}
+
+func (n *pagerDutyNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, n.url, m.Body); err != nil {
+		return fmt.Errorf("posting to PagerDuty: %w", err)
+	}
+	return nil
+}
+
+// buildPagerDutyEvent keys the event by the alert fingerprint, so the resolved
+// notification closes the incident its firing one opened.
+func buildPagerDutyEvent(routingKey string, alert Alert, payloadStatus string) pagerDutyEvent {
+	event := pagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "trigger",
+		DedupKey:    alertFingerprint(alert),
+		Client:      "alertmanager-adapter",
+	}
+	if status, _, _ := alertAppearance(alert, payloadStatus); status == "resolved" {
+		event.EventAction = "resolve"
+		return event
+	}
+
+	summary := alert.Labels["alertname"]
+	if s := alert.Annotations["summary"]; s != "" {
+		summary += ": " + s
+	}
+	source := alert.Labels["instance"]
+	if source == "" {
+		source = "alertmanager"
+	}
+	details := make(map[string]string, len(alert.Labels)+len(alert.Annotations))
+	for name, value := range alert.Labels {
+		details[name] = value
+	}
+	for name, value := range alert.Annotations {
+		details[name] = value
+	}
+
+	event.Payload = &pagerDutyPayload{
+		// PagerDuty rejects summaries longer than 1024 characters.
+		Summary:       truncateRunes(summary, 1024),
+		Source:        source,
+		Severity:      pagerDutySeverity(alert.Labels["severity"]),
+		Class:         alert.Labels["alertname"],
+		Group:         alert.Labels["job"],
+		CustomDetails: details,
+	}
+	if t, err := time.Parse(time.RFC3339, alert.StartsAt); err == nil && !t.IsZero() && t.Year() > 1 {
+		event.Payload.Timestamp = t.UTC().Format(time.RFC3339)
+	}
+	for _, link := range []struct{ annotation, text string }{
+		{"runbook_url", "Runbook"},
+		{"dashboard_url", "Dashboard"},
+		{"generator_url", "Source"},
+	} {
+		if href := alert.Annotations[link.annotation]; href != "" {
+			event.Links = append(event.Links, pagerDutyLink{Href: href, Text: link.text})
+		}
+	}
+	return event
+}
+
+// pagerDutySeverity maps the severity label onto PagerDuty's fixed severities.
+func pagerDutySeverity(severity string) string {
+	switch s := strings.ToLower(severity); s {
+	case "critical", "error", "warning", "info":
+		return s
+	default:
+		return "error"
+	}
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Google Chat rejects messages whose text is longer than 4096 characters or whose
+// encoded body exceeds 32 KB. Pages stay below both, with room for the page line.
+const (
+	gchatMaxTextBytes    = 4000
+	gchatMaxMessageBytes = 30000
+	// gchatPageLineBytes is reserved on every page for its "Page x of y" line.
+	gchatPageLineBytes = 32
+)
+
+// gchatPage is one message of a paginated alert list.
+type gchatPage struct {
+	message GoogleChatCard
+	alerts  int
+}
+
+// paginate renders the payload as one message if it fits Google Chat's limits, and
+// otherwise spreads its alerts over as many messages as needed, in order. An alert
+// that does not fit a message on its own gets its text truncated.
+func (n *googleChatNotifier) paginate(payload AlertmanagerPayload, l *localizer) ([]gchatPage, error) {
+	whole, err := n.buildMessage(payload, l)
+	if err != nil {
+		return nil, err
+	}
+	if fits, err := gchatFits(whole, 0); err != nil || fits {
+		return []gchatPage{{message: whole, alerts: len(payload.Alerts)}}, err
+	}
+
+	var pages []gchatPage
+	var current gchatPage
+	for start, end := 0, 1; start < len(payload.Alerts); end++ {
+		page := payload
+		page.Alerts = payload.Alerts[start:end]
+		message, err := n.buildMessage(page, l)
+		if err != nil {
+			return nil, err
+		}
+		fits, err := gchatFits(message, gchatPageLineBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case fits && end < len(payload.Alerts):
+			// Try to add the next alert as well.
+			current = gchatPage{message: message, alerts: end - start}
+			continue
+		case fits:
+			pages = append(pages, gchatPage{message: message, alerts: end - start})
+		case end-start == 1:
+			// A single alert too large for a message.
+			message.Text = truncateText(message.Text, gchatMaxTextBytes-gchatPageLineBytes)
+			pages = append(pages, gchatPage{message: message, alerts: 1})
+		default:
+			// The last alert did not fit, so the page ends before it.
+			pages = append(pages, current)
+			end--
+		}
+		start = end
+	}
+
+	for i := range pages {
+		line := fmt.Sprintf("_Page %d of %d_", i+1, len(pages))
+		if pages[i].message.Text == "" {
+			pages[i].message.Text = line
+		} else {
+			pages[i].message.Text = line + "\n" + pages[i].message.Text
+		}
+	}
+	return pages, nil
+}
+
+// gchatFits reports whether a message is within Google Chat's limits with reserve bytes
+// to spare.
+func gchatFits(m GoogleChatCard, reserve int) (bool, error) {
+	if len(m.Text)+reserve > gchatMaxTextBytes {
+		return false, nil
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return false, fmt.Errorf("encoding Google Chat message: %w", err)
+	}
+	return len(body)+reserve <= gchatMaxMessageBytes, nil
+}
+
+// truncateText shortens s to at most maxBytes, cutting at a character boundary and
+// marking the cut.
+func truncateText(s string, maxBytes int) string {
+	const marker = "\n… (truncated)"
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
+
+// pageThreadKey is the thread the pages of a message are posted to when incident
+// threading is off, so a long alert list still reads as one conversation.
+func pageThreadKey(payload AlertmanagerPayload) string {
+	return fmt.Sprintf("pages-%s-%d", alertFingerprint(payload.Alerts[0]), time.Now().UnixNano())
+}
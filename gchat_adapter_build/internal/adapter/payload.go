@@ -0,0 +1,49 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// supportedPayloadVersions are the Alertmanager webhook versions the adapter understands.
+// A payload without a version is accepted for senders that post the Alertmanager
+// format themselves, like the gpu-collector.
+var supportedPayloadVersions = []string{"4"}
+
+// decodeAlertmanagerPayload reads and validates one Alertmanager webhook payload. With
+// strict set, fields the webhook schema does not define are rejected.
+func decodeAlertmanagerPayload(r io.Reader, strict bool) (AlertmanagerPayload, error) {
+	var payload AlertmanagerPayload
+	if err := decodeJSON(json.NewDecoder(r), &payload, strict); err != nil {
+		return AlertmanagerPayload{}, err
+	}
+	return payload, payload.validate()
+}
+
+// validate checks the payload against the webhook schema of its version.
+func (p AlertmanagerPayload) validate() error {
+	if p.Version != "" && !slices.Contains(supportedPayloadVersions, p.Version) {
+		return fmt.Errorf("unsupported payload version %q (supported: %s)", p.Version, strings.Join(supportedPayloadVersions, ", "))
+	}
+	if p.Status != "firing" && p.Status != "resolved" {
+		return fmt.Errorf("status must be \"firing\" or \"resolved\", got %q", p.Status)
+	}
+	if p.Version != "" && p.GroupKey == "" {
+		return fmt.Errorf("groupKey is required")
+	}
+	if p.TruncatedAlerts < 0 {
+		return fmt.Errorf("truncatedAlerts must not be negative")
+	}
+	if len(p.Alerts) == 0 {
+		return fmt.Errorf("payload has no alerts")
+	}
+	for i, alert := range p.Alerts {
+		if alert.Status != "firing" && alert.Status != "resolved" {
+			return fmt.Errorf("alerts[%d]: status must be \"firing\" or \"resolved\", got %q", i, alert.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+)
+
+// previewedMessage is one rendered message as returned by /api/preview. Destination is
+// shown like in the audit log, so webhook secrets are not.
+type previewedMessage struct {
+	Backend     string          `json:"backend"`
+	Destination string          `json:"destination"`
+	Alerts      int             `json:"alerts"`
+	Message     json.RawMessage `json:"message"`
+}
+
+// messagePreview serves POST /api/preview: it renders a webhook payload for every
+// enabled backend, like cmd/replay, and returns the messages without
+// sending them, so template authors can iterate on a running adapter and CI can
+// snapshot the formats. ?format=grafana reads a Grafana payload. Redaction is applied;
+// enrichment, filters, deduplication, grouping and maintenance windows are not.
+type messagePreview struct {
+	token string
+	// adapter renders with the current configuration but state of its own, so
+	// previews never claim the incident threads of real alerts.
+	adapter *adapter
+}
+
+// newMessagePreview returns nil when the preview API is disabled.
+func newMessagePreview(cfg *Config) (*messagePreview, error) {
+	if !cfg.Preview.Enabled {
+		return nil, nil
+	}
+	a, err := newOfflineAdapter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &messagePreview{token: cfg.Preview.Token, adapter: a}, nil
+}
+
+// apply renders later previews with cfg.
+func (p *messagePreview) apply(cfg *Config) error {
+	return p.adapter.apply(cfg)
+}
+
+func (p *messagePreview) handle(w http.ResponseWriter, r *http.Request) {
+	if p.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+p.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	var payload AlertmanagerPayload
+	var err error
+	switch r.URL.Query().Get("format") {
+	case "", "alertmanager":
+		payload, err = decodeAlertmanagerPayload(r.Body, false)
+	case "grafana":
+		var gp grafanaPayload
+		if err = decodeJSON(json.NewDecoder(r.Body), &gp, false); err == nil {
+			payload = gp.normalize()
+			err = payload.validate()
+		}
+	default:
+		writeWebhookError(w, http.StatusBadRequest, "invalid_format", "format must be alertmanager or grafana")
+		return
+	}
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	messages, err := p.adapter.buildMessages(r.Context(), p.adapter.redact(payload))
+	if err != nil {
+		loggerFrom(r.Context()).Warn("Error rendering preview", "err", err)
+		writeWebhookError(w, http.StatusUnprocessableEntity, "render_failed", err.Error())
+		return
+	}
+	previewed := make([]previewedMessage, 0, len(messages))
+	for _, m := range messages {
+		backend := backendName(m)
+		previewed = append(previewed, previewedMessage{Backend: backend, Destination: auditDestination(backend, m.Destination), Alerts: m.Alerts, Message: m.Body})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(previewed)
+}
@@ -0,0 +1,127 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// topProcessesAnnotation is the annotation listing the processes using the most memory
+// on the alerting GPU; the rich message formats display it as "Top processes".
+const topProcessesAnnotation = "gpu_top_processes"
+
+// processEnricher looks up the alerting node's gpu-collector and annotates memory and
+// utilization alerts with the processes holding the most GPU memory, so the message
+// shows who is using the GPU.
+type processEnricher struct {
+	urlTemplate string
+	alertnames  *regexp.Regexp
+	top         int
+	client      *http.Client
+}
+
+// gpuProcessUsage is one gpu_process_memory_used_bytes sample.
+type gpuProcessUsage struct {
+	gpu, uuid string
+	pid       string
+	user      string
+	container string
+	command   string
+	bytes     float64
+}
+
+// newProcessEnricher returns nil when no collector URL is configured. The alert name
+// pattern has already been checked by Config.validate.
+func newProcessEnricher(cfg ProcessesConfig) *processEnricher {
+	if cfg.CollectorURL == "" {
+		return nil
+	}
+	return &processEnricher{
+		urlTemplate: cfg.CollectorURL,
+		alertnames:  regexp.MustCompile(cfg.AlertPattern),
+		top:         cfg.Top,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (e *processEnricher) enrich(payload AlertmanagerPayload) AlertmanagerPayload {
+	cache := make(map[string][]gpuProcessUsage)
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alerts[i] = alert
+		if alert.Status == "resolved" || !e.alertnames.MatchString(alert.Labels["alertname"]) {
+			continue
+		}
+
+		url := nodeURL(e.urlTemplate, alert.Labels["instance"])
+		if url == "" {
+			continue
+		}
+		usage, ok := cache[url]
+		if !ok {
+			var err error
+			usage, err = e.fetch(url)
+			if err != nil {
+				slog.Warn("Error fetching GPU processes", "url", url, "err", err)
+			}
+			cache[url] = usage
+		}
+
+		if top := e.topProcesses(alert.Labels, usage); top != "" {
+			alerts[i] = withAnnotations(alert, map[string]string{topProcessesAnnotation: top})
+		}
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (e *processEnricher) fetch(url string) ([]gpuProcessUsage, error) {
+	families, err := scrapeMetrics(e.client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []gpuProcessUsage
+	for _, m := range families["gpu_process_memory_used_bytes"].GetMetric() {
+		labels := metricLabels(m)
+		usage = append(usage, gpuProcessUsage{
+			gpu:       labels["gpu"],
+			uuid:      labels["uuid"],
+			pid:       labels["pid"],
+			user:      labels["user"],
+			container: labels["container"],
+			command:   labels["command"],
+			bytes:     metricValue(m),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].bytes > usage[j].bytes })
+	return usage, nil
+}
+
+// topProcesses renders the largest processes on the alert's GPU (by its gpu or UUID
+// label), or on the whole node for alerts without one, e.g.
+// "alice: python (pid 4242, container 1a2b3c4d5e6f) 31.2 GiB".
+func (e *processEnricher) topProcesses(labels map[string]string, usage []gpuProcessUsage) string {
+	var lines []string
+	for _, p := range usage {
+		if len(lines) == e.top {
+			break
+		}
+		if (labels["gpu"] != "" && labels["gpu"] != p.gpu) || (labels["UUID"] != "" && labels["UUID"] != p.uuid) {
+			continue
+		}
+		detail := "pid " + p.pid
+		if p.container != "" {
+			detail += ", container " + p.container
+		}
+		line := fmt.Sprintf("%s: %s (%s) %.1f GiB", p.user, p.command, detail, p.bytes/(1<<30))
+		if labels["gpu"] == "" && labels["UUID"] == "" {
+			line = fmt.Sprintf("GPU %s · %s", p.gpu, line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,311 @@
+package adapter
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	bolt "go.etcd.io/bbolt"
+)
+
+// queueBucket holds pending outbound messages keyed by a big-endian sequence number,
+// so iterating the bucket yields messages in arrival order.
+var queueBucket = []byte("outbound")
+
+// outboundQueue is a durable queue of rendered messages backed by a BoltDB file.
+// Messages are only removed after they were delivered, giving at-least-once delivery
+// across adapter restarts. Each destination is drained on its own, oldest message
+// first, so a destination that is down or rate limited only holds back its own
+// messages.
+type outboundQueue struct {
+	db *bolt.DB
+	// wake is signalled whenever new messages are enqueued.
+	wake chan struct{}
+	// rejected, if set, receives the messages dropped because of a non-retryable error.
+	rejected func(notifier.Notification, error)
+
+	mu sync.Mutex
+	// heads lists the keys of the queued messages of every destination, oldest first.
+	heads map[destination][][]byte
+}
+
+// queuedMessage is the on-disk representation of a queue entry.
+type queuedMessage struct {
+	notifier.Notification
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+}
+
+func (m queuedMessage) destination() destination {
+	return destination{m.Backend, m.Destination}
+}
+
+func openOutboundQueue(path string) (*outboundQueue, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	q := &outboundQueue{db: db, wake: make(chan struct{}, 1), heads: make(map[destination][][]byte)}
+	// Index the messages left from the previous run by destination. An undecodable
+	// entry could never be delivered, so it is discarded.
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(queueBucket)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var m queuedMessage
+			if err := json.Unmarshal(v, &m); err != nil {
+				slog.Error("Discarding undecodable queued message", "seq", binary.BigEndian.Uint64(k), "err", err)
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				continue
+			}
+			q.heads[m.destination()] = append(q.heads[m.destination()], append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *outboundQueue) close() error {
+	return q.db.Close()
+}
+
+// enqueue stores the messages in a single transaction: either all are queued or none are.
+func (q *outboundQueue) enqueue(messages ...notifier.Notification) error {
+	now := time.Now().UTC()
+	keys := make([][]byte, 0, len(messages))
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(queueBucket)
+		for _, m := range messages {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(queuedMessage{Notification: m, EnqueuedAt: now})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(sequenceKey(seq), value); err != nil {
+				return err
+			}
+			keys = append(keys, sequenceKey(seq))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("storing messages: %w", err)
+	}
+
+	q.mu.Lock()
+	for i, m := range messages {
+		d := destination{m.Backend, m.Destination}
+		q.heads[d] = append(q.heads[d], keys[i])
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// drainResult reports that a destination's drain stopped, with the error that made it
+// stop early.
+type drainResult struct {
+	destination destination
+	err         error
+}
+
+// drain delivers queued messages until ctx is cancelled. Every destination with queued
+// messages is drained concurrently, oldest message first. A message that still fails
+// after deliver's own retries stays at the head of its destination's messages, which are
+// tried again after pause. Messages rejected with a non-retryable error are dropped (and
+// handed to rejected) so they cannot block their destination.
+func (q *outboundQueue) drain(ctx context.Context, deliver func(context.Context, notifier.Notification) error, pause time.Duration) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	done := make(chan drainResult)
+	draining := make(map[destination]bool)
+	pausedUntil := make(map[destination]time.Time)
+
+	for ctx.Err() == nil {
+		now := time.Now()
+		next := now.Add(pause)
+		for _, d := range q.destinations() {
+			if draining[d] {
+				continue
+			}
+			if until, ok := pausedUntil[d]; ok && now.Before(until) {
+				if until.Before(next) {
+					next = until
+				}
+				continue
+			}
+			delete(pausedUntil, d)
+			draining[d] = true
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := q.drainDestination(ctx, d, deliver)
+				select {
+				case done <- drainResult{d, err}:
+				case <-ctx.Done():
+				}
+			}()
+		}
+
+		select {
+		case r := <-done:
+			delete(draining, r.destination)
+			if r.err != nil {
+				pausedUntil[r.destination] = time.Now().Add(pause)
+			}
+		case <-q.wake:
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// drainDestination delivers the destination's messages until none are left, one fails,
+// or ctx is done.
+func (q *outboundQueue) drainDestination(ctx context.Context, d destination, deliver func(context.Context, notifier.Notification) error) error {
+	for ctx.Err() == nil {
+		empty, err := q.deliverNext(ctx, d, deliver)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// flush delivers queued messages until the queue is empty or ctx is done. It is used on
+// shutdown, after drain has stopped; a destination whose message fails is skipped, and
+// anything left stays on disk.
+func (q *outboundQueue) flush(ctx context.Context, deliver func(context.Context, notifier.Notification) error) error {
+	var failed error
+	for _, d := range q.destinations() {
+		if err := q.drainDestination(ctx, d, deliver); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			failed = err
+		}
+	}
+	return failed
+}
+
+// destinations returns the destinations with queued messages.
+func (q *outboundQueue) destinations() []destination {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	destinations := make([]destination, 0, len(q.heads))
+	for d := range q.heads {
+		destinations = append(destinations, d)
+	}
+	return destinations
+}
+
+// deliverNext delivers and removes the destination's oldest message. The returned error
+// is only set when the message should be tried again later.
+func (q *outboundQueue) deliverNext(ctx context.Context, d destination, deliver func(context.Context, notifier.Notification) error) (empty bool, err error) {
+	key, m, err := q.peek(d)
+	if err != nil {
+		slog.Error("Error reading outbound queue", "err", err)
+		if key != nil {
+			// An undecodable entry would block its destination forever; discard it.
+			q.remove(d, key)
+			return false, nil
+		}
+		return false, err
+	}
+	if key == nil {
+		return true, nil
+	}
+
+	if err := deliver(ctx, m.Notification); err != nil {
+		if isRetryable(err) {
+			slog.Warn("Error forwarding queued message, will retry", "backend", m.Backend, "queued_at", m.EnqueuedAt, "err", err)
+			return false, err
+		}
+		slog.Error("Dropping queued message rejected by webhook", "backend", m.Backend, "err", err)
+		if q.rejected != nil {
+			q.rejected(m.Notification, err)
+		}
+	}
+
+	if err := q.remove(d, key); err != nil {
+		slog.Error("Error removing delivered message from queue", "err", err)
+	}
+	return false, nil
+}
+
+// pending returns the number of queued messages.
+func (q *outboundQueue) pending() int {
+	n := 0
+	q.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(queueBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// peek returns the destination's oldest queued message, or a nil key if it has none.
+// If the entry cannot be decoded, its key is returned along with the error.
+func (q *outboundQueue) peek(d destination) (key []byte, m queuedMessage, err error) {
+	q.mu.Lock()
+	if keys := q.heads[d]; len(keys) > 0 {
+		key = keys[0]
+	}
+	q.mu.Unlock()
+	if key == nil {
+		return nil, m, nil
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(queueBucket).Get(key)
+		if v == nil {
+			return fmt.Errorf("queued message %d is missing", binary.BigEndian.Uint64(key))
+		}
+		if err := json.Unmarshal(v, &m); err != nil {
+			return fmt.Errorf("decoding queued message %d: %w", binary.BigEndian.Uint64(key), err)
+		}
+		return nil
+	})
+	return key, m, err
+}
+
+// remove deletes the destination's oldest message, whose key is given.
+func (q *outboundQueue) remove(d destination, key []byte) error {
+	q.mu.Lock()
+	if keys := q.heads[d]; len(keys) > 1 {
+		q.heads[d] = keys[1:]
+	} else {
+		delete(q.heads, d)
+	}
+	q.mu.Unlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).Delete(key)
+	})
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"alertmanager-adapter/notifier"
+)
+
+func openTestQueue(t *testing.T) *outboundQueue {
+	t.Helper()
+	q, err := openOutboundQueue(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.close() })
+	return q
+}
+
+func queuedNotification(webhookURL, text string) notifier.Notification {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	return notifier.Notification{Backend: "gchat", Destination: webhookURL, Body: body, Alerts: 1}
+}
+
+// deliveries records what a test deliver func was called with, per destination.
+type deliveries struct {
+	mu   sync.Mutex
+	sent map[string][]string
+}
+
+func (d *deliveries) add(m notifier.Notification) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sent == nil {
+		d.sent = make(map[string][]string)
+	}
+	var body struct{ Text string }
+	json.Unmarshal(m.Body, &body)
+	d.sent[m.Destination] = append(d.sent[m.Destination], body.Text)
+}
+
+func (d *deliveries) get(webhookURL string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.sent[webhookURL])
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestOutboundQueueDrainsDestinationsIndependently(t *testing.T) {
+	q := openTestQueue(t)
+	var sent deliveries
+	var downAttempts int
+	release := make(chan struct{})
+	deliver := func(ctx context.Context, m notifier.Notification) error {
+		switch m.Destination {
+		case "https://down.example.com":
+			sent.mu.Lock()
+			downAttempts++
+			sent.mu.Unlock()
+			return &webhookStatusError{Status: "503 Service Unavailable", StatusCode: http.StatusServiceUnavailable}
+		case "https://slow.example.com":
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		sent.add(m)
+		return nil
+	}
+
+	err := q.enqueue(
+		queuedNotification("https://down.example.com", "down 1"),
+		queuedNotification("https://slow.example.com", "slow 1"),
+		queuedNotification("https://up.example.com", "up 1"),
+		queuedNotification("https://down.example.com", "down 2"),
+		queuedNotification("https://up.example.com", "up 2"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.drain(ctx, deliver, time.Hour)
+		close(stopped)
+	}()
+
+	// Neither the failing nor the hanging destination holds up the others.
+	waitFor(t, "up.example.com", func() bool { return len(sent.get("https://up.example.com")) == 2 })
+	if got := sent.get("https://up.example.com"); !slices.Equal(got, []string{"up 1", "up 2"}) {
+		t.Errorf("up.example.com got %v, want its messages in order", got)
+	}
+	if err := q.enqueue(queuedNotification("https://up.example.com", "up 3")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a message enqueued while draining", func() bool { return len(sent.get("https://up.example.com")) == 3 })
+
+	close(release)
+	waitFor(t, "slow.example.com", func() bool { return len(sent.get("https://slow.example.com")) == 1 })
+
+	cancel()
+	<-stopped
+	// The failing destination is paused after its first failure and keeps its messages.
+	if downAttempts != 1 {
+		t.Errorf("down.example.com was tried %d times, want 1 before the pause ends", downAttempts)
+	}
+	if n := q.pending(); n != 2 {
+		t.Errorf("%d messages pending, want the 2 of down.example.com", n)
+	}
+	key, m, err := q.peek(destination{"gchat", "https://down.example.com"})
+	if err != nil || key == nil || string(m.Body) != `{"text":"down 1"}` {
+		t.Errorf("head of down.example.com = %s (%v), want its first message", m.Body, err)
+	}
+}
+
+func TestOutboundQueueRetriesAfterPause(t *testing.T) {
+	q := openTestQueue(t)
+	var sent deliveries
+	var mu sync.Mutex
+	failures := 2
+	deliver := func(ctx context.Context, m notifier.Notification) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return errors.New("connection refused")
+		}
+		sent.add(m)
+		return nil
+	}
+	q.enqueue(queuedNotification("https://flaky.example.com", "1"), queuedNotification("https://flaky.example.com", "2"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.drain(ctx, deliver, 10*time.Millisecond)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	waitFor(t, "flaky.example.com", func() bool { return len(sent.get("https://flaky.example.com")) == 2 })
+	if got := sent.get("https://flaky.example.com"); !slices.Equal(got, []string{"1", "2"}) {
+		t.Errorf("flaky.example.com got %v, want its messages in order", got)
+	}
+}
+
+func TestOutboundQueueDropsRejectedMessages(t *testing.T) {
+	q := openTestQueue(t)
+	var rejected []notifier.Notification
+	q.rejected = func(m notifier.Notification, err error) { rejected = append(rejected, m) }
+	var sent deliveries
+	deliver := func(ctx context.Context, m notifier.Notification) error {
+		if string(m.Body) == `{"text":"bad"}` {
+			return &webhookStatusError{Status: "400 Bad Request", StatusCode: http.StatusBadRequest}
+		}
+		sent.add(m)
+		return nil
+	}
+	q.enqueue(queuedNotification("https://chat.example.com", "bad"), queuedNotification("https://chat.example.com", "good"))
+
+	if err := q.flush(context.Background(), deliver); err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 1 || !slices.Equal(sent.get("https://chat.example.com"), []string{"good"}) {
+		t.Errorf("rejected %d and sent %v, want the bad message rejected and the good one sent", len(rejected), sent.get("https://chat.example.com"))
+	}
+	if n := q.pending(); n != 0 {
+		t.Errorf("%d messages pending, want 0", n)
+	}
+}
+
+func TestOutboundQueueFlushSkipsFailingDestinations(t *testing.T) {
+	q := openTestQueue(t)
+	var sent deliveries
+	deliver := func(ctx context.Context, m notifier.Notification) error {
+		if m.Destination == "https://down.example.com" {
+			return errors.New("connection refused")
+		}
+		sent.add(m)
+		return nil
+	}
+	q.enqueue(queuedNotification("https://down.example.com", "down"), queuedNotification("https://up.example.com", "up"))
+
+	if err := q.flush(context.Background(), deliver); err == nil {
+		t.Error("flush() succeeded with a destination down")
+	}
+	if !slices.Equal(sent.get("https://up.example.com"), []string{"up"}) || q.pending() != 1 {
+		t.Errorf("sent %v with %d pending, want up.example.com's message sent and down's kept", sent.get("https://up.example.com"), q.pending())
+	}
+}
+
+func TestOutboundQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	q, err := openOutboundQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.enqueue(queuedNotification("https://a.example.com", "a1"), queuedNotification("https://b.example.com", "b1"), queuedNotification("https://a.example.com", "a2"))
+	q.close()
+
+	q, err = openOutboundQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+	var sent deliveries
+	if err := q.flush(context.Background(), func(ctx context.Context, m notifier.Notification) error {
+		sent.add(m)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sent.get("https://a.example.com"), []string{"a1", "a2"}) || !slices.Equal(sent.get("https://b.example.com"), []string{"b1"}) {
+		t.Errorf("sent %v, want every message of the previous run in order", sent.sent)
+	}
+}
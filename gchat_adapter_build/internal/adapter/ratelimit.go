@@ -0,0 +1,122 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket per destination webhook. Google Chat (and most chat
+// webhooks) enforce per-space quotas; instead of getting 429s once the quota is used up,
+// messages over the limit are suppressed and counted, and a single summary message
+// reports them as soon as the bucket has a token again.
+type rateLimiter struct {
+	ratePerSecond float64
+	burst         float64
+
+	mu      sync.Mutex
+	buckets map[destination]*tokenBucket
+}
+
+// destination identifies one webhook of one backend.
+type destination struct {
+	backend    string
+	webhookURL string
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+
+	// suppressedAlerts counts alerts in messages dropped since suppressedSince.
+	suppressedAlerts int
+	suppressedSince  time.Time
+}
+
+// newRateLimiter returns nil when rate limiting is disabled.
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	if cfg.PerMinute <= 0 {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return &rateLimiter{
+		ratePerSecond: float64(cfg.PerMinute) / 60,
+		burst:         float64(burst),
+		buckets:       make(map[destination]*tokenBucket),
+	}
+}
+
+// allow takes a token for the destination. If none is available the message's alerts
+// are recorded as suppressed and false is returned.
+func (l *rateLimiter) allow(d destination, alerts int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(d, time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	if b.suppressedAlerts == 0 {
+		b.suppressedSince = time.Now()
+	}
+	b.suppressedAlerts += alerts
+	return false
+}
+
+// refill returns the destination's bucket topped up for the time elapsed since its
+// last use. The caller must hold l.mu.
+func (l *rateLimiter) refill(d destination, now time.Time) *tokenBucket {
+	b, ok := l.buckets[d]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[d] = b
+		return b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.ratePerSecond
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	return b
+}
+
+// runSummaries periodically sends the "N additional alerts suppressed" message for every
+// destination that has suppressed alerts and a token available again.
+func (l *rateLimiter) runSummaries(interval time.Duration, send func(d destination, text string) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for d, text := range l.takeSummaries() {
+			if err := send(d, text); err != nil {
+				slog.Error("Error sending rate limit summary", "backend", d.backend, "err", err)
+			}
+		}
+	}
+}
+
+// takeSummaries consumes a token and resets the suppressed counter for every
+// destination whose summary can be sent now.
+func (l *rateLimiter) takeSummaries() map[destination]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	summaries := make(map[destination]string)
+	for d := range l.buckets {
+		b := l.refill(d, now)
+		if b.suppressedAlerts == 0 || b.tokens < 1 {
+			continue
+		}
+		b.tokens--
+		summaries[d] = fmt.Sprintf("⚠️ %d additional alert(s) were suppressed by the adapter's rate limit since %s.",
+			b.suppressedAlerts, b.suppressedSince.UTC().Format("2006-01-02 15:04:05 MST"))
+		b.suppressedAlerts = 0
+	}
+	return summaries
+}
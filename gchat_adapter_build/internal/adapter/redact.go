@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// redactedValue replaces masked values or the masked parts of them.
+const redactedValue = "[REDACTED]"
+
+// redactor strips or masks labels and annotations before alerts are rendered, so the
+// same rules apply to every output backend (and to the alert history).
+type redactor struct {
+	rules []redactionRule
+}
+
+type redactionRule struct {
+	keys *regexp.Regexp
+	// values, if set, masks only the matching parts of the value.
+	values *regexp.Regexp
+	strip  bool
+}
+
+// newRedactor returns nil when no redaction rules are configured. The rules have already
+// been checked by Config.validate.
+func newRedactor(configs []RedactionConfig) *redactor {
+	rules, _ := parseRedactionRules(configs)
+	if len(rules) == 0 {
+		return nil
+	}
+	return &redactor{rules: rules}
+}
+
+func parseRedactionRules(configs []RedactionConfig) ([]redactionRule, error) {
+	var rules []redactionRule
+	for i, rc := range configs {
+		if len(rc.Keys) == 0 {
+			return nil, fmt.Errorf("redaction[%d]: keys is required", i)
+		}
+		var rule redactionRule
+		pattern := ""
+		for j, key := range rc.Keys {
+			if j > 0 {
+				pattern += "|"
+			}
+			pattern += "(?:" + key + ")"
+		}
+		keys, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("redaction[%d]: invalid keys: %w", i, err)
+		}
+		rule.keys = keys
+
+		switch rc.Action {
+		case "", "mask":
+		case "strip":
+			rule.strip = true
+		default:
+			return nil, fmt.Errorf("redaction[%d]: unsupported action %q (expected \"mask\" or \"strip\")", i, rc.Action)
+		}
+		if rc.Values != "" {
+			if rule.strip {
+				return nil, fmt.Errorf("redaction[%d]: values can only be used with action mask", i)
+			}
+			if rule.values, err = regexp.Compile(rc.Values); err != nil {
+				return nil, fmt.Errorf("redaction[%d]: invalid values: %w", i, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// redact returns the payload with the rules applied to every alert's labels and
+// annotations. The incoming maps are not modified.
+func (r *redactor) redact(payload AlertmanagerPayload) AlertmanagerPayload {
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alert.Labels = r.redactMap(alert.Labels)
+		alert.Annotations = r.redactMap(alert.Annotations)
+		alerts[i] = alert
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (r *redactor) redactMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for key, value := range values {
+		keep := true
+		for _, rule := range r.rules {
+			if !rule.keys.MatchString(key) {
+				continue
+			}
+			switch {
+			case rule.strip:
+				keep = false
+			case rule.values != nil:
+				value = rule.values.ReplaceAllLiteralString(value, redactedValue)
+			default:
+				value = redactedValue
+			}
+		}
+		if keep {
+			out[key] = value
+		}
+	}
+	return out
+}
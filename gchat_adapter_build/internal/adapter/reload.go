@@ -0,0 +1,77 @@
+package adapter
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces the burst of events editors and Kubernetes ConfigMap
+// updates produce for a single change.
+const reloadDebounce = 500 * time.Millisecond
+
+// watchConfig calls reload on SIGHUP and whenever one of the watched files changes.
+// The files' directories are watched rather than the files themselves, so atomic
+// replacements (rename over, ConfigMap symlink swaps) are picked up as well.
+func watchConfig(files []string, reload func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("Error watching config files, only SIGHUP will reload", "err", err)
+	} else {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+
+		dirs := make(map[string]bool)
+		for _, f := range files {
+			if f == "" {
+				continue
+			}
+			dir := filepath.Dir(f)
+			if dirs[dir] {
+				continue
+			}
+			dirs[dir] = true
+			if err := watcher.Add(dir); err != nil {
+				slog.Warn("Error watching config directory", "dir", dir, "err", err)
+			}
+		}
+	}
+
+	watched := make(map[string]bool)
+	for _, f := range files {
+		if f != "" {
+			watched[filepath.Clean(f)] = true
+		}
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-signals:
+			slog.Info("Received SIGHUP, reloading config")
+			reload()
+		case ev := <-events:
+			// ConfigMaps update through a "..data" symlink, so any change in the
+			// directory of a watched file may change its content.
+			if watched[filepath.Clean(ev.Name)] || filepath.Base(ev.Name) == "..data" {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			slog.Warn("Error watching config files", "err", err)
+		case <-debounce:
+			debounce = nil
+			slog.Info("Config file changed, reloading config")
+			reload()
+		}
+	}
+}
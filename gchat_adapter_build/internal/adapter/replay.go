@@ -0,0 +1,120 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// replayedMessage is what replay prints for every rendered message.
+type replayedMessage struct {
+	File       string          `json:"file"`
+	Backend    string          `json:"backend"`
+	WebhookURL string          `json:"webhookURL"`
+	Alerts     int             `json:"alerts"`
+	Message    json.RawMessage `json:"message"`
+}
+
+// Replay implements the replay command (cmd/replay): it renders captured webhook payloads
+// with the given config and prints the resulting messages, so template and routing
+// changes can be checked without a running Alertmanager. Only with -live are the messages
+// posted. Redaction is applied; enrichment, deduplication, grouping and maintenance
+// windows are not.
+func Replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: replay [flags] payload.json... (\"-\" reads stdin)\n")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "path to the YAML config file (default: read settings from environment variables)")
+	grafana := flags.Bool("grafana", false, "the payloads are Grafana unified alerting webhooks")
+	live := flags.Bool("live", false, "also post the rendered messages to their webhooks")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err == nil {
+		err = configureLogging(cfg.Log)
+	}
+	if err == nil {
+		err = setupHTTPClient(cfg.HTTPClient)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	a, err := newOfflineAdapter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	status := 0
+	for _, file := range flags.Args() {
+		payload, err := readReplayPayload(file, *grafana)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			status = 1
+			continue
+		}
+
+		messages, err := a.buildMessages(context.Background(), a.redact(payload))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering %s: %v\n", file, err)
+			status = 1
+			continue
+		}
+		for _, m := range messages {
+			out.Encode(replayedMessage{File: file, Backend: m.Backend, WebhookURL: m.Destination, Alerts: m.Alerts, Message: m.Body})
+			if !*live {
+				continue
+			}
+			if err := a.deliver(context.Background(), m); err != nil {
+				fmt.Fprintf(os.Stderr, "Error posting %s to %s: %v\n", file, m.Backend, err)
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// newOfflineAdapter returns an adapter that only renders with cfg. It never touches the
+// shared state of running replicas.
+func newOfflineAdapter(cfg *Config) (*adapter, error) {
+	a := &adapter{threads: newThreadTracker(newMemoryStore()), actions: newAlertActions(cfg.Actions)}
+	if err := a.apply(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// readReplayPayload decodes one captured payload; "-" reads it from stdin.
+func readReplayPayload(file string, grafana bool) (AlertmanagerPayload, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return AlertmanagerPayload{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if grafana {
+		var payload grafanaPayload
+		if err := json.NewDecoder(r).Decode(&payload); err != nil {
+			return AlertmanagerPayload{}, err
+		}
+		return payload.normalize(), nil
+	}
+	return decodeAlertmanagerPayload(r, false)
+}
@@ -0,0 +1,93 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// retryPolicy controls how often and how patiently outbound posts are retried.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newRetryPolicy(rc RetryConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts:    rc.MaxAttempts,
+		initialBackoff: rc.InitialBackoff,
+		maxBackoff:     rc.MaxBackoff,
+	}
+	if policy.maxBackoff < policy.initialBackoff {
+		policy.maxBackoff = policy.initialBackoff
+	}
+	return policy
+}
+
+// do runs op until it succeeds, returns a non-retryable error, the attempts are exhausted
+// or ctx is done while waiting for the next attempt. The returned error wraps the last
+// one seen.
+func (p retryPolicy) do(ctx context.Context, description string, op func() error) error {
+	var err error
+	for attempt := 1; attempt <= p.maxAttempts; attempt++ {
+		if err = op(); err == nil {
+			return nil
+		}
+		if !isRetryable(err) || attempt == p.maxAttempts {
+			break
+		}
+		wait := p.backoff(attempt)
+		slog.Warn(description+" failed, retrying", "attempt", attempt, "max_attempts", p.maxAttempts, "wait", wait.String(), "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (retry cancelled: %v)", err, ctx.Err())
+		}
+	}
+	return err
+}
+
+// backoff returns the delay before the next attempt: exponential growth capped at maxBackoff,
+// with "equal jitter" so that concurrent retries spread out without ever waiting zero.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialBackoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// webhookStatusError is returned when the webhook answers with a non-success status code.
+type webhookStatusError struct {
+	Status     string
+	StatusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook failed with status: %s", e.Status)
+}
+
+// errNotRetryable marks errors that can never succeed on a later attempt.
+var errNotRetryable = errors.New("not retryable")
+
+// isRetryable reports whether a failed post is worth retrying. Connection errors,
+// 5xx responses and 429 (rate limited) are retried; other 4xx responses are not,
+// since sending the same message again will not change the outcome.
+func isRetryable(err error) bool {
+	if errors.Is(err, errNotRetryable) {
+		return false
+	}
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 429
+	}
+	return true
+}
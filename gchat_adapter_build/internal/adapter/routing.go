@@ -0,0 +1,172 @@
+package adapter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// webhookRouter picks the webhooks for an alert: label routes (e.g. per team) first,
+// then the route for its severity label, then the default webhook.
+type webhookRouter struct {
+	labelRoutes []labelRoute
+	defaultURL  string
+	bySeverity  map[string]string
+
+	// localizers hold the localizer of each destination of a route with its own
+	// timezone or locale; the others use defaultLocalizer.
+	localizers       map[string]*localizer
+	defaultLocalizer *localizer
+}
+
+// labelRoute sends alerts matching all matchers to webhookURL. Unless continueMatching
+// is set, the first matching route wins; with it, later routes are tried as well so the
+// alert fans out to several spaces. Outside its active time (nil: always) the route is
+// skipped.
+type labelRoute struct {
+	matchers         []labelMatcher
+	webhookURL       string
+	continueMatching bool
+	active           *activeTime
+}
+
+// routedPayload is the subset of an incoming payload destined for a single webhook.
+type routedPayload struct {
+	webhookURL string
+	payload    AlertmanagerPayload
+	// localizer renders the payload's times and fixed words for the webhook.
+	localizer *localizer
+}
+
+// newWebhookRouter builds a router from a backend's webhook section. Severity keys are
+// matched case-insensitively. locales may be nil for backends that send no
+// human-readable messages.
+func newWebhookRouter(wc WebhookConfig, locales *localization) (*webhookRouter, error) {
+	defaultLocalizer, err := locales.localizer("", "")
+	if err != nil {
+		return nil, err
+	}
+	router := &webhookRouter{
+		defaultURL:       wc.WebhookURL,
+		bySeverity:       make(map[string]string, len(wc.SeverityWebhooks)),
+		localizers:       make(map[string]*localizer),
+		defaultLocalizer: defaultLocalizer,
+	}
+	for i, rc := range wc.Routes {
+		// The matchers have already been checked by Config.validate.
+		matchers, _ := parseMatchers(rc.Matchers)
+		zone, err := locales.localizer(rc.Timezone, "")
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		active, err := parseActiveTime(rc.ActiveTime, zone.loc)
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d].activeTime: %w", i, err)
+		}
+		router.labelRoutes = append(router.labelRoutes, labelRoute{
+			matchers:         matchers,
+			webhookURL:       rc.WebhookURL,
+			continueMatching: rc.Continue,
+			active:           active,
+		})
+		if rc.Timezone == "" && rc.Locale == "" {
+			continue
+		}
+		lz, err := locales.localizer(rc.Timezone, rc.Locale)
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		// A destination shared by several routes is localized by the first of them.
+		if _, ok := router.localizers[rc.WebhookURL]; !ok {
+			router.localizers[rc.WebhookURL] = lz
+		}
+	}
+	for severity, url := range wc.SeverityWebhooks {
+		if url != "" {
+			router.bySeverity[strings.ToLower(severity)] = url
+		}
+	}
+	return router, nil
+}
+
+// empty reports whether no webhook is configured at all.
+func (r *webhookRouter) empty() bool {
+	return r.defaultURL == "" && len(r.bySeverity) == 0 && len(r.labelRoutes) == 0
+}
+
+// localizer returns the localizer of a destination.
+func (r *webhookRouter) localizer(url string) *localizer {
+	if lz, ok := r.localizers[url]; ok {
+		return lz
+	}
+	return r.defaultLocalizer
+}
+
+// allLocalizers returns every localizer the router hands out, the default first.
+func (r *webhookRouter) allLocalizers() []*localizer {
+	all := []*localizer{r.defaultLocalizer}
+	for _, lz := range r.localizers {
+		if !slices.Contains(all, lz) {
+			all = append(all, lz)
+		}
+	}
+	return all
+}
+
+// routes returns the webhooks for the alert, or nil if no route and no default matches.
+func (r *webhookRouter) routes(alert Alert) []string {
+	var urls []string
+	now := time.Now()
+	for _, route := range r.labelRoutes {
+		if !route.active.contains(now) || !matchAll(route.matchers, alert.Labels) {
+			continue
+		}
+		if !slices.Contains(urls, route.webhookURL) {
+			urls = append(urls, route.webhookURL)
+		}
+		if !route.continueMatching {
+			break
+		}
+	}
+	if len(urls) > 0 {
+		return urls
+	}
+	if url, ok := r.bySeverity[strings.ToLower(alert.Labels["severity"])]; ok {
+		return []string{url}
+	}
+	if r.defaultURL != "" {
+		return []string{r.defaultURL}
+	}
+	return nil
+}
+
+// split groups the payload's alerts by destination webhook, keeping the original alert order.
+// An alert fanned out to several webhooks appears in each of their groups. Alerts without
+// any matching webhook are returned separately so the caller can report them.
+func (r *webhookRouter) split(payload AlertmanagerPayload) (routed []routedPayload, unrouted []Alert) {
+	index := make(map[string]int)
+	for _, alert := range payload.Alerts {
+		urls := r.routes(alert)
+		if len(urls) == 0 {
+			unrouted = append(unrouted, alert)
+			continue
+		}
+		for _, url := range urls {
+			i, ok := index[url]
+			if !ok {
+				i = len(routed)
+				index[url] = i
+				sub := payload
+				sub.Alerts = nil
+				routed = append(routed, routedPayload{webhookURL: url, payload: sub, localizer: r.localizer(url)})
+			}
+			routed[i].payload.Alerts = append(routed[i].payload.Alerts, alert)
+		}
+	}
+	// A webhook that only gets the resolved alerts of a firing payload is told they resolved.
+	for i := range routed {
+		routed[i].payload.Status = combinedStatus(routed[i].payload.Alerts)
+	}
+	return routed, unrouted
+}
@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// alertEnricher adds annotations to alerts before they are rendered. Enrichers must
+// never keep an alert from being delivered: lookup failures are logged and leave the
+// alert unchanged.
+type alertEnricher interface {
+	enrich(payload AlertmanagerPayload) AlertmanagerPayload
+}
+
+// newEnrichers builds the enrichers enabled in cfg, in the order they are applied.
+func newEnrichers(cfg *Config) ([]alertEnricher, error) {
+	var enrichers []alertEnricher
+	e, err := newNodeMetadataEnricher(cfg.NodeMetadata)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		enrichers = append(enrichers, e)
+	}
+	if e := newDCGMEnricher(cfg.DCGM); e != nil {
+		enrichers = append(enrichers, e)
+	}
+	if e := newProcessEnricher(cfg.Processes); e != nil {
+		enrichers = append(enrichers, e)
+	}
+	if e := newThrottleEnricher(cfg.Throttle); e != nil {
+		enrichers = append(enrichers, e)
+	}
+	if e := newChassisEnricher(cfg.Chassis); e != nil {
+		enrichers = append(enrichers, e)
+	}
+	return enrichers, nil
+}
+
+// nodeURL fills the "{host}" and "{instance}" placeholders of urlTemplate from an
+// instance label; "{host}" is the label without its port. It returns "" if the template
+// needs the label and the alert has none.
+func nodeURL(urlTemplate, instance string) string {
+	if instance == "" && (strings.Contains(urlTemplate, "{host}") || strings.Contains(urlTemplate, "{instance}")) {
+		return ""
+	}
+	host := instance
+	if h, _, err := net.SplitHostPort(instance); err == nil {
+		host = h
+	}
+	return strings.NewReplacer("{host}", host, "{instance}", instance).Replace(urlTemplate)
+}
+
+// scrapeMetrics fetches and parses a Prometheus text format endpoint.
+func scrapeMetrics(client *http.Client, url string) (map[string]*dto.MetricFamily, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metrics: %w", err)
+	}
+	return families, nil
+}
+
+// metricValue returns the sample value of a gauge, counter or untyped metric.
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	default:
+		return m.GetUntyped().GetValue()
+	}
+}
+
+// metricLabels returns the labels of a metric as a map.
+func metricLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// withAnnotations returns a copy of the alert with extra annotations merged in, leaving
+// the original (which may be shared with other messages) untouched.
+func withAnnotations(alert Alert, extra map[string]string) Alert {
+	merged := make(map[string]string, len(alert.Annotations)+len(extra))
+	for k, v := range alert.Annotations {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	alert.Annotations = merged
+	return alert
+}
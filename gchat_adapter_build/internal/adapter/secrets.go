@@ -0,0 +1,304 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretTimeout bounds each request to Vault or AWS Secrets Manager while the config
+// is loaded.
+const secretTimeout = 10 * time.Second
+
+// secretReference matches the secret references resolved in config values and
+// environment variables:
+//
+//	${file:/run/secrets/chat_webhook}       the file's content, without a trailing newline
+//	${env:CHAT_WEBHOOK}                     the environment variable
+//	${vault:secret/data/alerting#webhook}   a key of a Vault secret (KV v1 or v2)
+//	${aws-sm:alerting/chat#webhook}         an AWS Secrets Manager secret, or with #key
+//	                                        one key of its JSON object
+//
+// References are resolved whenever the config is loaded, so a reload picks up
+// rotated secrets. Only references written into the file are resolved: the config API
+// rejects edits that add one.
+var secretReference = regexp.MustCompile(`\$\{(file|env|vault|aws-sm):([^}]+)\}`)
+
+// secretsClient fetches secrets while the config is loaded, before outboundClient is
+// set up from it.
+var secretsClient = &http.Client{Timeout: secretTimeout}
+
+// resolveSecrets replaces every secret reference in s by the secret.
+func resolveSecrets(s string) (string, error) {
+	var firstErr error
+	resolved := secretReference.ReplaceAllStringFunc(s, func(ref string) string {
+		m := secretReference.FindStringSubmatch(ref)
+		secret, err := readSecret(m[1], m[2])
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("resolving %s: %w", ref, err)
+		}
+		return secret
+	})
+	return resolved, firstErr
+}
+
+func readSecret(source, ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	switch source {
+	case "file":
+		return readSecretFile(ref)
+	case "env":
+		v, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("%s is not set", ref)
+		}
+		return v, nil
+	case "vault":
+		path, key, ok := strings.Cut(ref, "#")
+		if !ok || key == "" {
+			return "", fmt.Errorf("vault references need a key, e.g. secret/data/alerting#webhook")
+		}
+		return readVaultSecret(ctx, path, key)
+	default:
+		id, key, _ := strings.Cut(ref, "#")
+		return readAWSSecret(ctx, id, key)
+	}
+}
+
+// readSecretFile reads a Docker or Kubernetes secret file. The trailing newline most
+// editors and `echo` add is not part of the secret.
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// secretEnv reads the environment variable name, or the file named by name_FILE when
+// that is set, resolving the secret references in it.
+func secretEnv(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		v, err := readSecretFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %s_FILE: %w", name, err)
+		}
+		return v, nil
+	}
+	v, err := resolveSecrets(os.Getenv(name))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// resolveConfigSecrets resolves the secret references in the scalar values of a YAML
+// document. Values are replaced in the parsed document, so secrets with quotes or
+// newlines cannot change its structure.
+func resolveConfigSecrets(content []byte) ([]byte, error) {
+	if !secretReference.Match(content) {
+		return content, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode {
+			v, err := resolveSecrets(n.Value)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			n.Value = v
+		}
+		for _, child := range n.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&doc)
+}
+
+// readVaultSecret reads a key of a Vault secret from VAULT_ADDR with VAULT_TOKEN (or
+// VAULT_TOKEN_FILE) and, for Vault Enterprise, VAULT_NAMESPACE. path is the API path
+// below /v1/, e.g. secret/data/alerting for the KV v2 secret alerting.
+func readVaultSecret(ctx context.Context, path, key string) (string, error) {
+	addr := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if file := os.Getenv("VAULT_TOKEN_FILE"); file != "" {
+		var err error
+		if token, err = readSecretFile(file); err != nil {
+			return "", err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := doSecretRequest(req, &secret); err != nil {
+		return "", err
+	}
+	data := secret.Data
+	// KV v2 nests the secret's keys under data.data, next to its metadata.
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	return secretString(value), nil
+}
+
+// readAWSSecret reads a secret from AWS Secrets Manager in AWS_REGION (or
+// AWS_DEFAULT_REGION), signing the request with the credentials in
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+// AWS_ENDPOINT_URL_SECRETS_MANAGER overrides the endpoint, e.g. for a VPC endpoint.
+// With a key, the secret must be a JSON object and the key's value is returned.
+func readAWSSecret(ctx context.Context, id, key string) (string, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if region == "" || accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, body, accessKey, secretKey, region, "secretsmanager", time.Now())
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doSecretRequest(req, &secret); err != nil {
+		return "", err
+	}
+	if key == "" {
+		return secret.SecretString, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	return secretString(value), nil
+}
+
+// signAWSRequest adds an AWS Signature Version 4 Authorization header for the request
+// with the body, signing every header set so far.
+func signAWSRequest(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(bodyHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// doSecretRequest sends a request to a secret manager and decodes its JSON response.
+// Error responses are reported by status only: their bodies may echo the request.
+func doSecretRequest(req *http.Request, v any) error {
+	resp, err := secretsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// secretString returns a secret value as a string; numbers and booleans are formatted
+// as in JSON.
+func secretString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
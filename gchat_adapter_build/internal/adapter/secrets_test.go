@@ -0,0 +1,211 @@
+package adapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "webhook")
+	if err := os.WriteFile(file, []byte("https://chat.example.com/hook\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRET_TOKEN", "t0ken")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "no references", "no references", false},
+		{"file without trailing newline", "${file:" + file + "}", "https://chat.example.com/hook", false},
+		{"env", "Bearer ${env:TEST_SECRET_TOKEN}", "Bearer t0ken", false},
+		{"several", "${env:TEST_SECRET_TOKEN}@${file:" + file + "}", "t0ken@https://chat.example.com/hook", false},
+		{"unset env", "${env:TEST_SECRET_UNSET}", "", true},
+		{"missing file", "${file:" + filepath.Join(dir, "missing") + "}", "", true},
+		{"vault without key", "${vault:secret/data/alerting}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSecrets(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveSecrets() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("resolveSecrets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRET_INNER", "inner")
+	t.Setenv("TEST_SECRET_PLAIN", "plain")
+	t.Setenv("TEST_SECRET_REF", "${env:TEST_SECRET_INNER}")
+	t.Setenv("TEST_SECRET_FROM", "ignored")
+	t.Setenv("TEST_SECRET_FROM_FILE", file)
+
+	for name, want := range map[string]string{
+		"TEST_SECRET_PLAIN": "plain",
+		"TEST_SECRET_REF":   "inner",
+		"TEST_SECRET_FROM":  "from-file",
+		"TEST_SECRET_UNSET": "",
+	} {
+		got, err := secretEnv(name)
+		if err != nil {
+			t.Errorf("secretEnv(%s): %v", name, err)
+		} else if got != want {
+			t.Errorf("secretEnv(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResolveConfigSecrets(t *testing.T) {
+	// A secret that would change the document's structure if it were substituted into
+	// the text instead of the parsed value.
+	t.Setenv("TEST_SECRET_TRICKY", "a\"b\nlogLevel: debug")
+	content := []byte("googleChat:\n  webhookURL: ${env:TEST_SECRET_TRICKY}\nlogLevel: info\n")
+
+	resolved, err := resolveConfigSecrets(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		GoogleChat struct {
+			WebhookURL string `yaml:"webhookURL"`
+		} `yaml:"googleChat"`
+		LogLevel string `yaml:"logLevel"`
+	}
+	if err := yaml.Unmarshal(resolved, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.GoogleChat.WebhookURL != "a\"b\nlogLevel: debug" || got.LogLevel != "info" {
+		t.Errorf("resolved config = %+v", got)
+	}
+
+	plain := []byte("logLevel: info # no references\n")
+	if resolved, err := resolveConfigSecrets(plain); err != nil || string(resolved) != string(plain) {
+		t.Errorf("resolveConfigSecrets() = %q, %v; want the content unchanged", resolved, err)
+	}
+
+	_, err = resolveConfigSecrets([]byte("logLevel: info\nslack:\n  webhookURL: ${env:TEST_SECRET_UNSET}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("resolveConfigSecrets() error = %v, want one naming line 3", err)
+	}
+}
+
+func TestReadVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/alerting":
+			// KV v2
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"webhook": "v2-value"}, "metadata": map[string]any{"version": 3}}})
+		case "/v1/kv/alerting":
+			// KV v1
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"webhook": "v1-value", "port": 8443}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	for ref, want := range map[string]string{
+		"${vault:secret/data/alerting#webhook}": "v2-value",
+		"${vault:kv/alerting#webhook}":          "v1-value",
+		"${vault:kv/alerting#port}":             "8443",
+	} {
+		got, err := resolveSecrets(ref)
+		if err != nil {
+			t.Errorf("resolveSecrets(%s): %v", ref, err)
+		} else if got != want {
+			t.Errorf("resolveSecrets(%s) = %q, want %q", ref, got, want)
+		}
+	}
+	for _, ref := range []string{"${vault:kv/alerting#missing}", "${vault:kv/other#webhook}"} {
+		if _, err := resolveSecrets(ref); err == nil {
+			t.Errorf("resolveSecrets(%s) succeeded, want an error", ref)
+		}
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := resolveSecrets("${vault:kv/alerting#webhook}"); err == nil || strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("resolveSecrets() error = %v, want one without the response body", err)
+	}
+}
+
+func TestReadAWSSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.SecretId {
+		case "alerting/chat":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"webhook":"https://chat.example.com/hook"}`})
+		case "alerting/token":
+			json.NewEncoder(w).Encode(map[string]string{"SecretString": "t0ken"})
+		default:
+			http.Error(w, "not found", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+
+	for ref, want := range map[string]string{
+		"${aws-sm:alerting/chat#webhook}": "https://chat.example.com/hook",
+		"${aws-sm:alerting/token}":        "t0ken",
+	} {
+		got, err := resolveSecrets(ref)
+		if err != nil {
+			t.Errorf("resolveSecrets(%s): %v", ref, err)
+		} else if got != want {
+			t.Errorf("resolveSecrets(%s) = %q, want %q", ref, got, want)
+		}
+	}
+	for _, ref := range []string{"${aws-sm:alerting/token#webhook}", "${aws-sm:alerting/missing}"} {
+		if _, err := resolveSecrets(ref); err == nil {
+			t.Errorf("resolveSecrets(%s) succeeded, want an error", ref)
+		}
+	}
+}
+
+// TestSignAWSRequest checks the signature against the get-vanilla example of the AWS
+// Signature Version 4 test suite.
+func TestSignAWSRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signAWSRequest(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,500 @@
+package adapter
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// AlertmanagerPayload is the Alertmanager webhook payload (see payload.go for the
+// supported versions). Templates receive it as their data.
+type AlertmanagerPayload struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert is a single alert of the payload.
+type Alert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	Status       string            `json:"status"`
+	StartsAt     string            `json:"startsAt"`
+	EndsAt       string            `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// GoogleChatCard is a simplified structure for a Google Chat Card Message (Text + Cards format).
+type GoogleChatCard struct {
+	Text    string   `json:"text,omitempty"`
+	CardsV2 []CardV2 `json:"cardsV2,omitempty"`
+}
+
+// Run parses the command line and serves webhooks until the process is told to stop.
+func Run() {
+	configPath := flag.String("config", "", "path to the YAML config file (default: read settings from environment variables)")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate for serving HTTPS (requires -tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key for -tls-cert")
+	tlsClientCA := flag.String("tls-client-ca", "", "PEM CA bundle; when set, clients must present a certificate signed by it (mTLS)")
+	flag.Parse()
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fatal("-tls-cert and -tls-key must be given together")
+	}
+	if *tlsClientCA != "" && *tlsCert == "" {
+		fatal("-tls-client-ca requires -tls-cert and -tls-key")
+	}
+
+	// Without -config the legacy environment variables MUST be set in the docker-compose.yml,
+	// e.g. GOOGLE_CHAT_WEBHOOK_URL.
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if err := configureLogging(cfg.Log); err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+
+	// Optional: export OpenTelemetry traces of every webhook and delivery.
+	shutdownTracing, err := setupTracing(cfg.Tracing)
+	if err != nil {
+		fatal("Error setting up tracing", "err", err)
+	}
+	if shutdownTracing != nil {
+		slog.Info("Exporting traces", "endpoint", cfg.Tracing.Endpoint, "service", cfg.Tracing.ServiceName, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+
+	if err := setupHTTPClient(cfg.HTTPClient); err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+
+	// Optional: share dedup and thread state with other replicas through Redis.
+	store, err := newStateStore(cfg.SharedState)
+	if err != nil {
+		fatal("Error opening shared state store", "err", err)
+	}
+	if cfg.SharedState.RedisURL != "" {
+		slog.Info("Sharing dedup, thread and acknowledgement state through Redis", "key_prefix", cfg.SharedState.KeyPrefix)
+	}
+
+	// Optional: acknowledge/silence buttons on Google Chat cards.
+	a := &adapter{threads: newThreadTracker(store), issues: newJiraIssues(store), actions: newAlertActions(cfg.Actions), health: newHealthTracker(cfg.Readiness)}
+	if a.actions != nil {
+		a.mutes = newAlertMutes(store)
+	}
+	if err := a.apply(cfg); err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+
+	// drainCtx stops the background queue drain on shutdown; drained is closed once it has.
+	drainCtx, stopDrain := context.WithCancel(context.Background())
+	drained := make(chan struct{})
+
+	// Optional: the durable outbound queue. Alerts are then acknowledged
+	// as soon as they are stored and delivered in the background.
+	if cfg.QueuePath != "" {
+		queue, err := openOutboundQueue(cfg.QueuePath)
+		if err != nil {
+			fatal("Error opening outbound queue", "err", err)
+		}
+		defer queue.close()
+
+		a.queue = queue
+		go func() {
+			queue.drain(drainCtx, a.deliver, 10*time.Second)
+			close(drained)
+		}()
+		slog.Info("Durable outbound queue enabled", "path", cfg.QueuePath)
+	}
+
+	// Optional: answer webhooks with 202 and dispatch them on a bounded worker pool.
+	if pool := newDeliveryPool(cfg.Workers, a.dispatch); pool != nil {
+		a.pool = pool
+		slog.Info("Delivering alerts on a worker pool", "concurrency", cfg.Workers.Concurrency, "queue_depth", cfg.Workers.QueueDepth)
+	}
+
+	// Optional: keep messages that failed for good, listed and retried through
+	// /api/deadletters.
+	if cfg.DeadLetter.Path != "" {
+		deadLetters, err := openDeadLetterStore(cfg.DeadLetter)
+		if err != nil {
+			fatal("Error opening dead letter store", "err", err)
+		}
+		defer deadLetters.close()
+		a.deadLetters = deadLetters
+		if a.queue != nil {
+			a.queue.rejected = a.deadLetterRejected
+		}
+		http.HandleFunc("/api/deadletters", a.handleDeadLetters)
+		http.HandleFunc("/api/deadletters/", a.handleDeadLetters)
+		slog.Info("Dead letter store enabled", "path", cfg.DeadLetter.Path, "stored", deadLetters.count())
+	}
+
+	// Optional: batch alerts by group key and post one combined message per window.
+	if cfg.GroupWindow > 0 {
+		a.grouper = newAlertGrouper(cfg.GroupWindow, func(payload AlertmanagerPayload) {
+			if err := a.dispatch(context.Background(), payload); err != nil {
+				slog.Error("Error forwarding grouped alerts", "group_key", payload.GroupKey, "err", err)
+			}
+		})
+		slog.Info("Grouping alerts before posting", "window", cfg.GroupWindow.String())
+	}
+
+	// Optional: the alert history and its query API (GET /api/alerts).
+	if cfg.HistoryPath != "" {
+		history, err := openAlertHistory(cfg.HistoryPath)
+		if err != nil {
+			fatal("Error opening alert history", "err", err)
+		}
+		defer history.close()
+		a.history = history
+		http.HandleFunc("/api/alerts", history.handleAlerts)
+		slog.Info("Recording alert history", "path", cfg.HistoryPath)
+	}
+
+	// Optional: the audit log of outbound attempts and its query API (GET /api/audit).
+	if cfg.Audit.Path != "" {
+		audit, err := openAuditLog(cfg.Audit)
+		if err != nil {
+			fatal("Error opening audit log", "err", err)
+		}
+		defer audit.close()
+		a.audit = audit
+		go audit.run()
+		http.HandleFunc("/api/audit", audit.handleAudit)
+		slog.Info("Recording outbound attempts in the audit log", "path", cfg.Audit.Path, "retention", cfg.Audit.Retention.String())
+	}
+
+	// Optional: delivery success ratio and p95 latency per destination over rolling
+	// windows, and a scheduled pipeline health report.
+	slo, err := newDeliverySLO(cfg.SLO)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if slo != nil {
+		a.slo = slo
+		go slo.run(time.Minute)
+		if slo.report != nil {
+			go slo.runReports(a.deliver)
+		}
+		slog.Info("Tracking delivery SLOs", "windows", len(cfg.SLO.Windows), "report", slo.report != nil)
+	}
+
+	// Optional: the web dashboard (/dashboard) of firing alerts, deliveries, silences and GPUs.
+	alertmanagerURL := cfg.SilenceAPI.AlertmanagerURL
+	if alertmanagerURL == "" {
+		alertmanagerURL = cfg.Actions.AlertmanagerURL
+	}
+	dash := newDashboard(cfg.Dashboard, a.history, alertmanagerURL)
+	if dash != nil {
+		http.HandleFunc("/dashboard", dash.handlePage)
+		http.HandleFunc("/api/dashboard", dash.handleState)
+		go dash.run()
+		slog.Info("Dashboard enabled", "collectors", len(cfg.Dashboard.Collectors), "history", a.history != nil)
+	}
+
+	// Optional: create and expire Alertmanager silences by instance (/api/silence).
+	if silences := newSilenceAPI(cfg.SilenceAPI); silences != nil {
+		http.HandleFunc("/api/silence", silences.handle)
+		http.HandleFunc("/api/silence/", silences.handle)
+		slog.Info("Silence API enabled", "alertmanager", cfg.SilenceAPI.AlertmanagerURL)
+	}
+
+	// Optional: drop alerts Alertmanager re-sends unchanged within the TTL.
+	if dedup := newDeduplicator(cfg.DedupTTL, store); dedup != nil {
+		a.dedup = dedup
+		slog.Info("Suppressing duplicate alerts", "ttl", cfg.DedupTTL.String())
+	}
+
+	// Optional: hold back matching alerts during recurring maintenance windows.
+	maintenance, err := newMaintenanceScheduler(cfg.Maintenance)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if maintenance != nil {
+		a.maintenance = maintenance
+		go maintenance.run(30*time.Second, a.summarizeMaintenance)
+		slog.Info("Maintenance windows configured", "windows", len(cfg.Maintenance))
+	}
+
+	// Optional: hold back every alert during alert storms and post summaries instead.
+	if storm := newAlertStorm(cfg.Storm); storm != nil {
+		a.storm = storm
+		go storm.run(5*time.Second, a.summarizeStorm)
+		slog.Info("Alert storm breaker enabled", "threshold", cfg.Storm.Threshold, "window", cfg.Storm.Window.String())
+	}
+
+	// Optional: collect low-severity alerts into one digest message per interval.
+	if digest := newAlertDigest(cfg.Digest); digest != nil {
+		a.digest = digest
+		go digest.run(a.dispatchDigest)
+		slog.Info("Collecting alerts into digests", "severities", strings.Join(cfg.Digest.Severities, ","), "interval", cfg.Digest.Interval.String())
+	}
+
+	// Optional: post still-firing updates and resolution summaries per incident.
+	if timeline := newIncidentTimeline(cfg.Timeline); timeline != nil {
+		a.timeline = timeline
+		go timeline.run(time.Minute, a.postTimeline)
+		slog.Info("Incident timeline enabled", "update_interval", cfg.Timeline.UpdateInterval.String())
+	}
+
+	// Optional: re-post alerts nobody acknowledged, mentioning the on-call, and escalate
+	// them to further backends.
+	escalations, err := newEscalator(cfg.Escalation)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if escalations != nil {
+		a.escalator = escalations
+		go escalations.run(15*time.Second, a.escalate)
+		slog.Info("Escalating unacknowledged alerts", "policies", len(cfg.Escalation))
+	}
+
+	// Optional: token bucket per destination webhook; excess alerts are summarized.
+	if limiter := newRateLimiter(cfg.RateLimit); limiter != nil {
+		a.limiter = limiter
+		go limiter.runSummaries(5*time.Second, a.sendText)
+		slog.Info("Rate limiting outbound messages per webhook", "per_minute", cfg.RateLimit.PerMinute, "burst", cfg.RateLimit.Burst)
+	}
+
+	var webhookHandler http.Handler = http.HandlerFunc(a.handleWebhook)
+	var grafanaHandler http.Handler = http.HandlerFunc(a.handleGrafana)
+	// Optional: Kubernetes Events and node conditions as alerts.
+	kubernetes := newKubernetesInput(cfg.Kubernetes, a.accept)
+	var kubernetesHandler http.Handler = http.HandlerFunc(kubernetes.handle)
+	// Optional: notifications of legacy Zabbix and Nagios/Icinga monitoring.
+	zabbix := newZabbixInput(cfg.Zabbix, a.accept)
+	var zabbixHandler http.Handler = http.HandlerFunc(zabbix.handle)
+	nagios := newNagiosInput(cfg.Nagios, a.accept)
+	var nagiosHandler http.Handler = http.HandlerFunc(nagios.handle)
+
+	// Optional: answer Alertmanager's retries of processed webhooks without posting again.
+	if idempotency := newWebhookIdempotency(cfg.IdempotencyTTL, store); idempotency != nil {
+		webhookHandler = idempotency.wrap(webhookHandler)
+		grafanaHandler = idempotency.wrap(grafanaHandler)
+		kubernetesHandler = idempotency.wrap(kubernetesHandler)
+		zabbixHandler = idempotency.wrap(zabbixHandler)
+		nagiosHandler = idempotency.wrap(nagiosHandler)
+		slog.Info("Recognizing webhook retries", "ttl", cfg.IdempotencyTTL.String())
+	}
+
+	// Optional: require an X-Signature HMAC-SHA256 header on every webhook.
+	if verifier := newSignatureVerifier(cfg.Signature); verifier != nil {
+		webhookHandler = verifier.wrap(webhookHandler)
+		grafanaHandler = verifier.wrap(grafanaHandler)
+		kubernetesHandler = verifier.wrap(kubernetesHandler)
+		zabbixHandler = verifier.wrap(zabbixHandler)
+		nagiosHandler = verifier.wrap(nagiosHandler)
+		if verifier.warnOnly {
+			slog.Info("Webhook signature verification enabled (warn only)")
+		} else {
+			slog.Info("Webhook signature verification enabled")
+		}
+	}
+
+	// Body size and content type limits apply around the signature check, which
+	// buffers the body.
+	limits := newRequestLimits(cfg.Requests)
+	webhookHandler = limits.wrap(webhookHandler)
+	grafanaHandler = limits.wrap(grafanaHandler)
+	kubernetesHandler = limits.wrap(kubernetesHandler)
+	zabbixHandler = limits.wrap(zabbixHandler)
+	nagiosHandler = limits.wrap(nagiosHandler)
+	a.strictPayloads = cfg.Requests.StrictJSON
+
+	// Optional: require basic auth or a bearer token on every webhook. Credentials are
+	// checked before the signature, so unauthenticated requests are never buffered.
+	if auth := newWebhookAuth(cfg.Auth); auth != nil {
+		webhookHandler = auth.wrap(webhookHandler)
+		grafanaHandler = auth.wrap(grafanaHandler)
+		kubernetesHandler = auth.wrap(kubernetesHandler)
+		zabbixHandler = auth.wrap(zabbixHandler)
+		nagiosHandler = auth.wrap(nagiosHandler)
+		slog.Info("Webhook authentication enabled", "basic_auth", cfg.Auth.Username != "", "bearer_token", cfg.Auth.BearerToken != "")
+	}
+
+	// Optional: render payloads for every backend without sending them (/api/preview).
+	preview, err := newMessagePreview(cfg)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if preview != nil {
+		http.Handle("/api/preview", limits.wrap(http.HandlerFunc(preview.handle)))
+		slog.Info("Preview API enabled", "token_required", cfg.Preview.Token != "")
+	}
+
+	// With a config file, routing, templates and retries are reloaded on SIGHUP or
+	// when the config or template file changes.
+	if *configPath != "" {
+		apply := func(next *Config) error {
+			err := a.apply(next)
+			if err == nil && preview != nil {
+				err = preview.apply(next)
+			}
+			if err == nil {
+				err = setLogLevel(next.Log.Level)
+			}
+			return err
+		}
+		go watchConfig([]string{*configPath, cfg.GoogleChat.TemplatePath, cfg.Email.TemplatePath, cfg.Templates.TranslationsPath, cfg.NodeMetadata.File}, func() {
+			next, err := loadConfigFile(*configPath)
+			if err == nil {
+				err = apply(next)
+			}
+			if err != nil {
+				slog.Error("Error reloading config, keeping the previous one", "err", err)
+				return
+			}
+			if changed := restartRequired(cfg, next); len(changed) > 0 {
+				slog.Warn("Config reloaded; some changes take effect after a restart", "restart_required", strings.Join(changed, ", "))
+			} else {
+				slog.Info("Config reloaded")
+			}
+		})
+
+		// Optional: edit routes and templates at runtime (/api/routes, /api/templates).
+		if configAPI := newConfigAPI(cfg.ConfigAPI, *configPath, apply); configAPI != nil {
+			http.HandleFunc("/api/routes", configAPI.handleRoutes)
+			http.HandleFunc("/api/routes/", configAPI.handleRoutes)
+			http.HandleFunc("/api/templates", configAPI.handleTemplates)
+			http.HandleFunc("/api/templates/", configAPI.handleTemplates)
+			slog.Info("Config API enabled", "template_dir", configAPI.templateDir)
+		}
+	}
+
+	// Optional: receive SNMP traps and forward the configured ones as alerts.
+	snmpListener, err := newSNMPTrapListener(cfg.SNMP, a.process)
+	if err != nil {
+		fatal("Error starting SNMP trap listener", "err", err)
+	}
+	if snmpListener != nil {
+		go snmpListener.serve()
+		slog.Info("Receiving SNMP traps", "address", cfg.SNMP.ListenAddress, "traps", len(cfg.SNMP.Traps))
+	}
+
+	// Optional: alert on nodes whose driver or CUDA version differs from the fleet.
+	if drift := newFleetDrift(cfg.VersionDrift, a.process); drift != nil {
+		http.HandleFunc("/api/drift", drift.handleState)
+		go drift.run()
+		slog.Info("Version drift check enabled", "inventories", len(cfg.VersionDrift.Inventories), "interval", cfg.VersionDrift.Interval.String())
+	}
+
+	// Optional: alert on nodes that stop sending heartbeats.
+	if heartbeats := newNodeHeartbeats(cfg.Heartbeat, store, a.process); heartbeats != nil {
+		http.HandleFunc("/api/heartbeat", heartbeats.handle)
+		go heartbeats.run(5 * time.Second)
+		slog.Info("Watching node heartbeats", "nodes", len(cfg.Heartbeat.Nodes), "interval", cfg.Heartbeat.Interval.String(), "missed", cfg.Heartbeat.MissedHeartbeats)
+	}
+
+	// Optional: receive metrics, inventory and alerts streamed by node agents over gRPC.
+	aggregator, err := newNodeAggregator(cfg.Aggregator, a.process)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+	if aggregator != nil {
+		if dash != nil {
+			aggregator.observe = dash.record
+		}
+		http.HandleFunc("/api/aggregator/nodes", aggregator.handleNodes)
+		http.HandleFunc("/api/aggregator/metrics", aggregator.handleMetrics)
+		go func() {
+			if err := aggregator.serve(); err != nil {
+				fatal("Aggregator failed to start", "err", err)
+			}
+		}()
+		slog.Info("Aggregator listening for node agents", "address", cfg.Aggregator.ListenAddress, "client_certificates_required", cfg.Aggregator.ClientCAFile != "")
+	}
+
+	http.Handle("/", traceRequests("webhook", webhookHandler))
+	http.Handle("/grafana", traceRequests("grafana webhook", grafanaHandler))
+	if kubernetes != nil {
+		http.Handle("/kubernetes", traceRequests("kubernetes webhook", kubernetesHandler))
+		slog.Info("Receiving Kubernetes objects", "conditions", len(cfg.Kubernetes.Conditions), "events", len(cfg.Kubernetes.Events))
+	}
+	if zabbix != nil {
+		http.Handle("/zabbix", traceRequests("zabbix webhook", zabbixHandler))
+		slog.Info("Receiving Zabbix notifications")
+	}
+	if nagios != nil {
+		http.Handle("/nagios", traceRequests("nagios webhook", nagiosHandler))
+		slog.Info("Receiving Nagios notifications")
+	}
+	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", a.handleReadyz)
+	if a.actions != nil {
+		http.HandleFunc("/actions", a.handleAction)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	server := &http.Server{Addr: cfg.ListenAddress, Handler: withRequestID(http.DefaultServeMux)}
+	serverErr := make(chan error, 1)
+	if *tlsCert != "" {
+		// Optional: terminate TLS (and verify client certificates) in the adapter itself.
+		server.TLSConfig, err = newServerTLSConfig(*tlsClientCA)
+		if err != nil {
+			fatal("Invalid configuration", "err", err)
+		}
+		go func() {
+			if *tlsClientCA != "" {
+				slog.Info("Google Chat Adapter listening (HTTPS, client certificates required)", "address", cfg.ListenAddress)
+			} else {
+				slog.Info("Google Chat Adapter listening (HTTPS)", "address", cfg.ListenAddress)
+			}
+			serverErr <- server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		}()
+	} else {
+		go func() {
+			slog.Info("Google Chat Adapter listening", "address", cfg.ListenAddress)
+			serverErr <- server.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		fatal("Server failed to start", "err", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining", "timeout", cfg.DrainTimeout.String())
+	if snmpListener != nil {
+		snmpListener.close()
+	}
+	if aggregator != nil {
+		aggregator.close()
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+	a.shutdown(shutdownCtx, server, stopDrain, drained)
+	if shutdownTracing != nil {
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Warn("Error flushing traces", "err", err)
+		}
+	}
+}
+
+// loadConfig reads the config file if one was given and falls back to the environment otherwise.
+func loadConfig(path string) (*Config, error) {
+	if path == "" {
+		return configFromEnv()
+	}
+	return loadConfigFile(path)
+}
@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultSeverities are used when the config lists no severities.
+var defaultSeverities = []SeverityConfig{
+	{Values: []string{"critical"}, Color: "#D93025", TeamsStyle: "attention"},
+	{Values: []string{"warning"}, Color: "#F9AB00", TeamsStyle: "warning"},
+	{Values: []string{"info"}, Color: "#1A73E8", TeamsStyle: "accent"},
+}
+
+const (
+	// firingIcon is shown for firing alerts whose severity has no icon of its own.
+	firingIcon = "🚨"
+	// resolvedIcon is shown for every resolved alert regardless of severity.
+	resolvedIcon = "✅"
+	// unknownSeverityColor is used for severities without a configured color.
+	unknownSeverityColor = "#5F6368"
+	// resolvedColor is used for every resolved alert regardless of severity.
+	resolvedColor = "#188038"
+	// unknownTeamsStyle is the Adaptive Card container style of severities without one.
+	unknownTeamsStyle = "emphasis"
+)
+
+// severityLevel is how alerts of one severity are shown. rank orders the levels, the
+// most severe first.
+type severityLevel struct {
+	rank                    int
+	icon, color, teamsStyle string
+}
+
+// severityScheme maps lower-cased severity label values to their levels.
+type severityScheme map[string]severityLevel
+
+func newSeverityScheme(cfgs []SeverityConfig) severityScheme {
+	if len(cfgs) == 0 {
+		cfgs = defaultSeverities
+	}
+	scheme := make(severityScheme)
+	for rank, c := range cfgs {
+		level := severityLevel{rank: rank, icon: c.Icon, color: c.Color, teamsStyle: c.TeamsStyle}
+		if level.color == "" {
+			level.color = unknownSeverityColor
+		}
+		if level.teamsStyle == "" {
+			level.teamsStyle = unknownTeamsStyle
+		}
+		for _, v := range c.Values {
+			scheme[strings.ToLower(v)] = level
+		}
+	}
+	return scheme
+}
+
+// severities holds the scheme of the active configuration; apply swaps it on reload.
+var severities atomic.Pointer[severityScheme]
+
+// builtinSeverities is used until a configuration has been applied.
+var builtinSeverities = newSeverityScheme(nil)
+
+func setSeverities(cfgs []SeverityConfig) {
+	scheme := newSeverityScheme(cfgs)
+	severities.Store(&scheme)
+}
+
+// severityOf returns the level of a severity label value. Unknown severities rank after
+// every configured one.
+func severityOf(severity string) (severityLevel, bool) {
+	scheme := builtinSeverities
+	if s := severities.Load(); s != nil {
+		scheme = *s
+	}
+	level, ok := scheme[strings.ToLower(severity)]
+	if !ok {
+		return severityLevel{rank: len(scheme), color: unknownSeverityColor, teamsStyle: unknownTeamsStyle}, false
+	}
+	return level, true
+}
+
+// alertAppearance returns the alert's effective status along with the accent color and icon
+// to display it with. Alerts without their own status inherit the payload status.
+func alertAppearance(alert Alert, payloadStatus string) (status, color, icon string) {
+	status = alert.Status
+	if status == "" {
+		status = payloadStatus
+	}
+	if status == "resolved" {
+		return status, resolvedColor, resolvedIcon
+	}
+
+	level, _ := severityOf(alert.Labels["severity"])
+	icon = level.icon
+	if icon == "" {
+		icon = firingIcon
+	}
+	return status, level.color, icon
+}
+
+// payloadIcon is the icon of a whole notification: the icon of its most severe firing
+// alert, or resolvedIcon once everything resolved.
+func payloadIcon(payload AlertmanagerPayload) string {
+	if payload.Status == "resolved" {
+		return resolvedIcon
+	}
+	icon, rank := firingIcon, -1
+	for _, alert := range payload.Alerts {
+		status, _, alertIcon := alertAppearance(alert, payload.Status)
+		if status == "resolved" {
+			continue
+		}
+		if level, _ := severityOf(alert.Labels["severity"]); rank < 0 || level.rank < rank {
+			icon, rank = alertIcon, level.rank
+		}
+	}
+	return icon
+}
+
+// sortBySeverity returns a copy of alerts ordered from the most to the least severe,
+// keeping the received order within a severity.
+func sortBySeverity(alerts []Alert) []Alert {
+	sorted := slices.Clone(alerts)
+	slices.SortStableFunc(sorted, func(a, b Alert) int {
+		x, _ := severityOf(a.Labels["severity"])
+		y, _ := severityOf(b.Labels["severity"])
+		return x.rank - y.rank
+	})
+	return sorted
+}
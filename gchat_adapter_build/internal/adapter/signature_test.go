@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signBody(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignatureVerify(t *testing.T) {
+	v := &signatureVerifier{secret: []byte("s3cret")}
+	body := `{"status":"firing"}`
+	tests := []struct {
+		name    string
+		header  string
+		body    string
+		wantErr bool
+	}{
+		{"valid", signBody("s3cret", body), body, false},
+		{"sha256 prefix", "sha256=" + signBody("s3cret", body), body, false},
+		{"surrounding spaces", " " + signBody("s3cret", body) + " ", body, false},
+		{"missing", "", body, true},
+		{"not hex", "sha256=xyz", body, true},
+		{"other secret", signBody("other", body), body, true},
+		{"tampered body", signBody("s3cret", body), `{"status":"resolved"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.verify(tt.header, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignatureWrap(t *testing.T) {
+	body := `{"status":"firing"}`
+	tests := []struct {
+		name       string
+		warnOnly   bool
+		method     string
+		header     string
+		wantStatus int
+	}{
+		{"valid", false, http.MethodPost, signBody("s3cret", body), http.StatusOK},
+		{"invalid", false, http.MethodPost, signBody("other", body), http.StatusUnauthorized},
+		{"missing", false, http.MethodPost, "", http.StatusUnauthorized},
+		{"invalid in warn mode", true, http.MethodPost, signBody("other", body), http.StatusOK},
+		{"not a post", false, http.MethodGet, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &signatureVerifier{secret: []byte("s3cret"), warnOnly: tt.warnOnly}
+			var got string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+			})
+			req := httptest.NewRequest(tt.method, "/webhook", strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set(signatureHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			v.wrap(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && got != body {
+				t.Errorf("next read body %q, want %q", got, body)
+			}
+		})
+	}
+}
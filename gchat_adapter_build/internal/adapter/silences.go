@@ -0,0 +1,308 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// silenceAPI serves /api/silence, a small proxy in front of Alertmanager's silence API.
+// Callers only name an instance, a duration and a comment, so scripts and users can
+// silence a GPU node without access to Alertmanager itself.
+type silenceAPI struct {
+	alertmanagerURL string
+	token           string
+	maxDuration     time.Duration
+	client          *http.Client
+}
+
+// silenceRequest holds the parameters of POST /api/silence.
+type silenceRequest struct {
+	// Instance is the instance label, with or without its port.
+	Instance  string `json:"instance"`
+	Alertname string `json:"alertname"`
+	Duration  string `json:"duration"`
+	Comment   string `json:"comment"`
+	CreatedBy string `json:"createdBy"`
+}
+
+// silenceSummary is how silences are reported by /api/silence.
+type silenceSummary struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state,omitempty"`
+	Matchers  []string  `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+}
+
+// newSilenceAPI returns nil when the silence API is disabled.
+func newSilenceAPI(cfg SilenceAPIConfig) *silenceAPI {
+	if cfg.AlertmanagerURL == "" {
+		return nil
+	}
+	return &silenceAPI{
+		alertmanagerURL: strings.TrimRight(cfg.AlertmanagerURL, "/"),
+		token:           cfg.Token,
+		maxDuration:     cfg.MaxDuration,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// handle serves POST /api/silence (create), GET /api/silence?instance= (list active
+// silences) and DELETE /api/silence/{id} (expire).
+func (s *silenceAPI) handle(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/silence"), "/")
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		s.create(w, r)
+	case r.Method == http.MethodGet && id == "":
+		s.list(w, r)
+	case r.Method == http.MethodDelete && id != "":
+		s.expire(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *silenceAPI) create(w http.ResponseWriter, r *http.Request) {
+	logger := loggerFrom(r.Context())
+
+	var req silenceRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req = silenceRequest{
+			Instance:  r.FormValue("instance"),
+			Alertname: r.FormValue("alertname"),
+			Duration:  r.FormValue("duration"),
+			Comment:   r.FormValue("comment"),
+			CreatedBy: r.FormValue("createdBy"),
+		}
+	}
+
+	if req.Instance == "" {
+		http.Error(w, "instance is required", http.StatusBadRequest)
+		return
+	}
+	duration := time.Hour
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+		duration = d
+	}
+	if duration > s.maxDuration {
+		http.Error(w, fmt.Sprintf("duration must not exceed %s", s.maxDuration), http.StatusBadRequest)
+		return
+	}
+	if req.Comment == "" {
+		req.Comment = "Silenced through the alertmanager adapter API"
+	}
+	if req.CreatedBy == "" {
+		req.CreatedBy = "alertmanager-adapter"
+	}
+
+	now := time.Now().UTC()
+	silence := alertmanagerSilence{
+		Matchers:  []silenceMatcher{instanceMatcher(req.Instance)},
+		StartsAt:  now,
+		EndsAt:    now.Add(duration),
+		CreatedBy: req.CreatedBy,
+		Comment:   req.Comment,
+	}
+	if req.Alertname != "" {
+		silence.Matchers = append(silence.Matchers, silenceMatcher{Name: "alertname", Value: req.Alertname, IsEqual: true})
+	}
+
+	id, err := postSilence(s.client, s.alertmanagerURL, silence)
+	if err != nil {
+		logger.Error("Error creating silence", "instance", req.Instance, "err", err)
+		http.Error(w, "Error creating silence in Alertmanager", http.StatusBadGateway)
+		return
+	}
+	logger.Info("Created silence", "silence_id", id, "instance", req.Instance, "alertname", req.Alertname,
+		"duration", duration.String(), "created_by", req.CreatedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(summarizeSilence(id, "", silence))
+}
+
+func (s *silenceAPI) list(w http.ResponseWriter, r *http.Request) {
+	silences, err := fetchSilences(s.client, s.alertmanagerURL)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error listing silences", "err", err)
+		http.Error(w, "Error listing silences in Alertmanager", http.StatusBadGateway)
+		return
+	}
+
+	instance := r.URL.Query().Get("instance")
+	summaries := []silenceSummary{}
+	for _, silence := range silences {
+		if instance != "" && !silencesInstance(silence.Matchers, instance) {
+			continue
+		}
+		summaries = append(summaries, summarizeSilence(silence.ID, silence.Status.State, silence.alertmanagerSilence))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// listedSilence is a silence as listed by Alertmanager.
+type listedSilence struct {
+	alertmanagerSilence
+	ID     string `json:"id"`
+	Status struct {
+		State string `json:"state"`
+	} `json:"status"`
+}
+
+// fetchSilences returns Alertmanager's active and pending silences.
+func fetchSilences(client *http.Client, alertmanagerURL string) ([]listedSilence, error) {
+	resp, err := client.Get(alertmanagerURL + "/api/v2/silences")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Alertmanager returned %s", resp.Status)
+	}
+
+	var silences []listedSilence
+	if err := json.NewDecoder(resp.Body).Decode(&silences); err != nil {
+		return nil, fmt.Errorf("decoding silences: %w", err)
+	}
+	current := silences[:0]
+	for _, silence := range silences {
+		if silence.Status.State != "expired" {
+			current = append(current, silence)
+		}
+	}
+	return current, nil
+}
+
+func (s *silenceAPI) expire(w http.ResponseWriter, r *http.Request, id string) {
+	req, err := http.NewRequest(http.MethodDelete, s.alertmanagerURL+"/api/v2/silence/"+url.PathEscape(id), nil)
+	if err != nil {
+		http.Error(w, "Invalid silence ID", http.StatusBadRequest)
+		return
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		loggerFrom(r.Context()).Error("Error expiring silence", "silence_id", id, "err", err)
+		http.Error(w, "Error expiring silence in Alertmanager", http.StatusBadGateway)
+		return
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		http.Error(w, "Silence not found", http.StatusNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		loggerFrom(r.Context()).Error("Error expiring silence", "silence_id", id, "status", resp.Status)
+		http.Error(w, "Error expiring silence in Alertmanager", http.StatusBadGateway)
+	default:
+		loggerFrom(r.Context()).Info("Expired silence", "silence_id", id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// instanceMatcher matches the instance label exactly if a port is given and the host
+// on any port otherwise.
+func instanceMatcher(instance string) silenceMatcher {
+	if _, _, err := net.SplitHostPort(instance); err == nil {
+		return silenceMatcher{Name: "instance", Value: instance, IsEqual: true}
+	}
+	return silenceMatcher{Name: "instance", Value: regexp.QuoteMeta(instance) + "(:[0-9]+)?", IsRegex: true, IsEqual: true}
+}
+
+// silencesInstance reports whether the silence's instance matcher covers the instance
+// (given with or without its port).
+func silencesInstance(matchers []silenceMatcher, instance string) bool {
+	host := instance
+	if h, _, err := net.SplitHostPort(instance); err == nil {
+		host = h
+	}
+	for _, m := range matchers {
+		if m.Name != "instance" || !m.IsEqual {
+			continue
+		}
+		if !m.IsRegex {
+			if m.Value == instance || strings.HasPrefix(m.Value, host+":") || m.Value == host {
+				return true
+			}
+			continue
+		}
+		if re, err := regexp.Compile("^(?:" + m.Value + ")$"); err == nil && (re.MatchString(instance) || re.MatchString(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+func summarizeSilence(id, state string, silence alertmanagerSilence) silenceSummary {
+	summary := silenceSummary{
+		ID:        id,
+		State:     state,
+		Matchers:  []string{},
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+		CreatedBy: silence.CreatedBy,
+		Comment:   silence.Comment,
+	}
+	for _, m := range silence.Matchers {
+		op := "="
+		switch {
+		case m.IsRegex && m.IsEqual:
+			op = "=~"
+		case m.IsRegex:
+			op = "!~"
+		case !m.IsEqual:
+			op = "!="
+		}
+		summary.Matchers = append(summary.Matchers, fmt.Sprintf("%s%s%q", m.Name, op, m.Value))
+	}
+	return summary
+}
+
+// postSilence creates the silence in Alertmanager and returns its ID.
+func postSilence(client *http.Client, alertmanagerURL string, silence alertmanagerSilence) (string, error) {
+	body, err := json.Marshal(silence)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Post(alertmanagerURL+"/api/v2/silences", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating silence: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("creating silence: Alertmanager returned %s", resp.Status)
+	}
+
+	var created struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("decoding silence response: %w", err)
+	}
+	return created.SilenceID, nil
+}
@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"alertmanager-adapter/notifier"
+)
+
+// slackNotifier renders alerts as Slack Block Kit messages for incoming webhooks.
+type slackNotifier struct {
+	router *webhookRouter
+}
+
+// slackMessage is the body of a Slack incoming webhook request. Each alert is an
+// attachment so that it gets a severity colored bar next to its blocks.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func init() {
+	outputRegistry.Register("slack", func(cfg *Config, deps outputDeps) (output, error) {
+		return newSlackNotifier(cfg.Slack, deps.locales)
+	})
+}
+
+func newSlackNotifier(wc WebhookConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(wc, locales)
+	if err != nil {
+		return nil, fmt.Errorf("slack.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no Slack webhook URL is configured")
+	}
+	return &slackNotifier{router: router}, nil
+}
+
+func (n *slackNotifier) Name() string { return "slack" }
+
+func (n *slackNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No Slack webhook configured for alert, dropping it", alertAttr(alert))
+	}
+
+	messages := make([]notifier.Notification, 0, len(routed))
+	for _, group := range routed {
+		m, err := newNotification(n.Name(), group.webhookURL, len(group.payload.Alerts), buildSlackMessage(group.payload, group.localizer))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (n *slackNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *slackNotifier) renderText(webhookURL, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), webhookURL, 0, slackMessage{Text: text})
+}
+
+func (n *slackNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, m.Destination, m.Body); err != nil {
+		return fmt.Errorf("posting to Slack: %w", err)
+	}
+	return nil
+}
+
+func buildSlackMessage(payload AlertmanagerPayload, l *localizer) slackMessage {
+	msg := slackMessage{
+		// Plain text fallback used for notifications and clients without Block Kit support.
+		Text: fmt.Sprintf("%s [%s] %d alert(s)", payloadIcon(payload), strings.ToUpper(l.translate(payload.Status)), len(payload.Alerts)),
+	}
+
+	for _, alert := range payload.Alerts {
+		severity := alert.Labels["severity"]
+		status, color, alertIcon := alertAppearance(alert, payload.Status)
+
+		var fields []slackText
+		fields = appendSlackField(fields, l.translate("Status"), strings.ToUpper(l.translate(status)))
+		fields = appendSlackField(fields, l.translate("Severity"), l.translate(severity))
+		fields = appendSlackField(fields, l.translate("Instance"), alert.Labels["instance"])
+		fields = appendSlackField(fields, l.translate("GPU"), gpuDescription(alert.Labels))
+		fields = appendSlackField(fields, l.translate("Workload"), workloadDescription(alert.Labels))
+		fields = appendSlackField(fields, l.translate("SLURM job"), slurmJobDescription(alert.Labels))
+		fields = appendSlackField(fields, l.translate("Node"), alert.Annotations[nodeInfoAnnotation])
+		fields = appendSlackField(fields, l.translate("GPU health"), alert.Annotations[gpuHealthAnnotation])
+		fields = appendSlackField(fields, l.translate("Top processes"), alert.Annotations[topProcessesAnnotation])
+		fields = appendSlackField(fields, l.translate("Throttling"), alert.Annotations[throttleReasonsAnnotation])
+		fields = appendSlackField(fields, l.translate("Chassis"), alert.Annotations[chassisAnnotation])
+
+		blocks := []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: alertIcon + " " + alert.Labels["alertname"]}},
+			{Type: "section", Fields: fields},
+		}
+		if summary := alert.Annotations["summary"]; summary != "" {
+			blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: summary}})
+		}
+
+		var times []slackText
+		if started := l.formatTime(alert.StartsAt); started != "" {
+			times = append(times, slackText{Type: "mrkdwn", Text: l.translate("Started") + ": " + started})
+		}
+		if ended := l.formatTime(alert.EndsAt); ended != "" {
+			times = append(times, slackText{Type: "mrkdwn", Text: l.translate("Ended") + ": " + ended})
+		}
+		if len(times) > 0 {
+			blocks = append(blocks, slackBlock{Type: "context", Elements: times})
+		}
+
+		msg.Attachments = append(msg.Attachments, slackAttachment{Color: color, Blocks: blocks})
+	}
+	return msg
+}
+
+// appendSlackField adds a "*Label*\nvalue" field, skipping empty values.
+func appendSlackField(fields []slackText, label, value string) []slackText {
+	if value == "" {
+		return fields
+	}
+	return append(fields, slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", label, value)})
+}
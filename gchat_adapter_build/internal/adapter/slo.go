@@ -0,0 +1,314 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alertmanager-adapter/notifier"
+	"github.com/robfig/cron/v3"
+)
+
+// sloBucketWidth is the resolution of the rolling windows.
+const sloBucketWidth = 5 * time.Minute
+
+// sloReportSchedule is the default schedule of the report: Mondays at 09:00.
+const sloReportSchedule = "0 9 * * 1"
+
+// deliverySLO tracks the success ratio and p95 latency of deliveries per backend and
+// destination over rolling windows, exposed as gauges so the pipeline's own SLOs can be
+// alerted on. A delivery is a message's final outcome after retries; its latency runs
+// from rendering to delivery, like alertmanager_adapter_forward_latency_seconds.
+// Destinations are shown by host and a short hash of their path, so every Chat space or
+// Slack channel is told apart without exposing webhook secrets. The windows cover the
+// deliveries of this replica since it started.
+type deliverySLO struct {
+	windows []time.Duration
+	report  *sloReport
+
+	mu     sync.Mutex
+	series map[sloSeries][]*sloBucket
+}
+
+type sloSeries struct {
+	backend, destination string
+}
+
+// sloBucket counts the deliveries of one sloBucketWidth. latency is a histogram of the
+// successful ones over forwardLatencyBuckets, with a last bucket for slower ones.
+type sloBucket struct {
+	start     time.Time
+	delivered int
+	failed    int
+	latency   []int
+}
+
+// sloStats are the deliveries of one series within a window.
+type sloStats struct {
+	sloSeries
+	delivered, failed int
+	// p95 is the 95th percentile latency of the successful deliveries.
+	p95 time.Duration
+}
+
+// sloReport posts a pipeline health card to a Google Chat space on a schedule.
+type sloReport struct {
+	webhookURL string
+	schedule   cron.Schedule
+}
+
+// newDeliverySLO returns nil when no window is configured. The config has already been
+// checked by Config.validate.
+func newDeliverySLO(cfg SLOConfig) (*deliverySLO, error) {
+	if len(cfg.Windows) == 0 {
+		return nil, nil
+	}
+	windows := append([]time.Duration(nil), cfg.Windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	s := &deliverySLO{windows: windows, series: make(map[sloSeries][]*sloBucket)}
+	if cfg.Report.WebhookURL != "" {
+		schedule, err := parseSLOSchedule(cfg.Report)
+		if err != nil {
+			return nil, err
+		}
+		s.report = &sloReport{webhookURL: cfg.Report.WebhookURL, schedule: schedule}
+	}
+	return s, nil
+}
+
+func parseSLOSchedule(cfg SLOReportConfig) (cron.Schedule, error) {
+	timezone := cfg.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("slo.report: %w", err)
+	}
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	expr := cfg.Schedule
+	if expr == "" {
+		expr = sloReportSchedule
+	}
+	schedule, err := parser.Parse("CRON_TZ=" + timezone + " " + expr)
+	if err != nil {
+		return nil, fmt.Errorf("slo.report: invalid schedule %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// record notes the final outcome of a delivery.
+func (s *deliverySLO) record(backend, destination string, latency time.Duration, err error) {
+	key := sloSeries{backend, sloDestination(backend, destination)}
+	now := time.Now()
+	start := now.Truncate(sloBucketWidth)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets := s.series[key]
+	if len(buckets) == 0 || buckets[len(buckets)-1].start != start {
+		buckets = append(buckets, &sloBucket{start: start, latency: make([]int, len(forwardLatencyBuckets)+1)})
+		s.series[key] = buckets
+	}
+	b := buckets[len(buckets)-1]
+	if err != nil {
+		b.failed++
+		return
+	}
+	b.delivered++
+	b.latency[sort.SearchFloat64s(forwardLatencyBuckets, latency.Seconds())]++
+}
+
+// run refreshes the gauges and forgets the deliveries older than the longest window
+// every interval.
+func (s *deliverySLO) run(interval time.Duration) {
+	for {
+		s.refresh(time.Now())
+		time.Sleep(interval)
+	}
+}
+
+func (s *deliverySLO) refresh(now time.Time) {
+	s.prune(now)
+	deliverySuccessRatio.Reset()
+	deliveryLatencyP95.Reset()
+	for _, window := range s.windows {
+		label := sloWindowLabel(window)
+		for _, st := range s.stats(window, now) {
+			if total := st.delivered + st.failed; total > 0 {
+				deliverySuccessRatio.WithLabelValues(st.backend, st.destination, label).Set(float64(st.delivered) / float64(total))
+			}
+			if st.delivered > 0 {
+				deliveryLatencyP95.WithLabelValues(st.backend, st.destination, label).Set(st.p95.Seconds())
+			}
+		}
+	}
+}
+
+func (s *deliverySLO) prune(now time.Time) {
+	oldest := now.Add(-s.windows[len(s.windows)-1] - sloBucketWidth)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, buckets := range s.series {
+		i := sort.Search(len(buckets), func(i int) bool { return buckets[i].start.After(oldest) })
+		if i == len(buckets) {
+			delete(s.series, key)
+		} else if i > 0 {
+			s.series[key] = append([]*sloBucket(nil), buckets[i:]...)
+		}
+	}
+}
+
+// stats sums the buckets that started within the window, by series.
+func (s *deliverySLO) stats(window time.Duration, now time.Time) []sloStats {
+	since := now.Add(-window)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]sloStats, 0, len(s.series))
+	for key, buckets := range s.series {
+		st := sloStats{sloSeries: key}
+		latency := make([]int, len(forwardLatencyBuckets)+1)
+		for _, b := range buckets {
+			if b.start.Before(since) {
+				continue
+			}
+			st.delivered += b.delivered
+			st.failed += b.failed
+			for i, n := range b.latency {
+				latency[i] += n
+			}
+		}
+		if st.delivered+st.failed == 0 {
+			continue
+		}
+		st.p95 = histogramQuantile(0.95, latency)
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].backend != stats[j].backend {
+			return stats[i].backend < stats[j].backend
+		}
+		return stats[i].destination < stats[j].destination
+	})
+	return stats
+}
+
+// histogramQuantile estimates the quantile of the latencies counted over
+// forwardLatencyBuckets by linear interpolation within the bucket it falls into, like
+// PromQL's histogram_quantile. The last bucket is capped at the largest bound.
+func histogramQuantile(q float64, counts []int) time.Duration {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	cumulative := 0
+	for i, n := range counts {
+		if n == 0 || float64(cumulative+n) < rank {
+			cumulative += n
+			continue
+		}
+		if i == len(forwardLatencyBuckets) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = forwardLatencyBuckets[i-1]
+		}
+		upper := forwardLatencyBuckets[i]
+		seconds := lower + (upper-lower)*(rank-float64(cumulative))/float64(n)
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return time.Duration(forwardLatencyBuckets[len(forwardLatencyBuckets)-1] * float64(time.Second))
+}
+
+// sloDestination names a destination in the SLO metrics: a URL by its host and a hash
+// of its path (the query, with the Chat keys and thread, is left out), email by its
+// recipients and anything else, such as an API key, by a hash.
+func sloDestination(backend, destination string) string {
+	if u, err := url.Parse(destination); err == nil && u.Host != "" {
+		hash := sha256.Sum256([]byte(u.Host + u.Path))
+		return u.Host + "#" + hex.EncodeToString(hash[:4])
+	}
+	return auditDestination(backend, destination)
+}
+
+// sloWindowLabel formats a window as in "1h" or "7d".
+func sloWindowLabel(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// runReports posts the pipeline health card on the report's schedule.
+func (s *deliverySLO) runReports(deliver func(context.Context, notifier.Notification) error) {
+	for {
+		now := time.Now()
+		time.Sleep(s.report.schedule.Next(now).Sub(now))
+		m, err := s.reportMessage(time.Now())
+		if err == nil {
+			err = deliver(context.Background(), m)
+		}
+		if err != nil {
+			slog.Error("Error posting delivery SLO report", "err", err)
+		}
+	}
+}
+
+// reportMessage builds the pipeline health card over the longest window: the overall
+// success ratio, then one line per destination, grouped by backend.
+func (s *deliverySLO) reportMessage(now time.Time) (notifier.Notification, error) {
+	window := s.windows[len(s.windows)-1]
+	stats := s.stats(window, now)
+
+	delivered, failed := 0, 0
+	var sections []CardSection
+	for _, st := range stats {
+		delivered += st.delivered
+		failed += st.failed
+		if len(sections) == 0 || sections[len(sections)-1].Header != st.backend {
+			sections = append(sections, CardSection{Header: st.backend})
+		}
+		section := &sections[len(sections)-1]
+		section.Widgets = append(section.Widgets, CardWidget{DecoratedText: &DecoratedText{
+			TopLabel: st.destination,
+			Text:     sloSummary(st.delivered, st.failed, st.p95),
+			WrapText: true,
+		}})
+	}
+	subtitle := "No deliveries"
+	if delivered+failed > 0 {
+		subtitle = fmt.Sprintf("%.2f%% of %d messages delivered", 100*float64(delivered)/float64(delivered+failed), delivered+failed)
+	}
+	card := GoogleChatCard{CardsV2: []CardV2{{
+		CardID: "delivery-slo",
+		Card: Card{
+			Header:   &CardHeader{Title: "Notification pipeline health, last " + sloWindowLabel(window), Subtitle: subtitle},
+			Sections: sections,
+		},
+	}}}
+	return newNotification("gchat", s.report.webhookURL, 0, card)
+}
+
+func sloSummary(delivered, failed int, p95 time.Duration) string {
+	parts := []string{fmt.Sprintf("%.2f%% delivered (%d of %d)", 100*float64(delivered)/float64(delivered+failed), delivered, delivered+failed)}
+	if delivered > 0 {
+		parts = append(parts, "p95 "+p95.Round(10*time.Millisecond).String())
+	}
+	return strings.Join(parts, " · ")
+}
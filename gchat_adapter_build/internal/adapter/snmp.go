@@ -0,0 +1,574 @@
+package adapter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file decodes just enough of SNMP (RFC 3416 PDUs in RFC 1157/1901 and RFC 3412
+// messages, with the RFC 3414 user-based security model and RFC 3826 AES) to receive
+// SNMPv2c traps and informs and SNMPv3 traps.
+
+// BER tags used by SNMP.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berIPAddress   = 0x40
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berOpaque      = 0x44
+	berCounter64   = 0x46
+
+	pduResponse = 0xa2
+	pduInform   = 0xa6
+	pduTrapV2   = 0xa7
+)
+
+// SNMP message versions as encoded on the wire.
+const (
+	snmpVersion2c = 1
+	snmpVersion3  = 3
+)
+
+// Well-known variable bindings of every notification.
+const (
+	oidSysUpTime   = "1.3.6.1.2.1.1.3.0"
+	oidSNMPTrapOID = "1.3.6.1.6.3.1.1.4.1.0"
+	// oidSNMPTrapAddress is set by proxies forwarding traps for another agent.
+	oidSNMPTrapAddress = "1.3.6.1.6.3.18.1.3.0"
+)
+
+// msgFlags bits of SNMPv3 messages.
+const (
+	snmpFlagAuth = 0x01
+	snmpFlagPriv = 0x02
+)
+
+// berElement is one decoded TLV; start and end delimit its contents in the buffer it
+// was read from.
+type berElement struct {
+	tag        byte
+	start, end int
+}
+
+// readBER reads the element at off of buf and returns it with the offset after it.
+func readBER(buf []byte, off int) (berElement, int, error) {
+	if off+2 > len(buf) {
+		return berElement{}, 0, errors.New("truncated BER element")
+	}
+	tag := buf[off]
+	length := int(buf[off+1])
+	off += 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || off+n > len(buf) {
+			return berElement{}, 0, errors.New("invalid BER length")
+		}
+		length = 0
+		for _, b := range buf[off : off+n] {
+			length = length<<8 | int(b)
+		}
+		off += n
+	}
+	if length < 0 || off+length > len(buf) {
+		return berElement{}, 0, errors.New("BER length exceeds the message")
+	}
+	return berElement{tag: tag, start: off, end: off + length}, off + length, nil
+}
+
+// berReader reads the consecutive elements of a constructed element.
+type berReader struct {
+	buf      []byte
+	off, end int
+}
+
+func newBERReader(buf []byte, e berElement) *berReader {
+	return &berReader{buf: buf, off: e.start, end: e.end}
+}
+
+func (r *berReader) next(wantTag byte) (berElement, error) {
+	if r.off >= r.end {
+		return berElement{}, errors.New("missing BER element")
+	}
+	e, next, err := readBER(r.buf[:r.end], r.off)
+	if err != nil {
+		return berElement{}, err
+	}
+	if wantTag != 0 && e.tag != wantTag {
+		return berElement{}, fmt.Errorf("unexpected BER tag 0x%02x (expected 0x%02x)", e.tag, wantTag)
+	}
+	r.off = next
+	return e, nil
+}
+
+func (r *berReader) more() bool { return r.off < r.end }
+
+func (r *berReader) integer() (int64, error) {
+	e, err := r.next(berInteger)
+	if err != nil {
+		return 0, err
+	}
+	return berInt(r.buf[e.start:e.end])
+}
+
+func (r *berReader) octets() ([]byte, error) {
+	e, err := r.next(berOctetString)
+	if err != nil {
+		return nil, err
+	}
+	return r.buf[e.start:e.end], nil
+}
+
+func berInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, errors.New("invalid BER integer")
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func berOIDString(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", errors.New("empty OID")
+	}
+	var parts []string
+	var v uint64
+	for i, c := range b {
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return "", errors.New("truncated OID")
+			}
+			continue
+		}
+		if parts == nil {
+			// The first subidentifier encodes the first two arcs.
+			first := min(v/40, 2)
+			parts = append(parts, strconv.FormatUint(first, 10), strconv.FormatUint(v-first*40, 10))
+		} else {
+			parts = append(parts, strconv.FormatUint(v, 10))
+		}
+		v = 0
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// snmpVarbind is one variable binding with its value formatted as text.
+type snmpVarbind struct {
+	oid   string
+	value string
+}
+
+// formatBERValue renders a varbind value: numbers in decimal, OIDs dotted, octet strings
+// as text when they are printable and in hex otherwise.
+func formatBERValue(tag byte, b []byte) (string, error) {
+	switch tag {
+	case berInteger:
+		v, err := berInt(b)
+		return strconv.FormatInt(v, 10), err
+	case berCounter32, berGauge32, berTimeTicks, berCounter64:
+		if len(b) > 9 {
+			return "", errors.New("invalid BER unsigned integer")
+		}
+		return new(big.Int).SetBytes(b).String(), nil
+	case berOctetString, berOpaque:
+		if utf8.Valid(b) && !strings.ContainsFunc(string(b), func(r rune) bool { return r < 0x20 && r != '\t' && r != '\n' && r != '\r' }) {
+			return strings.TrimRight(string(b), "\x00"), nil
+		}
+		return hex.EncodeToString(b), nil
+	case berOID:
+		return berOIDString(b)
+	case berIPAddress:
+		if len(b) != 4 {
+			return "", errors.New("invalid IpAddress")
+		}
+		return net.IP(b).String(), nil
+	case berNull, 0x80, 0x81, 0x82:
+		// NULL, noSuchObject, noSuchInstance and endOfMibView carry no value.
+		return "", nil
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// snmpPDU is a decoded notification.
+type snmpPDU struct {
+	tag       byte
+	requestID int64
+	varbinds  []snmpVarbind
+	// offset is the position of the PDU tag in the message, for answering informs.
+	offset int
+}
+
+// trapOID returns the snmpTrapOID.0 of the notification.
+func (p snmpPDU) trapOID() string {
+	return p.varbind(oidSNMPTrapOID)
+}
+
+func (p snmpPDU) varbind(oid string) string {
+	for _, vb := range p.varbinds {
+		if vb.oid == oid {
+			return vb.value
+		}
+	}
+	return ""
+}
+
+func readPDU(buf []byte, e berElement, offset int) (snmpPDU, error) {
+	if e.tag != pduTrapV2 && e.tag != pduInform {
+		return snmpPDU{}, fmt.Errorf("unsupported PDU type 0x%02x (expected an SNMPv2 trap or inform)", e.tag)
+	}
+	pdu := snmpPDU{tag: e.tag, offset: offset}
+	r := newBERReader(buf, e)
+	var err error
+	if pdu.requestID, err = r.integer(); err != nil {
+		return snmpPDU{}, err
+	}
+	// error-status and error-index are always 0 in notifications.
+	if _, err := r.integer(); err != nil {
+		return snmpPDU{}, err
+	}
+	if _, err := r.integer(); err != nil {
+		return snmpPDU{}, err
+	}
+	list, err := r.next(berSequence)
+	if err != nil {
+		return snmpPDU{}, err
+	}
+	vbs := newBERReader(buf, list)
+	for vbs.more() {
+		seq, err := vbs.next(berSequence)
+		if err != nil {
+			return snmpPDU{}, err
+		}
+		vr := newBERReader(buf, seq)
+		name, err := vr.next(berOID)
+		if err != nil {
+			return snmpPDU{}, err
+		}
+		oid, err := berOIDString(buf[name.start:name.end])
+		if err != nil {
+			return snmpPDU{}, err
+		}
+		value, err := vr.next(0)
+		if err != nil {
+			return snmpPDU{}, err
+		}
+		text, err := formatBERValue(value.tag, buf[value.start:value.end])
+		if err != nil {
+			return snmpPDU{}, fmt.Errorf("varbind %s: %w", oid, err)
+		}
+		pdu.varbinds = append(pdu.varbinds, snmpVarbind{oid: oid, value: text})
+	}
+	return pdu, nil
+}
+
+// snmpMessage is a received message after authentication and decryption.
+type snmpMessage struct {
+	version int64
+	// community is set for SNMPv2c, user for SNMPv3.
+	community string
+	user      string
+	pdu       snmpPDU
+}
+
+// errSNMPAuth marks messages rejected for their community or credentials.
+var errSNMPAuth = errors.New("SNMP authentication failed")
+
+// snmpUser is an SNMPv3 user whose passwords have been turned into keys (RFC 3414 A.2.1);
+// the keys are localized to the sending engine per message.
+type snmpUser struct {
+	name    string
+	newHash func() hash.Hash
+	authKey []byte
+	priv    string
+	privKey []byte
+}
+
+func newSNMPUser(cfg SNMPUserConfig) snmpUser {
+	u := snmpUser{name: cfg.Username, priv: strings.ToUpper(cfg.PrivProtocol)}
+	switch strings.ToUpper(cfg.AuthProtocol) {
+	case "MD5":
+		u.newHash = md5.New
+	case "SHA":
+		u.newHash = sha1.New
+	default:
+		return u
+	}
+	u.authKey = snmpPasswordToKey(u.newHash, cfg.AuthPassword)
+	if u.priv != "" {
+		u.privKey = snmpPasswordToKey(u.newHash, cfg.PrivPassword)
+	}
+	return u
+}
+
+// snmpPasswordToKey hashes a megabyte of the repeated password.
+func snmpPasswordToKey(newHash func() hash.Hash, password string) []byte {
+	h := newHash()
+	chunk := make([]byte, 64)
+	for written := 0; written < 1<<20; written += len(chunk) {
+		for i := range chunk {
+			chunk[i] = password[(written+i)%len(password)]
+		}
+		h.Write(chunk)
+	}
+	return h.Sum(nil)
+}
+
+// localize derives the key of an engine from a user key (RFC 3414 A.2.2).
+func (u snmpUser) localize(key, engineID []byte) []byte {
+	h := u.newHash()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// snmpDecoder parses messages and checks their community or USM credentials.
+type snmpDecoder struct {
+	communities []string
+	users       map[string]snmpUser
+}
+
+func (d *snmpDecoder) decode(buf []byte) (snmpMessage, error) {
+	msg, next, err := readBER(buf, 0)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	if msg.tag != berSequence || next != len(buf) {
+		return snmpMessage{}, errors.New("not an SNMP message")
+	}
+	r := newBERReader(buf, msg)
+	version, err := r.integer()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	switch version {
+	case snmpVersion2c:
+		return d.decodeV2c(buf, r)
+	case snmpVersion3:
+		return d.decodeV3(buf, r)
+	}
+	return snmpMessage{}, fmt.Errorf("unsupported SNMP version %d (SNMPv2c and SNMPv3 are supported)", version+1)
+}
+
+func (d *snmpDecoder) decodeV2c(buf []byte, r *berReader) (snmpMessage, error) {
+	community, err := r.octets()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	accepted := false
+	for _, c := range d.communities {
+		accepted = accepted || subtle.ConstantTimeCompare(community, []byte(c)) == 1
+	}
+	if !accepted {
+		return snmpMessage{}, fmt.Errorf("%w: unknown community", errSNMPAuth)
+	}
+	offset := r.off
+	e, err := r.next(0)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	pdu, err := readPDU(buf, e, offset)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	return snmpMessage{version: snmpVersion2c, community: string(community), pdu: pdu}, nil
+}
+
+func (d *snmpDecoder) decodeV3(buf []byte, r *berReader) (snmpMessage, error) {
+	header, err := r.next(berSequence)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	hr := newBERReader(buf, header)
+	if _, err := hr.integer(); err != nil { // msgID
+		return snmpMessage{}, err
+	}
+	if _, err := hr.integer(); err != nil { // msgMaxSize
+		return snmpMessage{}, err
+	}
+	flags, err := hr.octets()
+	if err != nil || len(flags) != 1 {
+		return snmpMessage{}, errors.New("invalid msgFlags")
+	}
+	model, err := hr.integer()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	if model != 3 {
+		return snmpMessage{}, fmt.Errorf("unsupported security model %d (expected USM)", model)
+	}
+
+	params, err := r.next(berOctetString)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	usm, _, err := readBER(buf[:params.end], params.start)
+	if err != nil || usm.tag != berSequence {
+		return snmpMessage{}, errors.New("invalid USM security parameters")
+	}
+	ur := newBERReader(buf, usm)
+	engineID, err := ur.octets()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	boots, err := ur.integer()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	engineTime, err := ur.integer()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	userName, err := ur.octets()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	authParams, err := ur.next(berOctetString)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	privParams, err := ur.octets()
+	if err != nil {
+		return snmpMessage{}, err
+	}
+
+	user, ok := d.users[string(userName)]
+	if !ok {
+		return snmpMessage{}, fmt.Errorf("%w: unknown user %q", errSNMPAuth, userName)
+	}
+	authenticated, encrypted := flags[0]&snmpFlagAuth != 0, flags[0]&snmpFlagPriv != 0
+	if authenticated != (user.authKey != nil) || encrypted != (user.privKey != nil) {
+		return snmpMessage{}, fmt.Errorf("%w: security level of user %q does not match", errSNMPAuth, userName)
+	}
+	if authenticated {
+		if err := user.verify(buf, authParams, engineID); err != nil {
+			return snmpMessage{}, err
+		}
+	}
+
+	data, err := r.next(0)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	scoped, scopedBuf := data, buf
+	if encrypted {
+		if data.tag != berOctetString {
+			return snmpMessage{}, errors.New("encrypted PDU expected")
+		}
+		plain, err := user.decrypt(buf[data.start:data.end], user.localize(user.privKey, engineID), privParams, boots, engineTime)
+		if err != nil {
+			return snmpMessage{}, err
+		}
+		// Block ciphers pad the plaintext, so only the leading element counts.
+		if scoped, _, err = readBER(plain, 0); err != nil {
+			return snmpMessage{}, fmt.Errorf("decrypting PDU: %w", err)
+		}
+		scopedBuf = plain
+	}
+	if scoped.tag != berSequence {
+		return snmpMessage{}, errors.New("invalid scoped PDU")
+	}
+	sr := newBERReader(scopedBuf, scoped)
+	if _, err := sr.octets(); err != nil { // contextEngineID
+		return snmpMessage{}, err
+	}
+	if _, err := sr.octets(); err != nil { // contextName
+		return snmpMessage{}, err
+	}
+	e, err := sr.next(0)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	if e.tag == pduInform {
+		// Answering would need the adapter to act as authoritative engine.
+		return snmpMessage{}, errors.New("SNMPv3 informs are not supported, send traps")
+	}
+	pdu, err := readPDU(scopedBuf, e, -1)
+	if err != nil {
+		return snmpMessage{}, err
+	}
+	return snmpMessage{version: snmpVersion3, user: user.name, pdu: pdu}, nil
+}
+
+// verify checks the HMAC-96 of a message, computed with the auth parameters zeroed.
+func (u snmpUser) verify(buf []byte, authParams berElement, engineID []byte) error {
+	if authParams.end-authParams.start != 12 {
+		return fmt.Errorf("%w: invalid authentication parameters", errSNMPAuth)
+	}
+	got := append([]byte(nil), buf[authParams.start:authParams.end]...)
+	zeroed := append([]byte(nil), buf...)
+	clear(zeroed[authParams.start:authParams.end])
+	mac := hmac.New(u.newHash, u.localize(u.authKey, engineID))
+	mac.Write(zeroed)
+	if !hmac.Equal(got, mac.Sum(nil)[:12]) {
+		return fmt.Errorf("%w: wrong digest for user %q", errSNMPAuth, u.name)
+	}
+	return nil
+}
+
+// decrypt decrypts a scoped PDU with DES-CBC (RFC 3414 8.1.1) or AES-128-CFB (RFC 3826).
+func (u snmpUser) decrypt(data, key, salt []byte, boots, engineTime int64) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, errors.New("invalid privacy parameters")
+	}
+	plain := make([]byte, len(data))
+	switch u.priv {
+	case "DES":
+		if len(data)%des.BlockSize != 0 || len(key) < 16 {
+			return nil, errors.New("invalid DES encrypted PDU")
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 8)
+		for i := range iv {
+			iv[i] = key[8+i] ^ salt[i]
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	case "AES":
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 16)
+		binary.BigEndian.PutUint32(iv[0:], uint32(boots))
+		binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+		copy(iv[8:], salt)
+		cipher.NewCFBDecrypter(block, iv).XORKeyStream(plain, data)
+	default:
+		return nil, fmt.Errorf("unsupported privacy protocol %q", u.priv)
+	}
+	return plain, nil
+}
+
+// informResponse returns the Response-PDU acknowledging an SNMPv2c inform: the same
+// message with the PDU type changed, as error-status and error-index are already 0.
+func informResponse(buf []byte, msg snmpMessage) []byte {
+	response := append([]byte(nil), buf...)
+	response[msg.pdu.offset] = pduResponse
+	return response
+}
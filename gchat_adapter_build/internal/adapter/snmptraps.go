@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// snmpMaxMessageSize is the largest trap read; SNMP over UDP stays well below it.
+const snmpMaxMessageSize = 65507
+
+// snmpTrapListener receives SNMP traps from facility equipment (PDUs, switches, BMCs)
+// and runs those with a configured trap OID through the alert pipeline, so facility
+// events reach the same spaces as GPU alerts. The instance label is the sending
+// device's address, or the snmpTrapAddress.0 a proxy forwarded the trap for.
+type snmpTrapListener struct {
+	conn    net.PacketConn
+	decoder *snmpDecoder
+	traps   map[string]SNMPTrapConfig
+	process func(context.Context, AlertmanagerPayload) (string, error)
+}
+
+// newSNMPTrapListener returns nil when the listener is disabled. The config has already
+// been checked by Config.validate.
+func newSNMPTrapListener(cfg SNMPConfig, process func(context.Context, AlertmanagerPayload) (string, error)) (*snmpTrapListener, error) {
+	if cfg.ListenAddress == "" {
+		return nil, nil
+	}
+	conn, err := net.ListenPacket("udp", cfg.ListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	decoder := &snmpDecoder{communities: cfg.Communities, users: make(map[string]snmpUser)}
+	for _, u := range cfg.Users {
+		decoder.users[u.Username] = newSNMPUser(u)
+	}
+	traps := make(map[string]SNMPTrapConfig, len(cfg.Traps))
+	for _, t := range cfg.Traps {
+		traps[strings.TrimPrefix(t.OID, ".")] = t
+	}
+	return &snmpTrapListener{conn: conn, decoder: decoder, traps: traps, process: process}, nil
+}
+
+// serve handles traps until the listener is closed.
+func (l *snmpTrapListener) serve() {
+	buf := make([]byte, snmpMaxMessageSize)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Error("Error reading SNMP trap", "err", err)
+			continue
+		}
+		l.handle(append([]byte(nil), buf[:n]...), addr)
+	}
+}
+
+func (l *snmpTrapListener) close() error {
+	return l.conn.Close()
+}
+
+func (l *snmpTrapListener) handle(buf []byte, addr net.Addr) {
+	logger := slog.With("remote_addr", addr.String())
+	msg, err := l.decoder.decode(buf)
+	if err != nil {
+		result := "malformed"
+		if errors.Is(err, errSNMPAuth) {
+			result = "unauthenticated"
+		}
+		snmpTraps.WithLabelValues(result).Inc()
+		logger.Warn("Rejecting SNMP trap", "err", err)
+		return
+	}
+	if msg.pdu.tag == pduInform {
+		// The sender retries an inform until it is answered, whatever becomes of it.
+		if _, err := l.conn.WriteTo(informResponse(buf, msg), addr); err != nil {
+			logger.Warn("Error answering SNMP inform", "err", err)
+		}
+	}
+
+	trapOID := msg.pdu.trapOID()
+	trap, ok := l.traps[trapOID]
+	if !ok {
+		snmpTraps.WithLabelValues("unknown_oid").Inc()
+		logger.Debug("Dropping SNMP trap without a translation", "trap_oid", trapOID)
+		return
+	}
+	snmpTraps.WithLabelValues("accepted").Inc()
+
+	instance := msg.pdu.varbind(oidSNMPTrapAddress)
+	if instance == "" {
+		instance, _, _ = net.SplitHostPort(addr.String())
+	}
+	alert := snmpTrapAlert(trap, msg.pdu, instance, time.Now())
+	logger.Info("SNMP trap received", alertAttr(alert), "trap_oid", trapOID)
+	payload := AlertmanagerPayload{
+		GroupKey:     "snmp/" + instance + "/" + trap.Alertname,
+		Status:       alert.Status,
+		Receiver:     "snmp",
+		GroupLabels:  map[string]string{"alertname": trap.Alertname, "instance": instance},
+		CommonLabels: alert.Labels,
+		Alerts:       []Alert{alert},
+	}
+	ctx := context.WithValue(context.Background(), loggerKey{}, logger)
+	if _, err := l.process(ctx, payload); err != nil {
+		logger.Error("Error forwarding SNMP trap", alertAttr(alert), "err", err)
+	}
+}
+
+// snmpTrapAlert translates a trap into an alert. The fingerprint covers the alertname,
+// instance and varbind labels but not the configured labels and severity, so a
+// resolving trap only needs the same varbindLabels to resolve the firing alert.
+func snmpTrapAlert(trap SNMPTrapConfig, pdu snmpPDU, instance string, now time.Time) Alert {
+	labels := map[string]string{"alertname": trap.Alertname, "instance": instance, "job": "snmp"}
+	for name, oid := range trap.VarbindLabels {
+		oid = strings.TrimPrefix(oid, ".")
+		for _, vb := range pdu.varbinds {
+			if vb.oid == oid || strings.HasPrefix(vb.oid, oid+".") {
+				labels[name] = vb.value
+				break
+			}
+		}
+	}
+	fingerprint := labelsFingerprint(labels)
+	for name, value := range trap.Labels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = value
+		}
+	}
+	if trap.Severity != "" {
+		labels["severity"] = trap.Severity
+	}
+
+	alert := Alert{
+		Labels:      labels,
+		Annotations: map[string]string{"description": snmpVarbindsText(pdu)},
+		Status:      "firing",
+		StartsAt:    now.UTC().Format(time.RFC3339),
+		Fingerprint: fingerprint,
+	}
+	if trap.Summary != "" {
+		alert.Annotations["summary"] = expandLabels(trap.Summary, labels)
+	}
+	if trap.Resolves {
+		alert.Status = "resolved"
+		alert.EndsAt = alert.StartsAt
+	}
+	return alert
+}
+
+var labelPlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// expandLabels replaces "{name}" in s by the value of label name.
+func expandLabels(s string, labels map[string]string) string {
+	return labelPlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+		return labels[m[1:len(m)-1]]
+	})
+}
+
+// snmpVarbindsText lists the trap's variable bindings, except the two every
+// notification starts with, one "oid = value" per line.
+func snmpVarbindsText(pdu snmpPDU) string {
+	var lines []string
+	for _, vb := range pdu.varbinds {
+		if vb.oid != oidSysUpTime && vb.oid != oidSNMPTrapOID {
+			lines = append(lines, fmt.Sprintf("%s = %s", vb.oid, vb.value))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,145 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// stateStore holds the state that replicas of the adapter must agree on, such as which
+// alerts were already forwarded and which Google Chat thread an incident uses. A single
+// replica keeps it in memory; several replicas behind one Service share it through Redis
+// so that they neither post the same alert twice nor break thread correlation.
+type stateStore interface {
+	// setNX stores value under key for ttl unless the key exists, and reports whether
+	// it did. Exactly one of several concurrent callers wins.
+	setNX(key, value string, ttl time.Duration) (bool, error)
+	get(key string) (string, bool, error)
+	set(key, value string, ttl time.Duration) error
+	del(key string) error
+}
+
+// stateTimeout bounds every operation on a shared store, so a slow Redis cannot stall webhooks.
+const stateTimeout = 2 * time.Second
+
+// newStateStore returns a Redis store when a Redis URL is configured and an in-memory
+// store otherwise.
+func newStateStore(cfg SharedStateConfig) (stateStore, error) {
+	if cfg.RedisURL == "" {
+		return newMemoryStore(), nil
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sharedState.redisURL: %w", err)
+	}
+	store := &redisStore{client: redis.NewClient(opts), prefix: cfg.KeyPrefix}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	if err := store.client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return store, nil
+}
+
+// memoryStore is the single-replica stateStore.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   string
+	expires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *memoryStore) setNX(key, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.expire(now)
+	if _, ok := s.entries[key]; ok {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return "", false, nil
+	}
+	return e.value, true, nil
+}
+
+func (s *memoryStore) set(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.expire(now)
+	s.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *memoryStore) del(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// expire drops entries past their TTL. The caller must hold s.mu.
+func (s *memoryStore) expire(now time.Time) {
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// redisStore is the stateStore shared by several replicas. Keys expire in Redis itself.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func (s *redisStore) setNX(key, value string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	return s.client.SetNX(ctx, s.prefix+key, value, ttl).Result()
+}
+
+func (s *redisStore) get(key string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	value, err := s.client.Get(ctx, s.prefix+key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) set(key, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+func (s *redisStore) del(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertStorm is a circuit breaker for alert storms: when more than threshold alerts
+// arrive within window (say a network partition marks 200 nodes down), it trips and
+// holds back every alert, posting one summary with the counts per alert name instead of
+// hundreds of messages. Once fewer than threshold alerts arrived within the last
+// window, it resets and posts a closing summary of what it held back.
+type alertStorm struct {
+	threshold    int
+	window       time.Duration
+	dashboardURL string
+
+	mu sync.Mutex
+	// arrivals are the alerts received within the last window.
+	arrivals []stormArrival
+	tripped  bool
+	since    time.Time
+	// held holds the latest state of each alert held back, by fingerprint, and
+	// notifications counts them by alert name.
+	held          map[string]Alert
+	notifications map[string]int
+}
+
+type stormArrival struct {
+	at    time.Time
+	alert Alert
+}
+
+// newAlertStorm returns nil when the storm breaker is disabled.
+func newAlertStorm(cfg StormConfig) *alertStorm {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	return &alertStorm{threshold: cfg.Threshold, window: cfg.Window, dashboardURL: cfg.DashboardURL}
+}
+
+// hold counts the alerts and, while the breaker is tripped, removes them from the list.
+// When these alerts trip it, started holds the alerts of the window that did, for the
+// storm summary.
+func (s *alertStorm) hold(alerts []Alert, now time.Time) (forward, held, started []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, alert := range alerts {
+		s.arrivals = append(s.arrivals, stormArrival{at: now, alert: alert})
+	}
+	s.prune(now)
+
+	if !s.tripped && len(s.arrivals) > s.threshold {
+		s.tripped, s.since = true, now
+		s.held = make(map[string]Alert)
+		s.notifications = make(map[string]int)
+		stormActive.Set(1)
+		started = make([]Alert, len(s.arrivals))
+		for i, a := range s.arrivals {
+			started[i] = a.alert
+		}
+		slog.Warn("Alert storm detected, holding back alerts", "alerts", len(s.arrivals), "window", s.window.String())
+	}
+	if !s.tripped {
+		return alerts, nil, nil
+	}
+	for _, alert := range alerts {
+		s.held[alertFingerprint(alert)] = alert
+		s.notifications[alert.Labels["alertname"]]++
+	}
+	return nil, alerts, started
+}
+
+// prune drops the arrivals older than the window. The caller must hold s.mu.
+func (s *alertStorm) prune(now time.Time) {
+	i := 0
+	for i < len(s.arrivals) && now.Sub(s.arrivals[i].at) > s.window {
+		i++
+	}
+	s.arrivals = s.arrivals[i:]
+}
+
+// run checks every interval whether the storm is over and hands the held alerts and
+// the storm's start to ended when it is.
+func (s *alertStorm) run(interval time.Duration, ended func(held []Alert, notifications map[string]int, since time.Time)) {
+	for range time.Tick(interval) {
+		if held, notifications, since, ok := s.reset(time.Now()); ok {
+			ended(held, notifications, since)
+		}
+	}
+}
+
+// reset closes the breaker if it is tripped and the rate dropped below the threshold.
+func (s *alertStorm) reset(now time.Time) (held []Alert, notifications map[string]int, since time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now)
+	if !s.tripped || len(s.arrivals) >= s.threshold {
+		return nil, nil, time.Time{}, false
+	}
+	for _, alert := range s.held {
+		held = append(held, alert)
+	}
+	notifications, since = s.notifications, s.since
+	s.tripped, s.held, s.notifications = false, nil, nil
+	stormActive.Set(0)
+	slog.Info("Alert storm over, forwarding alerts again", "held", len(held), "duration", now.Sub(since).Round(time.Second).String())
+	return held, notifications, since, true
+}
+
+// startSummary is the message posted when the breaker trips, counting the alerts of the
+// window that tripped it by alert name.
+func (s *alertStorm) startSummary(alerts []Alert) string {
+	counts := make(map[string]int)
+	for _, alert := range alerts {
+		counts[alert.Labels["alertname"]]++
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🌩️ Alert storm: %d alert(s) within %s. Individual notifications are paused until the rate drops.", len(alerts), s.window)
+	writeStormCounts(&b, counts, nil)
+	s.writeDashboard(&b)
+	return b.String()
+}
+
+// endSummary is the message posted when the breaker resets, with the notifications held
+// back by alert name and how many of their alerts still fire.
+func (s *alertStorm) endSummary(held []Alert, notifications map[string]int, since time.Time) string {
+	counts := make(map[string]int)
+	firing := make(map[string]int)
+	stillFiring := 0
+	for _, alert := range held {
+		name := alert.Labels["alertname"]
+		counts[name] = notifications[name]
+		if alert.Status != "resolved" {
+			firing[name]++
+			stillFiring++
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "✅ Alert storm over after %s: %d alert(s) were held back, %d still firing.", humanizeDuration(time.Since(since).Round(time.Second)), len(held), stillFiring)
+	writeStormCounts(&b, counts, firing)
+	s.writeDashboard(&b)
+	return b.String()
+}
+
+// writeStormCounts lists the counts by alert name, the largest first.
+func writeStormCounts(b *strings.Builder, counts, firing map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		fmt.Fprintf(b, "\n• %s: %d", name, counts[name])
+		if firing != nil {
+			fmt.Fprintf(b, " (%d firing)", firing[name])
+		}
+	}
+}
+
+func (s *alertStorm) writeDashboard(b *strings.Builder) {
+	if s.dashboardURL != "" {
+		fmt.Fprintf(b, "\nDashboard: %s", s.dashboardURL)
+	}
+}
@@ -0,0 +1,189 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"alertmanager-adapter/notifier"
+)
+
+// teamsNotifier renders alerts as Adaptive Cards for Microsoft Teams incoming webhooks.
+type teamsNotifier struct {
+	router *webhookRouter
+}
+
+// teamsMessage is the envelope Teams expects around an Adaptive Card.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []adaptiveElement `json:"body"`
+	MSTeams map[string]string `json:"msteams,omitempty"`
+}
+
+// adaptiveElement covers the handful of Adaptive Card element types the adapter uses
+// (TextBlock, Container and FactSet); unused fields are omitted.
+type adaptiveElement struct {
+	Type      string            `json:"type"`
+	Text      string            `json:"text,omitempty"`
+	Size      string            `json:"size,omitempty"`
+	Weight    string            `json:"weight,omitempty"`
+	Color     string            `json:"color,omitempty"`
+	Wrap      bool              `json:"wrap,omitempty"`
+	IsSubtle  bool              `json:"isSubtle,omitempty"`
+	Style     string            `json:"style,omitempty"`
+	Bleed     bool              `json:"bleed,omitempty"`
+	Separator bool              `json:"separator,omitempty"`
+	Items     []adaptiveElement `json:"items,omitempty"`
+	Facts     []adaptiveFact    `json:"facts,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+func init() {
+	outputRegistry.Register("teams", func(cfg *Config, deps outputDeps) (output, error) {
+		return newTeamsNotifier(cfg.Teams, deps.locales)
+	})
+}
+
+func newTeamsNotifier(wc WebhookConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(wc, locales)
+	if err != nil {
+		return nil, fmt.Errorf("teams.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no Teams webhook URL is configured")
+	}
+	return &teamsNotifier{router: router}, nil
+}
+
+func (n *teamsNotifier) Name() string { return "teams" }
+
+func (n *teamsNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No Teams webhook configured for alert, dropping it", alertAttr(alert))
+	}
+
+	messages := make([]notifier.Notification, 0, len(routed))
+	for _, group := range routed {
+		m, err := newNotification(n.Name(), group.webhookURL, len(group.payload.Alerts), buildTeamsMessage(group.payload, group.localizer))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (n *teamsNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *teamsNotifier) renderText(webhookURL, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), webhookURL, 0, newTeamsCard([]adaptiveElement{{Type: "TextBlock", Text: text, Wrap: true}}))
+}
+
+func (n *teamsNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, m.Destination, m.Body); err != nil {
+		return fmt.Errorf("posting to Teams: %w", err)
+	}
+	return nil
+}
+
+func buildTeamsMessage(payload AlertmanagerPayload, l *localizer) teamsMessage {
+	body := []adaptiveElement{{
+		Type:   "TextBlock",
+		Text:   fmt.Sprintf("%s %s: %s", payloadIcon(payload), l.translate("Alert Status"), strings.ToUpper(l.translate(payload.Status))),
+		Size:   "Large",
+		Weight: "Bolder",
+		Wrap:   true,
+	}}
+
+	for _, alert := range payload.Alerts {
+		severity := alert.Labels["severity"]
+		status, _, alertIcon := alertAppearance(alert, payload.Status)
+
+		// The container style is rendered as a colored bar/background.
+		level, _ := severityOf(severity)
+		style := level.teamsStyle
+		if status == "resolved" {
+			style = "good"
+		}
+
+		var facts []adaptiveFact
+		facts = appendAdaptiveFact(facts, l.translate("Status"), strings.ToUpper(l.translate(status)))
+		facts = appendAdaptiveFact(facts, l.translate("Severity"), l.translate(severity))
+		facts = appendAdaptiveFact(facts, l.translate("Instance"), alert.Labels["instance"])
+		facts = appendAdaptiveFact(facts, l.translate("GPU"), gpuDescription(alert.Labels))
+		facts = appendAdaptiveFact(facts, l.translate("Workload"), workloadDescription(alert.Labels))
+		facts = appendAdaptiveFact(facts, l.translate("SLURM job"), slurmJobDescription(alert.Labels))
+		facts = appendAdaptiveFact(facts, l.translate("Node"), alert.Annotations[nodeInfoAnnotation])
+		facts = appendAdaptiveFact(facts, l.translate("GPU health"), alert.Annotations[gpuHealthAnnotation])
+		facts = appendAdaptiveFact(facts, l.translate("Top processes"), alert.Annotations[topProcessesAnnotation])
+		facts = appendAdaptiveFact(facts, l.translate("Throttling"), alert.Annotations[throttleReasonsAnnotation])
+		facts = appendAdaptiveFact(facts, l.translate("Chassis"), alert.Annotations[chassisAnnotation])
+		facts = appendAdaptiveFact(facts, l.translate("Started"), l.formatTime(alert.StartsAt))
+		facts = appendAdaptiveFact(facts, l.translate("Ended"), l.formatTime(alert.EndsAt))
+
+		items := []adaptiveElement{{
+			Type:   "TextBlock",
+			Text:   alertIcon + " " + alert.Labels["alertname"],
+			Size:   "Medium",
+			Weight: "Bolder",
+			Wrap:   true,
+		}}
+		if summary := alert.Annotations["summary"]; summary != "" {
+			items = append(items, adaptiveElement{Type: "TextBlock", Text: summary, Wrap: true})
+		}
+		items = append(items, adaptiveElement{Type: "FactSet", Facts: facts})
+
+		body = append(body, adaptiveElement{
+			Type:      "Container",
+			Style:     style,
+			Bleed:     true,
+			Separator: true,
+			Items:     items,
+		})
+	}
+
+	return newTeamsCard(body)
+}
+
+// newTeamsCard wraps Adaptive Card body elements in the Teams message envelope.
+func newTeamsCard(body []adaptiveElement) teamsMessage {
+	return teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: adaptiveCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+				MSTeams: map[string]string{"width": "Full"},
+			},
+		}},
+	}
+}
+
+// appendAdaptiveFact adds a fact, skipping empty values.
+func appendAdaptiveFact(facts []adaptiveFact, title, value string) []adaptiveFact {
+	if value == "" {
+		return facts
+	}
+	return append(facts, adaptiveFact{Title: title, Value: value})
+}
@@ -0,0 +1,221 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"alertmanager-adapter/notifier"
+)
+
+// telegramAPIURL is the public Bot API server.
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramMaxLength is the longest text Telegram accepts in one message, in characters.
+const telegramMaxLength = 4096
+
+// telegramNotifier sends alerts as MarkdownV2 formatted messages of a Telegram bot. Its
+// destinations are sendMessage URLs of a bot with the chat as chat_id parameter, so the
+// bot token stays in the destination like the secret of a webhook URL.
+type telegramNotifier struct {
+	router *webhookRouter
+}
+
+// telegramMessage is the body of a sendMessage request.
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+func init() {
+	outputRegistry.Register("telegram", func(cfg *Config, deps outputDeps) (output, error) {
+		return newTelegramNotifier(cfg.Telegram, deps.locales)
+	})
+}
+
+func newTelegramNotifier(cfg TelegramConfig, locales *localization) (output, error) {
+	router, err := newWebhookRouter(cfg.webhookConfig(), locales)
+	if err != nil {
+		return nil, fmt.Errorf("telegram.%w", err)
+	}
+	if router.empty() {
+		return nil, fmt.Errorf("no Telegram bot token and chat ID are configured")
+	}
+	return &telegramNotifier{router: router}, nil
+}
+
+func (n *telegramNotifier) Name() string { return "telegram" }
+
+// render posts the alerts of each chat in as few messages as fit telegramMaxLength.
+func (n *telegramNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	routed, unrouted := n.router.split(payload)
+	for _, alert := range unrouted {
+		slog.Warn("No Telegram chat configured for alert, dropping it", alertAttr(alert))
+	}
+
+	var messages []notifier.Notification
+	for _, group := range routed {
+		chatID := telegramChatID(group.webhookURL)
+		for _, chunk := range buildTelegramTexts(group.payload, group.localizer) {
+			m, err := newNotification(n.Name(), group.webhookURL, chunk.alerts, telegramMessage{ChatID: chatID, Text: chunk.text, ParseMode: "MarkdownV2"})
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (n *telegramNotifier) routes(alert Alert) []string { return n.router.routes(alert) }
+
+func (n *telegramNotifier) renderText(destination, text string) (notifier.Notification, error) {
+	return newNotification(n.Name(), destination, 0, telegramMessage{ChatID: telegramChatID(destination), Text: truncateRunes(text, telegramMaxLength)})
+}
+
+func (n *telegramNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, m.Destination, m.Body); err != nil {
+		return fmt.Errorf("posting to Telegram: %w", err)
+	}
+	return nil
+}
+
+// telegramDestination returns the sendMessage URL of a bot for a chat.
+func telegramDestination(apiURL, botToken, chatID string) string {
+	if apiURL == "" {
+		apiURL = telegramAPIURL
+	}
+	return fmt.Sprintf("%s/bot%s/sendMessage?chat_id=%s", strings.TrimRight(apiURL, "/"), botToken, url.QueryEscape(chatID))
+}
+
+// telegramChatID returns the chat of a destination built by telegramDestination.
+func telegramChatID(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("chat_id")
+}
+
+// telegramText is one message of a notification and the number of alerts starting in it.
+type telegramText struct {
+	text   string
+	alerts int
+}
+
+// buildTelegramTexts formats every alert as a block of lines and packs the blocks into
+// messages of at most telegramMaxLength characters. Alerts too long for one message
+// continue in the next, split between lines or, for very long lines, within them.
+func buildTelegramTexts(payload AlertmanagerPayload, l *localizer) []telegramText {
+	var texts []telegramText
+	var current strings.Builder
+	var alerts, length int
+	flush := func() {
+		if length > 0 {
+			texts = append(texts, telegramText{text: current.String(), alerts: alerts})
+		}
+		current.Reset()
+		alerts, length = 0, 0
+	}
+	add := func(line string) {
+		n := len([]rune(line))
+		if length > 0 && length+1+n > telegramMaxLength {
+			flush()
+		}
+		if length > 0 {
+			current.WriteByte('\n')
+			length++
+		}
+		current.WriteString(line)
+		length += n
+	}
+
+	for _, alert := range payload.Alerts {
+		lines := telegramAlertLines(alert, payload.Status, l)
+		// Alerts are separated by a blank line; an alert whose first line does not fit
+		// after it starts a new message.
+		if length > 0 && length+2+len([]rune(lines[0])) > telegramMaxLength {
+			flush()
+		}
+		if length > 0 {
+			add("")
+		}
+		alerts++
+		for _, line := range lines {
+			for _, part := range splitMarkdownV2(line, telegramMaxLength) {
+				add(part)
+			}
+		}
+	}
+	flush()
+	return texts
+}
+
+// telegramAlertLines formats an alert in MarkdownV2, one field per line, localized by l.
+func telegramAlertLines(alert Alert, payloadStatus string, l *localizer) []string {
+	status, _, icon := alertAppearance(alert, payloadStatus)
+	lines := []string{fmt.Sprintf("%s *%s* \\- *%s*", icon, escapeMarkdownV2(strings.ToUpper(l.translate(status))), escapeMarkdownV2(alert.Labels["alertname"]))}
+	for _, field := range []struct{ name, value string }{
+		{"Severity", l.translate(alert.Labels["severity"])},
+		{"Instance", alert.Labels["instance"]},
+		{"GPU", gpuDescription(alert.Labels)},
+		{"Workload", workloadDescription(alert.Labels)},
+		{"SLURM job", slurmJobDescription(alert.Labels)},
+		{"Summary", alert.Annotations["summary"]},
+		{"Node", alert.Annotations[nodeInfoAnnotation]},
+		{"GPU health", alert.Annotations[gpuHealthAnnotation]},
+		{"Top processes", alert.Annotations[topProcessesAnnotation]},
+		{"Throttling", alert.Annotations[throttleReasonsAnnotation]},
+		{"Chassis", alert.Annotations[chassisAnnotation]},
+		{"Started", l.formatTime(alert.StartsAt)},
+		{"Ended", l.formatTime(alert.EndsAt)},
+	} {
+		if field.value == "" {
+			continue
+		}
+		// Multi-line values (e.g. top processes) keep their lines.
+		for j, value := range strings.Split(field.value, "\n") {
+			if j == 0 {
+				lines = append(lines, fmt.Sprintf("*%s:* %s", escapeMarkdownV2(l.translate(field.name)), escapeMarkdownV2(value)))
+			} else {
+				lines = append(lines, escapeMarkdownV2(value))
+			}
+		}
+	}
+	return lines
+}
+
+// markdownV2Escaper escapes the characters MarkdownV2 reserves outside of entities.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`,
+	"`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`,
+	"{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func escapeMarkdownV2(s string) string {
+	return markdownV2Escaper.Replace(s)
+}
+
+// splitMarkdownV2 cuts an escaped line into parts of at most max characters, never
+// between a backslash and the character it escapes. Field names are short enough that
+// their bold markers always stay in the first part.
+func splitMarkdownV2(line string, max int) []string {
+	runes := []rune(line)
+	var parts []string
+	for len(runes) > max {
+		cut := max
+		backslashes := 0
+		for i := cut - 1; i >= 0 && runes[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			cut--
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return append(parts, string(runes))
+}
@@ -0,0 +1,64 @@
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// defaultMessageTemplate reproduces the adapter's original hard-coded message layout.
+// It is used when no template path is configured.
+// Its fixed words go through translate.
+const defaultMessageTemplate = `{{statusIcon .}} **{{translate "Alert Status"}}:** {{translate .Status}}
+{{range .Alerts}}
+**{{translate "Alert"}}: {{index .Labels "alertname"}}**
+  ->{{translate "Instance"}}: ` + "`{{index .Labels \"instance\"}}`" + `
+  ->{{translate "Severity"}}: {{translate (index .Labels "severity")}}
+  ->{{translate "Summary"}}: {{index .Annotations "summary"}}
+{{- with index .Annotations "node_info"}}
+  ->{{translate "Node"}}: {{.}}{{end}}
+{{end}}`
+
+// loadMessageTemplate parses the template file at path, or the built-in default when path
+// is empty. The helper functions use englishUTC until bound to a route's localizer by
+// localizeTemplates.
+func loadMessageTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New("default").Funcs(templateFuncs(englishUTC)).Parse(defaultMessageTemplate)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading message template: %w", err)
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=zero").Funcs(templateFuncs(englishUTC)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// localizeTemplates returns a copy of tmpl for each localizer, with the helper functions
+// bound to it.
+func localizeTemplates(tmpl *template.Template, localizers []*localizer) (map[*localizer]*template.Template, error) {
+	templates := make(map[*localizer]*template.Template, len(localizers))
+	for _, l := range localizers {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return nil, err
+		}
+		templates[l] = clone.Funcs(templateFuncs(l))
+	}
+	return templates, nil
+}
+
+// renderMessage executes the template with the full Alertmanager payload as its data.
+func renderMessage(tmpl *template.Template, payload AlertmanagerPayload) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return "", fmt.Errorf("executing message template: %w", err)
+	}
+	return buf.String(), nil
+}
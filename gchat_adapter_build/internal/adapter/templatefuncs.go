@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// severityEmojis are the icons severityEmoji returns for severities without a
+// configured icon.
+var severityEmojis = map[string]string{
+	"critical": "🔴",
+	"warning":  "🟠",
+	"info":     "🔵",
+	"resolved": resolvedIcon,
+}
+
+// markdownEscaper escapes the characters that start markdown formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "~", `\~`,
+	"[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "#", `\#`, ">", `\>`, "|", `\|`,
+)
+
+// templateFuncs are the helpers available in message and email templates. Times are
+// converted to the zone of l by toLocalTime unless a zone is passed, and translate and
+// formatTime use the locale and time format of l, e.g.
+//
+//	{{(toLocalTime .StartsAt).Format "2006-01-02 15:04 MST"}}
+//	{{translate "Started"}}: {{formatTime .StartsAt}}
+//	{{.Annotations.summary | truncate 80 | markdownEscape}}
+//	{{severityEmoji .Labels.severity}} {{bytesToGiB .Annotations.memory_used | printf "%.1f"}} GiB
+func templateFuncs(l *localizer) map[string]any {
+	return map[string]any{
+		// humanizeDuration formats a duration, or a number of seconds, as "1h 2m 3s".
+		"humanizeDuration": func(v any) (string, error) {
+			d, err := toDuration(v)
+			if err != nil {
+				return "", err
+			}
+			return humanizeDuration(d), nil
+		},
+		// toLocalTime parses an RFC 3339 timestamp (like startsAt) or converts a time to
+		// the templates' time zone, or to the zone named by the optional argument.
+		"toLocalTime": func(v any, zone ...string) (time.Time, error) {
+			var t time.Time
+			switch v := v.(type) {
+			case time.Time:
+				t = v
+			case string:
+				parsed, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return time.Time{}, err
+				}
+				t = parsed
+			default:
+				return time.Time{}, fmt.Errorf("toLocalTime: unsupported value %T", v)
+			}
+			if len(zone) > 0 {
+				l, err := time.LoadLocation(zone[0])
+				if err != nil {
+					return time.Time{}, err
+				}
+				return t.In(l), nil
+			}
+			return t.In(l.loc), nil
+		},
+		// translate returns the locale's word for an English status word, severity or
+		// field name, or the word itself.
+		"translate": l.translate,
+		// formatTime shows an RFC 3339 timestamp in the zone and time format of messages;
+		// it is "" for Alertmanager's zero endsAt.
+		"formatTime": l.formatTime,
+		// truncate shortens s to at most n characters, ending it with "…" if it was cut.
+		"truncate": func(n int, s string) string {
+			if n <= 0 || utf8.RuneCountInString(s) <= n {
+				return s
+			}
+			runes := []rune(s)
+			return string(runes[:n-1]) + "…"
+		},
+		// severityEmoji is the icon of a severity label value (or "resolved"): the icon
+		// configured in severities, or a colored circle.
+		"severityEmoji": func(severity string) string {
+			if level, ok := severityOf(severity); ok && level.icon != "" {
+				return level.icon
+			}
+			if emoji, ok := severityEmojis[strings.ToLower(severity)]; ok {
+				return emoji
+			}
+			return "⚪"
+		},
+		// statusIcon is the icon of a whole payload: that of its most severe firing alert,
+		// or ✅ once everything resolved.
+		"statusIcon":     payloadIcon,
+		"markdownEscape": markdownEscaper.Replace,
+		// toJSON encodes a value as JSON, e.g. a string with its quotes and escapes for
+		// the body of an http endpoint.
+		"toJSON": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		// bytesToGiB converts a number of bytes (a number or a numeric string such as an
+		// annotation) to GiB.
+		"bytesToGiB": func(v any) (float64, error) {
+			f, err := toFloat(v)
+			if err != nil {
+				return 0, err
+			}
+			return f / (1 << 30), nil
+		},
+	}
+}
+
+// humanizeDuration formats d like Prometheus' humanizeDuration, e.g. "2d 3h 4m 5s";
+// durations below a second are shown in milliseconds.
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + humanizeDuration(-d)
+	}
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	units := []struct {
+		suffix string
+		size   time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}}
+
+	var parts []string
+	for _, u := range units {
+		if n := d / u.size; n > 0 || (u.suffix == "s" && len(parts) == 0) {
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.suffix))
+			d -= n * u.size
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// toDuration accepts a time.Duration, a number of seconds or a string holding either.
+func toDuration(v any) (time.Duration, error) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, nil
+		}
+	}
+	seconds, err := toFloat(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
+
+// toFloat accepts the number types and numeric strings.
+func toFloat(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("unsupported number %v (%T)", v, v)
+	}
+}
@@ -0,0 +1,130 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// threadTrackerTTL bounds how long an incident's thread is remembered without any
+// notification for it, so alerts that never resolve do not accumulate forever.
+const threadTrackerTTL = 7 * 24 * time.Hour
+
+// threadTracker remembers which Google Chat thread each firing alert was posted to,
+// keyed by alert fingerprint, so that repeat and resolved notifications for the same
+// incident are posted as replies in that thread. With a shared store, replicas see
+// each other's threads.
+type threadTracker struct {
+	store stateStore
+}
+
+func newThreadTracker(store stateStore) *threadTracker {
+	return &threadTracker{store: store}
+}
+
+// threadKey returns the thread for a group of alerts sent as one message. If any alert
+// is already tracked its thread is reused; otherwise a new key is derived from the first
+// alert's fingerprint and start time. Firing alerts are (re)recorded under the key and
+// resolved alerts are forgotten, since their incident is over. When two replicas start
+// a thread for the same alert at once, both use the one recorded first.
+func (t *threadTracker) threadKey(alerts []Alert) string {
+	if len(alerts) == 0 {
+		return ""
+	}
+
+	key := ""
+	for _, alert := range alerts {
+		if tracked, ok := t.thread(alertFingerprint(alert)); ok {
+			key = tracked
+			break
+		}
+	}
+	if key == "" {
+		first := alerts[0]
+		fp := alertFingerprint(first)
+		key = fmt.Sprintf("incident-%s-%s", fp, first.StartsAt)
+		if first.Status != "resolved" {
+			claimed, err := t.store.setNX(threadStateKey(fp), key, threadTrackerTTL)
+			if err != nil {
+				slog.Warn("Error recording incident thread", alertAttr(first), "err", err)
+			} else if !claimed {
+				if tracked, ok := t.thread(fp); ok {
+					key = tracked
+				}
+			}
+		}
+	}
+
+	for _, alert := range alerts {
+		fp := alertFingerprint(alert)
+		var err error
+		if alert.Status == "resolved" {
+			err = t.store.del(threadStateKey(fp))
+		} else {
+			err = t.store.set(threadStateKey(fp), key, threadTrackerTTL)
+		}
+		if err != nil {
+			slog.Warn("Error recording incident thread", alertAttr(alert), "err", err)
+		}
+	}
+	return key
+}
+
+// thread returns the thread key of a tracked firing alert.
+func (t *threadTracker) thread(fingerprint string) (string, bool) {
+	key, ok, err := t.store.get(threadStateKey(fingerprint))
+	if err != nil {
+		slog.Warn("Error looking up incident thread", "fingerprint", fingerprint, "err", err)
+		return "", false
+	}
+	return key, ok
+}
+
+func threadStateKey(fingerprint string) string {
+	return "thread/" + fingerprint
+}
+
+// nodeThreadKey is the thread of every message about a node with threadBy "node". It
+// only depends on the node name, so all replicas, and the adapter after a restart, keep
+// posting to the same thread without any state.
+func nodeThreadKey(node string) string {
+	return "node-" + node
+}
+
+// splitByNode splits a routed payload into one payload per node (see alertNode), in the
+// order the nodes first appear, so each can go to its node's thread.
+func splitByNode(group routedPayload) []routedPayload {
+	var split []routedPayload
+	index := make(map[string]int)
+	for _, alert := range group.payload.Alerts {
+		node := alertNode(alert.Labels)
+		i, ok := index[node]
+		if !ok {
+			i = len(split)
+			index[node] = i
+			sub := group
+			sub.payload.Alerts = nil
+			split = append(split, sub)
+		}
+		split[i].payload.Alerts = append(split[i].payload.Alerts, alert)
+	}
+	for i := range split {
+		split[i].payload.Status = combinedStatus(split[i].payload.Alerts)
+	}
+	return split
+}
+
+// withThreadKey adds the Google Chat threading parameters to a webhook URL. Replies fall
+// back to a new thread if the key is unknown to the space (e.g. after it was deleted).
+func withThreadKey(webhookURL, threadKey string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing webhook URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("threadKey", threadKey)
+	q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,141 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// throttleReasonsAnnotation is the annotation listing why the alerting GPU runs its
+// clocks below the maximum; the rich message formats display it as "Throttling".
+const throttleReasonsAnnotation = "gpu_throttle_reasons"
+
+// throttleReasonNames are the display names of the gpu-collector's
+// gpu_clock_throttle_reason values.
+var throttleReasonNames = map[string]string{
+	"gpu_idle":                    "GPU idle",
+	"applications_clocks_setting": "application clocks setting",
+	"sw_power_cap":                "SW power cap",
+	"hw_slowdown":                 "HW slowdown",
+	"sync_boost":                  "sync boost",
+	"sw_thermal_slowdown":         "SW thermal slowdown",
+	"hw_thermal_slowdown":         "HW thermal slowdown",
+	"hw_power_brake_slowdown":     "HW power brake",
+	"display_clock_setting":       "display clock setting",
+}
+
+// throttleEnricher looks up the alerting node's gpu-collector and annotates
+// utilization and temperature alerts with the GPU's active clock throttle reasons, so
+// responders see right away why a GPU is slow.
+type throttleEnricher struct {
+	urlTemplate string
+	alertnames  *regexp.Regexp
+	client      *http.Client
+}
+
+// gpuThrottle is the active throttle reasons of one GPU.
+type gpuThrottle struct {
+	gpu, uuid string
+	reasons   []string
+}
+
+// newThrottleEnricher returns nil when no collector URL is configured. The alert name
+// pattern has already been checked by Config.validate.
+func newThrottleEnricher(cfg ThrottleConfig) *throttleEnricher {
+	if cfg.CollectorURL == "" {
+		return nil
+	}
+	return &throttleEnricher{
+		urlTemplate: cfg.CollectorURL,
+		alertnames:  regexp.MustCompile(cfg.AlertPattern),
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (e *throttleEnricher) enrich(payload AlertmanagerPayload) AlertmanagerPayload {
+	cache := make(map[string][]gpuThrottle)
+	alerts := make([]Alert, len(payload.Alerts))
+	for i, alert := range payload.Alerts {
+		alerts[i] = alert
+		if alert.Status == "resolved" || !e.alertnames.MatchString(alert.Labels["alertname"]) {
+			continue
+		}
+
+		url := nodeURL(e.urlTemplate, alert.Labels["instance"])
+		if url == "" {
+			continue
+		}
+		gpus, ok := cache[url]
+		if !ok {
+			var err error
+			gpus, err = e.fetch(url)
+			if err != nil {
+				slog.Warn("Error fetching GPU throttle reasons", "url", url, "err", err)
+			}
+			cache[url] = gpus
+		}
+
+		if summary := throttleSummary(alert.Labels, gpus); summary != "" {
+			alerts[i] = withAnnotations(alert, map[string]string{throttleReasonsAnnotation: summary})
+		}
+	}
+	payload.Alerts = alerts
+	return payload
+}
+
+func (e *throttleEnricher) fetch(url string) ([]gpuThrottle, error) {
+	families, err := scrapeMetrics(e.client, url)
+	if err != nil {
+		return nil, err
+	}
+
+	byGPU := make(map[string]*gpuThrottle)
+	for _, m := range families["gpu_clock_throttle_reason"].GetMetric() {
+		labels := metricLabels(m)
+		g, ok := byGPU[labels["gpu"]]
+		if !ok {
+			g = &gpuThrottle{gpu: labels["gpu"], uuid: labels["uuid"]}
+			byGPU[labels["gpu"]] = g
+		}
+		if metricValue(m) > 0 {
+			name := throttleReasonNames[labels["reason"]]
+			if name == "" {
+				name = labels["reason"]
+			}
+			g.reasons = append(g.reasons, name)
+		}
+	}
+
+	gpus := make([]gpuThrottle, 0, len(byGPU))
+	for _, g := range byGPU {
+		sort.Strings(g.reasons)
+		gpus = append(gpus, *g)
+	}
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].gpu < gpus[j].gpu })
+	return gpus, nil
+}
+
+// throttleSummary lists the active reasons of the alert's GPU (by its gpu or UUID
+// label), e.g. "HW thermal slowdown, SW power cap", or "none" when its clocks are not
+// held back. Alerts without a GPU get one line per throttled GPU of the node.
+func throttleSummary(labels map[string]string, gpus []gpuThrottle) string {
+	var lines []string
+	for _, g := range gpus {
+		if (labels["gpu"] != "" && labels["gpu"] != g.gpu) || (labels["UUID"] != "" && labels["UUID"] != g.uuid) {
+			continue
+		}
+		if labels["gpu"] != "" || labels["UUID"] != "" {
+			if len(g.reasons) == 0 {
+				return "none"
+			}
+			return strings.Join(g.reasons, ", ")
+		}
+		if len(g.reasons) > 0 {
+			lines = append(lines, fmt.Sprintf("GPU %s · %s", g.gpu, strings.Join(g.reasons, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,203 @@
+package adapter
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// incidentTimeline follows every incident, i.e. Alertmanager group key, from the first
+// firing alert to the resolution of the last one. The first fire is the regular alert
+// message; while the incident lasts, a "still firing" update is posted every interval,
+// and once it is over a resolution summary with its total duration. The state is kept
+// in memory, so a restart starts every incident's timeline over.
+type incidentTimeline struct {
+	// interval is the cadence of the still-firing updates; 0 posts none.
+	interval time.Duration
+
+	mu        sync.Mutex
+	incidents map[string]*incident
+}
+
+// incident is the lifecycle of one group key.
+type incident struct {
+	started    time.Time
+	lastUpdate time.Time
+	// alerts holds the latest state of every alert seen during the incident, by
+	// fingerprint; firing holds the fingerprints of those still firing.
+	alerts map[string]Alert
+	firing map[string]bool
+}
+
+// timelineUpdate is a lifecycle message to post to the destinations of its alerts.
+type timelineUpdate struct {
+	text   string
+	alerts []Alert
+}
+
+// newIncidentTimeline returns nil when the timeline is disabled.
+func newIncidentTimeline(cfg TimelineConfig) *incidentTimeline {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &incidentTimeline{interval: cfg.UpdateInterval, incidents: make(map[string]*incident)}
+}
+
+// observe records the alerts of a payload and returns the resolution summary if the
+// payload ended its incident.
+func (t *incidentTimeline) observe(payload AlertmanagerPayload, now time.Time) (timelineUpdate, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	inc := t.incidents[payload.GroupKey]
+	for _, alert := range payload.Alerts {
+		fp := alertFingerprint(alert)
+		if alert.Status == "resolved" {
+			if inc != nil {
+				inc.alerts[fp] = alert
+				delete(inc.firing, fp)
+			}
+			continue
+		}
+		if inc == nil {
+			inc = &incident{started: now, lastUpdate: now, alerts: make(map[string]Alert), firing: make(map[string]bool)}
+			t.incidents[payload.GroupKey] = inc
+		}
+		// The incident started when its earliest alert did, which may be before the
+		// adapter first heard of it.
+		if startsAt, err := time.Parse(time.RFC3339, alert.StartsAt); err == nil && startsAt.Year() > 1 && startsAt.Before(inc.started) {
+			inc.started = startsAt
+		}
+		inc.alerts[fp] = alert
+		inc.firing[fp] = true
+	}
+	if inc == nil || len(inc.firing) > 0 {
+		return timelineUpdate{}, false
+	}
+
+	delete(t.incidents, payload.GroupKey)
+	alerts := inc.sortedAlerts(nil)
+	end := now
+	for _, alert := range alerts {
+		if endsAt, err := time.Parse(time.RFC3339, alert.EndsAt); err == nil && endsAt.Year() > 1 && endsAt.After(inc.started) && (end == now || endsAt.After(end)) {
+			end = endsAt
+		}
+	}
+	text := fmt.Sprintf("✅ %s resolved after %s: %s.",
+		incidentTitle(alerts), humanizeDuration(end.Sub(inc.started).Truncate(time.Second)), affected(alerts))
+	return timelineUpdate{text: text, alerts: alerts}, true
+}
+
+// due returns the still-firing updates whose time has come.
+func (t *incidentTimeline) due(now time.Time) []timelineUpdate {
+	if t.interval <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var due []timelineUpdate
+	for _, inc := range t.incidents {
+		if now.Sub(inc.lastUpdate) < t.interval {
+			continue
+		}
+		inc.lastUpdate = now
+		firing := inc.sortedAlerts(inc.firing)
+		text := fmt.Sprintf("🔥 %s still firing for %s: %s.",
+			incidentTitle(firing), humanizeDuration(now.Sub(inc.started).Truncate(time.Minute)), affected(firing))
+		due = append(due, timelineUpdate{text: text, alerts: firing})
+	}
+	return due
+}
+
+// run posts the due still-firing updates, checking every interval.
+func (t *incidentTimeline) run(interval time.Duration, post func(timelineUpdate)) {
+	for {
+		time.Sleep(interval)
+		for _, update := range t.due(time.Now()) {
+			post(update)
+		}
+	}
+}
+
+// sortedAlerts returns the incident's alerts (only those in only, if set) by alert name
+// and instance.
+func (inc *incident) sortedAlerts(only map[string]bool) []Alert {
+	var alerts []Alert
+	for fp, alert := range inc.alerts {
+		if only == nil || only[fp] {
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if a, b := alerts[i].Labels["alertname"], alerts[j].Labels["alertname"]; a != b {
+			return a < b
+		}
+		return alerts[i].Labels["instance"] < alerts[j].Labels["instance"]
+	})
+	return alerts
+}
+
+// incidentTitle names an incident by its alert names, e.g. "GpuHot" or
+// "GpuHot, GpuXidError".
+func incidentTitle(alerts []Alert) string {
+	var names []string
+	for _, alert := range alerts {
+		if name := alert.Labels["alertname"]; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// affected counts the alerts and their nodes, e.g. "3 alerts on 2 nodes (gpu-node-07,
+// gpu-node-08)".
+func affected(alerts []Alert) string {
+	var nodes []string
+	for _, alert := range alerts {
+		if node := alertNode(alert.Labels); node != "" && !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	text := plural(len(alerts), "alert")
+	if len(nodes) == 0 {
+		return text
+	}
+	text += " on " + plural(len(nodes), "node")
+	const shown = 5
+	if len(nodes) > shown {
+		return fmt.Sprintf("%s (%s and %d more)", text, strings.Join(nodes[:shown], ", "), len(nodes)-shown)
+	}
+	return fmt.Sprintf("%s (%s)", text, strings.Join(nodes, ", "))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// postTimeline sends a lifecycle update as a plain notice to every destination its
+// alerts are routed to.
+func (a *adapter) postTimeline(update timelineUpdate) {
+	notifiers, _ := a.current()
+	selector := a.currentSelector()
+	for _, n := range notifiers {
+		urls := make(map[string]bool)
+		for _, alert := range selector.filter(update.alerts, n.Name()) {
+			for _, url := range n.routes(alert) {
+				urls[url] = true
+			}
+		}
+		for url := range urls {
+			if err := a.sendText(destination{n.Name(), url}, update.text); err != nil {
+				slog.Error("Error posting incident timeline update", "backend", n.Name(), "err", err)
+			}
+		}
+	}
+}
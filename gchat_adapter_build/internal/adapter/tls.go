@@ -0,0 +1,29 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// newServerTLSConfig returns the listener's TLS settings. With a client CA bundle, every
+// client must present a certificate signed by one of its CAs (mutual TLS).
+func newServerTLSConfig(clientCAPath string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCAPath == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(clientCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", clientCAPath)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
@@ -0,0 +1,115 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"alertmanager-adapter/notifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the adapter's spans. Until setupTracing installs a provider (and when
+// tracing is disabled) its spans are no-ops.
+var tracer = otel.Tracer("alertmanager-adapter")
+
+// setupTracing exports spans over OTLP/HTTP (e.g. to Jaeger's port 4318) and propagates
+// W3C trace context on incoming and outgoing requests. It returns a function that flushes
+// the remaining spans, or nil when tracing is disabled.
+func setupTracing(cfg TracingConfig) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Alertmanager does not trace its webhooks, so incoming requests are mostly roots.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// traceRequests starts a server span for every request to next, continuing the
+// caller's trace if the request carries one.
+func traceRequests(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// traceCarrier returns ctx's trace context as a map that can be stored with a queued
+// message, or nil if ctx is not traced.
+func traceCarrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// messageContext continues the trace a message was rendered in.
+func messageContext(ctx context.Context, m notifier.Notification) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m.Trace))
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// webhookHost is the host of a webhook URL. Only the host is recorded in spans, since
+// webhook URLs usually embed their credentials.
+func webhookHost(webhookURL string) attribute.KeyValue {
+	host := ""
+	if u, err := url.Parse(webhookURL); err == nil {
+		host = u.Host
+	}
+	return semconv.ServerAddress(host)
+}
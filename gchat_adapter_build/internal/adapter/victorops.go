@@ -0,0 +1,166 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"alertmanager-adapter/notifier"
+)
+
+// victorOpsRESTURL is the Splunk On-Call (VictorOps) REST endpoint; the integration's
+// API key and the routing key follow it in the request path.
+const victorOpsRESTURL = "https://alert.victorops.com/integrations/generic/20131114/alert"
+
+// defaultVictorOpsMessageTypes maps severity label values onto Splunk On-Call message
+// types; other severities are sent as WARNING. INFO messages show in the timeline
+// without opening an incident.
+var defaultVictorOpsMessageTypes = map[string]string{
+	"critical": "CRITICAL",
+	"error":    "CRITICAL",
+	"warning":  "WARNING",
+	"info":     "INFO",
+}
+
+// victorOpsNotifier sends one REST endpoint message per alert to Splunk On-Call: the
+// message type derived from the severity while the alert fires, RECOVERY once it
+// resolves. Its destinations are routing keys, which pick the team escalation policy.
+type victorOpsNotifier struct {
+	router       *webhookRouter
+	messageTypes map[string]string
+	// url is the REST endpoint up to and including the API key.
+	url string
+}
+
+// victorOpsMessage is the body of a REST endpoint request.
+type victorOpsMessage struct {
+	MessageType       string `json:"message_type"`
+	EntityID          string `json:"entity_id"`
+	EntityDisplayName string `json:"entity_display_name,omitempty"`
+	StateMessage      string `json:"state_message,omitempty"`
+	StateStartTime    int64  `json:"state_start_time,omitempty"`
+	MonitoringTool    string `json:"monitoring_tool"`
+	HostName          string `json:"host_name,omitempty"`
+	AlertName         string `json:"alertname,omitempty"`
+	Severity          string `json:"severity,omitempty"`
+	// Annotations starting with vo_annotate are shown as links on the incident.
+	Runbook   string `json:"vo_annotate.u.Runbook,omitempty"`
+	Dashboard string `json:"vo_annotate.u.Dashboard,omitempty"`
+	Source    string `json:"vo_annotate.u.Source,omitempty"`
+}
+
+func init() {
+	outputRegistry.Register("victorops", func(cfg *Config, _ outputDeps) (output, error) {
+		return newVictorOpsNotifier(cfg.VictorOps)
+	})
+}
+
+func newVictorOpsNotifier(cfg VictorOpsConfig) (output, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("no Splunk On-Call API key is configured")
+	}
+	// Messages carry no human-readable layout, so the router needs no localization and
+	// cannot fail.
+	router, _ := newWebhookRouter(cfg.webhookConfig(), nil)
+	if router.empty() {
+		return nil, fmt.Errorf("no Splunk On-Call routing key is configured")
+	}
+	messageTypes := make(map[string]string, len(defaultVictorOpsMessageTypes)+len(cfg.MessageTypes))
+	for severity, messageType := range defaultVictorOpsMessageTypes {
+		messageTypes[severity] = messageType
+	}
+	for severity, messageType := range cfg.MessageTypes {
+		messageTypes[strings.ToLower(severity)] = strings.ToUpper(messageType)
+	}
+	restURL := cfg.URL
+	if restURL == "" {
+		restURL = victorOpsRESTURL
+	}
+	return &victorOpsNotifier{
+		router:       router,
+		messageTypes: messageTypes,
+		url:          strings.TrimRight(restURL, "/") + "/" + url.PathEscape(cfg.APIKey),
+	}, nil
+}
+
+func (n *victorOpsNotifier) Name() string { return "victorops" }
+
+// render builds a message for every alert and routing key.
+func (n *victorOpsNotifier) render(payload AlertmanagerPayload) ([]notifier.Notification, error) {
+	var messages []notifier.Notification
+	for _, alert := range payload.Alerts {
+		keys := n.router.routes(alert)
+		if len(keys) == 0 {
+			slog.Warn("No Splunk On-Call routing key configured for alert, dropping it", alertAttr(alert))
+			continue
+		}
+		message := n.buildMessage(alert, payload.Status)
+		for _, key := range keys {
+			m, err := newNotification(n.Name(), key, 1, message)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (n *victorOpsNotifier) routes(alert Alert) []string {
+	return n.router.routes(alert)
+}
+
+// renderText is not supported: every Splunk On-Call message changes an incident's state.
+func (n *victorOpsNotifier) renderText(routingKey, text string) (notifier.Notification, error) {
+	return notifier.Notification{}, errTextUnsupported
+}
+
+func (n *victorOpsNotifier) Send(ctx context.Context, m notifier.Notification) error {
+	if err := postJSON(ctx, n.url+"/"+url.PathEscape(m.Destination), m.Body); err != nil {
+		return fmt.Errorf("posting to Splunk On-Call: %w", err)
+	}
+	return nil
+}
+
+// buildMessage keys the incident by the alert fingerprint (entity_id): Splunk On-Call
+// keeps repeated messages in the open incident, and the RECOVERY message resolves it.
+func (n *victorOpsNotifier) buildMessage(alert Alert, payloadStatus string) victorOpsMessage {
+	message := victorOpsMessage{
+		EntityID:       alertFingerprint(alert),
+		MonitoringTool: "alertmanager-adapter",
+		HostName:       alert.Labels["instance"],
+		AlertName:      alert.Labels["alertname"],
+		Severity:       alert.Labels["severity"],
+		Runbook:        alert.Annotations["runbook_url"],
+		Dashboard:      alert.Annotations["dashboard_url"],
+		Source:         alert.GeneratorURL,
+	}
+	status, _, _ := alertAppearance(alert, payloadStatus)
+	if status == "resolved" {
+		message.MessageType = "RECOVERY"
+	} else if message.MessageType = n.messageTypes[strings.ToLower(alert.Labels["severity"])]; message.MessageType == "" {
+		message.MessageType = "WARNING"
+	}
+
+	displayName := alert.Labels["alertname"]
+	if s := alert.Annotations["summary"]; s != "" {
+		displayName += ": " + s
+	}
+	if instance := alert.Labels["instance"]; instance != "" {
+		displayName += " (" + instance + ")"
+	}
+	description := alert.Annotations["description"]
+	if description == "" {
+		description = alert.Annotations["summary"]
+	}
+	// Splunk On-Call truncates the display name in pages and notifications.
+	message.EntityDisplayName = truncateRunes(displayName, 255)
+	message.StateMessage = truncateRunes(description, 10000)
+	if t, err := time.Parse(time.RFC3339, alert.StartsAt); err == nil && t.Year() > 1 {
+		message.StateStartTime = t.Unix()
+	}
+	return message
+}
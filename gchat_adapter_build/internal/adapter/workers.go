@@ -0,0 +1,110 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errDeliveryBacklog is returned for payloads arriving while the delivery pool's queue
+// is full. Webhooks get a 503, which Alertmanager retries.
+var errDeliveryBacklog = errors.New("delivery queue is full")
+
+// acceptedForDelivery is the webhook response for payloads handed to the delivery pool;
+// they are answered with 202 Accepted.
+const acceptedForDelivery = "Alert accepted for delivery"
+
+// deliveryPool dispatches payloads on a fixed number of workers, so webhooks are
+// answered as soon as their payload is queued in memory instead of after every backend
+// answered. Payloads still queued are lost if the process dies; the durable outbound
+// queue is the choice when that matters.
+type deliveryPool struct {
+	jobs chan deliveryJob
+	wg   sync.WaitGroup
+
+	// mu keeps submit from sending on the jobs channel once close closed it.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type deliveryJob struct {
+	// ctx carries the webhook's logger and trace, without its cancellation.
+	ctx      context.Context
+	payload  AlertmanagerPayload
+	queuedAt time.Time
+}
+
+// newDeliveryPool returns nil when payloads are dispatched within the webhook request.
+// Otherwise it starts cfg.Concurrency workers running dispatch.
+func newDeliveryPool(cfg WorkersConfig, dispatch func(context.Context, AlertmanagerPayload) error) *deliveryPool {
+	if cfg.Concurrency <= 0 {
+		return nil
+	}
+	p := &deliveryPool{jobs: make(chan deliveryJob, cfg.QueueDepth)}
+	deliveryQueueCapacity.Set(float64(cfg.QueueDepth))
+	for i := 0; i < cfg.Concurrency; i++ {
+		p.wg.Add(1)
+		go p.work(dispatch)
+	}
+	return p
+}
+
+// submit queues a payload for the workers. It never blocks: with the queue full (or the
+// pool closed) it returns errDeliveryBacklog.
+func (p *deliveryPool) submit(ctx context.Context, payload AlertmanagerPayload) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return errDeliveryBacklog
+	}
+	select {
+	case p.jobs <- deliveryJob{ctx: context.WithoutCancel(ctx), payload: payload, queuedAt: time.Now()}:
+		deliveryQueueLength.Set(float64(len(p.jobs)))
+		return nil
+	default:
+		deliveryRejected.Inc()
+		return errDeliveryBacklog
+	}
+}
+
+func (p *deliveryPool) work(dispatch func(context.Context, AlertmanagerPayload) error) {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		deliveryQueueLength.Set(float64(len(p.jobs)))
+		deliveryQueueWait.Observe(time.Since(job.queuedAt).Seconds())
+		deliveryWorkersBusy.Inc()
+		if err := dispatch(job.ctx, job.payload); err != nil {
+			loggerFrom(job.ctx).Error("Error forwarding alert", "group_key", job.payload.GroupKey, "err", err)
+		}
+		deliveryWorkersBusy.Dec()
+	}
+}
+
+// close stops accepting payloads and waits until the workers delivered the queued ones
+// or ctx is done.
+func (p *deliveryPool) close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pending returns the number of queued payloads.
+func (p *deliveryPool) pending() int {
+	return len(p.jobs)
+}
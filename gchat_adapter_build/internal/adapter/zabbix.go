@@ -0,0 +1,215 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultZabbixSeverities maps Zabbix trigger severities onto severity label values.
+var defaultZabbixSeverities = map[string]string{
+	"disaster":       "critical",
+	"high":           "critical",
+	"average":        "warning",
+	"warning":        "warning",
+	"information":    "info",
+	"not classified": "info",
+}
+
+// zabbixInput accepts notifications of a Zabbix webhook media type on /zabbix and runs
+// them through the alert pipeline, so legacy GPU nodes still monitored by Zabbix reach
+// the same spaces as Alertmanager alerts. A problem event fires an alert and its
+// recovery resolves it; acknowledgements and other updates are ignored.
+type zabbixInput struct {
+	severities map[string]string
+	labels     map[string]string
+	loc        *time.Location
+	accept     func(http.ResponseWriter, *http.Request, AlertmanagerPayload)
+}
+
+// zabbixEvent is what the media type posts: its parameters, named after the macros
+// they are set to (see config.example.yml). Every value is a string, and macros Zabbix
+// cannot resolve arrive unexpanded, e.g. "{EVENT.RECOVERY.DATE}" for a problem.
+type zabbixEvent struct {
+	EventID            string          `json:"event_id"`
+	EventValue         string          `json:"event_value"`
+	EventUpdateStatus  string          `json:"event_update_status"`
+	EventName          string          `json:"event_name"`
+	EventSeverity      string          `json:"event_severity"`
+	EventDate          string          `json:"event_date"`
+	EventTime          string          `json:"event_time"`
+	RecoveryDate       string          `json:"event_recovery_date"`
+	RecoveryTime       string          `json:"event_recovery_time"`
+	EventOpdata        string          `json:"event_opdata"`
+	EventTags          json.RawMessage `json:"event_tags"`
+	TriggerID          string          `json:"trigger_id"`
+	TriggerDescription string          `json:"trigger_description"`
+	HostHost           string          `json:"host_host"`
+	HostName           string          `json:"host_name"`
+	HostIP             string          `json:"host_ip"`
+	ZabbixURL          string          `json:"zabbix_url"`
+}
+
+// zabbixTag is an entry of {EVENT.TAGSJSON}.
+type zabbixTag struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// unexpandedMacro matches a macro Zabbix left as is.
+var unexpandedMacro = regexp.MustCompile(`^\{[A-Z0-9_.$]+\}$`)
+
+// newZabbixInput returns nil when the input is disabled. The config has already been
+// checked by Config.validate.
+func newZabbixInput(cfg ZabbixConfig, accept func(http.ResponseWriter, *http.Request, AlertmanagerPayload)) *zabbixInput {
+	if !cfg.Enabled {
+		return nil
+	}
+	severities := make(map[string]string, len(defaultZabbixSeverities)+len(cfg.Severities))
+	for name, severity := range defaultZabbixSeverities {
+		severities[name] = severity
+	}
+	for name, severity := range cfg.Severities {
+		severities[strings.ToLower(name)] = severity
+	}
+	loc := time.Local
+	if cfg.Timezone != "" {
+		loc, _ = time.LoadLocation(cfg.Timezone)
+	}
+	return &zabbixInput{severities: severities, labels: cfg.Labels, loc: loc, accept: accept}
+}
+
+func (z *zabbixInput) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeWebhookError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+		return
+	}
+
+	var event zabbixEvent
+	if err := decodeJSON(json.NewDecoder(r.Body), &event, false); err != nil {
+		legacyNotifications.WithLabelValues("zabbix", "malformed").Inc()
+		loggerFrom(r.Context()).Warn("Error decoding Zabbix event", "err", err)
+		writeDecodeError(w, err)
+		return
+	}
+	if macroValue(event.EventID) == "" || macroValue(event.HostHost) == "" {
+		legacyNotifications.WithLabelValues("zabbix", "malformed").Inc()
+		writeDecodeError(w, fmt.Errorf("event_id and host_host are required"))
+		return
+	}
+	if event.EventUpdateStatus == "1" {
+		legacyNotifications.WithLabelValues("zabbix", "ignored").Inc()
+		loggerFrom(r.Context()).Debug("Ignoring Zabbix event update", "event_id", event.EventID)
+		fmt.Fprint(w, "Event updates are not forwarded")
+		return
+	}
+	legacyNotifications.WithLabelValues("zabbix", "accepted").Inc()
+	z.accept(w, r, z.payload(event))
+}
+
+// payload translates the event. The alert is keyed by the problem's event ID, which
+// its recovery notification carries too, so the recovery resolves it.
+func (z *zabbixInput) payload(event zabbixEvent) AlertmanagerPayload {
+	host := macroValue(event.HostHost)
+	name := macroValue(event.EventName)
+	if name == "" {
+		name = "ZabbixProblem"
+	}
+	labels := map[string]string{"alertname": name, "instance": host, "job": "zabbix"}
+	severity := macroValue(event.EventSeverity)
+	if s, ok := z.severities[strings.ToLower(severity)]; ok {
+		labels["severity"] = s
+	} else if severity != "" {
+		labels["severity"] = strings.ToLower(severity)
+	}
+	// Tags become labels without replacing the ones above.
+	for _, tag := range zabbixTags(event.EventTags) {
+		if label := labelName(tag.Tag); label != "" && labels[label] == "" {
+			labels[label] = tag.Value
+		}
+	}
+	for label, value := range z.labels {
+		if _, ok := labels[label]; !ok {
+			labels[label] = value
+		}
+	}
+
+	alert := Alert{
+		Labels:      labels,
+		Annotations: map[string]string{"summary": name, "zabbix_event_id": event.EventID},
+		Status:      "firing",
+		StartsAt:    z.timestamp(event.EventDate, event.EventTime),
+		Fingerprint: labelsFingerprint(map[string]string{"zabbix_event_id": event.EventID}),
+	}
+	for annotation, value := range map[string]string{
+		"description":    macroValue(event.TriggerDescription),
+		"value":          macroValue(event.EventOpdata),
+		"zabbix_host":    macroValue(event.HostName),
+		"zabbix_host_ip": macroValue(event.HostIP),
+	} {
+		if value != "" {
+			alert.Annotations[annotation] = value
+		}
+	}
+	if base := strings.TrimRight(macroValue(event.ZabbixURL), "/"); base != "" && macroValue(event.TriggerID) != "" {
+		alert.GeneratorURL = base + "/tr_events.php?" + url.Values{"triggerid": {event.TriggerID}, "eventid": {event.EventID}}.Encode()
+	}
+	if event.EventValue == "0" {
+		alert.Status = "resolved"
+		alert.EndsAt = z.timestamp(event.RecoveryDate, event.RecoveryTime)
+	}
+	return AlertmanagerPayload{
+		GroupKey:     "zabbix/" + host + "/" + event.EventID,
+		Status:       alert.Status,
+		Receiver:     "zabbix",
+		GroupLabels:  map[string]string{"alertname": name, "instance": host},
+		CommonLabels: labels,
+		Alerts:       []Alert{alert},
+	}
+}
+
+// timestamp converts {EVENT.DATE} and {EVENT.TIME}, given in the Zabbix server's time
+// zone, to RFC 3339; events without them are stamped with the current time.
+func (z *zabbixInput) timestamp(date, clock string) string {
+	t, err := time.ParseInLocation("2006.01.02 15:04:05", macroValue(date)+" "+macroValue(clock), z.loc)
+	if err != nil {
+		t = time.Now()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// zabbixTags reads {EVENT.TAGSJSON}, which media type parameters pass as a string of
+// JSON; an array is accepted as well.
+func zabbixTags(raw json.RawMessage) []zabbixTag {
+	var tags []zabbixTag
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		raw = json.RawMessage(text)
+	}
+	json.Unmarshal(raw, &tags)
+	return tags
+}
+
+// macroValue returns v, or "" if it is a macro Zabbix could not expand.
+func macroValue(v string) string {
+	if unexpandedMacro.MatchString(v) {
+		return ""
+	}
+	return strings.TrimSpace(v)
+}
+
+// invalidLabelChars matches what Prometheus label names may not contain.
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// labelName turns a tag name into a label name, e.g. "GPU model" into "gpu_model".
+func labelName(tag string) string {
+	name := strings.ToLower(invalidLabelChars.ReplaceAllString(strings.TrimSpace(tag), "_"))
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,127 @@
+// The gRPC service between the node agents (gpu-collector) and the central aggregator
+// (alertmanager-adapter), for deployments where Prometheus cannot scrape the nodes.
+// Agents connect with mTLS and keep one Push stream open, sending a report every
+// interval and as soon as they raise or resolve an alert.
+//
+// The Go stubs are generated into collector/aggregatorpb and
+// gchat_adapter_build/internal/aggregatorpb with "go generate" in each module (protoc,
+// protoc-gen-go and protoc-gen-go-grpc); regenerate both after changing this file.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: aggregator.proto
+
+package aggregatorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Aggregator_Push_FullMethodName = "/gpunodemonitor.v1.Aggregator/Push"
+)
+
+// AggregatorClient is the client API for Aggregator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AggregatorClient interface {
+	// Push streams the reports of one node. The aggregator answers once the agent ends
+	// the stream, or with an error status when it drops it.
+	Push(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[NodeReport, PushResponse], error)
+}
+
+type aggregatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAggregatorClient(cc grpc.ClientConnInterface) AggregatorClient {
+	return &aggregatorClient{cc}
+}
+
+func (c *aggregatorClient) Push(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[NodeReport, PushResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Aggregator_ServiceDesc.Streams[0], Aggregator_Push_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[NodeReport, PushResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Aggregator_PushClient = grpc.ClientStreamingClient[NodeReport, PushResponse]
+
+// AggregatorServer is the server API for Aggregator service.
+// All implementations must embed UnimplementedAggregatorServer
+// for forward compatibility.
+type AggregatorServer interface {
+	// Push streams the reports of one node. The aggregator answers once the agent ends
+	// the stream, or with an error status when it drops it.
+	Push(grpc.ClientStreamingServer[NodeReport, PushResponse]) error
+	mustEmbedUnimplementedAggregatorServer()
+}
+
+// UnimplementedAggregatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAggregatorServer struct{}
+
+func (UnimplementedAggregatorServer) Push(grpc.ClientStreamingServer[NodeReport, PushResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+func (UnimplementedAggregatorServer) mustEmbedUnimplementedAggregatorServer() {}
+func (UnimplementedAggregatorServer) testEmbeddedByValue()                    {}
+
+// UnsafeAggregatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AggregatorServer will
+// result in compilation errors.
+type UnsafeAggregatorServer interface {
+	mustEmbedUnimplementedAggregatorServer()
+}
+
+func RegisterAggregatorServer(s grpc.ServiceRegistrar, srv AggregatorServer) {
+	// If the following call pancis, it indicates UnimplementedAggregatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Aggregator_ServiceDesc, srv)
+}
+
+func _Aggregator_Push_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AggregatorServer).Push(&grpc.GenericServerStream[NodeReport, PushResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Aggregator_PushServer = grpc.ClientStreamingServer[NodeReport, PushResponse]
+
+// Aggregator_ServiceDesc is the grpc.ServiceDesc for Aggregator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Aggregator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gpunodemonitor.v1.Aggregator",
+	HandlerType: (*AggregatorServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Push",
+			Handler:       _Aggregator_Push_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "aggregator.proto",
+}
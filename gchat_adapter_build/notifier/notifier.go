@@ -0,0 +1,38 @@
+// Package notifier defines what an output backend of the alertmanager adapter delivers
+// and the registry backends add themselves to.
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Notifier delivers rendered notifications to one kind of destination, e.g. Slack
+// webhooks or SMTP recipients.
+type Notifier interface {
+	// Name identifies the backend in the outputs setting, logs and queued notifications.
+	Name() string
+	// Send delivers a notification rendered for this backend. ctx carries the trace the
+	// delivery belongs to.
+	Send(ctx context.Context, n Notification) error
+}
+
+// Notification is a fully rendered message bound for a single destination. It is
+// stored as JSON in the outbound queue, so the field names must stay stable.
+type Notification struct {
+	// Backend is the notifier name; empty means "gchat" for notifications queued
+	// before multiple backends existed.
+	Backend string `json:"backend,omitempty"`
+	// Destination is the webhook URL, or what takes its place for the backend (e.g.
+	// email recipients or a PagerDuty routing key).
+	Destination string          `json:"webhookURL"`
+	Body        json.RawMessage `json:"message"`
+	// Alerts is the number of alerts the notification reports on.
+	Alerts int `json:"alerts,omitempty"`
+	// RenderedAt is used to measure end-to-end forwarding latency.
+	RenderedAt time.Time `json:"renderedAt,omitempty"`
+	// Trace is the trace context of the webhook the notification was rendered for, so
+	// its delivery continues that trace even from the outbound queue.
+	Trace map[string]string `json:"trace,omitempty"`
+}
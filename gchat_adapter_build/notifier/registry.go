@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry maps backend names to the factories that build them. F is the factory type,
+// which is up to the program since it decides what configuration a backend is built
+// from. Backends register from an init function, so adding one only takes a new file.
+type Registry[F any] struct {
+	mu        sync.RWMutex
+	factories map[string]F
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry[F any]() *Registry[F] {
+	return &Registry[F]{factories: make(map[string]F)}
+}
+
+// Register adds the factory for the backend name. It panics if name is already
+// registered, like database/sql.Register, since that is a programming error.
+func (r *Registry[F]) Register(name string, factory F) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[name]; ok {
+		panic(fmt.Sprintf("notifier: backend %q registered twice", name))
+	}
+	r.factories[name] = factory
+}
+
+// Lookup returns the factory registered for name.
+func (r *Registry[F]) Lookup(name string) (F, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Names returns the registered backend names in alphabetical order.
+func (r *Registry[F]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}